@@ -17,10 +17,10 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
-	"github.com/fatih/color"
-	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
-	"github.com/feiskyer/kube-copilot/pkg/utils"
 	"github.com/feiskyer/kube-copilot/pkg/workflows"
 	"github.com/spf13/cobra"
 )
@@ -28,40 +28,75 @@ import (
 var analysisName string
 var analysisNamespace string
 var analysisResource string
+var analysisFile string
 
 func init() {
 	analyzeCmd.PersistentFlags().StringVarP(&analysisName, "name", "", "", "Resource name")
 	analyzeCmd.PersistentFlags().StringVarP(&analysisNamespace, "namespace", "n", "default", "Resource namespace")
 	analyzeCmd.PersistentFlags().StringVarP(&analysisResource, "resource", "r", "pod", "Resource type")
-	analyzeCmd.MarkFlagRequired("name")
+	analyzeCmd.PersistentFlags().StringVarP(&analysisFile, "file", "f", "", "Analyze the manifests in the file instead of the live resource (use - for stdin)")
+	analyzeCmd.MarkFlagsMutuallyExclusive("name", "file")
+	addFailOnFlag(analyzeCmd)
+	registerResourceCompletions(analyzeCmd, "pods")
 }
 
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze",
 	Short: "Analyze issues for a given resource",
 	Run: func(cmd *cobra.Command, args []string) {
-		if analysisName == "" && len(args) > 0 {
+		if analysisName == "" && analysisFile == "" && len(args) > 0 {
 			analysisName = args[0]
 		}
-		if analysisName == "" {
-			fmt.Println("Please provide a resource name")
+		if analysisName == "" && analysisFile == "" {
+			printError("analyze", fmt.Errorf("please provide a resource name or a manifest file"))
 			return
 		}
 
-		fmt.Printf("Analysing %s %s/%s\n", analysisResource, analysisNamespace, analysisName)
-
-		manifests, err := kubernetes.GetYaml(analysisResource, analysisName, analysisNamespace)
-		if err != nil {
-			color.Red(err.Error())
-			return
+		var response, target string
+		var err error
+		if analysisFile != "" {
+			printStatus("Analysing manifests from %s\n", analysisFile)
+			var manifests string
+			if manifests, err = readManifests(analysisFile); err != nil {
+				printError("analyze", err)
+				return
+			}
+			target = analysisFile
+			response, err = workflows.AnalysisFlow(model, manifests, verbose)
+		} else {
+			// The manifest is fetched together with the events and logs of the resource.
+			printStatus("Analysing %s %s/%s\n", analysisResource, analysisNamespace, analysisName)
+			target = fmt.Sprintf("%s/%s/%s", analysisResource, analysisNamespace, analysisName)
+			response, err = workflows.AnalyzeResourceFlow(model, analysisResource, analysisName, analysisNamespace, verbose)
 		}
-
-		response, err := workflows.AnalysisFlow(model, manifests, verbose)
 		if err != nil {
-			color.Red(err.Error())
+			printError("analyze", err)
 			return
 		}
 
-		utils.RenderMarkdown(response)
+		result := newRunResult("analyze", target, response, nil)
+		saveHistory("analyze", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
 	},
 }
+
+// readManifests reads the manifests from the file, or from stdin if file is "-".
+func readManifests(file string) (string, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	manifests := strings.TrimSpace(string(data))
+	if manifests == "" {
+		return "", fmt.Errorf("no manifests found in %s", file)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/apiaudit"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	apiauditFiles    []string
+	apiauditAddr     string
+	apiauditCertFile string
+	apiauditKeyFile  string
+)
+
+func init() {
+	apiauditCmd.PersistentFlags().StringSliceVarP(&apiauditFiles, "file", "f", nil, "API server audit log files (defaults to the events received by \"apiaudit receive\")")
+	apiauditReceiveCmd.Flags().StringVarP(&apiauditAddr, "addr", "", ":8444", "Address to listen on")
+	apiauditReceiveCmd.Flags().StringVarP(&apiauditCertFile, "tls-cert-file", "", "", "TLS certificate file (serves plain HTTP if empty)")
+	apiauditReceiveCmd.Flags().StringVarP(&apiauditKeyFile, "tls-private-key-file", "", "", "TLS private key file")
+
+	apiauditCmd.AddCommand(apiauditAskCmd)
+	apiauditCmd.AddCommand(apiauditReceiveCmd)
+}
+
+var apiauditCmd = &cobra.Command{
+	Use:   "apiaudit",
+	Short: "Investigate the Kubernetes API server audit logs",
+	Long: `Answer questions like "who deleted the nginx deployment" or "what changed in
+namespace web in the last hour" from the API server audit logs, either files of
+the log backend (--audit-log-path) or events received from the webhook backend
+with "apiaudit receive".`,
+}
+
+var apiauditAskCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Ask a question about the changes and accesses in the cluster",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := apiauditFiles
+		if len(files) == 0 {
+			path, err := apiaudit.DefaultPath()
+			if err != nil {
+				return err
+			}
+			files = []string{path}
+		}
+		events, err := apiaudit.ReadFiles(files...)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return fmt.Errorf("no audit events found in %s", strings.Join(files, ", "))
+		}
+
+		question := strings.Join(args, " ")
+		printStatus("Searching %d audit events\n", len(events))
+		response, err := workflows.APIAuditFlow(model, question, events, verbose)
+		if err != nil {
+			printError("apiaudit", err)
+			return nil
+		}
+
+		result := newRunResult("apiaudit", strings.Join(files, ","), response, nil)
+		printResult(result, response)
+		return nil
+	},
+}
+
+var apiauditReceiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "Receive the audit events of the API server webhook backend",
+	Long: `Serve the webhook backend of the API server audit logs (--audit-webhook-config-file)
+and append the received events to ~/.kube-copilot/apiserver-audit.jsonl, or to
+--file, for "apiaudit ask". The webhook kubeconfig should point at
+https://<host>:8444/events.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (apiauditCertFile == "") != (apiauditKeyFile == "") {
+			return fmt.Errorf("--tls-cert-file and --tls-private-key-file should be set together")
+		}
+		if len(apiauditFiles) > 1 {
+			return fmt.Errorf("only one --file can be set to receive the events")
+		}
+		path, err := apiaudit.DefaultPath()
+		if err != nil {
+			return err
+		}
+		if len(apiauditFiles) == 1 {
+			path = apiauditFiles[0]
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/events", &apiaudit.Receiver{Path: path})
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+		server := &http.Server{Addr: apiauditAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintln(os.Stderr, color.BlueString("kube-copilot apiaudit receiver listening on %s, writing to %s", apiauditAddr, path))
+		if apiauditCertFile != "" {
+			err = server.ListenAndServeTLS(apiauditCertFile, apiauditKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	},
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/rollback"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// applyWatch is how long the workloads are watched after a change (--watch).
+var applyWatch time.Duration
+
+func init() {
+	addWatchFlag(applyCmd)
+}
+
+// addWatchFlag adds the --watch flag of the changes applied with rollback.
+func addWatchFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().DurationVarP(&applyWatch, "watch", "", rollback.DefaultWatch, "How long to watch the health of the changed workloads before keeping the change")
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Apply manifests, watch the health of their workloads and roll back if they degrade",
+	Long: `Apply the manifests of a fix (from a file, or "-" for stdin) with rollback:
+snapshot the current objects, apply the change, watch the health of the changed
+workloads for --watch, and restore the snapshots if a workload degrades (e.g.
+crash looping Pods or an exceeded progress deadline) or is not healthy by the
+end of the watch. Every step is recorded in the audit log.
+
+The manifests read from stdin are applied without confirmation, which
+requires --yes.`,
+	Example: `  kube-copilot apply fix.yaml --watch 10m
+  cat fix.yaml | kube-copilot apply - --yes
+  kube-copilot diagnose mypod --fix`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			if !assumeYes {
+				// The confirmation would be read from the manifests.
+				return fmt.Errorf("applying the manifests from stdin requires --yes")
+			}
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		return applyWithRollback(string(data), applyWatch)
+	},
+}
+
+// applyWithRollback validates the manifests, asks for the approval of the
+// user and applies them with rollback. It returns an error unless the change is kept.
+func applyWithRollback(manifests string, watch time.Duration) error {
+	if readOnly {
+		return fmt.Errorf("applying manifests is not allowed in read-only mode")
+	}
+	if watch <= 0 {
+		return fmt.Errorf("watch should be positive")
+	}
+	if err := kubernetes.ValidateYaml(manifests); err != nil {
+		return fmt.Errorf("validation failed: %v", err)
+	}
+	printStatus("%s\n", color.GreenString("Validation passed (server-side dry-run)"))
+
+	if !assumeYes {
+		event := auditlog.Event{Type: auditlog.EventApproval, Tool: "apply", Input: kubernetes.SummarizeManifests(manifests), Decision: auditlog.DecisionApproved}
+		approved := utils.Confirm(color.RedString("Do you approve to apply the manifests, rolling them back if the workloads degrade within %s?", watch))
+		if !approved {
+			event.Decision = auditlog.DecisionDeclined
+		}
+		auditlog.Record(event)
+		if !approved {
+			return nil
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	applier := rollback.New(watch)
+	applier.Progress = func(msg string) { printStatus("%s\n", msg) }
+	result, err := applier.Run(ctx, manifests)
+	if err != nil {
+		return err
+	}
+
+	if isStructuredOutput() {
+		printResult(result, "")
+	}
+	switch result.Outcome {
+	case rollback.OutcomeHealthy:
+		printStatus("%s\n", color.GreenString("The change is applied and the workloads are healthy."))
+		return nil
+	case rollback.OutcomeRolledBack:
+		return fmt.Errorf("the change was rolled back: %s", result.Reason)
+	default:
+		return fmt.Errorf("the change could not be rolled back, restore the objects manually: %s", result.Reason)
+	}
+}
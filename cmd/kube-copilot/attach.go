@@ -0,0 +1,89 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+)
+
+// attachmentExtensions are the extensions of the files read from the attached directories.
+var attachmentExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+	".md":   true,
+	".txt":  true,
+}
+
+// maxAttachmentsLength is the maximum total length of the attached files.
+const maxAttachmentsLength = 200000
+
+// readAttachments returns the attached files as a markdown section appended to
+// the instructions. Directories are read recursively for manifests and
+// documents, and "-" reads the standard input. Credentials are redacted.
+func readAttachments(paths []string) (string, error) {
+	var sb strings.Builder
+	add := func(name string, content []byte) error {
+		if sb.Len()+len(content) > maxAttachmentsLength {
+			return fmt.Errorf("the attached files exceed %d bytes, attach fewer files", maxAttachmentsLength)
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n```\n%s\n```\n", name, strings.TrimSpace(utils.Redact(string(content))))
+		return nil
+	}
+
+	for _, path := range paths {
+		if path == "-" {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return "", err
+			}
+			if err := add("stdin", content); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Explicitly attached files are read whatever their extension.
+			if entry.IsDir() || (file != path && !attachmentExtensions[strings.ToLower(filepath.Ext(file))]) {
+				return nil
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			return add(file, content)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "", nil
+	}
+	return "\n\n# Attached files\n" + sb.String(), nil
+}
@@ -17,9 +17,8 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/fatih/color"
-	"github.com/feiskyer/kube-copilot/pkg/utils"
 	"github.com/feiskyer/kube-copilot/pkg/workflows"
 	"github.com/spf13/cobra"
 )
@@ -27,33 +26,97 @@ import (
 var (
 	auditName      string
 	auditNamespace string
+	scanWorkers    int
 )
 
 func init() {
 	auditCmd.PersistentFlags().StringVarP(&auditName, "name", "", "", "Pod name")
 	auditCmd.PersistentFlags().StringVarP(&auditNamespace, "namespace", "n", "default", "Pod namespace")
-	auditCmd.MarkFlagRequired("name")
+	auditCmd.PersistentFlags().IntVarP(&scanWorkers, "scan-workers", "", workflows.ScanWorkers, "Number of images scanned concurrently when auditing a namespace")
+	addFailOnFlag(auditCmd)
+	registerResourceCompletions(auditCmd, "pods")
+	auditCmd.ValidArgsFunction = completeAuditTargets
 }
 
 var auditCmd = &cobra.Command{
-	Use:   "audit",
-	Short: "Audit security issues for a Pod",
+	Use:   "audit [pod | namespace/<namespace>]",
+	Short: "Audit security issues for a Pod or all Pods in a namespace",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if auditName == "" && len(args) > 0 {
 			auditName = args[0]
 		}
+		if namespace, ok := strings.CutPrefix(auditName, "namespace/"); ok {
+			auditNamespaceFlow(namespace)
+			return
+		}
+		auditName = strings.TrimPrefix(auditName, "pod/")
 		if auditName == "" {
-			fmt.Println("Please provide a pod name")
+			printError("audit", fmt.Errorf("please provide a pod name or namespace/<namespace>"))
 			return
 		}
 
-		fmt.Printf("Auditing Pod %s/%s\n", auditNamespace, auditName)
+		printStatus("Auditing Pod %s/%s\n", auditNamespace, auditName)
 		response, err := workflows.AuditFlow(model, auditNamespace, auditName, verbose)
 		if err != nil {
-			color.Red(err.Error())
+			printError("audit", err)
 			return
 		}
 
-		utils.RenderMarkdown(response)
+		printAuditResults([]workflows.AuditResult{{Namespace: auditNamespace, Name: auditName, Report: response}})
 	},
 }
+
+// auditNamespaceFlow audits all the Pods in the namespace.
+func auditNamespaceFlow(namespace string) {
+	printStatus("Auditing all Pods in namespace %s\n", namespace)
+	workflows.ScanWorkers = scanWorkers
+	results, err := workflows.BatchAuditFlow(model, namespace, verbose)
+	if err != nil {
+		printError("audit", err)
+		return
+	}
+	if len(results) == 0 {
+		printStatus("No Pods found in namespace %s\n", namespace)
+		return
+	}
+
+	printAuditResults(results)
+}
+
+// printAuditResults prints the audit results in the requested output format.
+// Structured output is always a list, even when a single Pod is audited.
+func printAuditResults(results []workflows.AuditResult) {
+	runResults := make([]*runResult, 0, len(results))
+	var findings []workflows.Finding
+	for _, result := range results {
+		r := newRunResult("audit", fmt.Sprintf("pod/%s/%s", result.Namespace, result.Name), result.Report, nil)
+		r.Error, r.ErrorCode = result.Error, result.ErrorCode
+		runResults = append(runResults, r)
+		findings = append(findings, r.Findings...)
+	}
+
+	answer := results[0].Report
+	if len(results) > 1 || results[0].Error != "" {
+		var sb strings.Builder
+		for _, result := range results {
+			sb.WriteString(fmt.Sprintf("# Pod %s/%s\n\n", result.Namespace, result.Name))
+			if result.Error != "" {
+				sb.WriteString(fmt.Sprintf("Audit failed: %s\n\n", result.Error))
+				continue
+			}
+			sb.WriteString(result.Report + "\n\n")
+		}
+		answer = sb.String()
+	}
+
+	saveHistory("audit", answer, runResults)
+	printResult(runResults, answer)
+	checkFailOn(findings)
+	for _, result := range results {
+		if result.Error != "" {
+			// The findings of the failed Pods are unknown.
+			exitCode = 1
+		}
+	}
+}
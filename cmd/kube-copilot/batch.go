@@ -0,0 +1,142 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"sigs.k8s.io/yaml"
+)
+
+// batchTask is a task in the execute --from-file tasks file.
+type batchTask struct {
+	Name         string `json:"name"`
+	Instructions string `json:"instructions"`
+}
+
+// batchFile is the format of the execute --from-file tasks file:
+//
+//	tasks:
+//	  - name: Check nodes
+//	    instructions: Check whether all the nodes are ready.
+type batchFile struct {
+	Tasks []batchTask `json:"tasks"`
+}
+
+// loadBatchTasks reads the tasks from a YAML or JSON file.
+func loadBatchTasks(file string) ([]batchTask, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch batchFile
+	if err := yaml.UnmarshalStrict(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	if len(batch.Tasks) == 0 {
+		return nil, fmt.Errorf("no tasks found in %s", file)
+	}
+	for i := range batch.Tasks {
+		if strings.TrimSpace(batch.Tasks[i].Instructions) == "" {
+			return nil, fmt.Errorf("task %d in %s has no instructions", i+1, file)
+		}
+		if batch.Tasks[i].Name == "" {
+			batch.Tasks[i].Name = fmt.Sprintf("Task %d", i+1)
+		}
+	}
+	return batch.Tasks, nil
+}
+
+// runBatch runs the tasks with at most concurrency tasks in parallel and
+// prints a combined report in the task order.
+func runBatch(tasks []batchTask, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Confirmations of parallel tasks are asked one at a time.
+	var confirmLock sync.Mutex
+	confirm := toolCallConfirmer()
+	if ask := confirm; ask != nil {
+		confirm = func(call workflows.ToolCall) (workflows.ToolCall, bool) {
+			confirmLock.Lock()
+			defer confirmLock.Unlock()
+			return ask(call)
+		}
+	}
+
+	results := make([]*runResult, len(tasks))
+	answers := make([]string, len(tasks))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task batchTask) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "%s\n", color.BlueString("[%d/%d] Running %s", i+1, len(tasks), task.Name))
+			}
+			results[i], answers[i] = runBatchTask(task, confirm)
+		}(i, task)
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	for i, task := range tasks {
+		fmt.Fprintf(&sb, "# %d. %s\n\n", i+1, task.Name)
+		if results[i].Error != "" {
+			fmt.Fprintf(&sb, "Task failed: %s\n\n", results[i].Error)
+			continue
+		}
+		sb.WriteString(answers[i] + "\n\n")
+	}
+
+	saveHistory("execute", sb.String(), results)
+	printResult(results, sb.String())
+}
+
+// runBatchTask runs a single task and returns its result and markdown answer.
+func runBatchTask(task batchTask, confirm func(workflows.ToolCall) (workflows.ToolCall, bool)) (*runResult, string) {
+	flow, err := workflows.NewReActFlow(model, task.Instructions, verbose, maxIterations)
+	if err != nil {
+		result := &runResult{Command: "execute", Model: model, Target: task.Name}
+		result.setError(err)
+		return result, ""
+	}
+	flow.DryRun = dryRun
+	flow.ConfirmToolCall = confirm
+
+	response, err := flow.Run()
+	result := newRunResult("execute", task.Name, response, flow)
+	if err != nil {
+		result.setError(err)
+	}
+
+	answer := response
+	if dryRun {
+		answer += formatSkippedToolCalls(flow.SkippedToolCalls)
+	}
+	return result, answer
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/cassette"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+var (
+	// recordFile is the cassette file the LLM and tool interactions are recorded to.
+	recordFile string
+	// replayFile is the cassette file the LLM and tool interactions are replayed from.
+	replayFile string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&recordFile, "record", "", "", "Record the LLM and tool interactions to the cassette file")
+	rootCmd.PersistentFlags().StringVarP(&replayFile, "replay", "", "", "Replay the LLM and tool interactions from the cassette file instead of calling the LLM and the cluster")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+}
+
+// setupCassette records or replays the LLM and tool interactions per --record and --replay.
+func setupCassette() error {
+	if workflows.Cassette != nil {
+		return nil
+	}
+
+	switch {
+	case recordFile != "":
+		workflows.Cassette = cassette.Record(recordFile)
+		if err := workflows.Cassette.Save(); err != nil {
+			return fmt.Errorf("failed to create cassette: %v", err)
+		}
+	case replayFile != "":
+		c, err := cassette.Load(replayFile)
+		if err != nil {
+			return err
+		}
+		workflows.Cassette = c
+	default:
+		return nil
+	}
+
+	workflows.Cassette.WrapTools(tools.CopilotTools)
+	return nil
+}
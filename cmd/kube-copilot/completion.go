@@ -0,0 +1,84 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/spf13/cobra"
+)
+
+// completeNamespaces completes the namespaces from the live cluster.
+func completeNamespaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	namespaces, err := kubernetes.ListNamespaces()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return namespaces, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeResourceNames returns a completion function for the names of the
+// resource type in the namespace of the --namespace flag. The resource type
+// is read from the --resource flag if set.
+func completeResourceNames(resource string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		resourceType := resource
+		if flag := cmd.Flags().Lookup("resource"); flag != nil {
+			resourceType = flag.Value.String()
+		}
+		namespace := ""
+		if flag := cmd.Flags().Lookup("namespace"); flag != nil {
+			namespace = flag.Value.String()
+		}
+
+		names, err := kubernetes.ListResourceNames(resourceType, namespace)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeAuditTargets completes the Pod names, or namespace/<namespace> for namespace-wide audits.
+func completeAuditTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	if strings.HasPrefix(toComplete, "namespace/") {
+		namespaces, _ := completeNamespaces(cmd, args, toComplete)
+		for i := range namespaces {
+			namespaces[i] = "namespace/" + namespaces[i]
+		}
+		return namespaces, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	pods, directive := completeResourceNames("pods")(cmd, args, toComplete)
+	return append(pods, "namespace/"), directive | cobra.ShellCompDirectiveNoSpace
+}
+
+// registerResourceCompletions registers the completions of the --name and
+// --namespace flags and the positional resource name of the command.
+func registerResourceCompletions(cmd *cobra.Command, resource string) {
+	cmd.ValidArgsFunction = completeResourceNames(resource)
+	cmd.RegisterFlagCompletionFunc("name", completeResourceNames(resource))
+	cmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/config"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/secrets"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables overriding the configuration file.
+const (
+	envModel         = "KUBE_COPILOT_MODEL"
+	envProvider      = "KUBE_COPILOT_PROVIDER"
+	envLanguage      = "KUBE_COPILOT_LANGUAGE"
+	envReadOnly      = "KUBE_COPILOT_READ_ONLY"
+	envOffline       = "KUBE_COPILOT_OFFLINE"
+	envAirGapped     = "KUBE_COPILOT_AIR_GAPPED"
+	envTrivyCache    = "KUBE_COPILOT_TRIVY_CACHE_DIR"
+	envTrivySeverity = "KUBE_COPILOT_TRIVY_SEVERITY"
+	envMaxTokens     = "KUBE_COPILOT_MAX_TOKENS"
+	envAPIKeySecret  = "KUBE_COPILOT_API_KEY_SECRET"
+	envAuditLog      = "KUBE_COPILOT_AUDIT_LOG"
+	envTheme         = "KUBE_COPILOT_THEME"
+	envNotify        = "KUBE_COPILOT_NOTIFY"
+	envTicket        = "KUBE_COPILOT_TICKET"
+	envGrafanaURL    = "KUBE_COPILOT_GRAFANA_URL"
+	envPluginsDir    = "KUBE_COPILOT_PLUGINS_DIR"
+	envPolicy        = "KUBE_COPILOT_POLICY"
+	envPrometheus    = "KUBE_COPILOT_PROMETHEUS_URL"
+	envTenancy       = "KUBE_COPILOT_TENANCY"
+	envPostProcess   = "KUBE_COPILOT_POST_PROCESS"
+	envDisclaimer    = "KUBE_COPILOT_DISCLAIMER"
+	envSummaryModel  = "KUBE_COPILOT_SUMMARY_MODEL"
+	envPromptsDir    = "KUBE_COPILOT_PROMPTS_DIR"
+	envPromptVersion = "KUBE_COPILOT_PROMPT_VERSIONS"
+)
+
+var (
+	// appliedBaseURL is the base URL environment variable value set from the configuration file.
+	appliedBaseURL string
+	// appliedAPIKey is the API key environment variable value resolved from the secret backend.
+	appliedAPIKey string
+)
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file (~/.kube-copilot/config.yaml)",
+	// Skip loading the configuration so that a broken file can still be fixed.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+}
+
+var configGetCmd = &cobra.Command{
+	Use:       "get [key]",
+	Short:     "Print the value of a configuration key (or all keys)",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: config.Keys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, cfg, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+
+		keys := config.Keys
+		if len(args) > 0 {
+			keys = args
+		}
+		for _, key := range keys {
+			value, err := cfg.Get(key)
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				fmt.Println(value)
+			} else {
+				fmt.Printf("%s: %s\n", key, value)
+			}
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:       "set <key> <value>",
+	Short:     "Set the value of a configuration key",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: config.Keys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, cfg, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return err
+		}
+		if err := cfg.Save(path); err != nil {
+			return err
+		}
+
+		if err := openAuditLog(cmd, cfg); err != nil {
+			return err
+		}
+		value, _ := cfg.Get(args[0])
+		auditlog.Record(auditlog.Event{Type: auditlog.EventConfigChange, Key: args[0], Value: value, Status: auditlog.StatusSucceeded})
+		return nil
+	},
+}
+
+// loadConfigFile loads the user-level configuration file.
+func loadConfigFile() (string, *config.Config, error) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := config.Load(path)
+	return path, cfg, err
+}
+
+// openAuditLog opens the audit log configured by KUBE_COPILOT_AUDIT_LOG or the configuration file.
+func openAuditLog(cmd *cobra.Command, cfg *config.Config) error {
+	auditlog.Command = strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().CommandPath()))
+	auditlog.Context = kubernetes.Context
+
+	path := firstNonEmpty(os.Getenv(envAuditLog), cfg.AuditLog)
+	switch path {
+	case "off":
+		return auditlog.Close()
+	case "":
+		var err error
+		if path, err = auditlog.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	if err := auditlog.Open(path); err != nil {
+		return fmt.Errorf("failed to open audit log %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadConfig resolves the settings with precedence flags > environment variables > configuration file.
+func loadConfig(cmd *cobra.Command) error {
+	path, cfg, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%v (edit %s or run \"kube-copilot config set\")", err, path)
+	}
+	if err := openAuditLog(cmd, cfg); err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("model") {
+		model = firstNonEmpty(os.Getenv(envModel), cfg.Model, model)
+	}
+	if !flags.Changed("max-tokens") {
+		if value := os.Getenv(envMaxTokens); value != "" {
+			if maxTokens, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid %s %q: %v", envMaxTokens, value, err)
+			}
+		} else if cfg.MaxTokens > 0 {
+			maxTokens = cfg.MaxTokens
+		}
+	}
+	if !flags.Changed("read-only") {
+		if value := os.Getenv(envReadOnly); value != "" {
+			if readOnly, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid %s %q: %v", envReadOnly, value, err)
+			}
+		} else {
+			readOnly = cfg.ReadOnly
+		}
+	}
+	tools.ReadOnly = readOnly
+	if !flags.Changed("offline") {
+		if value := os.Getenv(envOffline); value != "" {
+			if offline, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid %s %q: %v", envOffline, value, err)
+			}
+		} else {
+			offline = cfg.Offline
+		}
+	}
+	tools.Offline = offline
+	kubernetes.Offline = offline
+	if !flags.Changed("air-gapped") {
+		if value := os.Getenv(envAirGapped); value != "" {
+			if airGapped, err = strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid %s %q: %v", envAirGapped, value, err)
+			}
+		} else {
+			airGapped = cfg.AirGapped
+		}
+	}
+	tools.AirGapped = airGapped
+	workflows.AirGapped = airGapped
+	if store, err := artifactStore(); err == nil {
+		workflows.ArtifactStore = store
+	}
+	tools.TrivyCacheDir = firstNonEmpty(os.Getenv(envTrivyCache), cfg.TrivyCacheDir)
+	tools.TrivySkipUpdate = cfg.TrivySkipUpdate
+	if severity := firstNonEmpty(os.Getenv(envTrivySeverity), cfg.TrivySeverity); severity != "" {
+		if !tools.ValidTrivySeverity(severity) {
+			return fmt.Errorf("invalid %s %q, should be one of %s", envTrivySeverity, severity, strings.Join(tools.TrivySeverities, ", "))
+		}
+		tools.TrivySeverity = strings.ToUpper(severity)
+	}
+	if ttl, err := time.ParseDuration(cfg.TrivyCacheTTL); err == nil {
+		tools.TrivyCacheTTL = ttl
+	}
+
+	workflows.Provider = firstNonEmpty(os.Getenv(envProvider), cfg.Provider)
+	workflows.Language = firstNonEmpty(os.Getenv(envLanguage), cfg.Language)
+	postProcess := cfg.PostProcess
+	if value := os.Getenv(envPostProcess); value != "" {
+		postProcess = strings.Split(value, ",")
+	}
+	if len(postProcess) > 0 {
+		chain, err := workflows.ParsePostProcess(postProcess)
+		if err != nil {
+			return fmt.Errorf("invalid post-processing chain: %v", err)
+		}
+		workflows.PostProcess = chain
+	}
+	workflows.Disclaimer = firstNonEmpty(os.Getenv(envDisclaimer), cfg.Disclaimer)
+	summaryModel = firstNonEmpty(os.Getenv(envSummaryModel), cfg.SummaryModel)
+	promptsDir = firstNonEmpty(os.Getenv(envPromptsDir), cfg.PromptsDir)
+	configPromptVersions = cfg.PromptVersions
+	if value := os.Getenv(envPromptVersion); value != "" {
+		configPromptVersions = strings.Split(value, ",")
+	}
+	utils.Theme = strings.ToLower(firstNonEmpty(os.Getenv(envTheme), cfg.Theme))
+	if !flags.Changed("notify") {
+		notifyTargets = cfg.Notify
+		if value := os.Getenv(envNotify); value != "" {
+			notifyTargets = strings.Split(value, ",")
+		}
+	}
+	ticketTarget = firstNonEmpty(os.Getenv(envTicket), cfg.Ticket)
+	grafanaURL = firstNonEmpty(os.Getenv(envGrafanaURL), cfg.GrafanaURL)
+	grafanaTokenSecret = cfg.GrafanaTokenSecret
+	secrets.MasterKeySecret = cfg.MasterKeySecret
+	pluginsDir = firstNonEmpty(os.Getenv(envPluginsDir), cfg.PluginsDir)
+	if !flags.Changed("policy") {
+		policyPath = firstNonEmpty(os.Getenv(envPolicy), cfg.Policy)
+	}
+	tenancyPath = firstNonEmpty(os.Getenv(envTenancy), cfg.Tenancy)
+	if !flags.Changed("prometheus-url") {
+		prometheusURL = firstNonEmpty(os.Getenv(envPrometheus), cfg.PrometheusURL)
+	}
+	if cfg.BaseURL != "" {
+		baseURLEnv := "OPENAI_API_BASE"
+		if workflows.Provider == "azure" {
+			baseURLEnv = "AZURE_OPENAI_API_BASE"
+		}
+		// Environment variables take precedence, unless set from a previous load.
+		if value := os.Getenv(baseURLEnv); value == "" || value == appliedBaseURL {
+			os.Setenv(baseURLEnv, cfg.BaseURL)
+			appliedBaseURL = cfg.BaseURL
+		}
+	}
+
+	if ref := firstNonEmpty(os.Getenv(envAPIKeySecret), cfg.APIKeySecret); ref != "" {
+		apiKeyEnv := "OPENAI_API_KEY"
+		if workflows.Provider == "azure" {
+			apiKeyEnv = "AZURE_OPENAI_API_KEY"
+		}
+		// Environment variables take precedence, unless resolved from a previous load.
+		if value := os.Getenv(apiKeyEnv); value == "" || value == appliedAPIKey {
+			apiKey, err := secrets.Resolve(context.Background(), ref)
+			if err != nil {
+				return err
+			}
+			os.Setenv(apiKeyEnv, apiKey)
+			appliedAPIKey = apiKey
+		}
+	}
+	return nil
+}
+
+// firstNonEmpty returns the first non-empty value.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
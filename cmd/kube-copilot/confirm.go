@@ -0,0 +1,61 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+// toolCallConfirmer returns the confirmation hook for mutating commands,
+// or nil if --yes is set.
+func toolCallConfirmer() func(workflows.ToolCall) (workflows.ToolCall, bool) {
+	if assumeYes {
+		return nil
+	}
+	return confirmToolCall
+}
+
+// confirmToolCall asks the user to approve, reject or edit a mutating command.
+// The decision is recorded in the audit log.
+func confirmToolCall(call workflows.ToolCall) (workflows.ToolCall, bool) {
+	event := auditlog.Event{Type: auditlog.EventApproval, Tool: call.Name, Input: call.Input}
+	defer func() { auditlog.Record(event) }()
+
+	fmt.Fprintf(os.Stderr, "\n%s\n\n  %s\n\n", color.YellowString("The agent wants to run a command that may change the cluster:"), call.Input)
+	for {
+		switch strings.ToLower(utils.Ask("Run this command? [y/N/e(dit)] ")) {
+		case "y", "yes":
+			event.Decision = auditlog.DecisionApproved
+			return call, true
+		case "e", "edit":
+			if input := utils.Ask("Command: "); input != "" {
+				call.Input = input
+				event.Decision, event.Value = auditlog.DecisionEdited, input
+				return call, true
+			}
+		case "", "n", "no":
+			event.Decision = auditlog.DecisionDeclined
+			return call, false
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/datasafety"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dataSafetyNamespace string
+	dataSafetyOperation string
+	dataSafetyReplicas  int32
+)
+
+func init() {
+	dataSafetyCmd.PersistentFlags().StringVarP(&dataSafetyNamespace, "namespace", "n", "default", "Namespace of the StatefulSets")
+	dataSafetyCmd.PersistentFlags().StringVar(&dataSafetyOperation, "operation", datasafety.OperationReview, "Operation to review (review, scale or delete)")
+	dataSafetyCmd.PersistentFlags().Int32Var(&dataSafetyReplicas, "replicas", -1, "Target replicas of the scale operation")
+	addFailOnFlag(dataSafetyCmd)
+	dataSafetyCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	dataSafetyCmd.RegisterFlagCompletionFunc("operation", cobra.FixedCompletions([]string{datasafety.OperationReview, datasafety.OperationScale, datasafety.OperationDelete}, cobra.ShellCompDirectiveNoFileComp))
+	dataSafetyCmd.ValidArgsFunction = completeResourceNames("statefulsets")
+}
+
+var dataSafetyCmd = &cobra.Command{
+	Use:   "datasafety [statefulset]",
+	Short: "Review the data-loss risks before scaling down or deleting StatefulSets",
+	Long: `Review the update strategy, PVC retention policy, PV reclaim policy and backup
+coverage (Velero Schedules and VolumeSnapshots) of a StatefulSet, or of all the
+StatefulSets in a namespace, and warn about the data-loss risks of scaling it
+down (--operation scale --replicas N) or deleting it (--operation delete).`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		op := datasafety.Operation{Kind: dataSafetyOperation, Replicas: dataSafetyReplicas}
+		if err := op.Validate(); err != nil {
+			printError("datasafety", err)
+			return
+		}
+		var name string
+		target := "all StatefulSets"
+		if len(args) > 0 {
+			name = args[0]
+			target = "statefulset/" + name
+		}
+
+		printStatus("Checking the data safety of %s in namespace %s\n", target, dataSafetyNamespace)
+		storage, err := kubernetes.GetStorage(dataSafetyNamespace)
+		if err != nil {
+			printError("datasafety", err)
+			return
+		}
+		if len(storage.PVs) == 0 && len(storage.StorageClasses) == 0 {
+			printStatus("PersistentVolumes and StorageClasses are not accessible, skipping the reclaim policy checks\n")
+		}
+
+		risks, err := datasafety.Check(storage, name, op, time.Now())
+		if err != nil {
+			printError("datasafety", err)
+			return
+		}
+		printStatus("Found %d data-loss risks (%s)\n", len(risks), datasafety.Verdict(risks))
+		response, err := workflows.DataSafetyFlow(model, dataSafetyNamespace, target, op, risks, verbose)
+		if err != nil {
+			printError("datasafety", err)
+			return
+		}
+
+		resultTarget := fmt.Sprintf("namespace/%s", dataSafetyNamespace)
+		if name != "" {
+			resultTarget = fmt.Sprintf("statefulset/%s/%s", dataSafetyNamespace, name)
+		}
+		result := newRunResult("datasafety", resultTarget, response, nil)
+		saveHistory("datasafety", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+	},
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/feiskyer/kube-copilot/pkg/workflows"
@@ -25,39 +26,84 @@ import (
 
 var diagnoseName string
 var diagnoseNamespace string
+var diagnoseFix bool
+var diagnoseTicket bool
 
 func init() {
 	diagnoseCmd.PersistentFlags().StringVarP(&diagnoseName, "name", "", "", "Pod name")
 	diagnoseCmd.PersistentFlags().StringVarP(&diagnoseNamespace, "namespace", "n", "default", "Pod namespace")
-	diagnoseCmd.MarkFlagRequired("name")
+	diagnoseCmd.PersistentFlags().BoolVarP(&diagnoseFix, "fix", "", false, "Apply the proposed fix after confirmation, rolling it back if the workload degrades")
+	diagnoseCmd.PersistentFlags().BoolVarP(&diagnoseTicket, "ticket", "", false, "File a Jira or ServiceNow ticket with the findings (see the ticket configuration key)")
+	addWatchFlag(diagnoseCmd)
+	addFailOnFlag(diagnoseCmd)
+	registerResourceCompletions(diagnoseCmd, "pods")
 }
 
 var diagnoseCmd = &cobra.Command{
-	Use:   "diagnose",
+	Use:   "diagnose [pod]",
 	Short: "Diagnose problems for a Pod",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if diagnoseName == "" && len(args) > 0 {
 			diagnoseName = args[0]
 		}
 		if diagnoseName == "" {
-			fmt.Println("Please provide a pod name")
+			printError("diagnose", fmt.Errorf("please provide a pod name"))
 			return
 		}
 
-		fmt.Printf("Diagnosing Pod %s/%s\n", diagnoseNamespace, diagnoseName)
+		printStatus("Diagnosing Pod %s/%s\n", diagnoseNamespace, diagnoseName)
 
-		prompt := fmt.Sprintf("Diagnose the issues for Pod %s in namespace %s", diagnoseName, diagnoseNamespace)
-		flow, err := workflows.NewReActFlow(model, prompt, verbose, maxIterations)
+		flow, err := workflows.NewDiagnoseFlow(model, diagnoseNamespace, diagnoseName, verbose, maxIterations)
 		if err != nil {
-			color.Red(err.Error())
+			printError("diagnose", err)
 			return
 		}
+		flow.ConfirmToolCall = toolCallConfirmer()
 
+		start := time.Now()
+		stopProgress := attachProgress(flow)
 		response, err := flow.Run()
+		stopProgress()
 		if err != nil {
-			color.Red(err.Error())
+			printError("diagnose", err)
 			return
 		}
-		fmt.Println(response)
+
+		target := fmt.Sprintf("pod/%s/%s", diagnoseNamespace, diagnoseName)
+		result := newRunResult("diagnose", target, response, flow)
+		historyID := saveHistory("diagnose", response, result)
+		printResult(result, response)
+		annotateGrafana("diagnose", target, response, historyID, start)
+		if diagnoseTicket {
+			fileTicket(target, response, result.Findings, historyID)
+		}
+		if diagnoseFix {
+			applyFix(target, response)
+		}
+		checkFailOn(result.Findings)
 	},
 }
+
+// applyFix turns the fix proposed by the diagnosis into manifests and applies them with rollback.
+func applyFix(target, diagnosis string) {
+	printStatus("Preparing the fix of %s\n", target)
+	manifests, err := workflows.FixFlow(model, target, diagnosis, verbose)
+	if err != nil {
+		printError("diagnose", err)
+		return
+	}
+	if manifests == "" {
+		printStatus("No fix can be applied automatically.\n")
+		return
+	}
+
+	if !quiet && !isStructuredOutput() {
+		fmt.Printf("\nProposed fix:\n\n")
+		color.New(color.FgGreen).Printf("%s\n\n", manifests)
+	}
+	if err := applyWithRollback(manifests, applyWatch); err != nil {
+		color.Red(err.Error())
+		exitCode = 1
+	}
+}
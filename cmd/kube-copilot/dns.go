@@ -0,0 +1,80 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dnsNamespace string
+	dnsPod       string
+)
+
+func init() {
+	dnsCmd.PersistentFlags().StringVarP(&dnsNamespace, "namespace", "n", "default", "Namespace of the client Pod")
+	dnsCmd.PersistentFlags().StringVarP(&dnsPod, "pod", "p", "", "Client Pod failing to resolve the name")
+	addFailOnFlag(dnsCmd)
+	dnsCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	dnsCmd.RegisterFlagCompletionFunc("pod", completeResourceNames("pods"))
+}
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns [name]",
+	Short: "Troubleshoot DNS resolution problems",
+	Long: `Troubleshoot the DNS resolution of a name (e.g. a Service or an external host)
+and/or from a Pod: checks the CoreDNS Pods, Corefile, kube-dns Service and
+endpoints, runs test lookups from ephemeral debug Pods and explains the
+NXDOMAIN, SERVFAIL or timeout causes step by step.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		printStatus("Troubleshooting DNS in namespace %s\n", dnsNamespace)
+		flow, err := workflows.NewDNSFlow(model, dnsNamespace, name, dnsPod, verbose, maxIterations)
+		if err != nil {
+			printError("dns", err)
+			return
+		}
+		flow.ConfirmToolCall = toolCallConfirmer()
+
+		start := time.Now()
+		stopProgress := attachProgress(flow)
+		response, err := flow.Run()
+		stopProgress()
+		if err != nil {
+			printError("dns", err)
+			return
+		}
+
+		target := fmt.Sprintf("namespace/%s", dnsNamespace)
+		if dnsPod != "" {
+			target = fmt.Sprintf("pod/%s/%s", dnsNamespace, dnsPod)
+		}
+		result := newRunResult("dns", target, response, flow)
+		historyID := saveHistory("dns", response, result)
+		printResult(result, response)
+		annotateGrafana("dns", target, response, historyID, start)
+		checkFailOn(result.Findings)
+	},
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/eval"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalSuite       string
+	evalModels      []string
+	evalMinPassRate float64
+	// evalPromptVersions are the sets of prompt versions to compare, e.g. "audit=v2,plan=v3".
+	evalPromptVersions []string
+)
+
+func init() {
+	evalCmd.PersistentFlags().StringVarP(&evalSuite, "suite", "", "", "Path to the suite of scenarios (YAML or JSON)")
+	evalCmd.PersistentFlags().StringSliceVarP(&evalModels, "models", "", nil, "Models to evaluate (defaults to the models of the suite, or --model)")
+	evalCmd.PersistentFlags().Float64VarP(&evalMinPassRate, "min-pass-rate", "", 1, "Exit with code 2 when the pass rate of any model is below this ratio (0-1)")
+	evalCmd.PersistentFlags().StringArrayVarP(&evalPromptVersions, "prompt-versions", "", nil, "Comma-separated prompt versions to compare, e.g. audit=v2,plan=v3 (repeat to evaluate several sets)")
+	evalCmd.MarkPersistentFlagRequired("suite")
+}
+
+// evalReport is the structured output of the eval command.
+type evalReport struct {
+	Suite     string         `json:"suite"`
+	Summaries []eval.Summary `json:"summaries"`
+	Results   []eval.Result  `json:"results"`
+}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate the agent against a suite of scenarios",
+	Long: `Run the scenarios of a suite against the configured models and score the answers.
+Tool outputs (e.g. kubectl) are mocked by the fixtures of each scenario, so no
+cluster is needed; combine with --replay for fully deterministic runs in CI.
+
+Each --prompt-versions set runs the suite once more with these prompt versions
+pinned, and the results are summarized per model and prompt versions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		suite, err := eval.LoadSuite(evalSuite)
+		if err != nil {
+			return err
+		}
+		// Only the tools are mocked: the workflows must not read the cluster
+		// either, e.g. to prefetch the context of a diagnosed Pod.
+		kubernetes.Offline = true
+
+		models := evalModels
+		if len(models) == 0 {
+			models = suite.Models
+		}
+		if len(models) == 0 {
+			models = []string{model}
+		}
+
+		variants := evalPromptVersions
+		if len(variants) == 0 {
+			variants = []string{""}
+		}
+		selectors := make([]*prompts.Selector, len(variants))
+		labels := make([]string, len(variants))
+		for i, variant := range variants {
+			pins := strings.Split(variant, ",")
+			if selectors[i], err = newPromptSelector(pins); err != nil {
+				return err
+			}
+			parsed, _ := prompts.ParsePins(pins)
+			labels[i] = prompts.Label(parsed)
+		}
+
+		total := len(variants) * len(models) * len(suite.Scenarios)
+		done := 0
+		var results []eval.Result
+		for i, label := range labels {
+			workflows.Prompts = selectors[i]
+			variantResults := suite.Run(models, tools.CopilotTools, func(model string, scenario eval.Scenario) (string, error) {
+				done++
+				status := fmt.Sprintf("[%d/%d] Evaluating %s with %s", done, total, scenario.Name, model)
+				if label != "" {
+					status += " and " + label
+				}
+				printStatus("%s\n", color.BlueString(status))
+				if workflows.Cassette != nil {
+					// Record or replay the mocked tools too.
+					workflows.Cassette.WrapTools(tools.CopilotTools)
+				}
+				// Draw the versions of the rolled out prompts again for each scenario.
+				workflows.Prompts.Reset()
+				return runScenario(model, scenario)
+			})
+			for j := range variantResults {
+				variantResults[j].Prompts = label
+			}
+			results = append(results, variantResults...)
+		}
+
+		report := evalReport{Suite: suite.Name, Summaries: eval.Summarize(results), Results: results}
+		printResult(report, formatEvalReport(report))
+		for _, summary := range report.Summaries {
+			if summary.PassRate < evalMinPassRate {
+				exitCode = 2
+			}
+		}
+		return nil
+	},
+}
+
+// runScenario runs the workflow of the scenario with the model.
+func runScenario(model string, scenario eval.Scenario) (string, error) {
+	switch scenario.Workflow {
+	case eval.WorkflowExecute:
+		flow, err := workflows.NewReActFlow(model, scenario.Instructions, verbose, maxIterations)
+		if err != nil {
+			return "", err
+		}
+		return flow.Run()
+	case eval.WorkflowAudit:
+		return workflows.AuditFlow(model, scenario.Namespace, scenario.Pod, verbose)
+	case eval.WorkflowAnalyze:
+		return workflows.AnalysisFlow(model, scenario.Manifest, verbose)
+	default:
+		return workflows.DiagnoseFlow(model, scenario.Namespace, scenario.Pod, verbose, maxIterations)
+	}
+}
+
+// formatEvalReport renders the eval report as markdown.
+func formatEvalReport(report evalReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Evaluation of %s\n\n", report.Suite)
+	sb.WriteString("| Model | Prompts | Passed | Pass rate | Score |\n|---|---|---|---|---|\n")
+	for _, s := range report.Summaries {
+		fmt.Fprintf(&sb, "| %s | %s | %d/%d | %.0f%% | %.2f |\n", s.Model, promptsOrDefault(s.Prompts), s.Passed, s.Total, s.PassRate*100, s.Score)
+	}
+
+	sb.WriteString("\n## Scenarios\n\n| Scenario | Model | Prompts | Result | Score | Duration |\n|---|---|---|---|---|---|\n")
+	for _, r := range report.Results {
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %.2f | %s |\n", r.Scenario, r.Model, promptsOrDefault(r.Prompts), status, r.Score, r.Duration)
+	}
+
+	for _, r := range report.Results {
+		if r.Passed {
+			continue
+		}
+		if r.Prompts != "" {
+			fmt.Fprintf(&sb, "\n### %s (%s, %s)\n\n", r.Scenario, r.Model, r.Prompts)
+		} else {
+			fmt.Fprintf(&sb, "\n### %s (%s)\n\n", r.Scenario, r.Model)
+		}
+		if r.Error != "" {
+			fmt.Fprintf(&sb, "- Error: %s\n", r.Error)
+		}
+		for _, check := range r.Checks {
+			if !check.Passed {
+				fmt.Fprintf(&sb, "- Failed check: %s\n", check.Name)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// promptsOrDefault returns the label of the prompt versions, or "default" for the configured ones.
+func promptsOrDefault(label string) string {
+	if label == "" {
+		return "default"
+	}
+	return label
+}
@@ -18,45 +18,111 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/fatih/color"
 	"github.com/feiskyer/kube-copilot/pkg/tools"
 	"github.com/feiskyer/kube-copilot/pkg/workflows"
 	"github.com/spf13/cobra"
 )
 
-var instructions string
+var (
+	instructions string
+	dryRun       bool
+	fromFile     string
+	concurrency  int
+	attachments  []string
+	continueID   string
+)
 
 func init() {
 	tools.CopilotTools["trivy"] = tools.Trivy
 
 	executeCmd.PersistentFlags().StringVarP(&instructions, "instructions", "", "", "instructions to execute")
-	executeCmd.MarkFlagRequired("instructions")
+	executeCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false, "Preview the commands without executing them (only read-only kubectl commands are run)")
+	executeCmd.PersistentFlags().StringVarP(&fromFile, "from-file", "f", "", "Run the tasks listed in a YAML file and print a combined report")
+	executeCmd.PersistentFlags().IntVarP(&concurrency, "concurrency", "", 1, "Number of tasks from --from-file to run in parallel")
+	executeCmd.PersistentFlags().StringSliceVarP(&attachments, "attach", "a", nil, "Manifests or documents (files, directories or - for stdin) to answer from, e.g. with --offline")
+	executeCmd.PersistentFlags().StringVarP(&continueID, "continue", "", "", "Continue the previous run with the given history ID, using its summary as context")
+	executeCmd.MarkFlagsMutuallyExclusive("instructions", "from-file")
+	executeCmd.MarkFlagsMutuallyExclusive("continue", "from-file")
 }
 
 var executeCmd = &cobra.Command{
 	Use:   "execute",
 	Short: "Execute operations based on prompt instructions",
 	Run: func(cmd *cobra.Command, args []string) {
+		if fromFile != "" {
+			tasks, err := loadBatchTasks(fromFile)
+			if err != nil {
+				printError("execute", err)
+				return
+			}
+			runBatch(tasks, concurrency)
+			return
+		}
+
 		if instructions == "" && len(args) > 0 {
 			instructions = strings.Join(args, " ")
 		}
 		if instructions == "" {
-			fmt.Println("Please provide the instructions")
+			printError("execute", fmt.Errorf("please provide the instructions"))
+			return
+		}
+		attached, err := readAttachments(attachments)
+		if err != nil {
+			printError("execute", err)
 			return
 		}
+		if continueID != "" {
+			previous, err := continuationContext(continueID)
+			if err != nil {
+				printError("execute", err)
+				return
+			}
+			attached += previous
+		}
 
-		flow, err := workflows.NewReActFlow(model, instructions, verbose, maxIterations)
+		flow, err := workflows.NewReActFlow(model, instructions+attached, verbose, maxIterations)
 		if err != nil {
-			color.Red(err.Error())
+			printError("execute", err)
 			return
 		}
+		flow.DryRun = dryRun
+		flow.ConfirmToolCall = toolCallConfirmer()
 
+		start := time.Now()
+		stopProgress := attachProgress(flow)
 		response, err := flow.Run()
+		stopProgress()
 		if err != nil {
-			color.Red(err.Error())
+			printError("execute", err)
 			return
 		}
-		fmt.Println(response)
+
+		answer := response
+		if dryRun {
+			answer += formatSkippedToolCalls(flow.SkippedToolCalls)
+		}
+		result := newRunResult("execute", "", response, flow)
+		historyID := saveHistory("execute", answer, result)
+		printResult(result, answer)
+		// Only remediations are annotated, not the read-only questions.
+		if changedCluster(flow) {
+			annotateGrafana("execute", "", response, historyID, start)
+		}
 	},
 }
+
+// formatSkippedToolCalls renders the tool calls skipped in dry-run mode as markdown.
+func formatSkippedToolCalls(calls []workflows.ToolCall) string {
+	if len(calls) == 0 {
+		return "\n\n## Dry run\n\nNo commands were skipped.\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## Dry run\n\nThe following commands were not executed:\n")
+	for i, call := range calls {
+		fmt.Fprintf(&sb, "\n%d. %s:\n\n```\n%s\n```\n", i+1, call.Name, call.Input)
+	}
+	return sb.String()
+}
@@ -16,37 +16,47 @@ limitations under the License.
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
 	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
 	"github.com/feiskyer/kube-copilot/pkg/utils"
 	"github.com/feiskyer/kube-copilot/pkg/workflows"
 	"github.com/spf13/cobra"
 )
 
-var generatePrompt string
+var (
+	generatePrompt   string
+	generateFile     string
+	generateValidate bool
+	generateApply    bool
+)
 
 func init() {
 	generateCmd.PersistentFlags().StringVarP(&generatePrompt, "prompt", "p", "", "Prompts to generate Kubernetes manifests")
-	generateCmd.MarkFlagRequired("prompt")
+	generateCmd.PersistentFlags().StringVarP(&generateFile, "file", "f", "", "Write the generated manifests to the file instead of stdout")
+	generateCmd.PersistentFlags().BoolVarP(&generateValidate, "validate", "", false, "Validate the generated manifests with a server-side dry-run")
+	generateCmd.PersistentFlags().BoolVarP(&generateApply, "apply", "", false, "Apply the generated manifests to the cluster after confirmation")
 }
 
 var generateCmd = &cobra.Command{
-	Use:   "generate",
+	Use:   "generate [instructions]",
 	Short: "Generate Kubernetes manifests",
 	Run: func(cmd *cobra.Command, args []string) {
+		if generatePrompt == "" && len(args) > 0 {
+			generatePrompt = strings.Join(args, " ")
+		}
 		if generatePrompt == "" {
-			color.Red("Please specify a prompt")
+			printError("generate", fmt.Errorf("please specify a prompt"))
 			return
 		}
 
 		response, err := workflows.GeneratorFlow(model, generatePrompt, verbose)
 		if err != nil {
-			color.Red(err.Error())
+			printError("generate", err)
 			return
 		}
 
@@ -55,25 +65,55 @@ var generateCmd = &cobra.Command{
 		if strings.Contains(response, "```") {
 			yaml = utils.ExtractYaml(response)
 		}
-		fmt.Printf("\nGenerated manifests:\n\n")
-		color.New(color.FgGreen).Printf("%s\n\n", yaml)
 
-		// apply the yaml to kubernetes cluster
-		color.New(color.FgRed).Printf("Do you approve to apply the generated manifests to cluster? (y/n)")
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			approve := scanner.Text()
-			if strings.ToLower(approve) != "y" && strings.ToLower(approve) != "yes" {
-				break
+		result := newRunResult("generate", "", yaml, nil)
+		saveHistory("generate", yaml, result)
+		if generateFile != "" {
+			if err := os.WriteFile(generateFile, []byte(strings.TrimSpace(yaml)+"\n"), 0644); err != nil {
+				printError("generate", err)
+				return
 			}
+			printStatus("Generated manifests written to %s\n", generateFile)
+		} else if isStructuredOutput() {
+			printResult(result, yaml)
+		} else if outputFormat == outputPlain || quiet {
+			fmt.Println(strings.TrimSpace(yaml))
+		} else {
+			fmt.Printf("\nGenerated manifests:\n\n")
+			color.New(color.FgGreen).Printf("%s\n\n", yaml)
+		}
 
-			if err := kubernetes.ApplyYaml(yaml); err != nil {
-				color.Red(err.Error())
+		if generateValidate || generateApply {
+			if err := kubernetes.ValidateYaml(yaml); err != nil {
+				printError("generate", fmt.Errorf("validation failed: %v", err))
 				return
 			}
+			printStatus("%s\n", color.GreenString("Validation passed (server-side dry-run)"))
+		}
 
-			color.New(color.FgGreen).Printf("Applied the generated manifests to cluster successfully!")
-			break
+		if !generateApply {
+			return
 		}
+		if readOnly {
+			printError("generate", fmt.Errorf("applying manifests is not allowed in read-only mode"))
+			return
+		}
+
+		// apply the yaml to kubernetes cluster
+		if !assumeYes && !utils.Confirm(color.RedString("Do you approve to apply the generated manifests to cluster?")) {
+			return
+		}
+		err = kubernetes.ApplyYaml(yaml)
+		event := auditlog.Event{Type: auditlog.EventApply, Input: kubernetes.SummarizeManifests(yaml), Status: auditlog.StatusSucceeded}
+		if err != nil {
+			event.Status, event.Error = auditlog.StatusFailed, err.Error()
+		}
+		auditlog.Record(event)
+		if err != nil {
+			printError("generate", err)
+			return
+		}
+
+		color.New(color.FgGreen).Printf("Applied the generated manifests to cluster successfully!\n")
 	},
 }
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/grafana"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/secrets"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+// envGrafanaToken is the environment variable of the Grafana token.
+const envGrafanaToken = "GRAFANA_TOKEN"
+
+// maxAnnotationSummary bounds the summary of the reports in the Grafana annotations.
+const maxAnnotationSummary = 500
+
+var (
+	// grafanaURL enables the Grafana annotations when set.
+	grafanaURL string
+	// grafanaTokenSecret references the Grafana token when GRAFANA_TOKEN is not set.
+	grafanaTokenSecret string
+)
+
+// annotateGrafana records a diagnosis or remediation that ran from start to
+// now as a Grafana annotation, when Grafana is configured. Failures are
+// reported on stderr without failing the command.
+func annotateGrafana(command, workload, report, historyID string, start time.Time) {
+	if grafanaURL == "" {
+		return
+	}
+	if err := createGrafanaAnnotation(command, workload, report, historyID, start); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Unable to create Grafana annotation: %v", err))
+	}
+}
+
+func createGrafanaAnnotation(command, workload, report, historyID string, start time.Time) error {
+	ctx := context.Background()
+	token := os.Getenv(envGrafanaToken)
+	if token == "" && grafanaTokenSecret != "" {
+		var err error
+		if token, err = secrets.Resolve(ctx, grafanaTokenSecret); err != nil {
+			return err
+		}
+	}
+	client, err := grafana.NewClient(grafanaURL, token)
+	if err != nil {
+		return err
+	}
+
+	tags := []string{"kube-copilot", command}
+	cluster := kubernetes.CurrentContext()
+	if cluster != "" {
+		tags = append(tags, "cluster:"+cluster)
+	}
+	if workload != "" {
+		tags = append(tags, "workload:"+workload)
+	}
+
+	text := fmt.Sprintf("<b>kube-copilot %s</b>", command)
+	if workload != "" {
+		text += " " + html.EscapeString(workload)
+	}
+	if cluster != "" {
+		text += fmt.Sprintf(" (cluster %s)", html.EscapeString(cluster))
+	}
+	text += ": " + html.EscapeString(workflows.Summarize(report, maxAnnotationSummary))
+	if historyID != "" {
+		text += fmt.Sprintf("<br/>Full report: <code>kube-copilot history show %s</code>", historyID)
+	}
+
+	_, err = client.Annotate(ctx, grafana.Annotation{
+		Time:    start.UnixMilli(),
+		TimeEnd: time.Now().UnixMilli(),
+		Tags:    tags,
+		Text:    text,
+	})
+	return err
+}
+
+// changedCluster returns true if the flow ran kubectl commands or plugins that may change the cluster.
+func changedCluster(flow *workflows.ReActFlow) bool {
+	if flow == nil || flow.PlanTracker == nil || flow.DryRun {
+		return false
+	}
+	for _, step := range flow.PlanTracker.Steps {
+		if tools.IsMutatingTool(step.Action.Name, step.Action.Input) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/helmdiff"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	helmDiffNamespace    string
+	helmDiffFromRevision int
+	helmDiffToRevision   int
+	helmDiffProposed     helmdiff.Source
+)
+
+func init() {
+	helmDiffCmd.PersistentFlags().StringVarP(&helmDiffNamespace, "namespace", "n", "default", "Namespace of the release")
+	helmDiffCmd.PersistentFlags().IntVarP(&helmDiffFromRevision, "from-revision", "", 0, "Revision to compare from (defaults to the current revision)")
+	helmDiffCmd.PersistentFlags().IntVarP(&helmDiffToRevision, "to-revision", "", 0, "Revision to compare to, instead of the proposed chart and values")
+	helmDiffCmd.PersistentFlags().StringVarP(&helmDiffProposed.Chart, "chart", "", "", "Chart of the proposed change (e.g. bitnami/nginx or a local path)")
+	helmDiffCmd.PersistentFlags().StringVarP(&helmDiffProposed.Version, "version", "", "", "Chart version of the proposed change")
+	helmDiffCmd.PersistentFlags().StringSliceVarP(&helmDiffProposed.Values, "values", "f", nil, "Proposed values files")
+	helmDiffCmd.PersistentFlags().StringArrayVarP(&helmDiffProposed.Set, "set", "", nil, "Proposed values (e.g. replicaCount=3)")
+	helmDiffCmd.PersistentFlags().BoolVarP(&helmDiffProposed.ReuseValues, "reuse-values", "", true, "Apply the proposed values on top of the current values of the release")
+	helmDiffCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	addFailOnFlag(helmDiffCmd)
+}
+
+var helmDiffCmd = &cobra.Command{
+	Use:   "helmdiff <release>",
+	Short: "Explain the impact of a Helm release change",
+	Long: `Render two versions of a Helm release, diff their manifests and explain the
+operational impact of the change. Compare the current revision with a proposed
+chart and values (--chart, --values, --set), or two revisions of the release
+(--from-revision, --to-revision).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		release := args[0]
+		from := helmdiff.Source{Revision: helmDiffFromRevision}
+		to := helmDiffProposed
+		switch {
+		case helmDiffToRevision > 0 && to.Chart != "":
+			return fmt.Errorf("--to-revision and --chart are mutually exclusive")
+		case helmDiffToRevision > 0:
+			to = helmdiff.Source{Revision: helmDiffToRevision}
+		case to.Chart == "":
+			return fmt.Errorf("either --chart or --to-revision is required")
+		}
+
+		printStatus("Rendering release %s/%s from %s and %s\n", helmDiffNamespace, release, from, to)
+		oldManifest, err := helmdiff.Render(release, helmDiffNamespace, from)
+		if err != nil {
+			printError("helmdiff", fmt.Errorf("failed to render %s: %v", from, err))
+			return nil
+		}
+		newManifest, err := helmdiff.Render(release, helmDiffNamespace, to)
+		if err != nil {
+			printError("helmdiff", fmt.Errorf("failed to render %s: %v", to, err))
+			return nil
+		}
+
+		changes := helmdiff.Diff(helmdiff.ParseManifest(oldManifest), helmdiff.ParseManifest(newManifest))
+		printStatus("%d objects changed\n", len(changes))
+		response, err := workflows.HelmDiffFlow(model, release, helmDiffNamespace, from, to, changes, verbose)
+		if err != nil {
+			printError("helmdiff", err)
+			return nil
+		}
+
+		result := newRunResult("helmdiff", fmt.Sprintf("release/%s/%s", helmDiffNamespace, release), response, nil)
+		saveHistory("helmdiff", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+		return nil
+	},
+}
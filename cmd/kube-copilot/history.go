@@ -0,0 +1,413 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/history"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/report"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat         string
+	exportFile           string
+	serveAddr            string
+	clusterCheckInterval time.Duration
+	// summaryModel is the model writing the titles and summaries of the history entries.
+	summaryModel string
+)
+
+// summaryModelOff disables the titles and summaries of the history entries.
+const summaryModelOff = "off"
+
+// maxContinuedAnswer is the size of the answer seeding a continued run without a summary.
+const maxContinuedAnswer = 2000
+
+func init() {
+	historyExportCmd.Flags().StringVarP(&exportFormat, "format", "f", report.FormatMarkdown, "Report format (markdown or html)")
+	historyExportCmd.Flags().StringVarP(&exportFile, "file", "", "", "Write the report to the file (\"-\" for stdout, defaults to kube-copilot-<id>.<md|html>)")
+	historyExportCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{report.FormatMarkdown, report.FormatHTML}, cobra.ShellCompDirectiveNoFileComp))
+	historyServeCmd.Flags().StringVarP(&serveAddr, "addr", "", "127.0.0.1:8080", "Address to listen on")
+	historyServeCmd.Flags().DurationVarP(&clusterCheckInterval, "cluster-check-interval", "", time.Minute, "Period of the health checks of the kubeconfig clusters (0 to disable them)")
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyReplayCmd)
+	historyCmd.AddCommand(historyExportCmd)
+	historyCmd.AddCommand(historySummarizeCmd)
+	historyCmd.AddCommand(historyServeCmd)
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse and replay previous runs",
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List previous runs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entries, err := store.List()
+		if err != nil {
+			return err
+		}
+
+		if isStructuredOutput() {
+			printResult(entries, "")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tMODEL\tTITLE")
+		for _, entry := range entries {
+			title := entry.Title
+			if title == "" {
+				title = strings.Join(entry.Args, " ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.ID, entry.Time.Format("2006-01-02 15:04:05"), entry.Model, truncate(title, 60))
+		}
+		return w.Flush()
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the result of a previous run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entry, err := store.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		printStatus("%s\n\n", color.BlueString("kube-copilot %s (model %s, %s)", strings.Join(entry.Args, " "), entry.Model, entry.Time.Format("2006-01-02 15:04:05")))
+		printResult(entry, entry.Answer)
+		return nil
+	},
+}
+
+var historyReplayCmd = &cobra.Command{
+	Use:   "replay <id>",
+	Short: "Re-run a previous run, optionally with another --model or --context",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entry, err := store.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		// Flags appended later take precedence over the recorded ones.
+		replayArgs := append([]string{}, entry.Args...)
+		flags := cmd.Flags()
+		if flags.Changed("model") {
+			replayArgs = append(replayArgs, "--model", model)
+		} else if entry.Model != "" {
+			replayArgs = append(replayArgs, "--model", entry.Model)
+		}
+		if flags.Changed("context") {
+			replayArgs = append(replayArgs, "--context", kubernetes.Context)
+		} else if entry.Context != "" {
+			replayArgs = append(replayArgs, "--context", entry.Context)
+		}
+		for _, name := range []string{"kubeconfig", "output", "verbose", "yes", "read-only"} {
+			if flag := flags.Lookup(name); flag != nil && flag.Changed {
+				replayArgs = append(replayArgs, fmt.Sprintf("--%s=%s", name, flag.Value.String()))
+			}
+		}
+
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		printStatus("%s\n", color.BlueString("Replaying: kube-copilot %s", strings.Join(replayArgs, " ")))
+		replay := exec.Command(self, replayArgs...)
+		replay.Stdin = os.Stdin
+		replay.Stdout = os.Stdout
+		replay.Stderr = os.Stderr
+		return replay.Run()
+	},
+}
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Export a previous run as a Markdown or HTML report",
+	Long: `Export a previous run as a shareable Markdown or HTML report with the
+question, the reasoning steps, the commands run with their outputs and the
+final answer, e.g. for attaching to incident tickets.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !report.ValidFormat(exportFormat) {
+			return fmt.Errorf("unsupported report format %q, should be markdown or html", exportFormat)
+		}
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entry, err := store.Load(args[0])
+		if err != nil {
+			return err
+		}
+		out, err := report.Render(entry, exportFormat)
+		if err != nil {
+			return err
+		}
+
+		if exportFile == "-" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		file := exportFile
+		if file == "" {
+			file = report.FileName(entry, exportFormat)
+		}
+		if err := os.WriteFile(file, out, 0600); err != nil {
+			return err
+		}
+		printStatus("Report written to %s\n", file)
+		return nil
+	},
+}
+
+var historySummarizeCmd = &cobra.Command{
+	Use:   "summarize [id...]",
+	Short: "Generate the titles and summaries of previous runs",
+	Long: `Generate the titles and summaries of the given runs, or of all the runs
+without a title (e.g. saved before summaries were introduced or while the
+LLM was unavailable).`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		var entries []*history.Entry
+		if len(args) > 0 {
+			for _, id := range args {
+				entry, err := store.Load(id)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry)
+			}
+		} else {
+			all, err := store.List()
+			if err != nil {
+				return err
+			}
+			for _, entry := range all {
+				if entry.Title == "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+
+		failed := 0
+		for _, entry := range entries {
+			if err := summarizeEntry(entry); err != nil {
+				printStatus("%s\n", color.YellowString("Unable to summarize %s: %v", entry.ID, err))
+				failed++
+				continue
+			}
+			if err := store.Save(entry); err != nil {
+				return err
+			}
+			printStatus("%s  %s\n", entry.ID, entry.Title)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d runs could not be summarized", failed, len(entries))
+		}
+		return nil
+	},
+}
+
+var historyServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the history and the reports of previous runs over HTTP",
+	Long: `Serve the history and the reports of previous runs over HTTP:
+
+  GET /api/v1/history                         lists the runs
+  GET /api/v1/history/<id>                    returns a run as JSON
+  GET /api/v1/history/<id>/report?format=html downloads the report (markdown or html)
+  GET /api/v1/findings?severity=HIGH          lists the findings (also filtered by category, resource, id and command)
+  GET /api/v1/artifacts/<run>                 lists the tool outputs saved by a run
+  GET /api/v1/artifacts/<run>/<name>          downloads a tool output
+  GET /api/v1/clusters                        returns the health of the kubeconfig clusters
+
+The credentials and the API server of each kubeconfig cluster are checked
+every --cluster-check-interval. It listens on localhost by default, since the history holds cluster details.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		outputs, err := artifactStore()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		var monitor *kubernetes.HealthMonitor
+		if clusterCheckInterval > 0 && !offline {
+			monitor = kubernetes.NewHealthMonitor(clusterCheckInterval)
+			go monitor.Run(ctx)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/api/", report.NewHandler(store, outputs, monitor))
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+		server := &http.Server{Addr: serveAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintf(os.Stderr, "kube-copilot history listening on %s\n", serveAddr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+// historyStore returns the store of the default history directory.
+func historyStore() (*history.Store, error) {
+	dir, err := history.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return history.NewStore(dir), nil
+}
+
+// artifactStore returns the store of the large tool outputs of the runs.
+func artifactStore() (*artifacts.Store, error) {
+	dir, err := artifacts.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return artifacts.NewStore(dir), nil
+}
+
+// saveHistory persists the run so that it can be browsed and replayed later,
+// and returns the ID of the entry ("" if it could not be saved).
+func saveHistory(command, answer string, result interface{}) string {
+	entry := &history.Entry{
+		Command: command,
+		Args:    os.Args[1:],
+		Model:   model,
+		Context: kubernetes.Context,
+		Prompts: workflows.SelectedPrompts(),
+		Answer:  answer,
+	}
+	if summaryModel != summaryModelOff {
+		if err := summarizeEntry(entry); err != nil && verbose {
+			color.Yellow("Unable to summarize the run: %v", err)
+		}
+	}
+	store, err := historyStore()
+	if err == nil {
+		if entry.Result, err = json.Marshal(result); err == nil {
+			err = store.Save(entry)
+		}
+	}
+	if err != nil {
+		if verbose {
+			color.Yellow("Unable to save history: %v", err)
+		}
+		return ""
+	}
+	return entry.ID
+}
+
+// summarizeEntry sets the title and the summary of the entry.
+func summarizeEntry(entry *history.Entry) error {
+	if entry.Answer == "" {
+		return fmt.Errorf("the run has no answer")
+	}
+	summaryFor := summaryModel
+	if summaryFor == summaryModelOff {
+		summaryFor = ""
+	}
+	summary, err := workflows.SummarizeFlow(firstNonEmpty(summaryFor, entry.Model, model), strings.Join(entry.Args, " "), entry.Answer)
+	if err != nil {
+		return err
+	}
+	entry.Title, entry.Summary = summary.Title, summary.Summary
+	return nil
+}
+
+// continuationContext returns the context of the previous run with the given
+// history ID, which seeds the instructions of a run continuing it.
+func continuationContext(id string) (string, error) {
+	store, err := historyStore()
+	if err != nil {
+		return "", err
+	}
+	entry, err := store.Load(id)
+	if err != nil {
+		return "", err
+	}
+
+	title := firstNonEmpty(entry.Title, strings.Join(entry.Args, " "))
+	outcome := entry.Summary
+	if outcome == "" {
+		outcome = truncate(entry.Answer, maxContinuedAnswer)
+	}
+	return fmt.Sprintf("\n\nThis continues a previous run (%s) whose outcome was:\n%s", title, outcome), nil
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
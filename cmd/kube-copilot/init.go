@@ -0,0 +1,195 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/config"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// requiredTools are the external tools without which kube-copilot does not work.
+var requiredTools = map[string]bool{"kubectl": true}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the configuration file and check the environment",
+	Long: `Interactively create ~/.kube-copilot/config.yaml, validate the API key against
+the LLM provider and check the required binaries and the cluster access.
+Pass --yes to accept the defaults without prompts.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, cfg, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+
+		before := *cfg
+		defaults := map[string]string{
+			"provider":        firstNonEmpty(cfg.Provider, detectProvider()),
+			"baseURL":         cfg.BaseURL,
+			"model":           firstNonEmpty(cfg.Model, model),
+			"language":        cfg.Language,
+			"readOnly":        strconv.FormatBool(cfg.ReadOnly),
+			"offline":         strconv.FormatBool(cfg.Offline),
+			"airGapped":       strconv.FormatBool(cfg.AirGapped),
+			"trivyCacheDir":   cfg.TrivyCacheDir,
+			"trivySkipUpdate": strconv.FormatBool(cfg.TrivySkipUpdate),
+			"trivySeverity":   cfg.TrivySeverity,
+			"trivyCacheTTL":   cfg.TrivyCacheTTL,
+			"maxTokens":       strconv.Itoa(maxTokens),
+			"apiKeySecret":    cfg.APIKeySecret,
+			"masterKeySecret": cfg.MasterKeySecret,
+			"theme":           firstNonEmpty(cfg.Theme, "auto"),
+			"prometheusURL":   cfg.PrometheusURL,
+			"tenancy":         cfg.Tenancy,
+			"ticket":          cfg.Ticket,
+			"postProcess":     firstNonEmpty(strings.Join(cfg.PostProcess, ","), strings.Join(workflows.PostProcess, ",")),
+			"disclaimer":      cfg.Disclaimer,
+			"summaryModel":    cfg.SummaryModel,
+			"promptsDir":      cfg.PromptsDir,
+		}
+		if cfg.MaxTokens > 0 {
+			defaults["maxTokens"] = strconv.Itoa(cfg.MaxTokens)
+		}
+		for _, key := range config.Keys {
+			for {
+				value := defaults[key]
+				if !assumeYes {
+					if answer := utils.Ask(fmt.Sprintf("%s [%s]: ", key, value)); answer != "" {
+						value = answer
+					}
+				}
+				if err := cfg.Set(key, value); err != nil {
+					if assumeYes {
+						return err
+					}
+					color.Red(err.Error())
+					continue
+				}
+				break
+			}
+		}
+		if err := cfg.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("%s Configuration written to %s\n", color.GreenString("✓"), path)
+
+		// Resolve the settings again so that the new configuration takes effect.
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		for _, key := range config.Keys {
+			oldValue, _ := before.Get(key)
+			if newValue, _ := cfg.Get(key); newValue != oldValue {
+				auditlog.Record(auditlog.Event{Type: auditlog.EventConfigChange, Key: key, Value: newValue, Status: auditlog.StatusSucceeded})
+			}
+		}
+
+		ok := checkAPIKey()
+		ok = checkTools() && ok
+		ok = checkCluster() && ok
+		if !ok {
+			return fmt.Errorf("some checks failed, please fix them before using kube-copilot")
+		}
+		fmt.Printf("\nAll set! Try: kube-copilot execute --instructions \"list the pods in kube-system\"\n")
+		return nil
+	},
+}
+
+// detectProvider returns the provider from the API keys in the environment.
+func detectProvider() string {
+	if os.Getenv("OPENAI_API_KEY") == "" && os.Getenv("AZURE_OPENAI_API_KEY") != "" {
+		return "azure"
+	}
+	return "openai"
+}
+
+// checkAPIKey validates the API key of the configured provider, asking for it if not set.
+func checkAPIKey() bool {
+	keyEnv := "OPENAI_API_KEY"
+	if workflows.Provider == "azure" {
+		keyEnv = "AZURE_OPENAI_API_KEY"
+		if os.Getenv("AZURE_OPENAI_API_BASE") == "" {
+			color.Red("✗ AZURE_OPENAI_API_BASE is not set (set it or the baseURL configuration)")
+			return false
+		}
+	}
+
+	if os.Getenv(keyEnv) == "" {
+		if assumeYes {
+			color.Red("✗ %s is not set", keyEnv)
+			return false
+		}
+		key := utils.AskSecret(fmt.Sprintf("%s is not set, enter it to validate: ", keyEnv))
+		if key == "" {
+			color.Red("✗ %s is not set", keyEnv)
+			return false
+		}
+		os.Setenv(keyEnv, key)
+		defer fmt.Printf("  Add \"export %s=<your key>\" to your shell profile to use it in the next runs.\n", keyEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := workflows.CheckLLM(ctx, model); err != nil {
+		color.Red("✗ Unable to call model %s: %v", model, err)
+		return false
+	}
+	fmt.Printf("%s API key is valid and model %s is available\n", color.GreenString("✓"), model)
+	return true
+}
+
+// checkTools checks the external tools used by kube-copilot.
+func checkTools() bool {
+	ok := true
+	for _, tool := range externalTools {
+		version := getToolVersion(tool.name, tool.args...)
+		switch {
+		case version.Available:
+			fmt.Printf("%s %s %s\n", color.GreenString("✓"), tool.name, version.Version)
+		case requiredTools[tool.name]:
+			color.Red("✗ %s is not found in PATH", tool.name)
+			ok = false
+		default:
+			color.Yellow("! %s is not found in PATH (optional)", tool.name)
+		}
+	}
+	return ok
+}
+
+// checkCluster checks the access to the Kubernetes cluster.
+func checkCluster() bool {
+	namespaces, err := kubernetes.ListNamespaces()
+	if err != nil {
+		color.Red("✗ Unable to access the Kubernetes cluster: %v", err)
+		return false
+	}
+	fmt.Printf("%s Kubernetes cluster is accessible (%d namespaces)\n", color.GreenString("✓"), len(namespaces))
+	return true
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var jobsNamespace string
+
+func init() {
+	jobsCmd.PersistentFlags().StringVarP(&jobsNamespace, "namespace", "n", "default", "Namespace of the CronJob or Job")
+	addFailOnFlag(jobsCmd)
+	jobsCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	jobsCmd.ValidArgsFunction = completeResourceNames("cronjobs")
+}
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs [cronjob/<name> | job/<name>]",
+	Short: "Diagnose failing CronJobs and Jobs",
+	Long: `Explain why scheduled work isn't running: inspects the CronJob schedule and
+missed runs, concurrency policy, Job history, backoff limits and deadlines,
+and the status and logs of the failed Pods. A name without kind is a CronJob;
+without argument, all the CronJobs and Jobs of the namespace are checked.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var ref string
+		if len(args) > 0 {
+			ref = args[0]
+		}
+		kind, name, err := workflows.ParseJobRef(ref)
+		if err != nil {
+			printError("jobs", err)
+			return
+		}
+
+		target := fmt.Sprintf("namespace/%s", jobsNamespace)
+		if name != "" {
+			target = fmt.Sprintf("%s/%s/%s", strings.ToLower(kind), jobsNamespace, name)
+		}
+		printStatus("Diagnosing %s\n", target)
+		flow, err := workflows.NewJobsFlow(model, jobsNamespace, kind, name, verbose, maxIterations)
+		if err != nil {
+			printError("jobs", err)
+			return
+		}
+		flow.ConfirmToolCall = toolCallConfirmer()
+
+		start := time.Now()
+		stopProgress := attachProgress(flow)
+		response, err := flow.Run()
+		stopProgress()
+		if err != nil {
+			printError("jobs", err)
+			return
+		}
+
+		result := newRunResult("jobs", target, response, flow)
+		historyID := saveHistory("jobs", response, result)
+		printResult(result, response)
+		annotateGrafana("jobs", target, response, historyID, start)
+		checkFailOn(result.Findings)
+	},
+}
@@ -16,11 +16,24 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/telemetry"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
 	"github.com/spf13/cobra"
 )
 
+// annotationCluster marks the commands running the agents against the
+// cluster, which fail fast if the cluster can't be used instead of spending
+// LLM tokens on it.
+const annotationCluster = "kube-copilot/cluster"
+
 var (
 	// global flags
 	model         string
@@ -28,12 +41,71 @@ var (
 	countTokens   bool
 	verbose       bool
 	maxIterations int
+	outputFormat  string
+	assumeYes     bool
+	readOnly      bool
+	offline       bool
+	airGapped     bool
+	quiet         bool
+	noColor       bool
+
+	// failOn is the --fail-on severity of the audit, analyze and diagnose commands.
+	failOn string
+	// exitCode is the exit code of the process once the command completes.
+	exitCode int
 
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
 		Use:     "kube-copilot",
 		Version: VERSION,
 		Short:   "Kubernetes Copilot powered by OpenAI",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if noColor {
+				utils.DisableColor()
+			}
+			if quiet {
+				// Only the final answer is printed in quiet mode.
+				verbose = false
+			}
+			if err := loadConfig(cmd); err != nil {
+				return err
+			}
+			// The master key of a --kubeconfig secret reference may come from the configuration.
+			if err := resolveKubeconfig(); err != nil {
+				return err
+			}
+			if kubernetes.Kubeconfig != "" {
+				// Child processes (kubectl, python) follow the same kubeconfig.
+				os.Setenv("KUBECONFIG", kubernetes.Kubeconfig)
+			}
+			loadPlugins()
+			// The workflows without their own hook (e.g. audit) confirm the mutating tool calls too.
+			workflows.ConfirmToolCall = toolCallConfirmer()
+			if err := setupPrompts(); err != nil {
+				return err
+			}
+			if err := setupPolicy(); err != nil {
+				return err
+			}
+			if err := setupTenancy(); err != nil {
+				return err
+			}
+			if err := setupCassette(); err != nil {
+				return err
+			}
+			if cmd.Annotations[annotationCluster] == "required" {
+				if err := kubernetes.EnsureReachable(cmd.Context()); err != nil {
+					return err
+				}
+			}
+			if failOn != "" && !workflows.ValidSeverity(failOn) {
+				return fmt.Errorf("unsupported severity %q for --fail-on, should be one of CRITICAL, HIGH, MEDIUM or LOW", failOn)
+			}
+			return validateOutputFormat(cmd)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			printUsageSummary()
+		},
 	}
 )
 
@@ -41,20 +113,70 @@ var (
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&model, "model", "m", "gpt-4o", "OpenAI model to use")
 	rootCmd.PersistentFlags().IntVarP(&maxTokens, "max-tokens", "t", 2048, "Max tokens for the GPT model")
-	rootCmd.PersistentFlags().BoolVarP(&countTokens, "count-tokens", "c", false, "Print tokens count")
+	rootCmd.PersistentFlags().BoolVarP(&countTokens, "count-tokens", "c", false, "Print a summary of iterations, tool calls, tokens, estimated cost and elapsed time")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print the final answer (no status or progress messages)")
+	rootCmd.PersistentFlags().BoolVarP(&noColor, "no-color", "", false, "Disable colored output (also honors the NO_COLOR environment variable)")
 	rootCmd.PersistentFlags().IntVarP(&maxIterations, "max-iterations", "x", 30, "Max iterations for the agent running")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputMarkdown, "Output format (markdown, json, yaml, plain, or sarif for analyze and audit)")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Run mutating commands without asking for confirmation")
+	rootCmd.PersistentFlags().BoolVarP(&readOnly, "read-only", "", false, "Reject all commands that may change the cluster")
+	rootCmd.PersistentFlags().BoolVarP(&offline, "offline", "", false, "Disable the cluster access and answer only from the provided manifests and documents")
+	rootCmd.PersistentFlags().BoolVarP(&airGapped, "air-gapped", "", false, "Disable the internet access: no search tool, local trivy database and local LLM only")
+	rootCmd.PersistentFlags().StringVarP(&kubernetes.Kubeconfig, "kubeconfig", "", "", "Path to the kubeconfig file (defaults to KUBECONFIG or ~/.kube/config), or a secret reference such as store://<path>#<name>")
+	rootCmd.PersistentFlags().StringVarP(&kubernetes.Context, "context", "", "", "The name of the kubeconfig context to use")
+	rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{outputMarkdown, outputJSON, outputYAML, outputPlain, outputSARIF}, cobra.ShellCompDirectiveNoFileComp))
 
 	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(apiauditCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(dataSafetyCmd)
 	rootCmd.AddCommand(diagnoseCmd)
+	rootCmd.AddCommand(dnsCmd)
+	rootCmd.AddCommand(evalCmd)
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(helmDiffCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(jobsCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(oomCmd)
+	rootCmd.AddCommand(promptsCmd)
+	rootCmd.AddCommand(operatorCmd)
+	rootCmd.AddCommand(preflightCmd)
+	rootCmd.AddCommand(readinessCmd)
+	rootCmd.AddCommand(rebalanceCmd)
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.AddCommand(runbooksCmd)
+	rootCmd.AddCommand(secretsCmd)
+	rootCmd.AddCommand(tenancyCmd)
+	rootCmd.AddCommand(traceCmd)
 	rootCmd.AddCommand(executeCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(webhookCmd)
+
+	for _, cmd := range []*cobra.Command{auditCmd, dataSafetyCmd, diagnoseCmd, dnsCmd, helmDiffCmd, jobsCmd, oomCmd, readinessCmd, rebalanceCmd} {
+		cmd.Annotations = map[string]string{annotationCluster: "required"}
+	}
 }
 
 func main() {
+	shutdown, err := telemetry.InitTracing(context.Background(), "kube-copilot", VERSION)
+	if err != nil {
+		color.Yellow("Unable to initialize tracing: %v", err)
+	}
+
+	setupKubectlPlugin()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
+		exitCode = 1
 	}
+
+	removeKubeconfig()
+	auditlog.Close()
+	shutdown(context.Background())
+	os.Exit(exitCode)
 }
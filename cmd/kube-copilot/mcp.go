@@ -0,0 +1,59 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/feiskyer/kube-copilot/pkg/mcp"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+var mcpAllowWrite bool
+
+func init() {
+	mcpCmd.PersistentFlags().BoolVarP(&mcpAllowWrite, "allow-write", "", false, "Allow commands that may change the cluster (read-only by default)")
+}
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run a Model Context Protocol server exposing the kube-copilot tools over stdio",
+	Long: `Run a Model Context Protocol (MCP) server over stdio, so that MCP clients
+(e.g. Claude Desktop or Cursor) can use the kubectl, trivy, helm, events and
+logs tools. The tools are read-only unless --allow-write is set, and every
+execution is recorded in the audit log.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// There is no terminal to confirm mutating commands, so writes must be allowed explicitly.
+		tools.ReadOnly = readOnly || !mcpAllowWrite
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Fprintf(os.Stderr, "kube-copilot MCP server is listening on stdio (read-only: %v)\n", tools.ReadOnly)
+		server := mcp.NewServer("kube-copilot", VERSION, mcp.DefaultTools())
+		if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+			return err
+		}
+		return nil
+	},
+}
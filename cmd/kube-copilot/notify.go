@@ -0,0 +1,165 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/notify"
+	"github.com/feiskyer/kube-copilot/pkg/secrets"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// notifyTargets are the notification targets of the watch and operator commands.
+var notifyTargets []string
+
+// ticketTarget is the Jira or ServiceNow target of the tickets (see notify.ParseTicket).
+var ticketTarget string
+
+// addNotifyFlag adds the --notify flag to the command.
+func addNotifyFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringSliceVarP(&notifyTargets, "notify", "", nil, "Notification targets of the findings: slack:<url>, webhook:<url>, feishu:<url>, dingtalk:<url>, jira:<url>, servicenow:<url> or smtp://host:port?from=...&to=... (the URL may be a secret reference, e.g. slack:store://<path>#<name>)")
+}
+
+// sendNotification delivers msg to the notification targets and prints a
+// warning if any delivery fails.
+func sendNotification(msg notify.Message) {
+	if len(notifyTargets) == 0 {
+		return
+	}
+	notifiers, err := parseNotifyTargets(context.Background())
+	if err == nil {
+		err = notifiers.Notify(context.Background(), msg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Unable to send notification: %v", err))
+	}
+}
+
+// fileTicket files the findings of a diagnosis as a ticket of the ticket
+// target and prints its key, or a warning if it cannot be filed.
+func fileTicket(target, report string, findings []workflows.Finding, historyID string) {
+	if ticketTarget == "" {
+		fmt.Fprintln(os.Stderr, color.YellowString("Unable to file ticket: no ticket target, set the ticket configuration key or %s", envTicket))
+		return
+	}
+	ctx := context.Background()
+	resolved := ticketTarget
+	if kind, ref, ok := strings.Cut(ticketTarget, ":"); ok && secrets.ValidateRef(ref) == nil {
+		value, err := secrets.Resolve(ctx, ref)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Unable to file ticket: %v", err))
+			return
+		}
+		resolved = kind + ":" + value
+	}
+	filer, err := notify.ParseTicket(resolved)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Unable to file ticket: %v", err))
+		return
+	}
+
+	severity := highestSeverity(findings)
+	title := "kube-copilot diagnosis of " + target
+	if len(findings) > 0 {
+		title += ": " + findings[0].Title
+	}
+	if severity != "" {
+		title = fmt.Sprintf("[%s] %s", severity, title)
+	}
+	ticket, err := filer.File(ctx, notify.Message{
+		Title:    title,
+		Text:     formatTicket(target, report, findings, historyID),
+		Severity: severity,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Unable to file ticket: %v", err))
+		return
+	}
+	printStatus("Filed ticket %s: %s\n", ticket.ID, ticket.URL)
+}
+
+// formatTicket renders the description of a ticket: the summary of the
+// findings with their severity and remediation, followed by the full report.
+func formatTicket(target, report string, findings []workflows.Finding, historyID string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "kube-copilot diagnosed %s", target)
+	if kubernetes.Context != "" {
+		fmt.Fprintf(&sb, " in cluster context %s", kubernetes.Context)
+	}
+	sb.WriteString(".\n\n")
+	if len(findings) > 0 {
+		sb.WriteString("Findings:\n\n")
+		for i, finding := range findings {
+			fmt.Fprintf(&sb, "%d. %s", i+1, finding.Title)
+			if finding.Severity != "" {
+				fmt.Fprintf(&sb, " (%s)", finding.Severity)
+			}
+			sb.WriteString("\n")
+			if finding.Description != "" {
+				fmt.Fprintf(&sb, "   %s\n", finding.Description)
+			}
+			if finding.Remediation != "" {
+				fmt.Fprintf(&sb, "   Remediation: %s\n", finding.Remediation)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Report:\n\n")
+	sb.WriteString(strings.TrimSpace(report))
+	sb.WriteString("\n")
+	if historyID != "" {
+		fmt.Fprintf(&sb, "\nFull report with the commands run: kube-copilot history export %s\n", historyID)
+	}
+	return sb.String()
+}
+
+// parseNotifyTargets creates the Notifiers of the notification targets,
+// resolving the "<kind>:<secret reference>" ones (see secrets.Resolve).
+func parseNotifyTargets(ctx context.Context) (notify.Notifiers, error) {
+	targets := make([]string, 0, len(notifyTargets))
+	for _, target := range notifyTargets {
+		if kind, ref, ok := strings.Cut(target, ":"); ok && secrets.ValidateRef(ref) == nil {
+			value, err := secrets.Resolve(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			// SMTP secrets hold the whole smtp:// target including the password.
+			target = kind + ":" + value
+			if kind == "smtp" {
+				target = value
+			}
+		}
+		targets = append(targets, target)
+	}
+	return notify.ParseAll(targets)
+}
+
+// highestSeverity returns the highest severity of the findings, or "" if there is none.
+func highestSeverity(findings []workflows.Finding) string {
+	for _, severity := range []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"} {
+		if len(workflows.FindingsAtOrAbove(findings, severity)) > 0 {
+			return severity
+		}
+	}
+	return ""
+}
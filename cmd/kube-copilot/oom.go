@@ -0,0 +1,92 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/oomkill"
+	"github.com/feiskyer/kube-copilot/pkg/prometheus"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// envPrometheusToken is the environment variable of the optional Prometheus bearer token.
+const envPrometheusToken = "PROMETHEUS_TOKEN"
+
+var (
+	// prometheusURL enables the memory usage history when set.
+	prometheusURL string
+	oomNamespace  string
+	oomWindow     time.Duration
+)
+
+func init() {
+	oomCmd.PersistentFlags().StringVarP(&oomNamespace, "namespace", "n", "default", "Pod namespace")
+	oomCmd.PersistentFlags().StringVarP(&prometheusURL, "prometheus-url", "", "", "Prometheus URL of the memory usage history (e.g. http://prometheus.monitoring:9090)")
+	oomCmd.PersistentFlags().DurationVarP(&oomWindow, "window", "w", 24*time.Hour, "Time window of the memory usage history")
+	addFailOnFlag(oomCmd)
+	registerResourceCompletions(oomCmd, "pods")
+}
+
+var oomCmd = &cobra.Command{
+	Use:   "oom <pod>",
+	Short: "Analyze the OOM kills and memory leaks of a Pod",
+	Long: `Correlate the container restarts and OOMKilled reasons of a Pod with its memory
+limits and usage history from Prometheus, and recommend right-sized limits and
+leak suspicion indicators.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		printStatus("Analyzing the memory of Pod %s/%s\n", oomNamespace, name)
+		pod, err := kubernetes.GetPod(oomNamespace, name)
+		if err != nil {
+			printError("oom", err)
+			return
+		}
+
+		var history map[string][]prometheus.Point
+		if prometheusURL != "" {
+			client, err := prometheus.NewClient(prometheusURL, os.Getenv(envPrometheusToken))
+			if err != nil {
+				printError("oom", err)
+				return
+			}
+			if history, err = oomkill.History(context.Background(), client, oomNamespace, name, oomWindow); err != nil {
+				// The restarts and limits are still worth analyzing.
+				printStatus("Unable to get the memory usage history: %v\n", err)
+			}
+		} else {
+			printStatus("Prometheus is not configured (--prometheus-url), analyzing without the memory usage history\n")
+		}
+
+		containers := oomkill.Analyze(pod, history)
+		response, err := workflows.OOMFlow(model, oomNamespace, name, containers, oomWindow.String(), verbose)
+		if err != nil {
+			printError("oom", err)
+			return
+		}
+
+		result := newRunResult("oom", fmt.Sprintf("pod/%s/%s", oomNamespace, name), response, nil)
+		saveHistory("oom", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+	},
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/notify"
+	"github.com/feiskyer/kube-copilot/pkg/operator"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+var (
+	operatorNamespace  string
+	operatorWorkers    int
+	operatorAllowWrite bool
+	operatorResources  bool
+
+	autoDiagnose         bool
+	autoDiagnoseCooldown time.Duration
+	autoDiagnoseRate     int
+	autoDiagnosePublish  []string
+)
+
+func init() {
+	operatorCmd.PersistentFlags().StringVarP(&operatorNamespace, "namespace", "n", "", "Namespace of the custom resources to watch (all namespaces if empty)")
+	operatorCmd.PersistentFlags().IntVarP(&operatorWorkers, "workers", "", 2, "Number of workflows to run concurrently")
+	operatorCmd.PersistentFlags().BoolVarP(&operatorAllowWrite, "allow-write", "", false, "Allow CopilotTasks to run commands that may change the cluster (read-only by default)")
+	operatorCmd.PersistentFlags().BoolVarP(&operatorResources, "resources", "", true, "Watch the Diagnosis, Audit and CopilotTask resources (requires the CRDs)")
+	operatorCmd.PersistentFlags().BoolVarP(&autoDiagnose, "auto-diagnose", "", false, "Diagnose the Pods running into CrashLoopBackOff, OOMKilled, ImagePullBackOff or FailedScheduling")
+	operatorCmd.PersistentFlags().DurationVarP(&autoDiagnoseCooldown, "auto-diagnose-cooldown", "", time.Hour, "Minimum interval between two diagnoses of the same problem of a Pod")
+	operatorCmd.PersistentFlags().IntVarP(&autoDiagnoseRate, "auto-diagnose-rate", "", 10, "Maximum number of automatic diagnoses per hour (0 for unlimited)")
+	operatorCmd.PersistentFlags().StringSliceVarP(&autoDiagnosePublish, "publish", "", []string{"events"}, "Where to publish the automatic diagnoses: events, annotations or none")
+	addNotifyFlag(operatorCmd)
+	operatorCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	operatorCmd.RegisterFlagCompletionFunc("publish", cobra.FixedCompletions([]string{"events", "annotations", "none"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Run the workflows requested by Diagnosis, Audit and CopilotTask resources",
+	Long: `Run a controller that watches the Diagnosis, Audit and CopilotTask custom
+resources (kube-copilot.io/v1alpha1), runs the requested workflow and writes
+the report and findings back into their status. Install the CRDs with
+"kubectl apply -f deploy/crds.yaml" first.
+
+With --auto-diagnose, it also watches the Pods and diagnoses the ones running
+into CrashLoopBackOff, OOMKilled, ImagePullBackOff or FailedScheduling. Each
+problem of a Pod is diagnosed at most once per --auto-diagnose-cooldown, and
+the results are published as Events and/or annotations of the Pod.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if operatorWorkers <= 0 {
+			return fmt.Errorf("workers should be positive")
+		}
+		if !operatorResources && !autoDiagnose {
+			return fmt.Errorf("nothing to do: both --resources and --auto-diagnose are disabled")
+		}
+		for _, p := range autoDiagnosePublish {
+			if p != "events" && p != "annotations" && p != "none" {
+				return fmt.Errorf("invalid --publish %q: should be one of events, annotations or none", p)
+			}
+		}
+		if _, err := parseNotifyTargets(context.Background()); err != nil {
+			return err
+		}
+		// There is nobody to confirm mutating commands, so writes must be allowed explicitly.
+		tools.ReadOnly = readOnly || !operatorAllowWrite
+
+		config, err := kubernetes.GetKubeConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		errCh := make(chan error, 2)
+		running := 0
+		if operatorResources {
+			client, err := dynamic.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+			controller := operator.NewController(client, operatorNamespace, map[schema.GroupVersionResource]operator.Runner{
+				operator.DiagnosisResource:   runDiagnosisResource,
+				operator.AuditResource:       runAuditResource,
+				operator.CopilotTaskResource: runCopilotTaskResource,
+			})
+			running++
+			go func() { errCh <- controller.Run(ctx, operatorWorkers) }()
+		}
+		if autoDiagnose {
+			client, err := clientset.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+			diagnoser := operator.NewAutoDiagnoser(client, operatorNamespace, diagnosePod, autoDiagnoseCooldown, autoDiagnoseRate)
+			publish := strings.Join(autoDiagnosePublish, ",")
+			diagnoser.PublishEvents = strings.Contains(publish, "events")
+			diagnoser.PublishAnnotations = strings.Contains(publish, "annotations")
+			diagnoser.OnResult = func(r operator.DiagnosisResult) {
+				if r.Err != nil {
+					fmt.Fprintf(os.Stderr, "auto-diagnosis of Pod %s/%s (%s) failed: %v\n", r.Namespace, r.Pod, r.Reason, r.Err)
+					return
+				}
+				sendNotification(notify.Message{
+					Title:    fmt.Sprintf("kube-copilot diagnosed %s of Pod %s/%s", r.Reason, r.Namespace, r.Pod),
+					Text:     r.Report,
+					Severity: highestSeverity(workflows.ParseFindings(r.Report)),
+				})
+				annotateGrafana("auto-diagnose", fmt.Sprintf("pod/%s/%s", r.Namespace, r.Pod), r.Report, "", r.Start)
+			}
+			running++
+			go func() { errCh <- diagnoser.Run(ctx, operatorWorkers) }()
+		}
+
+		fmt.Fprintf(os.Stderr, "kube-copilot operator started (read-only: %v, auto-diagnose: %v)\n", tools.ReadOnly, autoDiagnose)
+		for i := 0; i < running; i++ {
+			if err := <-errCh; err != nil {
+				stop()
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// operatorDisabledTools are the tools the workflows of the operator may not
+// run: nobody reviews the python scripts, which would run with the
+// credentials of the operator.
+var operatorDisabledTools = []string{"python"}
+
+// diagnosePod runs the diagnose workflow for an automatic diagnosis.
+func diagnosePod(ctx context.Context, namespace, name string) (string, error) {
+	return runOperatorFlow(ctx, func() (*workflows.ReActFlow, error) {
+		return workflows.NewDiagnoseFlow(model, namespace, name, verbose, maxIterations)
+	})
+}
+
+// runDiagnosisResource diagnoses the Pod spec.pod of a Diagnosis.
+func runDiagnosisResource(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	namespace, pod, err := resourcePod(obj)
+	if err != nil {
+		return "", err
+	}
+	return runOperatorFlow(ctx, func() (*workflows.ReActFlow, error) {
+		return workflows.NewDiagnoseFlow(resourceModel(obj), namespace, pod, verbose, maxIterations)
+	})
+}
+
+// runAuditResource audits the Pod spec.pod of an Audit.
+func runAuditResource(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	namespace, pod, err := resourcePod(obj)
+	if err != nil {
+		return "", err
+	}
+	return workflows.AuditFlowContext(ctx, resourceModel(obj), namespace, pod, verbose)
+}
+
+// runCopilotTaskResource runs the spec.instructions of a CopilotTask.
+func runCopilotTaskResource(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	instructions, _, _ := unstructured.NestedString(obj.Object, "spec", "instructions")
+	if instructions == "" {
+		return "", fmt.Errorf("spec.instructions is required")
+	}
+	return runOperatorFlow(ctx, func() (*workflows.ReActFlow, error) {
+		return workflows.NewReActFlow(resourceModel(obj), instructions, verbose, maxIterations)
+	})
+}
+
+// runOperatorFlow runs a ReAct workflow of the operator without the
+// operatorDisabledTools, until ctx is done.
+func runOperatorFlow(ctx context.Context, newFlow func() (*workflows.ReActFlow, error)) (string, error) {
+	flow, err := newFlow()
+	if err != nil {
+		return "", err
+	}
+	flow.DisabledTools = operatorDisabledTools
+	return flow.RunContext(ctx)
+}
+
+// resourcePod returns the namespace (the resource namespace) and the name
+// (spec.pod) of the Pod targeted by a resource. A resource can't target the
+// Pods of other namespaces, which its creator may not be allowed to access.
+func resourcePod(obj *unstructured.Unstructured) (string, string, error) {
+	pod, _, _ := unstructured.NestedString(obj.Object, "spec", "pod")
+	if pod == "" {
+		return "", "", fmt.Errorf("spec.pod is required")
+	}
+	namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "namespace")
+	if namespace != "" && namespace != obj.GetNamespace() {
+		return "", "", fmt.Errorf("spec.namespace %q should be the namespace of the resource (%s)", namespace, obj.GetNamespace())
+	}
+	return obj.GetNamespace(), pod, nil
+}
+
+// resourceModel returns the spec.model of a resource, or the configured model.
+func resourceModel(obj *unstructured.Unstructured) string {
+	m, _, _ := unstructured.NestedString(obj.Object, "spec", "model")
+	return firstNonEmpty(m, model)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/sarif"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// Supported values of the --output flag.
+const (
+	outputMarkdown = "markdown"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputPlain    = "plain"
+	outputSARIF    = "sarif"
+)
+
+// sarifCommands are the commands supporting the sarif output format.
+var sarifCommands = []string{"analyze", "audit"}
+
+// runResult is the machine-readable result of a command, printed for json and yaml output.
+type runResult struct {
+	Command  string                 `json:"command"`
+	Model    string                 `json:"model"`
+	Target   string                 `json:"target,omitempty"`
+	Answer   string                 `json:"answer"`
+	Findings []workflows.Finding    `json:"findings,omitempty"`
+	Trace    []workflows.StepDetail `json:"trace,omitempty"`
+	DryRun   []workflows.ToolCall   `json:"dry_run,omitempty"`
+	// Artifacts are the large tool outputs saved outside of the chat history.
+	Artifacts []artifacts.Artifact `json:"artifacts,omitempty"`
+	// Prompts are the versions of the prompts used by the run, by name.
+	Prompts map[string]string `json:"prompts,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	// ErrorCode is the stable code of the error kind (see errdefs.Code).
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// setError records err and its error code in the result.
+func (r *runResult) setError(err error) {
+	r.Error = err.Error()
+	r.ErrorCode = errdefs.Code(err)
+}
+
+// printError prints the failure of a command and sets the exit code to 1. For
+// structured output, the error is printed as a runResult so that scripts can
+// branch on its code.
+func printError(command string, err error) {
+	exitCode = 1
+	if !isStructuredOutput() {
+		color.Red(err.Error())
+		return
+	}
+	result := &runResult{Command: command, Model: model}
+	result.setError(err)
+	printResult(result, "")
+}
+
+// newRunResult creates a runResult and extracts the findings from the answer.
+func newRunResult(command, target, answer string, flow *workflows.ReActFlow) *runResult {
+	findingsTarget := target
+	if command == "analyze" && analysisFile != "" {
+		// The manifest file is not a resource of the cluster.
+		findingsTarget = ""
+	}
+	result := &runResult{
+		Command:  command,
+		Model:    model,
+		Target:   target,
+		Answer:   answer,
+		Findings: workflows.ExtractFindings(command, findingsTarget, answer),
+		Prompts:  workflows.SelectedPrompts(),
+	}
+	if flow != nil && flow.PlanTracker != nil {
+		result.Trace = flow.PlanTracker.Steps
+	}
+	if flow != nil {
+		result.DryRun = flow.SkippedToolCalls
+		result.Artifacts = flow.Artifacts()
+	}
+	return result
+}
+
+// validateOutputFormat checks the value of the --output flag for the command.
+func validateOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputMarkdown, outputJSON, outputYAML, outputPlain:
+		return nil
+	case outputSARIF:
+		if slices.Contains(sarifCommands, cmd.Name()) {
+			return nil
+		}
+		return fmt.Errorf("output format sarif is only supported by %s", strings.Join(sarifCommands, " and "))
+	default:
+		return fmt.Errorf("unsupported output format %q, should be one of markdown, json, yaml, plain or sarif", outputFormat)
+	}
+}
+
+// isStructuredOutput returns true if the results should be printed as json, yaml or sarif.
+func isStructuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML || outputFormat == outputSARIF
+}
+
+// printResult prints the result in the format selected by --output.
+// data is printed as is for structured output; answer is printed for text output.
+func printResult(data interface{}, answer string) {
+	switch outputFormat {
+	case outputJSON:
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			color.Red(err.Error())
+			return
+		}
+		fmt.Println(string(out))
+	case outputYAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			color.Red(err.Error())
+			return
+		}
+		fmt.Print(string(out))
+	case outputSARIF:
+		out, err := json.MarshalIndent(toSARIF(data), "", "  ")
+		if err != nil {
+			color.Red(err.Error())
+			return
+		}
+		fmt.Println(string(out))
+	case outputPlain:
+		fmt.Println(answer)
+	default:
+		utils.RenderMarkdown(answer)
+	}
+}
+
+// toSARIF converts the runResults of the analyze and audit commands to a SARIF log.
+func toSARIF(data interface{}) *sarif.Log {
+	var results []*runResult
+	switch v := data.(type) {
+	case *runResult:
+		results = []*runResult{v}
+	case []*runResult:
+		results = v
+	}
+
+	targets := make([]sarif.Target, 0, len(results))
+	for _, r := range results {
+		target := sarif.Target{Name: r.Target, Findings: r.Findings, Error: r.Error}
+		if r.Command == "analyze" && analysisFile != "" {
+			target.File = analysisFile
+		}
+		targets = append(targets, target)
+	}
+	return sarif.New("kube-copilot", VERSION, targets)
+}
+
+// exitCodeFindings is the exit code when findings at or above --fail-on are found.
+const exitCodeFindings = 2
+
+// addFailOnFlag adds the --fail-on flag to the command.
+func addFailOnFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&failOn, "fail-on", "", "", "Exit with code 2 if findings at or above the severity (CRITICAL, HIGH, MEDIUM or LOW) are found")
+}
+
+// checkFailOn sets the exit code if any of the findings is at or above the --fail-on severity.
+func checkFailOn(findings []workflows.Finding) {
+	if failOn == "" {
+		return
+	}
+	if matched := workflows.FindingsAtOrAbove(findings, failOn); len(matched) > 0 {
+		exitCode = exitCodeFindings
+		if quiet {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", color.RedString("Found %d issue(s) at or above severity %s", len(matched), strings.ToUpper(failOn)))
+	}
+}
+
+// printStatus prints a progress message for text output. It is suppressed
+// for structured output so that stdout stays parseable, and in quiet mode.
+func printStatus(format string, a ...interface{}) {
+	if quiet || isStructuredOutput() {
+		return
+	}
+	fmt.Printf(format, a...)
+}
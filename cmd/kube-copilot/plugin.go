@@ -0,0 +1,48 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// kubectlPluginName is the binary name kubectl looks up for "kubectl copilot".
+const kubectlPluginName = "kubectl-copilot"
+
+// isKubectlPlugin returns true when the binary is invoked as a kubectl plugin.
+func isKubectlPlugin() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == kubectlPluginName
+}
+
+// setupKubectlPlugin adjusts the help text so that it shows "kubectl copilot"
+// when the binary is invoked as a kubectl plugin.
+func setupKubectlPlugin() {
+	if !isKubectlPlugin() {
+		return
+	}
+
+	rootCmd.Use = kubectlPluginName
+	rootCmd.Short = "Kubernetes Copilot powered by OpenAI (kubectl plugin)"
+	if rootCmd.Annotations == nil {
+		rootCmd.Annotations = map[string]string{}
+	}
+	rootCmd.Annotations[cobra.CommandDisplayNameAnnotation] = "kubectl copilot"
+}
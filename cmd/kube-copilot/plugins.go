@@ -0,0 +1,94 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+// pluginsDir is the directory of the tool plugins, from KUBE_COPILOT_PLUGINS_DIR or the configuration file.
+var pluginsDir string
+
+// pluginsErr is the error of the last plugin loading, reported by "plugins list".
+var pluginsErr error
+
+func init() {
+	pluginsCmd.AddCommand(pluginsListCmd)
+}
+
+// loadPlugins registers the plugins of the plugins directory as agent tools.
+// Invalid plugins are skipped with a warning so that they don't block the other commands.
+func loadPlugins() {
+	dir := pluginsDir
+	if dir == "" {
+		var err error
+		if dir, err = tools.DefaultPluginsDir(); err != nil {
+			pluginsErr = err
+			return
+		}
+	}
+
+	plugins, err := tools.LoadPlugins(dir)
+	tools.RegisterPlugins(plugins)
+	pluginsErr = err
+	if err != nil && !quiet {
+		fmt.Fprintln(os.Stderr, color.YellowString("Skipped invalid plugins: %v", err))
+	}
+}
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Manage the tool plugins of the agent",
+	Long: `Executables in the plugins directory (~/.kube-copilot/plugins, or the pluginsDir
+configuration key) are registered as agent tools. A plugin reads a JSON request
+from stdin and writes a JSON response to stdout:
+
+  {"action": "describe"}
+    -> {"name": "oncall", "description": "...", "input": "...", "readOnly": true}
+  {"action": "invoke", "input": "..."}
+    -> {"output": "..."} or {"error": "..."}`,
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registered plugins",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pluginsErr != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Skipped invalid plugins: %v", pluginsErr))
+		}
+		if isStructuredOutput() {
+			printResult(tools.Plugins, "")
+			return nil
+		}
+		if len(tools.Plugins) == 0 {
+			printStatus("No plugins registered.\n")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tREAD-ONLY\tPATH\tDESCRIPTION")
+		for _, plugin := range tools.Plugins {
+			fmt.Fprintf(w, "%s\t%t\t%s\t%s\n", plugin.Name, plugin.ReadOnly, plugin.Path, plugin.Description)
+		}
+		return w.Flush()
+	},
+}
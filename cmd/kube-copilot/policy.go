@@ -0,0 +1,43 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"github.com/feiskyer/kube-copilot/pkg/policy"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// policyPath is the Rego file or directory of the action policies (--policy).
+var policyPath string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&policyPath, "policy", "", "", "Rego policy file or directory evaluated for every tool execution (requires opa)")
+}
+
+// setupPolicy evaluates the action policies before every tool execution.
+func setupPolicy() error {
+	if policyPath == "" {
+		tools.Authorize = nil
+		return nil
+	}
+
+	engine, err := policy.New([]string{policyPath})
+	if err != nil {
+		return err
+	}
+	tools.Authorize = engine.Authorize
+	return nil
+}
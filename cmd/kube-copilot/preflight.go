@@ -0,0 +1,196 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// Statuses of the preflight checks.
+const (
+	preflightOK       = "ok"
+	preflightDegraded = "degraded"
+	preflightDisabled = "disabled"
+	preflightFailed   = "failed"
+)
+
+// trivyDBMaxAge is the age above which the vulnerability database of trivy is outdated.
+const trivyDBMaxAge = 7 * 24 * time.Hour
+
+// preflightCheck is the status of a feature in the current environment.
+type preflightCheck struct {
+	Feature string `json:"feature"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail"`
+}
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check which features are available in the current environment",
+	Long: `Check the LLM endpoint, the cluster access, the external tools, the
+vulnerability database of trivy and the optional integrations, and report the
+features which are degraded or disabled, e.g. by --offline or --air-gapped.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks := []preflightCheck{checkLLMEndpoint(), checkClusterAccess()}
+		checks = append(checks, checkToolBinaries()...)
+		checks = append(checks, checkTrivyDB(), checkWebSearch(), checkPrometheus())
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == preflightFailed {
+				failed++
+			}
+		}
+		if isStructuredOutput() {
+			printResult(checks, "")
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, c := range checks {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", c.Feature, colorStatus(c.Status), c.Detail)
+			}
+			w.Flush()
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d required features are not available", failed)
+		}
+		return nil
+	},
+}
+
+func colorStatus(status string) string {
+	switch status {
+	case preflightOK:
+		return color.GreenString(status)
+	case preflightFailed:
+		return color.RedString(status)
+	default:
+		return color.YellowString(status)
+	}
+}
+
+// checkLLMEndpoint checks that the LLM endpoint is allowed and answers.
+func checkLLMEndpoint() preflightCheck {
+	check := preflightCheck{Feature: "LLM"}
+	endpoint := workflows.LLMEndpoint()
+	if endpoint == "" {
+		check.Status, check.Detail = preflightFailed, "OPENAI_API_KEY or AZURE_OPENAI_API_KEY is not set"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := workflows.CheckLLM(ctx, model); err != nil {
+		check.Status, check.Detail = preflightFailed, err.Error()
+		return check
+	}
+	check.Status, check.Detail = preflightOK, fmt.Sprintf("model %s at %s", model, endpoint)
+	return check
+}
+
+// checkClusterAccess checks the access to the Kubernetes cluster.
+func checkClusterAccess() preflightCheck {
+	check := preflightCheck{Feature: "Cluster access"}
+	if offline {
+		check.Status, check.Detail = preflightDisabled, "offline mode: answers only from the attached manifests and documents"
+		return check
+	}
+	namespaces, err := kubernetes.ListNamespaces()
+	if err != nil {
+		check.Status, check.Detail = preflightFailed, err.Error()
+		return check
+	}
+	check.Status, check.Detail = preflightOK, fmt.Sprintf("%d namespaces", len(namespaces))
+	return check
+}
+
+// checkToolBinaries checks the external tools used by the agent.
+func checkToolBinaries() []preflightCheck {
+	var checks []preflightCheck
+	for _, tool := range externalTools {
+		check := preflightCheck{Feature: tool.name}
+		version := getToolVersion(tool.name, tool.args...)
+		switch {
+		case version.Available:
+			check.Status, check.Detail = preflightOK, version.Version
+		case requiredTools[tool.name] && !offline:
+			check.Status, check.Detail = preflightFailed, "not found in PATH"
+		default:
+			check.Status, check.Detail = preflightDegraded, "not found in PATH, the features using it are skipped"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkTrivyDB checks the vulnerability database used by the image scanning.
+func checkTrivyDB() preflightCheck {
+	check := preflightCheck{Feature: "Image scanning"}
+	localOnly := airGapped || tools.TrivySkipUpdate
+	info, err := tools.TrivyDB()
+	switch {
+	case err != nil && localOnly:
+		check.Status = preflightDegraded
+		check.Detail = fmt.Sprintf("%v: run \"trivy image --download-db-only\" on a connected machine and copy its db directory to the trivy cache directory (trivyCacheDir)", err)
+	case err != nil:
+		check.Status, check.Detail = preflightOK, "the vulnerability database is downloaded by the first scan"
+	case time.Since(info.UpdatedAt) > trivyDBMaxAge && localOnly:
+		check.Status = preflightDegraded
+		check.Detail = fmt.Sprintf("the vulnerability database in %s was updated %d days ago, recent vulnerabilities are missed", info.Path, int(time.Since(info.UpdatedAt).Hours()/24))
+	default:
+		check.Status, check.Detail = preflightOK, fmt.Sprintf("vulnerability database in %s updated at %s", info.Path, info.UpdatedAt.Format(time.RFC3339))
+	}
+	return check
+}
+
+// checkWebSearch checks the search tool.
+func checkWebSearch() preflightCheck {
+	check := preflightCheck{Feature: "Web search"}
+	switch {
+	case airGapped:
+		check.Status, check.Detail = preflightDisabled, "air-gapped mode"
+	case os.Getenv("GOOGLE_API_KEY") == "" || os.Getenv("GOOGLE_CSE_ID") == "":
+		check.Status, check.Detail = preflightDegraded, "GOOGLE_API_KEY and GOOGLE_CSE_ID are not set"
+	default:
+		check.Status, check.Detail = preflightOK, "Google Custom Search"
+	}
+	return check
+}
+
+// checkPrometheus checks the Prometheus server used by the OOM analysis.
+func checkPrometheus() preflightCheck {
+	check := preflightCheck{Feature: "Prometheus"}
+	switch {
+	case prometheusURL == "":
+		check.Status, check.Detail = preflightDegraded, "prometheusURL is not set, the OOM analysis relies on the Pod status only"
+	case airGapped && !workflows.IsLocalEndpoint(prometheusURL):
+		check.Status, check.Detail = preflightDegraded, fmt.Sprintf("%s is not on the local network", prometheusURL)
+	default:
+		check.Status, check.Detail = preflightOK, prometheusURL
+	}
+	return check
+}
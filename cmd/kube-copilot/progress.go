@@ -0,0 +1,151 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"golang.org/x/term"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progress displays the progress of a ReActFlow on stderr. On a TTY it
+// redraws a single spinner line; otherwise it prints one log line per event.
+type progress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	width   int
+	start   time.Time
+	status  string
+	paused  bool
+	stopped bool
+	done    chan struct{}
+}
+
+// attachProgress shows the progress of the flow unless quiet mode or verbose
+// output (which already logs every step) is enabled. The display is paused while the user
+// confirms a tool call. The returned function stops the display.
+func attachProgress(flow *workflows.ReActFlow) func() {
+	if verbose || quiet {
+		return func() {}
+	}
+
+	p := &progress{
+		out:   os.Stderr,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	fd := int(os.Stderr.Fd())
+	if term.IsTerminal(fd) {
+		p.tty = true
+		p.width, _, _ = term.GetSize(fd)
+		go p.spin()
+	}
+
+	flow.OnEvent = p.handle
+	if confirm := flow.ConfirmToolCall; confirm != nil {
+		flow.ConfirmToolCall = func(call workflows.ToolCall) (workflows.ToolCall, bool) {
+			p.pause(true)
+			defer p.pause(false)
+			return confirm(call)
+		}
+	}
+	return p.stop
+}
+
+// pause suspends or resumes the spinner.
+func (p *progress) pause(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = paused
+	if paused && p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+// handle updates the status from a flow event.
+func (p *progress) handle(event workflows.Event) {
+	var status string
+	switch event.Type {
+	case workflows.EventPlanning:
+		status = "Planning"
+	case workflows.EventStepStarted, workflows.EventThinking:
+		status = fmt.Sprintf("[%d/%d] %s", event.Iteration, event.MaxIterations, event.Step)
+	case workflows.EventToolStarted:
+		status = fmt.Sprintf("Running %s: %s", event.Tool, strings.Join(strings.Fields(event.ToolInput), " "))
+	default:
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || status == p.status {
+		return
+	}
+	p.status = status
+	if !p.tty {
+		fmt.Fprintf(p.out, "[%s] %s\n", p.elapsed(), status)
+	}
+}
+
+// spin redraws the spinner line until the progress is stopped.
+func (p *progress) spin() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			if p.status != "" && !p.paused {
+				line := fmt.Sprintf("%s %s (%s)", spinnerFrames[frame%len(spinnerFrames)], p.status, p.elapsed())
+				if p.width > 1 && len([]rune(line)) >= p.width {
+					line = string([]rune(line)[:p.width-1])
+				}
+				fmt.Fprintf(p.out, "\r\033[K%s", line)
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// stop stops the display and clears the spinner line.
+func (p *progress) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	close(p.done)
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+func (p *progress) elapsed() string {
+	return time.Since(p.start).Round(time.Second).String()
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// promptsDir is the directory of the prompt versions, from KUBE_COPILOT_PROMPTS_DIR or the configuration file.
+	promptsDir string
+	// configPromptVersions are the prompt versions pinned by KUBE_COPILOT_PROMPT_VERSIONS or the configuration file.
+	configPromptVersions []string
+	// promptVersions are the prompt versions pinned for this run (--prompt-version).
+	promptVersions []string
+	promptFile     string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringSliceVarP(&promptVersions, "prompt-version", "", nil, "Pin the version of a prompt for this run, e.g. audit=v2 (see the prompts command)")
+	promptsAddCmd.Flags().StringVarP(&promptFile, "file", "f", "", "File holding the prompt (\"-\" for stdin)")
+	promptsAddCmd.MarkFlagRequired("file")
+
+	promptsCmd.AddCommand(promptsListCmd)
+	promptsCmd.AddCommand(promptsShowCmd)
+	promptsCmd.AddCommand(promptsAddCmd)
+}
+
+// promptStore returns the store of the prompt versions.
+func promptStore() (*prompts.Store, error) {
+	dir := promptsDir
+	if dir == "" {
+		var err error
+		if dir, err = prompts.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return prompts.NewStore(dir), nil
+}
+
+// setupPrompts selects the versions of the prompts of the workflows. The
+// versions pinned by --prompt-version take precedence over the configured ones.
+func setupPrompts() error {
+	selector, err := newPromptSelector(nil)
+	if err != nil {
+		return err
+	}
+	workflows.Prompts = selector
+	return nil
+}
+
+// newPromptSelector creates a validated selector of the configured prompt
+// versions, with the extra pins taking precedence.
+func newPromptSelector(extra []string) (*prompts.Selector, error) {
+	store, err := promptStore()
+	if err != nil {
+		return nil, err
+	}
+	values := append(append([]string{}, configPromptVersions...), promptVersions...)
+	pins, err := prompts.ParsePins(append(values, extra...))
+	if err != nil {
+		return nil, err
+	}
+
+	selector := prompts.NewSelector(store, workflows.PromptTemplates, pins)
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
+
+// promptInfo describes a prompt in the prompts list.
+type promptInfo struct {
+	Name     string         `json:"name"`
+	Versions []string       `json:"versions"`
+	Rollout  map[string]int `json:"rollout,omitempty"`
+	Pinned   string         `json:"pinned,omitempty"`
+}
+
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Manage the versions of the prompts",
+	Long: `The versions of the prompts are stored as <name>/<version>.md files in the
+prompts directory (~/.kube-copilot/prompts, or the promptsDir configuration key),
+next to the builtin version of kube-copilot. A version is selected once per run:
+the version pinned by --prompt-version or the promptVersions configuration key,
+else a version drawn according to the weights of <name>/rollout.yaml, e.g.
+
+  builtin: 90
+  v2: 10
+
+else the builtin version. The versions must keep the placeholders (e.g.
+{namespace} or %s) of the builtin prompt.`,
+}
+
+var promptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the prompts with their versions and rollouts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := promptStore()
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(workflows.PromptTemplates))
+		for name := range workflows.PromptTemplates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		infos := make([]promptInfo, 0, len(names))
+		for _, name := range names {
+			versions, err := store.Versions(name)
+			if err != nil {
+				return err
+			}
+			rollout, err := store.Rollout(name)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, promptInfo{
+				Name:     name,
+				Versions: append([]string{prompts.Builtin}, versions...),
+				Rollout:  rollout,
+				Pinned:   workflows.Prompts.Pins[name],
+			})
+		}
+
+		if isStructuredOutput() {
+			printResult(infos, "")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSIONS\tROLLOUT\tPINNED")
+		for _, info := range infos {
+			var rollout []string
+			for version, weight := range info.Rollout {
+				rollout = append(rollout, fmt.Sprintf("%s=%d", version, weight))
+			}
+			sort.Strings(rollout)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, strings.Join(info.Versions, ","), strings.Join(rollout, ","), info.Pinned)
+		}
+		return w.Flush()
+	},
+}
+
+var promptsShowCmd = &cobra.Command{
+	Use:   "show <name>[@<version>]",
+	Short: "Print a version of a prompt (the builtin version by default)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, version, _ := strings.Cut(args[0], "@")
+		builtin, ok := workflows.PromptTemplates[name]
+		if !ok {
+			return fmt.Errorf("unknown prompt %q", name)
+		}
+		if version == "" || version == prompts.Builtin {
+			fmt.Println(strings.TrimSpace(builtin))
+			return nil
+		}
+
+		store, err := promptStore()
+		if err != nil {
+			return err
+		}
+		text, err := store.Load(name, version)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(text))
+		return nil
+	},
+}
+
+var promptsAddCmd = &cobra.Command{
+	Use:   "add <name> <version>",
+	Short: "Store a new version of a prompt",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, version := args[0], args[1]
+		builtin, ok := workflows.PromptTemplates[name]
+		if !ok {
+			return fmt.Errorf("unknown prompt %q", name)
+		}
+
+		var data []byte
+		var err error
+		if promptFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(promptFile)
+		}
+		if err != nil {
+			return err
+		}
+		want, got := prompts.Placeholders(builtin), prompts.Placeholders(string(data))
+		if !slices.Equal(got, want) {
+			return fmt.Errorf("the prompt has the placeholders %v instead of %v", got, want)
+		}
+
+		store, err := promptStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Save(name, version, string(data)); err != nil {
+			return err
+		}
+		printStatus("Stored prompt version %s@%s\n", name, version)
+		return nil
+	},
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/availability"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var readinessNamespace string
+
+func init() {
+	readinessCmd.PersistentFlags().StringVarP(&readinessNamespace, "namespace", "n", "default", "Namespace to check")
+	addFailOnFlag(readinessCmd)
+	readinessCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+}
+
+var readinessCmd = &cobra.Command{
+	Use:   "readiness",
+	Short: "Check whether the workloads in a namespace are ready for a maintenance window",
+	Long: `Check the replica counts, PodDisruptionBudgets, topology spread, anti-affinity
+and single points of failure of the Deployments and StatefulSets in a namespace,
+and report whether they stay available during node drains and upgrades.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printStatus("Checking the availability of namespace %s\n", readinessNamespace)
+		snapshot, err := kubernetes.GetSnapshot(readinessNamespace)
+		if err != nil {
+			printError("readiness", err)
+			return
+		}
+		if len(snapshot.Nodes) == 0 {
+			printStatus("Nodes are not accessible, skipping the zone checks\n")
+		}
+
+		issues := availability.Check(snapshot)
+		printStatus("Found %d availability issues (%s)\n", len(issues), availability.Verdict(issues))
+		response, err := workflows.ReadinessFlow(model, readinessNamespace, issues, verbose)
+		if err != nil {
+			printError("readiness", err)
+			return
+		}
+
+		result := newRunResult("readiness", fmt.Sprintf("namespace/%s", readinessNamespace), response, nil)
+		saveHistory("readiness", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+	},
+}
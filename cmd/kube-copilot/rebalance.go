@@ -0,0 +1,77 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/rebalance"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rebalanceThresholds = rebalance.DefaultThresholds
+	rebalanceGenerate   bool
+)
+
+func init() {
+	rebalanceCmd.PersistentFlags().IntVarP(&rebalanceThresholds.Low, "low", "", rebalance.DefaultThresholds.Low, "Percentage of the allocatable CPU and memory requested below which a Node is underutilized")
+	rebalanceCmd.PersistentFlags().IntVarP(&rebalanceThresholds.High, "high", "", rebalance.DefaultThresholds.High, "Percentage of the allocatable CPU or memory requested above which a Node is overutilized")
+	rebalanceCmd.PersistentFlags().BoolVarP(&rebalanceGenerate, "generate", "", false, "Generate the manifests of the recommendations and a DeschedulerPolicy")
+	addFailOnFlag(rebalanceCmd)
+}
+
+var rebalanceCmd = &cobra.Command{
+	Use:   "rebalance",
+	Short: "Recommend how to rebalance the workloads across the Nodes",
+	Long: `Detect the overutilized and underutilized Nodes (from the resource requests,
+as the descheduler does) and the workloads concentrated on few Nodes, and
+recommend affinity, taint or descheduler policies to rebalance them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := rebalanceThresholds.Validate(); err != nil {
+			return err
+		}
+
+		printStatus("Analyzing the Node utilization and Pod placement\n")
+		snapshot, err := kubernetes.GetSnapshot("")
+		if err != nil {
+			printError("rebalance", err)
+			return nil
+		}
+		if len(snapshot.Nodes) == 0 {
+			return fmt.Errorf("no Nodes found, listing the Nodes requires the cluster-wide permission")
+		}
+
+		report := rebalance.Analyze(snapshot, rebalanceThresholds)
+		response, err := workflows.RebalanceFlow(model, report, rebalanceGenerate, verbose)
+		if err != nil {
+			printError("rebalance", err)
+			return nil
+		}
+		if rebalanceGenerate {
+			response = fmt.Sprintf("%s\n\n## DeschedulerPolicy\n\n```yaml\n%s```\n", response, report.Policy())
+		}
+
+		result := newRunResult("rebalance", "cluster", response, nil)
+		saveHistory("rebalance", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+		return nil
+	},
+}
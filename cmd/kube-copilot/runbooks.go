@@ -0,0 +1,166 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/runbooks"
+	"github.com/spf13/cobra"
+)
+
+var runbooksLimit int
+
+func init() {
+	runbooksSearchCmd.PersistentFlags().IntVarP(&runbooksLimit, "limit", "", 5, "Maximum number of excerpts to show")
+
+	runbooksCmd.AddCommand(runbooksIngestCmd)
+	runbooksCmd.AddCommand(runbooksSearchCmd)
+	runbooksCmd.AddCommand(runbooksListCmd)
+	runbooksCmd.AddCommand(runbooksRemoveCmd)
+}
+
+var runbooksCmd = &cobra.Command{
+	Use:   "runbooks",
+	Short: "Manage the runbooks searched by the agent",
+	Long: `Manage the local index of internal runbooks and postmortems (markdown).
+Once runbooks are ingested, the diagnose and execute workflows can search them
+with the "runbooks" tool and follow the organization-specific procedures.
+The index is stored in ~/.kube-copilot/runbooks.json and never leaves the machine.`,
+}
+
+var runbooksIngestCmd = &cobra.Command{
+	Use:   "ingest <path>...",
+	Short: "Ingest markdown files or directories of markdown files",
+	Long: `Ingest markdown files (*.md and *.markdown) or directories of markdown files.
+Runbooks ingested again replace their previous version.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, index, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		before := len(index.Chunks)
+		var files []string
+		for _, arg := range args {
+			ingested, err := index.IngestPath(arg)
+			if err != nil {
+				return err
+			}
+			files = append(files, ingested...)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no markdown file found in %s", strings.Join(args, ", "))
+		}
+		if err := index.Save(path); err != nil {
+			return err
+		}
+
+		printStatus("%s\n", color.GreenString("Ingested %d runbook(s), %d excerpts indexed (%+d)", len(files), len(index.Chunks), len(index.Chunks)-before))
+		return nil
+	},
+}
+
+var runbooksSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the ingested runbooks",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, index, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		results := index.Search(strings.Join(args, " "), runbooksLimit)
+		var sb strings.Builder
+		for _, r := range results {
+			fmt.Fprintf(&sb, "## %s", r.Source)
+			if r.Heading != "" {
+				fmt.Fprintf(&sb, " (%s)", r.Heading)
+			}
+			fmt.Fprintf(&sb, "\n\n*Score: %.2f*\n\n%s\n\n", r.Score, r.Text)
+		}
+		if len(results) == 0 {
+			sb.WriteString("No relevant runbook found.")
+		}
+		printResult(results, strings.TrimSpace(sb.String()))
+		return nil
+	},
+}
+
+var runbooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the ingested runbooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, index, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+
+		counts := index.Sources()
+		sources := make([]string, 0, len(counts))
+		for source := range counts {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+
+		if isStructuredOutput() {
+			printResult(counts, "")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tEXCERPTS")
+		for _, source := range sources {
+			fmt.Fprintf(w, "%s\t%d\n", source, counts[source])
+		}
+		return w.Flush()
+	},
+}
+
+var runbooksRemoveCmd = &cobra.Command{
+	Use:   "remove <source>...",
+	Short: "Remove ingested runbooks (as shown by \"runbooks list\")",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, index, err := loadRunbooks()
+		if err != nil {
+			return err
+		}
+		for _, source := range args {
+			if !index.Remove(source) {
+				return fmt.Errorf("runbook %s is not ingested", source)
+			}
+		}
+		return index.Save(path)
+	},
+}
+
+// loadRunbooks loads the runbook index at the default path.
+func loadRunbooks() (string, *runbooks.Index, error) {
+	path, err := runbooks.DefaultPath()
+	if err != nil {
+		return "", nil, err
+	}
+	index, err := runbooks.Load(path)
+	return path, index, err
+}
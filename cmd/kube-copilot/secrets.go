@@ -0,0 +1,289 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// secretsStorePath is the --store path of the secrets commands.
+	secretsStorePath string
+	// secretsFromFile is the --from-file of "secrets set" ("-" for stdin).
+	secretsFromFile string
+	// kubeconfigDir is the private directory of the decrypted kubeconfig of a
+	// --kubeconfig secret reference, removed on exit.
+	kubeconfigDir string
+)
+
+func init() {
+	secretsCmd.PersistentFlags().StringVarP(&secretsStorePath, "store", "", "", "Path of the encrypted secret store (defaults to ~/.kube-copilot/secrets.json)")
+	secretsSetCmd.Flags().StringVarP(&secretsFromFile, "from-file", "f", "", "Read the value from a file, e.g. a kubeconfig (\"-\" for stdin)")
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	secretsCmd.AddCommand(secretsRotateCmd)
+	secretsCmd.AddCommand(secretsGenerateKeyCmd)
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encrypted secret store (provider keys, kubeconfigs, webhook URLs)",
+	Long: `Manage the secret store, which encrypts the provider keys, kubeconfigs and
+webhook URLs at rest with a master key from KUBE_COPILOT_MASTER_KEY or the
+masterKeySecret configuration (e.g. a Vault secret or a KMS-encrypted Kubernetes
+Secret). The stored secrets are referenced as store://<path>#<name> by
+apiKeySecret, grafanaTokenSecret, --notify and --kubeconfig.
+
+To rotate the master key, set the new key in KUBE_COPILOT_MASTER_KEY, the old one
+in KUBE_COPILOT_PREVIOUS_MASTER_KEYS, and run "kube-copilot secrets rotate".`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Encrypt and store a secret (the value is read from stdin when omitted)",
+	Example: `  kube-copilot secrets set openai-key
+  kube-copilot secrets set kubeconfig-prod --from-file ~/.kube/prod.yaml
+  kube-copilot config set apiKeySecret store://~/.kube-copilot/secrets.json#openai-key`,
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := readSecretValue(args)
+		if err != nil {
+			return err
+		}
+		store, path, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		err = store.Set(args[0], value)
+		recordSecretChange(args[0], err)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Stored secret %s, reference it as store://%s#%s\n", color.GreenString("✓"), args[0], path, args[0])
+		return nil
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the stored secrets and the master keys encrypting them",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, _, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		infos := store.List()
+		if isStructuredOutput() {
+			printResult(infos, "")
+			return nil
+		}
+		if len(infos) == 0 {
+			fmt.Println("No secrets stored.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKEY ID\tUPDATED")
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, info.KeyID, info.UpdatedAt.Local().Format(time.RFC3339))
+		}
+		return w.Flush()
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:          "delete <name>",
+	Short:        "Delete a stored secret",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, _, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		err = store.Delete(args[0])
+		recordSecretChange(args[0], err)
+		return err
+	},
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt the stored secrets with the current master key",
+	Long: `Re-encrypt the secrets encrypted with a previous master key (from
+KUBE_COPILOT_PREVIOUS_MASTER_KEYS) with the current one (from
+KUBE_COPILOT_MASTER_KEY or masterKeySecret). The previous keys can be discarded
+once the rotation succeeds.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, err := secrets.MasterKeys(context.Background())
+		if err != nil {
+			return err
+		}
+		store, _, err := openSecretStore()
+		if err != nil {
+			return err
+		}
+		rotated, err := store.Rotate()
+		status := auditlog.StatusSucceeded
+		if err != nil {
+			status = auditlog.StatusFailed
+		}
+		auditlog.Record(auditlog.Event{Type: auditlog.EventConfigChange, Key: "secrets", Value: "rotated to master key " + secrets.KeyID(keys[0]), Status: status})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Re-encrypted %d secrets with master key %s\n", color.GreenString("✓"), rotated, secrets.KeyID(keys[0]))
+		return nil
+	},
+}
+
+var secretsGenerateKeyCmd = &cobra.Command{
+	Use:   "generate-key",
+	Short: "Print a new random master key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := secrets.GenerateMasterKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	},
+}
+
+// openSecretStore opens the --store secret store with the master keys.
+func openSecretStore() (*secrets.Store, string, error) {
+	path := secretsStorePath
+	if path == "" {
+		var err error
+		if path, err = secrets.DefaultStorePath(); err != nil {
+			return nil, "", err
+		}
+	}
+	keys, err := secrets.MasterKeys(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := secrets.OpenStore(path, keys...)
+	return store, path, err
+}
+
+// readSecretValue returns the value argument, or reads it from --from-file or stdin.
+func readSecretValue(args []string) (string, error) {
+	if len(args) > 1 {
+		if secretsFromFile != "" {
+			return "", fmt.Errorf("value and --from-file are mutually exclusive")
+		}
+		return args[1], nil
+	}
+
+	var data []byte
+	var err error
+	switch secretsFromFile {
+	case "", "-":
+		data, err = io.ReadAll(os.Stdin)
+		// A value typed or piped on stdin ends with a newline.
+		data = []byte(strings.TrimRight(string(data), "\r\n"))
+	default:
+		data, err = os.ReadFile(secretsFromFile)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty secret value")
+	}
+	return string(data), nil
+}
+
+// recordSecretChange records the change of a stored secret, without its value, in the audit log.
+func recordSecretChange(name string, err error) {
+	status := auditlog.StatusSucceeded
+	if err != nil {
+		status = auditlog.StatusFailed
+	}
+	auditlog.Record(auditlog.Event{Type: auditlog.EventConfigChange, Key: "secrets/" + name, Status: status})
+}
+
+// resolveKubeconfig decrypts a --kubeconfig secret reference to a file of a
+// private temporary directory, which is removed by removeKubeconfig on exit
+// or when kube-copilot is interrupted.
+func resolveKubeconfig() error {
+	if kubernetes.Kubeconfig == "" || secrets.ValidateRef(kubernetes.Kubeconfig) != nil {
+		return nil
+	}
+	value, err := secrets.Resolve(context.Background(), kubernetes.Kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	// MkdirTemp creates the directory with owner-only permissions.
+	dir, err := os.MkdirTemp("", "kube-copilot-kubeconfig-*")
+	if err != nil {
+		return err
+	}
+	kubeconfigDir = dir
+	removeKubeconfigOnSignal()
+	file := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(file, []byte(value), 0600); err != nil {
+		return err
+	}
+	kubernetes.Kubeconfig = file
+	return nil
+}
+
+// removeKubeconfigOnSignal removes the decrypted kubeconfig when kube-copilot
+// is interrupted, and then delivers the signal again so that it terminates
+// kube-copilot, or stops the commands handling it, as usual.
+func removeKubeconfigOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		removeKubeconfig()
+		signal.Stop(signals)
+		if process, err := os.FindProcess(os.Getpid()); err != nil || process.Signal(sig) != nil {
+			os.Exit(130)
+		}
+	}()
+}
+
+// removeKubeconfig removes the decrypted kubeconfig, if any.
+func removeKubeconfig() {
+	if kubeconfigDir != "" {
+		os.RemoveAll(kubeconfigDir)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tenancy"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+// systemTenancyPath is the tenancy mapping installed by the administrators,
+// which takes precedence over the user configuration so that users can't opt out.
+const systemTenancyPath = "/etc/kube-copilot/tenancy.yaml"
+
+// tenancyPath is the tenancy mapping from KUBE_COPILOT_TENANCY or the configuration file.
+var tenancyPath string
+
+func init() {
+	tenancyCmd.AddCommand(tenancyShowCmd)
+}
+
+// tenancyFile returns the path of the tenancy mapping in effect, or "" if there is none.
+func tenancyFile() string {
+	if _, err := os.Stat(systemTenancyPath); err == nil {
+		return systemTenancyPath
+	}
+	return tenancyPath
+}
+
+// setupTenancy restricts the tools and the cluster accesses to the tenants of the current user.
+func setupTenancy() error {
+	tools.Tenancy = nil
+	kubernetes.Tenancy = nil
+	path := tenancyFile()
+	if path == "" {
+		return nil
+	}
+
+	t, err := tenancy.Load(path)
+	if err != nil {
+		return err
+	}
+	scope := t.ScopeFor(tenancy.CurrentUser())
+	tools.Tenancy = scope
+	kubernetes.Tenancy = scope
+	return nil
+}
+
+var tenancyCmd = &cobra.Command{
+	Use:   "tenancy",
+	Short: "Inspect the tenancy mapping of the users to their clusters and namespaces",
+}
+
+var tenancyShowCmd = &cobra.Command{
+	Use:          "show [user]",
+	Short:        "Show the clusters and namespaces granted to a user (defaults to the current user)",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := tenancyFile()
+		if path == "" {
+			return fmt.Errorf("no tenancy mapping configured, set the tenancy configuration key or install %s", systemTenancyPath)
+		}
+		t, err := tenancy.Load(path)
+		if err != nil {
+			return err
+		}
+		name := tenancy.CurrentUser()
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		scope := t.ScopeFor(name)
+		if isStructuredOutput() {
+			printResult(scope, "")
+			return nil
+		}
+		if len(scope.Tenants) == 0 {
+			fmt.Printf("User %s belongs to no tenant in %s: all cluster accesses are denied.\n", name, path)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TENANT\tCLUSTERS\tNAMESPACES\tCLUSTER-SCOPED")
+		for _, tenant := range scope.Tenants {
+			clusterScoped := "none"
+			switch {
+			case len(tenant.Namespaces) == 0:
+				clusterScoped = "read-write"
+			case tenant.ClusterScopedRead:
+				clusterScoped = "read"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tenant.Name, orAll(tenant.Clusters), orAll(tenant.Namespaces), clusterScoped)
+		}
+		return w.Flush()
+	},
+}
+
+// orAll joins the patterns, or returns "*" if there is none.
+func orAll(patterns []string) string {
+	if len(patterns) == 0 {
+		return "*"
+	}
+	return strings.Join(patterns, ",")
+}
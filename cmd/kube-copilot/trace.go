@@ -0,0 +1,70 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/pathtrace"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var traceNamespace string
+
+func init() {
+	traceCmd.PersistentFlags().StringVarP(&traceNamespace, "namespace", "n", "default", "Namespace of the Ingress or HTTPRoute")
+	addFailOnFlag(traceCmd)
+	traceCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+}
+
+var traceCmd = &cobra.Command{
+	Use:   "trace <url>",
+	Short: "Trace the path of HTTP requests and find the first broken hop",
+	Long: `Trace the HTTP requests to a URL from the Ingress or Gateway API route to the
+Service, its endpoints, the readiness of the Pods and the container port, and
+explain the first broken hop with a hop-by-hop table.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printStatus("Tracing %s in namespace %s\n", args[0], traceNamespace)
+		routing, err := kubernetes.GetRouting(traceNamespace)
+		if err != nil {
+			printError("trace", err)
+			return
+		}
+
+		trace, err := pathtrace.Run(routing, args[0])
+		if err != nil {
+			printError("trace", err)
+			return
+		}
+		if hop := trace.FirstBroken(); hop != nil {
+			printStatus("First broken hop: %s\n", hop.Name)
+		}
+
+		response, err := workflows.TraceFlow(model, traceNamespace, trace, verbose)
+		if err != nil {
+			printError("trace", err)
+			return
+		}
+
+		result := newRunResult("trace", fmt.Sprintf("url/%s/%s", traceNamespace, trace.URL), response, nil)
+		saveHistory("trace", response, result)
+		printResult(result, response)
+		checkFailOn(result.Findings)
+	},
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/llms"
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+// startTime is the time when kube-copilot started.
+var startTime = time.Now()
+
+// printUsageSummary prints the LLM and tool usage of the run to stderr if --count-tokens is set.
+func printUsageSummary() {
+	usage := workflows.GetUsage()
+	if !countTokens || usage.Requests == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, color.BlueString("\nUsage summary:"))
+	fmt.Fprintf(os.Stderr, "  Iterations:     %d\n", usage.Iterations)
+	if usage.CachedToolCalls > 0 {
+		fmt.Fprintf(os.Stderr, "  Tool calls:     %d (+%d served from cache)\n", usage.ToolCalls, usage.CachedToolCalls)
+	} else {
+		fmt.Fprintf(os.Stderr, "  Tool calls:     %d\n", usage.ToolCalls)
+	}
+	if hits, misses := tools.TrivyCacheStats(); hits+misses > 0 {
+		fmt.Fprintf(os.Stderr, "  Image scans:    %d (%d served from cache, %.0f%% hit rate)\n",
+			hits+misses, hits, 100*float64(hits)/float64(hits+misses))
+	}
+	fmt.Fprintf(os.Stderr, "  LLM requests:   %d\n", usage.Requests)
+	fmt.Fprintf(os.Stderr, "  Tokens:         %d prompt + %d completion = %d total\n",
+		usage.PromptTokens, usage.CompletionTokens, usage.PromptTokens+usage.CompletionTokens)
+	if usage.RawObservationTokens > 0 {
+		fmt.Fprintf(os.Stderr, "  Observations:   ~%d tokens compacted to ~%d (%.0f%% saved)\n",
+			usage.RawObservationTokens, usage.ObservationTokens, 100*float64(usage.RawObservationTokens-usage.ObservationTokens)/float64(usage.RawObservationTokens))
+	}
+	if usage.UngroundedClaims > 0 {
+		fmt.Fprintf(os.Stderr, "  Unverified:     %d claims not found in the observations\n", usage.UngroundedClaims)
+	}
+	if selected := workflows.SelectedPrompts(); customPrompts(selected) {
+		fmt.Fprintf(os.Stderr, "  Prompts:        %s\n", prompts.Label(selected))
+	}
+	if cost, ok := llms.EstimateCost(model, usage.PromptTokens, usage.CompletionTokens); ok {
+		fmt.Fprintf(os.Stderr, "  Estimated cost: $%.4f (%s list price)\n", cost, model)
+	} else {
+		fmt.Fprintf(os.Stderr, "  Estimated cost: unknown price for model %s\n", model)
+	}
+	fmt.Fprintf(os.Stderr, "  Elapsed time:   %s\n", time.Since(startTime).Round(time.Second))
+}
+
+// customPrompts returns true if a version other than the builtin one was selected for any prompt.
+func customPrompts(selected map[string]string) bool {
+	for _, version := range selected {
+		if version != prompts.Builtin {
+			return true
+		}
+	}
+	return false
+}
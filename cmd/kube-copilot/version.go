@@ -16,7 +16,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -26,10 +33,102 @@ const (
 	VERSION = "v0.6.4"
 )
 
+// Build metadata injected at build time, e.g.
+// go build -ldflags "-X main.gitCommit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+// externalTools are the external commands used by kube-copilot and the
+// arguments to print their versions.
+var externalTools = []struct {
+	name string
+	args []string
+}{
+	{name: "kubectl", args: []string{"version", "--client"}},
+	{name: "trivy", args: []string{"--version"}},
+	{name: "jq", args: []string{"--version"}},
+	{name: "python3", args: []string{"--version"}},
+	{name: "helm", args: []string{"version", "--short"}},
+}
+
+// toolVersion is the version of an external tool.
+type toolVersion struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+}
+
+// versionInfo is the version and environment information of kube-copilot.
+type versionInfo struct {
+	Version   string        `json:"version"`
+	GitCommit string        `json:"gitCommit"`
+	BuildDate string        `json:"buildDate"`
+	GoVersion string        `json:"goVersion"`
+	Platform  string        `json:"platform"`
+	Tools     []toolVersion `json:"tools"`
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
-	Short: "Print the version of kube-copilot",
+	Short: "Print the version of kube-copilot and the external tools",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("kube-copiolt %s\n", VERSION)
+		info := versionInfo{
+			Version:   VERSION,
+			GitCommit: gitCommit,
+			BuildDate: buildDate,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		}
+		for _, tool := range externalTools {
+			info.Tools = append(info.Tools, getToolVersion(tool.name, tool.args...))
+		}
+
+		if isStructuredOutput() {
+			printResult(info, "")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "kube-copilot:\t%s\n", info.Version)
+		fmt.Fprintf(w, "Git commit:\t%s\n", info.GitCommit)
+		fmt.Fprintf(w, "Build date:\t%s\n", info.BuildDate)
+		fmt.Fprintf(w, "Go version:\t%s\n", info.GoVersion)
+		fmt.Fprintf(w, "Platform:\t%s\n", info.Platform)
+		for _, tool := range info.Tools {
+			version := tool.Version
+			if !tool.Available {
+				version = "not found"
+			}
+			fmt.Fprintf(w, "%s:\t%s\n", tool.Name, version)
+		}
+		w.Flush()
 	},
 }
+
+// getToolVersion returns the version of the external tool, if available.
+func getToolVersion(name string, args ...string) toolVersion {
+	result := toolVersion{Name: name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return result
+	}
+	result.Available = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		result.Version = "unknown"
+		return result
+	}
+
+	// Keep the first line without prefixes like "Client Version:" or "Version:".
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0])
+	if i := strings.LastIndex(line, ":"); i >= 0 {
+		line = strings.TrimSpace(line[i+1:])
+	}
+	result.Version = line
+	return result
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/notify"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+// Supported values of the watch --workflow flag.
+const (
+	watchHealth = "health"
+	watchAudit  = "audit"
+)
+
+var (
+	watchNamespace string
+	watchInterval  time.Duration
+	watchWorkflow  string
+)
+
+func init() {
+	watchCmd.PersistentFlags().StringVarP(&watchNamespace, "namespace", "n", "default", "Namespace to watch")
+	watchCmd.PersistentFlags().DurationVarP(&watchInterval, "interval", "i", 10*time.Minute, "Interval between two runs")
+	watchCmd.PersistentFlags().StringVarP(&watchWorkflow, "workflow", "w", watchHealth, "Workflow to run: health (diagnose unhealthy Pods) or audit (audit all Pods)")
+	addNotifyFlag(watchCmd)
+	watchCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	watchCmd.RegisterFlagCompletionFunc("workflow", cobra.FixedCompletions([]string{watchHealth, watchAudit}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// watchFinding is a finding of a Pod reported by watch.
+type watchFinding struct {
+	Pod string `json:"pod"`
+	workflows.Finding
+}
+
+// watchResult is the result of one watch run, printed for json and yaml output.
+type watchResult struct {
+	Time     time.Time      `json:"time"`
+	New      []watchFinding `json:"new,omitempty"`
+	Resolved []watchFinding `json:"resolved,omitempty"`
+	Errors   []string       `json:"errors,omitempty"`
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Continuously monitor a namespace and report new findings",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchWorkflow != watchHealth && watchWorkflow != watchAudit {
+			return fmt.Errorf("unsupported workflow %q, should be health or audit", watchWorkflow)
+		}
+		if watchInterval <= 0 {
+			return fmt.Errorf("interval should be positive")
+		}
+
+		// Watch runs unattended, so never change the cluster.
+		tools.ReadOnly = true
+		if _, err := parseNotifyTargets(context.Background()); err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		previous := map[string]watchFinding{}
+		for i := 0; ; i++ {
+			if i > 0 {
+				// Pick up configuration changes (e.g. model or provider) without restarting.
+				if err := loadConfig(cmd); err != nil {
+					color.Yellow("Unable to reload configuration, keeping the previous one: %v", err)
+				}
+				tools.ReadOnly = true
+			}
+
+			printStatus("%s\n", color.BlueString("[%s] Running %s workflow in namespace %s", time.Now().Format(time.RFC3339), watchWorkflow, watchNamespace))
+			current, failed, errs := runWatchWorkflow()
+			if current == nil {
+				// Nothing was checked: keep the findings until the next run.
+				current = previous
+			} else {
+				keepFailedFindings(previous, current, failed)
+			}
+			result := diffWatchFindings(previous, current)
+			result.Errors = errs
+			previous = current
+
+			if len(result.New) > 0 || len(result.Resolved) > 0 || len(result.Errors) > 0 {
+				printResult(result, formatWatchResult(result))
+				notifyWatchResult(result)
+			} else {
+				printStatus("No new findings\n")
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(watchInterval):
+			}
+		}
+	},
+}
+
+// runWatchWorkflow runs the selected workflow and returns the findings keyed
+// by Pod and title, and the Pods which failed to be checked. The findings are
+// nil if the workflow failed altogether.
+func runWatchWorkflow() (map[string]watchFinding, map[string]bool, []string) {
+	var results []workflows.AuditResult
+	switch watchWorkflow {
+	case watchAudit:
+		var err error
+		if results, err = workflows.BatchAuditFlow(model, watchNamespace, verbose); err != nil {
+			return nil, nil, []string{err.Error()}
+		}
+	default:
+		pods, err := kubernetes.ListUnhealthyPods(watchNamespace)
+		if err != nil {
+			return nil, nil, []string{err.Error()}
+		}
+		for _, pod := range pods {
+			result := workflows.AuditResult{Namespace: watchNamespace, Name: pod}
+			report, err := workflows.DiagnoseFlow(model, watchNamespace, pod, verbose, maxIterations)
+			if err != nil {
+				result.Error, result.ErrorCode = err.Error(), errdefs.Code(err)
+			} else {
+				result.Report = report
+			}
+			results = append(results, result)
+		}
+	}
+
+	command := "diagnose"
+	if watchWorkflow == watchAudit {
+		command = "audit"
+	}
+	findings := map[string]watchFinding{}
+	failed := map[string]bool{}
+	var errs []string
+	for _, result := range results {
+		pod := result.Namespace + "/" + result.Name
+		if result.Error != "" {
+			failed[pod] = true
+			errs = append(errs, fmt.Sprintf("%s: %s", pod, result.Error))
+			continue
+		}
+		for _, finding := range workflows.ExtractFindings(command, "pod/"+pod, result.Report) {
+			key := pod + "/" + strings.ToLower(strings.TrimSpace(finding.Title))
+			findings[key] = watchFinding{Pod: pod, Finding: finding}
+		}
+	}
+	return findings, failed, errs
+}
+
+// keepFailedFindings copies the previous findings of the failed Pods into
+// current, so that they are not reported as resolved.
+func keepFailedFindings(previous, current map[string]watchFinding, failed map[string]bool) {
+	for key, finding := range previous {
+		if failed[finding.Pod] {
+			current[key] = finding
+		}
+	}
+}
+
+// diffWatchFindings returns the findings added and resolved since the previous run.
+func diffWatchFindings(previous, current map[string]watchFinding) *watchResult {
+	result := &watchResult{Time: time.Now()}
+	for key, finding := range current {
+		if _, ok := previous[key]; !ok {
+			result.New = append(result.New, finding)
+		}
+	}
+	for key, finding := range previous {
+		if _, ok := current[key]; !ok {
+			result.Resolved = append(result.Resolved, finding)
+		}
+	}
+	sortWatchFindings(result.New)
+	sortWatchFindings(result.Resolved)
+	return result
+}
+
+func sortWatchFindings(findings []watchFinding) {
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Pod != findings[j].Pod {
+			return findings[i].Pod < findings[j].Pod
+		}
+		return findings[i].Title < findings[j].Title
+	})
+}
+
+// notifyWatchResult sends the new and resolved findings to the notification targets.
+func notifyWatchResult(result *watchResult) {
+	if len(result.New) == 0 && len(result.Resolved) == 0 {
+		return
+	}
+	findings := make([]workflows.Finding, 0, len(result.New))
+	for _, f := range result.New {
+		findings = append(findings, f.Finding)
+	}
+	sendNotification(notify.Message{
+		Title:    fmt.Sprintf("kube-copilot watch: %d new and %d resolved finding(s) in namespace %s", len(result.New), len(result.Resolved), watchNamespace),
+		Text:     formatWatchResult(result),
+		Severity: highestSeverity(findings),
+	})
+}
+
+// formatWatchResult renders the watch result as markdown.
+func formatWatchResult(result *watchResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Findings at %s\n\n", result.Time.Format(time.RFC3339))
+	if len(result.New) > 0 {
+		sb.WriteString("## New\n\n")
+		for _, f := range result.New {
+			fmt.Fprintf(&sb, "### %s: %s\n\n", f.Pod, f.Title)
+			if f.Severity != "" {
+				fmt.Fprintf(&sb, "- **Severity**: %s\n", f.Severity)
+			}
+			fmt.Fprintf(&sb, "- **Findings**: %s\n- **How to resolve**: %s\n\n", f.Description, f.Remediation)
+		}
+	}
+	if len(result.Resolved) > 0 {
+		sb.WriteString("## Resolved\n\n")
+		for _, f := range result.Resolved {
+			fmt.Fprintf(&sb, "- %s: %s\n", f.Pod, f.Title)
+		}
+		sb.WriteString("\n")
+	}
+	if len(result.Errors) > 0 {
+		sb.WriteString("## Errors\n\n")
+		for _, err := range result.Errors {
+			fmt.Fprintf(&sb, "- %s\n", err)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/webhook"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookAddr            string
+	webhookCertFile        string
+	webhookKeyFile         string
+	webhookDenySeverity    string
+	webhookWarnSeverity    string
+	webhookLLM             bool
+	webhookAnalysisTimeout time.Duration
+)
+
+func init() {
+	webhookCmd.PersistentFlags().StringVarP(&webhookAddr, "addr", "", ":8443", "Address to listen on")
+	webhookCmd.PersistentFlags().StringVarP(&webhookCertFile, "tls-cert-file", "", "", "TLS certificate file (serves plain HTTP if empty, for local testing only)")
+	webhookCmd.PersistentFlags().StringVarP(&webhookKeyFile, "tls-private-key-file", "", "", "TLS private key file")
+	webhookCmd.PersistentFlags().StringVarP(&webhookDenySeverity, "deny-severity", "", "", "Deny requests with findings at or above this severity: CRITICAL, HIGH, MEDIUM or LOW (never deny if empty)")
+	webhookCmd.PersistentFlags().StringVarP(&webhookWarnSeverity, "warn-severity", "", "LOW", "Report findings at or above this severity as warnings and audit annotations")
+	webhookCmd.PersistentFlags().BoolVarP(&webhookLLM, "llm", "", true, "Review the manifests with the LLM in addition to the built-in checks")
+	webhookCmd.PersistentFlags().DurationVarP(&webhookAnalysisTimeout, "analysis-timeout", "", 8*time.Second, "Timeout of the LLM analysis (keep it below the webhook timeoutSeconds)")
+	webhookCmd.RegisterFlagCompletionFunc("deny-severity", cobra.FixedCompletions([]string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}, cobra.ShellCompDirectiveNoFileComp))
+	webhookCmd.RegisterFlagCompletionFunc("warn-severity", cobra.FixedCompletions([]string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Run a validating admission webhook reviewing the manifests",
+	Long: `Run a validating admission webhook that reviews the manifests on CREATE and
+UPDATE with fast built-in checks (privileged containers, host namespaces,
+mutable image tags, missing memory limits) followed by the LLM analysis.
+
+Findings at or above --warn-severity are returned as warnings and recorded in
+the "kube-copilot.io/findings" audit annotation; requests with findings at or
+above --deny-severity are denied. The resources to review are selected by the
+rules of the ValidatingWebhookConfiguration (see deploy/webhook.yaml).`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for flag, severity := range map[string]string{"--deny-severity": webhookDenySeverity, "--warn-severity": webhookWarnSeverity} {
+			if severity != "" && !workflows.ValidSeverity(severity) {
+				return fmt.Errorf("unsupported severity %q for %s, should be one of CRITICAL, HIGH, MEDIUM or LOW", severity, flag)
+			}
+		}
+		if (webhookCertFile == "") != (webhookKeyFile == "") {
+			return fmt.Errorf("--tls-cert-file and --tls-private-key-file should be set together")
+		}
+		// The LLM only inspects the cluster while reviewing the manifests.
+		tools.ReadOnly = true
+
+		handler := &webhook.Handler{
+			AnalysisTimeout: webhookAnalysisTimeout,
+			DenySeverity:    webhookDenySeverity,
+			WarnSeverity:    webhookWarnSeverity,
+		}
+		if webhookLLM {
+			// Fail fast on a missing API key instead of failing every review.
+			if _, err := workflows.NewSwarm(); err != nil {
+				return err
+			}
+			handler.Analyzer = analyzeManifest
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/validate", handler)
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+		server := &http.Server{Addr: webhookAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Fprintf(os.Stderr, "kube-copilot webhook listening on %s (llm: %v, deny severity: %q)\n", webhookAddr, webhookLLM, webhookDenySeverity)
+		var err error
+		if webhookCertFile != "" {
+			err = server.ListenAndServeTLS(webhookCertFile, webhookKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	},
+}
+
+// analyzeManifest reviews a manifest with the analysis workflow. The
+// manifests come from the admission requests, so the analysis never reads
+// the cluster on their behalf.
+func analyzeManifest(ctx context.Context, manifest string) ([]workflows.Finding, error) {
+	report, err := workflows.ManifestAnalysisFlow(ctx, model, manifest, verbose)
+	if err != nil {
+		return nil, err
+	}
+	return workflows.ExtractFindings("analyze", "", report), nil
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package apiaudit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Audit stages of the API server.
+const (
+	StageResponseComplete = "ResponseComplete"
+	StagePanic            = "Panic"
+)
+
+// maxLineSize is the maximum size of an event line (request and response objects may be large).
+const maxLineSize = 16 * 1024 * 1024
+
+// MutatingVerbs are the verbs of the requests changing the cluster.
+var MutatingVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// Event is the subset of an audit.k8s.io/v1 Event used for the analysis.
+type Event struct {
+	AuditID                  string            `json:"auditID"`
+	Stage                    string            `json:"stage"`
+	RequestURI               string            `json:"requestURI,omitempty"`
+	Verb                     string            `json:"verb"`
+	User                     UserInfo          `json:"user"`
+	ImpersonatedUser         *UserInfo         `json:"impersonatedUser,omitempty"`
+	SourceIPs                []string          `json:"sourceIPs,omitempty"`
+	UserAgent                string            `json:"userAgent,omitempty"`
+	ObjectRef                *ObjectReference  `json:"objectRef,omitempty"`
+	ResponseStatus           *Status           `json:"responseStatus,omitempty"`
+	RequestReceivedTimestamp time.Time         `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time         `json:"stageTimestamp"`
+	Annotations              map[string]string `json:"annotations,omitempty"`
+}
+
+// UserInfo is the user of an audit event.
+type UserInfo struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// ObjectReference is the object of an audit event.
+type ObjectReference struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// Status is the response status of an audit event.
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// eventList is the audit.k8s.io/v1 EventList sent by the webhook backend.
+type eventList struct {
+	Kind  string  `json:"kind"`
+	Items []Event `json:"items"`
+}
+
+// DefaultPath returns the path of the events received from the webhook backend.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "apiserver-audit.jsonl"), nil
+}
+
+// Decode reads the events of a log backend file (one Event per line) or of
+// webhook backend payloads (one EventList per line).
+func Decode(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		var list eventList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("invalid audit event at line %d: %v", line, err)
+		}
+		if list.Kind == "EventList" {
+			events = append(events, list.Items...)
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("invalid audit event at line %d: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReadFiles reads the events of the audit log files.
+func ReadFiles(paths ...string) ([]Event, error) {
+	var events []Event
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		events = append(events, decoded...)
+	}
+	return events, nil
+}
+
+// Filter selects the audit events of a query. Empty fields match any event.
+type Filter struct {
+	// Verbs are the request verbs. Defaults to MutatingVerbs; "*" matches all verbs.
+	Verbs []string
+	// Resource is the resource (e.g. deployments), in singular or plural form.
+	Resource  string
+	Namespace string
+	// Name is a substring of the object name.
+	Name string
+	// User is a substring of the username.
+	User  string
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of events returned, the most recent first.
+	Limit int
+}
+
+// Query returns the completed events matching the filter, the most recent first.
+func Query(events []Event, filter Filter) []Event {
+	verbs := filter.Verbs
+	if len(verbs) == 0 {
+		verbs = MutatingVerbs
+	}
+	allVerbs := false
+	for _, verb := range verbs {
+		allVerbs = allVerbs || verb == "*"
+	}
+
+	var matches []Event
+	for _, event := range events {
+		// Requests are logged at several stages; only keep the final one.
+		if event.Stage != "" && event.Stage != StageResponseComplete && event.Stage != StagePanic {
+			continue
+		}
+		if !allVerbs && !containsFold(verbs, event.Verb) {
+			continue
+		}
+		if !filter.Since.IsZero() && event.time().Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && event.time().After(filter.Until) {
+			continue
+		}
+		if filter.User != "" && !strings.Contains(strings.ToLower(event.User.Username), strings.ToLower(filter.User)) {
+			continue
+		}
+
+		ref := event.ObjectRef
+		if ref == nil {
+			ref = &ObjectReference{}
+		}
+		if filter.Resource != "" && !sameResource(ref.Resource, filter.Resource) {
+			continue
+		}
+		if filter.Namespace != "" && ref.Namespace != filter.Namespace {
+			continue
+		}
+		if filter.Name != "" && !strings.Contains(ref.Name, filter.Name) {
+			continue
+		}
+		matches = append(matches, event)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].time().After(matches[j].time()) })
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[:filter.Limit]
+	}
+	return matches
+}
+
+// Format renders the events as a table.
+func Format(events []Event) string {
+	if len(events) == 0 {
+		return "No matching audit events."
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tVERB\tRESOURCE\tNAMESPACE\tNAME\tCODE\tSOURCE")
+	for _, event := range events {
+		ref := event.ObjectRef
+		if ref == nil {
+			ref = &ObjectReference{}
+		}
+		resource := ref.Resource
+		if ref.Subresource != "" {
+			resource += "/" + ref.Subresource
+		}
+		user := event.User.Username
+		if event.ImpersonatedUser != nil {
+			user += " (as " + event.ImpersonatedUser.Username + ")"
+		}
+		code := ""
+		if event.ResponseStatus != nil {
+			code = fmt.Sprint(event.ResponseStatus.Code)
+		}
+		source := strings.Join(event.SourceIPs, ",")
+		if event.UserAgent != "" {
+			source += " " + event.UserAgent
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", event.time().UTC().Format(time.RFC3339), user, event.Verb,
+			orDash(resource), orDash(ref.Namespace), orDash(ref.Name), orDash(code), strings.TrimSpace(source))
+	}
+	w.Flush()
+	return strings.TrimSpace(sb.String())
+}
+
+// Receiver is an http.Handler for the webhook backend of the API server.
+// It appends the received events to the file at Path, one Event per line.
+type Receiver struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var list eventList
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxLineSize)).Decode(&list); err != nil {
+		http.Error(w, fmt.Sprintf("invalid EventList: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := r.Append(list.Items); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Append writes the events to the file at Path.
+func (r *Receiver) Append(events []Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		writer.Write(append(data, '\n'))
+	}
+	return writer.Flush()
+}
+
+// time returns the time of the event.
+func (e Event) time() time.Time {
+	if !e.RequestReceivedTimestamp.IsZero() {
+		return e.RequestReceivedTimestamp
+	}
+	return e.StageTimestamp
+}
+
+// sameResource returns true if the resources are the same, ignoring case
+// and singular or plural forms (e.g. deployment, ingress or networkpolicy).
+func sameResource(a, b string) bool {
+	return singular(strings.ToLower(a)) == singular(strings.ToLower(b))
+}
+
+// singular returns the singular form of a resource name.
+func singular(resource string) string {
+	switch {
+	case strings.HasSuffix(resource, "ies"):
+		return strings.TrimSuffix(resource, "ies") + "y"
+	case strings.HasSuffix(resource, "sses"), strings.HasSuffix(resource, "xes"):
+		return resource[:len(resource)-2]
+	case strings.HasSuffix(resource, "s") && !strings.HasSuffix(resource, "ss"):
+		return strings.TrimSuffix(resource, "s")
+	}
+	return resource
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
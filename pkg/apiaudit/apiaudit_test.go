@@ -0,0 +1,124 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package apiaudit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const auditLog = `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"1","stage":"RequestReceived","verb":"delete","user":{"username":"alice"},"objectRef":{"resource":"deployments","namespace":"web","name":"nginx","apiGroup":"apps"},"requestReceivedTimestamp":"2025-03-01T08:00:00Z"}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"1","stage":"ResponseComplete","verb":"delete","user":{"username":"alice"},"sourceIPs":["10.0.0.1"],"userAgent":"kubectl/v1.32.0","objectRef":{"resource":"deployments","namespace":"web","name":"nginx","apiGroup":"apps"},"responseStatus":{"code":200},"requestReceivedTimestamp":"2025-03-01T08:00:00Z"}
+
+{"kind":"EventList","apiVersion":"audit.k8s.io/v1","items":[{"auditID":"2","stage":"ResponseComplete","verb":"get","user":{"username":"system:serviceaccount:web:reader"},"objectRef":{"resource":"secrets","namespace":"web","name":"db"},"responseStatus":{"code":200},"requestReceivedTimestamp":"2025-03-01T08:30:00Z"},{"auditID":"3","stage":"ResponseComplete","verb":"patch","user":{"username":"bob"},"objectRef":{"resource":"networkpolicies","namespace":"kube-system","name":"deny-all"},"responseStatus":{"code":403},"requestReceivedTimestamp":"2025-03-01T09:00:00Z"}]}
+`
+
+func TestDecode(t *testing.T) {
+	events, err := Decode(strings.NewReader(auditLog))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Decode() returned %d events, want 4", len(events))
+	}
+	if events[2].User.Username != "system:serviceaccount:web:reader" || events[3].ObjectRef.Name != "deny-all" {
+		t.Errorf("Decode() did not read the EventList items: %+v", events[2:])
+	}
+
+	if _, err := Decode(strings.NewReader("not json\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Decode() error = %v, want the invalid line", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	events, err := Decode(strings.NewReader(auditLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "mutating by default", filter: Filter{}, want: []string{"3", "1"}},
+		{name: "all verbs", filter: Filter{Verbs: []string{"*"}}, want: []string{"3", "2", "1"}},
+		{name: "read accesses", filter: Filter{Verbs: []string{"get", "list"}, Resource: "secret"}, want: []string{"2"}},
+		{name: "singular resource", filter: Filter{Resource: "deployment", Name: "ngi"}, want: []string{"1"}},
+		{name: "plural policies", filter: Filter{Resource: "NetworkPolicy"}, want: []string{"3"}},
+		{name: "namespace", filter: Filter{Verbs: []string{"*"}, Namespace: "web"}, want: []string{"2", "1"}},
+		{name: "user", filter: Filter{User: "ALICE"}, want: []string{"1"}},
+		{name: "time range", filter: Filter{Verbs: []string{"*"}, Since: time.Date(2025, 3, 1, 8, 15, 0, 0, time.UTC), Until: time.Date(2025, 3, 1, 8, 45, 0, 0, time.UTC)}, want: []string{"2"}},
+		{name: "limit", filter: Filter{Verbs: []string{"*"}, Limit: 1}, want: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, event := range Query(events, tt.filter) {
+				got = append(got, event.AuditID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format(nil); got != "No matching audit events." {
+		t.Errorf("Format(nil) = %q", got)
+	}
+
+	events, _ := Decode(strings.NewReader(auditLog))
+	got := Format(Query(events, Filter{Name: "nginx"}))
+	for _, want := range []string{"2025-03-01T08:00:00Z", "alice", "delete", "deployments", "web", "nginx", "200", "10.0.0.1 kubectl/v1.32.0"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Format() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	receiver := &Receiver{Path: path}
+	lines := strings.Split(strings.TrimSpace(auditLog), "\n")
+
+	for _, body := range []string{lines[len(lines)-1], lines[len(lines)-1]} {
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ServeHTTP() status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP() status = %d for an invalid body, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	events, err := ReadFiles(path)
+	if err != nil {
+		t.Fatalf("ReadFiles() error = %v", err)
+	}
+	if len(events) != 4 || events[0].AuditID != "2" || events[1].ResponseStatus.Code != 403 {
+		t.Errorf("ReadFiles() = %+v, want the received events", events)
+	}
+}
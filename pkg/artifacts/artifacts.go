@@ -0,0 +1,206 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package artifacts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+)
+
+// HandlePrefix is the prefix of the handles referencing the artifacts in the observations.
+const HandlePrefix = "artifact://"
+
+// Limits of the excerpt of an artifact kept in the observation.
+const (
+	maxExcerptLines = 40
+	maxExcerptSize  = 4000
+)
+
+// ErrNotFound is returned when opening an artifact that does not exist.
+var ErrNotFound = errors.New("not found")
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Artifact is a large tool output saved in the scratch directory of a run.
+type Artifact struct {
+	// Handle references the artifact in the observations, e.g. artifact://<run>/<name>.
+	Handle string `json:"handle"`
+	Run    string `json:"run"`
+	Name   string `json:"name"`
+	Tool   string `json:"tool,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// Store keeps the artifacts of the runs in one directory per run.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns the default artifacts directory (~/.kube-copilot/artifacts).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "artifacts"), nil
+}
+
+// NewStore creates a Store in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Run is the scratch directory of an agent run. It is only created when the
+// first artifact is saved.
+type Run struct {
+	ID    string
+	store *Store
+
+	lock      sync.Mutex
+	artifacts []Artifact
+}
+
+// NewRun returns the scratch directory of a new run.
+func (s *Store) NewRun() *Run {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	// Concurrent runs (e.g. of the operator) get distinct IDs.
+	id := strings.ReplaceAll(time.Now().Format("20060102-150405.000"), ".", "-") + "-" + hex.EncodeToString(suffix)
+	return &Run{ID: id, store: s}
+}
+
+// Save writes the output of the tool as a new artifact of the run.
+// Credentials in the output are redacted.
+func (r *Run) Save(tool, content string) (Artifact, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ext := ".txt"
+	if json.Valid([]byte(content)) {
+		ext = ".json"
+	}
+	name := fmt.Sprintf("%03d-%s%s", len(r.artifacts)+1, invalidNameChars.ReplaceAllString(tool, "_"), ext)
+	dir := filepath.Join(r.store.Dir, r.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Artifact{}, err
+	}
+	content = utils.Redact(content)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+		return Artifact{}, err
+	}
+
+	artifact := Artifact{Handle: Handle(r.ID, name), Run: r.ID, Name: name, Tool: tool, Size: int64(len(content))}
+	r.artifacts = append(r.artifacts, artifact)
+	return artifact, nil
+}
+
+// Artifacts returns the artifacts saved so far.
+func (r *Run) Artifacts() []Artifact {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Artifact(nil), r.artifacts...)
+}
+
+// List returns the artifacts of the run, in the order they were saved.
+func (s *Store) List(run string) ([]Artifact, error) {
+	if !validName(run) {
+		return nil, fmt.Errorf("invalid run ID %q", run)
+	}
+	files, err := os.ReadDir(filepath.Join(s.Dir, run))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("artifacts of run %s %w", run, ErrNotFound)
+		}
+		return nil, err
+	}
+
+	artifacts := make([]Artifact, 0, len(files))
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		artifacts = append(artifacts, Artifact{Handle: Handle(run, file.Name()), Run: run, Name: file.Name(), Size: info.Size()})
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
+
+// Open opens the artifact of the run for reading.
+func (s *Store) Open(run, name string) (*os.File, error) {
+	if !validName(run) || !validName(name) {
+		return nil, fmt.Errorf("invalid artifact %s/%s", run, name)
+	}
+	f, err := os.Open(filepath.Join(s.Dir, run, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("artifact %s/%s %w", run, name, ErrNotFound)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Handle returns the handle of the artifact of the run.
+func Handle(run, name string) string {
+	return HandlePrefix + run + "/" + name
+}
+
+// ParseHandle returns the run and the name of the artifact referenced by the handle.
+func ParseHandle(handle string) (run, name string, err error) {
+	run, name, ok := strings.Cut(strings.TrimPrefix(handle, HandlePrefix), "/")
+	if !ok || !validName(run) || !validName(name) {
+		return "", "", fmt.Errorf("invalid artifact handle %q, should be %s<run>/<name>", handle, HandlePrefix)
+	}
+	return run, name, nil
+}
+
+// Excerpt returns the beginning of the content (the artifact or its compacted
+// form), followed by a note referencing the full artifact with its handle.
+func Excerpt(content string, artifact Artifact) string {
+	lines := strings.SplitAfter(content, "\n")
+	var sb strings.Builder
+	for i, line := range lines {
+		if i >= maxExcerptLines || sb.Len()+len(line) > maxExcerptSize {
+			break
+		}
+		sb.WriteString(line)
+	}
+	excerpt := strings.TrimRight(sb.String(), "\n")
+	if excerpt == "" && len(content) > maxExcerptSize {
+		// A single long line, e.g. compact JSON.
+		excerpt = content[:maxExcerptSize]
+	}
+	return fmt.Sprintf("%s\n...\n(Output truncated: the full output of %d bytes is saved as %s. Refine the command, e.g. with a label selector, jsonpath or grep, to inspect the other parts.)",
+		excerpt, artifact.Size, artifact.Handle)
+}
+
+// validName returns true if the run ID or artifact name is a single path element.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package artifacts
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	store := NewStore(t.TempDir())
+	run := store.NewRun()
+	if _, err := os.Stat(filepath.Join(store.Dir, run.ID)); !os.IsNotExist(err) {
+		t.Errorf("the run directory should only be created with the first artifact")
+	}
+
+	yamlArtifact, err := run.Save("kubectl", "kind: Secret\ndata:\n  password: czNjcjN0\n")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	jsonArtifact, err := run.Save("trivy", `{"Results": []}`)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if yamlArtifact.Name != "001-kubectl.txt" || jsonArtifact.Name != "002-trivy.json" {
+		t.Errorf("names = %q, %q, want numbered names with the extension of the content", yamlArtifact.Name, jsonArtifact.Name)
+	}
+	if len(run.Artifacts()) != 2 {
+		t.Errorf("Artifacts() = %v, want the two artifacts", run.Artifacts())
+	}
+
+	list, err := store.List(run.ID)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Handle != yamlArtifact.Handle || list[1].Size != jsonArtifact.Size {
+		t.Errorf("List() = %+v, want the saved artifacts", list)
+	}
+
+	gotRun, gotName, err := ParseHandle(yamlArtifact.Handle)
+	if err != nil || gotRun != run.ID || gotName != yamlArtifact.Name {
+		t.Fatalf("ParseHandle(%q) = %q, %q, %v", yamlArtifact.Handle, gotRun, gotName, err)
+	}
+	f, err := store.Open(gotRun, gotName)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+	if strings.Contains(string(data), "czNjcjN0") {
+		t.Errorf("artifact = %q, want the Secret data redacted", data)
+	}
+
+	if _, err := store.Open(run.ID, "003-kubectl.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() of a missing artifact error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Open(run.ID, "../../etc/passwd"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() should reject the paths outside of the run")
+	}
+	if _, err := store.List("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("List() of a missing run error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := ParseHandle("artifact://run-only"); err == nil {
+		t.Errorf("ParseHandle() should reject a handle without name")
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	artifact := Artifact{Handle: "artifact://run/001-kubectl.txt", Size: 123456}
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, "pod line")
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		wantLines int
+	}{
+		{name: "many lines", content: strings.Join(lines, "\n"), wantLines: maxExcerptLines},
+		{name: "single long line", content: strings.Repeat("x", 3*maxExcerptSize), wantLines: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Excerpt(tt.content, artifact)
+			excerpt, note, ok := strings.Cut(got, "\n...\n")
+			if !ok || !strings.Contains(note, "123456 bytes") || !strings.Contains(note, artifact.Handle) {
+				t.Fatalf("Excerpt() = %q, want a note referencing the artifact", got)
+			}
+			if n := len(strings.Split(excerpt, "\n")); n != tt.wantLines || len(excerpt) > maxExcerptSize {
+				t.Errorf("excerpt has %d lines and %d bytes, want %d lines within %d bytes", n, len(excerpt), tt.wantLines, maxExcerptSize)
+			}
+		})
+	}
+}
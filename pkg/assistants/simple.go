@@ -52,7 +52,7 @@ func Assistant(model string, prompts []openai.ChatCompletionMessage, maxTokens i
 
 	client, err := llms.NewOpenAIClient()
 	if err != nil {
-		return "", nil, fmt.Errorf("unable to get OpenAI client: %v", err)
+		return "", nil, fmt.Errorf("unable to get OpenAI client: %w", err)
 	}
 
 	defer func() {
@@ -68,7 +68,7 @@ func Assistant(model string, prompts []openai.ChatCompletionMessage, maxTokens i
 
 	resp, err := client.Chat(model, maxTokens, chatHistory)
 	if err != nil {
-		return "", chatHistory, fmt.Errorf("chat completion error: %v", err)
+		return "", chatHistory, fmt.Errorf("chat completion error: %w", err)
 	}
 
 	chatHistory = append(chatHistory, openai.ChatCompletionMessage{
@@ -150,7 +150,7 @@ func Assistant(model string, prompts []openai.ChatCompletionMessage, maxTokens i
 
 			resp, err := client.Chat(model, maxTokens, chatHistory)
 			if err != nil {
-				return "", chatHistory, fmt.Errorf("chat completion error: %v", err)
+				return "", chatHistory, fmt.Errorf("chat completion error: %w", err)
 			}
 
 			chatHistory = append(chatHistory, openai.ChatCompletionMessage{
@@ -174,7 +174,7 @@ func Assistant(model string, prompts []openai.ChatCompletionMessage, maxTokens i
 
 				resp, err = client.Chat(model, maxTokens, chatHistory)
 				if err != nil {
-					return "", chatHistory, fmt.Errorf("chat completion error: %v", err)
+					return "", chatHistory, fmt.Errorf("chat completion error: %w", err)
 				}
 
 				return resp, chatHistory, nil
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auditlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+)
+
+// SchemaVersion is the version of the Event schema. It is bumped on incompatible changes.
+const SchemaVersion = 1
+
+// Event types.
+const (
+	// EventToolExecution is a tool (e.g. kubectl or python) executed on behalf of the agent.
+	EventToolExecution = "tool_execution"
+	// EventApproval is the user's decision on a command that may change the cluster.
+	EventApproval = "approval"
+	// EventApply is an application of manifests to the cluster.
+	EventApply = "apply"
+	// EventConfigChange is a change of the configuration file.
+	EventConfigChange = "config_change"
+	// EventPolicyDecision is the decision of the action policy on a tool execution.
+	EventPolicyDecision = "policy_decision"
+	// EventSnapshot is the snapshot of an object taken before applying a change to it.
+	EventSnapshot = "snapshot"
+	// EventHealthCheck is the outcome of the health watch after applying a change.
+	EventHealthCheck = "health_check"
+	// EventRollback is the restoration of an object to its snapshot.
+	EventRollback = "rollback"
+)
+
+// Event statuses and decisions.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	DecisionApproved = "approved"
+	DecisionEdited   = "edited"
+	DecisionDeclined = "declined"
+	DecisionAllowed  = "allowed"
+	DecisionDenied   = "denied"
+)
+
+// Event is a security-relevant event, written as one JSON line to the audit log.
+type Event struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	User    string    `json:"user,omitempty"`
+	// Command is the kube-copilot command that produced the event (e.g. execute).
+	Command string `json:"command,omitempty"`
+	Context string `json:"context,omitempty"`
+
+	Tool     string `json:"tool,omitempty"`
+	Input    string `json:"input,omitempty"`
+	Decision string `json:"decision,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+var (
+	// Command is the kube-copilot command recorded in every event.
+	Command string
+	// Context is the kubeconfig context recorded in every event.
+	Context string
+
+	mu     sync.Mutex
+	path   string
+	writer io.Writer
+	closer io.Closer
+)
+
+// DefaultPath returns the default path of the audit log.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "audit.log"), nil
+}
+
+// Open directs the audit log to the file at p, or to stderr if p is "-".
+// Reopening the current path is a no-op. Events are discarded until Open is called.
+func Open(p string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if p == path && writer != nil {
+		return nil
+	}
+
+	var w io.Writer = os.Stderr
+	var c io.Closer
+	if p != "-" {
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		w, c = file, file
+	}
+
+	closeLocked()
+	path, writer, closer = p, w, c
+	return nil
+}
+
+// Close closes the audit log. Later events are discarded.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return closeLocked()
+}
+
+func closeLocked() error {
+	var err error
+	if closer != nil {
+		err = closer.Close()
+	}
+	path, writer, closer = "", nil, nil
+	return err
+}
+
+// Record writes the event to the audit log, filling in the version, time, user, command and context.
+// Credentials in the input, value and error are redacted.
+func Record(event Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return
+	}
+
+	event.Version = SchemaVersion
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.User == "" {
+		event.User = currentUser()
+	}
+	if event.Command == "" {
+		event.Command = Command
+	}
+	if event.Context == "" {
+		event.Context = Context
+	}
+	event.Input = utils.Redact(event.Input)
+	event.Value = utils.Redact(event.Value)
+	event.Error = utils.Redact(event.Error)
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	writer.Write(append(data, '\n'))
+}
+
+// currentUser returns the name of the user running the process.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
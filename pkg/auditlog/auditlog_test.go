@@ -0,0 +1,73 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	Record(Event{Type: EventToolExecution, Tool: "kubectl", Input: "get pods"})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := Open(path); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	Command = "execute"
+	Record(Event{Type: EventApproval, Tool: "kubectl", Input: "delete pod nginx", Decision: DecisionDeclined})
+	Record(Event{Type: EventConfigChange, Key: "model", Value: "gpt-4o-mini", Command: "config set"})
+	if err := Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	Record(Event{Type: EventToolExecution, Tool: "kubectl", Input: "get nodes"})
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(events), events)
+	}
+	tests := []struct {
+		event       Event
+		wantType    string
+		wantCommand string
+	}{
+		{event: events[0], wantType: EventApproval, wantCommand: "execute"},
+		{event: events[1], wantType: EventConfigChange, wantCommand: "config set"},
+	}
+	for _, tt := range tests {
+		if tt.event.Version != SchemaVersion || tt.event.Time.IsZero() || tt.event.Type != tt.wantType || tt.event.Command != tt.wantCommand {
+			t.Errorf("unexpected event %+v, want type %s and command %s", tt.event, tt.wantType, tt.wantCommand)
+		}
+	}
+}
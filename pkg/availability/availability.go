@@ -0,0 +1,297 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package availability checks whether the workloads of a namespace survive
+// voluntary disruptions such as node drains and upgrades.
+package availability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Severities of the issues, matching the severities of the workflow findings.
+const (
+	SeverityHigh   = "HIGH"
+	SeverityMedium = "MEDIUM"
+	SeverityLow    = "LOW"
+)
+
+// Readiness verdicts of a namespace.
+const (
+	Ready    = "READY"
+	AtRisk   = "AT RISK"
+	NotReady = "NOT READY"
+)
+
+// zoneLabel is the well-known label of the Node zones.
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// Issue is an availability risk of a workload.
+type Issue struct {
+	// Workload is the kind and name of the workload, e.g. deployment/web.
+	Workload    string `json:"workload"`
+	Check       string `json:"check"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation"`
+}
+
+// workload is the part of a Deployment or StatefulSet relevant to the checks.
+type workload struct {
+	name     string
+	replicas int32
+	ready    int32
+	selector *metav1.LabelSelector
+	template corev1.PodTemplateSpec
+}
+
+// Check returns the availability issues of the workloads in the snapshot,
+// ordered by severity and workload.
+func Check(snapshot *kubernetes.Snapshot) []Issue {
+	var workloads []workload
+	for _, d := range snapshot.Deployments {
+		workloads = append(workloads, workload{
+			name:     "deployment/" + d.Name,
+			replicas: replicas(d.Spec.Replicas),
+			ready:    d.Status.ReadyReplicas,
+			selector: d.Spec.Selector,
+			template: d.Spec.Template,
+		})
+	}
+	for _, s := range snapshot.StatefulSets {
+		workloads = append(workloads, workload{
+			name:     "statefulset/" + s.Name,
+			replicas: replicas(s.Spec.Replicas),
+			ready:    s.Status.ReadyReplicas,
+			selector: s.Spec.Selector,
+			template: s.Spec.Template,
+		})
+	}
+
+	nodeZones := make(map[string]string, len(snapshot.Nodes))
+	zones := make(map[string]bool)
+	for _, node := range snapshot.Nodes {
+		if zone := node.Labels[zoneLabel]; zone != "" {
+			nodeZones[node.Name] = zone
+			zones[zone] = true
+		}
+	}
+
+	var issues []Issue
+	for _, w := range workloads {
+		issues = append(issues, checkWorkload(w, snapshot, nodeZones, len(zones))...)
+	}
+	for _, pod := range snapshot.Pods {
+		if len(pod.OwnerReferences) == 0 && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			issues = append(issues, Issue{
+				Workload:    "pod/" + pod.Name,
+				Check:       "unmanaged-pod",
+				Severity:    SeverityMedium,
+				Message:     "The Pod is not managed by a controller and is not recreated after an eviction or node failure.",
+				Remediation: "Run it as a Deployment or StatefulSet.",
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if ri, rj := severityRank(issues[i].Severity), severityRank(issues[j].Severity); ri != rj {
+			return ri > rj
+		}
+		return issues[i].Workload < issues[j].Workload
+	})
+	return issues
+}
+
+// checkWorkload returns the availability issues of a single workload.
+func checkWorkload(w workload, snapshot *kubernetes.Snapshot, nodeZones map[string]string, zoneCount int) []Issue {
+	if w.replicas == 0 {
+		return nil
+	}
+
+	var issues []Issue
+	add := func(check, severity, message, remediation string) {
+		issues = append(issues, Issue{Workload: w.name, Check: check, Severity: severity, Message: message, Remediation: remediation})
+	}
+
+	if w.ready < w.replicas {
+		add("unavailable-replicas", SeverityHigh,
+			fmt.Sprintf("Only %d of %d replicas are ready, so the workload has no headroom for disruptions.", w.ready, w.replicas),
+			"Fix the unready replicas before the maintenance.")
+	}
+	if w.replicas == 1 {
+		add("single-replica", SeverityHigh,
+			"The workload runs a single replica, which is a single point of failure: draining its Node causes downtime.",
+			"Scale it to at least 2 replicas and add a PodDisruptionBudget.")
+	}
+
+	podLabels := labels.Set(w.template.Labels)
+	var pdbs []policyv1.PodDisruptionBudget
+	for _, pdb := range snapshot.PDBs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err == nil && !selector.Empty() && selector.Matches(podLabels) {
+			pdbs = append(pdbs, pdb)
+		}
+	}
+	switch {
+	case len(pdbs) == 0 && w.replicas > 1:
+		add("missing-pdb", SeverityMedium,
+			"No PodDisruptionBudget covers the workload, so a drain may evict all its replicas at once.",
+			fmt.Sprintf("Add a PodDisruptionBudget with maxUnavailable: 1 selecting %s.", labels.FormatLabels(w.template.Labels)))
+	case len(pdbs) > 1:
+		add("overlapping-pdbs", SeverityMedium,
+			fmt.Sprintf("%d PodDisruptionBudgets (%s) cover the workload; evictions of its Pods fail until only one remains.", len(pdbs), pdbNames(pdbs)),
+			"Keep a single PodDisruptionBudget per workload.")
+	}
+	for _, pdb := range pdbs {
+		if blocksEviction(pdb, w.replicas) {
+			add("blocking-pdb", SeverityHigh,
+				fmt.Sprintf("PodDisruptionBudget %s allows no disruption of the %d replicas, so node drains hang.", pdb.Name, w.replicas),
+				"Scale the workload up or relax the budget (e.g. maxUnavailable: 1).")
+		}
+	}
+
+	if w.replicas > 1 && !spreadsReplicas(w.template.Spec) {
+		add("no-spread", SeverityMedium,
+			"Neither topology spread constraints nor pod anti-affinity spread the replicas, so they may land on the same Node or zone.",
+			"Add topologySpreadConstraints on kubernetes.io/hostname and topology.kubernetes.io/zone.")
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(w.selector)
+	if err == nil && w.replicas > 1 {
+		nodes := make(map[string]bool)
+		zones := make(map[string]bool)
+		for _, pod := range snapshot.Pods {
+			if pod.Spec.NodeName == "" || pod.Status.Phase != corev1.PodRunning || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			nodes[pod.Spec.NodeName] = true
+			if zone := nodeZones[pod.Spec.NodeName]; zone != "" {
+				zones[zone] = true
+			}
+		}
+		switch {
+		case len(nodes) == 1:
+			add("single-node", SeverityHigh,
+				fmt.Sprintf("All the running replicas are on Node %s, which is a single point of failure.", firstKey(nodes)),
+				"Spread the replicas across Nodes with topology spread constraints or pod anti-affinity.")
+		case len(zones) == 1 && zoneCount > 1:
+			add("single-zone", SeverityMedium,
+				fmt.Sprintf("All the running replicas are in zone %s although the cluster spans %d zones.", firstKey(zones), zoneCount),
+				"Add a topology spread constraint on topology.kubernetes.io/zone.")
+		}
+	}
+
+	for _, container := range w.template.Spec.Containers {
+		if container.ReadinessProbe == nil {
+			add("no-readiness-probe", SeverityLow,
+				fmt.Sprintf("Container %s has no readiness probe, so traffic reaches replicas before they are ready during rollouts and rescheduling.", container.Name),
+				"Add a readinessProbe to the container.")
+		}
+	}
+	return issues
+}
+
+// blocksEviction returns true if the PodDisruptionBudget allows no eviction of the workload replicas.
+func blocksEviction(pdb policyv1.PodDisruptionBudget, replicas int32) bool {
+	if pdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(replicas), true)
+		return err == nil && maxUnavailable <= 0
+	}
+	if pdb.Spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(replicas), true)
+		return err == nil && minAvailable >= int(replicas)
+	}
+	return false
+}
+
+// spreadsReplicas returns true if the Pod spec has topology spread constraints or pod anti-affinity.
+func spreadsReplicas(spec corev1.PodSpec) bool {
+	if len(spec.TopologySpreadConstraints) > 0 {
+		return true
+	}
+	antiAffinity := spec.Affinity != nil && spec.Affinity.PodAntiAffinity != nil
+	return antiAffinity && (len(spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) > 0 ||
+		len(spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0)
+}
+
+// Verdict returns NOT READY if any issue is HIGH, AT RISK if any is MEDIUM and READY otherwise.
+func Verdict(issues []Issue) string {
+	verdict := Ready
+	for _, issue := range issues {
+		switch issue.Severity {
+		case SeverityHigh:
+			return NotReady
+		case SeverityMedium:
+			verdict = AtRisk
+		}
+	}
+	return verdict
+}
+
+// Format renders the issues as a list, one issue per line.
+func Format(issues []Issue) string {
+	if len(issues) == 0 {
+		return "No availability issues found."
+	}
+
+	lines := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		lines = append(lines, fmt.Sprintf("- [%s] %s (%s): %s Fix: %s", issue.Severity, issue.Workload, issue.Check, issue.Message, issue.Remediation))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replicas returns the desired replicas, which default to 1.
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func pdbNames(pdbs []policyv1.PodDisruptionBudget) string {
+	names := make([]string, 0, len(pdbs))
+	for _, pdb := range pdbs {
+		names = append(names, pdb.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func firstKey(m map[string]bool) string {
+	for k := range m {
+		return k
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package availability
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func deployment(name string, replicas, ready int32, mutate func(*corev1.PodSpec)) appsv1.Deployment {
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "app", ReadinessProbe: &corev1.Probe{}}}}
+	if mutate != nil {
+		mutate(&spec)
+	}
+	labels := map[string]string{"app": name}
+	return appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}, Spec: spec},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func pdb(name, app string, minAvailable, maxUnavailable *intstr.IntOrString) policyv1.PodDisruptionBudget {
+	return policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
+
+func pod(name, app, node string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          map[string]string{"app": app},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: app}},
+		},
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func node(name, zone string) corev1.Node {
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{zoneLabel: zone}}}
+}
+
+func spread(spec *corev1.PodSpec) {
+	spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{TopologyKey: "kubernetes.io/hostname"}}
+}
+
+func TestCheck(t *testing.T) {
+	one, all, zero := intstr.FromInt32(1), intstr.FromString("100%"), intstr.FromInt32(0)
+	nodes := []corev1.Node{node("node-a", "zone-1"), node("node-b", "zone-1"), node("node-c", "zone-2")}
+
+	tests := []struct {
+		name     string
+		snapshot kubernetes.Snapshot
+		want     []string
+		verdict  string
+	}{
+		{
+			name: "highly available",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 2, 2, spread)},
+				PDBs:        []policyv1.PodDisruptionBudget{pdb("web", "web", nil, &one)},
+				Pods:        []corev1.Pod{pod("web-1", "web", "node-a"), pod("web-2", "web", "node-c")},
+				Nodes:       nodes,
+			},
+			verdict: Ready,
+		},
+		{
+			name: "single replica",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 1, 1, nil)},
+				Pods:        []corev1.Pod{pod("web-1", "web", "node-a")},
+			},
+			want:    []string{"deployment/web single-replica"},
+			verdict: NotReady,
+		},
+		{
+			name: "scaled down workloads are skipped",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 0, 0, nil)},
+			},
+			verdict: Ready,
+		},
+		{
+			name: "missing pdb, spread and readiness probe",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 3, 2, func(spec *corev1.PodSpec) {
+					spec.Containers[0].ReadinessProbe = nil
+				})},
+				Pods: []corev1.Pod{pod("web-1", "web", "node-a"), pod("web-2", "web", "node-b")},
+			},
+			want:    []string{"deployment/web unavailable-replicas", "deployment/web missing-pdb", "deployment/web no-spread", "deployment/web no-readiness-probe"},
+			verdict: NotReady,
+		},
+		{
+			name: "blocking and overlapping pdbs",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 2, 2, spread)},
+				PDBs:        []policyv1.PodDisruptionBudget{pdb("web-min", "web", &all, nil), pdb("web-max", "web", nil, &zero), pdb("api", "api", nil, &zero)},
+				Pods:        []corev1.Pod{pod("web-1", "web", "node-a"), pod("web-2", "web", "node-c")},
+			},
+			want:    []string{"deployment/web blocking-pdb", "deployment/web blocking-pdb", "deployment/web overlapping-pdbs"},
+			verdict: NotReady,
+		},
+		{
+			name: "single node and zone",
+			snapshot: kubernetes.Snapshot{
+				Deployments: []appsv1.Deployment{deployment("web", 2, 2, spread), deployment("api", 2, 2, spread)},
+				PDBs:        []policyv1.PodDisruptionBudget{pdb("web", "web", &one, nil), pdb("api", "api", &one, nil)},
+				Pods: []corev1.Pod{
+					pod("web-1", "web", "node-a"), pod("web-2", "web", "node-a"),
+					pod("api-1", "api", "node-a"), pod("api-2", "api", "node-b"),
+				},
+				Nodes: nodes,
+			},
+			want:    []string{"deployment/web single-node", "deployment/api single-zone"},
+			verdict: NotReady,
+		},
+		{
+			name: "unmanaged pod",
+			snapshot: kubernetes.Snapshot{
+				Pods: []corev1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Name: "debug"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "job"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+				},
+			},
+			want:    []string{"pod/debug unmanaged-pod"},
+			verdict: AtRisk,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Check(&tt.snapshot)
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.Workload+" "+issue.Check)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+			if verdict := Verdict(issues); verdict != tt.verdict {
+				t.Errorf("Verdict() = %s, want %s", verdict, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format(nil); got != "No availability issues found." {
+		t.Errorf("Format(nil) = %q", got)
+	}
+
+	got := Format([]Issue{{Workload: "deployment/web", Check: "single-replica", Severity: SeverityHigh, Message: "One replica.", Remediation: "Scale it."}})
+	if want := "- [HIGH] deployment/web (single-replica): One replica. Fix: Scale it."; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
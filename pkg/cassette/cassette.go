@@ -0,0 +1,243 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// version is the version of the cassette file format.
+const version = 1
+
+// LLMInteraction is a recorded chat completion.
+type LLMInteraction struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ToolInteraction is a recorded tool execution.
+type ToolInteraction struct {
+	Tool   string `json:"tool"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Cassette records the LLM and tool interactions of a run to a file, or
+// replays them so that the agent loop runs deterministically without an API
+// key or a cluster. Credentials are redacted from the recorded interactions.
+type Cassette struct {
+	Version int               `json:"version"`
+	LLM     []LLMInteraction  `json:"llm"`
+	Tools   []ToolInteraction `json:"tools"`
+
+	path      string
+	recording bool
+	mu        sync.Mutex
+	nextLLM   int
+	toolsUsed []bool
+}
+
+// Record creates a cassette recording the interactions to path.
+func Record(path string) *Cassette {
+	return &Cassette{Version: version, path: path, recording: true}
+}
+
+// Load reads the cassette at path for replay.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{path: path}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %v", path, err)
+	}
+	if c.Version != version {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s, should be %d", c.Version, path, version)
+	}
+	c.toolsUsed = make([]bool, len(c.Tools))
+	return c, nil
+}
+
+// Recording returns true if the cassette records the interactions, false if it replays them.
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+// Save writes the cassette to its file.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(c.path, data, 0o600)
+}
+
+// Client returns the LLM client of the cassette: inner wrapped to record
+// the chat completions, or a client replaying them (inner is unused).
+func (c *Cassette) Client(inner swarm.OpenAIClient) swarm.OpenAIClient {
+	if c.recording {
+		return &recorder{OpenAIClient: inner, cassette: c}
+	}
+	return &player{cassette: c}
+}
+
+// Tool returns the tool of the cassette: tool wrapped to record its
+// executions, or a tool replaying the recorded outputs for the same input.
+func (c *Cassette) Tool(name string, tool tools.Tool) tools.Tool {
+	if c.recording {
+		return func(input string) (string, error) {
+			output, err := tool(input)
+			interaction := ToolInteraction{Tool: name, Input: utils.Redact(input), Output: utils.Redact(output)}
+			if err != nil {
+				interaction.Error = utils.Redact(err.Error())
+			}
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.Tools = append(c.Tools, interaction)
+			if saveErr := c.save(); saveErr != nil {
+				return output, errors.Join(err, fmt.Errorf("failed to save cassette: %v", saveErr))
+			}
+			return output, err
+		}
+	}
+
+	return func(input string) (string, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, interaction := range c.Tools {
+			if c.toolsUsed[i] || interaction.Tool != name || interaction.Input != utils.Redact(input) {
+				continue
+			}
+			c.toolsUsed[i] = true
+			if interaction.Error != "" {
+				return interaction.Output, errors.New(interaction.Error)
+			}
+			return interaction.Output, nil
+		}
+		return "", fmt.Errorf("no recorded execution of tool %s with input %q in cassette %s", name, input, c.path)
+	}
+}
+
+// recorder records the chat completions of the wrapped client.
+type recorder struct {
+	swarm.OpenAIClient
+	cassette *Cassette
+}
+
+// CreateChatCompletion implements swarm.OpenAIClient.
+func (r *recorder) CreateChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	resp, err := r.OpenAIClient.CreateChatCompletion(ctx, params)
+
+	request, marshalErr := json.Marshal(params)
+	if marshalErr != nil {
+		return resp, err
+	}
+	interaction := LLMInteraction{Request: redactJSON(request)}
+	if err != nil {
+		interaction.Error = utils.Redact(err.Error())
+	} else if response, marshalErr := json.Marshal(resp); marshalErr == nil {
+		interaction.Response = redactJSON(response)
+	}
+
+	r.cassette.mu.Lock()
+	defer r.cassette.mu.Unlock()
+	r.cassette.LLM = append(r.cassette.LLM, interaction)
+	if saveErr := r.cassette.save(); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save cassette: %v", saveErr)
+	}
+	return resp, err
+}
+
+// CreateChatCompletionStream implements swarm.OpenAIClient.
+func (r *recorder) CreateChatCompletionStream(ctx context.Context, params openai.ChatCompletionNewParams) (*ssestream.Stream[openai.ChatCompletionChunk], error) {
+	return nil, fmt.Errorf("streaming chat completions are not supported by cassettes")
+}
+
+// player replays the recorded chat completions in order.
+type player struct {
+	cassette *Cassette
+}
+
+// CreateChatCompletion implements swarm.OpenAIClient.
+func (p *player) CreateChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	c := p.cassette
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nextLLM >= len(c.LLM) {
+		return nil, fmt.Errorf("cassette %s has no more recorded chat completions (%d replayed)", c.path, len(c.LLM))
+	}
+	interaction := c.LLM[c.nextLLM]
+	c.nextLLM++
+	if interaction.Error != "" {
+		return nil, errors.New(interaction.Error)
+	}
+
+	var resp openai.ChatCompletion
+	if err := json.Unmarshal(interaction.Response, &resp); err != nil {
+		return nil, fmt.Errorf("invalid chat completion %d in cassette %s: %v", c.nextLLM, c.path, err)
+	}
+	return &resp, nil
+}
+
+// CreateChatCompletionStream implements swarm.OpenAIClient.
+func (p *player) CreateChatCompletionStream(ctx context.Context, params openai.ChatCompletionNewParams) (*ssestream.Stream[openai.ChatCompletionChunk], error) {
+	return nil, fmt.Errorf("streaming chat completions are not supported by cassettes")
+}
+
+// redactJSON redacts the credentials of a JSON document, keeping it valid.
+func redactJSON(data []byte) json.RawMessage {
+	redacted := utils.Redact(string(data))
+	if !json.Valid([]byte(redacted)) {
+		return json.RawMessage(`{"redacted": true}`)
+	}
+	return json.RawMessage(redacted)
+}
+
+// WrapTools replaces the tools of the registry by their cassette wrappers.
+func (c *Cassette) WrapTools(registry map[string]tools.Tool) {
+	for name, tool := range registry {
+		registry[name] = c.Tool(name, tool)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+)
+
+// fakeClient answers every chat completion with the same content.
+type fakeClient struct {
+	swarm.OpenAIClient
+	content string
+	calls   int
+}
+
+func (f *fakeClient) CreateChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	f.calls++
+	return &openai.ChatCompletion{
+		ID:    "chatcmpl-1",
+		Model: params.Model.Value,
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: "assistant", Content: f.content}},
+		},
+	}, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	params := openai.ChatCompletionNewParams{
+		Model:    openai.F("gpt-4o"),
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{openai.UserMessage("api_key=sk-abcdefghijklmnopqrstuvwx")}),
+	}
+
+	recording := Record(path)
+	inner := &fakeClient{content: "The Pod is healthy."}
+	if _, err := recording.Client(inner).CreateChatCompletion(context.Background(), params); err != nil {
+		t.Fatalf("CreateChatCompletion() error = %v", err)
+	}
+	kubectl := recording.Tool("kubectl", func(input string) (string, error) {
+		if input == "get pod missing" {
+			return "", errors.New("pods \"missing\" not found")
+		}
+		return "NAME READY\nnginx 1/1", nil
+	})
+	kubectl("get pods")
+	kubectl("get pod missing")
+
+	replay, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if replay.Recording() {
+		t.Errorf("Recording() = true for a loaded cassette")
+	}
+	if request := string(replay.LLM[0].Request); strings.Contains(request, "sk-abcdefghijklmnopqrstuvwx") || !json.Valid(replay.LLM[0].Request) {
+		t.Errorf("recorded request is not redacted: %s", request)
+	}
+
+	client := replay.Client(nil)
+	resp, err := client.CreateChatCompletion(context.Background(), params)
+	if err != nil {
+		t.Fatalf("replayed CreateChatCompletion() error = %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "The Pod is healthy." {
+		t.Errorf("replayed content = %q, want %q", got, "The Pod is healthy.")
+	}
+	if _, err := client.CreateChatCompletion(context.Background(), params); err == nil {
+		t.Errorf("CreateChatCompletion() on an exhausted cassette should fail")
+	}
+	if inner.calls != 1 {
+		t.Errorf("inner client called %d times, want 1", inner.calls)
+	}
+
+	replayed := replay.Tool("kubectl", nil)
+	if _, err := replayed("get pod missing"); err == nil {
+		t.Errorf("replayed tool should return the recorded error")
+	}
+	if got, err := replayed("get pods"); err != nil || got != "NAME READY\nnginx 1/1" {
+		t.Errorf("replayed tool = %q, %v", got, err)
+	}
+	if _, err := replayed("get pods"); err == nil {
+		t.Errorf("replayed tool should fail once the recorded executions are used")
+	}
+}
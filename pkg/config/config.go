@@ -0,0 +1,515 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/notify"
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+	"github.com/feiskyer/kube-copilot/pkg/secrets"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the user-level configuration stored in ~/.kube-copilot/config.yaml.
+type Config struct {
+	// Model is the default LLM model.
+	Model string `yaml:"model,omitempty"`
+	// Provider is the LLM provider (openai or azure).
+	Provider string `yaml:"provider,omitempty"`
+	// BaseURL is the API base URL of the LLM provider.
+	BaseURL string `yaml:"baseURL,omitempty"`
+	// Language is the language of the responses (e.g. English or Chinese).
+	Language string `yaml:"language,omitempty"`
+	// ReadOnly rejects all commands that may change the cluster.
+	ReadOnly bool `yaml:"readOnly,omitempty"`
+	// Offline disables the cluster access, so that the agent only answers from the provided manifests and documents.
+	Offline bool `yaml:"offline,omitempty"`
+	// AirGapped disables the internet access: the search tool is disabled, trivy
+	// uses its local vulnerability database and the LLM must be on the local network.
+	AirGapped bool `yaml:"airGapped,omitempty"`
+	// TrivyCacheDir is the cache directory of trivy holding the vulnerability database.
+	TrivyCacheDir string `yaml:"trivyCacheDir,omitempty"`
+	// TrivySkipUpdate scans the images without updating the vulnerability database.
+	TrivySkipUpdate bool `yaml:"trivySkipUpdate,omitempty"`
+	// TrivySeverity is the minimum severity of the image vulnerabilities handed to the model (defaults to HIGH).
+	TrivySeverity string `yaml:"trivySeverity,omitempty"`
+	// TrivyCacheTTL is how long the scan results of an image digest are reused, e.g. "24h"
+	// (defaults to 6h, "0" disables the scan cache).
+	TrivyCacheTTL string `yaml:"trivyCacheTTL,omitempty"`
+	// MaxTokens is the token budget of the LLM model.
+	MaxTokens int `yaml:"maxTokens,omitempty"`
+	// APIKeySecret references the API key of the LLM provider (see secrets.Resolve),
+	// so that the key itself is neither stored in the file nor passed on the command line.
+	APIKeySecret string `yaml:"apiKeySecret,omitempty"`
+	// MasterKeySecret references the master key of the encrypted secret store (see
+	// secrets.Store) when KUBE_COPILOT_MASTER_KEY is not set, e.g. a Vault secret.
+	MasterKeySecret string `yaml:"masterKeySecret,omitempty"`
+	// AuditLog is the path of the audit log ("-" for stderr, "off" to disable).
+	// Defaults to ~/.kube-copilot/audit.log.
+	AuditLog string `yaml:"auditLog,omitempty"`
+	// Theme is the markdown theme (auto, dark, light or plain).
+	Theme string `yaml:"theme,omitempty"`
+	// Notify are the notification targets of the findings (see notify.Parse), whose
+	// URL may be a secret reference, e.g. "slack:store://~/.kube-copilot/secrets.json#slack".
+	Notify []string `yaml:"notify,omitempty"`
+	// Ticket is the Jira or ServiceNow target of the tickets filed by "diagnose --ticket"
+	// (see notify.ParseTicket), whose URL may be a secret reference.
+	Ticket string `yaml:"ticket,omitempty"`
+	// GrafanaURL enables the Grafana annotations of the diagnoses and remediations.
+	GrafanaURL string `yaml:"grafanaURL,omitempty"`
+	// GrafanaTokenSecret references the Grafana token (see secrets.Resolve).
+	GrafanaTokenSecret string `yaml:"grafanaTokenSecret,omitempty"`
+	// PluginsDir is the directory of the tool plugins. Defaults to ~/.kube-copilot/plugins.
+	PluginsDir string `yaml:"pluginsDir,omitempty"`
+	// PrometheusURL enables the memory usage history of the OOM analysis.
+	PrometheusURL string `yaml:"prometheusURL,omitempty"`
+	// Tenancy is the file mapping the users and roles to their clusters and
+	// namespaces (see tenancy.Tenancy). /etc/kube-copilot/tenancy.yaml takes precedence.
+	Tenancy string `yaml:"tenancy,omitempty"`
+	// Policy is the Rego file or directory of the policies evaluated for every tool execution.
+	Policy string `yaml:"policy,omitempty"`
+	// PostProcess are the post-processors applied in order to the final answers
+	// (defaults to strip-thoughts, markdown and verify, "none" disables them).
+	PostProcess []string `yaml:"postProcess,omitempty"`
+	// Disclaimer is appended to the final answers by the footer post-processor.
+	Disclaimer string `yaml:"disclaimer,omitempty"`
+	// SummaryModel is the (cheaper) model writing the titles and summaries of
+	// the history entries. Defaults to the model of the run, "off" disables them.
+	SummaryModel string `yaml:"summaryModel,omitempty"`
+	// PromptsDir is the directory of the prompt versions. Defaults to ~/.kube-copilot/prompts.
+	PromptsDir string `yaml:"promptsDir,omitempty"`
+	// PromptVersions pin the versions of the prompts as "name=version" (see prompts.Selector).
+	PromptVersions []string `yaml:"promptVersions,omitempty"`
+}
+
+// Keys are the configuration keys supported by Get and Set.
+var Keys = []string{"model", "provider", "baseURL", "language", "readOnly", "offline", "airGapped", "trivyCacheDir", "trivySkipUpdate", "trivySeverity", "trivyCacheTTL", "maxTokens", "apiKeySecret", "masterKeySecret", "auditLog", "theme", "notify", "ticket", "grafanaURL", "grafanaTokenSecret", "pluginsDir", "policy", "tenancy", "prometheusURL", "postProcess", "disclaimer", "summaryModel", "promptsDir", "promptVersions"}
+
+// Providers are the supported LLM providers.
+var Providers = []string{"openai", "azure"}
+
+// DefaultPath returns the path of the user-level configuration file.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "config.yaml"), nil
+}
+
+// unknownFieldPattern matches the yaml errors of unknown keys.
+var unknownFieldPattern = regexp.MustCompile(`line (\d+): field (\S+) not found in type`)
+
+// Load reads the configuration from path. A missing file yields an empty configuration.
+// Unknown keys are rejected so that typos don't silently fall back to the defaults.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		if matches := unknownFieldPattern.FindAllStringSubmatch(err.Error(), -1); matches != nil {
+			var problems []string
+			for _, m := range matches {
+				problems = append(problems, fmt.Sprintf("unknown key %q at line %s", m[2], m[1]))
+			}
+			return nil, fmt.Errorf("invalid configuration %s: %s (supported keys: %s)", path, strings.Join(problems, "; "), strings.Join(Keys, ", "))
+		}
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the values of the configuration and returns an error
+// listing all the problems found.
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Provider != "" && !contains(Providers, c.Provider) {
+		problems = append(problems, fmt.Sprintf("provider %q is not supported, should be one of %s", c.Provider, strings.Join(Providers, ", ")))
+	}
+	// Azure OpenAI has no default endpoint.
+	if c.Provider == "azure" && c.BaseURL == "" && os.Getenv("AZURE_OPENAI_API_BASE") == "" {
+		problems = append(problems, "baseURL is required for the azure provider (or set AZURE_OPENAI_API_BASE)")
+	}
+	if c.Theme != "" && !contains(utils.Themes, c.Theme) {
+		problems = append(problems, fmt.Sprintf("theme %q is not supported, should be one of %s", c.Theme, strings.Join(utils.Themes, ", ")))
+	}
+	if c.TrivySeverity != "" && !tools.ValidTrivySeverity(c.TrivySeverity) {
+		problems = append(problems, fmt.Sprintf("trivySeverity %q is not supported, should be one of %s", c.TrivySeverity, strings.Join(tools.TrivySeverities, ", ")))
+	}
+	if c.TrivyCacheTTL != "" && !validDuration(c.TrivyCacheTTL) {
+		problems = append(problems, fmt.Sprintf("trivyCacheTTL %q should be a non-negative duration such as 24h", c.TrivyCacheTTL))
+	}
+	if c.MaxTokens < 0 {
+		problems = append(problems, fmt.Sprintf("maxTokens %d should be a non-negative integer", c.MaxTokens))
+	}
+	if c.APIKeySecret != "" {
+		if err := secrets.ValidateRef(c.APIKeySecret); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.MasterKeySecret != "" {
+		if err := validateMasterKeySecret(c.MasterKeySecret); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.GrafanaURL != "" && !validHTTPURL(c.GrafanaURL) {
+		problems = append(problems, fmt.Sprintf("grafanaURL %q should be an http(s) URL", c.GrafanaURL))
+	}
+	if c.GrafanaTokenSecret != "" {
+		if err := secrets.ValidateRef(c.GrafanaTokenSecret); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.PrometheusURL != "" && !validHTTPURL(c.PrometheusURL) {
+		problems = append(problems, fmt.Sprintf("prometheusURL %q should be an http(s) URL", c.PrometheusURL))
+	}
+	if _, err := prompts.ParsePins(c.PromptVersions); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := workflows.ParsePostProcess(c.PostProcess); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, target := range c.Notify {
+		if err := validateNotifyTarget(target); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.Ticket != "" {
+		if err := validateTicketTarget(c.Ticket); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Save writes the configuration to path, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get returns the value of the given key.
+func (c *Config) Get(key string) (string, error) {
+	switch key {
+	case "model":
+		return c.Model, nil
+	case "provider":
+		return c.Provider, nil
+	case "baseURL":
+		return c.BaseURL, nil
+	case "language":
+		return c.Language, nil
+	case "readOnly":
+		return strconv.FormatBool(c.ReadOnly), nil
+	case "offline":
+		return strconv.FormatBool(c.Offline), nil
+	case "airGapped":
+		return strconv.FormatBool(c.AirGapped), nil
+	case "trivyCacheDir":
+		return c.TrivyCacheDir, nil
+	case "trivySkipUpdate":
+		return strconv.FormatBool(c.TrivySkipUpdate), nil
+	case "trivySeverity":
+		return c.TrivySeverity, nil
+	case "trivyCacheTTL":
+		return c.TrivyCacheTTL, nil
+	case "maxTokens":
+		return strconv.Itoa(c.MaxTokens), nil
+	case "apiKeySecret":
+		return c.APIKeySecret, nil
+	case "masterKeySecret":
+		return c.MasterKeySecret, nil
+	case "auditLog":
+		return c.AuditLog, nil
+	case "theme":
+		return c.Theme, nil
+	case "notify":
+		return strings.Join(c.Notify, ","), nil
+	case "ticket":
+		return c.Ticket, nil
+	case "grafanaURL":
+		return c.GrafanaURL, nil
+	case "grafanaTokenSecret":
+		return c.GrafanaTokenSecret, nil
+	case "pluginsDir":
+		return c.PluginsDir, nil
+	case "policy":
+		return c.Policy, nil
+	case "tenancy":
+		return c.Tenancy, nil
+	case "prometheusURL":
+		return c.PrometheusURL, nil
+	case "postProcess":
+		return strings.Join(c.PostProcess, ","), nil
+	case "disclaimer":
+		return c.Disclaimer, nil
+	case "summaryModel":
+		return c.SummaryModel, nil
+	case "promptsDir":
+		return c.PromptsDir, nil
+	case "promptVersions":
+		return strings.Join(c.PromptVersions, ","), nil
+	default:
+		return "", unknownKeyError(key)
+	}
+}
+
+// Set parses value and sets it to the given key.
+func (c *Config) Set(key, value string) error {
+	switch key {
+	case "model":
+		c.Model = value
+	case "provider":
+		value = strings.ToLower(value)
+		if value != "" && !contains(Providers, value) {
+			return fmt.Errorf("unsupported provider %q, should be one of %s", value, strings.Join(Providers, ", "))
+		}
+		c.Provider = value
+	case "baseURL":
+		c.BaseURL = value
+	case "language":
+		c.Language = value
+	case "readOnly":
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for readOnly: %v", value, err)
+		}
+		c.ReadOnly = readOnly
+	case "offline":
+		offline, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for offline: %v", value, err)
+		}
+		c.Offline = offline
+	case "airGapped":
+		airGapped, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for airGapped: %v", value, err)
+		}
+		c.AirGapped = airGapped
+	case "trivyCacheDir":
+		c.TrivyCacheDir = value
+	case "trivySkipUpdate":
+		skipUpdate, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for trivySkipUpdate: %v", value, err)
+		}
+		c.TrivySkipUpdate = skipUpdate
+	case "trivySeverity":
+		if value != "" && !tools.ValidTrivySeverity(value) {
+			return fmt.Errorf("invalid value %q for trivySeverity, should be one of %s", value, strings.Join(tools.TrivySeverities, ", "))
+		}
+		c.TrivySeverity = strings.ToUpper(value)
+	case "trivyCacheTTL":
+		if value != "" && !validDuration(value) {
+			return fmt.Errorf("invalid value %q for trivyCacheTTL, should be a non-negative duration such as 24h", value)
+		}
+		c.TrivyCacheTTL = value
+	case "maxTokens":
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil || maxTokens < 0 {
+			return fmt.Errorf("invalid value %q for maxTokens, should be a non-negative integer", value)
+		}
+		c.MaxTokens = maxTokens
+	case "apiKeySecret":
+		if value != "" {
+			if err := secrets.ValidateRef(value); err != nil {
+				return err
+			}
+		}
+		c.APIKeySecret = value
+	case "masterKeySecret":
+		if value != "" {
+			if err := validateMasterKeySecret(value); err != nil {
+				return err
+			}
+		}
+		c.MasterKeySecret = value
+	case "auditLog":
+		c.AuditLog = value
+	case "theme":
+		value = strings.ToLower(value)
+		if value != "" && !contains(utils.Themes, value) {
+			return fmt.Errorf("unsupported theme %q, should be one of %s", value, strings.Join(utils.Themes, ", "))
+		}
+		c.Theme = value
+	case "notify":
+		// Multiple targets are separated by commas.
+		var targets []string
+		for _, target := range strings.Split(value, ",") {
+			if target = strings.TrimSpace(target); target == "" {
+				continue
+			}
+			if err := validateNotifyTarget(target); err != nil {
+				return err
+			}
+			targets = append(targets, target)
+		}
+		c.Notify = targets
+	case "ticket":
+		if value != "" {
+			if err := validateTicketTarget(value); err != nil {
+				return err
+			}
+		}
+		c.Ticket = value
+	case "grafanaURL":
+		if value != "" && !validHTTPURL(value) {
+			return fmt.Errorf("invalid value %q for grafanaURL, should be an http(s) URL", value)
+		}
+		c.GrafanaURL = value
+	case "grafanaTokenSecret":
+		if value != "" {
+			if err := secrets.ValidateRef(value); err != nil {
+				return err
+			}
+		}
+		c.GrafanaTokenSecret = value
+	case "pluginsDir":
+		c.PluginsDir = value
+	case "policy":
+		c.Policy = value
+	case "tenancy":
+		c.Tenancy = value
+	case "prometheusURL":
+		if value != "" && !validHTTPURL(value) {
+			return fmt.Errorf("invalid value %q for prometheusURL, should be an http(s) URL", value)
+		}
+		c.PrometheusURL = value
+	case "postProcess":
+		// The post-processors are separated by commas.
+		var names []string
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if _, err := workflows.ParsePostProcess(names); err != nil {
+			return err
+		}
+		c.PostProcess = names
+	case "disclaimer":
+		c.Disclaimer = value
+	case "summaryModel":
+		c.SummaryModel = value
+	case "promptsDir":
+		c.PromptsDir = value
+	case "promptVersions":
+		// The pins are separated by commas.
+		var pins []string
+		for _, pin := range strings.Split(value, ",") {
+			if pin = strings.TrimSpace(pin); pin != "" {
+				pins = append(pins, pin)
+			}
+		}
+		if _, err := prompts.ParsePins(pins); err != nil {
+			return err
+		}
+		c.PromptVersions = pins
+	default:
+		return unknownKeyError(key)
+	}
+	return nil
+}
+
+// validateMasterKeySecret rejects the invalid references and the encrypted
+// store, which cannot hold its own master key.
+func validateMasterKeySecret(ref string) error {
+	if err := secrets.ValidateRef(ref); err != nil {
+		return err
+	}
+	if strings.HasPrefix(ref, "store://") {
+		return fmt.Errorf("masterKeySecret %q cannot reference the encrypted secret store", ref)
+	}
+	return nil
+}
+
+// validateNotifyTarget checks a notification target, whose URL may be a
+// secret reference resolved when the notifications are sent.
+func validateNotifyTarget(target string) error {
+	if kind, ref, ok := strings.Cut(target, ":"); ok && secrets.ValidateRef(ref) == nil {
+		if !contains(notify.Kinds, kind) {
+			return fmt.Errorf("unsupported notification target %q, should be one of %s", kind, strings.Join(notify.Kinds, ", "))
+		}
+		return nil
+	}
+	_, err := notify.Parse(target)
+	return err
+}
+
+// validateTicketTarget checks a ticket target, whose URL may be a secret
+// reference resolved when the tickets are filed.
+func validateTicketTarget(target string) error {
+	if kind, ref, ok := strings.Cut(target, ":"); ok && secrets.ValidateRef(ref) == nil {
+		if !contains(notify.TicketKinds, kind) {
+			return fmt.Errorf("unsupported ticket target %q, should be one of %s", kind, strings.Join(notify.TicketKinds, ", "))
+		}
+		return nil
+	}
+	_, err := notify.ParseTicket(target)
+	return err
+}
+
+func unknownKeyError(key string) error {
+	return fmt.Errorf("unknown configuration key %q, should be one of %s", key, strings.Join(Keys, ", "))
+}
+
+func validHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validDuration(value string) bool {
+	d, err := time.ParseDuration(value)
+	return err == nil && d >= 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,438 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package datasafety reviews the risks of data loss of StatefulSets before
+// they are scaled down or deleted.
+package datasafety
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Severities of the risks, matching the severities of the workflow findings.
+const (
+	SeverityHigh   = "HIGH"
+	SeverityMedium = "MEDIUM"
+	SeverityLow    = "LOW"
+)
+
+// Verdicts of the review.
+const (
+	Safe    = "SAFE"
+	Caution = "CAUTION"
+	Unsafe  = "UNSAFE"
+)
+
+// Operations reviewed before they are performed.
+const (
+	OperationReview = "review"
+	OperationScale  = "scale"
+	OperationDelete = "delete"
+)
+
+// maxBackupAge is the age above which the last backup is considered stale.
+const maxBackupAge = 48 * time.Hour
+
+// Operation is the operation the user is about to perform on the StatefulSets.
+type Operation struct {
+	Kind string
+	// Replicas is the target number of replicas when scaling.
+	Replicas int32
+}
+
+// Validate checks the kind and the replicas of the operation.
+func (o Operation) Validate() error {
+	switch o.Kind {
+	case OperationReview, OperationDelete:
+		return nil
+	case OperationScale:
+		if o.Replicas < 0 {
+			return fmt.Errorf("the target replicas must be set to scale")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid operation %q: must be one of %s, %s or %s", o.Kind, OperationReview, OperationScale, OperationDelete)
+	}
+}
+
+// String describes the operation.
+func (o Operation) String() string {
+	if o.Kind == OperationScale {
+		return fmt.Sprintf("scale to %d replicas", o.Replicas)
+	}
+	return o.Kind
+}
+
+// Risk is a data-loss risk of a StatefulSet.
+type Risk struct {
+	// Workload is the kind and name of the workload, e.g. statefulset/db.
+	Workload    string `json:"workload"`
+	Check       string `json:"check"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation"`
+}
+
+// claim is a PersistentVolumeClaim of a StatefulSet replica.
+type claim struct {
+	pvc     corev1.PersistentVolumeClaim
+	ordinal int
+}
+
+// Check returns the data-loss risks of the operation on the StatefulSet
+// with the given name, or on all the StatefulSets if name is empty,
+// ordered by severity and workload.
+func Check(storage *kubernetes.Storage, name string, op Operation, now time.Time) ([]Risk, error) {
+	var risks []Risk
+	found := false
+	for _, sts := range storage.StatefulSets {
+		if name != "" && sts.Name != name {
+			continue
+		}
+		found = true
+		risks = append(risks, checkStatefulSet(sts, storage, op, now)...)
+	}
+	if name != "" && !found {
+		return nil, fmt.Errorf("statefulset %s not found in namespace %s", name, storage.Namespace)
+	}
+
+	sort.SliceStable(risks, func(i, j int) bool {
+		if severityRank(risks[i].Severity) != severityRank(risks[j].Severity) {
+			return severityRank(risks[i].Severity) > severityRank(risks[j].Severity)
+		}
+		return risks[i].Workload < risks[j].Workload
+	})
+	return risks, nil
+}
+
+func checkStatefulSet(sts appsv1.StatefulSet, storage *kubernetes.Storage, op Operation, now time.Time) []Risk {
+	workload := "statefulset/" + sts.Name
+	current := replicas(sts.Spec.Replicas)
+	scalingDown := op.Kind == OperationScale && op.Replicas < current
+	var risks []Risk
+	add := func(check, severity, message, remediation string) {
+		risks = append(risks, Risk{Workload: workload, Check: check, Severity: severity, Message: message, Remediation: remediation})
+	}
+
+	risks = append(risks, checkUpdateStrategy(sts, workload, current)...)
+	if scalingDown {
+		removed := make([]string, 0, current-op.Replicas)
+		for i := op.Replicas; i < current; i++ {
+			removed = append(removed, fmt.Sprintf("%s-%d", sts.Name, i))
+		}
+		severity := SeverityMedium
+		message := fmt.Sprintf("Scaling from %d to %d replicas removes the Pods %s, with the data they hold.", current, op.Replicas, strings.Join(removed, ", "))
+		if current >= 3 && op.Replicas*2 <= current {
+			severity = SeverityHigh
+			message += " The remaining replicas are not a majority: quorum-based applications (e.g. etcd, ZooKeeper, Kafka, MongoDB) lose their quorum and the data not yet replicated."
+		}
+		add("scale-down", severity, message, "Scale down one replica at a time, and let the application rebalance or decommission the replicas (e.g. drain the data or remove the members) first.")
+		if sts.Spec.PodManagementPolicy == appsv1.ParallelPodManagement {
+			add("parallel-pod-management", SeverityMedium, "podManagementPolicy is Parallel: the removed Pods are terminated at once instead of one by one.", "Scale down one replica at a time.")
+		}
+	}
+
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		add("no-persistent-storage", SeverityLow, "The StatefulSet has no volumeClaimTemplates: the data stored in its Pods (e.g. in emptyDir volumes) is lost when they are deleted or rescheduled.", "Add volumeClaimTemplates if the application stores data that must survive restarts.")
+		return risks
+	}
+
+	whenDeleted, whenScaled := appsv1.RetainPersistentVolumeClaimRetentionPolicyType, appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	if policy := sts.Spec.PersistentVolumeClaimRetentionPolicy; policy != nil {
+		if policy.WhenDeleted != "" {
+			whenDeleted = policy.WhenDeleted
+		}
+		if policy.WhenScaled != "" {
+			whenScaled = policy.WhenScaled
+		}
+	}
+
+	claims := claimsOf(sts, storage.PVCs)
+	// deleted returns true if the operation deletes the claim.
+	deleted := func(c claim) bool {
+		switch {
+		case op.Kind == OperationDelete:
+			return whenDeleted == appsv1.DeletePersistentVolumeClaimRetentionPolicyType
+		case scalingDown:
+			return whenScaled == appsv1.DeletePersistentVolumeClaimRetentionPolicyType && int32(c.ordinal) >= op.Replicas
+		}
+		return false
+	}
+
+	if whenDeleted == appsv1.DeletePersistentVolumeClaimRetentionPolicyType {
+		severity := SeverityMedium
+		if op.Kind == OperationDelete {
+			severity = SeverityHigh
+		}
+		add("pvc-deleted-with-statefulset", severity, "persistentVolumeClaimRetentionPolicy.whenDeleted is Delete: the PVCs are deleted with the StatefulSet.", "Set whenDeleted to Retain before deleting the StatefulSet, or back up the volumes first.")
+	}
+	if whenScaled == appsv1.DeletePersistentVolumeClaimRetentionPolicyType {
+		severity := SeverityMedium
+		if scalingDown {
+			severity = SeverityHigh
+		}
+		add("pvc-deleted-on-scale-down", severity, "persistentVolumeClaimRetentionPolicy.whenScaled is Delete: the PVCs of the removed replicas are deleted when scaling down.", "Set whenScaled to Retain before scaling down, or back up the volumes of the removed replicas first.")
+	}
+	if op.Kind == OperationDelete && whenDeleted == appsv1.RetainPersistentVolumeClaimRetentionPolicyType && len(claims) > 0 {
+		add("orphaned-pvcs", SeverityLow, fmt.Sprintf("The PVCs %s are retained after the deletion and keep consuming storage.", claimNames(claims)), "Delete the PVCs explicitly once the data is no longer needed, or reuse them by recreating the StatefulSet with the same name and volumeClaimTemplates.")
+	}
+
+	var deleteReclaimed, deletedNow []string
+	for _, c := range claims {
+		if reclaimPolicy(c.pvc, storage) != corev1.PersistentVolumeReclaimDelete {
+			continue
+		}
+		deleteReclaimed = append(deleteReclaimed, c.pvc.Name)
+		if deleted(c) {
+			deletedNow = append(deletedNow, c.pvc.Name)
+		}
+	}
+	remediation := "Set persistentVolumeReclaimPolicy to Retain on the PersistentVolumes (kubectl patch pv <pv> -p '{\"spec\":{\"persistentVolumeReclaimPolicy\":\"Retain\"}}') before deleting their claims."
+	switch {
+	case len(deletedNow) > 0:
+		action := "Scaling down"
+		if op.Kind == OperationDelete {
+			action = "Deleting the StatefulSet"
+		}
+		add("volumes-destroyed", SeverityHigh, fmt.Sprintf("%s deletes the PVCs %s, and their PersistentVolumes use the Delete reclaim policy: the data is destroyed.", action, strings.Join(deletedNow, ", ")), remediation)
+	case len(deleteReclaimed) > 0:
+		add("delete-reclaim-policy", SeverityMedium, fmt.Sprintf("The PersistentVolumes of the PVCs %s use the Delete reclaim policy: deleting the claims destroys the data.", strings.Join(deleteReclaimed, ", ")), remediation)
+	}
+
+	risks = append(risks, checkBackups(sts, workload, claims, storage, op, now)...)
+	return risks
+}
+
+func checkUpdateStrategy(sts appsv1.StatefulSet, workload string, current int32) []Risk {
+	var risks []Risk
+	strategy := sts.Spec.UpdateStrategy
+	if strategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		risks = append(risks, Risk{Workload: workload, Check: "on-delete-updates", Severity: SeverityLow,
+			Message:     "The update strategy is OnDelete: the Pods are only updated when they are deleted manually, so the running Pods may not match the spec.",
+			Remediation: "Check that the running Pods are up to date (kubectl get pods -L controller-revision-hash) before the operation."})
+	}
+	if strategy.RollingUpdate == nil {
+		return risks
+	}
+
+	if mu := strategy.RollingUpdate.MaxUnavailable; mu != nil {
+		if maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(mu, int(current), false); err == nil && maxUnavailable > 1 {
+			risks = append(risks, Risk{Workload: workload, Check: "max-unavailable", Severity: SeverityMedium,
+				Message:     fmt.Sprintf("The rolling updates take down up to %d Pods at once, which can break the quorum of replicated databases.", maxUnavailable),
+				Remediation: "Set rollingUpdate.maxUnavailable to 1 for quorum-based applications."})
+		}
+	}
+	if partition := strategy.RollingUpdate.Partition; partition != nil && *partition > 0 {
+		risks = append(risks, Risk{Workload: workload, Check: "partitioned-update", Severity: SeverityLow,
+			Message:     fmt.Sprintf("The rolling update is partitioned at %d: the Pods with a lower ordinal run the previous revision.", *partition),
+			Remediation: "Finish or roll back the staged update before the operation."})
+	}
+	return risks
+}
+
+func checkBackups(sts appsv1.StatefulSet, workload string, claims []claim, storage *kubernetes.Storage, op Operation, now time.Time) []Risk {
+	var schedules []kubernetes.BackupSchedule
+	for _, s := range storage.BackupSchedules {
+		if coversNamespace(s.Spec.Template, storage.Namespace) && matchesLabels(s.Spec.Template.LabelSelector, sts.Spec.Template.Labels) && !s.Spec.Paused {
+			schedules = append(schedules, s)
+		}
+	}
+
+	claimSet := make(map[string]bool, len(claims))
+	for _, c := range claims {
+		claimSet[c.pvc.Name] = true
+	}
+	snapshotted := false
+	for _, vs := range storage.VolumeSnapshots {
+		if vs.Spec.Source.PersistentVolumeClaimName == nil || !claimSet[*vs.Spec.Source.PersistentVolumeClaimName] {
+			continue
+		}
+		if vs.Status != nil && vs.Status.ReadyToUse != nil && *vs.Status.ReadyToUse && vs.Status.CreationTime != nil && now.Sub(vs.Status.CreationTime.Time) <= maxBackupAge {
+			snapshotted = true
+		}
+	}
+
+	if len(schedules) == 0 {
+		if snapshotted {
+			return nil
+		}
+		severity := SeverityHigh
+		if op.Kind == OperationReview {
+			severity = SeverityMedium
+		}
+		return []Risk{{Workload: workload, Check: "no-backup", Severity: severity,
+			Message:     fmt.Sprintf("No Velero Schedule nor VolumeSnapshot from the last %s covers the volumes of the StatefulSet.", formatDuration(maxBackupAge)),
+			Remediation: "Back up the volumes before the operation, e.g. with a VolumeSnapshot of each PVC or \"velero backup create --include-namespaces <namespace> --snapshot-volumes\"."}}
+	}
+
+	var risks []Risk
+	for _, s := range schedules {
+		t := s.Spec.Template
+		if t.SnapshotVolumes != nil && !*t.SnapshotVolumes && (t.DefaultVolumesToFsBackup == nil || !*t.DefaultVolumesToFsBackup) && !snapshotted {
+			risks = append(risks, Risk{Workload: workload, Check: "manifests-only-backup", Severity: SeverityMedium,
+				Message:     fmt.Sprintf("The Velero Schedule %s/%s backs up the manifests but not the volume data (snapshotVolumes is false).", s.Namespace, s.Name),
+				Remediation: "Enable snapshotVolumes or defaultVolumesToFsBackup, or take a VolumeSnapshot of each PVC before the operation."})
+			continue
+		}
+		if s.Status.LastBackup == nil {
+			risks = append(risks, Risk{Workload: workload, Check: "stale-backup", Severity: SeverityMedium,
+				Message:     fmt.Sprintf("The Velero Schedule %s/%s (%s) has not completed any backup yet.", s.Namespace, s.Name, s.Spec.Schedule),
+				Remediation: fmt.Sprintf("Run a backup before the operation: velero backup create --from-schedule %s.", s.Name)})
+		} else if age := now.Sub(s.Status.LastBackup.Time); age > maxBackupAge {
+			risks = append(risks, Risk{Workload: workload, Check: "stale-backup", Severity: SeverityMedium,
+				Message:     fmt.Sprintf("The last backup of the Velero Schedule %s/%s was %s ago.", s.Namespace, s.Name, formatDuration(age)),
+				Remediation: fmt.Sprintf("Run a backup before the operation: velero backup create --from-schedule %s.", s.Name)})
+		}
+	}
+	return risks
+}
+
+// claimsOf returns the PVCs created from the volumeClaimTemplates of the
+// StatefulSet, named <template>-<statefulset>-<ordinal>, ordered by name.
+func claimsOf(sts appsv1.StatefulSet, pvcs []corev1.PersistentVolumeClaim) []claim {
+	var claims []claim
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		prefix := fmt.Sprintf("%s-%s-", template.Name, sts.Name)
+		for _, pvc := range pvcs {
+			if !strings.HasPrefix(pvc.Name, prefix) {
+				continue
+			}
+			if ordinal, err := strconv.Atoi(strings.TrimPrefix(pvc.Name, prefix)); err == nil && ordinal >= 0 {
+				claims = append(claims, claim{pvc: pvc, ordinal: ordinal})
+			}
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].pvc.Name < claims[j].pvc.Name })
+	return claims
+}
+
+func claimNames(claims []claim) string {
+	names := make([]string, 0, len(claims))
+	for _, c := range claims {
+		names = append(names, c.pvc.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// reclaimPolicy returns the reclaim policy of the volume bound to the claim,
+// falling back to its StorageClass. It is empty when neither is accessible.
+func reclaimPolicy(pvc corev1.PersistentVolumeClaim, storage *kubernetes.Storage) corev1.PersistentVolumeReclaimPolicy {
+	for _, pv := range storage.PVs {
+		if pvc.Spec.VolumeName != "" && pv.Name == pvc.Spec.VolumeName {
+			return pv.Spec.PersistentVolumeReclaimPolicy
+		}
+	}
+	if pvc.Spec.StorageClassName == nil {
+		return ""
+	}
+	for _, sc := range storage.StorageClasses {
+		if sc.Name == *pvc.Spec.StorageClassName {
+			if sc.ReclaimPolicy == nil {
+				return corev1.PersistentVolumeReclaimDelete
+			}
+			return *sc.ReclaimPolicy
+		}
+	}
+	return ""
+}
+
+// coversNamespace returns true if the Velero backup template includes the namespace.
+func coversNamespace(t kubernetes.BackupTemplate, namespace string) bool {
+	for _, ns := range t.ExcludedNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(t.IncludedNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range t.IncludedNamespaces {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesLabels(selector *metav1.LabelSelector, set map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	return err == nil && s.Matches(labels.Set(set))
+}
+
+// Verdict returns UNSAFE if any risk is HIGH, CAUTION if there are other risks, and SAFE otherwise.
+func Verdict(risks []Risk) string {
+	verdict := Safe
+	for _, r := range risks {
+		if r.Severity == SeverityHigh {
+			return Unsafe
+		}
+		verdict = Caution
+	}
+	return verdict
+}
+
+// Format formats the risks as a list, one risk per line.
+func Format(risks []Risk) string {
+	if len(risks) == 0 {
+		return "No data-loss risks found."
+	}
+
+	lines := make([]string, 0, len(risks))
+	for _, r := range risks {
+		lines = append(lines, fmt.Sprintf("- [%s] %s (%s): %s Fix: %s", r.Severity, r.Workload, r.Check, r.Message, r.Remediation))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replicas returns the desired replicas, which default to 1.
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// formatDuration formats a duration in hours, or in days above two days.
+func formatDuration(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%d hours", int(d/time.Hour))
+}
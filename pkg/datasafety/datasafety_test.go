@@ -0,0 +1,215 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package datasafety
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func statefulSet(name string, replicas int32, templates ...string) appsv1.StatefulSet {
+	sts := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:       &replicas,
+			Template:       corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}}},
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+		},
+	}
+	for _, t := range templates {
+		sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: t}})
+	}
+	return sts
+}
+
+func pvc(name string, volume string, storageClass string) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volume, StorageClassName: &storageClass},
+	}
+}
+
+func pv(name string, policy corev1.PersistentVolumeReclaimPolicy) corev1.PersistentVolume {
+	return corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: policy},
+	}
+}
+
+func testStorage() *kubernetes.Storage {
+	db := statefulSet("db", 3, "data")
+	db.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+	db.Spec.PersistentVolumeClaimRetentionPolicy = &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+	}
+	cache := statefulSet("cache", 1)
+	cache.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+	retain := corev1.PersistentVolumeReclaimRetain
+
+	return &kubernetes.Storage{
+		Namespace:    "prod",
+		StatefulSets: []appsv1.StatefulSet{db, cache, statefulSet("queue", 2, "data")},
+		PVCs: []corev1.PersistentVolumeClaim{
+			pvc("data-db-0", "pv-0", "standard"),
+			pvc("data-db-1", "pv-1", "standard"),
+			pvc("data-db-2", "pv-2", "standard"),
+			pvc("data-queue-0", "pv-3", "retain"),
+			pvc("data-queue-1", "pv-4", "retain"),
+			pvc("data-dbx-0", "pv-5", "standard"),
+		},
+		PVs:            []corev1.PersistentVolume{pv("pv-0", corev1.PersistentVolumeReclaimDelete), pv("pv-1", corev1.PersistentVolumeReclaimDelete), pv("pv-2", corev1.PersistentVolumeReclaimDelete)},
+		StorageClasses: []storagev1.StorageClass{{ObjectMeta: metav1.ObjectMeta{Name: "retain"}, ReclaimPolicy: &retain}},
+		BackupSchedules: []kubernetes.BackupSchedule{{
+			ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "velero"},
+			Spec: kubernetes.BackupScheduleSpec{
+				Schedule: "0 1 * * *",
+				Template: kubernetes.BackupTemplate{
+					IncludedNamespaces: []string{"prod"},
+					LabelSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"app": "queue"}},
+				},
+			},
+			Status: kubernetes.BackupScheduleStatus{LastBackup: &metav1.Time{Time: now.Add(-72 * time.Hour)}},
+		}},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		statefulSet string
+		op          Operation
+		want        []string
+		wantVerdict string
+	}{
+		{
+			name:        "scale down",
+			statefulSet: "db",
+			op:          Operation{Kind: OperationScale, Replicas: 1},
+			want: []string{
+				"HIGH db no-backup", "HIGH db pvc-deleted-on-scale-down", "HIGH db scale-down", "HIGH db volumes-destroyed",
+				"MEDIUM db parallel-pod-management",
+			},
+			wantVerdict: Unsafe,
+		},
+		{
+			name:        "delete",
+			statefulSet: "queue",
+			op:          Operation{Kind: OperationDelete},
+			want:        []string{"LOW queue orphaned-pvcs", "MEDIUM queue stale-backup"},
+			wantVerdict: Caution,
+		},
+		{
+			name: "review",
+			op:   Operation{Kind: OperationReview},
+			want: []string{
+				"LOW cache no-persistent-storage", "LOW cache on-delete-updates",
+				"MEDIUM db delete-reclaim-policy", "MEDIUM db no-backup", "MEDIUM db pvc-deleted-on-scale-down",
+				"MEDIUM queue stale-backup",
+			},
+			wantVerdict: Caution,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risks, err := Check(testStorage(), tt.statefulSet, tt.op, now)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			var got []string
+			for _, r := range risks {
+				got = append(got, r.Severity+" "+strings.TrimPrefix(r.Workload, "statefulset/")+" "+r.Check)
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+			if verdict := Verdict(risks); verdict != tt.wantVerdict {
+				t.Errorf("Verdict() = %s, want %s", verdict, tt.wantVerdict)
+			}
+		})
+	}
+}
+
+func TestCheckMessages(t *testing.T) {
+	risks, err := Check(testStorage(), "db", Operation{Kind: OperationScale, Replicas: 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted := Format(risks)
+	for _, want := range []string{
+		"Scaling from 3 to 1 replicas removes the Pods db-1, db-2",
+		"Scaling down deletes the PVCs data-db-1, data-db-2, and their PersistentVolumes use the Delete reclaim policy",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Format() = %s\nwant it to contain %q", formatted, want)
+		}
+	}
+
+	if _, err := Check(testStorage(), "missing", Operation{Kind: OperationDelete}, now); err == nil {
+		t.Error("Check() = nil error, want a not found error")
+	}
+}
+
+func TestCheckRecentSnapshot(t *testing.T) {
+	storage := testStorage()
+	ready := true
+	for _, name := range []string{"data-db-0", "data-db-1", "data-db-2"} {
+		claimName := name
+		storage.VolumeSnapshots = append(storage.VolumeSnapshots, kubernetes.VolumeSnapshot{
+			Spec:   kubernetes.VolumeSnapshotSpec{Source: kubernetes.VolumeSnapshotSource{PersistentVolumeClaimName: &claimName}},
+			Status: &kubernetes.VolumeSnapshotStatus{ReadyToUse: &ready, CreationTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+		})
+	}
+
+	risks, err := Check(storage, "db", Operation{Kind: OperationReview}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range risks {
+		if r.Check == "no-backup" {
+			t.Errorf("Check() = %+v, want no backup risk with recent snapshots", r)
+		}
+	}
+}
+
+func TestOperationValidate(t *testing.T) {
+	tests := []struct {
+		op      Operation
+		wantErr bool
+	}{
+		{op: Operation{Kind: OperationReview}},
+		{op: Operation{Kind: OperationDelete}},
+		{op: Operation{Kind: OperationScale, Replicas: 0}},
+		{op: Operation{Kind: OperationScale, Replicas: -1}, wantErr: true},
+		{op: Operation{Kind: "drain"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.op.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.op, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package errdefs
+
+import "errors"
+
+// Error kinds shared by the packages. Errors are wrapped with
+// fmt.Errorf("%w: ...", ErrXxx) so that callers can branch on the kind with
+// errors.Is while keeping the original message.
+var (
+	// ErrProviderAuth is returned when the LLM provider rejects (or misses) the credentials.
+	ErrProviderAuth = errors.New("LLM provider authentication failed")
+	// ErrToolTimeout is returned when a tool doesn't complete in time.
+	ErrToolTimeout = errors.New("tool execution timed out")
+	// ErrClusterUnreachable is returned when the Kubernetes API server can't be reached.
+	ErrClusterUnreachable = errors.New("cluster is unreachable")
+	// ErrJSONParse is returned when a response of the LLM isn't valid JSON.
+	ErrJSONParse = errors.New("unable to parse JSON response")
+	// ErrOffline is returned when the cluster is accessed in offline mode.
+	ErrOffline = errors.New("cluster access is disabled in offline mode")
+	// ErrAirGapped is returned when the internet is accessed in air-gapped mode.
+	ErrAirGapped = errors.New("internet access is disabled in air-gapped mode")
+	// ErrTenantScope is returned when a cluster or namespace outside of the user's tenants is accessed.
+	ErrTenantScope = errors.New("access is outside of the tenant scope")
+)
+
+// codes are the stable error codes of the error kinds.
+var codes = []struct {
+	kind error
+	code string
+}{
+	{ErrProviderAuth, "provider_auth"},
+	{ErrToolTimeout, "tool_timeout"},
+	{ErrClusterUnreachable, "cluster_unreachable"},
+	{ErrJSONParse, "json_parse"},
+	{ErrOffline, "offline"},
+	{ErrAirGapped, "air_gapped"},
+	{ErrTenantScope, "tenant_scope"},
+}
+
+// Code returns the error code of err ("unknown" if its kind isn't known), or "" if err is nil.
+func Code(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.kind) {
+			return c.code
+		}
+	}
+	return "unknown"
+}
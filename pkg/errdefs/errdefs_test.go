@@ -0,0 +1,48 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "provider auth", err: fmt.Errorf("%w: OPENAI_API_KEY is not set", ErrProviderAuth), want: "provider_auth"},
+		{name: "nested", err: fmt.Errorf("chat completion error: %w", fmt.Errorf("%w: 401", ErrProviderAuth)), want: "provider_auth"},
+		{name: "tool timeout", err: fmt.Errorf("%w: kubectl", ErrToolTimeout), want: "tool_timeout"},
+		{name: "cluster unreachable", err: fmt.Errorf("%w: connection refused", ErrClusterUnreachable), want: "cluster_unreachable"},
+		{name: "json parse", err: fmt.Errorf("%w: unexpected end of JSON input", ErrJSONParse), want: "json_parse"},
+		{name: "offline", err: fmt.Errorf("%w: the kubectl tool is disabled", ErrOffline), want: "offline"},
+		{name: "air-gapped", err: fmt.Errorf("%w: the search tool is disabled", ErrAirGapped), want: "air_gapped"},
+		{name: "tenant scope", err: fmt.Errorf("%w: namespace kube-system", ErrTenantScope), want: "tenant_scope"},
+		{name: "unknown", err: errors.New("boom"), want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.want {
+				t.Errorf("Code() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"sigs.k8s.io/yaml"
+)
+
+// Workflows supported by the scenarios.
+const (
+	WorkflowDiagnose = "diagnose"
+	WorkflowExecute  = "execute"
+	WorkflowAudit    = "audit"
+	WorkflowAnalyze  = "analyze"
+)
+
+// Suite is a set of scenarios evaluated against one or more models:
+//
+//	name: pod-diagnosis
+//	models: [gpt-4o, gpt-4o-mini]
+//	scenarios:
+//	  - name: crashloop-missing-configmap
+//	    namespace: default
+//	    pod: web
+//	    fixtures:
+//	      - tool: kubectl
+//	        match: get pod web
+//	        output: |
+//	          NAME   READY   STATUS                       RESTARTS
+//	          web    0/1     CreateContainerConfigError   0
+//	    expect:
+//	      contains: [configmap]
+type Suite struct {
+	Name      string     `json:"name"`
+	Models    []string   `json:"models,omitempty"`
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// Scenario is a task for the agent, with mocked tool outputs and the
+// expectations its answer is scored against.
+type Scenario struct {
+	Name string `json:"name"`
+	// Workflow is one of diagnose (default), execute, audit or analyze.
+	Workflow     string      `json:"workflow,omitempty"`
+	Namespace    string      `json:"namespace,omitempty"`
+	Pod          string      `json:"pod,omitempty"`
+	Instructions string      `json:"instructions,omitempty"`
+	Manifest     string      `json:"manifest,omitempty"`
+	Fixtures     []Fixture   `json:"fixtures,omitempty"`
+	Expect       Expectation `json:"expect"`
+}
+
+// Fixture is the mocked output of a tool for the inputs matching Match.
+type Fixture struct {
+	Tool string `json:"tool"`
+	// Match is a regular expression matched against the tool input. Empty matches any input.
+	Match  string `json:"match,omitempty"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// matches returns true if the fixture applies to the tool input.
+func (f *Fixture) matches(input string) bool {
+	if f.pattern == nil {
+		pattern, err := regexp.Compile(f.Match)
+		if err != nil {
+			return false
+		}
+		f.pattern = pattern
+	}
+	return f.pattern.MatchString(input)
+}
+
+// Expectation is the expected outcome of a scenario. Each item is a check
+// contributing equally to the score of the scenario.
+type Expectation struct {
+	// Contains are the substrings the answer must contain (case-insensitive).
+	Contains []string `json:"contains,omitempty"`
+	// NotContains are the substrings the answer must not contain (case-insensitive).
+	NotContains []string `json:"notContains,omitempty"`
+	// Severity is the minimum severity of at least one finding in the answer.
+	Severity string `json:"severity,omitempty"`
+	// ToolCalls are regular expressions, each matching the input of at least one tool call.
+	ToolCalls []string `json:"toolCalls,omitempty"`
+}
+
+// ToolCall is a tool invocation of the agent during a scenario.
+type ToolCall struct {
+	Tool  string `json:"tool"`
+	Input string `json:"input"`
+}
+
+// Check is the outcome of a single expectation.
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+}
+
+// Result is the outcome of a scenario for a model.
+type Result struct {
+	Model string `json:"model"`
+	// Prompts labels the prompt versions evaluated (e.g. "audit@v2"), if any.
+	Prompts   string        `json:"prompts,omitempty"`
+	Scenario  string        `json:"scenario"`
+	Passed    bool          `json:"passed"`
+	Score     float64       `json:"score"`
+	Checks    []Check       `json:"checks,omitempty"`
+	ToolCalls []ToolCall    `json:"toolCalls,omitempty"`
+	Answer    string        `json:"answer,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Runner runs the workflow of a scenario with the model and returns its answer.
+type Runner func(model string, scenario Scenario) (string, error)
+
+// LoadSuite reads and validates a suite from a YAML or JSON file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite Suite
+	if err := yaml.UnmarshalStrict(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if err := suite.validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %v", path, err)
+	}
+	return &suite, nil
+}
+
+func (s *Suite) validate() error {
+	if len(s.Scenarios) == 0 {
+		return fmt.Errorf("no scenarios found")
+	}
+
+	for i := range s.Scenarios {
+		scenario := &s.Scenarios[i]
+		if scenario.Name == "" {
+			scenario.Name = fmt.Sprintf("scenario-%d", i+1)
+		}
+		if scenario.Workflow == "" {
+			scenario.Workflow = WorkflowDiagnose
+		}
+
+		switch scenario.Workflow {
+		case WorkflowDiagnose, WorkflowAudit:
+			if scenario.Pod == "" {
+				return fmt.Errorf("scenario %s has no pod", scenario.Name)
+			}
+			if scenario.Namespace == "" {
+				scenario.Namespace = "default"
+			}
+		case WorkflowExecute:
+			if strings.TrimSpace(scenario.Instructions) == "" {
+				return fmt.Errorf("scenario %s has no instructions", scenario.Name)
+			}
+		case WorkflowAnalyze:
+			if strings.TrimSpace(scenario.Manifest) == "" {
+				return fmt.Errorf("scenario %s has no manifest", scenario.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported workflow %q in scenario %s, should be one of diagnose, execute, audit or analyze", scenario.Workflow, scenario.Name)
+		}
+
+		for j := range scenario.Fixtures {
+			fixture := &scenario.Fixtures[j]
+			if _, ok := tools.CopilotTools[fixture.Tool]; !ok {
+				return fmt.Errorf("unknown tool %q in the fixtures of scenario %s", fixture.Tool, scenario.Name)
+			}
+			pattern, err := regexp.Compile(fixture.Match)
+			if err != nil {
+				return fmt.Errorf("invalid match %q in scenario %s: %v", fixture.Match, scenario.Name, err)
+			}
+			fixture.pattern = pattern
+		}
+		for _, call := range scenario.Expect.ToolCalls {
+			if _, err := regexp.Compile(call); err != nil {
+				return fmt.Errorf("invalid toolCalls %q in scenario %s: %v", call, scenario.Name, err)
+			}
+		}
+		if scenario.Expect.Severity != "" && !workflows.ValidSeverity(scenario.Expect.Severity) {
+			return fmt.Errorf("unsupported severity %q in scenario %s, should be one of CRITICAL, HIGH, MEDIUM or LOW", scenario.Expect.Severity, scenario.Name)
+		}
+	}
+	return nil
+}
+
+// Run runs every scenario against every model, one at a time. The tools of
+// the registry are replaced by the fixtures of the scenario while it runs,
+// so the agent never reaches a real cluster; tool inputs matching no
+// fixture fail with an error.
+func (s *Suite) Run(models []string, registry map[string]tools.Tool, run Runner) []Result {
+	var results []Result
+	for _, model := range models {
+		for _, scenario := range s.Scenarios {
+			mock := &mock{fixtures: scenario.Fixtures}
+			restore := mock.install(registry)
+
+			start := time.Now()
+			answer, err := run(model, scenario)
+			restore()
+
+			result := Score(scenario.Expect, answer, mock.calls)
+			result.Model = model
+			result.Scenario = scenario.Name
+			result.Answer = answer
+			result.Duration = time.Since(start).Round(time.Millisecond)
+			if err != nil {
+				result.Error = err.Error()
+				result.Passed = false
+				result.Score = 0
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Score checks the answer and the tool calls of a scenario against the
+// expectation. The score is the ratio of passed checks.
+func Score(expect Expectation, answer string, calls []ToolCall) Result {
+	result := Result{ToolCalls: calls}
+	lower := strings.ToLower(answer)
+	for _, s := range expect.Contains {
+		result.Checks = append(result.Checks, Check{Name: fmt.Sprintf("contains %q", s), Passed: strings.Contains(lower, strings.ToLower(s))})
+	}
+	for _, s := range expect.NotContains {
+		result.Checks = append(result.Checks, Check{Name: fmt.Sprintf("does not contain %q", s), Passed: !strings.Contains(lower, strings.ToLower(s))})
+	}
+	if expect.Severity != "" {
+		found := len(workflows.FindingsAtOrAbove(workflows.ParseFindings(answer), expect.Severity)) > 0
+		result.Checks = append(result.Checks, Check{Name: fmt.Sprintf("finding at or above %s", strings.ToUpper(expect.Severity)), Passed: found})
+	}
+	for _, expr := range expect.ToolCalls {
+		pattern, err := regexp.Compile(expr)
+		called := false
+		for _, call := range calls {
+			if err == nil && pattern.MatchString(call.Input) {
+				called = true
+				break
+			}
+		}
+		result.Checks = append(result.Checks, Check{Name: fmt.Sprintf("tool call matching %q", expr), Passed: called})
+	}
+
+	passed := 0
+	for _, check := range result.Checks {
+		if check.Passed {
+			passed++
+		}
+	}
+	result.Score = 1
+	if len(result.Checks) > 0 {
+		result.Score = float64(passed) / float64(len(result.Checks))
+	}
+	result.Passed = passed == len(result.Checks)
+	return result
+}
+
+// Summary is the aggregated outcome of a suite for a model and prompt versions.
+type Summary struct {
+	Model    string  `json:"model"`
+	Prompts  string  `json:"prompts,omitempty"`
+	Passed   int     `json:"passed"`
+	Total    int     `json:"total"`
+	Score    float64 `json:"score"`
+	PassRate float64 `json:"passRate"`
+}
+
+// Summarize aggregates the results per model and prompt versions, in the
+// order of the results.
+func Summarize(results []Result) []Summary {
+	var summaries []Summary
+	index := map[[2]string]int{}
+	for _, result := range results {
+		key := [2]string{result.Model, result.Prompts}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, Summary{Model: result.Model, Prompts: result.Prompts})
+		}
+		summaries[i].Total++
+		summaries[i].Score += result.Score
+		if result.Passed {
+			summaries[i].Passed++
+		}
+	}
+	for i := range summaries {
+		summaries[i].Score /= float64(summaries[i].Total)
+		summaries[i].PassRate = float64(summaries[i].Passed) / float64(summaries[i].Total)
+	}
+	return summaries
+}
+
+// mock serves the fixtures of a scenario in place of the real tools.
+type mock struct {
+	fixtures []Fixture
+	mu       sync.Mutex
+	calls    []ToolCall
+}
+
+// install replaces all the tools of the registry by the mock and returns a
+// function restoring them.
+func (m *mock) install(registry map[string]tools.Tool) func() {
+	saved := make(map[string]tools.Tool, len(registry))
+	for name, tool := range registry {
+		saved[name] = tool
+		registry[name] = m.tool(name)
+	}
+	return func() {
+		for name, tool := range saved {
+			registry[name] = tool
+		}
+	}
+}
+
+func (m *mock) tool(name string) tools.Tool {
+	return func(input string) (string, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.calls = append(m.calls, ToolCall{Tool: name, Input: input})
+
+		for i := range m.fixtures {
+			fixture := &m.fixtures[i]
+			if fixture.Tool != name || !fixture.matches(input) {
+				continue
+			}
+			if fixture.Error != "" {
+				return fixture.Output, errors.New(fixture.Error)
+			}
+			return fixture.Output, nil
+		}
+		return "", fmt.Errorf("no fixture of tool %s matches the input %q", name, input)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package eval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+func TestLoadSuite(t *testing.T) {
+	suite, err := LoadSuite("../../suites/pod-diagnosis.yaml")
+	if err != nil {
+		t.Fatalf("LoadSuite() error = %v", err)
+	}
+	if len(suite.Scenarios) == 0 {
+		t.Fatalf("LoadSuite() returned no scenarios")
+	}
+	for _, scenario := range suite.Scenarios {
+		if scenario.Workflow != WorkflowDiagnose {
+			t.Errorf("scenario %s workflow = %q, want %q", scenario.Name, scenario.Workflow, WorkflowDiagnose)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		suite   Suite
+		wantErr string
+	}{
+		{name: "no scenarios", suite: Suite{}, wantErr: "no scenarios"},
+		{name: "no pod", suite: Suite{Scenarios: []Scenario{{Name: "a"}}}, wantErr: "has no pod"},
+		{name: "unknown workflow", suite: Suite{Scenarios: []Scenario{{Name: "a", Workflow: "fix"}}}, wantErr: "unsupported workflow"},
+		{name: "unknown tool", suite: Suite{Scenarios: []Scenario{{Name: "a", Pod: "web", Fixtures: []Fixture{{Tool: "helm"}}}}}, wantErr: "unknown tool"},
+		{name: "invalid match", suite: Suite{Scenarios: []Scenario{{Name: "a", Pod: "web", Fixtures: []Fixture{{Tool: "kubectl", Match: "("}}}}}, wantErr: "invalid match"},
+		{name: "invalid severity", suite: Suite{Scenarios: []Scenario{{Name: "a", Pod: "web", Expect: Expectation{Severity: "urgent"}}}}, wantErr: "unsupported severity"},
+		{name: "valid", suite: Suite{Scenarios: []Scenario{{Workflow: WorkflowExecute, Instructions: "list pods"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.suite.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	answer := `## 1. Missing ConfigMap
+
+- **Severity**: HIGH
+- **Findings**: configmap "web-config" not found.
+- **How to resolve**: Create the ConfigMap web-config.`
+	calls := []ToolCall{{Tool: "kubectl", Input: "describe pod web -n default"}}
+
+	tests := []struct {
+		name       string
+		expect     Expectation
+		wantScore  float64
+		wantPassed bool
+	}{
+		{name: "no expectations", wantScore: 1, wantPassed: true},
+		{name: "all passed", expect: Expectation{Contains: []string{"WEB-CONFIG"}, NotContains: []string{"OOMKilled"}, Severity: "HIGH", ToolCalls: []string{"describe pod web"}}, wantScore: 1, wantPassed: true},
+		{name: "half passed", expect: Expectation{Contains: []string{"web-config", "secret"}}, wantScore: 0.5},
+		{name: "severity too low", expect: Expectation{Severity: "CRITICAL"}, wantScore: 0},
+		{name: "tool not called", expect: Expectation{ToolCalls: []string{"logs web"}}, wantScore: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Score(tt.expect, answer, calls)
+			if got.Score != tt.wantScore || got.Passed != tt.wantPassed {
+				t.Errorf("Score() = %v (passed %v), want %v (passed %v); checks %v", got.Score, got.Passed, tt.wantScore, tt.wantPassed, got.Checks)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	suite := Suite{Scenarios: []Scenario{
+		{
+			Name: "crashloop",
+			Pod:  "web",
+			Fixtures: []Fixture{
+				{Tool: "kubectl", Match: "get pod web", Output: "web 0/1 CrashLoopBackOff"},
+				{Tool: "kubectl", Match: "logs", Error: "container not found"},
+			},
+			Expect: Expectation{Contains: []string{"CrashLoopBackOff"}},
+		},
+		{Name: "failing", Pod: "api"},
+	}}
+	if err := suite.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	real := func(string) (string, error) { return "real", nil }
+	registry := map[string]tools.Tool{"kubectl": real}
+	results := suite.Run([]string{"model-a", "model-b"}, registry, func(model string, scenario Scenario) (string, error) {
+		if scenario.Name == "failing" {
+			return "", errors.New("LLM unavailable")
+		}
+		if _, err := registry["kubectl"]("logs web"); err == nil {
+			return "", errors.New("logs should fail with the fixture error")
+		}
+		if _, err := registry["kubectl"]("get nodes"); err == nil {
+			return "", errors.New("inputs without fixture should fail")
+		}
+		return registry["kubectl"]("get pod web -n default")
+	})
+
+	if len(results) != 4 {
+		t.Fatalf("Run() returned %d results, want 4", len(results))
+	}
+	if r := results[0]; !r.Passed || r.Model != "model-a" || len(r.ToolCalls) != 3 {
+		t.Errorf("results[0] = %+v, want passed with 3 tool calls", r)
+	}
+	if r := results[1]; r.Passed || r.Error != "LLM unavailable" || r.Score != 0 {
+		t.Errorf("results[1] = %+v, want failed with the runner error", r)
+	}
+	if out, _ := registry["kubectl"]("get pods"); out != "real" {
+		t.Errorf("Run() did not restore the tools, got %q", out)
+	}
+
+	summaries := Summarize(results)
+	if len(summaries) != 2 || summaries[0].Model != "model-a" || summaries[0].Passed != 1 || summaries[0].PassRate != 0.5 {
+		t.Errorf("Summarize() = %+v", summaries)
+	}
+
+	for i := range results {
+		results[i].Model = "model-a"
+		if i%2 == 1 {
+			results[i].Prompts = "diagnose@v2"
+		}
+	}
+	summaries = Summarize(results)
+	if len(summaries) != 2 || summaries[0].Prompts != "" || summaries[0].Passed != 2 || summaries[1].Prompts != "diagnose@v2" || summaries[1].Passed != 0 {
+		t.Errorf("Summarize() of the prompt versions = %+v", summaries)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// requestTimeout is the timeout of the Grafana API requests.
+const requestTimeout = 10 * time.Second
+
+// Annotation is a Grafana annotation (see https://grafana.com/docs/grafana/latest/developers/http_api/annotations/).
+type Annotation struct {
+	// Time is the time of the annotation in milliseconds since the epoch.
+	Time int64 `json:"time"`
+	// TimeEnd makes the annotation a region from Time to TimeEnd (optional).
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// Text is the annotation text (HTML is supported, e.g. for links).
+	Text string `json:"text"`
+	// DashboardUID scopes the annotation to a dashboard (organization-wide if empty).
+	DashboardUID string `json:"dashboardUID,omitempty"`
+}
+
+// Client writes annotations with the Grafana HTTP API.
+type Client struct {
+	// URL is the base URL of Grafana, e.g. https://grafana.example.com.
+	URL string
+	// Token is a service account token (or API key) with the annotations:write permission.
+	Token string
+	// HTTPClient is the client of the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a Client after validating the base URL.
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Grafana URL %q, should be http(s)://<host>[/<path>]", baseURL)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("a Grafana token is required to write annotations (set GRAFANA_TOKEN)")
+	}
+	return &Client{URL: strings.TrimRight(baseURL, "/"), Token: token}, nil
+}
+
+// Annotate creates an annotation and returns its ID.
+func (c *Client) Annotate(ctx context.Context, annotation Annotation) (int64, error) {
+	if annotation.Time == 0 {
+		annotation.Time = time.Now().UnixMilli()
+	}
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/api/annotations", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Grafana annotation: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("failed to create Grafana annotation: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("unexpected response of Grafana: %s", strings.TrimSpace(string(data)))
+	}
+	return result.ID, nil
+}
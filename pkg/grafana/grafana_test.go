@@ -0,0 +1,88 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		url     string
+		token   string
+		wantErr bool
+	}{
+		{url: "https://grafana.example.com/", token: "glsa_token"},
+		{url: "grafana.example.com", token: "glsa_token", wantErr: true},
+		{url: "https://grafana.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			client, err := NewClient(tt.url, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && client.URL != "https://grafana.example.com" {
+				t.Errorf("NewClient().URL = %q, want the URL without trailing slash", client.URL)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	var got Annotation
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grafana/api/annotations" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id": 42, "message": "Annotation added"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/grafana", "glsa_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Annotation{Time: 1700000000000, Tags: []string{"kube-copilot", "diagnose"}, Text: "Image pull error"}
+	id, err := client.Annotate(context.Background(), want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 || auth != "Bearer glsa_token" || !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotate() = %d with %q and %+v, want 42 with the token and %+v", id, auth, got, want)
+	}
+}
+
+func TestAnnotateError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(server.URL, "invalid")
+	if _, err := client.Annotate(context.Background(), Annotation{Text: "test"}); err == nil {
+		t.Errorf("Annotate() should fail on 401")
+	}
+}
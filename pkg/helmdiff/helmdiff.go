@@ -0,0 +1,305 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package helmdiff renders two versions of a Helm release and diffs their manifests.
+package helmdiff
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// Actions of the object changes.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// contextLines is the number of unchanged lines around the changes of a diff.
+const contextLines = 3
+
+var documentSeparator = regexp.MustCompile(`(?m)^---.*$`)
+
+// Source is a version of a release: a revision of the release, or the chart
+// rendered with the given values.
+type Source struct {
+	// Revision is the release revision, or 0 for the current one. It is ignored when Chart is set.
+	Revision int
+	Chart    string
+	Version  string
+	Values   []string
+	Set      []string
+	// ReuseValues renders the chart with the current values of the release,
+	// overridden by Values and Set, similar to "helm upgrade --reuse-values".
+	ReuseValues bool
+}
+
+// String describes the source.
+func (s Source) String() string {
+	if s.Chart == "" {
+		if s.Revision == 0 {
+			return "the current revision"
+		}
+		return fmt.Sprintf("revision %d", s.Revision)
+	}
+
+	var parts []string
+	if s.ReuseValues {
+		parts = append(parts, "the current values")
+	}
+	for _, file := range s.Values {
+		parts = append(parts, file)
+	}
+	if len(s.Set) > 0 {
+		parts = append(parts, "--set "+strings.Join(s.Set, ","))
+	}
+	chart := s.Chart
+	if s.Version != "" {
+		chart += " " + s.Version
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("chart %s with the default values", chart)
+	}
+	return fmt.Sprintf("chart %s with %s", chart, strings.Join(parts, ", "))
+}
+
+// Render returns the manifests of the release for the source.
+func Render(release, namespace string, source Source) (string, error) {
+	if source.Chart == "" {
+		command := fmt.Sprintf("get manifest %s --namespace %s", release, namespace)
+		if source.Revision > 0 {
+			command += fmt.Sprintf(" --revision %d", source.Revision)
+		}
+		return tools.Helm(command)
+	}
+
+	args := []string{"template", release, source.Chart, "--namespace", namespace}
+	if source.Version != "" {
+		args = append(args, "--version", source.Version)
+	}
+	if source.ReuseValues {
+		values, err := tools.Helm(fmt.Sprintf("get values %s --namespace %s --output yaml", release, namespace))
+		if err != nil {
+			return "", fmt.Errorf("failed to get the values of release %s: %v", release, err)
+		}
+		file, err := os.CreateTemp("", "kube-copilot-values-*.yaml")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(file.Name())
+		if _, err := file.WriteString(values); err != nil {
+			file.Close()
+			return "", err
+		}
+		file.Close()
+		args = append(args, "--values", file.Name())
+	}
+	for _, file := range source.Values {
+		args = append(args, "--values", file)
+	}
+	for _, value := range source.Set {
+		args = append(args, "--set", value)
+	}
+	return tools.Helm(strings.Join(args, " "))
+}
+
+// Object is a Kubernetes object of a rendered manifest.
+type Object struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Manifest is the object manifest with the Secret values and credentials redacted.
+	Manifest string
+	raw      string
+}
+
+// Key identifies the object in the release.
+func (o Object) Key() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s/%s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// ParseManifest splits a multi-document manifest into its objects.
+// Documents without a kind, such as comments or helm warnings, are skipped.
+func ParseManifest(manifest string) []Object {
+	var objects []Object
+	for _, document := range documentSeparator.Split(manifest, -1) {
+		document = strings.TrimSpace(document)
+		if document == "" {
+			continue
+		}
+
+		var meta struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(document), &meta); err != nil || meta.Kind == "" {
+			continue
+		}
+		objects = append(objects, Object{
+			Kind:      meta.Kind,
+			Namespace: meta.Metadata.Namespace,
+			Name:      meta.Metadata.Name,
+			Manifest:  utils.Redact(document),
+			raw:       document,
+		})
+	}
+	return objects
+}
+
+// Change is an object added, removed or changed between two versions of a release.
+type Change struct {
+	Object string `json:"object"`
+	Action string `json:"action"`
+	// Diff is the unified diff of the object manifests.
+	Diff string `json:"diff"`
+}
+
+// Diff returns the changes between the old and new objects, ordered by object.
+func Diff(oldObjects, newObjects []Object) []Change {
+	oldByKey := make(map[string]Object, len(oldObjects))
+	for _, o := range oldObjects {
+		oldByKey[o.Key()] = o
+	}
+	newByKey := make(map[string]Object, len(newObjects))
+	for _, o := range newObjects {
+		newByKey[o.Key()] = o
+	}
+
+	var changes []Change
+	for key, o := range oldByKey {
+		n, ok := newByKey[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Object: key, Action: Removed, Diff: unifiedDiff(o.Manifest, "")})
+		case n.raw != o.raw:
+			diff := unifiedDiff(o.Manifest, n.Manifest)
+			if n.Manifest == o.Manifest {
+				diff = "(only redacted values changed)"
+			}
+			changes = append(changes, Change{Object: key, Action: Changed, Diff: diff})
+		}
+	}
+	for key, n := range newByKey {
+		if _, ok := oldByKey[key]; !ok {
+			changes = append(changes, Change{Object: key, Action: Added, Diff: unifiedDiff("", n.Manifest)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Object < changes[j].Object })
+	return changes
+}
+
+// Format renders the changes as diffs, one section per object.
+func Format(changes []Change) string {
+	if len(changes) == 0 {
+		return "No changes."
+	}
+
+	sections := make([]string, 0, len(changes))
+	for _, change := range changes {
+		sections = append(sections, fmt.Sprintf("# %s (%s)\n%s", change.Object, change.Action, change.Diff))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// unifiedDiff returns the line diff of a and b. The unchanged lines further
+// than contextLines from a change are dropped, with "@@" between the hunks.
+func unifiedDiff(a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var result []string
+	for i, line := range lines {
+		if line[0] != ' ' || nearChange(lines, i) {
+			result = append(result, line)
+		} else if len(result) > 0 && result[len(result)-1] != "@@" {
+			result = append(result, "@@")
+		}
+	}
+	if len(result) > 0 && result[len(result)-1] == "@@" {
+		result = result[:len(result)-1]
+	}
+	return strings.Join(result, "\n")
+}
+
+// nearChange returns true if a changed line is within contextLines of lines[i].
+func nearChange(lines []string, i int) bool {
+	for j := max(0, i-contextLines); j <= min(len(lines)-1, i+contextLines); j++ {
+		if lines[j][0] != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// diffLines returns the lines of a and b prefixed by "-" (removed), "+"
+// (added) or " " (unchanged), from their longest common subsequence.
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
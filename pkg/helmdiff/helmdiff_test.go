@@ -0,0 +1,136 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helmdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+const oldManifest = `WARNING: Kubernetes configuration file is group-readable. This is insecure.
+---
+# Source: web/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+data:
+  password: b2xk
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.26
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+`
+
+const newManifest = `---
+# Source: web/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+data:
+  password: bmV3
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+func TestParseManifest(t *testing.T) {
+	objects := ParseManifest(oldManifest)
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key())
+	}
+	if got, want := strings.Join(keys, ","), "Secret/web,Deployment/prod/web,PersistentVolumeClaim/data"; got != want {
+		t.Fatalf("ParseManifest() = %s, want %s", got, want)
+	}
+	if strings.Contains(objects[0].Manifest, "b2xk") {
+		t.Errorf("ParseManifest() did not redact the Secret: %s", objects[0].Manifest)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changes := Diff(ParseManifest(oldManifest), ParseManifest(newManifest))
+	want := []struct{ object, action, diff string }{
+		{object: "Deployment/prod/web", action: Changed, diff: "     spec:\n       containers:\n         - name: web\n-          image: nginx:1.26\n+          image: nginx:1.27"},
+		{object: "PersistentVolumeClaim/data", action: Removed, diff: "-apiVersion: v1\n-kind: PersistentVolumeClaim\n-metadata:\n-  name: data"},
+		{object: "Secret/web", action: Changed, diff: "(only redacted values changed)"},
+		{object: "Service/web", action: Added, diff: "+apiVersion: v1\n+kind: Service\n+metadata:\n+  name: web"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() = %+v, want %d changes", changes, len(want))
+	}
+	for i, w := range want {
+		if changes[i].Object != w.object || changes[i].Action != w.action || changes[i].Diff != w.diff {
+			t.Errorf("Diff()[%d] = %+v, want %+v", i, changes[i], w)
+		}
+	}
+
+	if got := Format(Diff(ParseManifest(oldManifest), ParseManifest(oldManifest))); got != "No changes." {
+		t.Errorf("Format() = %q for identical manifests", got)
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	tests := []struct {
+		source Source
+		want   string
+	}{
+		{source: Source{}, want: "the current revision"},
+		{source: Source{Revision: 3}, want: "revision 3"},
+		{source: Source{Chart: "bitnami/nginx"}, want: "chart bitnami/nginx with the default values"},
+		{
+			source: Source{Chart: "bitnami/nginx", Version: "18.0.0", Values: []string{"prod.yaml"}, Set: []string{"replicaCount=3"}, ReuseValues: true},
+			want:   "chart bitnami/nginx 18.0.0 with the current values, prod.yaml, --set replicaCount=3",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.source.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
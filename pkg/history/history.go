@@ -0,0 +1,149 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+)
+
+// ErrNotFound is returned when loading an entry that does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Entry is a persisted run of a kube-copilot command.
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	// Args are the command line arguments used to replay the run.
+	Args    []string `json:"args"`
+	Model   string   `json:"model"`
+	Context string   `json:"context,omitempty"`
+	// Title and Summary describe the run in the history listing and seed
+	// the context of the runs continuing it.
+	Title   string `json:"title,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	// Prompts are the versions of the prompts used by the run, by name.
+	Prompts map[string]string `json:"prompts,omitempty"`
+	Answer  string            `json:"answer"`
+	// Result is the structured result of the run (as printed by --output json).
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// Store persists the entries as JSON files in a directory.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns the default history directory (~/.kube-copilot/history).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "history"), nil
+}
+
+// NewStore creates a Store in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Save persists the entry. The ID and time are set if empty.
+// Credentials in the answer and result are redacted.
+func (s *Store) Save(entry *Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.ID == "" {
+		entry.ID = strings.ReplaceAll(entry.Time.Format("20060102-150405.000"), ".", "-")
+	}
+	entry.Title = utils.Redact(entry.Title)
+	entry.Summary = utils.Redact(entry.Summary)
+	entry.Answer = utils.Redact(entry.Answer)
+	if entry.Result != nil {
+		entry.Result = json.RawMessage(utils.Redact(string(entry.Result)))
+		if !json.Valid(entry.Result) {
+			// Never store an unredacted result; the answer is kept anyway.
+			entry.Result = nil
+		}
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(entry.ID), data, 0600)
+}
+
+// Load reads the entry with the given ID.
+func (s *Store) Load(id string) (*Entry, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid history ID %q", id)
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("history %s %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %v", id, err)
+	}
+	return &entry, nil
+}
+
+// List returns all the entries, newest first.
+func (s *Store) List() ([]*Entry, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(files))
+	for _, file := range files {
+		entry, err := s.Load(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			// Skip broken entries rather than failing the whole listing.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	return entries, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
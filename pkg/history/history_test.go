@@ -0,0 +1,59 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Now()
+	older := &Entry{Time: now.Add(-time.Hour), Command: "diagnose", Args: []string{"diagnose", "nginx"}, Answer: "older"}
+	newer := &Entry{Time: now, Command: "execute", Args: []string{"execute", "list pods"}, Answer: "newer"}
+	for _, entry := range []*Entry{older, newer} {
+		if err := store.Save(entry); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if entry.ID == "" {
+			t.Fatalf("Save() did not set the ID")
+		}
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Answer != "newer" || entries[1].Answer != "older" {
+		t.Errorf("List() = %+v, want newest first", entries)
+	}
+
+	entry, err := store.Load(older.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if entry.Command != "diagnose" || len(entry.Args) != 2 {
+		t.Errorf("Load() = %+v, want %+v", entry, older)
+	}
+
+	if _, err := store.Load("../config"); err == nil {
+		t.Errorf("Load() with path separators should fail")
+	}
+	if _, err := store.Load("missing"); err == nil {
+		t.Errorf("Load() of missing entry should fail")
+	}
+}
@@ -18,9 +18,13 @@ package kubernetes
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"fmt"
 	"io"
-	"path/filepath"
+	"os"
+	"strings"
 
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -32,87 +36,224 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+)
+
+var (
+	// Kubeconfig is the path of the kubeconfig file. When empty, the files
+	// in KUBECONFIG or ~/.kube/config are used, following kubectl conventions.
+	Kubeconfig string
+	// Context is the kubeconfig context to use. When empty, the current context is used.
+	Context string
+	// Offline rejects all the API server accesses.
+	Offline bool
 )
 
 // GetKubeConfig gets kubeconfig.
 func GetKubeConfig() (*rest.Config, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		kubeconfig := filepath.Join(homedir.HomeDir(), ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, err
+	if Offline {
+		return nil, errdefs.ErrOffline
+	}
+	if err := authorizeCluster(); err != nil {
+		return nil, err
+	}
+
+	// In-cluster config is preferred unless a kubeconfig is explicitly selected.
+	if Kubeconfig == "" && Context == "" && os.Getenv("KUBECONFIG") == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
 		}
 	}
 
-	return config, nil
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 }
 
-// ApplyYaml applies the manifests into Kubernetes cluster.
-func ApplyYaml(manifests string) error {
-	config, err := GetKubeConfig()
+// CurrentContext returns the name of the selected kubeconfig context, or "" if
+// there is none (e.g. in-cluster).
+func CurrentContext() string {
+	if Context != "" {
+		return Context
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
 	if err != nil {
-		return err
+		return ""
 	}
+	return config.CurrentContext
+}
 
-	// Create a new clientset which include all needed client APIs
-	clientset, err := kubernetes.NewForConfig(config)
+// KubectlArgs returns the global kubectl arguments matching the selected kubeconfig and context.
+func KubectlArgs() []string {
+	var args []string
+	if Kubeconfig != "" {
+		args = append(args, "--kubeconfig", Kubeconfig)
+	}
+	if Context != "" {
+		args = append(args, "--context", Context)
+	}
+	return args
+}
+
+// WriteContextKubeconfig writes a copy of the kubeconfig with the selected
+// context as its current context, for the processes which follow KUBECONFIG
+// but have no --context flag (e.g. the Kubernetes client of python scripts).
+// It returns the path of the copy, "" if no context is selected, and a
+// function removing it.
+func WriteContextKubeconfig() (string, func(), error) {
+	if Context == "" {
+		return "", func() {}, nil
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	dynamicclient, err := dynamic.NewForConfig(config)
+	if _, ok := config.Contexts[Context]; !ok {
+		return "", nil, fmt.Errorf("context %q not found in the kubeconfig", Context)
+	}
+	config.CurrentContext = Context
+
+	file, err := os.CreateTemp("", "kube-copilot-kubeconfig-*")
+	if err != nil {
+		return "", nil, err
+	}
+	remove := func() { os.Remove(file.Name()) }
+	file.Close()
+	if err := clientcmd.WriteToFile(config, file.Name()); err != nil {
+		remove()
+		return "", nil, err
+	}
+	return file.Name(), remove, nil
+}
+
+// ApplyYaml applies the manifests into Kubernetes cluster.
+func ApplyYaml(manifests string) error {
+	return applyYaml(manifests, false)
+}
+
+// ValidateYaml validates the manifests with a server-side dry-run apply,
+// so that schema and admission errors are reported without changing the cluster.
+func ValidateYaml(manifests string) error {
+	return applyYaml(manifests, true)
+}
+
+func applyYaml(manifests string, dryRun bool) error {
+	objects, err := ParseManifests(manifests)
 	if err != nil {
 		return err
 	}
 
-	// Decode the yaml file into a Kubernetes object
+	for _, obj := range objects {
+		dri, err := objectInterface(obj, true)
+		if err != nil {
+			return err
+		}
+
+		options := metav1.ApplyOptions{FieldManager: "application/apply-patch"}
+		if dryRun {
+			options.DryRun = []string{metav1.DryRunAll}
+		}
+		if _, err := dri.Apply(context.Background(), obj.GetName(), obj, options); err != nil {
+			return fmt.Errorf("%s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// SummarizeManifests describes the manifests by the kind, namespace and name
+// of their objects, and their SHA-256 digest. The audit log records it
+// instead of the manifests, which may hold Secret data.
+func SummarizeManifests(manifests string) string {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifests)))
+	objects, err := ParseManifests(manifests)
+	if err != nil || len(objects) == 0 {
+		return digest
+	}
+	names := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		names = append(names, fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName()))
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(names, ", "), digest)
+}
+
+// ParseManifests decodes the YAML or JSON manifests into objects. The
+// objects without a namespace are set to the default namespace.
+func ParseManifests(manifests string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
 	decode := yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(manifests)), 100)
 	for {
 		var rawObj runtime.RawExtension
-		if err = decode.Decode(&rawObj); err != nil {
+		if err := decode.Decode(&rawObj); err != nil {
 			if err == io.EOF {
 				break
 			}
-			return err
+			return nil, err
+		}
+		if len(bytes.TrimSpace(rawObj.Raw)) == 0 || string(bytes.TrimSpace(rawObj.Raw)) == "null" {
+			// Empty documents, e.g. a trailing "---".
+			continue
 		}
 
-		obj, gvk, err := yamlserializer.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(rawObj.Raw, nil, nil)
+		obj, _, err := yamlserializer.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(rawObj.Raw, nil, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
 		unstructuredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		unstructuredObj := &unstructured.Unstructured{Object: unstructuredMap}
 		if unstructuredObj.GetNamespace() == "" {
 			unstructuredObj.SetNamespace("default")
 		}
+		objects = append(objects, unstructuredObj)
+	}
+	return objects, nil
+}
 
-		grs, err := restmapper.GetAPIGroupResources(clientset.Discovery())
-		if err != nil {
-			return err
-		}
+// objectInterface returns the dynamic client of the object's resource after
+// checking the access (write or read) to its namespace with Tenancy.
+func objectInterface(obj *unstructured.Unstructured, write bool) (dynamic.ResourceInterface, error) {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
 
-		mapping, err := restmapper.NewDiscoveryRESTMapper(grs).RESTMapping(gvk.GroupKind(), gvk.Version)
-		if err != nil {
-			return err
-		}
+	// Create a new clientset which include all needed client APIs
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicclient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
-		var dri dynamic.ResourceInterface
-		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-			dri = dynamicclient.Resource(mapping.Resource).Namespace(unstructuredObj.GetNamespace())
-		} else {
-			dri = dynamicclient.Resource(mapping.Resource)
-		}
+	grs, err := restmapper.GetAPIGroupResources(clientset.Discovery())
+	if err != nil {
+		return nil, clusterError(err)
+	}
 
-		if _, err := dri.Apply(context.Background(), unstructuredObj.GetName(), unstructuredObj, metav1.ApplyOptions{FieldManager: "application/apply-patch"}); err != nil {
-			return err
-		}
+	gvk := obj.GroupVersionKind()
+	mapping, err := restmapper.NewDiscoveryRESTMapper(grs).RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if err := authorizeNamespace(obj.GetNamespace()); err != nil {
+			return nil, err
+		}
+		return dynamicclient.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+	if err := authorizeClusterScoped(write); err != nil {
+		return nil, err
+	}
+	return dynamicclient.Resource(mapping.Resource), nil
 }
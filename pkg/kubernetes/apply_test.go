@@ -0,0 +1,102 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestSummarizeManifests(t *testing.T) {
+	manifests := `apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+  namespace: web
+stringData:
+  password: hunter2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`
+	got := SummarizeManifests(manifests)
+	if !strings.HasPrefix(got, "Secret/web/creds, Deployment/default/nginx (sha256:") {
+		t.Errorf("SummarizeManifests() = %q, want the objects and the digest", got)
+	}
+	if strings.Contains(got, "hunter2") {
+		t.Errorf("SummarizeManifests() = %q, should not contain the manifests", got)
+	}
+	if got := SummarizeManifests("{invalid"); !strings.HasPrefix(got, "sha256:") || strings.Contains(got, "invalid") {
+		t.Errorf("SummarizeManifests(invalid) = %q, want the digest only", got)
+	}
+}
+
+func TestWriteContextKubeconfig(t *testing.T) {
+	defer func(kubeconfig, context string) { Kubeconfig, Context = kubeconfig, context }(Kubeconfig, Context)
+	Kubeconfig = filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(Kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: https://dev.example.com}
+- name: prod
+  cluster: {server: https://prod.example.com}
+contexts:
+- name: dev
+  context: {cluster: dev, user: admin}
+- name: prod
+  context: {cluster: prod, user: admin}
+users:
+- name: admin
+  user: {token: secret}
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	Context = ""
+	if path, _, err := WriteContextKubeconfig(); err != nil || path != "" {
+		t.Errorf("WriteContextKubeconfig() = %q, %v, want no copy without --context", path, err)
+	}
+
+	Context = "prod"
+	path, remove, err := WriteContextKubeconfig()
+	if err != nil {
+		t.Fatalf("WriteContextKubeconfig() error = %v", err)
+	}
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if config.CurrentContext != "prod" {
+		t.Errorf("current context = %q, want prod", config.CurrentContext)
+	}
+	remove()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the kubeconfig copy %s was not removed", path)
+	}
+
+	Context = "staging"
+	if _, _, err := WriteContextKubeconfig(); err == nil {
+		t.Errorf("WriteContextKubeconfig() should fail for an unknown context")
+	}
+}
@@ -30,62 +30,94 @@ import (
 
 // GetYaml gets the yaml of a resource.
 func GetYaml(resource, name, namespace string) (string, error) {
-	config, err := GetKubeConfig()
+	dri, err := resourceInterface(resource, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := dri.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(res.Object)
 	if err != nil {
 		return "", err
 	}
 
+	return string(data), nil
+}
+
+// ListResourceNames lists the names of the given resource type in the namespace.
+// The namespace is ignored for cluster-scoped resources.
+func ListResourceNames(resource, namespace string) ([]string, error) {
+	dri, err := resourceInterface(resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dri.List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	return names, nil
+}
+
+// resourceInterface returns the dynamic client for the given resource type (e.g. pod or deployments).
+func resourceInterface(resource, namespace string) (dynamic.ResourceInterface, error) {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a new clientset which include all needed client APIs
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	dynamicclient, err := dynamic.NewForConfig(config)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	grs, err := restmapper.GetAPIGroupResources(clientset.Discovery())
 	if err != nil {
-		return "", err
+		return nil, clusterError(err)
 	}
 
 	mapper := restmapper.NewDiscoveryRESTMapper(grs)
 	gvks, err := mapper.KindsFor(schema.GroupVersionResource{Resource: resource})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(gvks) == 0 {
-		return "", fmt.Errorf("no kind found for %s", resource)
+		return nil, fmt.Errorf("no kind found for %s", resource)
 	}
 
 	gvk := gvks[0]
-	mapping, err := restmapper.NewDiscoveryRESTMapper(grs).RESTMapping(gvk.GroupKind(), gvk.Version)
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var dri dynamic.ResourceInterface
 	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
 		if namespace == "" {
 			namespace = "default"
 		}
-		dri = dynamicclient.Resource(mapping.Resource).Namespace(namespace)
-	} else {
-		dri = dynamicclient.Resource(mapping.Resource)
-	}
-
-	res, err := dri.Get(context.Background(), name, metav1.GetOptions{})
-	if err != nil {
-		return "", err
+		if err := authorizeNamespace(namespace); err != nil {
+			return nil, err
+		}
+		return dynamicclient.Resource(mapping.Resource).Namespace(namespace), nil
 	}
-
-	data, err := yaml.Marshal(res.Object)
-	if err != nil {
-		return "", err
+	if err := authorizeClusterScoped(false); err != nil {
+		return nil, err
 	}
-
-	return string(data), nil
+	return dynamicclient.Resource(mapping.Resource), nil
 }
@@ -0,0 +1,279 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Statuses of the cluster health checks.
+const (
+	ClusterHealthy      = "ok"
+	ClusterUnauthorized = "unauthorized"
+	ClusterUnreachable  = "unreachable"
+)
+
+// healthCheckTimeout bounds each request of a cluster health check.
+const healthCheckTimeout = 10 * time.Second
+
+// ClusterHealth is the result of the health check of a cluster.
+type ClusterHealth struct {
+	// Cluster is the kubeconfig context ("" for the in-cluster configuration).
+	Cluster string `json:"cluster"`
+	Status  string `json:"status"`
+	// Version is the version of the API server.
+	Version   string    `json:"version,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// LastHealthyAt is the time of the last successful check, if any.
+	LastHealthyAt *time.Time `json:"lastHealthyAt,omitempty"`
+	// Failures is the number of consecutive failed checks.
+	Failures int `json:"failures"`
+	// Checks and FailedChecks count the checks since the monitor started.
+	Checks       int `json:"checks"`
+	FailedChecks int `json:"failedChecks"`
+}
+
+// Err returns an error wrapping errdefs.ErrClusterUnreachable if the cluster isn't healthy.
+func (h ClusterHealth) Err() error {
+	switch h.Status {
+	case ClusterHealthy:
+		return nil
+	case ClusterUnauthorized:
+		return fmt.Errorf("%w: the credentials of cluster %s are rejected: %s", errdefs.ErrClusterUnreachable, displayCluster(h.Cluster), h.Error)
+	default:
+		return fmt.Errorf("%w: cluster %s: %s", errdefs.ErrClusterUnreachable, displayCluster(h.Cluster), h.Error)
+	}
+}
+
+// ListClusters lists the kubeconfig contexts accessible to the user, or the
+// in-cluster configuration ("") if there is no kubeconfig context.
+func ListClusters() ([]string, error) {
+	if Offline {
+		return nil, errdefs.ErrOffline
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Contexts) == 0 {
+		return []string{""}, nil
+	}
+
+	var clusters []string
+	for name := range config.Contexts {
+		if Tenancy != nil && Tenancy.CheckCluster(name) != nil {
+			continue
+		}
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// CheckCluster checks that the API server of the cluster ("" for the selected
+// one) is reachable and accepts the credentials.
+func CheckCluster(ctx context.Context, cluster string) ClusterHealth {
+	start := time.Now()
+	health := ClusterHealth{Cluster: cluster, CheckedAt: start}
+	config, err := kubeConfigFor(cluster)
+	if err == nil {
+		config = rest.CopyConfig(config)
+		config.Timeout = healthCheckTimeout
+		var client kubernetes.Interface
+		if client, err = kubernetes.NewForConfig(config); err == nil {
+			checkClient(ctx, client, &health)
+		}
+	}
+	if err != nil {
+		health.Status, health.Error = ClusterUnreachable, err.Error()
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+	return health
+}
+
+// EnsureReachable returns an error if the selected cluster can't be used,
+// so that the agents don't run against an unreachable cluster.
+func EnsureReachable(ctx context.Context) error {
+	if Offline {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return CheckCluster(ctx, Context).Err()
+}
+
+// kubeConfigFor returns the client configuration of the kubeconfig context ("" for the selected one).
+func kubeConfigFor(cluster string) (*rest.Config, error) {
+	if cluster == "" {
+		return GetKubeConfig()
+	}
+	if Offline {
+		return nil, errdefs.ErrOffline
+	}
+	if Tenancy != nil {
+		if err := Tenancy.CheckCluster(cluster); err != nil {
+			return nil, err
+		}
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cluster}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// checkClient checks the API server reachability with its version, and the
+// credentials with a self access review: the version may be served to
+// anonymous users, while any authenticated user may review its own access.
+func checkClient(ctx context.Context, client kubernetes.Interface, health *ClusterHealth) {
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		health.Status, health.Error = healthStatus(err), err.Error()
+		return
+	}
+	health.Version = version.GitVersion
+
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		},
+	}
+	if _, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{}); err != nil && !apierrors.IsForbidden(err) {
+		health.Status, health.Error = healthStatus(err), err.Error()
+		return
+	}
+	health.Status = ClusterHealthy
+}
+
+// healthStatus returns the status of a cluster whose check failed with err.
+func healthStatus(err error) string {
+	if apierrors.IsUnauthorized(err) {
+		return ClusterUnauthorized
+	}
+	return ClusterUnreachable
+}
+
+// displayCluster returns the name of the cluster in the messages.
+func displayCluster(cluster string) string {
+	if cluster == "" {
+		return "(in-cluster)"
+	}
+	return cluster
+}
+
+// HealthMonitor periodically checks the credentials and the API servers of the clusters.
+type HealthMonitor struct {
+	// Interval is the period of the checks.
+	Interval time.Duration
+	// Clusters lists the clusters to check (ListClusters by default).
+	Clusters func() ([]string, error)
+	// Check checks a cluster (CheckCluster by default).
+	Check func(ctx context.Context, cluster string) ClusterHealth
+
+	lock     sync.RWMutex
+	statuses map[string]ClusterHealth
+}
+
+// NewHealthMonitor returns a monitor checking the clusters every interval.
+func NewHealthMonitor(interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		Interval: interval,
+		Clusters: ListClusters,
+		Check:    CheckCluster,
+		statuses: map[string]ClusterHealth{},
+	}
+}
+
+// Run checks the clusters every Interval until ctx is done.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll checks all the clusters concurrently and updates their statuses.
+// The clusters no longer listed are forgotten.
+func (m *HealthMonitor) CheckAll(ctx context.Context) error {
+	clusters, err := m.Clusters()
+	if err != nil {
+		return err
+	}
+
+	results := make([]ClusterHealth, len(clusters))
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster string) {
+			defer wg.Done()
+			results[i] = m.Check(ctx, cluster)
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	statuses := make(map[string]ClusterHealth, len(results))
+	for _, health := range results {
+		previous := m.statuses[health.Cluster]
+		health.Checks = previous.Checks + 1
+		health.FailedChecks = previous.FailedChecks
+		health.LastHealthyAt = previous.LastHealthyAt
+		if health.Status == ClusterHealthy {
+			checkedAt := health.CheckedAt
+			health.LastHealthyAt = &checkedAt
+		} else {
+			health.Failures = previous.Failures + 1
+			health.FailedChecks++
+		}
+		statuses[health.Cluster] = health
+	}
+	m.statuses = statuses
+	return nil
+}
+
+// Statuses returns the last health checks of the clusters, sorted by name.
+func (m *HealthMonitor) Statuses() []ClusterHealth {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	statuses := make([]ClusterHealth, 0, len(m.statuses))
+	for _, health := range m.statuses {
+		statuses = append(statuses, health)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Cluster < statuses[j].Cluster })
+	return statuses
+}
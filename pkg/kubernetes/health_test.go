@@ -0,0 +1,113 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckClient(t *testing.T) {
+	reviews := schema.GroupResource{Group: "authorization.k8s.io", Resource: "selfsubjectaccessreviews"}
+	tests := []struct {
+		name       string
+		verb       string
+		resource   string
+		err        error
+		wantStatus string
+	}{
+		{name: "healthy", wantStatus: ClusterHealthy},
+		{name: "unreachable", verb: "get", resource: "version", err: errors.New("dial tcp 10.0.0.1:443: connect: connection refused"), wantStatus: ClusterUnreachable},
+		{name: "expired token", verb: "get", resource: "version", err: apierrors.NewUnauthorized("token has expired"), wantStatus: ClusterUnauthorized},
+		{name: "anonymous version", verb: "create", resource: "selfsubjectaccessreviews", err: apierrors.NewUnauthorized("Unauthorized"), wantStatus: ClusterUnauthorized},
+		{name: "forbidden review", verb: "create", resource: "selfsubjectaccessreviews", err: apierrors.NewForbidden(reviews, "", errors.New("denied")), wantStatus: ClusterHealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.30.2"}
+			if tt.err != nil {
+				client.PrependReactor(tt.verb, tt.resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.err
+				})
+			}
+
+			health := ClusterHealth{Cluster: "prod"}
+			checkClient(context.Background(), client, &health)
+			if health.Status != tt.wantStatus {
+				t.Fatalf("checkClient() status = %q (%s), want %q", health.Status, health.Error, tt.wantStatus)
+			}
+			if tt.wantStatus == ClusterHealthy && health.Version != "v1.30.2" {
+				t.Errorf("checkClient() version = %q, want v1.30.2", health.Version)
+			}
+			if err := health.Err(); (err != nil) != (tt.wantStatus != ClusterHealthy) || (err != nil && !errors.Is(err, errdefs.ErrClusterUnreachable)) {
+				t.Errorf("Err() = %v", err)
+			}
+		})
+	}
+}
+
+func TestHealthMonitor(t *testing.T) {
+	status := map[string]string{"prod": ClusterHealthy, "staging": ClusterUnreachable}
+	clusters := []string{"staging", "prod"}
+	monitor := NewHealthMonitor(time.Minute)
+	monitor.Clusters = func() ([]string, error) { return clusters, nil }
+	monitor.Check = func(ctx context.Context, cluster string) ClusterHealth {
+		return ClusterHealth{Cluster: cluster, Status: status[cluster], CheckedAt: time.Now()}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := monitor.CheckAll(context.Background()); err != nil {
+			t.Fatalf("CheckAll() error = %v", err)
+		}
+	}
+	status["staging"] = ClusterHealthy
+	status["prod"] = ClusterUnauthorized
+	if err := monitor.CheckAll(context.Background()); err != nil {
+		t.Fatalf("CheckAll() error = %v", err)
+	}
+
+	statuses := monitor.Statuses()
+	if len(statuses) != 2 || statuses[0].Cluster != "prod" || statuses[1].Cluster != "staging" {
+		t.Fatalf("Statuses() = %+v, want prod and staging", statuses)
+	}
+	prod, staging := statuses[0], statuses[1]
+	if prod.Status != ClusterUnauthorized || prod.Failures != 1 || prod.Checks != 3 || prod.FailedChecks != 1 || prod.LastHealthyAt == nil {
+		t.Errorf("prod = %+v, want 1 failure out of 3 checks after being healthy", prod)
+	}
+	if staging.Status != ClusterHealthy || staging.Failures != 0 || staging.FailedChecks != 2 || staging.LastHealthyAt == nil {
+		t.Errorf("staging = %+v, want healthy again after 2 failed checks", staging)
+	}
+
+	clusters = []string{"prod"}
+	if err := monitor.CheckAll(context.Background()); err != nil {
+		t.Fatalf("CheckAll() error = %v", err)
+	}
+	if statuses := monitor.Statuses(); len(statuses) != 1 || statuses[0].Failures != 2 {
+		t.Errorf("Statuses() = %+v, want only prod with 2 consecutive failures", statuses)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KubectlCommand is a parsed kubectl command line.
+type KubectlCommand struct {
+	// Args are the positional arguments: the command (e.g. get, or rollout
+	// status) followed by the resources and their names.
+	Args []string
+	// Flags are the values of the flags by their long names (e.g. --namespace
+	// for -n), "true" for the boolean flags.
+	Flags map[string]string
+
+	// unknownAt is the number of positional arguments preceding the first
+	// flag which may or may not take a value, -1 if there is none.
+	unknownAt int
+}
+
+// kubectlShorthands are the long names of the kubectl flag shorthands.
+var kubectlShorthands = map[string]string{
+	"-n": "--namespace", "-o": "--output", "-l": "--selector", "-c": "--container",
+	"-f": "--filename", "-k": "--kustomize", "-s": "--server", "-v": "--v",
+	"-L": "--label-columns", "-A": "--all-namespaces", "-w": "--watch",
+	"-R": "--recursive", "-i": "--stdin", "-t": "--tty", "-h": "--help", "-q": "--quiet",
+}
+
+// kubectlCommandShorthands are the shorthands whose meaning depends on the kubectl command.
+var kubectlCommandShorthands = map[string]map[string]string{
+	"logs":  {"-f": "--follow", "-p": "--previous"},
+	"patch": {"-p": "--patch"},
+}
+
+// kubectlValueFlags are the kubectl flags taking a value, which may be
+// separated from the flag (e.g. "-n default" or "--namespace default").
+var kubectlValueFlags = map[string]bool{
+	// Global flags.
+	"--namespace": true, "--context": true, "--kubeconfig": true, "--cluster": true, "--user": true,
+	"--server": true, "--as": true, "--as-group": true, "--as-uid": true, "--token": true,
+	"--username": true, "--password": true, "--cache-dir": true, "--certificate-authority": true,
+	"--client-certificate": true, "--client-key": true, "--tls-server-name": true,
+	"--request-timeout": true, "--v": true, "--vmodule": true, "--log-flush-frequency": true,
+	"--profile": true, "--profile-output": true,
+	// Flags of the commands.
+	"--output": true, "--selector": true, "--container": true, "--filename": true, "--kustomize": true,
+	"--field-selector": true, "--label-columns": true, "--sort-by": true, "--template": true,
+	"--chunk-size": true, "--raw": true, "--subresource": true, "--since": true, "--since-time": true,
+	"--tail": true, "--limit-bytes": true, "--max-log-requests": true, "--pod-running-timeout": true,
+	"--for": true, "--timeout": true, "--patch": true, "--type": true, "--image": true,
+	"--replicas": true, "--port": true, "--grace-period": true, "--cascade": true,
+	"--revision": true, "--to-revision": true, "--resource-version": true,
+}
+
+// kubectlBoolFlags are the kubectl flags without a value (or with an attached one, e.g. --watch=true).
+var kubectlBoolFlags = map[string]bool{
+	// Global flags.
+	"--insecure-skip-tls-verify": true, "--match-server-version": true, "--warnings-as-errors": true,
+	"--disable-compression": true, "--help": true,
+	// Flags of the commands.
+	"--all-namespaces": true, "--watch": true, "--watch-only": true, "--show-labels": true,
+	"--show-kind": true, "--no-headers": true, "--ignore-not-found": true, "--recursive": true,
+	"--previous": true, "--follow": true, "--timestamps": true, "--all-containers": true,
+	"--prefix": true, "--stdin": true, "--tty": true, "--quiet": true, "--overwrite": true,
+	"--local": true, "--all": true, "--force": true, "--dry-run": true, "--show-managed-fields": true,
+	"--server-side": true, "--force-conflicts": true, "--containers": true, "--use-protocol-buffers": true,
+	"--sum": true, "--show-events": true, "--list": true, "--ignore-daemonsets": true,
+	"--delete-emptydir-data": true, "--output-watch-events": true,
+}
+
+// ParseKubectl parses a kubectl command line, with or without its kubectl
+// prefix. The flags of unknown arity are assumed not to take a value, and
+// Command reports when this makes the command itself ambiguous.
+func ParseKubectl(command string) (*KubectlCommand, error) {
+	fields := strings.Fields(command)
+	if len(fields) > 0 && fields[0] == "kubectl" {
+		fields = fields[1:]
+	}
+
+	cmd := &KubectlCommand{Flags: map[string]string{}, unknownAt: -1}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "--":
+			// The arguments of the command run by exec or debug.
+			cmd.Args = append(cmd.Args, fields[i+1:]...)
+			return cmd, nil
+		case field == "-" || !strings.HasPrefix(field, "-"):
+			cmd.Args = append(cmd.Args, field)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(field, "=")
+		if !strings.HasPrefix(name, "--") && len(name) > 2 {
+			// Attached shorthand values (e.g. -nkube-system) or combined
+			// boolean shorthands (e.g. -it).
+			if long := cmd.longName(name[:2]); cmd.takesValue(long) {
+				cmd.Flags[long] = strings.TrimPrefix(field[2:], "=")
+				continue
+			}
+			for _, c := range field[1:] {
+				long := cmd.longName("-" + string(c))
+				if !kubectlBoolFlags[long] {
+					return nil, fmt.Errorf("invalid kubectl flag %s", field)
+				}
+				cmd.Flags[long] = "true"
+			}
+			continue
+		}
+
+		long := cmd.longName(name)
+		switch {
+		case hasValue:
+		case cmd.takesValue(long):
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("kubectl flag %s needs a value", name)
+			}
+			i++
+			value = fields[i]
+		case kubectlBoolFlags[long]:
+			value = "true"
+		default:
+			value = "true"
+			if cmd.unknownAt < 0 {
+				cmd.unknownAt = len(cmd.Args)
+			}
+		}
+		cmd.Flags[long] = value
+	}
+	return cmd, nil
+}
+
+// longName returns the long name of a flag, given the command parsed so far.
+func (c *KubectlCommand) longName(name string) string {
+	if strings.HasPrefix(name, "--") {
+		return name
+	}
+	if len(c.Args) > 0 {
+		if long, ok := kubectlCommandShorthands[c.Args[0]][name]; ok {
+			return long
+		}
+	}
+	if long, ok := kubectlShorthands[name]; ok {
+		return long
+	}
+	return name
+}
+
+// takesValue returns true if the flag takes a value, given the command parsed so far.
+func (c *KubectlCommand) takesValue(long string) bool {
+	if long == "--raw" && len(c.Args) > 0 && c.Args[0] == "config" {
+		// "config view --raw" shows the credentials, "get --raw <path>" reads a path.
+		return false
+	}
+	return kubectlValueFlags[long]
+}
+
+// Command returns the first n positional arguments (e.g. 2 for "rollout
+// status"), or false if a flag of unknown arity precedes them, in which case
+// one of them may actually be its value.
+func (c *KubectlCommand) Command(n int) ([]string, bool) {
+	if len(c.Args) < n || (c.unknownAt >= 0 && c.unknownAt < n) {
+		return nil, false
+	}
+	return c.Args[:n], true
+}
+
+// Namespace returns the namespace of the command, empty if not set.
+func (c *KubectlCommand) Namespace() string {
+	return c.Flags["--namespace"]
+}
+
+// AllNamespaces returns true if the command runs across all namespaces.
+func (c *KubectlCommand) AllNamespaces() bool {
+	value, ok := c.Flags["--all-namespaces"]
+	return ok && value != "false"
+}
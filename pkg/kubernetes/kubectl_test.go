@@ -0,0 +1,84 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKubectl(t *testing.T) {
+	tests := []struct {
+		command   string
+		wantArgs  []string
+		wantFlags map[string]string
+		wantErr   bool
+	}{
+		{command: "kubectl get pods -n kube-system", wantArgs: []string{"get", "pods"}, wantFlags: map[string]string{"--namespace": "kube-system"}},
+		{command: "get pods -nkube-system", wantArgs: []string{"get", "pods"}, wantFlags: map[string]string{"--namespace": "kube-system"}},
+		{command: "get pods --namespace=kube-system -oyaml", wantArgs: []string{"get", "pods"}, wantFlags: map[string]string{"--namespace": "kube-system", "--output": "yaml"}},
+		{command: "--cache-dir /tmp get pods", wantArgs: []string{"get", "pods"}, wantFlags: map[string]string{"--cache-dir": "/tmp"}},
+		{command: "exec -it nginx -- ls -l", wantArgs: []string{"exec", "nginx", "ls", "-l"}, wantFlags: map[string]string{"--stdin": "true", "--tty": "true"}},
+		{command: "logs -f -p nginx", wantArgs: []string{"logs", "nginx"}, wantFlags: map[string]string{"--follow": "true", "--previous": "true"}},
+		{command: "apply -f -", wantArgs: []string{"apply"}, wantFlags: map[string]string{"--filename": "-"}},
+		{command: "config view --raw", wantArgs: []string{"config", "view"}, wantFlags: map[string]string{"--raw": "true"}},
+		{command: "get --raw /api/v1/namespaces", wantArgs: []string{"get"}, wantFlags: map[string]string{"--raw": "/api/v1/namespaces"}},
+		{command: "get pods -n", wantErr: true},
+		{command: "get pods -xyz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got, err := ParseKubectl(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKubectl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Args, tt.wantArgs) || !reflect.DeepEqual(got.Flags, tt.wantFlags) {
+				t.Errorf("ParseKubectl() = %v %v, want %v %v", got.Args, got.Flags, tt.wantArgs, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestKubectlCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		n       int
+		want    []string
+		wantOK  bool
+	}{
+		{command: "get pods -n default", n: 1, want: []string{"get"}, wantOK: true},
+		{command: "rollout status deployment/nginx", n: 2, want: []string{"rollout", "status"}, wantOK: true},
+		{command: "--unknown get delete pod x", n: 1},
+		{command: "get pods --unknown x", n: 2, want: []string{"get", "pods"}, wantOK: true},
+		{command: "rollout --unknown status", n: 2},
+		{command: "get", n: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			cmd, err := ParseKubectl(tt.command)
+			if err != nil {
+				t.Fatalf("ParseKubectl() error = %v", err)
+			}
+			got, ok := cmd.Command(tt.n)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Command(%d) = %v %v, want %v %v", tt.n, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
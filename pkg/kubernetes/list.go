@@ -0,0 +1,233 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ListPods lists the names of the Pods in the given namespace.
+func ListPods(namespace string) ([]string, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	names := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names, nil
+}
+
+// PodImages are the container images of a Pod.
+type PodImages struct {
+	Name   string
+	Images []string
+}
+
+// ListPodImages lists the Pods in the given namespace with their container images.
+func ListPodImages(namespace string) ([]PodImages, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	result := make([]PodImages, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		result = append(result, PodImages{Name: pod.Name, Images: podImages(&pod)})
+	}
+	return result, nil
+}
+
+// podImages returns the distinct images of the init and regular containers of the Pod.
+func podImages(pod *corev1.Pod) []string {
+	var images []string
+	seen := map[string]bool{}
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Image != "" && !seen[container.Image] {
+				seen[container.Image] = true
+				images = append(images, container.Image)
+			}
+		}
+	}
+	return images
+}
+
+// GetPod gets the Pod with the given name in the namespace.
+func GetPod(namespace, name string) (*corev1.Pod, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	return pod, nil
+}
+
+// ListUnhealthyPods lists the names of the Pods in the given namespace that
+// are pending, failed, or have containers which are not ready.
+func ListUnhealthyPods(namespace string) ([]string, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	var names []string
+	for _, pod := range pods.Items {
+		if !isPodHealthy(&pod) {
+			names = append(names, pod.Name)
+		}
+	}
+	return names, nil
+}
+
+// isPodHealthy returns true if the Pod has completed or all its containers are ready.
+func isPodHealthy(pod *corev1.Pod) bool {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return true
+	case corev1.PodRunning:
+		for _, status := range pod.Status.ContainerStatuses {
+			if !status.Ready {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// ListNamespaces lists the names of all the namespaces accessible to the user.
+func ListNamespaces() ([]string, error) {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	names := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		// Only the namespaces of the user's tenants are listed.
+		if authorizeNamespace(ns.Name) == nil {
+			names = append(names, ns.Name)
+		}
+	}
+	return names, nil
+}
+
+// ListEvents lists the most recent events of the objects with the given name
+// in the namespace, oldest first.
+func ListEvents(namespace, name string) ([]EventSummary, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := clientset.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: "involvedObject.name=" + name,
+	})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	return summarizeEvents(events.Items), nil
+}
+
+// clusterError marks the network errors of API requests as errdefs.ErrClusterUnreachable.
+func clusterError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", errdefs.ErrClusterUnreachable, err)
+	}
+	return err
+}
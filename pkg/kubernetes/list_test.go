@@ -0,0 +1,58 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodImages(t *testing.T) {
+	tests := []struct {
+		name string
+		spec corev1.PodSpec
+		want []string
+	}{
+		{
+			name: "init and regular containers",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init", Image: "busybox"}},
+				Containers:     []corev1.Container{{Name: "web", Image: "nginx:1.25"}, {Name: "proxy", Image: "envoy:1.30"}},
+			},
+			want: []string{"busybox", "nginx:1.25", "envoy:1.30"},
+		},
+		{
+			name: "shared images",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "migrate", Image: "app:v1"}},
+				Containers:     []corev1.Container{{Name: "app", Image: "app:v1"}, {Name: "worker", Image: "app:v1"}},
+			},
+			want: []string{"app:v1"},
+		},
+		{
+			name: "no containers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podImages(&corev1.Pod{Spec: tt.spec}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("podImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
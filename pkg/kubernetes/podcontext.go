@@ -0,0 +1,816 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Limits keeping the pod context compact.
+const (
+	maxContextEvents  = 15
+	maxContextMessage = 300
+	maxContextKeys    = 20
+	maxContextLogs    = 30
+)
+
+// PodContext is a compact bundle of the state of a Pod and of the resources
+// related to it, handed to the model up front to save tool iterations.
+type PodContext struct {
+	Pod        PodSummary        `json:"pod"`
+	Owners     []WorkloadSummary `json:"owners,omitempty"`
+	Services   []ServiceSummary  `json:"services,omitempty"`
+	Ingresses  []IngressSummary  `json:"ingresses,omitempty"`
+	ConfigMaps []ConfigReference `json:"configMaps,omitempty"`
+	Secrets    []ConfigReference `json:"secrets,omitempty"`
+	Events     []EventSummary    `json:"events,omitempty"`
+	Node       *NodeSummary      `json:"node,omitempty"`
+	Logs       []ContainerLogs   `json:"logs,omitempty"`
+	// Skipped are the parts that could not be collected, with the reason.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// PodSummary is the status of a Pod.
+type PodSummary struct {
+	Name       string             `json:"name"`
+	Namespace  string             `json:"namespace"`
+	Phase      string             `json:"phase"`
+	Reason     string             `json:"reason,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	Node       string             `json:"node,omitempty"`
+	QOSClass   string             `json:"qosClass,omitempty"`
+	StartTime  string             `json:"startTime,omitempty"`
+	Conditions []string           `json:"conditions,omitempty"`
+	Containers []ContainerSummary `json:"containers"`
+}
+
+// ContainerSummary is the spec and status of a container.
+type ContainerSummary struct {
+	Name      string `json:"name"`
+	Init      bool   `json:"init,omitempty"`
+	Image     string `json:"image"`
+	Ready     bool   `json:"ready"`
+	Restarts  int32  `json:"restarts,omitempty"`
+	State     string `json:"state,omitempty"`
+	LastState string `json:"lastState,omitempty"`
+	Requests  string `json:"requests,omitempty"`
+	Limits    string `json:"limits,omitempty"`
+}
+
+// WorkloadSummary is the status of a controller owning the Pod.
+type WorkloadSummary struct {
+	Kind       string   `json:"kind"`
+	Name       string   `json:"name"`
+	Replicas   string   `json:"replicas,omitempty"`
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// ServiceSummary is a Service selecting the Pod.
+type ServiceSummary struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	ClusterIP string   `json:"clusterIP,omitempty"`
+	Ports     []string `json:"ports,omitempty"`
+}
+
+// IngressSummary is an Ingress routing requests to the Services of the Pod.
+type IngressSummary struct {
+	Name  string   `json:"name"`
+	Class string   `json:"class,omitempty"`
+	Rules []string `json:"rules"`
+}
+
+// ConfigReference is a ConfigMap or Secret referenced by the Pod. The values
+// are never collected; only the keys of the ConfigMaps are listed.
+type ConfigReference struct {
+	Name        string   `json:"name"`
+	Missing     bool     `json:"missing,omitempty"`
+	Optional    bool     `json:"optional,omitempty"`
+	Keys        []string `json:"keys,omitempty"`
+	MissingKeys []string `json:"missingKeys,omitempty"`
+}
+
+// EventSummary is a recent event of the Pod or of its owners.
+type EventSummary struct {
+	Time    string `json:"time"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Object  string `json:"object"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"`
+}
+
+// NodeSummary is the status of the Node running the Pod.
+type NodeSummary struct {
+	Name           string            `json:"name"`
+	Ready          string            `json:"ready"`
+	Conditions     []string          `json:"conditions,omitempty"`
+	Unschedulable  bool              `json:"unschedulable,omitempty"`
+	Taints         []string          `json:"taints,omitempty"`
+	Allocatable    map[string]string `json:"allocatable,omitempty"`
+	KubeletVersion string            `json:"kubeletVersion,omitempty"`
+}
+
+// ContainerLogs are the last lines of the logs of a container that is not
+// ready or has restarted; Previous is set for the logs of the last instance.
+type ContainerLogs struct {
+	Container string   `json:"container"`
+	Previous  bool     `json:"previous,omitempty"`
+	Lines     []string `json:"lines"`
+}
+
+// GetPodContext collects the state of a Pod together with its owners, the
+// Services and Ingresses serving it, the ConfigMaps and Secrets it references,
+// the recent events, its Node and the logs of its failing containers. The parts the user is not allowed to read
+// are reported in Skipped instead of failing the collection.
+func GetPodContext(namespace, name string) (*PodContext, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return buildPodContext(context.Background(), clientset, namespace, name, authorizeClusterScoped(false) == nil)
+}
+
+// GetPodLogs returns the last lines of the logs of the containers of the Pod
+// that are not ready or have restarted, including their previous instance.
+func GetPodLogs(namespace, name string) ([]ContainerLogs, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	logs, _ := collectLogs(context.Background(), clientset, pod)
+	return logs, nil
+}
+
+// Format renders the pod context as YAML.
+func (c *PodContext) Format() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func buildPodContext(ctx context.Context, client kubernetes.Interface, namespace, name string, readNodes bool) (*PodContext, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+
+	c := &PodContext{Pod: summarizePod(pod)}
+	// The logs are fetched while the other resources are collected.
+	logsDone := make(chan struct{})
+	var logs []ContainerLogs
+	var logsSkipped []string
+	go func() {
+		defer close(logsDone)
+		logs, logsSkipped = collectLogs(ctx, client, pod)
+	}()
+	// objects are the names of the Pod and of its owners, whose events are collected.
+	objects := map[string]bool{"Pod/" + pod.Name: true}
+	if err := c.addOwners(ctx, client, pod, objects); err != nil {
+		c.skip("owners", err)
+	}
+	if err := c.addServices(ctx, client, pod); err != nil {
+		c.skip("services", err)
+	}
+	if len(c.Services) > 0 {
+		if err := c.addIngresses(ctx, client, namespace); err != nil {
+			c.skip("ingresses", err)
+		}
+	}
+	c.addConfigReferences(ctx, client, pod)
+	if err := c.addEvents(ctx, client, namespace, objects); err != nil {
+		c.skip("events", err)
+	}
+	if pod.Spec.NodeName != "" {
+		if !readNodes {
+			c.Skipped = append(c.Skipped, "node: outside of the tenant scope")
+		} else if err := c.addNode(ctx, client, pod.Spec.NodeName); err != nil {
+			c.skip("node", err)
+		}
+	}
+	<-logsDone
+	c.Logs = logs
+	c.Skipped = append(c.Skipped, logsSkipped...)
+	return c, nil
+}
+
+func (c *PodContext) skip(part string, err error) {
+	c.Skipped = append(c.Skipped, fmt.Sprintf("%s: %s", part, truncateMessage(err.Error())))
+}
+
+func summarizePod(pod *corev1.Pod) PodSummary {
+	summary := PodSummary{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Phase:     string(pod.Status.Phase),
+		Reason:    pod.Status.Reason,
+		Message:   truncateMessage(pod.Status.Message),
+		Node:      pod.Spec.NodeName,
+		QOSClass:  string(pod.Status.QOSClass),
+	}
+	if pod.Status.StartTime != nil {
+		summary.StartTime = pod.Status.StartTime.UTC().Format(time.RFC3339)
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			summary.Conditions = append(summary.Conditions, formatCondition(string(condition.Type), string(condition.Status), condition.Reason, condition.Message))
+		}
+	}
+
+	statuses := map[string]corev1.ContainerStatus{}
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		statuses[status.Name] = status
+	}
+	add := func(container corev1.Container, init bool) {
+		status := statuses[container.Name]
+		summary.Containers = append(summary.Containers, ContainerSummary{
+			Name:      container.Name,
+			Init:      init,
+			Image:     container.Image,
+			Ready:     status.Ready,
+			Restarts:  status.RestartCount,
+			State:     formatContainerState(status.State),
+			LastState: formatContainerState(status.LastTerminationState),
+			Requests:  formatResources(container.Resources.Requests),
+			Limits:    formatResources(container.Resources.Limits),
+		})
+	}
+	for _, container := range pod.Spec.InitContainers {
+		add(container, true)
+	}
+	for _, container := range pod.Spec.Containers {
+		add(container, false)
+	}
+	return summary
+}
+
+// addOwners follows the controllers of the Pod, e.g. ReplicaSet and Deployment.
+func (c *PodContext) addOwners(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, objects map[string]bool) error {
+	owner := metav1.GetControllerOf(pod)
+	for owner != nil {
+		objects[owner.Kind+"/"+owner.Name] = true
+		var next *metav1.OwnerReference
+		switch owner.Kind {
+		case "ReplicaSet":
+			rs, err := client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			c.Owners = append(c.Owners, WorkloadSummary{
+				Kind:     owner.Kind,
+				Name:     owner.Name,
+				Replicas: fmt.Sprintf("%d/%d ready", rs.Status.ReadyReplicas, ptrValue(rs.Spec.Replicas)),
+			})
+			next = metav1.GetControllerOf(rs)
+		case "Deployment":
+			deployment, err := client.AppsV1().Deployments(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			c.Owners = append(c.Owners, summarizeDeployment(deployment))
+		case "StatefulSet":
+			sts, err := client.AppsV1().StatefulSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			summary := WorkloadSummary{
+				Kind:     owner.Kind,
+				Name:     owner.Name,
+				Replicas: fmt.Sprintf("%d/%d ready, %d updated", sts.Status.ReadyReplicas, ptrValue(sts.Spec.Replicas), sts.Status.UpdatedReplicas),
+			}
+			if sts.Status.CurrentRevision != sts.Status.UpdateRevision {
+				summary.Conditions = append(summary.Conditions, fmt.Sprintf("rolling out revision %s (current %s)", sts.Status.UpdateRevision, sts.Status.CurrentRevision))
+			}
+			c.Owners = append(c.Owners, summary)
+		case "DaemonSet":
+			ds, err := client.AppsV1().DaemonSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			c.Owners = append(c.Owners, WorkloadSummary{
+				Kind:     owner.Kind,
+				Name:     owner.Name,
+				Replicas: fmt.Sprintf("%d/%d ready, %d updated, %d misscheduled", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled, ds.Status.UpdatedNumberScheduled, ds.Status.NumberMisscheduled),
+			})
+		case "Job":
+			job, err := client.BatchV1().Jobs(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			summary := WorkloadSummary{
+				Kind:     owner.Kind,
+				Name:     owner.Name,
+				Replicas: fmt.Sprintf("%d active, %d succeeded, %d failed (backoffLimit %d)", job.Status.Active, job.Status.Succeeded, job.Status.Failed, ptrValue(job.Spec.BackoffLimit)),
+			}
+			for _, condition := range job.Status.Conditions {
+				if condition.Status == corev1.ConditionTrue {
+					summary.Conditions = append(summary.Conditions, formatCondition(string(condition.Type), string(condition.Status), condition.Reason, condition.Message))
+				}
+			}
+			c.Owners = append(c.Owners, summary)
+			next = metav1.GetControllerOf(job)
+		default:
+			// Other controllers (e.g. CronJobs or custom resources) are only named.
+			c.Owners = append(c.Owners, WorkloadSummary{Kind: owner.Kind, Name: owner.Name})
+		}
+		owner = next
+	}
+	return nil
+}
+
+func summarizeDeployment(deployment *appsv1.Deployment) WorkloadSummary {
+	summary := WorkloadSummary{
+		Kind:     "Deployment",
+		Name:     deployment.Name,
+		Replicas: fmt.Sprintf("%d/%d ready, %d updated, %d available", deployment.Status.ReadyReplicas, ptrValue(deployment.Spec.Replicas), deployment.Status.UpdatedReplicas, deployment.Status.AvailableReplicas),
+	}
+	for _, condition := range deployment.Status.Conditions {
+		summary.Conditions = append(summary.Conditions, formatCondition(string(condition.Type), string(condition.Status), condition.Reason, ""))
+	}
+	if deployment.Spec.Paused {
+		summary.Conditions = append(summary.Conditions, "paused")
+	}
+	return summary
+}
+
+// addServices adds the Services whose selector matches the labels of the Pod.
+func (c *PodContext) addServices(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) error {
+	services, err := client.CoreV1().Services(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, service := range services.Items {
+		if len(service.Spec.Selector) == 0 || !labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		summary := ServiceSummary{Name: service.Name, Type: string(service.Spec.Type), ClusterIP: service.Spec.ClusterIP}
+		for _, port := range service.Spec.Ports {
+			summary.Ports = append(summary.Ports, fmt.Sprintf("%d->%s/%s", port.Port, port.TargetPort.String(), port.Protocol))
+		}
+		c.Services = append(c.Services, summary)
+	}
+	return nil
+}
+
+// addIngresses adds the Ingresses routing requests to the Services of the Pod.
+func (c *PodContext) addIngresses(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	services := map[string]bool{}
+	for _, service := range c.Services {
+		services[service.Name] = true
+	}
+
+	for _, ingress := range ingresses.Items {
+		summary := IngressSummary{Name: ingress.Name, Class: ptrValue(ingress.Spec.IngressClassName)}
+		if backend := ingress.Spec.DefaultBackend; backend != nil && backend.Service != nil && services[backend.Service.Name] {
+			summary.Rules = append(summary.Rules, "default -> "+formatIngressBackend(backend.Service))
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			host := rule.Host
+			if host == "" {
+				host = "*"
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil && services[path.Backend.Service.Name] {
+					summary.Rules = append(summary.Rules, fmt.Sprintf("%s%s -> %s", host, path.Path, formatIngressBackend(path.Backend.Service)))
+				}
+			}
+		}
+		if len(summary.Rules) > 0 {
+			c.Ingresses = append(c.Ingresses, summary)
+		}
+	}
+	return nil
+}
+
+// configReference collects the references of the Pod to a ConfigMap or Secret.
+type configReference struct {
+	optional bool
+	keys     map[string]bool
+}
+
+// addConfigReferences checks the ConfigMaps and Secrets referenced by the
+// volumes and the environment of the containers.
+func (c *PodContext) addConfigReferences(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) {
+	configMaps := map[string]*configReference{}
+	secrets := map[string]*configReference{}
+	reference := func(refs map[string]*configReference, name string, optional *bool, key string) {
+		if name == "" {
+			return
+		}
+		ref, ok := refs[name]
+		if !ok {
+			ref = &configReference{optional: true, keys: map[string]bool{}}
+			refs[name] = ref
+		}
+		// The reference is optional only if all its uses are optional.
+		ref.optional = ref.optional && optional != nil && *optional
+		if key != "" {
+			ref.keys[key] = true
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if cm := volume.ConfigMap; cm != nil {
+			reference(configMaps, cm.Name, cm.Optional, "")
+			for _, item := range cm.Items {
+				reference(configMaps, cm.Name, cm.Optional, item.Key)
+			}
+		}
+		if secret := volume.Secret; secret != nil {
+			reference(secrets, secret.SecretName, secret.Optional, "")
+			for _, item := range secret.Items {
+				reference(secrets, secret.SecretName, secret.Optional, item.Key)
+			}
+		}
+		if projected := volume.Projected; projected != nil {
+			for _, source := range projected.Sources {
+				if source.ConfigMap != nil {
+					reference(configMaps, source.ConfigMap.Name, source.ConfigMap.Optional, "")
+				}
+				if source.Secret != nil {
+					reference(secrets, source.Secret.Name, source.Secret.Optional, "")
+				}
+			}
+		}
+	}
+	for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil {
+				reference(configMaps, from.ConfigMapRef.Name, from.ConfigMapRef.Optional, "")
+			}
+			if from.SecretRef != nil {
+				reference(secrets, from.SecretRef.Name, from.SecretRef.Optional, "")
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				reference(configMaps, ref.Name, ref.Optional, ref.Key)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				reference(secrets, ref.Name, ref.Optional, ref.Key)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(configMaps) {
+		ref := configMaps[name]
+		summary := ConfigReference{Name: name, Optional: ref.optional}
+		cm, err := client.CoreV1().ConfigMaps(pod.Namespace).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			summary.Missing = true
+		case err != nil:
+			c.skip("configmap "+name, err)
+			continue
+		default:
+			keys := map[string]bool{}
+			for key := range cm.Data {
+				keys[key] = true
+			}
+			for key := range cm.BinaryData {
+				keys[key] = true
+			}
+			summary.Keys = sortedKeys(keys)
+			if len(summary.Keys) > maxContextKeys {
+				summary.Keys = append(summary.Keys[:maxContextKeys], "...")
+			}
+			summary.MissingKeys = missingKeys(ref.keys, keys)
+		}
+		c.ConfigMaps = append(c.ConfigMaps, summary)
+	}
+	for _, name := range sortedKeys(secrets) {
+		ref := secrets[name]
+		summary := ConfigReference{Name: name, Optional: ref.optional}
+		secret, err := client.CoreV1().Secrets(pod.Namespace).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			summary.Missing = true
+		case err != nil:
+			c.skip("secret "+name, err)
+			continue
+		default:
+			keys := map[string]bool{}
+			for key := range secret.Data {
+				keys[key] = true
+			}
+			for key := range secret.StringData {
+				keys[key] = true
+			}
+			summary.MissingKeys = missingKeys(ref.keys, keys)
+		}
+		c.Secrets = append(c.Secrets, summary)
+	}
+}
+
+// addEvents adds the most recent events of the Pod and of its owners, oldest first.
+func (c *PodContext) addEvents(ctx context.Context, client kubernetes.Interface, namespace string, objects map[string]bool) error {
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var related []corev1.Event
+	for _, event := range events.Items {
+		if objects[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] {
+			related = append(related, event)
+		}
+	}
+	c.Events = summarizeEvents(related)
+	return nil
+}
+
+// summarizeEvents returns the summaries of the most recent events, oldest first.
+func summarizeEvents(events []corev1.Event) []EventSummary {
+	sort.Slice(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > maxContextEvents {
+		events = events[len(events)-maxContextEvents:]
+	}
+	summaries := make([]EventSummary, 0, len(events))
+	for _, event := range events {
+		summaries = append(summaries, EventSummary{
+			Time:    eventTime(event).UTC().Format(time.RFC3339),
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Object:  event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+			Message: truncateMessage(event.Message),
+			Count:   event.Count,
+		})
+	}
+	return summaries
+}
+
+// collectLogs returns the last lines of the logs of the containers that are
+// not ready or have restarted, including the logs of their previous instance,
+// and the logs that could not be fetched.
+func collectLogs(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) ([]ContainerLogs, []string) {
+	var requests []ContainerLogs
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 || status.RestartCount > 0 {
+			requests = appendLogRequests(requests, status)
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready || status.RestartCount > 0 {
+			requests = appendLogRequests(requests, status)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(requests))
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			requests[i].Lines, errs[i] = containerLogs(ctx, client, pod, requests[i].Container, requests[i].Previous)
+		}(i)
+	}
+	wg.Wait()
+
+	var logs []ContainerLogs
+	var skipped []string
+	for i, request := range requests {
+		if errs[i] != nil {
+			part := "logs of " + request.Container
+			if request.Previous {
+				part = "previous " + part
+			}
+			skipped = append(skipped, fmt.Sprintf("%s: %s", part, truncateMessage(errs[i].Error())))
+			continue
+		}
+		if len(request.Lines) > 0 {
+			logs = append(logs, request)
+		}
+	}
+	return logs, skipped
+}
+
+// appendLogRequests appends the logs to fetch for the container: the current
+// ones unless it is waiting to start, and the previous ones if it restarted.
+func appendLogRequests(requests []ContainerLogs, status corev1.ContainerStatus) []ContainerLogs {
+	if status.State.Waiting == nil {
+		requests = append(requests, ContainerLogs{Container: status.Name})
+	}
+	if status.RestartCount > 0 {
+		requests = append(requests, ContainerLogs{Container: status.Name, Previous: true})
+	}
+	return requests
+}
+
+func containerLogs(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, container string, previous bool) ([]string, error) {
+	data, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: ptr(int64(maxContextLogs)),
+	}).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			if len(line) > maxContextMessage {
+				line = line[:maxContextMessage-3] + "..."
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func (c *PodContext) addNode(ctx context.Context, client kubernetes.Interface, name string) error {
+	node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	summary := &NodeSummary{
+		Name:           node.Name,
+		Ready:          "Unknown",
+		Unschedulable:  node.Spec.Unschedulable,
+		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		Allocatable:    map[string]string{},
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			summary.Ready = string(condition.Status)
+			if condition.Status != corev1.ConditionTrue {
+				summary.Conditions = append(summary.Conditions, formatCondition(string(condition.Type), string(condition.Status), condition.Reason, condition.Message))
+			}
+		} else if condition.Status == corev1.ConditionTrue {
+			// The pressure and network conditions are only reported when set.
+			summary.Conditions = append(summary.Conditions, formatCondition(string(condition.Type), string(condition.Status), condition.Reason, condition.Message))
+		}
+	}
+	for _, taint := range node.Spec.Taints {
+		summary.Taints = append(summary.Taints, taint.ToString())
+	}
+	for _, resource := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourcePods} {
+		if quantity, ok := node.Status.Allocatable[resource]; ok {
+			summary.Allocatable[string(resource)] = quantity.String()
+		}
+	}
+	c.Node = summary
+	return nil
+}
+
+func formatCondition(conditionType, status, reason, message string) string {
+	s := conditionType + "=" + status
+	if reason != "" {
+		s += " (" + reason + ")"
+	}
+	if message != "" {
+		s += ": " + truncateMessage(message)
+	}
+	return s
+}
+
+func formatContainerState(state corev1.ContainerState) string {
+	switch {
+	case state.Waiting != nil:
+		s := "waiting: " + state.Waiting.Reason
+		if state.Waiting.Message != "" {
+			s += ": " + truncateMessage(state.Waiting.Message)
+		}
+		return s
+	case state.Terminated != nil:
+		s := fmt.Sprintf("terminated: %s (exit code %d)", state.Terminated.Reason, state.Terminated.ExitCode)
+		if !state.Terminated.FinishedAt.IsZero() {
+			s += " at " + state.Terminated.FinishedAt.UTC().Format(time.RFC3339)
+		}
+		if state.Terminated.Message != "" {
+			s += ": " + truncateMessage(state.Terminated.Message)
+		}
+		return s
+	case state.Running != nil:
+		return "running since " + state.Running.StartedAt.UTC().Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
+
+func formatResources(resources corev1.ResourceList) string {
+	var parts []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage} {
+		if quantity, ok := resources[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatIngressBackend(backend *networkingv1.IngressServiceBackend) string {
+	if backend.Port.Name != "" {
+		return backend.Name + ":" + backend.Port.Name
+	}
+	return fmt.Sprintf("%s:%d", backend.Name, backend.Port.Number)
+}
+
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+func missingKeys(referenced, existing map[string]bool) []string {
+	var missing []string
+	for _, key := range sortedKeys(referenced) {
+		if !existing[key] {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func truncateMessage(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= maxContextMessage {
+		return s
+	}
+	return s[:maxContextMessage-3] + "..."
+}
+
+func ptrValue[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
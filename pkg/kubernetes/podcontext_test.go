@@ -0,0 +1,189 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBuildPodContext(t *testing.T) {
+	controller := true
+	replicas := int32(2)
+	now := metav1.NewTime(time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC))
+	objects := []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1, UpdatedReplicas: 2, AvailableReplicas: 1, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionFalse, Reason: "MinimumReplicasUnavailable"},
+			}},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-5d4f8", Namespace: "prod", OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}}},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+			Status:     appsv1.ReplicaSetStatus{ReadyReplicas: 1},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-5d4f8-abcde", Namespace: "prod", Labels: map[string]string{"app": "web", "pod-template-hash": "5d4f8"}, OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d4f8", Controller: &controller}}},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Containers: []corev1.Container{{
+					Name:  "web",
+					Image: "nginx:1.27",
+					Env: []corev1.EnvVar{
+						{Name: "LOG_LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}, Key: "log-level"}}},
+						{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"}, Key: "token"}}},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "ContainersNotReady"}},
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "web",
+					RestartCount:         5,
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError", Message: `secret "web-token" not found`}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+				}},
+			},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "prod"}, Data: map[string]string{"logLevel": "debug"}},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.0.0.10", Selector: map[string]string{"app": "web"}, Ports: []corev1.ServicePort{
+				{Port: 80, TargetPort: intstr.FromString("http"), Protocol: corev1.ProtocolTCP},
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "api"}},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{
+				Host: "shop.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{
+					{Path: "/", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web", Port: networkingv1.ServiceBackendPort{Number: 80}}}},
+					{Path: "/api", Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "api", Port: networkingv1.ServiceBackendPort{Number: 80}}}},
+				}}},
+			}}},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e1", Namespace: "prod"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-5d4f8-abcde"},
+			Type:           corev1.EventTypeWarning, Reason: "Failed", Message: `Error: secret "web-token" not found`, Count: 5, LastTimestamp: now,
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "e2", Namespace: "prod"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api-1"},
+			Type:           corev1.EventTypeNormal, Reason: "Started", Message: "Started container api", LastTimestamp: now,
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec:       corev1.NodeSpec{Taints: []corev1.Taint{{Key: "dedicated", Value: "web", Effect: corev1.TaintEffectNoSchedule}}},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue, Reason: "KubeletHasInsufficientMemory"},
+				{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse},
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(objects...)
+
+	tests := []struct {
+		name      string
+		readNodes bool
+		check     func(t *testing.T, c *PodContext)
+	}{
+		{
+			name:      "related resources",
+			readNodes: true,
+			check: func(t *testing.T, c *PodContext) {
+				container := c.Pod.Containers[0]
+				if container.Restarts != 5 || !strings.HasPrefix(container.State, "waiting: CreateContainerConfigError") || container.LastState != "terminated: Error (exit code 1)" {
+					t.Errorf("container = %+v", container)
+				}
+				if want := []string{"Ready=False (ContainersNotReady)"}; !reflect.DeepEqual(c.Pod.Conditions, want) {
+					t.Errorf("pod conditions = %v, want %v", c.Pod.Conditions, want)
+				}
+				if len(c.Owners) != 2 || c.Owners[0].Kind != "ReplicaSet" || c.Owners[1].Kind != "Deployment" || c.Owners[1].Replicas != "1/2 ready, 2 updated, 1 available" {
+					t.Errorf("owners = %+v, want the ReplicaSet and the Deployment", c.Owners)
+				}
+				if len(c.Services) != 1 || c.Services[0].Name != "web" || c.Services[0].Ports[0] != "80->http/TCP" {
+					t.Errorf("services = %+v, want only the Service selecting the Pod", c.Services)
+				}
+				if len(c.Ingresses) != 1 || !reflect.DeepEqual(c.Ingresses[0].Rules, []string{"shop.example.com/ -> web:80"}) {
+					t.Errorf("ingresses = %+v, want only the rule of the Service", c.Ingresses)
+				}
+				if want := []ConfigReference{{Name: "web-config", Keys: []string{"logLevel"}, MissingKeys: []string{"log-level"}}}; !reflect.DeepEqual(c.ConfigMaps, want) {
+					t.Errorf("configMaps = %+v, want %+v", c.ConfigMaps, want)
+				}
+				if want := []ConfigReference{{Name: "web-token", Missing: true}}; !reflect.DeepEqual(c.Secrets, want) {
+					t.Errorf("secrets = %+v, want %+v", c.Secrets, want)
+				}
+				if len(c.Events) != 1 || c.Events[0].Reason != "Failed" {
+					t.Errorf("events = %+v, want only the events of the Pod", c.Events)
+				}
+				if c.Node == nil || c.Node.Ready != "True" || !reflect.DeepEqual(c.Node.Conditions, []string{"MemoryPressure=True (KubeletHasInsufficientMemory)"}) || !reflect.DeepEqual(c.Node.Taints, []string{"dedicated=web:NoSchedule"}) {
+					t.Errorf("node = %+v", c.Node)
+				}
+				// The container waiting to restart only has the logs of its previous instance.
+				if want := []ContainerLogs{{Container: "web", Previous: true, Lines: []string{"fake logs"}}}; !reflect.DeepEqual(c.Logs, want) {
+					t.Errorf("logs = %+v, want %+v", c.Logs, want)
+				}
+				if len(c.Skipped) != 0 {
+					t.Errorf("skipped = %v, want none", c.Skipped)
+				}
+			},
+		},
+		{
+			name: "node outside of the tenant scope",
+			check: func(t *testing.T, c *PodContext) {
+				if c.Node != nil || len(c.Skipped) != 1 || !strings.HasPrefix(c.Skipped[0], "node:") {
+					t.Errorf("node = %+v, skipped = %v, want the node skipped", c.Node, c.Skipped)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := buildPodContext(context.Background(), client, "prod", "web-5d4f8-abcde", tt.readNodes)
+			if err != nil {
+				t.Fatalf("buildPodContext() error = %v", err)
+			}
+			tt.check(t, c)
+			if out := c.Format(); !strings.Contains(out, "name: web-5d4f8-abcde") || strings.Contains(out, "debug") {
+				t.Errorf("Format() = %s, want the YAML without the values", out)
+			}
+		})
+	}
+
+	if _, err := buildPodContext(context.Background(), client, "prod", "missing", true); err == nil {
+		t.Errorf("buildPodContext() of a missing Pod should fail")
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetObject gets the live state of the object identified by the kind,
+// namespace and name of obj, or nil if it doesn't exist.
+func GetObject(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	dri, err := objectInterface(obj, false)
+	if err != nil {
+		return nil, err
+	}
+	live, err := dri.Get(context.Background(), obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	return live, nil
+}
+
+// RestoreObject restores the object to the previous state returned by
+// GetObject. The object is deleted when it didn't exist before (previous is nil).
+func RestoreObject(obj, previous *unstructured.Unstructured) error {
+	dri, err := objectInterface(obj, true)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if previous == nil {
+		err := dri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("%s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		return nil
+	}
+
+	current, err := dri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// Deleted meanwhile: recreate it without the server-set fields.
+		restored := previous.DeepCopy()
+		restored.SetResourceVersion("")
+		restored.SetUID("")
+		restored.SetManagedFields(nil)
+		unstructured.RemoveNestedField(restored.Object, "status")
+		_, err = dri.Create(ctx, restored, metav1.CreateOptions{})
+	} else if err == nil {
+		// Replace the whole object, so that the fields added by the change are removed too.
+		restored := previous.DeepCopy()
+		restored.SetResourceVersion(current.GetResourceVersion())
+		restored.SetManagedFields(nil)
+		_, err = dri.Update(ctx, restored, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", obj.GetKind(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// Health is the health of a workload.
+type Health struct {
+	// Healthy is true when the workload is fully rolled out and ready.
+	Healthy bool `json:"healthy"`
+	// Degraded is true when the workload is failing, e.g. its Pods are crash
+	// looping or its rollout exceeded the progress deadline. A workload still
+	// rolling out is neither healthy nor degraded.
+	Degraded bool   `json:"degraded"`
+	Detail   string `json:"detail"`
+}
+
+// podFailureReasons are the waiting and termination reasons of failing containers.
+var podFailureReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+	"OOMKilled":                  true,
+	"Error":                      true,
+}
+
+// IsWorkload returns true if WorkloadHealth checks the rollout of the object's kind.
+func IsWorkload(obj *unstructured.Unstructured) bool {
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "Pod":
+		return true
+	}
+	return false
+}
+
+// WorkloadHealth returns the health of a Deployment, StatefulSet, DaemonSet
+// or Pod. The other objects are healthy as long as they exist. The failures
+// of the Pods in ignorePods, e.g. the Pods existing before a change, are ignored.
+func WorkloadHealth(obj *unstructured.Unstructured, ignorePods map[string]bool) (*Health, error) {
+	live, err := GetObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return &Health{Degraded: true, Detail: fmt.Sprintf("%s %s not found", obj.GetKind(), obj.GetName())}, nil
+	}
+
+	var health *Health
+	switch live.GetKind() {
+	case "Deployment":
+		var d appsv1.Deployment
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, &d); err != nil {
+			return nil, err
+		}
+		health = deploymentHealth(&d)
+	case "StatefulSet":
+		var s appsv1.StatefulSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, &s); err != nil {
+			return nil, err
+		}
+		health = statefulSetHealth(&s)
+	case "DaemonSet":
+		var d appsv1.DaemonSet
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, &d); err != nil {
+			return nil, err
+		}
+		health = daemonSetHealth(&d)
+	case "Pod":
+		var p corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, &p); err != nil {
+			return nil, err
+		}
+		if reason := podFailure(&p); reason != "" {
+			return &Health{Degraded: true, Detail: reason}, nil
+		}
+		return &Health{Healthy: isPodHealthy(&p), Detail: fmt.Sprintf("phase %s", p.Status.Phase)}, nil
+	default:
+		return &Health{Healthy: true, Detail: "exists"}, nil
+	}
+
+	// Failing Pods degrade the workload even while its rollout is in progress.
+	pods, err := workloadPods(live)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if ignorePods[pod.Name] {
+			continue
+		}
+		if reason := podFailure(&pod); reason != "" {
+			return &Health{Degraded: true, Detail: fmt.Sprintf("Pod %s: %s", pod.Name, reason)}, nil
+		}
+	}
+	return health, nil
+}
+
+// ListWorkloadPods lists the names of the Pods selected by a Deployment,
+// StatefulSet or DaemonSet (none for the other objects).
+func ListWorkloadPods(obj *unstructured.Unstructured) ([]string, error) {
+	if !IsWorkload(obj) || obj.GetKind() == "Pod" {
+		return nil, nil
+	}
+	live, err := GetObject(obj)
+	if err != nil || live == nil {
+		return nil, err
+	}
+	pods, err := workloadPods(live)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names, nil
+}
+
+// workloadPods lists the Pods matching the matchLabels selector of the live workload.
+func workloadPods(live *unstructured.Unstructured) ([]corev1.Pod, error) {
+	matchLabels, _, _ := unstructured.NestedStringMap(live.Object, "spec", "selector", "matchLabels")
+	if len(matchLabels) == 0 {
+		return nil, nil
+	}
+
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.SelectorFromSet(matchLabels)
+	pods, err := clientset.CoreV1().Pods(live.GetNamespace()).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	return pods.Items, nil
+}
+
+func deploymentHealth(d *appsv1.Deployment) *Health {
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return &Health{Degraded: true, Detail: c.Message}
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas < replicas ||
+		d.Status.AvailableReplicas < replicas || d.Status.Replicas > d.Status.UpdatedReplicas {
+		return &Health{Detail: fmt.Sprintf("rolling out: %d/%d updated, %d available", d.Status.UpdatedReplicas, replicas, d.Status.AvailableReplicas)}
+	}
+	return &Health{Healthy: true, Detail: fmt.Sprintf("%d/%d available", d.Status.AvailableReplicas, replicas)}
+}
+
+func statefulSetHealth(s *appsv1.StatefulSet) *Health {
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	if s.Status.ObservedGeneration < s.Generation || s.Status.ReadyReplicas < replicas ||
+		(s.Status.UpdateRevision != "" && s.Status.CurrentRevision != s.Status.UpdateRevision && s.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType) {
+		return &Health{Detail: fmt.Sprintf("rolling out: %d/%d ready, %d updated", s.Status.ReadyReplicas, replicas, s.Status.UpdatedReplicas)}
+	}
+	return &Health{Healthy: true, Detail: fmt.Sprintf("%d/%d ready", s.Status.ReadyReplicas, replicas)}
+}
+
+func daemonSetHealth(d *appsv1.DaemonSet) *Health {
+	desired := d.Status.DesiredNumberScheduled
+	if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedNumberScheduled < desired || d.Status.NumberAvailable < desired {
+		return &Health{Detail: fmt.Sprintf("rolling out: %d/%d updated, %d available", d.Status.UpdatedNumberScheduled, desired, d.Status.NumberAvailable)}
+	}
+	return &Health{Healthy: true, Detail: fmt.Sprintf("%d/%d available", d.Status.NumberAvailable, desired)}
+}
+
+// podFailure returns the reason of the first failing container of the Pod, or "" if none.
+func podFailure(pod *corev1.Pod) string {
+	if pod.Status.Phase == corev1.PodFailed {
+		return strings.TrimSpace("failed " + pod.Status.Reason)
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if w := status.State.Waiting; w != nil && podFailureReasons[w.Reason] {
+			return fmt.Sprintf("container %s is %s", status.Name, w.Reason)
+		}
+		if t := status.LastTerminationState.Terminated; t != nil && podFailureReasons[t.Reason] && status.RestartCount > 0 && !status.Ready {
+			return fmt.Sprintf("container %s restarted after %s", status.Name, t.Reason)
+		}
+	}
+	return ""
+}
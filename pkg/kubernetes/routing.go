@@ -0,0 +1,204 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// gatewayAPIVersions are the Gateway API versions tried in order.
+var gatewayAPIVersions = []string{"v1", "v1beta1"}
+
+const gatewayAPIGroup = "gateway.networking.k8s.io"
+
+// Routing is the state of the resources serving the HTTP requests in a namespace.
+type Routing struct {
+	Namespace string
+	Ingresses []networkingv1.Ingress
+	// HTTPRoutes and Gateways are empty when the Gateway API is not installed or not accessible.
+	HTTPRoutes     []HTTPRoute
+	Gateways       []Gateway
+	Services       []corev1.Service
+	EndpointSlices []discoveryv1.EndpointSlice
+	Pods           []corev1.Pod
+}
+
+// ParentReference references the Gateway of an HTTPRoute.
+type ParentReference struct {
+	Kind        string `json:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// HTTPPathMatch matches the path of the requests.
+type HTTPPathMatch struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// HTTPRouteMatch matches the requests of an HTTPRoute rule.
+type HTTPRouteMatch struct {
+	Path *HTTPPathMatch `json:"path,omitempty"`
+}
+
+// HTTPBackendRef references the Service receiving the requests.
+type HTTPBackendRef struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Port      int32  `json:"port,omitempty"`
+}
+
+// HTTPRouteRule is a rule of an HTTPRoute.
+type HTTPRouteRule struct {
+	Matches     []HTTPRouteMatch `json:"matches,omitempty"`
+	BackendRefs []HTTPBackendRef `json:"backendRefs,omitempty"`
+}
+
+// HTTPRouteSpec is the spec of an HTTPRoute.
+type HTTPRouteSpec struct {
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	Rules      []HTTPRouteRule   `json:"rules,omitempty"`
+}
+
+// RouteParentStatus is the status of an HTTPRoute for one of its Gateways.
+type RouteParentStatus struct {
+	ParentRef  ParentReference    `json:"parentRef"`
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// HTTPRouteStatus is the status of an HTTPRoute.
+type HTTPRouteStatus struct {
+	Parents []RouteParentStatus `json:"parents,omitempty"`
+}
+
+// HTTPRoute is the subset of a Gateway API HTTPRoute used to trace the requests.
+type HTTPRoute struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              HTTPRouteSpec   `json:"spec"`
+	Status            HTTPRouteStatus `json:"status,omitempty"`
+}
+
+// GatewayStatus is the status of a Gateway.
+type GatewayStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Gateway is the subset of a Gateway API Gateway used to trace the requests.
+type Gateway struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Status            GatewayStatus `json:"status,omitempty"`
+}
+
+// GetRouting gets the Ingresses, HTTPRoutes, Services, EndpointSlices and
+// Pods in the given namespace, together with the Gateways of the cluster.
+func GetRouting(namespace string) (*Routing, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	routing := &Routing{Namespace: namespace}
+	ingresses, err := clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	routing.Ingresses = ingresses.Items
+
+	services, err := clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	routing.Services = services.Items
+
+	slices, err := clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	routing.EndpointSlices = slices.Items
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	routing.Pods = pods.Items
+
+	if err := listCustomResources(ctx, dynamicClient, gatewayAPIGroup, gatewayAPIVersions, "httproutes", namespace, &routing.HTTPRoutes); err != nil {
+		return nil, err
+	}
+	// The Gateways usually live in a shared namespace, which may be outside of the user's tenants.
+	gatewayNamespace := metav1.NamespaceAll
+	if authorizeNamespace(gatewayNamespace) != nil {
+		gatewayNamespace = namespace
+	}
+	if err := listCustomResources(ctx, dynamicClient, gatewayAPIGroup, gatewayAPIVersions, "gateways", gatewayNamespace, &routing.Gateways); err != nil {
+		return nil, err
+	}
+	return routing, nil
+}
+
+// listCustomResources lists the custom resources of the first served
+// version into items. Missing CRDs and forbidden lists are ignored.
+func listCustomResources[T any](ctx context.Context, client dynamic.Interface, group string, versions []string, resource string, namespace string, items *[]T) error {
+	for _, version := range versions {
+		gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+		list, err := client.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			return nil
+		}
+		if err != nil {
+			return clusterError(err)
+		}
+
+		for _, obj := range list.Items {
+			var item T
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &item); err != nil {
+				return err
+			}
+			*items = append(*items, item)
+		}
+		return nil
+	}
+	return nil
+}
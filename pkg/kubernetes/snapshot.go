@@ -0,0 +1,94 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Snapshot is the state of the workloads in a namespace and of the cluster Nodes.
+type Snapshot struct {
+	Namespace    string
+	Deployments  []appsv1.Deployment
+	StatefulSets []appsv1.StatefulSet
+	PDBs         []policyv1.PodDisruptionBudget
+	Pods         []corev1.Pod
+	// Nodes is empty when the user is not allowed to list the Nodes.
+	Nodes []corev1.Node
+}
+
+// GetSnapshot gets the Deployments, StatefulSets, PodDisruptionBudgets and
+// Pods in the given namespace, together with the cluster Nodes.
+func GetSnapshot(namespace string) (*Snapshot, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	snapshot := &Snapshot{Namespace: namespace}
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	snapshot.Deployments = deployments.Items
+
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	snapshot.StatefulSets = statefulSets.Items
+
+	pdbs, err := clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	snapshot.PDBs = pdbs.Items
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	snapshot.Pods = pods.Items
+
+	// Namespace-scoped users may not list the Nodes; the checks relying on them are skipped.
+	if authorizeClusterScoped(false) != nil {
+		return snapshot, nil
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsForbidden(err) {
+		return nil, clusterError(err)
+	}
+	if err == nil {
+		snapshot.Nodes = nodes.Items
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Storage is the state of the StatefulSets and of their volumes and backups in a namespace.
+type Storage struct {
+	Namespace    string
+	StatefulSets []appsv1.StatefulSet
+	PVCs         []corev1.PersistentVolumeClaim
+	// PVs and StorageClasses are empty when the user is not allowed to list them.
+	PVs            []corev1.PersistentVolume
+	StorageClasses []storagev1.StorageClass
+	// BackupSchedules and VolumeSnapshots are empty when Velero or the
+	// snapshot CRDs are not installed or not accessible.
+	BackupSchedules []BackupSchedule
+	VolumeSnapshots []VolumeSnapshot
+}
+
+// BackupTemplate is the subset of a Velero backup spec used to check the backup coverage.
+type BackupTemplate struct {
+	IncludedNamespaces       []string              `json:"includedNamespaces,omitempty"`
+	ExcludedNamespaces       []string              `json:"excludedNamespaces,omitempty"`
+	LabelSelector            *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	SnapshotVolumes          *bool                 `json:"snapshotVolumes,omitempty"`
+	DefaultVolumesToFsBackup *bool                 `json:"defaultVolumesToFsBackup,omitempty"`
+}
+
+// BackupScheduleSpec is the spec of a Velero Schedule.
+type BackupScheduleSpec struct {
+	Schedule string         `json:"schedule"`
+	Paused   bool           `json:"paused,omitempty"`
+	Template BackupTemplate `json:"template"`
+}
+
+// BackupScheduleStatus is the status of a Velero Schedule.
+type BackupScheduleStatus struct {
+	Phase      string       `json:"phase,omitempty"`
+	LastBackup *metav1.Time `json:"lastBackup,omitempty"`
+}
+
+// BackupSchedule is the subset of a Velero Schedule used to check the backup coverage.
+type BackupSchedule struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BackupScheduleSpec   `json:"spec"`
+	Status            BackupScheduleStatus `json:"status,omitempty"`
+}
+
+// VolumeSnapshotSource is the source of a VolumeSnapshot.
+type VolumeSnapshotSource struct {
+	PersistentVolumeClaimName *string `json:"persistentVolumeClaimName,omitempty"`
+}
+
+// VolumeSnapshotSpec is the spec of a VolumeSnapshot.
+type VolumeSnapshotSpec struct {
+	Source VolumeSnapshotSource `json:"source"`
+}
+
+// VolumeSnapshotStatus is the status of a VolumeSnapshot.
+type VolumeSnapshotStatus struct {
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+	ReadyToUse   *bool        `json:"readyToUse,omitempty"`
+}
+
+// VolumeSnapshot is the subset of a CSI VolumeSnapshot used to check the backup coverage.
+type VolumeSnapshot struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              VolumeSnapshotSpec    `json:"spec"`
+	Status            *VolumeSnapshotStatus `json:"status,omitempty"`
+}
+
+// GetStorage gets the StatefulSets and PersistentVolumeClaims in the given
+// namespace, together with the PersistentVolumes, StorageClasses, Velero
+// Schedules and VolumeSnapshots protecting their data.
+func GetStorage(namespace string) (*Storage, error) {
+	if err := authorizeNamespace(namespace); err != nil {
+		return nil, err
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	storage := &Storage{Namespace: namespace}
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	storage.StatefulSets = statefulSets.Items
+
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, clusterError(err)
+	}
+	storage.PVCs = pvcs.Items
+
+	// Namespace-scoped users may not list the cluster-scoped resources.
+	if authorizeClusterScoped(false) == nil {
+		pvs, err := clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		if err != nil && !apierrors.IsForbidden(err) {
+			return nil, clusterError(err)
+		}
+		if err == nil {
+			storage.PVs = pvs.Items
+		}
+		storageClasses, err := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+		if err != nil && !apierrors.IsForbidden(err) {
+			return nil, clusterError(err)
+		}
+		if err == nil {
+			storage.StorageClasses = storageClasses.Items
+		}
+	}
+
+	// The Velero Schedules live in the namespace of Velero, which may be outside of the user's tenants.
+	if authorizeNamespace(metav1.NamespaceAll) == nil {
+		if err := listCustomResources(ctx, dynamicClient, "velero.io", []string{"v1"}, "schedules", metav1.NamespaceAll, &storage.BackupSchedules); err != nil {
+			return nil, err
+		}
+	}
+	if err := listCustomResources(ctx, dynamicClient, "snapshot.storage.k8s.io", []string{"v1"}, "volumesnapshots", namespace, &storage.VolumeSnapshots); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
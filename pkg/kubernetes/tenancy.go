@@ -0,0 +1,69 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubernetes
+
+import (
+	"github.com/feiskyer/kube-copilot/pkg/tenancy"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Tenancy, if set, restricts the clusters and namespaces accessible to the user.
+var Tenancy *tenancy.Scope
+
+// CurrentNamespace returns the namespace of the selected kubeconfig context,
+// which kubectl uses when no namespace is given ("default" if unset).
+func CurrentNamespace() string {
+	return ContextNamespace(Context)
+}
+
+// ContextNamespace returns the namespace of a kubeconfig context (the current
+// one if empty), which kubectl uses when no namespace is given ("default" if unset).
+func ContextNamespace(context string) string {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	namespace, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// authorizeCluster checks the access to the selected cluster with Tenancy.
+func authorizeCluster() error {
+	if Tenancy == nil {
+		return nil
+	}
+	return Tenancy.CheckCluster(CurrentContext())
+}
+
+// authorizeNamespace checks the access to the namespace ("" for all the
+// namespaces) of the selected cluster with Tenancy.
+func authorizeNamespace(namespace string) error {
+	if Tenancy == nil {
+		return nil
+	}
+	return Tenancy.CheckNamespace(CurrentContext(), namespace)
+}
+
+// authorizeClusterScoped checks the access to the cluster-scoped resources of
+// the selected cluster with Tenancy.
+func authorizeClusterScoped(write bool) error {
+	if Tenancy == nil {
+		return nil
+	}
+	return Tenancy.CheckClusterScoped(CurrentContext(), write)
+}
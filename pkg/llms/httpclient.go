@@ -0,0 +1,126 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package llms
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Settings of the HTTP transport shared by the LLM clients.
+const (
+	maxIdleConnsPerHost = 32
+	idleConnTimeout     = 90 * time.Second
+	dnsCacheTTL         = time.Minute
+)
+
+var (
+	httpClientOnce sync.Once
+	httpClient     *http.Client
+)
+
+// HTTPClient returns the HTTP client shared by all the LLM clients of the
+// process. Its transport keeps the connections to the provider alive, uses
+// HTTP/2 when available and caches the DNS lookups, so that the requests of
+// the agent loops and of the servers do not pay for a new connection each.
+func HTTPClient() *http.Client {
+	httpClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&cachingDialer{
+			dialer:   &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+			resolver: net.DefaultResolver,
+			ttl:      dnsCacheTTL,
+		}).DialContext
+		transport.ForceAttemptHTTP2 = true
+		transport.MaxIdleConns = 100
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		transport.IdleConnTimeout = idleConnTimeout
+		httpClient = &http.Client{Transport: transport}
+	})
+	return httpClient
+}
+
+// cachingDialer dials the addresses resolved by the previous lookups of the
+// host for ttl, instead of resolving it for each new connection.
+type cachingDialer struct {
+	dialer   *net.Dialer
+	resolver interface {
+		LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	}
+	ttl time.Duration
+
+	lock    sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// DialContext implements the DialContext of http.Transport.
+func (d *cachingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	for _, addr := range addrs {
+		conn, err = d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	// The cached addresses may be stale: resolve the host again next time.
+	d.lock.Lock()
+	delete(d.entries, host)
+	d.lock.Unlock()
+	return nil, err
+}
+
+// lookup returns the addresses of host, from the cache if not expired.
+func (d *cachingDialer) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	d.lock.Lock()
+	entry, ok := d.entries[host]
+	d.lock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.entries == nil {
+		d.entries = map[string]dnsEntry{}
+	}
+	d.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+	return addrs, nil
+}
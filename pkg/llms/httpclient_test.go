@@ -0,0 +1,89 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package llms
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// countingResolver resolves every host to the loopback address.
+type countingResolver struct {
+	lookups int
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups++
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func TestCachingDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	resolver := &countingResolver{}
+	dialer := &cachingDialer{dialer: &net.Dialer{Timeout: time.Second}, resolver: resolver, ttl: time.Minute}
+	for i := 0; i < 3; i++ {
+		conn, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort("llm.example.com", port))
+		if err != nil {
+			t.Fatalf("DialContext() error = %v", err)
+		}
+		conn.Close()
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("lookups = %d, want the address cached after the first one", resolver.lookups)
+	}
+
+	// Expired entries are resolved again.
+	dialer.entries["llm.example.com"] = dnsEntry{addrs: dialer.entries["llm.example.com"].addrs, expires: time.Now().Add(-time.Second)}
+	if conn, err := dialer.DialContext(context.Background(), "tcp", net.JoinHostPort("llm.example.com", port)); err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	} else {
+		conn.Close()
+	}
+	if resolver.lookups != 2 {
+		t.Errorf("lookups = %d, want the expired address resolved again", resolver.lookups)
+	}
+
+	// IP addresses are dialed directly.
+	if conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String()); err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	} else {
+		conn.Close()
+	}
+	if resolver.lookups != 2 {
+		t.Errorf("lookups = %d, want no lookup for an IP address", resolver.lookups)
+	}
+
+	if HTTPClient() != HTTPClient() {
+		t.Errorf("HTTPClient() should return the shared client")
+	}
+}
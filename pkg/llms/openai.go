@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -43,6 +44,7 @@ func NewOpenAIClient() (*OpenAIClient, error) {
 		if baseURL != "" {
 			config.BaseURL = baseURL
 		}
+		config.HTTPClient = HTTPClient()
 
 		return &OpenAIClient{
 			Retries: 5,
@@ -65,6 +67,7 @@ func NewOpenAIClient() (*OpenAIClient, error) {
 		config.AzureModelMapperFunc = func(model string) string {
 			return regexp.MustCompile(`[.:]`).ReplaceAllString(model, "")
 		}
+		config.HTTPClient = HTTPClient()
 
 		return &OpenAIClient{
 			Retries: 5,
@@ -73,7 +76,7 @@ func NewOpenAIClient() (*OpenAIClient, error) {
 		}, nil
 	}
 
-	return nil, fmt.Errorf("OPENAI_API_KEY or AZURE_OPENAI_API_KEY is not set")
+	return nil, fmt.Errorf("%w: OPENAI_API_KEY or AZURE_OPENAI_API_KEY is not set", errdefs.ErrProviderAuth)
 }
 
 func (c *OpenAIClient) Chat(model string, maxTokens int, prompts []openai.ChatCompletionMessage) (string, error) {
@@ -102,8 +105,8 @@ func (c *OpenAIClient) Chat(model string, maxTokens int, prompts []openai.ChatCo
 
 		if errors.As(err, &e) {
 			switch e.HTTPStatusCode {
-			case 401:
-				return "", err
+			case 401, 403:
+				return "", fmt.Errorf("%w: %v", errdefs.ErrProviderAuth, err)
 			case 429, 500:
 				time.Sleep(backoff)
 				backoff *= 2
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package llms
+
+import (
+	"sort"
+	"strings"
+)
+
+// modelPrice is the price in USD per million tokens.
+type modelPrice struct {
+	prompt     float64
+	completion float64
+}
+
+// pricesPerModel are the list prices of OpenAI models, matched by the longest prefix.
+var pricesPerModel = map[string]modelPrice{
+	"gpt-3.5-turbo": {prompt: 0.5, completion: 1.5},
+	"gpt-4":         {prompt: 30, completion: 60},
+	"gpt-4-32k":     {prompt: 60, completion: 120},
+	"gpt-4-turbo":   {prompt: 10, completion: 30},
+	"gpt-4o":        {prompt: 2.5, completion: 10},
+	"gpt-4o-mini":   {prompt: 0.15, completion: 0.6},
+	"gpt-4.1":       {prompt: 2, completion: 8},
+	"gpt-4.1-mini":  {prompt: 0.4, completion: 1.6},
+	"gpt-4.1-nano":  {prompt: 0.1, completion: 0.4},
+	"o1":            {prompt: 15, completion: 60},
+	"o1-mini":       {prompt: 1.1, completion: 4.4},
+	"o3-mini":       {prompt: 1.1, completion: 4.4},
+}
+
+// EstimateCost returns the estimated cost in USD for the given token usage,
+// and false if the price of the model is unknown.
+func EstimateCost(model string, promptTokens, completionTokens int64) (float64, bool) {
+	model = strings.ToLower(model)
+	prefixes := make([]string, 0, len(pricesPerModel))
+	for prefix := range pricesPerModel {
+		prefixes = append(prefixes, prefix)
+	}
+	// Longest prefix first, so that gpt-4o-mini is not priced as gpt-4o.
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(model, prefix) {
+			price := pricesPerModel[prefix]
+			return (float64(promptTokens)*price.prompt + float64(completionTokens)*price.completion) / 1e6, true
+		}
+	}
+	return 0, false
+}
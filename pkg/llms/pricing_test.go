@@ -0,0 +1,42 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package llms
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		model     string
+		want      float64
+		wantKnown bool
+	}{
+		{model: "gpt-4o", want: 2.5 + 10, wantKnown: true},
+		{model: "gpt-4o-mini", want: 0.15 + 0.6, wantKnown: true},
+		{model: "gpt-4o-2024-08-06", want: 2.5 + 10, wantKnown: true},
+		{model: "llama3", wantKnown: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, known := EstimateCost(tt.model, 1e6, 1e6)
+			if known != tt.wantKnown || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("EstimateCost(%s) = %v, %v, want %v, %v", tt.model, got, known, tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
@@ -154,3 +154,14 @@ func ConstrictPrompt(prompt string, model string, tokenLimits int) string {
 		}
 	}
 }
+
+// EstimateTokens approximates the number of tokens of a text without the
+// tokenizer files, which are downloaded on first use: words count for one
+// token per four characters, and the whitespace is merged with the next word.
+func EstimateTokens(text string) int {
+	tokens := 0
+	for _, word := range strings.Fields(text) {
+		tokens += (len(word) + 3) / 4
+	}
+	return tokens
+}
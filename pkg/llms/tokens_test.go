@@ -99,3 +99,20 @@ func TestConstrictPrompt(t *testing.T) {
 		})
 	}
 }
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "pod", want: 1},
+		{text: "nginx-7c5ddbdf54-x2v9k   1/1     Running", want: 9},
+		{text: "a  \n\n   b", want: 2},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProtocolVersion is the latest Model Context Protocol version supported by the server.
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a tool exposed to MCP clients.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	// Handler runs the tool with the arguments sent by the client.
+	Handler func(args map[string]interface{}) (string, error) `json:"-"`
+}
+
+// Server is a Model Context Protocol server over the stdio transport
+// (newline-delimited JSON-RPC 2.0 messages).
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+}
+
+// NewServer creates a MCP server exposing the given tools.
+func NewServer(name, version string, tools []Tool) *Server {
+	return &Server{Name: name, Version: version, Tools: tools}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads the requests from r and writes the responses to w until r is
+// closed or ctx is canceled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				return scanner.Err()
+			}
+			if len(line) == 0 {
+				continue
+			}
+			if resp := s.handle(line); resp != nil {
+				if err := encoder.Encode(resp); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// handle processes a message and returns its response, or nil for notifications.
+func (s *Server) handle(message []byte) *response {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}}
+	}
+	if req.ID == nil {
+		// Notifications (e.g. notifications/initialized) have no response.
+		return nil
+	}
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return resp
+	}
+
+	switch req.Method {
+	case "initialize":
+		resp.Result = s.initialize(req.Params)
+	case "ping":
+		resp.Result = map[string]interface{}{}
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": s.Tools}
+	case "tools/call":
+		result, err := s.callTool(req.Params)
+		if err != nil {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		} else {
+			resp.Result = result
+		}
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)}
+	}
+	return resp
+}
+
+func (s *Server) initialize(params json.RawMessage) interface{} {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(params, &p)
+
+	// Agree on the client version if it's supported, or propose ours otherwise.
+	version := ProtocolVersion
+	if p.ProtocolVersion == "2024-11-05" || p.ProtocolVersion == "2025-03-26" {
+		version = p.ProtocolVersion
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+		"serverInfo":      map[string]interface{}{"name": s.Name, "version": s.Version},
+	}
+}
+
+// callTool runs the requested tool. Tool failures are reported in the result
+// (isError) so that the model can see them; protocol errors are returned.
+func (s *Server) callTool(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid tools/call params: %v", err)
+	}
+
+	for _, tool := range s.Tools {
+		if tool.Name != p.Name {
+			continue
+		}
+		if p.Arguments == nil {
+			p.Arguments = map[string]interface{}{}
+		}
+		output, err := tool.Handler(p.Arguments)
+		if err != nil {
+			output = strings.TrimSpace(fmt.Sprintf("%s\n\nError: %v", output, err))
+		}
+		return map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": output}},
+			"isError": err != nil,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown tool %q", p.Name)
+}
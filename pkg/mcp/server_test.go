@@ -0,0 +1,133 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	server := NewServer("kube-copilot", "test", []Tool{
+		{
+			Name:        "echo",
+			Description: "Echo the message",
+			InputSchema: objectSchema(map[string]string{"message": "The message"}, "message"),
+			Handler: func(args map[string]interface{}) (string, error) {
+				message, err := stringArg(args, "message", true)
+				if err != nil {
+					return "", err
+				}
+				if message == "fail" {
+					return "partial output", fmt.Errorf("failed")
+				}
+				return message, nil
+			},
+		},
+	})
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"message":"hello"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{"message":"fail"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"unknown"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"resources/list"}`,
+		`not json`,
+	}, "\n")
+
+	var output bytes.Buffer
+	if err := server.Serve(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	var responses []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 7 {
+		t.Fatalf("got %d responses, want 7 (no response to notifications):\n%s", len(responses), output.String())
+	}
+
+	tests := []struct {
+		name      string
+		response  map[string]interface{}
+		wantInRes string
+		wantCode  float64
+	}{
+		{name: "initialize", response: responses[0], wantInRes: `"protocolVersion":"2024-11-05"`},
+		{name: "tools/list", response: responses[1], wantInRes: `"name":"echo"`},
+		{name: "tools/call", response: responses[2], wantInRes: `"text":"hello"`},
+		{name: "tools/call failure", response: responses[3], wantInRes: `"isError":true`},
+		{name: "unknown tool", response: responses[4], wantCode: codeInvalidParams},
+		{name: "unknown method", response: responses[5], wantCode: codeMethodNotFound},
+		{name: "parse error", response: responses[6], wantCode: codeParseError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantCode != 0 {
+				rpcErr, _ := tt.response["error"].(map[string]interface{})
+				if rpcErr == nil || rpcErr["code"] != tt.wantCode {
+					t.Errorf("response %v, want error code %v", tt.response, tt.wantCode)
+				}
+				return
+			}
+			result, _ := json.Marshal(tt.response["result"])
+			if !strings.Contains(string(result), tt.wantInRes) {
+				t.Errorf("result %s, want %s", result, tt.wantInRes)
+			}
+		})
+	}
+}
+
+func TestStringArg(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		arg      string
+		required bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "string", args: map[string]interface{}{"name": " nginx "}, arg: "name", want: "nginx"},
+		{name: "bool", args: map[string]interface{}{"previous": true}, arg: "previous", want: "true"},
+		{name: "number", args: map[string]interface{}{"tail": float64(50)}, arg: "tail", want: "50"},
+		{name: "missing optional", args: map[string]interface{}{}, arg: "container"},
+		{name: "missing required", args: map[string]interface{}{}, arg: "name", required: true, wantErr: true},
+		{name: "spaces in name", args: map[string]interface{}{"name": "nginx --all-namespaces"}, arg: "name", wantErr: true},
+		{name: "spaces in command", args: map[string]interface{}{"command": "get pods -A"}, arg: "command", want: "get pods -A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stringArg(tt.args, tt.arg, tt.required)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("stringArg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("stringArg() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
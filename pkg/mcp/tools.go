@@ -0,0 +1,169 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// DefaultTools returns the kube-copilot tools exposed over MCP. They run
+// through the tools package, so read-only mode and the audit log apply.
+func DefaultTools() []Tool {
+	return []Tool{
+		{
+			Name:        "kubectl",
+			Description: "Run a kubectl command against the current cluster (e.g. \"get pods -n kube-system\").",
+			InputSchema: objectSchema(map[string]string{"command": "The kubectl command without the kubectl prefix"}, "command"),
+			Handler: func(args map[string]interface{}) (string, error) {
+				command, err := stringArg(args, "command", true)
+				if err != nil {
+					return "", err
+				}
+				return tools.Kubectl(command)
+			},
+		},
+		{
+			Name:        "trivy",
+			Description: "Scan a container image for vulnerabilities with trivy.",
+			InputSchema: objectSchema(map[string]string{"image": "The container image to scan (e.g. nginx:1.25)"}, "image"),
+			Handler: func(args map[string]interface{}) (string, error) {
+				image, err := stringArg(args, "image", true)
+				if err != nil {
+					return "", err
+				}
+				return tools.Trivy(image)
+			},
+		},
+		{
+			Name:        "helm",
+			Description: "Run a helm command against the current cluster (e.g. \"list -A\" or \"status nginx -n web\").",
+			InputSchema: objectSchema(map[string]string{"command": "The helm command without the helm prefix"}, "command"),
+			Handler: func(args map[string]interface{}) (string, error) {
+				command, err := stringArg(args, "command", true)
+				if err != nil {
+					return "", err
+				}
+				return tools.Helm(command)
+			},
+		},
+		{
+			Name:        "events",
+			Description: "List the Kubernetes events of a namespace, optionally only those of an object, sorted by time.",
+			InputSchema: objectSchema(map[string]string{
+				"namespace": "The namespace (defaults to default)",
+				"name":      "The name of the involved object (e.g. a Pod name)",
+			}),
+			Handler: func(args map[string]interface{}) (string, error) {
+				namespace, name, err := namespaceAndName(args, false)
+				if err != nil {
+					return "", err
+				}
+				command := fmt.Sprintf("get events -n %s --sort-by=.lastTimestamp", namespace)
+				if name != "" {
+					command += " --field-selector involvedObject.name=" + name
+				}
+				return tools.Kubectl(command)
+			},
+		},
+		{
+			Name:        "logs",
+			Description: "Fetch the logs of a Pod container.",
+			InputSchema: objectSchema(map[string]string{
+				"namespace": "The namespace (defaults to default)",
+				"name":      "The Pod name",
+				"container": "The container name (defaults to the only or default container)",
+				"previous":  "Fetch the logs of the previous (crashed) container instance, true or false",
+				"tail":      "The number of recent lines (defaults to 200)",
+			}, "name"),
+			Handler: func(args map[string]interface{}) (string, error) {
+				namespace, name, err := namespaceAndName(args, true)
+				if err != nil {
+					return "", err
+				}
+				command := fmt.Sprintf("logs %s -n %s", name, namespace)
+				if container, _ := stringArg(args, "container", false); container != "" {
+					command += " -c " + container
+				}
+				if previous, _ := stringArg(args, "previous", false); previous == "true" {
+					command += " --previous"
+				}
+				tail, _ := stringArg(args, "tail", false)
+				if tail == "" {
+					tail = "200"
+				}
+				return tools.Kubectl(command + " --tail=" + tail)
+			},
+		},
+	}
+}
+
+// objectSchema returns the JSON schema of an object with string properties.
+func objectSchema(properties map[string]string, required ...string) map[string]interface{} {
+	props := map[string]interface{}{}
+	for name, description := range properties {
+		props[name] = map[string]interface{}{"type": "string", "description": description}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// stringArg returns the argument as a string. Values are single words or
+// commands, so spaces are only allowed in commands.
+func stringArg(args map[string]interface{}, name string, required bool) (string, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		if required {
+			return "", fmt.Errorf("argument %s is required", name)
+		}
+		return "", nil
+	}
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case bool, float64:
+		s = fmt.Sprint(v)
+	default:
+		return "", fmt.Errorf("argument %s should be a string", name)
+	}
+	if required && s == "" {
+		return "", fmt.Errorf("argument %s is required", name)
+	}
+	if name != "command" && strings.ContainsAny(s, " \t\n") {
+		return "", fmt.Errorf("argument %s should not contain spaces", name)
+	}
+	return s, nil
+}
+
+// namespaceAndName returns the namespace (defaults to default) and name arguments.
+func namespaceAndName(args map[string]interface{}, nameRequired bool) (string, string, error) {
+	namespace, err := stringArg(args, "namespace", false)
+	if err != nil {
+		return "", "", err
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	name, err := stringArg(args, "name", nameRequired)
+	return namespace, name, err
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+)
+
+// Kinds are the supported kinds of notification targets.
+var Kinds = []string{"slack", "webhook", "feishu", "dingtalk", "smtp", "jira", "servicenow"}
+
+// maxTextLength bounds the text of the messages (chat webhooks reject large payloads).
+const maxTextLength = 4000
+
+// httpTimeout is the timeout of the webhook requests.
+const httpTimeout = 10 * time.Second
+
+// Message is a notification of findings.
+type Message struct {
+	// Title is a one-line summary, e.g. "2 new findings in namespace default".
+	Title string `json:"title"`
+	// Text is the markdown report.
+	Text string `json:"text"`
+	// Severity is the highest severity of the findings, if known.
+	Severity string `json:"severity,omitempty"`
+}
+
+// Notifier delivers messages to a target.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Notifiers delivers messages to several targets.
+type Notifiers []Notifier
+
+// Notify implements Notifier. It delivers the message to all the targets
+// and returns the errors of the failed ones.
+func (n Notifiers) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Parse creates a Notifier from a target, which is one of:
+//
+//	slack:<incoming webhook URL>
+//	webhook:<URL receiving the Message as JSON>
+//	feishu:<custom bot webhook URL>
+//	dingtalk:<custom robot webhook URL>
+//	smtp://[user:password@]host:port?from=<address>&to=<address>[,<address>...]
+//	jira:<URL> or servicenow:<URL> filing tickets (see ParseTicket)
+func Parse(target string) (Notifier, error) {
+	if strings.HasPrefix(target, "smtp://") {
+		return parseSMTP(target)
+	}
+	if kind, _, _ := strings.Cut(target, ":"); contains(TicketKinds, kind) {
+		return ParseTicket(target)
+	}
+
+	kind, rawURL, ok := strings.Cut(target, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid notification target %q, should be <kind>:<url> with kind one of %s", target, strings.Join(Kinds, ", "))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL in notification target %s:%s", kind, redactURL(rawURL))
+	}
+
+	switch kind {
+	case "slack":
+		return &webhookNotifier{kind: kind, url: rawURL, payload: slackPayload}, nil
+	case "webhook":
+		return &webhookNotifier{kind: kind, url: rawURL, payload: func(msg Message) interface{} { return msg }}, nil
+	case "feishu":
+		return &webhookNotifier{kind: kind, url: rawURL, payload: feishuPayload}, nil
+	case "dingtalk":
+		return &webhookNotifier{kind: kind, url: rawURL, payload: dingtalkPayload}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification target %q, should be one of %s", kind, strings.Join(Kinds, ", "))
+	}
+}
+
+// ParseAll creates the Notifiers of the targets.
+func ParseAll(targets []string) (Notifiers, error) {
+	var notifiers Notifiers
+	for _, target := range targets {
+		notifier, err := Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// prepare redacts the credentials in the message and truncates its text to maxLength.
+func prepare(msg Message, maxLength int) Message {
+	msg.Title = utils.Redact(msg.Title)
+	msg.Text = truncateText(utils.Redact(msg.Text), maxLength)
+	return msg
+}
+
+// webhookNotifier posts the messages as JSON to a chat or generic webhook.
+type webhookNotifier struct {
+	kind    string
+	url     string
+	payload func(Message) interface{}
+}
+
+// Notify implements Notifier.
+func (w *webhookNotifier) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(w.payload(prepare(msg, maxTextLength)))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL carries the webhook token, so don't leak it in the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to notify %s: %v", w.kind, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to notify %s: %s: %s", w.kind, resp.Status, strings.TrimSpace(string(data)))
+	}
+	// Feishu and DingTalk report the errors in the body of 200 responses.
+	var result struct {
+		Code    int    `json:"code"`
+		ErrCode int    `json:"errcode"`
+		Msg     string `json:"msg"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(data, &result) == nil && (result.Code != 0 || result.ErrCode != 0) {
+		return fmt.Errorf("failed to notify %s: %s", w.kind, firstNonEmpty(result.Msg, result.ErrMsg))
+	}
+	return nil
+}
+
+func slackPayload(msg Message) interface{} {
+	return map[string]string{"text": fmt.Sprintf("*%s*\n%s", msg.Title, msg.Text)}
+}
+
+func feishuPayload(msg Message) interface{} {
+	return map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": fmt.Sprintf("%s\n\n%s", msg.Title, msg.Text)},
+	}
+}
+
+func dingtalkPayload(msg Message) interface{} {
+	return map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": msg.Title, "text": fmt.Sprintf("### %s\n\n%s", msg.Title, msg.Text)},
+	}
+}
+
+// smtpNotifier sends the messages by email.
+type smtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func parseSMTP(target string) (Notifier, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification target %s", redactURL(target))
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil || host == "" || port == "" {
+		return nil, fmt.Errorf("invalid notification target %s: host:port is required", redactURL(target))
+	}
+
+	n := &smtpNotifier{addr: u.Host, from: u.Query().Get("from")}
+	for _, to := range strings.Split(u.Query().Get("to"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			n.to = append(n.to, to)
+		}
+	}
+	if n.from == "" || len(n.to) == 0 {
+		return nil, fmt.Errorf("invalid notification target %s: from and to are required", redactURL(target))
+	}
+	if password, ok := u.User.Password(); ok {
+		n.auth = smtp.PlainAuth("", u.User.Username(), password, host)
+	}
+	return n, nil
+}
+
+// Notify implements Notifier.
+func (s *smtpNotifier) Notify(ctx context.Context, msg Message) error {
+	msg = prepare(msg, maxTextLength)
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", strings.ReplaceAll(msg.Title, "\n", " "))
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+
+	// net/smtp doesn't support contexts, so the delivery is abandoned (not
+	// interrupted) when ctx is done.
+	errCh := make(chan error, 1)
+	go func() { errCh <- smtp.SendMail(s.addr, s.auth, s.from, s.to, body.Bytes()) }()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to notify smtp: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to notify smtp: %v", ctx.Err())
+	}
+}
+
+// redactURL hides the credentials and tokens of a URL in error messages.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Path = ""
+	return u.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
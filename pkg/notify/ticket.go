@@ -0,0 +1,245 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TicketKinds are the kinds of notification targets filing tickets.
+var TicketKinds = []string{"jira", "servicenow"}
+
+// maxTicketTextLength bounds the description of the tickets.
+const maxTicketTextLength = 30000
+
+// maxSummaryLength bounds the summary of the tickets.
+const maxSummaryLength = 160
+
+// Ticket is a ticket filed in an issue tracker.
+type Ticket struct {
+	// ID is the key (Jira) or number (ServiceNow) of the ticket.
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// TicketFiler is a Notifier filing the messages as tickets.
+type TicketFiler interface {
+	Notifier
+	File(ctx context.Context, msg Message) (*Ticket, error)
+}
+
+// ParseTicket creates a TicketFiler from a target, which is one of:
+//
+//	jira:https://<user>:<API token>@<host>?project=<key>[&issuetype=<name>][&labels=<label>,...][&priority=false]
+//	servicenow:https://<user>:<password>@<instance>.service-now.com[?<incident field>=<value>&...]
+//
+// Jira Data Center personal access tokens are used as bearer tokens when the
+// user is empty (https://:<token>@<host>).
+func ParseTicket(target string) (TicketFiler, error) {
+	kind, rawURL, ok := strings.Cut(target, ":")
+	if !ok || !contains(TicketKinds, kind) {
+		return nil, fmt.Errorf("invalid ticket target %q, should be <kind>:<url> with kind one of %s", redactTarget(target), strings.Join(TicketKinds, ", "))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL in ticket target %s:%s", kind, redactURL(rawURL))
+	}
+	password, _ := u.User.Password()
+	if u.User == nil || password == "" {
+		return nil, fmt.Errorf("invalid ticket target %s:%s: credentials are required", kind, redactURL(rawURL))
+	}
+
+	t := &ticketFiler{
+		kind:     kind,
+		baseURL:  strings.TrimSuffix((&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(), "/"),
+		user:     u.User.Username(),
+		password: password,
+		params:   u.Query(),
+	}
+	if kind == "jira" && t.params.Get("project") == "" {
+		return nil, fmt.Errorf("invalid ticket target %s:%s: project is required", kind, redactURL(rawURL))
+	}
+	if kind == "servicenow" && t.user == "" {
+		return nil, fmt.Errorf("invalid ticket target %s:%s: user is required", kind, redactURL(rawURL))
+	}
+	return t, nil
+}
+
+// ticketFiler files the messages as Jira issues or ServiceNow incidents.
+type ticketFiler struct {
+	kind     string
+	baseURL  string
+	user     string
+	password string
+	params   url.Values
+}
+
+// Notify implements Notifier.
+func (t *ticketFiler) Notify(ctx context.Context, msg Message) error {
+	_, err := t.File(ctx, msg)
+	return err
+}
+
+// File implements TicketFiler.
+func (t *ticketFiler) File(ctx context.Context, msg Message) (*Ticket, error) {
+	msg = prepare(msg, maxTicketTextLength)
+	if len(msg.Title) > maxSummaryLength {
+		msg.Title = msg.Title[:maxSummaryLength-3] + "..."
+	}
+
+	if t.kind == "jira" {
+		var result struct {
+			Key string `json:"key"`
+		}
+		if err := t.post(ctx, "/rest/api/2/issue", t.jiraPayload(msg), &result); err != nil {
+			return nil, err
+		}
+		return &Ticket{ID: result.Key, URL: t.baseURL + "/browse/" + result.Key}, nil
+	}
+
+	var result struct {
+		Result struct {
+			SysID  string `json:"sys_id"`
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	if err := t.post(ctx, "/api/now/table/incident", t.serviceNowPayload(msg), &result); err != nil {
+		return nil, err
+	}
+	return &Ticket{ID: result.Result.Number, URL: t.baseURL + "/nav_to.do?uri=incident.do?sys_id=" + result.Result.SysID}, nil
+}
+
+// jiraPriorities map the severities to the default Jira priorities.
+var jiraPriorities = map[string]string{"CRITICAL": "Highest", "HIGH": "High", "MEDIUM": "Medium", "LOW": "Low"}
+
+func (t *ticketFiler) jiraPayload(msg Message) interface{} {
+	issueType := t.params.Get("issuetype")
+	if issueType == "" {
+		issueType = "Task"
+	}
+	labels := []string{"kube-copilot"}
+	for _, label := range strings.Split(t.params.Get("labels"), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": t.params.Get("project")},
+		"issuetype":   map[string]string{"name": issueType},
+		"summary":     msg.Title,
+		"description": msg.Text,
+		"labels":      labels,
+	}
+	// Projects without the priority field reject it, hence priority=false.
+	if priority, ok := jiraPriorities[strings.ToUpper(msg.Severity)]; ok && t.params.Get("priority") != "false" {
+		fields["priority"] = map[string]string{"name": priority}
+	}
+	return map[string]interface{}{"fields": fields}
+}
+
+// serviceNowLevels map the severities to the impact and urgency of the
+// incidents, i.e. the priorities P1 (CRITICAL), P2, P3 and P5 (LOW).
+var serviceNowLevels = map[string][2]string{"CRITICAL": {"1", "1"}, "HIGH": {"1", "2"}, "MEDIUM": {"2", "2"}, "LOW": {"3", "3"}}
+
+func (t *ticketFiler) serviceNowPayload(msg Message) interface{} {
+	fields := map[string]string{}
+	// The query parameters of the target are set as fields of the incident,
+	// e.g. assignment_group, caller_id or category.
+	for key := range t.params {
+		fields[key] = t.params.Get(key)
+	}
+	fields["short_description"] = msg.Title
+	fields["description"] = msg.Text
+	if levels, ok := serviceNowLevels[strings.ToUpper(msg.Severity)]; ok {
+		fields["impact"], fields["urgency"] = levels[0], levels[1]
+	}
+	return fields
+}
+
+// post sends the payload as JSON and decodes the response into result.
+func (t *ticketFiler) post(ctx context.Context, path string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if t.user == "" {
+		req.Header.Set("Authorization", "Bearer "+t.password)
+	} else {
+		req.SetBasicAuth(t.user, t.password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to file %s ticket: %v", t.kind, err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to file %s ticket: %s: %s", t.kind, resp.Status, truncateText(strings.TrimSpace(string(data)), 512))
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to file %s ticket: invalid response: %v", t.kind, err)
+	}
+	return nil
+}
+
+// redactTarget hides the URL of an invalid target in error messages.
+func redactTarget(target string) string {
+	kind, rawURL, ok := strings.Cut(target, ":")
+	if !ok {
+		return "<invalid target>"
+	}
+	return kind + ":" + redactURL(rawURL)
+}
+
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
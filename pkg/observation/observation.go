@@ -0,0 +1,438 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package observation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// maxNormalEvents is the number of the most recent Normal events kept when a
+// listing of events is larger than maxEvents; the Warning events are all kept.
+const (
+	maxEvents       = 30
+	maxNormalEvents = 10
+)
+
+// valueFlags are the kubectl flags taking a separate value.
+var valueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--context": true, "--cluster": true, "--kubeconfig": true,
+	"--user": true, "-s": true, "--server": true, "-l": true, "--selector": true,
+	"--field-selector": true, "-o": true, "--output": true, "--sort-by": true, "-c": true,
+	"--container": true, "--request-timeout": true, "--chunk-size": true, "-L": true, "--label-columns": true,
+}
+
+// command is the subset of a kubectl command selecting the formatter.
+type command struct {
+	verb     string
+	resource string
+	output   string
+}
+
+// parseCommand finds the verb, the resource and the output format of a kubectl command.
+func parseCommand(cmd string) command {
+	var c command
+	var args []string
+	fields := strings.Fields(cmd)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if !strings.HasPrefix(field, "-") {
+			args = append(args, field)
+			continue
+		}
+		name, value, hasValue := strings.Cut(field, "=")
+		if !hasValue && valueFlags[name] && i+1 < len(fields) {
+			i++
+			value = fields[i]
+		}
+		if name == "-o" || name == "--output" {
+			c.output = value
+		} else if strings.HasPrefix(name, "-o") && len(name) > 2 && !strings.HasPrefix(name, "--") {
+			// -owide or -oyaml
+			c.output = name[2:]
+		}
+	}
+	if len(args) > 0 && args[0] == "kubectl" {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		c.verb = args[0]
+	}
+	if len(args) > 1 {
+		c.resource = strings.ToLower(strings.SplitN(args[1], "/", 2)[0])
+	}
+	return c
+}
+
+// Compact converts the output of common kubectl commands into a compact form
+// before it enters the prompt: tables (e.g. "get pods -o wide") without the
+// alignment and the columns empty in every row, deduplicated events, describe
+// outputs without the defaults, and manifests without the managed fields.
+// The output is returned unchanged for other commands, when it cannot be
+// parsed, or when the compact form is not shorter.
+func Compact(cmd, output string) string {
+	c := parseCommand(cmd)
+	var compacted string
+	switch {
+	case c.verb == "get" && (c.output == "yaml" || c.output == "json"):
+		compacted = compactManifest(output, c.output)
+	case c.verb == "get" && (c.output == "" || c.output == "wide") && isEvents(c.resource):
+		compacted = compactEvents(output)
+	case c.verb == "get" && (c.output == "" || c.output == "wide"):
+		compacted = compactTables(output)
+	case c.verb == "events":
+		compacted = compactEvents(output)
+	case c.verb == "describe":
+		compacted = compactDescribe(output)
+	}
+	if compacted == "" || len(strings.TrimSpace(compacted)) >= len(strings.TrimSpace(output)) {
+		return output
+	}
+	return compacted
+}
+
+func isEvents(resource string) bool {
+	return resource == "events" || resource == "event" || resource == "ev" || strings.HasPrefix(resource, "events.")
+}
+
+// table is a kubectl table parsed with the positions of its header columns.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+var columnSeparator = regexp.MustCompile(`\S+(?: \S+)*`)
+
+// parseTable parses an aligned kubectl table. Headers may contain single
+// spaces (e.g. "NOMINATED NODE"), so the columns are separated by two or more
+// spaces in the header and the cells are cut at the header positions.
+func parseTable(lines []string) (*table, bool) {
+	if len(lines) < 1 {
+		return nil, false
+	}
+	header := lines[0]
+	positions := columnSeparator.FindAllStringIndex(header, -1)
+	if len(positions) < 2 {
+		return nil, false
+	}
+
+	t := &table{}
+	for _, p := range positions {
+		t.headers = append(t.headers, header[p[0]:p[1]])
+	}
+	for _, line := range lines[1:] {
+		row := make([]string, len(positions))
+		for i, p := range positions {
+			start := p[0]
+			if start > len(line) {
+				break
+			}
+			// The cells start at the header positions, after a space.
+			if start > 0 && line[start-1] != ' ' {
+				return nil, false
+			}
+			end := len(line)
+			if i+1 < len(positions) && positions[i+1][0] < end {
+				end = positions[i+1][0]
+			}
+			row[i] = strings.TrimSpace(line[start:end])
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, true
+}
+
+// format renders the table with two spaces between the cells, dropping the
+// columns that are empty or "<none>" in every row.
+func (t *table) format() string {
+	keep := make([]bool, len(t.headers))
+	for i := range t.headers {
+		for _, row := range t.rows {
+			if row[i] != "" && row[i] != "<none>" {
+				keep[i] = true
+				break
+			}
+		}
+	}
+
+	var sb strings.Builder
+	write := func(cells []string) {
+		var kept []string
+		for i, cell := range cells {
+			if keep[i] {
+				kept = append(kept, cell)
+			}
+		}
+		sb.WriteString(strings.Join(kept, "  "))
+		sb.WriteString("\n")
+	}
+	write(t.headers)
+	for _, row := range t.rows {
+		write(row)
+	}
+	return sb.String()
+}
+
+// compactTables compacts the tables of a "kubectl get" output, which has one
+// table per resource type separated by blank lines.
+func compactTables(output string) string {
+	var blocks []string
+	for _, block := range strings.Split(strings.TrimRight(output, "\n"), "\n\n") {
+		lines := strings.Split(block, "\n")
+		t, ok := parseTable(lines)
+		if !ok || lines[0] != strings.ToUpper(lines[0]) {
+			blocks = append(blocks, block)
+			continue
+		}
+		blocks = append(blocks, strings.TrimRight(t.format(), "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// compactEvents renders the events as "<last seen> <type> <reason> <object>: <message>"
+// lines, merging the duplicates and keeping only the most recent Normal events
+// of large listings.
+func compactEvents(output string) string {
+	t, ok := parseTable(strings.Split(strings.TrimRight(output, "\n"), "\n"))
+	if !ok {
+		return ""
+	}
+	column := map[string]int{}
+	for i, header := range t.headers {
+		column[header] = i
+	}
+	cell := func(row []string, name string) string {
+		if i, ok := column[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+	if _, ok := column["MESSAGE"]; !ok {
+		return ""
+	}
+
+	type event struct {
+		lastSeen, kind, reason, object, message string
+		count                                   int
+	}
+	var events []*event
+	seen := map[string]*event{}
+	for _, row := range t.rows {
+		object := cell(row, "OBJECT")
+		if namespace := cell(row, "NAMESPACE"); namespace != "" {
+			object = namespace + "/" + object
+		}
+		e := &event{lastSeen: cell(row, "LAST SEEN"), kind: cell(row, "TYPE"), reason: cell(row, "REASON"), object: object, message: cell(row, "MESSAGE"), count: 1}
+		key := strings.Join([]string{e.kind, e.reason, e.object, e.message}, "\x00")
+		if previous, ok := seen[key]; ok {
+			// kubectl lists the events oldest first, so the last one is the most recent.
+			previous.count++
+			previous.lastSeen = e.lastSeen
+			continue
+		}
+		seen[key] = e
+		events = append(events, e)
+	}
+
+	omitted := 0
+	if len(events) > maxEvents {
+		normals := 0
+		for _, e := range events {
+			if e.kind == "Normal" {
+				normals++
+			}
+		}
+		var kept []*event
+		for _, e := range events {
+			if e.kind == "Normal" && normals > maxNormalEvents {
+				normals--
+				omitted++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		events = kept
+	}
+
+	var sb strings.Builder
+	if omitted > 0 {
+		fmt.Fprintf(&sb, "(%d older Normal events omitted)\n", omitted)
+	}
+	for _, e := range events {
+		fmt.Fprintf(&sb, "%s %s %s %s: %s", e.lastSeen, e.kind, e.reason, e.object, e.message)
+		if e.count > 1 {
+			fmt.Fprintf(&sb, " (x%d)", e.count)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+var (
+	describeNoneLine       = regexp.MustCompile(`^\s*[A-Za-z][\w .\-/]*:\s+<none>\s*$`)
+	describeKeyValue       = regexp.MustCompile(`^(\s*[A-Za-z][\w .\-/()]*:)\s{2,}(\S.*)$`)
+	describeTokenVolume    = regexp.MustCompile(`^(\s+)kube-api-access-[a-z0-9]+:\s*$`)
+	describeTokenMount     = regexp.MustCompile(`/var/run/secrets/kubernetes\.io/serviceaccount from kube-api-access-[a-z0-9]+`)
+	describeDefaultTolerat = regexp.MustCompile(`node\.kubernetes\.io/(not-ready|unreachable):NoExecute op=Exists for 300s`)
+	alignment              = regexp.MustCompile(`(\S)\s{3,}`)
+)
+
+// compactDescribe drops the empty fields, the default tolerations and the
+// service account token volume of a "kubectl describe" output, removes the
+// alignment of the values and renders the events one per line.
+func compactDescribe(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	var out []string
+	// keyIndent is the indentation of the last key, whose values continue on
+	// the following lines aligned with the first value (e.g. the labels).
+	keyIndent := -1
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " ")
+		if indent := indentation(line); keyIndent >= 0 && indent > keyIndent+4 {
+			line = strings.Repeat(" ", keyIndent+2) + strings.TrimSpace(line)
+		}
+
+		// The projected service account token volume is the same for all the Pods.
+		if m := describeTokenVolume.FindStringSubmatch(line); m != nil {
+			for i+1 < len(lines) && indentation(lines[i+1]) > len(m[1]) {
+				i++
+			}
+			continue
+		}
+		// "Events: <none>" is kept to tell that there is no event.
+		if describeTokenMount.MatchString(line) || (describeNoneLine.MatchString(line) && !strings.HasPrefix(line, "Events:")) {
+			continue
+		}
+		if describeDefaultTolerat.MatchString(line) {
+			if strings.HasPrefix(strings.TrimSpace(line), "Tolerations:") {
+				out = append(out, "Tolerations: default not-ready and unreachable for 300s")
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "Events:" && i+1 < len(lines) {
+			events, next := describeEvents(lines[i+1:])
+			if events != nil {
+				out = append(out, "Events:")
+				out = append(out, events...)
+				i += next
+				continue
+			}
+		}
+		if m := describeKeyValue.FindStringSubmatch(line); m != nil {
+			line = m[1] + " " + m[2]
+			keyIndent = indentation(line)
+		} else if strings.HasSuffix(line, ":") {
+			keyIndent = -1
+		}
+		// Shorten the alignment of the tables, e.g. the conditions.
+		out = append(out, alignment.ReplaceAllString(line, "$1  "))
+	}
+
+	// Drop the keys left without values, e.g. "Mounts:" when only the service
+	// account token was mounted.
+	kept := out[:0]
+	for i, line := range out {
+		if strings.HasSuffix(line, ":") && (i+1 == len(out) || indentation(out[i+1]) <= indentation(line)) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n") + "\n"
+}
+
+// describeEvents renders the events table following "Events:" and returns the
+// number of lines consumed (nil if the table cannot be parsed).
+func describeEvents(lines []string) ([]string, int) {
+	var tableLines []string
+	consumed := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || indentation(line) == 0 {
+			break
+		}
+		consumed++
+		// Skip the "----" line under the headers.
+		if strings.Trim(strings.TrimSpace(line), "- ") == "" {
+			continue
+		}
+		tableLines = append(tableLines, strings.TrimRight(line, " "))
+	}
+	t, ok := parseTable(tableLines)
+	if !ok || len(t.headers) != 5 || t.headers[0] != "Type" && t.headers[0] != "TYPE" {
+		return nil, 0
+	}
+
+	events := make([]string, 0, len(t.rows))
+	for _, row := range t.rows {
+		events = append(events, fmt.Sprintf("  %s %s (%s, %s): %s", row[0], row[1], row[2], row[3], row[4]))
+	}
+	return events, consumed
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// compactManifest drops the managed fields and the last applied configuration
+// of the manifests, and renders the JSON manifests without indentation.
+func compactManifest(output, format string) string {
+	var obj interface{}
+	var err error
+	if format == "json" {
+		err = json.Unmarshal([]byte(output), &obj)
+	} else {
+		err = yaml.Unmarshal([]byte(output), &obj)
+	}
+	if err != nil {
+		return ""
+	}
+	stripManagedFields(obj)
+
+	var data []byte
+	if format == "json" {
+		data, err = json.Marshal(obj)
+	} else {
+		data, err = yaml.Marshal(obj)
+	}
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func stripManagedFields(obj interface{}) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	if items, ok := m["items"].([]interface{}); ok {
+		for _, item := range items {
+			stripManagedFields(item)
+		}
+	}
+}
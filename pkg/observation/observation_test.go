@@ -0,0 +1,158 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package observation
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/llms"
+)
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		file        string
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name:        "get pods wide",
+			command:     "kubectl get pods -o wide -n prod",
+			file:        "get-pods-wide.txt",
+			want:        []string{"NAME  READY  STATUS  RESTARTS  AGE  IP  NODE\n", "web-5d4f8-abcde  0/1  CrashLoopBackOff  5 (30s ago)  10m  10.244.1.5  node-1\n"},
+			wantMissing: []string{"NOMINATED NODE", "<none>"},
+		},
+		{
+			name:    "get events",
+			command: "kubectl -n prod get events --sort-by=.lastTimestamp",
+			file:    "get-events.txt",
+			want: []string{
+				"10m Normal Scheduled pod/web-5d4f8-abcde: Successfully assigned prod/web-5d4f8-abcde to node-1\n",
+				"30s Warning BackOff pod/web-5d4f8-abcde: Back-off restarting failed container web in pod web-5d4f8-abcde_prod(1234) (x2)\n",
+			},
+			wantMissing: []string{"LAST SEEN", "2m Warning"},
+		},
+		{
+			name:    "describe pod",
+			command: "kubectl describe pod web-5d4f8-abcde -n prod",
+			file:    "describe-pod.txt",
+			want: []string{
+				"Labels: app=web\n  pod-template-hash=5d4f8\n",
+				"    State: Waiting\n      Reason: CrashLoopBackOff\n",
+				"Conditions:\n  Type  Status\n  Initialized  True\n",
+				"Tolerations: default not-ready and unreachable for 300s\nEvents:\n",
+				"  Warning BackOff (30s (x5 over 9m), kubelet): Back-off restarting failed container web",
+			},
+			wantMissing: []string{"kube-api-access", "Annotations", "Mounts:", "Volumes:", "Node-Selectors", "-------"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Compact(tt.command, string(data))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Compact() = %s\nwant it to contain %q", got, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got, missing) {
+					t.Errorf("Compact() = %s\nwant it without %q", got, missing)
+				}
+			}
+			if before, after := llms.EstimateTokens(string(data)), llms.EstimateTokens(got); after >= before {
+				t.Errorf("Compact() tokens = %d, want less than %d", after, before)
+			}
+		})
+	}
+}
+
+func TestCompactEventsLimit(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("LAST SEEN   TYPE      REASON    OBJECT         MESSAGE\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&sb, "%-11s Normal    Pulled    pod/web-%02d     Pulled image\n", fmt.Sprintf("%dm", 40-i), i)
+	}
+	sb.WriteString("1m          Warning   Failed    pod/web-00     Error: ImagePullBackOff\n")
+
+	got := Compact("kubectl get events", sb.String())
+	if !strings.HasPrefix(got, "(30 older Normal events omitted)\n") {
+		t.Errorf("Compact() = %s, want the older Normal events omitted", got)
+	}
+	if !strings.Contains(got, "Warning Failed pod/web-00") || !strings.Contains(got, "pod/web-39") || strings.Contains(got, "pod/web-29:") {
+		t.Errorf("Compact() = %s, want the Warning and the last Normal events", got)
+	}
+}
+
+func TestCompactManifest(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Pod
+metadata:
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"apiVersion":"v1","kind":"Pod","metadata":{"name":"web"}}
+  managedFields:
+  - apiVersion: v1
+    fieldsType: FieldsV1
+    manager: kubectl-client-side-apply
+    operation: Update
+  name: web
+spec:
+  containers:
+  - image: nginx
+    name: web
+`
+	got := Compact("kubectl get pod web -o yaml", manifest)
+	if strings.Contains(got, "managedFields") || strings.Contains(got, "last-applied") || !strings.Contains(got, "image: nginx") {
+		t.Errorf("Compact() = %s, want the manifest without the managed fields", got)
+	}
+
+	list := `{
+  "apiVersion": "v1",
+  "items": [{"metadata": {"name": "web", "managedFields": [{"manager": "kubectl"}]}}],
+  "kind": "List"
+}`
+	if got := Compact("kubectl get pods -ojson", list); got != `{"apiVersion":"v1","items":[{"metadata":{"name":"web"}}],"kind":"List"}` {
+		t.Errorf("Compact() = %s, want the compact JSON list", got)
+	}
+}
+
+func TestCompactUnchanged(t *testing.T) {
+	tests := []struct {
+		command string
+		output  string
+	}{
+		{command: "kubectl logs web-5d4f8-abcde -n prod", output: "2024/11/01 10:00:00   [error]   connection refused\n"},
+		{command: "kubectl get pods -n prod", output: "No resources found in prod namespace.\n"},
+		{command: "kubectl get pods -o jsonpath={.items[*].metadata.name}", output: "web   api"},
+		{command: "kubectl get pod web -o yaml", output: "error: the server doesn't have a resource type"},
+		{command: "kubectl top pods -n prod", output: "NAME                CPU(cores)   MEMORY(bytes)\nweb-5d4f8-abcde     1m           12Mi\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := Compact(tt.command, tt.output); got != tt.output {
+				t.Errorf("Compact() = %q, want the output unchanged", got)
+			}
+		})
+	}
+}
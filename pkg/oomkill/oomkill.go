@@ -0,0 +1,250 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package oomkill correlates the OOMKilled restarts of containers with their
+// memory limits and usage history to recommend right-sized limits.
+package oomkill
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/prometheus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	mebibyte = 1 << 20
+	// limitHeadroom is the headroom of the recommended limits over the peak usage.
+	limitHeadroom = 1.25
+	// nearLimit is the share of the limit above which the peak usage is close to an OOM kill.
+	nearLimit = 0.9
+	// minLeakR2 is the minimum fit of the linear trend for a steady growth to suggest a leak.
+	minLeakR2 = 0.8
+)
+
+// workingSetQuery is the PromQL query of the memory working set of the Pod containers,
+// which is what the kubelet compares with the limits.
+const workingSetQuery = `max by (container) (container_memory_working_set_bytes{namespace=%q, pod=%q, container!="", container!="POD"})`
+
+// History queries the memory working set of the Pod containers over the window before now.
+func History(ctx context.Context, client *prometheus.Client, namespace, pod string, window time.Duration) (map[string][]prometheus.Point, error) {
+	end := time.Now()
+	step := max(window/300, 15*time.Second)
+	series, err := client.QueryRange(ctx, fmt.Sprintf(workingSetQuery, namespace, pod), end.Add(-window), end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string][]prometheus.Point, len(series))
+	for _, s := range series {
+		history[s.Metric["container"]] = s.Points
+	}
+	return history, nil
+}
+
+// Usage summarizes the memory working set of a container over a time window.
+type Usage struct {
+	Samples int     `json:"samples"`
+	Average float64 `json:"average"`
+	P95     float64 `json:"p95"`
+	Peak    float64 `json:"peak"`
+	// GrowthPerHour is the slope of the linear trend of the usage (bytes/hour), and R2 its fit.
+	GrowthPerHour float64 `json:"growthPerHour"`
+	R2            float64 `json:"r2"`
+	// Resets is the number of drops of more than half of the usage, e.g. at restarts.
+	Resets int `json:"resets"`
+}
+
+// Container is the memory analysis of a container.
+type Container struct {
+	Name     string `json:"name"`
+	Request  int64  `json:"request,omitempty"`
+	Limit    int64  `json:"limit,omitempty"`
+	Restarts int32  `json:"restarts"`
+	// LastTermination is the reason of the last termination, e.g. OOMKilled.
+	LastTermination string    `json:"lastTermination,omitempty"`
+	LastTerminated  time.Time `json:"lastTerminated,omitempty"`
+	// Usage is nil without usage history.
+	Usage *Usage `json:"usage,omitempty"`
+	// LeakIndicators are the signs of a memory leak.
+	LeakIndicators     []string `json:"leakIndicators,omitempty"`
+	RecommendedRequest int64    `json:"recommendedRequest,omitempty"`
+	RecommendedLimit   int64    `json:"recommendedLimit,omitempty"`
+}
+
+// OOMKilled returns true if the last termination of the container was an OOM kill.
+func (c Container) OOMKilled() bool {
+	return c.LastTermination == "OOMKilled"
+}
+
+// Analyze correlates the restarts and limits of the Pod containers with their
+// memory usage history (by container name, may be empty).
+func Analyze(pod *corev1.Pod, history map[string][]prometheus.Point) []Container {
+	statuses := make(map[string]corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+
+	containers := make([]Container, 0, len(pod.Spec.Containers))
+	for _, spec := range pod.Spec.Containers {
+		c := Container{
+			Name:    spec.Name,
+			Request: spec.Resources.Requests.Memory().Value(),
+			Limit:   spec.Resources.Limits.Memory().Value(),
+		}
+		if status, ok := statuses[spec.Name]; ok {
+			c.Restarts = status.RestartCount
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				c.LastTermination = terminated.Reason
+				c.LastTerminated = terminated.FinishedAt.Time
+			}
+		}
+		if points := history[spec.Name]; len(points) > 0 {
+			c.Usage = Summarize(points)
+		}
+		c.LeakIndicators = leakIndicators(c)
+		c.RecommendedRequest, c.RecommendedLimit = recommend(c)
+		containers = append(containers, c)
+	}
+	return containers
+}
+
+// Summarize computes the statistics and the linear trend of the usage samples.
+func Summarize(points []prometheus.Point) *Usage {
+	values := make([]float64, len(points))
+	var sum float64
+	u := &Usage{Samples: len(points)}
+	for i, p := range points {
+		values[i] = p.Value
+		sum += p.Value
+		u.Peak = math.Max(u.Peak, p.Value)
+		if i > 0 && p.Value < points[i-1].Value/2 {
+			u.Resets++
+		}
+	}
+	u.Average = sum / float64(len(points))
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	u.P95 = sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+
+	// Least squares fit of the usage over the hours since the first sample.
+	if len(points) > 2 {
+		var sx, sy, sxx, sxy, syy float64
+		n := float64(len(points))
+		for _, p := range points {
+			x := p.Time.Sub(points[0].Time).Hours()
+			sx += x
+			sy += p.Value
+			sxx += x * x
+			sxy += x * p.Value
+			syy += p.Value * p.Value
+		}
+		if denominator := n*sxx - sx*sx; denominator > 0 {
+			u.GrowthPerHour = (n*sxy - sx*sy) / denominator
+			if variance := n*syy - sy*sy; variance > 0 {
+				r := (n*sxy - sx*sy) / math.Sqrt(denominator*variance)
+				u.R2 = r * r
+			}
+		}
+	}
+	return u
+}
+
+// leakIndicators returns the signs of a memory leak of the container.
+func leakIndicators(c Container) []string {
+	if c.Usage == nil {
+		return nil
+	}
+
+	var indicators []string
+	u := c.Usage
+	if u.GrowthPerHour > 0 && u.R2 >= minLeakR2 && u.Resets == 0 {
+		indicators = append(indicators, fmt.Sprintf("memory grows steadily by %s/hour (R²=%.2f) without being released", formatBytes(u.GrowthPerHour), u.R2))
+	}
+	if u.Resets > 0 && c.OOMKilled() {
+		indicators = append(indicators, fmt.Sprintf("memory is only released when the container restarts (%d resets), a sawtooth pattern typical of leaks", u.Resets))
+	}
+	if c.Limit > 0 && u.Peak >= nearLimit*float64(c.Limit) && u.P95 < 0.5*u.Peak {
+		indicators = append(indicators, "the usage is usually low but spikes to the limit, which suggests unbounded buffers or caches rather than the steady-state footprint")
+	}
+	return indicators
+}
+
+// recommend returns the right-sized memory request and limit of the container.
+func recommend(c Container) (int64, int64) {
+	if c.Usage == nil {
+		// Without usage history, only OOM killed containers get a higher limit.
+		if c.OOMKilled() && c.Limit > 0 {
+			return c.Request, roundUp(float64(c.Limit) * 1.5)
+		}
+		return 0, 0
+	}
+
+	peak := c.Usage.Peak
+	// The working set can't exceed the limit: an OOM killed container needs more than its peak.
+	if c.OOMKilled() && c.Limit > 0 {
+		peak = math.Max(peak, float64(c.Limit))
+	}
+	return roundUp(c.Usage.P95), roundUp(peak * limitHeadroom)
+}
+
+// roundUp rounds the bytes up to the next 16Mi.
+func roundUp(bytes float64) int64 {
+	const unit = 16 * mebibyte
+	return int64(math.Ceil(bytes/unit)) * unit
+}
+
+// Format renders the analysis of the containers as plain text.
+func Format(containers []Container) string {
+	var sb strings.Builder
+	for _, c := range containers {
+		fmt.Fprintf(&sb, "Container %s: request %s, limit %s, %d restarts", c.Name, formatQuantity(c.Request), formatQuantity(c.Limit), c.Restarts)
+		if c.LastTermination != "" {
+			fmt.Fprintf(&sb, ", last terminated with %s at %s", c.LastTermination, c.LastTerminated.UTC().Format(time.RFC3339))
+		}
+		sb.WriteString("\n")
+		if u := c.Usage; u != nil {
+			fmt.Fprintf(&sb, "- usage (working set, %d samples): average %s, p95 %s, peak %s, trend %+.1fMi/hour (R²=%.2f), %d resets\n",
+				u.Samples, formatBytes(u.Average), formatBytes(u.P95), formatBytes(u.Peak), u.GrowthPerHour/mebibyte, u.R2, u.Resets)
+		} else {
+			sb.WriteString("- usage history: not available\n")
+		}
+		for _, indicator := range c.LeakIndicators {
+			fmt.Fprintf(&sb, "- leak indicator: %s\n", indicator)
+		}
+		if c.RecommendedLimit > 0 {
+			fmt.Fprintf(&sb, "- recommended: request %s, limit %s\n", formatQuantity(c.RecommendedRequest), formatQuantity(c.RecommendedLimit))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatQuantity formats bytes as a Kubernetes quantity in Mi, or "none" if unset.
+func formatQuantity(bytes int64) string {
+	if bytes <= 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%dMi", int64(math.Ceil(float64(bytes)/mebibyte)))
+}
+
+func formatBytes(bytes float64) string {
+	return fmt.Sprintf("%.0fMi", bytes/mebibyte)
+}
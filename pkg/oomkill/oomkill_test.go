@@ -0,0 +1,140 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package oomkill
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// series returns hourly samples of the given values in Mi.
+func series(values ...float64) []prometheus.Point {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]prometheus.Point, len(values))
+	for i, v := range values {
+		points[i] = prometheus.Point{Time: start.Add(time.Duration(i) * time.Hour), Value: v * mebibyte}
+	}
+	return points
+}
+
+func TestSummarize(t *testing.T) {
+	u := Summarize(series(100, 110, 120, 130, 140, 150, 160, 170, 180, 190))
+	if u.Samples != 10 || u.Peak != 190*mebibyte || u.Average != 145*mebibyte || u.P95 != 190*mebibyte {
+		t.Errorf("Summarize() = %+v", u)
+	}
+	if u.GrowthPerHour != 10*mebibyte || u.R2 < 0.99 || u.Resets != 0 {
+		t.Errorf("Summarize() trend = %+v, want 10Mi/hour with a perfect fit", u)
+	}
+
+	if u := Summarize(series(100, 200, 300, 50, 150, 250, 40)); u.Resets != 2 {
+		t.Errorf("Summarize() resets = %d, want 2", u.Resets)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "leaky", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			}},
+			{Name: "undersized", Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+			}},
+			{Name: "growing"},
+			{Name: "sidecar"},
+		}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "leaky", RestartCount: 3, LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "OOMKilled", FinishedAt: metav1.NewTime(time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC)),
+			}}},
+			{Name: "undersized", RestartCount: 5, LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}}},
+		}},
+	}
+	history := map[string][]prometheus.Point{
+		"leaky":   series(100, 180, 250, 60, 150, 240, 70, 160),
+		"growing": series(100, 120, 140, 160, 180, 200),
+	}
+
+	containers := Analyze(pod, history)
+	if len(containers) != 4 {
+		t.Fatalf("Analyze() = %+v, want 4 containers", containers)
+	}
+
+	leaky := containers[0]
+	if !leaky.OOMKilled() || leaky.Restarts != 3 || leaky.Limit != 256*mebibyte || len(leaky.LeakIndicators) != 1 || !strings.Contains(leaky.LeakIndicators[0], "only released when the container restarts") {
+		t.Errorf("Analyze() leaky = %+v, want a sawtooth leak indicator", leaky)
+	}
+	// OOM killed at the limit: the recommended limit is above the limit.
+	if leaky.RecommendedLimit != 320*mebibyte || leaky.RecommendedRequest != 256*mebibyte {
+		t.Errorf("Analyze() leaky recommendation = %d/%d", leaky.RecommendedRequest, leaky.RecommendedLimit)
+	}
+
+	if undersized := containers[1]; undersized.Usage != nil || undersized.RecommendedLimit != 96*mebibyte || len(undersized.LeakIndicators) != 0 {
+		t.Errorf("Analyze() undersized = %+v, want the limit raised by half without history", undersized)
+	}
+	if growing := containers[2]; len(growing.LeakIndicators) != 1 || !strings.Contains(growing.LeakIndicators[0], "grows steadily by 20Mi/hour") {
+		t.Errorf("Analyze() growing = %+v, want a steady growth indicator", growing)
+	}
+	if sidecar := containers[3]; sidecar.Usage != nil || sidecar.RecommendedLimit != 0 {
+		t.Errorf("Analyze() sidecar = %+v, want no recommendation", sidecar)
+	}
+
+	formatted := Format(containers)
+	for _, want := range []string{
+		"Container leaky: request 128Mi, limit 256Mi, 3 restarts, last terminated with OOMKilled at 2025-03-01T06:00:00Z",
+		"- recommended: request 256Mi, limit 320Mi",
+		"Container growing: request none, limit none, 0 restarts",
+		"- usage history: not available",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Format() = %q, want it to contain %q", formatted, want)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if !strings.Contains(r.Form.Get("query"), `namespace="web", pod="web-0"`) {
+			http.Error(w, "unexpected query", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"container":"app"},"values":[[1700000000,"1048576"]]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := prometheus.NewClient(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := History(context.Background(), client, "web", "web-0", time.Hour)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if points := history["app"]; len(points) != 1 || points[0].Value != mebibyte {
+		t.Errorf("History() = %+v", history)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Pod problems that trigger an automatic diagnosis.
+const (
+	ReasonCrashLoopBackOff = "CrashLoopBackOff"
+	ReasonOOMKilled        = "OOMKilled"
+	ReasonImagePullBackOff = "ImagePullBackOff"
+	ReasonFailedScheduling = "FailedScheduling"
+)
+
+// Annotations written on the diagnosed Pods.
+const (
+	AnnotationDiagnosis   = "kube-copilot.io/diagnosis"
+	AnnotationDiagnosedAt = "kube-copilot.io/diagnosed-at"
+)
+
+// maxSummaryLength bounds the diagnosis summary published in Events and annotations.
+const maxSummaryLength = 1024
+
+// DiagnoseFunc diagnoses a Pod and returns the markdown report.
+type DiagnoseFunc func(ctx context.Context, namespace, name string) (string, error)
+
+// DiagnosisResult is the result of an automatic diagnosis.
+type DiagnosisResult struct {
+	Namespace string
+	Pod       string
+	Reason    string
+	Report    string
+	Err       error
+	// Start is the time the diagnosis started.
+	Start time.Time
+}
+
+// podKey identifies a problem of a Pod.
+type podKey struct {
+	namespace string
+	name      string
+	reason    string
+}
+
+// AutoDiagnoser watches the Pods and diagnoses the ones that run into
+// CrashLoopBackOff, OOMKilled, ImagePullBackOff or FailedScheduling.
+// The same problem of a Pod is diagnosed at most once per cooldown, and the
+// diagnoses are rate limited to bound the LLM cost.
+type AutoDiagnoser struct {
+	// PublishEvents records the diagnosis summary as an Event of the Pod.
+	PublishEvents bool
+	// PublishAnnotations writes the diagnosis summary into the Pod annotations.
+	PublishAnnotations bool
+	// OnResult, if set, is called with every diagnosis (e.g. to send notifications).
+	OnResult func(DiagnosisResult)
+
+	client   kubernetes.Interface
+	diagnose DiagnoseFunc
+	cooldown time.Duration
+	limiter  *rate.Limiter
+	factory  informers.SharedInformerFactory
+	queue    workqueue.TypedRateLimitingInterface[podKey]
+	now      func() time.Time
+
+	mu            sync.Mutex
+	lastDiagnosed map[podKey]time.Time
+}
+
+// NewAutoDiagnoser creates an AutoDiagnoser for the Pods in namespace (all
+// namespaces if empty), running at most perHour diagnoses per hour (unlimited
+// if perHour is not positive).
+func NewAutoDiagnoser(client kubernetes.Interface, namespace string, diagnose DiagnoseFunc, cooldown time.Duration, perHour int) *AutoDiagnoser {
+	limit := rate.Inf
+	if perHour > 0 {
+		limit = rate.Every(time.Hour / time.Duration(perHour))
+	}
+	a := &AutoDiagnoser{
+		client:        client,
+		diagnose:      diagnose,
+		cooldown:      cooldown,
+		limiter:       rate.NewLimiter(limit, 1),
+		factory:       informers.NewSharedInformerFactoryWithOptions(client, 10*time.Minute, informers.WithNamespace(namespace)),
+		queue:         workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[podKey]()),
+		now:           time.Now,
+		lastDiagnosed: map[podKey]time.Time{},
+	}
+
+	a.factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    a.handlePod,
+		UpdateFunc: func(_, obj interface{}) { a.handlePod(obj) },
+	})
+	return a
+}
+
+// Run starts the Pod informer and the workers, and blocks until ctx is canceled.
+func (a *AutoDiagnoser) Run(ctx context.Context, workers int) error {
+	defer a.queue.ShutDown()
+
+	a.factory.Start(ctx.Done())
+	for _, synced := range a.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync the Pod informer")
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, func(ctx context.Context) {
+			for a.processNextItem(ctx) {
+			}
+		}, time.Second)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (a *AutoDiagnoser) handlePod(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	if reason := PodProblem(pod); reason != "" {
+		k := podKey{namespace: pod.Namespace, name: pod.Name, reason: reason}
+		if a.shouldDiagnose(k) {
+			a.queue.Add(k)
+		}
+	}
+}
+
+// shouldDiagnose returns true if the problem hasn't been diagnosed within the cooldown.
+func (a *AutoDiagnoser) shouldDiagnose(k podKey) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for key, last := range a.lastDiagnosed {
+		if now.Sub(last) >= a.cooldown {
+			delete(a.lastDiagnosed, key)
+		}
+	}
+	if _, ok := a.lastDiagnosed[k]; ok {
+		return false
+	}
+	a.lastDiagnosed[k] = now
+	return true
+}
+
+func (a *AutoDiagnoser) processNextItem(ctx context.Context) bool {
+	k, quit := a.queue.Get()
+	if quit {
+		return false
+	}
+	defer a.queue.Done(k)
+
+	if err := a.limiter.Wait(ctx); err != nil {
+		return false
+	}
+	a.process(ctx, k)
+	a.queue.Forget(k)
+	return true
+}
+
+// process diagnoses the Pod and publishes the result.
+func (a *AutoDiagnoser) process(ctx context.Context, k podKey) {
+	start := a.now()
+	report, err := a.diagnose(ctx, k.namespace, k.name)
+	result := DiagnosisResult{Namespace: k.namespace, Pod: k.name, Reason: k.reason, Report: report, Err: err, Start: start}
+	if a.OnResult != nil {
+		a.OnResult(result)
+	}
+	if err != nil {
+		return
+	}
+
+	summary := workflows.Summarize(report, maxSummaryLength)
+	if a.PublishEvents {
+		a.publishEvent(ctx, k, summary)
+	}
+	if a.PublishAnnotations {
+		a.publishAnnotations(ctx, k, summary)
+	}
+}
+
+func (a *AutoDiagnoser) publishEvent(ctx context.Context, k podKey, summary string) {
+	pod, err := a.client.CoreV1().Pods(k.namespace).Get(ctx, k.name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	now := metav1.NewTime(a.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: k.name + ".", Namespace: k.namespace},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  k.namespace,
+			Name:       k.name,
+			UID:        pod.UID,
+		},
+		Reason:         "CopilotDiagnosis",
+		Message:        fmt.Sprintf("%s diagnosed: %s", k.reason, summary),
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "kube-copilot"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	a.client.CoreV1().Events(k.namespace).Create(ctx, event, metav1.CreateOptions{})
+}
+
+func (a *AutoDiagnoser) publishAnnotations(ctx context.Context, k podKey, summary string) {
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				AnnotationDiagnosis:   summary,
+				AnnotationDiagnosedAt: a.now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	a.client.CoreV1().Pods(k.namespace).Patch(ctx, k.name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+// PodProblem returns the problem of the Pod that should be diagnosed, or "" if none.
+func PodProblem(pod *corev1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return ""
+	}
+
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.Reason == ReasonOOMKilled {
+			return ReasonOOMKilled
+		}
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case ReasonCrashLoopBackOff:
+				return ReasonCrashLoopBackOff
+			case ReasonImagePullBackOff, "ErrImagePull":
+				return ReasonImagePullBackOff
+			}
+		}
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			return ReasonFailedScheduling
+		}
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodProblem(t *testing.T) {
+	waiting := func(reason string) corev1.PodStatus {
+		return corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}},
+		}}}
+	}
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   string
+	}{
+		{name: "healthy", status: corev1.PodStatus{Phase: corev1.PodRunning}, want: ""},
+		{name: "crash loop", status: waiting("CrashLoopBackOff"), want: ReasonCrashLoopBackOff},
+		{name: "image pull back-off", status: waiting("ImagePullBackOff"), want: ReasonImagePullBackOff},
+		{name: "image pull error", status: waiting("ErrImagePull"), want: ReasonImagePullBackOff},
+		{name: "container creating", status: waiting("ContainerCreating"), want: ""},
+		{
+			name: "OOM killed",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+			}}},
+			want: ReasonOOMKilled,
+		},
+		{
+			name: "unschedulable",
+			status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type:   corev1.PodScheduled,
+				Status: corev1.ConditionFalse,
+				Reason: corev1.PodReasonUnschedulable,
+			}}},
+			want: ReasonFailedScheduling,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PodProblem(&corev1.Pod{Status: tt.status}); got != tt.want {
+				t.Errorf("PodProblem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldDiagnose(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &AutoDiagnoser{cooldown: time.Hour, now: func() time.Time { return now }, lastDiagnosed: map[podKey]time.Time{}}
+	crash := podKey{namespace: "default", name: "nginx", reason: ReasonCrashLoopBackOff}
+	oom := podKey{namespace: "default", name: "nginx", reason: ReasonOOMKilled}
+
+	steps := []struct {
+		after time.Duration
+		key   podKey
+		want  bool
+	}{
+		{key: crash, want: true},
+		{key: crash, want: false},
+		{key: oom, want: true},
+		{after: 30 * time.Minute, key: crash, want: false},
+		{after: 31 * time.Minute, key: crash, want: true},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		if got := a.shouldDiagnose(step.key); got != step.want {
+			t.Errorf("step %d: shouldDiagnose(%v) = %v, want %v", i, step.key, got, step.want)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default", UID: "uid"}}
+	client := fake.NewSimpleClientset(pod)
+	report := "## 1. Image pull error\n\n- **Findings**: The image nginx:latestt doesn't exist.\n- **How to resolve**: Fix the image tag."
+
+	var results []DiagnosisResult
+	a := NewAutoDiagnoser(client, "", func(ctx context.Context, namespace, name string) (string, error) {
+		return report, nil
+	}, time.Hour, 10)
+	a.PublishEvents = true
+	a.PublishAnnotations = true
+	a.OnResult = func(r DiagnosisResult) { results = append(results, r) }
+
+	ctx := context.Background()
+	a.process(ctx, podKey{namespace: "default", name: "nginx", reason: ReasonImagePullBackOff})
+
+	if len(results) != 1 || results[0].Report != report {
+		t.Errorf("OnResult got %v, want one result with the report", results)
+	}
+
+	events, err := client.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("got %d events, want 1", len(events.Items))
+	}
+	if got, want := events.Items[0].Message, "ImagePullBackOff diagnosed: Image pull error"; got != want {
+		t.Errorf("event message = %q, want %q", got, want)
+	}
+	if events.Items[0].InvolvedObject.UID != "uid" {
+		t.Errorf("event involved object = %v, want the Pod", events.Items[0].InvolvedObject)
+	}
+
+	got, err := client.CoreV1().Pods("default").Get(ctx, "nginx", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[AnnotationDiagnosis] != "Image pull error" || got.Annotations[AnnotationDiagnosedAt] == "" {
+		t.Errorf("annotations = %v, want the diagnosis summary", got.Annotations)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Group and Version of the kube-copilot custom resources.
+const (
+	Group   = "kube-copilot.io"
+	Version = "v1alpha1"
+)
+
+// Custom resources handled by the operator.
+var (
+	// DiagnosisResource diagnoses a Pod (spec.pod).
+	DiagnosisResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "diagnoses"}
+	// AuditResource audits the security of a Pod (spec.pod).
+	AuditResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "audits"}
+	// CopilotTaskResource runs the instructions of spec.instructions.
+	CopilotTaskResource = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "copilottasks"}
+)
+
+// Phases of the custom resources (status.phase).
+const (
+	PhaseRunning   = "Running"
+	PhaseCompleted = "Completed"
+	PhaseFailed    = "Failed"
+)
+
+// Runner runs the workflow requested by a custom resource and returns its markdown report.
+type Runner func(ctx context.Context, obj *unstructured.Unstructured) (string, error)
+
+// key identifies a custom resource in the work queue.
+type key struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// Controller runs the workflows requested by the kube-copilot custom
+// resources and writes the results back into their status.
+type Controller struct {
+	client  dynamic.Interface
+	runners map[schema.GroupVersionResource]Runner
+	factory dynamicinformer.DynamicSharedInformerFactory
+	queue   workqueue.TypedRateLimitingInterface[key]
+}
+
+// NewController creates a controller for the resources of runners in the
+// given namespace (all namespaces if empty).
+func NewController(client dynamic.Interface, namespace string, runners map[schema.GroupVersionResource]Runner) *Controller {
+	c := &Controller{
+		client:  client,
+		runners: runners,
+		factory: dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 10*time.Minute, namespace, nil),
+		queue:   workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[key]()),
+	}
+
+	for resource := range runners {
+		resource := resource
+		enqueue := func(obj interface{}) {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				c.queue.Add(key{resource: resource, namespace: u.GetNamespace(), name: u.GetName()})
+			}
+		}
+		c.factory.ForResource(resource).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    enqueue,
+			UpdateFunc: func(_, obj interface{}) { enqueue(obj) },
+		})
+	}
+	return c
+}
+
+// Run starts the informers and the workers, and blocks until ctx is canceled.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	defer c.queue.ShutDown()
+
+	c.factory.Start(ctx.Done())
+	for resource, synced := range c.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync the informer of %s (is the CRD installed?)", resource.Resource)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	k, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(k)
+
+	if err := c.reconcile(ctx, k); err != nil {
+		c.queue.AddRateLimited(k)
+		return true
+	}
+	c.queue.Forget(k)
+	return true
+}
+
+// reconcile runs the workflow of the resource unless its current generation
+// has already completed. Workflow failures are recorded in the status and not
+// retried; only API errors are.
+func (c *Controller) reconcile(ctx context.Context, k key) error {
+	client := c.client.Resource(k.resource).Namespace(k.namespace)
+	obj, err := client.Get(ctx, k.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observed == obj.GetGeneration() && (phase == PhaseCompleted || phase == PhaseFailed) {
+		return nil
+	}
+
+	// A Running phase of the current generation means the operator restarted during the run.
+	status := map[string]interface{}{
+		"phase":              PhaseRunning,
+		"observedGeneration": obj.GetGeneration(),
+		"startTime":          time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := unstructured.SetNestedMap(obj.Object, status, "status"); err != nil {
+		return err
+	}
+	if obj, err = client.UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	report, runErr := c.runners[k.resource](ctx, obj)
+	status["phase"] = PhaseCompleted
+	status["completionTime"] = time.Now().UTC().Format(time.RFC3339)
+	if runErr != nil {
+		status["phase"] = PhaseFailed
+		status["error"] = runErr.Error()
+	} else {
+		status["report"] = report
+		if findings := findingsToStatus(workflows.ParseFindings(report)); len(findings) > 0 {
+			status["findings"] = findings
+		}
+	}
+	if err := unstructured.SetNestedMap(obj.Object, status, "status"); err != nil {
+		return err
+	}
+	_, err = client.UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
+
+// findingsToStatus converts the findings to unstructured values.
+func findingsToStatus(findings []workflows.Finding) []interface{} {
+	result := make([]interface{}, 0, len(findings))
+	for _, f := range findings {
+		item := map[string]interface{}{"title": f.Title}
+		if f.Severity != "" {
+			item["severity"] = f.Severity
+		}
+		if f.Description != "" {
+			item["description"] = f.Description
+		}
+		if f.Remediation != "" {
+			item["remediation"] = f.Remediation
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newDiagnosis(name string, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Group + "/" + Version,
+		"kind":       "Diagnosis",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default", "generation": int64(1)},
+		"spec":       map[string]interface{}{"pod": "nginx"},
+	}}
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
+
+func TestReconcile(t *testing.T) {
+	report := "## 1. Image pull error\n\n- **Severity**: HIGH\n- **Findings**: The image nginx:latestt doesn't exist.\n- **How to resolve**: Fix the image tag."
+	tests := []struct {
+		name         string
+		obj          *unstructured.Unstructured
+		runErr       error
+		wantRuns     int
+		wantPhase    string
+		wantFindings int
+	}{
+		{
+			name:         "new diagnosis",
+			obj:          newDiagnosis("new", nil),
+			wantRuns:     1,
+			wantPhase:    PhaseCompleted,
+			wantFindings: 1,
+		},
+		{
+			name:      "failed workflow",
+			obj:       newDiagnosis("failed", nil),
+			runErr:    fmt.Errorf("OPENAI_API_KEY is not set"),
+			wantRuns:  1,
+			wantPhase: PhaseFailed,
+		},
+		{
+			name:      "completed generation",
+			obj:       newDiagnosis("completed", map[string]interface{}{"phase": PhaseCompleted, "observedGeneration": int64(1)}),
+			wantRuns:  0,
+			wantPhase: PhaseCompleted,
+		},
+		{
+			name:         "interrupted run",
+			obj:          newDiagnosis("interrupted", map[string]interface{}{"phase": PhaseRunning, "observedGeneration": int64(1)}),
+			wantRuns:     1,
+			wantPhase:    PhaseCompleted,
+			wantFindings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{DiagnosisResource: "DiagnosisList"})
+			// The resource is created explicitly as the fake tracker can't guess the plural of Diagnosis.
+			if _, err := client.Resource(DiagnosisResource).Namespace("default").Create(context.Background(), tt.obj, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			runs := 0
+			c := NewController(client, "", map[schema.GroupVersionResource]Runner{
+				DiagnosisResource: func(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+					runs++
+					if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != PhaseRunning {
+						t.Errorf("phase during the run = %q, want %q", phase, PhaseRunning)
+					}
+					return report, tt.runErr
+				},
+			})
+
+			k := key{resource: DiagnosisResource, namespace: "default", name: tt.obj.GetName()}
+			if err := c.reconcile(context.Background(), k); err != nil {
+				t.Fatalf("reconcile() error = %v", err)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("runs = %d, want %d", runs, tt.wantRuns)
+			}
+
+			obj, err := client.Resource(DiagnosisResource).Namespace("default").Get(context.Background(), tt.obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != tt.wantPhase {
+				t.Errorf("status.phase = %q, want %q", phase, tt.wantPhase)
+			}
+			findings, _, _ := unstructured.NestedSlice(obj.Object, "status", "findings")
+			if len(findings) != tt.wantFindings {
+				t.Errorf("status.findings = %v, want %d findings", findings, tt.wantFindings)
+			}
+		})
+	}
+
+	t.Run("deleted resource", func(t *testing.T) {
+		client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{DiagnosisResource: "DiagnosisList"})
+		c := NewController(client, "", map[schema.GroupVersionResource]Runner{DiagnosisResource: nil})
+		if err := c.reconcile(context.Background(), key{resource: DiagnosisResource, namespace: "default", name: "gone"}); err != nil {
+			t.Errorf("reconcile() error = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,540 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package pathtrace traces an HTTP request through the Ingress or Gateway
+// API route, the Service, its endpoints and the Pods serving it.
+package pathtrace
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Status of a hop.
+const (
+	StatusOK      = "OK"
+	StatusWarning = "WARN"
+	StatusBroken  = "BROKEN"
+	StatusSkipped = "SKIPPED"
+)
+
+// Hop is a step on the path of the requests.
+type Hop struct {
+	Name     string
+	Resource string
+	Status   string
+	Detail   string
+}
+
+// Trace is the hop-by-hop path of the requests to a URL.
+type Trace struct {
+	URL  string
+	Hops []Hop
+}
+
+// backend is the Service port receiving the requests of a route.
+type backend struct {
+	service string
+	port    intstr.IntOrString
+}
+
+// route is an Ingress or HTTPRoute rule matching the requests.
+type route struct {
+	resource string
+	match    string
+	backend  backend
+	// score ranks the routes: the most specific host, then path, wins.
+	score     int
+	ingress   *networkingv1.Ingress
+	httpRoute *kubernetes.HTTPRoute
+}
+
+// Run traces the requests to rawURL through the routing resources of a namespace.
+func Run(routing *kubernetes.Routing, rawURL string) (*Trace, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %v", rawURL, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", rawURL)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	t := &Trace{URL: u.String()}
+	r := findRoute(routing, strings.ToLower(u.Hostname()), path)
+	if r == nil {
+		t.add(Hop{Name: "Route", Status: StatusBroken, Detail: fmt.Sprintf("no Ingress or HTTPRoute in namespace %s matches host %s and path %s", routing.Namespace, u.Hostname(), path)})
+		t.skip("Service", "Endpoints", "Pods", "Container port")
+		return t, nil
+	}
+
+	t.add(routeHop(r))
+	if r.httpRoute != nil {
+		t.add(gatewayHop(routing, r.httpRoute))
+	}
+
+	service, servicePort, hop := serviceHop(routing, r.backend)
+	t.add(hop)
+	if servicePort == nil || service.Spec.Type == corev1.ServiceTypeExternalName {
+		t.skip("Endpoints", "Pods", "Container port")
+		return t, nil
+	}
+
+	t.add(endpointsHop(routing, service, servicePort))
+	pods, hop := podsHop(routing, service)
+	t.add(hop)
+	if len(pods) == 0 {
+		t.skip("Container port")
+		return t, nil
+	}
+	t.add(containerPortHop(pods, servicePort))
+	return t, nil
+}
+
+// FirstBroken returns the first broken hop, or nil if there is none.
+func (t *Trace) FirstBroken() *Hop {
+	for i := range t.Hops {
+		if t.Hops[i].Status == StatusBroken {
+			return &t.Hops[i]
+		}
+	}
+	return nil
+}
+
+// Format formats the trace as a markdown table, followed by the first broken hop.
+func (t *Trace) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Trace of %s:\n\n", t.URL)
+	b.WriteString("| # | Hop | Resource | Status | Details |\n")
+	b.WriteString("|---|-----|----------|--------|---------|\n")
+	for i, hop := range t.Hops {
+		resource := hop.Resource
+		if resource == "" {
+			resource = "-"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s |\n", i+1, hop.Name, resource, hop.Status, strings.ReplaceAll(hop.Detail, "|", "\\|"))
+	}
+
+	if hop := t.FirstBroken(); hop != nil {
+		fmt.Fprintf(&b, "\nFirst broken hop: %s (%s)\n", hop.Name, hop.Detail)
+	} else {
+		b.WriteString("\nNo broken hop found.\n")
+	}
+	return b.String()
+}
+
+func (t *Trace) add(hop Hop) {
+	t.Hops = append(t.Hops, hop)
+}
+
+// skip adds the hops which can't be traced because of the previous hops.
+func (t *Trace) skip(names ...string) {
+	for _, name := range names {
+		t.add(Hop{Name: name, Status: StatusSkipped, Detail: "not traced because of the previous hops"})
+	}
+}
+
+// findRoute returns the most specific Ingress or HTTPRoute rule matching the host and path.
+func findRoute(routing *kubernetes.Routing, host string, path string) *route {
+	var best *route
+	consider := func(r route) {
+		if best == nil || r.score > best.score {
+			best = &r
+		}
+	}
+
+	for i := range routing.Ingresses {
+		ing := &routing.Ingresses[i]
+		resource := "ingress/" + ing.Name
+		for _, rule := range ing.Spec.Rules {
+			hostScore := matchHost(rule.Host, host)
+			if hostScore < 0 || rule.HTTP == nil {
+				continue
+			}
+			for _, p := range rule.HTTP.Paths {
+				pathType := string(networkingv1.PathTypeImplementationSpecific)
+				if p.PathType != nil {
+					pathType = string(*p.PathType)
+				}
+				pathScore := matchPath(pathType, p.Path, path)
+				if pathScore < 0 || p.Backend.Service == nil {
+					continue
+				}
+				consider(route{
+					resource: resource,
+					match:    fmt.Sprintf("host %s, path %s %s", displayHost(rule.Host), pathType, p.Path),
+					backend:  ingressBackend(p.Backend.Service),
+					score:    hostScore*100000 + pathScore,
+					ingress:  ing,
+				})
+			}
+		}
+		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+			consider(route{
+				resource: resource,
+				match:    "the default backend",
+				backend:  ingressBackend(ing.Spec.DefaultBackend.Service),
+				ingress:  ing,
+			})
+		}
+	}
+
+	for i := range routing.HTTPRoutes {
+		hr := &routing.HTTPRoutes[i]
+		hostScore, hostname := 0, "*"
+		if len(hr.Spec.Hostnames) > 0 {
+			hostScore = -1
+			for _, h := range hr.Spec.Hostnames {
+				if s := matchHost(h, host); s > hostScore {
+					hostScore, hostname = s, h
+				}
+			}
+			if hostScore < 0 {
+				continue
+			}
+		}
+
+		for _, rule := range hr.Spec.Rules {
+			backends := serviceBackends(hr, rule.BackendRefs)
+			if len(backends) == 0 {
+				continue
+			}
+			matches := rule.Matches
+			if len(matches) == 0 {
+				matches = []kubernetes.HTTPRouteMatch{{}}
+			}
+			for _, m := range matches {
+				pathType, value := "PathPrefix", "/"
+				if m.Path != nil {
+					if m.Path.Type != "" {
+						pathType = m.Path.Type
+					}
+					if m.Path.Value != "" {
+						value = m.Path.Value
+					}
+				}
+				pathScore := matchPath(pathType, value, path)
+				if pathScore < 0 {
+					continue
+				}
+				consider(route{
+					resource:  "httproute/" + hr.Name,
+					match:     fmt.Sprintf("host %s, path %s %s", hostname, pathType, value),
+					backend:   backends[0],
+					score:     hostScore*100000 + pathScore,
+					httpRoute: hr,
+				})
+			}
+		}
+	}
+	return best
+}
+
+// matchHost returns -1 if pattern doesn't match host, 0 for any host,
+// 1 for a wildcard and 2 for an exact match.
+func matchHost(pattern string, host string) int {
+	pattern = strings.ToLower(pattern)
+	switch {
+	case pattern == "" || pattern == "*":
+		return 0
+	case pattern == host:
+		return 2
+	case strings.HasPrefix(pattern, "*."):
+		// A wildcard matches a single DNS label.
+		suffix := pattern[1:]
+		if strings.HasSuffix(host, suffix) && !strings.Contains(strings.TrimSuffix(host, suffix), ".") {
+			return 1
+		}
+	}
+	return -1
+}
+
+// matchPath returns -1 if the path doesn't match, otherwise a score
+// growing with the specificity of the match.
+func matchPath(pathType string, value string, path string) int {
+	switch pathType {
+	case "Exact":
+		if path == value {
+			return 50000
+		}
+	case "Prefix", "PathPrefix":
+		// Prefixes match whole path elements: /foo matches /foo/bar but not /foobar.
+		prefix := strings.TrimSuffix(value, "/")
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return len(prefix) + 1
+		}
+	case "RegularExpression":
+		if re, err := regexp.Compile("^(?:" + value + ")$"); err == nil && re.MatchString(path) {
+			return len(value)
+		}
+	default:
+		// ImplementationSpecific paths are matched as plain prefixes.
+		if strings.HasPrefix(path, value) {
+			return len(value)
+		}
+	}
+	return -1
+}
+
+func displayHost(host string) string {
+	if host == "" {
+		return "*"
+	}
+	return host
+}
+
+func ingressBackend(service *networkingv1.IngressServiceBackend) backend {
+	if service.Port.Name != "" {
+		return backend{service: service.Name, port: intstr.FromString(service.Port.Name)}
+	}
+	return backend{service: service.Name, port: intstr.FromInt32(service.Port.Number)}
+}
+
+// serviceBackends returns the Service backends of an HTTPRoute rule in the namespace of the route.
+func serviceBackends(hr *kubernetes.HTTPRoute, refs []kubernetes.HTTPBackendRef) []backend {
+	var backends []backend
+	for _, ref := range refs {
+		if (ref.Kind != "" && ref.Kind != "Service") || (ref.Namespace != "" && ref.Namespace != hr.Namespace) {
+			continue
+		}
+		backends = append(backends, backend{service: ref.Name, port: intstr.FromInt32(ref.Port)})
+	}
+	return backends
+}
+
+func routeHop(r *route) Hop {
+	hop := Hop{
+		Name:     "Route",
+		Resource: r.resource,
+		Status:   StatusOK,
+		Detail:   fmt.Sprintf("matches %s, forwards to service %s port %s", r.match, r.backend.service, r.backend.port.String()),
+	}
+	if r.ingress != nil && len(r.ingress.Status.LoadBalancer.Ingress) == 0 {
+		hop.Status = StatusWarning
+		hop.Detail += "; the Ingress has no address, check its ingressClassName and the ingress controller"
+	}
+	if r.httpRoute != nil {
+		for _, parent := range r.httpRoute.Status.Parents {
+			for _, conditionType := range []string{"Accepted", "ResolvedRefs"} {
+				if c := meta.FindStatusCondition(parent.Conditions, conditionType); c != nil && c.Status == metav1.ConditionFalse {
+					hop.Status = StatusBroken
+					hop.Detail = fmt.Sprintf("the HTTPRoute is not %s by gateway %s: %s (%s)", conditionType, parent.ParentRef.Name, c.Reason, c.Message)
+					return hop
+				}
+			}
+		}
+	}
+	return hop
+}
+
+func gatewayHop(routing *kubernetes.Routing, hr *kubernetes.HTTPRoute) Hop {
+	for _, ref := range hr.Spec.ParentRefs {
+		if ref.Kind != "" && ref.Kind != "Gateway" {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = hr.Namespace
+		}
+		resource := fmt.Sprintf("gateway/%s/%s", namespace, ref.Name)
+		for _, gw := range routing.Gateways {
+			if gw.Namespace != namespace || gw.Name != ref.Name {
+				continue
+			}
+			for _, conditionType := range []string{"Accepted", "Programmed"} {
+				if c := meta.FindStatusCondition(gw.Status.Conditions, conditionType); c != nil && c.Status == metav1.ConditionFalse {
+					return Hop{Name: "Gateway", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("the Gateway is not %s: %s (%s)", conditionType, c.Reason, c.Message)}
+				}
+			}
+			return Hop{Name: "Gateway", Resource: resource, Status: StatusOK, Detail: "the Gateway is accepted and programmed"}
+		}
+		return Hop{Name: "Gateway", Resource: resource, Status: StatusWarning, Detail: "the Gateway is not found or not accessible"}
+	}
+	return Hop{Name: "Gateway", Resource: "httproute/" + hr.Name, Status: StatusBroken, Detail: "the HTTPRoute has no Gateway parentRef"}
+}
+
+func serviceHop(routing *kubernetes.Routing, b backend) (*corev1.Service, *corev1.ServicePort, Hop) {
+	resource := "service/" + b.service
+	var service *corev1.Service
+	for i := range routing.Services {
+		if routing.Services[i].Name == b.service {
+			service = &routing.Services[i]
+		}
+	}
+	if service == nil {
+		return nil, nil, Hop{Name: "Service", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("service %s is not found in namespace %s", b.service, routing.Namespace)}
+	}
+
+	var ports []string
+	for i, p := range service.Spec.Ports {
+		ports = append(ports, fmt.Sprintf("%s/%d", p.Name, p.Port))
+		if (b.port.Type == intstr.String && p.Name == b.port.StrVal) || (b.port.Type == intstr.Int && p.Port == b.port.IntVal) {
+			port := &service.Spec.Ports[i]
+			target := targetPort(port)
+			hop := Hop{Name: "Service", Resource: resource, Status: StatusOK, Detail: fmt.Sprintf("%s service, port %d targets %s", service.Spec.Type, port.Port, target.String())}
+			switch {
+			case service.Spec.Type == corev1.ServiceTypeExternalName:
+				hop.Detail = fmt.Sprintf("ExternalName service to %s, not traced further", service.Spec.ExternalName)
+			case len(service.Spec.Selector) == 0:
+				hop.Status = StatusWarning
+				hop.Detail += "; the Service has no selector, its endpoints are managed manually"
+			}
+			return service, port, hop
+		}
+	}
+	return service, nil, Hop{Name: "Service", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("service %s has no port %s (ports: %s)", b.service, b.port.String(), strings.Join(ports, ", "))}
+}
+
+// targetPort returns the target port of a Service port, which defaults to the port itself.
+func targetPort(port *corev1.ServicePort) intstr.IntOrString {
+	if (port.TargetPort.Type == intstr.String && port.TargetPort.StrVal != "") || (port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal != 0) {
+		return port.TargetPort
+	}
+	return intstr.FromInt32(port.Port)
+}
+
+func endpointsHop(routing *kubernetes.Routing, service *corev1.Service, port *corev1.ServicePort) Hop {
+	resource := "endpointslices/" + service.Name
+	ready, notReady := 0, 0
+	for _, slice := range routing.EndpointSlices {
+		if slice.Labels[discoveryv1.LabelServiceName] != service.Name || !slicePort(slice, port.Name) {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+				ready++
+			} else {
+				notReady++
+			}
+		}
+	}
+
+	switch {
+	case ready == 0 && notReady == 0:
+		return Hop{Name: "Endpoints", Resource: resource, Status: StatusBroken, Detail: "the Service has no endpoints"}
+	case ready == 0:
+		return Hop{Name: "Endpoints", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("the Service has no ready endpoints (%d not ready)", notReady)}
+	case notReady > 0:
+		return Hop{Name: "Endpoints", Resource: resource, Status: StatusWarning, Detail: fmt.Sprintf("%d ready endpoints, %d not ready", ready, notReady)}
+	default:
+		return Hop{Name: "Endpoints", Resource: resource, Status: StatusOK, Detail: fmt.Sprintf("%d ready endpoints", ready)}
+	}
+}
+
+// slicePort returns true if the EndpointSlice exposes the Service port with the given name.
+func slicePort(slice discoveryv1.EndpointSlice, name string) bool {
+	for _, p := range slice.Ports {
+		if (p.Name == nil && name == "") || (p.Name != nil && *p.Name == name) {
+			return true
+		}
+	}
+	return false
+}
+
+func podsHop(routing *kubernetes.Routing, service *corev1.Service) ([]corev1.Pod, Hop) {
+	if len(service.Spec.Selector) == 0 {
+		return nil, Hop{Name: "Pods", Status: StatusSkipped, Detail: "the Service has no selector"}
+	}
+
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	var pods []corev1.Pod
+	var notReady []string
+	for _, pod := range routing.Pods {
+		if pod.DeletionTimestamp != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		pods = append(pods, pod)
+		if !podReady(pod) {
+			notReady = append(notReady, fmt.Sprintf("%s (%s)", pod.Name, podProblem(pod)))
+		}
+	}
+	sort.Strings(notReady)
+
+	resource := "pods/" + selector.String()
+	switch {
+	case len(pods) == 0:
+		return nil, Hop{Name: "Pods", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("no Pod matches the selector %s", selector.String())}
+	case len(notReady) == len(pods):
+		return pods, Hop{Name: "Pods", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("0/%d Pods ready: %s", len(pods), strings.Join(notReady, ", "))}
+	case len(notReady) > 0:
+		return pods, Hop{Name: "Pods", Resource: resource, Status: StatusWarning, Detail: fmt.Sprintf("%d/%d Pods ready, not ready: %s", len(pods)-len(notReady), len(pods), strings.Join(notReady, ", "))}
+	default:
+		return pods, Hop{Name: "Pods", Resource: resource, Status: StatusOK, Detail: fmt.Sprintf("%d/%d Pods ready", len(pods), len(pods))}
+	}
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podProblem returns the most relevant reason of a Pod not being ready.
+func podProblem(pod corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		switch {
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+			return cs.State.Waiting.Reason
+		case cs.State.Terminated != nil && cs.State.Terminated.Reason != "":
+			return cs.State.Terminated.Reason
+		case !cs.Ready && cs.State.Running != nil:
+			return "readiness probe failing"
+		}
+	}
+	if pod.Status.Reason != "" {
+		return pod.Status.Reason
+	}
+	return string(pod.Status.Phase)
+}
+
+func containerPortHop(pods []corev1.Pod, port *corev1.ServicePort) Hop {
+	target := targetPort(port)
+	resource := "port/" + target.String()
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			for _, p := range c.Ports {
+				if (target.Type == intstr.String && p.Name == target.StrVal) || (target.Type == intstr.Int && p.ContainerPort == target.IntVal) {
+					return Hop{Name: "Container port", Resource: resource, Status: StatusOK, Detail: fmt.Sprintf("container %s declares port %d", c.Name, p.ContainerPort)}
+				}
+			}
+		}
+	}
+
+	if target.Type == intstr.String {
+		return Hop{Name: "Container port", Resource: resource, Status: StatusBroken, Detail: fmt.Sprintf("no container declares a port named %s, so the Service has no endpoints for it", target.StrVal)}
+	}
+	return Hop{Name: "Container port", Resource: resource, Status: StatusWarning, Detail: fmt.Sprintf("no container declares port %d, check that the application listens on it", target.IntVal)}
+}
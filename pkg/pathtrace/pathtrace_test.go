@@ -0,0 +1,250 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package pathtrace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func ingressPath(path string, pathType networkingv1.PathType, service string, port networkingv1.ServiceBackendPort) networkingv1.HTTPIngressPath {
+	return networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: &pathType,
+		Backend:  networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: service, Port: port}},
+	}
+}
+
+func service(name string, port corev1.ServicePort) corev1.Service {
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{"app": name},
+			Ports:    []corev1.ServicePort{port},
+		},
+	}
+}
+
+func endpointSlice(service string, port string, ready ...bool) discoveryv1.EndpointSlice {
+	slice := discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: service + "-abcde", Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+		Ports:      []discoveryv1.EndpointPort{{Name: &port}},
+	}
+	for i := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Conditions: discoveryv1.EndpointConditions{Ready: &ready[i]}})
+	}
+	return slice
+}
+
+func pod(name string, app string, ready bool, port corev1.ContainerPort) corev1.Pod {
+	p := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": app}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: app, Ports: []corev1.ContainerPort{port}}}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if !ready {
+		p.Status.Conditions[0].Status = corev1.ConditionFalse
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: app, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}}
+	}
+	return p
+}
+
+func testRouting() *kubernetes.Routing {
+	return &kubernetes.Routing{
+		Namespace: "shop",
+		Ingresses: []networkingv1.Ingress{{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop"},
+			Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{
+				Host: "shop.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{
+					ingressPath("/", networkingv1.PathTypePrefix, "web", networkingv1.ServiceBackendPort{Number: 80}),
+					ingressPath("/api", networkingv1.PathTypePrefix, "api", networkingv1.ServiceBackendPort{Name: "http"}),
+					ingressPath("/admin", networkingv1.PathTypePrefix, "admin", networkingv1.ServiceBackendPort{Number: 80}),
+				}}},
+			}}},
+			Status: networkingv1.IngressStatus{LoadBalancer: networkingv1.IngressLoadBalancerStatus{Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}}},
+		}},
+		HTTPRoutes: []kubernetes.HTTPRoute{{
+			ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "shop"},
+			Spec: kubernetes.HTTPRouteSpec{
+				ParentRefs: []kubernetes.ParentReference{{Name: "public", Namespace: "infra"}},
+				Hostnames:  []string{"*.example.com"},
+				Rules: []kubernetes.HTTPRouteRule{{
+					Matches:     []kubernetes.HTTPRouteMatch{{Path: &kubernetes.HTTPPathMatch{Type: "PathPrefix", Value: "/checkout"}}},
+					BackendRefs: []kubernetes.HTTPBackendRef{{Name: "api", Port: 80}},
+				}},
+			},
+		}},
+		Gateways: []kubernetes.Gateway{{
+			ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "infra"},
+			Status: kubernetes.GatewayStatus{Conditions: []metav1.Condition{
+				{Type: "Programmed", Status: metav1.ConditionFalse, Reason: "AddressNotAssigned", Message: "no address"},
+			}},
+		}},
+		Services: []corev1.Service{
+			service("api", corev1.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromString("http")}),
+			service("web", corev1.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromInt32(8080)}),
+		},
+		EndpointSlices: []discoveryv1.EndpointSlice{
+			endpointSlice("api", "http", true, true),
+			endpointSlice("web", "http", false),
+		},
+		Pods: []corev1.Pod{
+			pod("api-1", "api", true, corev1.ContainerPort{Name: "http", ContainerPort: 8080}),
+			pod("api-2", "api", true, corev1.ContainerPort{Name: "http", ContainerPort: 8080}),
+			pod("web-1", "web", false, corev1.ContainerPort{ContainerPort: 8080}),
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantRoute  string
+		wantStatus []string
+		wantBroken string
+	}{
+		{
+			url:        "https://shop.example.com/api/orders",
+			wantRoute:  "ingress/shop",
+			wantStatus: []string{StatusOK, StatusOK, StatusOK, StatusOK, StatusOK},
+		},
+		{
+			url:        "shop.example.com/apis",
+			wantRoute:  "ingress/shop",
+			wantStatus: []string{StatusOK, StatusOK, StatusBroken, StatusBroken, StatusOK},
+			wantBroken: "Endpoints",
+		},
+		{
+			url:        "shop.example.com/admin",
+			wantRoute:  "ingress/shop",
+			wantStatus: []string{StatusOK, StatusBroken, StatusSkipped, StatusSkipped, StatusSkipped},
+			wantBroken: "Service",
+		},
+		{
+			url:        "http://pay.example.com/checkout/cart",
+			wantRoute:  "httproute/checkout",
+			wantStatus: []string{StatusOK, StatusBroken, StatusOK, StatusOK, StatusOK, StatusOK},
+			wantBroken: "Gateway",
+		},
+		{
+			url:        "http://shop.example.org/",
+			wantStatus: []string{StatusBroken, StatusSkipped, StatusSkipped, StatusSkipped, StatusSkipped},
+			wantBroken: "Route",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			trace, err := Run(testRouting(), tt.url)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var statuses []string
+			for _, hop := range trace.Hops {
+				statuses = append(statuses, hop.Status)
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.wantStatus, ",") {
+				t.Errorf("Run() hops = %+v, want statuses %v", trace.Hops, tt.wantStatus)
+			}
+			if trace.Hops[0].Resource != tt.wantRoute {
+				t.Errorf("Run() route = %q, want %q", trace.Hops[0].Resource, tt.wantRoute)
+			}
+			broken := trace.FirstBroken()
+			if (broken == nil && tt.wantBroken != "") || (broken != nil && broken.Name != tt.wantBroken) {
+				t.Errorf("FirstBroken() = %+v, want %q", broken, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestRunInvalidURL(t *testing.T) {
+	if _, err := Run(testRouting(), "http:///path"); err == nil {
+		t.Error("Run() = nil error, want a missing host error")
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    int
+	}{
+		{pattern: "", host: "a.example.com", want: 0},
+		{pattern: "a.example.com", host: "a.example.com", want: 2},
+		{pattern: "*.example.com", host: "a.example.com", want: 1},
+		{pattern: "*.example.com", host: "a.b.example.com", want: -1},
+		{pattern: "*.example.com", host: "example.com", want: -1},
+		{pattern: "b.example.com", host: "a.example.com", want: -1},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHost(%q, %q) = %d, want %d", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pathType string
+		value    string
+		path     string
+		match    bool
+	}{
+		{pathType: "Prefix", value: "/", path: "/anything", match: true},
+		{pathType: "Prefix", value: "/foo/", path: "/foo", match: true},
+		{pathType: "Prefix", value: "/foo", path: "/foo/bar", match: true},
+		{pathType: "Prefix", value: "/foo", path: "/foobar", match: false},
+		{pathType: "Exact", value: "/foo", path: "/foo/", match: false},
+		{pathType: "ImplementationSpecific", value: "/foo", path: "/foobar", match: true},
+		{pathType: "RegularExpression", value: "/v[0-9]+/.*", path: "/v2/users", match: true},
+		{pathType: "RegularExpression", value: "/v[0-9]+", path: "/v2/users", match: false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pathType, tt.value, tt.path) >= 0; got != tt.match {
+			t.Errorf("matchPath(%s, %q, %q) = %v, want %v", tt.pathType, tt.value, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	trace, err := Run(testRouting(), "shop.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted := trace.Format()
+	for _, want := range []string{
+		"Trace of http://shop.example.com/:",
+		"| 1 | Route | ingress/shop | OK | matches host shop.example.com, path Prefix /, forwards to service web port 80 |",
+		"| 4 | Pods | pods/app=web | BROKEN | 0/1 Pods ready: web-1 (CrashLoopBackOff) |",
+		"| 5 | Container port | port/8080 | OK | container web declares port 8080 |",
+		"First broken hop: Endpoints (the Service has no ready endpoints (1 not ready))",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Format() = %s\nwant it to contain %q", formatted, want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+)
+
+// Query is the Rego query evaluated for every tool execution: the deny
+// messages of the kubecopilot package. The execution is allowed when it is
+// empty or undefined.
+const Query = "data.kubecopilot.deny"
+
+// evalTimeout is the timeout of a policy evaluation.
+const evalTimeout = 10 * time.Second
+
+// Input is the input document of the policies, describing a proposed tool execution.
+type Input struct {
+	// Tool is the name of the tool (e.g. kubectl, python or a plugin).
+	Tool string `json:"tool"`
+	// Input is the raw input of the tool (e.g. the kubectl command without the kubectl prefix).
+	Input string `json:"input"`
+	// Verb is the kubectl subcommand (e.g. get or delete).
+	Verb string `json:"verb,omitempty"`
+	// Resource is the first argument after the kubectl verb (e.g. pods or deployment/nginx).
+	Resource string `json:"resource,omitempty"`
+	// Namespace is the kubectl namespace: the namespace of the context if not
+	// set in the command, empty for all namespaces.
+	Namespace string `json:"namespace,omitempty"`
+	// AllNamespaces is true for kubectl commands across all namespaces.
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+	// Cluster is the kubeconfig context the tool runs against.
+	Cluster string `json:"cluster,omitempty"`
+	// User is the user running kube-copilot.
+	User string `json:"user,omitempty"`
+	// Command is the kube-copilot command (e.g. execute).
+	Command string `json:"command,omitempty"`
+	// Time is the time of the execution in RFC 3339, and Weekday and Hour its local weekday and hour.
+	Time    string `json:"time"`
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+}
+
+// Decision is the outcome of the policies for a tool execution.
+type Decision struct {
+	Allowed bool     `json:"allowed"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Evaluator evaluates Query on the policies at paths and returns the deny messages.
+type Evaluator func(ctx context.Context, paths []string, input []byte) ([]string, error)
+
+// Engine evaluates the Rego policies for every tool execution.
+type Engine struct {
+	// Paths are the Rego files or directories of the policies.
+	Paths []string
+	// Evaluate evaluates the policies. Defaults to the opa command line.
+	Evaluate Evaluator
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// New creates an engine for the policies at paths, evaluated with the opa
+// command line, which must be installed.
+func New(paths []string) (*Engine, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no policy provided")
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %v", path, err)
+		}
+	}
+	if _, err := exec.LookPath("opa"); err != nil {
+		return nil, fmt.Errorf("opa is required to evaluate the policies: %v", err)
+	}
+	return &Engine{Paths: paths, Evaluate: evalOPA, Now: time.Now}, nil
+}
+
+// Authorize evaluates the policies for the tool execution and returns an
+// error if it is denied. Evaluation failures, and kubectl commands of which
+// the verb can't be parsed, deny the execution. The decision is written to
+// the audit log.
+func (e *Engine) Authorize(tool, input string) error {
+	in := NewInput(tool, input, e.now())
+	decision := Decision{Reasons: []string{fmt.Sprintf("ambiguous kubectl command %q", input)}}
+	if in.Tool != "kubectl" || in.Verb != "" {
+		decision = e.Decide(context.Background(), in)
+	}
+
+	event := auditlog.Event{Type: auditlog.EventPolicyDecision, Tool: tool, Input: input, Decision: auditlog.DecisionAllowed}
+	if !decision.Allowed {
+		event.Decision = auditlog.DecisionDenied
+		event.Error = strings.Join(decision.Reasons, "; ")
+	}
+	auditlog.Record(event)
+
+	if !decision.Allowed {
+		return fmt.Errorf("denied by policy: %s", strings.Join(decision.Reasons, "; "))
+	}
+	return nil
+}
+
+// Decide evaluates the policies for the input.
+func (e *Engine) Decide(ctx context.Context, input Input) Decision {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return Decision{Reasons: []string{fmt.Sprintf("invalid policy input: %v", err)}}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, evalTimeout)
+	defer cancel()
+	evaluate := e.Evaluate
+	if evaluate == nil {
+		evaluate = evalOPA
+	}
+	reasons, err := evaluate(ctx, e.Paths, data)
+	if err != nil {
+		return Decision{Reasons: []string{fmt.Sprintf("policy evaluation failed: %v", err)}}
+	}
+	return Decision{Allowed: len(reasons) == 0, Reasons: reasons}
+}
+
+func (e *Engine) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+	return e.Now()
+}
+
+// NewInput describes the tool execution for the policies.
+func NewInput(tool, input string, now time.Time) Input {
+	in := Input{
+		Tool:    tool,
+		Input:   input,
+		Cluster: kubernetes.CurrentContext(),
+		User:    currentUser(),
+		Command: auditlog.Command,
+		Time:    now.Format(time.RFC3339),
+		Weekday: now.Weekday().String(),
+		Hour:    now.Hour(),
+	}
+	if tool == "kubectl" {
+		parseKubectl(&in, input)
+	}
+	return in
+}
+
+// parseKubectl sets the verb, resource, namespace and cluster of a kubectl
+// command. The verb and resource are left empty when they are ambiguous.
+func parseKubectl(in *Input, command string) {
+	cmd, err := kubernetes.ParseKubectl(command)
+	if err != nil {
+		return
+	}
+	in.Namespace = cmd.Namespace()
+	in.AllNamespaces = cmd.AllNamespaces()
+	if cluster := cmd.Flags["--context"]; cluster != "" {
+		in.Cluster = cluster
+	}
+	if in.Namespace == "" && !in.AllNamespaces {
+		// kubectl runs in the namespace of the context, which the policies must see.
+		in.Namespace = kubernetes.ContextNamespace(cmd.Flags["--context"])
+	}
+	if args, ok := cmd.Command(1); ok {
+		in.Verb = args[0]
+	}
+	if args, ok := cmd.Command(2); ok {
+		in.Resource = args[1]
+	}
+}
+
+// opaResult is the JSON output of "opa eval".
+type opaResult struct {
+	Result []struct {
+		Expressions []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// evalOPA evaluates Query with "opa eval".
+func evalOPA(ctx context.Context, paths []string, input []byte) ([]string, error) {
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, path := range paths {
+		args = append(args, "--data", path)
+	}
+	args = append(args, Query)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "opa", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+	return parseOPAResult(stdout.Bytes())
+}
+
+// parseOPAResult returns the deny messages of the "opa eval" output.
+// An undefined result denies nothing.
+func parseOPAResult(data []byte) ([]string, error) {
+	var result opaResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid opa output: %v", err)
+	}
+
+	var reasons []string
+	for _, r := range result.Result {
+		for _, expr := range r.Expressions {
+			var values []interface{}
+			if err := json.Unmarshal(expr.Value, &values); err != nil {
+				return nil, fmt.Errorf("%s should be a set of messages: %v", Query, err)
+			}
+			for _, v := range values {
+				if s, ok := v.(string); ok {
+					reasons = append(reasons, s)
+				} else {
+					reasons = append(reasons, fmt.Sprint(v))
+				}
+			}
+		}
+	}
+	return reasons, nil
+}
+
+// currentUser returns the name of the user running the process.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
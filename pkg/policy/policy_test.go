@@ -0,0 +1,166 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+)
+
+func TestParseKubectl(t *testing.T) {
+	defer func(kubeconfig string) { kubernetes.Kubeconfig = kubeconfig }(kubernetes.Kubeconfig)
+	kubernetes.Kubeconfig = filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubernetes.Kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: admin
+clusters:
+- name: prod
+  cluster: {server: https://prod.example.com}
+contexts:
+- name: admin
+  context: {cluster: prod, namespace: kube-system}
+- name: prod-eu
+  context: {cluster: prod, namespace: web}
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		want    Input
+	}{
+		{command: "get pods -n kube-system", want: Input{Verb: "get", Resource: "pods", Namespace: "kube-system"}},
+		{command: "kubectl delete deployment/nginx --namespace=web", want: Input{Verb: "delete", Resource: "deployment/nginx", Namespace: "web"}},
+		{command: "--context prod-eu scale deploy web --replicas 3", want: Input{Verb: "scale", Resource: "deploy", Namespace: "web", Cluster: "prod-eu"}},
+		{command: "get pods -A -o wide", want: Input{Verb: "get", Resource: "pods", AllNamespaces: true}},
+		{command: "logs web -c app -n default --tail=10", want: Input{Verb: "logs", Resource: "web", Namespace: "default"}},
+		{command: "delete pod dns -nkube-system", want: Input{Verb: "delete", Resource: "pod", Namespace: "kube-system"}},
+		{command: "delete --namespace=kube-system pod dns", want: Input{Verb: "delete", Resource: "pod", Namespace: "kube-system"}},
+		{command: "--context=prod-eu -Adelete get pods", want: Input{}},
+		{command: "--cache-dir /tmp delete pod dns", want: Input{Verb: "delete", Resource: "pod", Namespace: "kube-system"}},
+		{command: "delete pod dns", want: Input{Verb: "delete", Resource: "pod", Namespace: "kube-system"}},
+		{command: "--unknown get delete pod dns", want: Input{Namespace: "kube-system"}},
+		{command: "get pods -n", want: Input{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var got Input
+			parseKubectl(&got, tt.command)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKubectl() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOPAResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "undefined", output: `{}`},
+		{name: "empty set", output: `{"result": [{"expressions": [{"value": [], "text": "data.kubecopilot.deny"}]}]}`},
+		{name: "denied", output: `{"result": [{"expressions": [{"value": ["not allowed in kube-system"]}]}]}`, want: []string{"not allowed in kube-system"}},
+		{name: "not a set", output: `{"result": [{"expressions": [{"value": true}]}]}`, wantErr: true},
+		{name: "invalid", output: `opa: error`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOPAResult([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOPAResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOPAResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	engine := &Engine{
+		Now: func() time.Time { return time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC) },
+		Evaluate: func(ctx context.Context, paths []string, data []byte) ([]string, error) {
+			var input Input
+			if err := json.Unmarshal(data, &input); err != nil {
+				return nil, err
+			}
+			if input.Weekday != "Saturday" || input.Hour != 10 {
+				t.Errorf("input time = %s %d, want Saturday 10", input.Weekday, input.Hour)
+			}
+			if input.Tool == "kubectl" && input.Verb == "delete" && input.Namespace == "kube-system" {
+				return []string{"deletes are not allowed in kube-system"}, nil
+			}
+			if input.Tool == "python" {
+				return nil, exec.ErrNotFound
+			}
+			return nil, nil
+		},
+	}
+
+	if err := engine.Authorize("kubectl", "get pods -n kube-system"); err != nil {
+		t.Errorf("Authorize(get) error = %v", err)
+	}
+	if err := engine.Authorize("kubectl", "delete pod dns -n kube-system"); err == nil || !strings.Contains(err.Error(), "deletes are not allowed in kube-system") {
+		t.Errorf("Authorize(delete) error = %v, want the deny message", err)
+	}
+	if err := engine.Authorize("kubectl", "delete pod dns -nkube-system"); err == nil || !strings.Contains(err.Error(), "deletes are not allowed in kube-system") {
+		t.Errorf("Authorize(delete -nkube-system) error = %v, want the deny message", err)
+	}
+	if err := engine.Authorize("kubectl", "--unknown get delete pod dns -n kube-system"); err == nil || !strings.Contains(err.Error(), "ambiguous kubectl command") {
+		t.Errorf("Authorize(ambiguous) error = %v, want an ambiguous command to deny", err)
+	}
+	if err := engine.Authorize("python", "print(1)"); err == nil || !strings.Contains(err.Error(), "policy evaluation failed") {
+		t.Errorf("Authorize(python) error = %v, want a failed evaluation to deny", err)
+	}
+}
+
+func TestExamplePolicy(t *testing.T) {
+	if _, err := exec.LookPath("opa"); err != nil {
+		t.Skip("opa is not installed")
+	}
+
+	engine, err := New([]string{"../../policies/kubecopilot.rego"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	saturday := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input       Input
+		wantAllowed bool
+	}{
+		{input: Input{Tool: "kubectl", Verb: "get", Namespace: "kube-system"}, wantAllowed: true},
+		{input: Input{Tool: "kubectl", Verb: "delete", Namespace: "kube-system"}},
+		{input: Input{Tool: "kubectl", Verb: "scale", Namespace: "web", Cluster: "prod-eu", Weekday: saturday.Weekday().String(), Hour: 10}},
+		{input: Input{Tool: "kubectl", Verb: "scale", Namespace: "web", Cluster: "prod-eu", Weekday: "Monday", Hour: 10}, wantAllowed: true},
+	}
+	for _, tt := range tests {
+		if got := engine.Decide(context.Background(), tt.input); got.Allowed != tt.wantAllowed {
+			t.Errorf("Decide(%+v) = %+v, want allowed %v", tt.input, got, tt.wantAllowed)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout is the timeout of the Prometheus API requests.
+const requestTimeout = 30 * time.Second
+
+// Point is a sample of a time series.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a time series of a range query.
+type Series struct {
+	Metric map[string]string
+	Points []Point
+}
+
+// Client queries the Prometheus HTTP API (or a compatible one, e.g. Thanos or Mimir).
+type Client struct {
+	// URL is the base URL of Prometheus, e.g. http://prometheus.monitoring:9090.
+	URL string
+	// Token is an optional bearer token of the requests.
+	Token string
+	// HTTPClient is the client of the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a Client after validating the base URL.
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus URL %q, should be http(s)://<host>[/<path>]", baseURL)
+	}
+	return &Client{URL: strings.TrimRight(baseURL, "/"), Token: token}, nil
+}
+
+// QueryRange evaluates the PromQL query from start to end with the given step
+// (see https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries).
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/api/v1/query_range", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	var result struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Values [][2]interface{}  `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response of Prometheus (%s): %s", resp.Status, truncate(strings.TrimSpace(string(data)), 200))
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("failed to query Prometheus: %s", result.Error)
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %q of the range query", result.Data.ResultType)
+	}
+
+	series := make([]Series, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		s := Series{Metric: r.Metric, Points: make([]Point, 0, len(r.Values))}
+		for _, v := range r.Values {
+			timestamp, ok := v[0].(float64)
+			value, _ := v[1].(string)
+			parsed, err := strconv.ParseFloat(value, 64)
+			if !ok || err != nil || math.IsNaN(parsed) {
+				continue
+			}
+			sec, frac := math.Modf(timestamp)
+			s.Points = append(s.Points, Point{Time: time.Unix(int64(sec), int64(frac*1e9)), Value: parsed})
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
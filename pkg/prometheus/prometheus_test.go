@@ -0,0 +1,75 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prometheus/api/v1/query_range" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		r.ParseForm()
+		switch r.Form.Get("query") {
+		case "up":
+			if r.Form.Get("start") != "1700000000" || r.Form.Get("end") != "1700003600" || r.Form.Get("step") != "60" {
+				http.Error(w, "unexpected range", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[1700000000,"1"],[1700000060.5,"0"],[1700000120,"NaN"]]}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/prometheus/", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	series, err := client.QueryRange(context.Background(), "up", start, start.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatalf("QueryRange() error = %v", err)
+	}
+	if len(series) != 1 || series[0].Metric["job"] != "api" || len(series[0].Points) != 2 {
+		t.Fatalf("QueryRange() = %+v, want one series with 2 points", series)
+	}
+	if p := series[0].Points[1]; p.Value != 0 || !p.Time.Equal(time.Unix(1700000060, 500000000)) {
+		t.Errorf("QueryRange() point = %+v", p)
+	}
+
+	if _, err := client.QueryRange(context.Background(), "up{", start, start.Add(time.Hour), time.Minute); err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("QueryRange() error = %v, want the Prometheus error", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for _, u := range []string{"prometheus:9090", "ftp://prometheus", "http://"} {
+		if _, err := NewClient(u, ""); err == nil {
+			t.Errorf("NewClient(%q) = nil error, want an invalid URL", u)
+		}
+	}
+}
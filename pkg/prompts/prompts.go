@@ -0,0 +1,318 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package prompts
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Builtin is the version of the prompts compiled into kube-copilot.
+const Builtin = "builtin"
+
+// rolloutFile holds the rollout weights of the versions of a prompt.
+const rolloutFile = "rollout.yaml"
+
+var (
+	namePattern       = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	versionPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	inputPattern      = regexp.MustCompile(`\{[a-z_]+\}`)
+	formatVerbPattern = regexp.MustCompile(`%%|%[a-z]`)
+)
+
+// DefaultDir returns the default prompts directory (~/.kube-copilot/prompts).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "prompts"), nil
+}
+
+// Store holds the versions of the prompts as <dir>/<name>/<version>.md files,
+// and their rollout weights as <dir>/<name>/rollout.yaml, e.g.:
+//
+//	builtin: 90
+//	v2: 10
+type Store struct {
+	Dir string
+}
+
+// NewStore creates a Store in the given directory.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Versions returns the stored versions of the prompt, sorted by name.
+func (s *Store) Versions(name string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, name, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(files))
+	for _, file := range files {
+		versions = append(versions, strings.TrimSuffix(filepath.Base(file), ".md"))
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Load reads a stored version of the prompt.
+func (s *Store) Load(name, version string) (string, error) {
+	if !namePattern.MatchString(name) || !versionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid prompt version %s@%s", name, version)
+	}
+	data, err := os.ReadFile(s.path(name, version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("prompt version %s@%s not found in %s", name, version, s.Dir)
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Save stores a version of the prompt.
+func (s *Store) Save(name, version, text string) error {
+	if !namePattern.MatchString(name) || !versionPattern.MatchString(version) || version == Builtin {
+		return fmt.Errorf("invalid prompt version %s@%s", name, version)
+	}
+	if err := os.MkdirAll(filepath.Join(s.Dir, name), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(name, version), []byte(text), 0600)
+}
+
+// Rollout returns the rollout weights of the versions of the prompt, or nil
+// if the prompt has no rollout.
+func (s *Store) Rollout(name string) (map[string]int, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, name, rolloutFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var weights map[string]int
+	if err := yaml.UnmarshalStrict(data, &weights); err != nil {
+		return nil, fmt.Errorf("invalid rollout of prompt %s: %v", name, err)
+	}
+	return weights, nil
+}
+
+func (s *Store) path(name, version string) string {
+	return filepath.Join(s.Dir, name, version+".md")
+}
+
+// ParsePins parses the "name=version" pins of the prompt versions.
+func ParsePins(values []string) (map[string]string, error) {
+	pins := map[string]string{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		name, version, ok := strings.Cut(value, "=")
+		name, version = strings.TrimSpace(name), strings.TrimSpace(version)
+		if !ok || !namePattern.MatchString(name) || !versionPattern.MatchString(version) {
+			return nil, fmt.Errorf("invalid prompt version %q, should be <name>=<version>", value)
+		}
+		pins[name] = version
+	}
+	return pins, nil
+}
+
+// Placeholders returns the inputs (e.g. {namespace}) and the format verbs
+// (e.g. %s) of a prompt, which all its versions must keep.
+func Placeholders(text string) []string {
+	inputs := inputPattern.FindAllString(text, -1)
+	sort.Strings(inputs)
+	inputs = slices.Compact(inputs)
+	for _, verb := range formatVerbPattern.FindAllString(text, -1) {
+		if verb != "%%" {
+			inputs = append(inputs, verb)
+		}
+	}
+	return inputs
+}
+
+// Selector selects the version of each prompt once, so that all the LLM
+// calls of a run use the same version.
+type Selector struct {
+	Store *Store
+	// Builtins are the prompts compiled into kube-copilot, by name.
+	Builtins map[string]string
+	// Pins select the versions of the prompts by name. The other prompts are
+	// picked according to their rollout weights, or the builtin version.
+	Pins map[string]string
+	// Intn returns a random number in [0, n); it is replaced in tests.
+	Intn func(n int) int
+
+	lock     sync.Mutex
+	selected map[string]string
+	texts    map[string]string
+}
+
+// NewSelector creates a Selector of the builtin prompts and of the versions in store.
+func NewSelector(store *Store, builtins map[string]string, pins map[string]string) *Selector {
+	return &Selector{Store: store, Builtins: builtins, Pins: pins, Intn: rand.Intn}
+}
+
+// Validate checks the pins and the rollouts, and that the stored versions
+// keep the placeholders of the builtin prompts.
+func (s *Selector) Validate() error {
+	var problems []string
+	for name, version := range s.Pins {
+		if _, ok := s.Builtins[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown prompt %q", name))
+		} else if version != Builtin {
+			if _, err := s.Store.Load(name, version); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	for name, builtin := range s.Builtins {
+		versions, err := s.Store.Versions(name)
+		if err != nil {
+			return err
+		}
+		want := Placeholders(builtin)
+		for _, version := range versions {
+			text, err := s.Store.Load(name, version)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			if got := Placeholders(text); !slices.Equal(got, want) {
+				problems = append(problems, fmt.Sprintf("prompt version %s@%s has the placeholders %v instead of %v", name, version, got, want))
+			}
+		}
+
+		weights, err := s.Store.Rollout(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for version, weight := range weights {
+			if weight < 0 {
+				problems = append(problems, fmt.Sprintf("rollout of prompt %s has a negative weight for %s", name, version))
+			}
+			if version != Builtin && !slices.Contains(versions, version) {
+				problems = append(problems, fmt.Sprintf("rollout of prompt %s references the unknown version %s", name, version))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid prompts: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Prompt returns the selected version of the prompt. A stored version that
+// cannot be read falls back to the builtin prompt.
+func (s *Selector) Prompt(name string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if text, ok := s.texts[name]; ok {
+		return text
+	}
+
+	version := s.choose(name)
+	text := s.Builtins[name]
+	if version != Builtin {
+		if stored, err := s.Store.Load(name, version); err == nil {
+			text = stored
+		} else {
+			version = Builtin
+		}
+	}
+	if s.selected == nil {
+		s.selected, s.texts = map[string]string{}, map[string]string{}
+	}
+	s.selected[name], s.texts[name] = version, text
+	return text
+}
+
+// choose returns the pinned version of the prompt, or a version drawn
+// according to its rollout weights.
+func (s *Selector) choose(name string) string {
+	if version, ok := s.Pins[name]; ok {
+		return version
+	}
+	weights, err := s.Store.Rollout(name)
+	if err != nil || len(weights) == 0 {
+		return Builtin
+	}
+
+	versions := make([]string, 0, len(weights))
+	total := 0
+	for version, weight := range weights {
+		if weight > 0 {
+			versions = append(versions, version)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return Builtin
+	}
+	sort.Strings(versions)
+	n := s.Intn(total)
+	for _, version := range versions {
+		if n -= weights[version]; n < 0 {
+			return version
+		}
+	}
+	return Builtin
+}
+
+// Selected returns the versions of the prompts used so far, by name.
+func (s *Selector) Selected() map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	selected := make(map[string]string, len(s.selected))
+	for name, version := range s.selected {
+		selected[name] = version
+	}
+	return selected
+}
+
+// Reset forgets the selections, so that the next run selects the versions again.
+func (s *Selector) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.selected, s.texts = nil, nil
+}
+
+// Label renders the selected versions as "name@version" pairs sorted by name, e.g. "audit@v2, plan@builtin".
+func Label(selected map[string]string) string {
+	pairs := make([]string, 0, len(selected))
+	for name, version := range selected {
+		pairs = append(pairs, name+"@"+version)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testBuiltins = map[string]string{
+	"audit": "Audit the pod {pod_name} in {namespace}.",
+	"plan":  "Plan %s.",
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, version := range []string{"v2", "v10"} {
+		if err := store.Save("audit", version, "Audit {pod_name} in {namespace} ("+version+")."); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if err := store.Save("audit", Builtin, "x"); err == nil {
+		t.Errorf("Save() of the builtin version should fail")
+	}
+	if err := store.Save("audit", "../v3", "x"); err == nil {
+		t.Errorf("Save() with path separators should fail")
+	}
+
+	versions, err := store.Versions("audit")
+	if err != nil || !reflect.DeepEqual(versions, []string{"v10", "v2"}) {
+		t.Errorf("Versions() = %v, %v, want [v10 v2]", versions, err)
+	}
+	if text, err := store.Load("audit", "v2"); err != nil || !strings.HasSuffix(text, "(v2).") {
+		t.Errorf("Load() = %q, %v", text, err)
+	}
+	if _, err := store.Load("audit", "v3"); err == nil {
+		t.Errorf("Load() of a missing version should fail")
+	}
+	if weights, err := store.Rollout("audit"); err != nil || weights != nil {
+		t.Errorf("Rollout() without rollout = %v, %v, want nil", weights, err)
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{values: nil, want: map[string]string{}},
+		{values: []string{"audit=v2", " plan = builtin ", ""}, want: map[string]string{"audit": "v2", "plan": "builtin"}},
+		{values: []string{"audit=v2", "audit=v3"}, want: map[string]string{"audit": "v3"}},
+		{values: []string{"audit"}, wantErr: true},
+		{values: []string{"audit=../v2"}, wantErr: true},
+		{values: []string{"Audit=v2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.values, ","), func(t *testing.T) {
+			got, err := ParsePins(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "no placeholders", want: nil},
+		{text: "{namespace} and {pod_name}, again {namespace}", want: []string{"{namespace}", "{pod_name}"}},
+		{text: "Plan %s in 100%% of %d cases", want: []string{"%s", "%d"}},
+		{text: "JSON {\"key\": 1}", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Placeholders(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Placeholders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pins    map[string]string
+		wantErr string
+	}{
+		{name: "builtin only"},
+		{name: "pinned builtin", pins: map[string]string{"audit": Builtin}},
+		{
+			name:  "pinned version",
+			files: map[string]string{"audit/v2.md": "Check {namespace}/{pod_name}."},
+			pins:  map[string]string{"audit": "v2"},
+		},
+		{name: "unknown prompt", pins: map[string]string{"fix": "v2"}, wantErr: `unknown prompt "fix"`},
+		{name: "missing version", pins: map[string]string{"audit": "v2"}, wantErr: "audit@v2 not found"},
+		{
+			name:    "missing placeholder",
+			files:   map[string]string{"plan/v2.md": "Plan it."},
+			wantErr: "plan@v2 has the placeholders",
+		},
+		{
+			name:    "unknown rollout version",
+			files:   map[string]string{"audit/rollout.yaml": "builtin: 90\nv3: 10\n"},
+			wantErr: "unknown version v3",
+		},
+		{
+			name:    "negative weight",
+			files:   map[string]string{"audit/rollout.yaml": "builtin: -1\n"},
+			wantErr: "negative weight",
+		},
+		{
+			name:    "invalid rollout",
+			files:   map[string]string{"audit/rollout.yaml": "builtin: all\n"},
+			wantErr: "invalid rollout of prompt audit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := testStore(t, tt.files)
+			err := NewSelector(store, testBuiltins, tt.pins).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelector(t *testing.T) {
+	store := testStore(t, map[string]string{
+		"audit/v2.md":        "Audit v2 of {pod_name} in {namespace}.",
+		"audit/rollout.yaml": "builtin: 90\nv2: 10\n",
+		"plan/v2.md":         "Plan v2 of %s.",
+	})
+
+	tests := []struct {
+		name string
+		pins map[string]string
+		draw int
+		want map[string]string
+	}{
+		{name: "rollout builtin", draw: 89, want: map[string]string{"audit": Builtin, "plan": Builtin}},
+		{name: "rollout version", draw: 90, want: map[string]string{"audit": "v2", "plan": Builtin}},
+		{name: "pinned", pins: map[string]string{"audit": Builtin, "plan": "v2"}, draw: 95, want: map[string]string{"audit": Builtin, "plan": "v2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := NewSelector(store, testBuiltins, tt.pins)
+			draws := 0
+			selector.Intn = func(n int) int {
+				draws++
+				if n != 100 {
+					t.Errorf("Intn(%d), want the total weight 100", n)
+				}
+				return tt.draw
+			}
+
+			for name, version := range tt.want {
+				text := selector.Prompt(name)
+				if version == Builtin && text != testBuiltins[name] {
+					t.Errorf("Prompt(%s) = %q, want the builtin prompt", name, text)
+				}
+				if version != Builtin && !strings.Contains(text, version) {
+					t.Errorf("Prompt(%s) = %q, want version %s", name, text, version)
+				}
+				// The version is selected once per run.
+				if again := selector.Prompt(name); again != text {
+					t.Errorf("Prompt(%s) changed within a run: %q != %q", name, again, text)
+				}
+			}
+			if got := selector.Selected(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Selected() = %v, want %v", got, tt.want)
+			}
+			if draws > 1 {
+				t.Errorf("the rollout was drawn %d times, want at most once", draws)
+			}
+
+			selector.Reset()
+			if got := selector.Selected(); len(got) != 0 {
+				t.Errorf("Selected() after Reset() = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	if got := Label(map[string]string{"plan": Builtin, "audit": "v2"}); got != "audit@v2, plan@builtin" {
+		t.Errorf("Label() = %q", got)
+	}
+	if got := Label(nil); got != "" {
+		t.Errorf("Label(nil) = %q, want empty", got)
+	}
+}
+
+// testStore creates a Store holding the files.
+func testStore(t *testing.T, files map[string]string) *Store {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewStore(dir)
+}
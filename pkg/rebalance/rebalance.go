@@ -0,0 +1,258 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package rebalance detects the node utilization imbalance and the Pod
+// placement skew that the descheduler would act on.
+package rebalance
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Classes of the Node utilization.
+const (
+	Underutilized = "underutilized"
+	Overutilized  = "overutilized"
+	Balanced      = "balanced"
+)
+
+// Thresholds are the percentages of the allocatable CPU and memory requested
+// below which a Node is underutilized and above which it is overutilized,
+// following the LowNodeUtilization strategy of the descheduler.
+type Thresholds struct {
+	Low  int
+	High int
+}
+
+// DefaultThresholds are the thresholds used when none are given.
+var DefaultThresholds = Thresholds{Low: 20, High: 80}
+
+// Validate returns an error if the thresholds are not 0 <= Low < High <= 100.
+func (t Thresholds) Validate() error {
+	if t.Low < 0 || t.High > 100 || t.Low >= t.High {
+		return fmt.Errorf("invalid thresholds %d%%/%d%%, should be 0 <= low < high <= 100", t.Low, t.High)
+	}
+	return nil
+}
+
+// NodeUtilization is the requested share of the allocatable resources of a Node.
+type NodeUtilization struct {
+	Node          string  `json:"node"`
+	Pods          int     `json:"pods"`
+	CPUPercent    float64 `json:"cpuPercent"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	Class         string  `json:"class"`
+}
+
+// Skew is a workload whose Pods are concentrated on fewer Nodes than they could spread on.
+type Skew struct {
+	Namespace string `json:"namespace"`
+	// Owner is the kind and name of the controller of the Pods, e.g. ReplicaSet/web-5d4f8.
+	Owner string `json:"owner"`
+	Pods  int    `json:"pods"`
+	// MaxPerNode is the number of Pods on the most loaded Node, and Ideal the
+	// number with an even spread across the schedulable Nodes.
+	MaxPerNode int    `json:"maxPerNode"`
+	Ideal      int    `json:"ideal"`
+	Node       string `json:"node"`
+}
+
+// Report is the result of the rebalancing analysis.
+type Report struct {
+	Thresholds Thresholds        `json:"thresholds"`
+	Nodes      []NodeUtilization `json:"nodes"`
+	Skews      []Skew            `json:"skews"`
+}
+
+// Analyze computes the utilization of the schedulable Nodes from the requests
+// of their Pods and the placement skew of the replicated workloads.
+func Analyze(snapshot *kubernetes.Snapshot, thresholds Thresholds) *Report {
+	report := &Report{Thresholds: thresholds}
+
+	schedulable := make(map[string]*corev1.Node)
+	for i := range snapshot.Nodes {
+		if node := &snapshot.Nodes[i]; !node.Spec.Unschedulable {
+			schedulable[node.Name] = node
+		}
+	}
+
+	type requests struct {
+		pods        int
+		cpu, memory int64
+	}
+	requested := make(map[string]*requests)
+	owners := make(map[string]map[string]int)
+	for _, pod := range snapshot.Pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		r := requested[pod.Spec.NodeName]
+		if r == nil {
+			r = &requests{}
+			requested[pod.Spec.NodeName] = r
+		}
+		r.pods++
+		for _, container := range pod.Spec.Containers {
+			r.cpu += container.Resources.Requests.Cpu().MilliValue()
+			r.memory += container.Resources.Requests.Memory().Value()
+		}
+
+		if owner := controller(pod); owner != "" && schedulable[pod.Spec.NodeName] != nil {
+			key := pod.Namespace + "/" + owner
+			if owners[key] == nil {
+				owners[key] = make(map[string]int)
+			}
+			owners[key][pod.Spec.NodeName]++
+		}
+	}
+
+	for name, node := range schedulable {
+		r := requested[name]
+		if r == nil {
+			r = &requests{}
+		}
+		u := NodeUtilization{
+			Node:          name,
+			Pods:          r.pods,
+			CPUPercent:    percent(r.cpu, node.Status.Allocatable.Cpu().MilliValue()),
+			MemoryPercent: percent(r.memory, node.Status.Allocatable.Memory().Value()),
+			Class:         Balanced,
+		}
+		// Same as the descheduler: a Node is underutilized when all its
+		// resources are below the low threshold, and overutilized when any
+		// is above the high threshold.
+		switch {
+		case u.CPUPercent > float64(thresholds.High) || u.MemoryPercent > float64(thresholds.High):
+			u.Class = Overutilized
+		case u.CPUPercent < float64(thresholds.Low) && u.MemoryPercent < float64(thresholds.Low):
+			u.Class = Underutilized
+		}
+		report.Nodes = append(report.Nodes, u)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool { return report.Nodes[i].Node < report.Nodes[j].Node })
+
+	for key, nodes := range owners {
+		pods, maxPerNode, busiest := 0, 0, ""
+		for node, count := range nodes {
+			pods += count
+			if count > maxPerNode || (count == maxPerNode && node < busiest) {
+				maxPerNode, busiest = count, node
+			}
+		}
+		ideal := int(math.Ceil(float64(pods) / float64(len(schedulable))))
+		if pods < 2 || maxPerNode <= ideal {
+			continue
+		}
+		namespace, owner, _ := strings.Cut(key, "/")
+		report.Skews = append(report.Skews, Skew{Namespace: namespace, Owner: owner, Pods: pods, MaxPerNode: maxPerNode, Ideal: ideal, Node: busiest})
+	}
+	sort.Slice(report.Skews, func(i, j int) bool {
+		if report.Skews[i].Namespace != report.Skews[j].Namespace {
+			return report.Skews[i].Namespace < report.Skews[j].Namespace
+		}
+		return report.Skews[i].Owner < report.Skews[j].Owner
+	})
+	return report
+}
+
+// Balanced returns true if no workload is skewed and there are not both
+// over- and underutilized Nodes, which is when the descheduler moves Pods.
+func (r *Report) Balanced() bool {
+	var over, under bool
+	for _, node := range r.Nodes {
+		over = over || node.Class == Overutilized
+		under = under || node.Class == Underutilized
+	}
+	return !(over && under) && len(r.Skews) == 0
+}
+
+// Format renders the report as plain text.
+func (r *Report) Format() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Node utilization (requests / allocatable, underutilized below %d%%, overutilized above %d%%):\n", r.Thresholds.Low, r.Thresholds.High)
+	for _, node := range r.Nodes {
+		fmt.Fprintf(&sb, "- %s: cpu %.0f%%, memory %.0f%%, %d pods, %s\n", node.Node, node.CPUPercent, node.MemoryPercent, node.Pods, node.Class)
+	}
+
+	if len(r.Skews) == 0 {
+		sb.WriteString("\nNo Pod placement skew found.")
+		return sb.String()
+	}
+	sb.WriteString("\nPod placement skew:\n")
+	for _, skew := range r.Skews {
+		fmt.Fprintf(&sb, "- %s %s: %d of %d pods on node %s (%d with an even spread)\n", skew.Namespace, skew.Owner, skew.MaxPerNode, skew.Pods, skew.Node, skew.Ideal)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Policy returns a DeschedulerPolicy evicting the Pods from the overutilized
+// Nodes and the duplicate or skewed Pods, with the report thresholds.
+func (r *Report) Policy() string {
+	return fmt.Sprintf(`apiVersion: "descheduler/v1alpha2"
+kind: "DeschedulerPolicy"
+profiles:
+  - name: rebalance
+    pluginConfig:
+      - name: "DefaultEvictor"
+        args:
+          evictLocalStoragePods: false
+          ignorePvcPods: true
+          nodeFit: true
+      - name: "LowNodeUtilization"
+        args:
+          thresholds:
+            cpu: %[1]d
+            memory: %[1]d
+          targetThresholds:
+            cpu: %[2]d
+            memory: %[2]d
+      - name: "RemoveDuplicates"
+      - name: "RemovePodsViolatingTopologySpreadConstraint"
+        args:
+          constraints:
+            - DoNotSchedule
+            - ScheduleAnyway
+    plugins:
+      balance:
+        enabled:
+          - "LowNodeUtilization"
+          - "RemoveDuplicates"
+          - "RemovePodsViolatingTopologySpreadConstraint"
+`, r.Thresholds.Low, r.Thresholds.High)
+}
+
+// controller returns the kind and name of the controller of the Pod, or ""
+// for unmanaged and DaemonSet Pods which are never rebalanced.
+func controller(pod corev1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && ref.Kind != "DaemonSet" {
+			return ref.Kind + "/" + ref.Name
+		}
+	}
+	return ""
+}
+
+func percent(value, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(value) * 100 / float64(total)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rebalance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func node(name string, unschedulable bool) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		}},
+	}
+}
+
+func pod(name, owner, kind, node, cpu, memory string) corev1.Pod {
+	controller := true
+	p := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			}}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if owner != "" {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: owner, Controller: &controller}}
+	}
+	return p
+}
+
+func TestAnalyze(t *testing.T) {
+	snapshot := &kubernetes.Snapshot{
+		Nodes: []corev1.Node{node("node-a", false), node("node-b", false), node("node-c", true)},
+		Pods: []corev1.Pod{
+			pod("web-1", "web", "ReplicaSet", "node-a", "1", "2Gi"),
+			pod("web-2", "web", "ReplicaSet", "node-a", "1", "2Gi"),
+			pod("web-3", "web", "ReplicaSet", "node-a", "1600m", "1Gi"),
+			pod("api-1", "api", "ReplicaSet", "node-a", "100m", "128Mi"),
+			pod("api-2", "api", "ReplicaSet", "node-b", "100m", "128Mi"),
+			pod("agent-1", "agent", "DaemonSet", "node-a", "100m", "64Mi"),
+			pod("agent-2", "agent", "DaemonSet", "node-b", "100m", "64Mi"),
+			pod("debug", "", "", "node-b", "100m", "64Mi"),
+			pod("cordoned", "db", "StatefulSet", "node-c", "1", "1Gi"),
+		},
+	}
+	done := pod("job", "job", "Job", "node-b", "4", "8Gi")
+	done.Status.Phase = corev1.PodSucceeded
+	snapshot.Pods = append(snapshot.Pods, done)
+
+	report := Analyze(snapshot, DefaultThresholds)
+	if len(report.Nodes) != 2 {
+		t.Fatalf("Analyze() nodes = %+v, want the 2 schedulable nodes", report.Nodes)
+	}
+	if a := report.Nodes[0]; a.Node != "node-a" || a.Class != Overutilized || a.Pods != 5 || a.CPUPercent != 95 {
+		t.Errorf("Analyze() node-a = %+v, want overutilized with 95%% cpu and 5 pods", a)
+	}
+	if b := report.Nodes[1]; b.Node != "node-b" || b.Class != Underutilized || b.Pods != 3 {
+		t.Errorf("Analyze() node-b = %+v, want underutilized with 3 pods", b)
+	}
+
+	if len(report.Skews) != 1 {
+		t.Fatalf("Analyze() skews = %+v, want only web", report.Skews)
+	}
+	if skew := report.Skews[0]; skew.Owner != "ReplicaSet/web" || skew.Pods != 3 || skew.MaxPerNode != 3 || skew.Ideal != 2 || skew.Node != "node-a" {
+		t.Errorf("Analyze() skew = %+v", skew)
+	}
+	if report.Balanced() {
+		t.Errorf("Balanced() = true, want false")
+	}
+
+	formatted := report.Format()
+	for _, want := range []string{"node-a: cpu 95%", "underutilized below 20%", "default ReplicaSet/web: 3 of 3 pods on node node-a (2 with an even spread)"} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Format() = %q, want it to contain %q", formatted, want)
+		}
+	}
+}
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   bool
+	}{
+		{name: "empty", report: Report{}, want: true},
+		{name: "all underutilized", report: Report{Nodes: []NodeUtilization{{Class: Underutilized}, {Class: Underutilized}}}, want: true},
+		{name: "over and balanced", report: Report{Nodes: []NodeUtilization{{Class: Overutilized}, {Class: Balanced}}}, want: true},
+		{name: "over and under", report: Report{Nodes: []NodeUtilization{{Class: Overutilized}, {Class: Underutilized}}}, want: false},
+		{name: "skewed", report: Report{Skews: []Skew{{Owner: "ReplicaSet/web"}}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.Balanced(); got != tt.want {
+				t.Errorf("Balanced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	for _, thresholds := range []Thresholds{{Low: 30, High: 20}, {Low: -1, High: 50}, {Low: 20, High: 101}} {
+		if err := thresholds.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", thresholds)
+		}
+	}
+	if err := DefaultThresholds.Validate(); err != nil {
+		t.Errorf("Validate(default) = %v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	report := &Report{Thresholds: Thresholds{Low: 25, High: 70}}
+	var policy struct {
+		Kind     string `json:"kind"`
+		Profiles []struct {
+			PluginConfig []struct {
+				Name string                 `json:"name"`
+				Args map[string]interface{} `json:"args"`
+			} `json:"pluginConfig"`
+		} `json:"profiles"`
+	}
+	if err := yaml.Unmarshal([]byte(report.Policy()), &policy); err != nil {
+		t.Fatalf("Policy() is not valid YAML: %v", err)
+	}
+	if policy.Kind != "DeschedulerPolicy" || len(policy.Profiles) != 1 {
+		t.Fatalf("Policy() = %+v", policy)
+	}
+	for _, plugin := range policy.Profiles[0].PluginConfig {
+		if plugin.Name == "LowNodeUtilization" {
+			if low := plugin.Args["thresholds"].(map[string]interface{})["cpu"]; low != float64(25) {
+				t.Errorf("Policy() low cpu threshold = %v, want 25", low)
+			}
+			if high := plugin.Args["targetThresholds"].(map[string]interface{})["memory"]; high != float64(70) {
+				t.Errorf("Policy() high memory threshold = %v, want 70", high)
+			}
+			return
+		}
+	}
+	t.Errorf("Policy() has no LowNodeUtilization plugin")
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/history"
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Supported report formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// maxOutputSize bounds the size of each command output in the report.
+const maxOutputSize = 8000
+
+// run is the part of a saved result rendered in the report. The result of a
+// history entry is a single run, or a list of runs for batch and namespace audits.
+type run struct {
+	Command  string                 `json:"command"`
+	Target   string                 `json:"target,omitempty"`
+	Answer   string                 `json:"answer"`
+	Findings []workflows.Finding    `json:"findings,omitempty"`
+	Trace    []workflows.StepDetail `json:"trace,omitempty"`
+	DryRun   []workflows.ToolCall   `json:"dry_run,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+
+	Artifacts []artifacts.Artifact `json:"artifacts,omitempty"`
+}
+
+// ValidFormat returns true if the format is supported.
+func ValidFormat(format string) bool {
+	return format == FormatMarkdown || format == FormatHTML
+}
+
+// Extension returns the file extension of the format.
+func Extension(format string) string {
+	if format == FormatHTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+// ContentType returns the MIME type of the format.
+func ContentType(format string) string {
+	if format == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// Render renders the entry as a report in the given format.
+func Render(entry *history.Entry, format string) ([]byte, error) {
+	switch format {
+	case FormatMarkdown:
+		return []byte(Markdown(entry)), nil
+	case FormatHTML:
+		return HTML(entry)
+	default:
+		return nil, fmt.Errorf("unsupported report format %q, should be markdown or html", format)
+	}
+}
+
+// Markdown renders the entry as a Markdown report with the question, the
+// reasoning steps with the commands run and their outputs, and the final answer.
+func Markdown(entry *history.Entry) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title(entry))
+	sb.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&sb, "| ID | %s |\n", entry.ID)
+	fmt.Fprintf(&sb, "| Time | %s |\n", entry.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "| Model | %s |\n", entry.Model)
+	if entry.Context != "" {
+		fmt.Fprintf(&sb, "| Cluster context | %s |\n", entry.Context)
+	}
+	if len(entry.Prompts) > 0 {
+		fmt.Fprintf(&sb, "| Prompts | %s |\n", prompts.Label(entry.Prompts))
+	}
+
+	if entry.Summary != "" {
+		fmt.Fprintf(&sb, "\n## Summary\n\n%s\n", entry.Summary)
+	}
+
+	sb.WriteString("\n## Question\n\n")
+	writeCode(&sb, "sh", "kube-copilot "+strings.Join(entry.Args, " "))
+
+	runs := parseRuns(entry.Result)
+	for _, r := range runs {
+		heading := "##"
+		if len(runs) > 1 {
+			heading = "###"
+			fmt.Fprintf(&sb, "## %s\n\n", runTitle(r))
+		}
+		writeRun(&sb, heading, r)
+	}
+
+	sb.WriteString("## Final answer\n\n")
+	sb.WriteString(strings.TrimSpace(entry.Answer))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// HTML renders the entry as a standalone HTML page. Raw HTML in the answer and
+// the command outputs is escaped, so the page is safe to attach to tickets.
+func HTML(entry *history.Entry) ([]byte, error) {
+	var body bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	if err := md.Convert([]byte(Markdown(entry)), &body); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	err := pageTemplate.Execute(&out, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: title(entry),
+		Body:  template.HTML(body.String()),
+	})
+	return out.Bytes(), err
+}
+
+// parseRuns decodes the result of an entry (nil if it has no result).
+func parseRuns(result json.RawMessage) []run {
+	if len(result) == 0 {
+		return nil
+	}
+	var single run
+	if err := json.Unmarshal(result, &single); err == nil {
+		return []run{single}
+	}
+	var runs []run
+	if err := json.Unmarshal(result, &runs); err == nil {
+		return runs
+	}
+	return nil
+}
+
+// writeRun writes the reasoning steps, skipped commands and findings of a run.
+func writeRun(sb *strings.Builder, heading string, r run) {
+	if r.Error != "" {
+		fmt.Fprintf(sb, "%s Error\n\n", heading)
+		writeCode(sb, "", r.Error)
+	}
+
+	if len(r.Trace) > 0 {
+		fmt.Fprintf(sb, "%s Reasoning steps\n\n", heading)
+		for i, step := range r.Trace {
+			fmt.Fprintf(sb, "**%d. %s**", i+1, oneLine(step.Name))
+			if step.Status != "" {
+				fmt.Fprintf(sb, " (%s)", step.Status)
+			}
+			sb.WriteString("\n\n")
+			if step.Description != "" {
+				sb.WriteString(strings.TrimSpace(step.Description) + "\n\n")
+			}
+			if step.Action.Name != "" {
+				fmt.Fprintf(sb, "Command (`%s`):\n\n", step.Action.Name)
+				writeCode(sb, "sh", step.Action.Input)
+			}
+			if step.Observation != "" {
+				sb.WriteString("Output:\n\n")
+				writeCode(sb, "", truncateOutput(step.Observation))
+			}
+		}
+	}
+
+	if len(r.DryRun) > 0 {
+		fmt.Fprintf(sb, "%s Skipped commands (dry run)\n\n", heading)
+		for _, call := range r.DryRun {
+			writeCode(sb, "sh", call.Input)
+		}
+	}
+
+	if len(r.Artifacts) > 0 {
+		fmt.Fprintf(sb, "%s Artifacts\n\n", heading)
+		sb.WriteString("Only excerpts of these tool outputs entered the prompt; the full outputs are kept in the artifact store:\n\n")
+		for _, artifact := range r.Artifacts {
+			fmt.Fprintf(sb, "- `%s` (%s, %d bytes)\n", artifact.Handle, artifact.Tool, artifact.Size)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Findings) > 0 {
+		fmt.Fprintf(sb, "%s Findings\n\n", heading)
+		sb.WriteString("| Severity | Finding | Remediation |\n|---|---|---|\n")
+		for _, finding := range r.Findings {
+			fmt.Fprintf(sb, "| %s | %s | %s |\n", tableCell(finding.Severity), tableCell(finding.Title), tableCell(finding.Remediation))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// writeCode writes s as a fenced code block, with a fence longer than any
+// backtick run in s so that the outputs cannot break out of the block.
+func writeCode(sb *strings.Builder, lang, s string) {
+	longest, current := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(sb, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(s, "\n"), fence)
+}
+
+// truncateOutput shortens the command outputs larger than maxOutputSize.
+func truncateOutput(s string) string {
+	if len(s) <= maxOutputSize {
+		return s
+	}
+	return s[:maxOutputSize] + fmt.Sprintf("\n... (%d more bytes truncated)", len(s)-maxOutputSize)
+}
+
+// tableCell escapes s for a Markdown table cell.
+func tableCell(s string) string {
+	return strings.ReplaceAll(oneLine(s), "|", `\|`)
+}
+
+// oneLine joins the lines of s with spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func title(entry *history.Entry) string {
+	return "kube-copilot " + entry.Command + " report"
+}
+
+func runTitle(r run) string {
+	if r.Target == "" {
+		return r.Command
+	}
+	return r.Command + " " + r.Target
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; line-height: 1.5; color: #1f2328; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; border-radius: 6px; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.9em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/history"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+)
+
+func testEntry() *history.Entry {
+	return &history.Entry{
+		ID:      "20241101-101010-000",
+		Time:    time.Date(2024, 11, 1, 10, 10, 10, 0, time.UTC),
+		Command: "diagnose",
+		Args:    []string{"diagnose", "web-1", "-n", "web"},
+		Model:   "gpt-4o",
+		Context: "prod",
+		Title:   "web-1 image pull failure",
+		Summary: "web-1 cannot pull its image.",
+		Prompts: map[string]string{"diagnose": "v2"},
+		Answer:  "The image tag does not exist.\n<script>alert(1)</script>",
+		Result: json.RawMessage(`{"command":"diagnose","target":"web/web-1","answer":"x",
+			"findings":[{"title":"Image | pull","severity":"HIGH","remediation":"Fix the tag"}],
+			"trace":[{"name":"Get pod","action":{"name":"kubectl","input":"kubectl get pod web-1 -n web"},"observation":"` + "```" + `\nImagePullBackOff","status":"completed"}]}`),
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	batch := testEntry()
+	batch.Result = json.RawMessage(`[{"command":"execute","target":"list pods","answer":"a"},{"command":"execute","target":"list nodes","answer":"b","error":"boom"}]`)
+
+	tests := []struct {
+		name  string
+		entry *history.Entry
+		want  []string
+	}{
+		{
+			name:  "single run",
+			entry: testEntry(),
+			want: []string{
+				"# kube-copilot diagnose report",
+				"| Cluster context | prod |\n| Prompts | diagnose@v2 |",
+				"## Summary\n\nweb-1 cannot pull its image.\n\n## Question",
+				"```sh\nkube-copilot diagnose web-1 -n web\n```",
+				"## Reasoning steps",
+				"**1. Get pod** (completed)",
+				"Command (`kubectl`):\n\n```sh\nkubectl get pod web-1 -n web\n```",
+				"````\n```\nImagePullBackOff\n````",
+				"| HIGH | Image \\| pull | Fix the tag |",
+				"## Final answer\n\nThe image tag does not exist.",
+			},
+		},
+		{
+			name:  "batch runs",
+			entry: batch,
+			want: []string{
+				"## execute list pods",
+				"## execute list nodes\n\n### Error\n\n```\nboom\n```",
+			},
+		},
+		{
+			name:  "no result",
+			entry: &history.Entry{ID: "1", Command: "generate", Answer: "apiVersion: v1"},
+			want:  []string{"## Final answer\n\napiVersion: v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Markdown(tt.entry)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Markdown() = %s\nwant it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	out, err := HTML(testEntry())
+	if err != nil {
+		t.Fatalf("HTML() error = %v", err)
+	}
+	got := string(out)
+	for _, want := range []string{"<title>kube-copilot diagnose report</title>", "<table>", "ImagePullBackOff"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML() = %s\nwant it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("HTML() should not include the raw HTML of the answer")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := history.NewStore(t.TempDir())
+	entry := testEntry()
+	if err := store.Save(entry); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	artifactStore := artifacts.NewStore(t.TempDir())
+	run := artifactStore.NewRun()
+	artifact, err := run.Save("kubectl", `{"kind": "List"}`)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	monitor := kubernetes.NewHealthMonitor(time.Minute)
+	monitor.Clusters = func() ([]string, error) { return []string{"prod"}, nil }
+	monitor.Check = func(ctx context.Context, cluster string) kubernetes.ClusterHealth {
+		return kubernetes.ClusterHealth{Cluster: cluster, Status: kubernetes.ClusterUnreachable, Error: "connection refused"}
+	}
+	if err := monitor.CheckAll(context.Background()); err != nil {
+		t.Fatalf("CheckAll() error = %v", err)
+	}
+	handler := NewHandler(store, artifactStore, monitor)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantType    string
+		wantContain string
+	}{
+		{name: "list", path: "/api/v1/history", wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"id":"20241101-101010-000"`},
+		{name: "list titles", path: "/api/v1/history", wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"title":"web-1 image pull failure"`},
+		{name: "list of prompt version", path: "/api/v1/history?prompt=diagnose@v2", wantStatus: http.StatusOK, wantContain: `"prompts":{"diagnose":"v2"}`},
+		{name: "list of other prompt version", path: "/api/v1/history?prompt=diagnose@builtin", wantStatus: http.StatusOK, wantContain: "[]"},
+		{name: "entry", path: "/api/v1/history/20241101-101010-000", wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"answer":`},
+		{name: "html report", path: "/api/v1/history/20241101-101010-000/report", wantStatus: http.StatusOK, wantType: "text/html; charset=utf-8", wantContain: "<h2>Reasoning steps</h2>"},
+		{name: "markdown report", path: "/api/v1/history/20241101-101010-000/report?format=markdown", wantStatus: http.StatusOK, wantType: "text/markdown; charset=utf-8", wantContain: "## Final answer"},
+		{name: "unsupported format", path: "/api/v1/history/20241101-101010-000/report?format=pdf", wantStatus: http.StatusBadRequest},
+		{name: "missing entry", path: "/api/v1/history/missing/report", wantStatus: http.StatusNotFound},
+		{name: "artifacts", path: "/api/v1/artifacts/" + run.ID, wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"handle":"` + artifact.Handle + `"`},
+		{name: "artifact", path: "/api/v1/artifacts/" + run.ID + "/" + artifact.Name, wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"kind": "List"`},
+		{name: "missing artifact", path: "/api/v1/artifacts/" + run.ID + "/002-kubectl.txt", wantStatus: http.StatusNotFound},
+		{name: "missing run", path: "/api/v1/artifacts/missing", wantStatus: http.StatusNotFound},
+		{name: "findings", path: "/api/v1/findings?severity=high&command=diagnose", wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"run":"20241101-101010-000","time":"2024-11-01T10:10:10Z","command":"diagnose","target":"web/web-1","prompts":{"diagnose":"v2"},"title":"Image | pull"`},
+		{name: "findings above severity", path: "/api/v1/findings?severity=CRITICAL", wantStatus: http.StatusOK, wantContain: "[]"},
+		{name: "findings of category", path: "/api/v1/findings?category=vulnerability", wantStatus: http.StatusOK, wantContain: "[]"},
+		{name: "findings of prompt", path: "/api/v1/findings?prompt=diagnose", wantStatus: http.StatusOK, wantContain: `"title":"Image | pull"`},
+		{name: "findings of other prompt", path: "/api/v1/findings?prompt=audit@v2", wantStatus: http.StatusOK, wantContain: "[]"},
+		{name: "unsupported severity", path: "/api/v1/findings?severity=severe", wantStatus: http.StatusBadRequest},
+		{name: "clusters", path: "/api/v1/clusters", wantStatus: http.StatusOK, wantType: "application/json", wantContain: `"cluster":"prod","status":"unreachable"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantType != "" && rec.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantContain) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body.String(), tt.wantContain)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(store, artifactStore, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/clusters", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status of the clusters without monitor = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
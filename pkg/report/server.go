@@ -0,0 +1,255 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/history"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+// Summary is an entry of the history listing returned by the API.
+type Summary struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Args    []string  `json:"args"`
+	Model   string    `json:"model"`
+	Context string    `json:"context,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	// Prompts are the versions of the prompts used by the run, by name.
+	Prompts map[string]string `json:"prompts,omitempty"`
+}
+
+// FindingEntry is a finding of a run returned by the findings API.
+type FindingEntry struct {
+	Run     string            `json:"run"`
+	Time    time.Time         `json:"time"`
+	Command string            `json:"command"`
+	Target  string            `json:"target,omitempty"`
+	Prompts map[string]string `json:"prompts,omitempty"`
+	workflows.Finding
+}
+
+// NewHandler returns the HTTP API serving the history and its reports:
+//
+//	GET /api/v1/history                    lists the runs, newest first
+//	GET /api/v1/history/{id}               returns a run as JSON
+//	GET /api/v1/history/{id}/report        downloads the report of a run
+//	GET /api/v1/findings                   lists the findings of the runs, newest first
+//	GET /api/v1/artifacts/{run}            lists the tool outputs saved by a run
+//	GET /api/v1/artifacts/{run}/{name}     downloads a tool output
+//	GET /api/v1/clusters                   returns the health of the clusters checked by monitor
+//
+// The report format is selected by the "format" query parameter (markdown
+// or html, defaults to html). The findings are filtered by the "severity"
+// (at or above), "category", "resource", "id" and "command" query parameters.
+// The runs and the findings are filtered by the "prompt" query parameter
+// (name@version, e.g. audit@v2) to compare the versions of a prompt.
+// The clusters route is disabled if monitor is nil.
+func NewHandler(store *history.Store, artifactStore *artifacts.Store, monitor *kubernetes.HealthMonitor) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/history", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := store.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		prompt := r.URL.Query().Get("prompt")
+		summaries := make([]Summary, 0, len(entries))
+		for _, entry := range entries {
+			if !matchPrompt(prompt, entry.Prompts) {
+				continue
+			}
+			summaries = append(summaries, Summary{
+				ID:      entry.ID,
+				Time:    entry.Time,
+				Command: entry.Command,
+				Args:    entry.Args,
+				Model:   entry.Model,
+				Context: entry.Context,
+				Title:   entry.Title,
+				Summary: entry.Summary,
+				Prompts: entry.Prompts,
+			})
+		}
+		writeJSON(w, summaries)
+	})
+	mux.HandleFunc("GET /api/v1/history/{id}", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := loadEntry(w, store, r.PathValue("id"))
+		if ok {
+			writeJSON(w, entry)
+		}
+	})
+	mux.HandleFunc("GET /api/v1/history/{id}/report", func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatHTML
+		}
+		if !ValidFormat(format) {
+			http.Error(w, fmt.Sprintf("unsupported report format %q, should be markdown or html", format), http.StatusBadRequest)
+			return
+		}
+		entry, ok := loadEntry(w, store, r.PathValue("id"))
+		if !ok {
+			return
+		}
+		out, err := Render(entry, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", FileName(entry, format)))
+		w.Write(out)
+	})
+	mux.HandleFunc("GET /api/v1/findings", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		severity := query.Get("severity")
+		if severity != "" && !workflows.ValidSeverity(severity) {
+			http.Error(w, fmt.Sprintf("unsupported severity %q, should be one of CRITICAL, HIGH, MEDIUM or LOW", severity), http.StatusBadRequest)
+			return
+		}
+		entries, err := store.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		findings := []FindingEntry{}
+		for _, entry := range entries {
+			if !matchPrompt(query.Get("prompt"), entry.Prompts) {
+				continue
+			}
+			for _, run := range parseRuns(entry.Result) {
+				matched := run.Findings
+				if severity != "" {
+					matched = workflows.FindingsAtOrAbove(matched, severity)
+				}
+				for _, finding := range matched {
+					if matchFinding(query, run.Command, finding) {
+						findings = append(findings, FindingEntry{Run: entry.ID, Time: entry.Time, Command: run.Command, Target: run.Target, Prompts: entry.Prompts, Finding: finding})
+					}
+				}
+			}
+		}
+		writeJSON(w, findings)
+	})
+	mux.HandleFunc("GET /api/v1/artifacts/{run}", func(w http.ResponseWriter, r *http.Request) {
+		list, err := artifactStore.List(r.PathValue("run"))
+		if err != nil {
+			writeArtifactError(w, err)
+			return
+		}
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("GET /api/v1/artifacts/{run}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		f, err := artifactStore.Open(r.PathValue("run"), r.PathValue("name"))
+		if err != nil {
+			writeArtifactError(w, err)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType := "text/plain; charset=utf-8"
+		if strings.HasSuffix(info.Name(), ".json") {
+			contentType = "application/json"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+	mux.HandleFunc("GET /api/v1/clusters", func(w http.ResponseWriter, r *http.Request) {
+		if monitor == nil {
+			http.Error(w, "cluster health monitoring is disabled", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, monitor.Statuses())
+	})
+	return mux
+}
+
+// matchFinding returns true if the finding of the command matches the
+// category, resource, id and command filters of the query.
+func matchFinding(query url.Values, command string, finding workflows.Finding) bool {
+	resource := ""
+	if finding.Resource != nil {
+		resource = finding.Resource.String()
+	}
+	for key, value := range map[string]string{"category": finding.Category, "resource": resource, "id": finding.ID, "command": command} {
+		if filter := query.Get(key); filter != "" && !strings.EqualFold(filter, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPrompt returns true if the run used the prompt version of the filter
+// (name@version), or if the filter is empty.
+func matchPrompt(filter string, prompts map[string]string) bool {
+	if filter == "" {
+		return true
+	}
+	name, version, _ := strings.Cut(filter, "@")
+	used, ok := prompts[name]
+	return ok && (version == "" || used == version)
+}
+
+// writeArtifactError writes the error response of a failed artifact request.
+func writeArtifactError(w http.ResponseWriter, err error) {
+	if errors.Is(err, artifacts.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// FileName returns the default file name of the report of the entry.
+func FileName(entry *history.Entry, format string) string {
+	return "kube-copilot-" + entry.ID + Extension(format)
+}
+
+// loadEntry loads the entry and writes the error response if it fails.
+func loadEntry(w http.ResponseWriter, store *history.Store, id string) (*history.Entry, bool) {
+	entry, err := store.Load(id)
+	switch {
+	case err == nil:
+		return entry, true
+	case errors.Is(err, history.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rollback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Defaults of the health watch.
+const (
+	DefaultWatch    = 5 * time.Minute
+	DefaultInterval = 10 * time.Second
+)
+
+// Outcomes of a change.
+const (
+	// OutcomeHealthy means the change was kept: the workloads stayed healthy during the watch.
+	OutcomeHealthy = "healthy"
+	// OutcomeRolledBack means the objects were restored to their snapshots.
+	OutcomeRolledBack = "rolled_back"
+	// OutcomeRollbackFailed means some objects could not be restored.
+	OutcomeRollbackFailed = "rollback_failed"
+)
+
+// Cluster is the cluster access of the Applier.
+type Cluster interface {
+	// Get returns the live object, or nil if it doesn't exist.
+	Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	// Apply applies the manifests.
+	Apply(manifests string) error
+	// Restore restores the object to its previous state (deleting it if previous is nil).
+	Restore(obj, previous *unstructured.Unstructured) error
+	// Pods lists the Pods of a workload.
+	Pods(obj *unstructured.Unstructured) ([]string, error)
+	// Health returns the health of a workload, ignoring the failures of ignorePods.
+	Health(obj *unstructured.Unstructured, ignorePods map[string]bool) (*kubernetes.Health, error)
+}
+
+// kubernetesCluster is the Cluster of the kubernetes package.
+type kubernetesCluster struct{}
+
+func (kubernetesCluster) Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return kubernetes.GetObject(obj)
+}
+
+func (kubernetesCluster) Apply(manifests string) error {
+	return kubernetes.ApplyYaml(manifests)
+}
+
+func (kubernetesCluster) Restore(obj, previous *unstructured.Unstructured) error {
+	return kubernetes.RestoreObject(obj, previous)
+}
+
+func (kubernetesCluster) Pods(obj *unstructured.Unstructured) ([]string, error) {
+	return kubernetes.ListWorkloadPods(obj)
+}
+
+func (kubernetesCluster) Health(obj *unstructured.Unstructured, ignorePods map[string]bool) (*kubernetes.Health, error) {
+	return kubernetes.WorkloadHealth(obj, ignorePods)
+}
+
+// Check is a health check of a workload during the watch.
+type Check struct {
+	Time    time.Time `json:"time"`
+	Object  string    `json:"object"`
+	Healthy bool      `json:"healthy"`
+	Detail  string    `json:"detail"`
+}
+
+// Result is the outcome of a change applied with rollback.
+type Result struct {
+	Outcome string `json:"outcome"`
+	// Reason explains the rollback, if any.
+	Reason  string   `json:"reason,omitempty"`
+	Objects []string `json:"objects"`
+	Checks  []Check  `json:"checks,omitempty"`
+}
+
+// Applier applies a change after taking a snapshot of the objects it
+// modifies, watches the health of its workloads and rolls the change back
+// when they degrade. Every step is written to the audit log.
+type Applier struct {
+	Cluster Cluster
+	// Watch is how long the workloads are watched after the change.
+	Watch time.Duration
+	// Interval is the interval between the health checks.
+	Interval time.Duration
+	// Progress, if set, is called with the status messages.
+	Progress func(msg string)
+}
+
+// New creates an Applier for the current cluster.
+func New(watch time.Duration) *Applier {
+	return &Applier{Cluster: kubernetesCluster{}, Watch: watch, Interval: DefaultInterval}
+}
+
+// snapshot is the state of an object before the change.
+type snapshot struct {
+	object   *unstructured.Unstructured
+	previous *unstructured.Unstructured
+	// pods are the Pods of the workload before the change, whose failures are
+	// not caused by the change.
+	pods map[string]bool
+}
+
+// Run applies the manifests with rollback. Cancelling ctx during the watch
+// rolls the change back. An error is returned when the change can't be
+// applied at all; the other failures are reported by the Result outcome.
+func (a *Applier) Run(ctx context.Context, manifests string) (*Result, error) {
+	objects, err := kubernetes.ParseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no object to apply")
+	}
+
+	result := &Result{}
+	snapshots := make([]snapshot, 0, len(objects))
+	for _, obj := range objects {
+		name := objectName(obj)
+		result.Objects = append(result.Objects, name)
+		previous, err := a.Cluster.Get(obj)
+		s := snapshot{object: obj, previous: previous}
+		if err == nil && previous != nil && kubernetes.IsWorkload(obj) {
+			var pods []string
+			pods, err = a.Cluster.Pods(obj)
+			s.pods = toSet(pods)
+		}
+		// The snapshot itself isn't recorded since it may hold Secret data.
+		value := "absent"
+		if previous != nil {
+			value = "resourceVersion " + previous.GetResourceVersion()
+		}
+		record(auditlog.EventSnapshot, name, value, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %v", name, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	a.progress("Applying %d objects", len(objects))
+	err = a.Cluster.Apply(manifests)
+	auditEvent := auditlog.Event{Type: auditlog.EventApply, Input: kubernetes.SummarizeManifests(manifests), Status: auditlog.StatusSucceeded}
+	if err != nil {
+		auditEvent.Status, auditEvent.Error = auditlog.StatusFailed, err.Error()
+	}
+	auditlog.Record(auditEvent)
+	if err != nil {
+		// Some objects may have been applied before the failure.
+		return a.rollback(result, snapshots, fmt.Sprintf("apply failed: %v", err)), nil
+	}
+
+	if reason := a.watch(ctx, result, snapshots); reason != "" {
+		record(auditlog.EventHealthCheck, "", "unhealthy", errors.New(reason))
+		return a.rollback(result, snapshots, reason), nil
+	}
+	record(auditlog.EventHealthCheck, "", "healthy", nil)
+	result.Outcome = OutcomeHealthy
+	return result, nil
+}
+
+// watch checks the health of the workloads until the end of the watch and
+// returns the reason to roll back, or "" if they stayed healthy.
+func (a *Applier) watch(ctx context.Context, result *Result, snapshots []snapshot) string {
+	var workloads []snapshot
+	for _, s := range snapshots {
+		if kubernetes.IsWorkload(s.object) {
+			workloads = append(workloads, s)
+		}
+	}
+	if len(workloads) == 0 {
+		a.progress("No workload to watch")
+		return ""
+	}
+
+	a.progress("Watching the health of %d workloads for %s", len(workloads), a.Watch)
+	deadline := time.Now().Add(a.Watch)
+	var unhealthy string
+	for {
+		unhealthy = ""
+		for _, s := range workloads {
+			name := objectName(s.object)
+			health, err := a.Cluster.Health(s.object, s.pods)
+			if err != nil {
+				// Transient API errors don't roll back unless they last until the end of the watch.
+				health = &kubernetes.Health{Detail: err.Error()}
+			}
+			result.Checks = append(result.Checks, Check{Time: time.Now(), Object: name, Healthy: health.Healthy, Detail: health.Detail})
+			if health.Degraded {
+				return fmt.Sprintf("%s degraded: %s", name, health.Detail)
+			}
+			if !health.Healthy && unhealthy == "" {
+				unhealthy = fmt.Sprintf("%s not healthy after %s: %s", name, a.Watch, health.Detail)
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return unhealthy
+		}
+		wait := a.Interval
+		if wait > remaining {
+			wait = remaining
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "watch interrupted"
+		case <-timer.C:
+		}
+	}
+}
+
+// rollback restores the objects to their snapshots, in reverse order.
+func (a *Applier) rollback(result *Result, snapshots []snapshot, reason string) *Result {
+	a.progress("Rolling back: %s", reason)
+	result.Reason = reason
+	result.Outcome = OutcomeRolledBack
+	var errs []error
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		name := objectName(s.object)
+		err := a.Cluster.Restore(s.object, s.previous)
+		value := "restored"
+		if s.previous == nil {
+			value = "deleted"
+		}
+		record(auditlog.EventRollback, name, value, err)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		result.Outcome = OutcomeRollbackFailed
+		result.Reason = fmt.Sprintf("%s; rollback failed: %v", reason, errors.Join(errs...))
+	}
+	return result
+}
+
+func (a *Applier) progress(format string, args ...interface{}) {
+	if a.Progress != nil {
+		a.Progress(fmt.Sprintf(format, args...))
+	}
+}
+
+// record writes a step of the change to the audit log.
+func record(eventType, key, value string, err error) {
+	event := auditlog.Event{Type: eventType, Key: key, Value: value, Status: auditlog.StatusSucceeded}
+	if err != nil {
+		event.Status, event.Error = auditlog.StatusFailed, err.Error()
+	}
+	auditlog.Record(event)
+}
+
+// objectName returns "<kind>/<namespace>/<name>".
+func objectName(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
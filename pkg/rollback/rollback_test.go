@@ -0,0 +1,158 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rollback
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: shop
+data:
+  mode: fixed
+`
+
+// fakeCluster records the restores and returns the health of the Deployment in order.
+type fakeCluster struct {
+	existing map[string]bool
+	applyErr error
+	health   []kubernetes.Health
+	ignored  map[string]bool
+	restored []string
+}
+
+func (c *fakeCluster) Get(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if !c.existing[obj.GetName()] {
+		return nil, nil
+	}
+	live := obj.DeepCopy()
+	live.SetResourceVersion("42")
+	return live, nil
+}
+
+func (c *fakeCluster) Apply(manifests string) error { return c.applyErr }
+
+func (c *fakeCluster) Restore(obj, previous *unstructured.Unstructured) error {
+	action := "restore "
+	if previous == nil {
+		action = "delete "
+	}
+	c.restored = append(c.restored, action+obj.GetName())
+	return nil
+}
+
+func (c *fakeCluster) Pods(obj *unstructured.Unstructured) ([]string, error) {
+	return []string{"web-old"}, nil
+}
+
+func (c *fakeCluster) Health(obj *unstructured.Unstructured, ignorePods map[string]bool) (*kubernetes.Health, error) {
+	c.ignored = ignorePods
+	health := c.health[0]
+	if len(c.health) > 1 {
+		c.health = c.health[1:]
+	}
+	return &health, nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		applyErr     error
+		health       []kubernetes.Health
+		wantOutcome  string
+		wantRestored []string
+	}{
+		{
+			name:        "healthy",
+			health:      []kubernetes.Health{{Detail: "rolling out"}, {Healthy: true, Detail: "2/2 available"}},
+			wantOutcome: OutcomeHealthy,
+		},
+		{
+			name:         "degraded",
+			health:       []kubernetes.Health{{Detail: "rolling out"}, {Degraded: true, Detail: "Pod web-new: container web is CrashLoopBackOff"}},
+			wantOutcome:  OutcomeRolledBack,
+			wantRestored: []string{"delete web-config", "restore web"},
+		},
+		{
+			name:         "never healthy",
+			health:       []kubernetes.Health{{Detail: "rolling out"}},
+			wantOutcome:  OutcomeRolledBack,
+			wantRestored: []string{"delete web-config", "restore web"},
+		},
+		{
+			name:         "apply failed",
+			applyErr:     errors.New("admission webhook denied the request"),
+			wantOutcome:  OutcomeRolledBack,
+			wantRestored: []string{"delete web-config", "restore web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &fakeCluster{existing: map[string]bool{"web": true}, applyErr: tt.applyErr, health: tt.health}
+			applier := &Applier{Cluster: cluster, Watch: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+			result, err := applier.Run(context.Background(), testManifests)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Outcome != tt.wantOutcome {
+				t.Errorf("Run() outcome = %s (%s), want %s", result.Outcome, result.Reason, tt.wantOutcome)
+			}
+			if !reflect.DeepEqual(cluster.restored, tt.wantRestored) {
+				t.Errorf("restored = %v, want %v", cluster.restored, tt.wantRestored)
+			}
+			if tt.health != nil && !cluster.ignored["web-old"] {
+				t.Errorf("the Pods existing before the change are not ignored: %v", cluster.ignored)
+			}
+		})
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	cluster := &fakeCluster{existing: map[string]bool{"web": true}, health: []kubernetes.Health{{Healthy: true}}}
+	applier := &Applier{Cluster: cluster, Watch: time.Hour, Interval: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	result, err := applier.Run(ctx, testManifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Outcome != OutcomeRolledBack || result.Reason != "watch interrupted" {
+		t.Errorf("Run() = %s (%s), want rolled back after the interruption", result.Outcome, result.Reason)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package runbooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// indexVersion is the version of the index file format.
+const indexVersion = 1
+
+// maxChunkSize is the maximum size (in bytes) of a chunk before it is split
+// at a paragraph boundary.
+const maxChunkSize = 1500
+
+// stopWords are the common words ignored when indexing and searching.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "has": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true, "was": true, "with": true,
+}
+
+// Chunk is an excerpt of a runbook, indexed by its term frequencies.
+type Chunk struct {
+	Source  string         `json:"source"`
+	Heading string         `json:"heading,omitempty"`
+	Text    string         `json:"text"`
+	Terms   map[string]int `json:"terms"`
+}
+
+// Result is a chunk matching a search query.
+type Result struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Index is a local TF-IDF vector store of runbook chunks. It needs no
+// embedding API, so the runbooks never leave the machine while indexing.
+type Index struct {
+	Version int     `json:"version"`
+	Chunks  []Chunk `json:"chunks"`
+}
+
+// DefaultPath returns the default index path (~/.kube-copilot/runbooks.json).
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "runbooks.json"), nil
+}
+
+// Load reads the index at path. A missing file is an empty index.
+func Load(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Index{Version: indexVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if index.Version != indexVersion {
+		return nil, fmt.Errorf("unsupported runbook index version %d in %s, ingest the runbooks again", index.Version, path)
+	}
+	return &index, nil
+}
+
+// Save writes the index to path.
+func (i *Index) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	i.Version = indexVersion
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Add indexes a markdown document, replacing the chunks previously indexed
+// from the same source. It returns the number of chunks added.
+func (i *Index) Add(source, content string) int {
+	i.Remove(source)
+	chunks := splitMarkdown(source, content)
+	i.Chunks = append(i.Chunks, chunks...)
+	return len(chunks)
+}
+
+// Remove removes the chunks of a source and returns true if any were removed.
+func (i *Index) Remove(source string) bool {
+	kept := i.Chunks[:0]
+	for _, c := range i.Chunks {
+		if c.Source != source {
+			kept = append(kept, c)
+		}
+	}
+	removed := len(kept) != len(i.Chunks)
+	i.Chunks = kept
+	return removed
+}
+
+// Sources returns the number of chunks of each indexed source.
+func (i *Index) Sources() map[string]int {
+	sources := map[string]int{}
+	for _, c := range i.Chunks {
+		sources[c.Source]++
+	}
+	return sources
+}
+
+// IngestPath indexes the markdown files (*.md and *.markdown) at path, which
+// may be a file or a directory. It returns the ingested files.
+func (i *Index) IngestPath(path string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(p)); p != path && ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		source, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		i.Add(source, string(content))
+		files = append(files, source)
+		return nil
+	})
+	return files, err
+}
+
+// Search returns the chunks most similar to the query (by the cosine
+// similarity of their TF-IDF vectors), best first.
+func (i *Index) Search(query string, limit int) []Result {
+	queryTerms := termFrequencies(query)
+	if len(queryTerms) == 0 || len(i.Chunks) == 0 {
+		return nil
+	}
+
+	docFreq := map[string]int{}
+	for _, c := range i.Chunks {
+		for t := range c.Terms {
+			docFreq[t]++
+		}
+	}
+	idf := func(t string) float64 {
+		return math.Log(1 + float64(len(i.Chunks))/float64(1+docFreq[t]))
+	}
+
+	queryVector := weights(queryTerms, idf)
+	var results []Result
+	for _, c := range i.Chunks {
+		if score := cosine(queryVector, weights(c.Terms, idf)); score > 0 {
+			results = append(results, Result{Chunk: c, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(a, b int) bool { return results[a].Score > results[b].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// splitMarkdown splits a markdown document into chunks at its headings, and
+// splits the long sections at paragraph boundaries.
+func splitMarkdown(source, content string) []Chunk {
+	var chunks []Chunk
+	heading := ""
+	var section []string
+	flush := func() {
+		for _, text := range splitParagraphs(strings.TrimSpace(strings.Join(section, "\n"))) {
+			terms := termFrequencies(heading + "\n" + text)
+			if len(terms) > 0 {
+				chunks = append(chunks, Chunk{Source: source, Heading: heading, Text: text, Terms: terms})
+			}
+		}
+		section = nil
+	}
+
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			flush()
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+		section = append(section, line)
+	}
+	flush()
+	return chunks
+}
+
+// splitParagraphs splits text into parts of at most maxChunkSize bytes at
+// paragraph boundaries (a single paragraph may still be longer).
+func splitParagraphs(text string) []string {
+	if text == "" {
+		return nil
+	}
+	if len(text) <= maxChunkSize {
+		return []string{text}
+	}
+
+	var parts []string
+	current := ""
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if current != "" && len(current)+len(paragraph)+2 > maxChunkSize {
+			parts = append(parts, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n\n"
+		}
+		current += paragraph
+	}
+	if strings.TrimSpace(current) != "" {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// termFrequencies returns the frequency of each term of text.
+func termFrequencies(text string) map[string]int {
+	terms := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 1 && !stopWords[word] {
+			terms[word]++
+		}
+	}
+	return terms
+}
+
+// weights returns the TF-IDF vector of the term frequencies.
+func weights(terms map[string]int, idf func(string) float64) map[string]float64 {
+	vector := make(map[string]float64, len(terms))
+	for t, n := range terms {
+		vector[t] = (1 + math.Log(float64(n))) * idf(t)
+	}
+	return vector
+}
+
+// cosine returns the cosine similarity of two sparse vectors.
+func cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for t, w := range a {
+		dot += w * b[t]
+		normA += w * w
+	}
+	for _, w := range b {
+		normB += w * w
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package runbooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const oomRunbook = `# OOMKilled Pods
+
+Pods are killed by the kernel when they exceed their memory limit.
+
+## Diagnosis
+
+Check the last termination reason of the container and the memory usage with kubectl top.
+
+## Mitigation
+
+Raise the memory limit of the Deployment or fix the memory leak of the application.
+`
+
+const dnsRunbook = `# DNS failures
+
+## CoreDNS
+
+When lookups time out, restart CoreDNS and check the upstream resolvers.
+`
+
+func TestSearch(t *testing.T) {
+	index := &Index{}
+	index.Add("oom.md", oomRunbook)
+	index.Add("dns.md", dnsRunbook)
+
+	tests := []struct {
+		query       string
+		wantSource  string
+		wantHeading string
+	}{
+		{query: "container OOMKilled memory limit", wantSource: "oom.md"},
+		{query: "how to mitigate a memory leak", wantSource: "oom.md", wantHeading: "Mitigation"},
+		{query: "coredns lookups time out", wantSource: "dns.md", wantHeading: "CoreDNS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results := index.Search(tt.query, 2)
+			if len(results) == 0 {
+				t.Fatalf("Search(%q) returned no result", tt.query)
+			}
+			if results[0].Source != tt.wantSource {
+				t.Errorf("Search(%q)[0].Source = %q, want %q", tt.query, results[0].Source, tt.wantSource)
+			}
+			if tt.wantHeading != "" && results[0].Heading != tt.wantHeading {
+				t.Errorf("Search(%q)[0].Heading = %q, want %q", tt.query, results[0].Heading, tt.wantHeading)
+			}
+		})
+	}
+
+	if results := index.Search("the of and", 5); len(results) != 0 {
+		t.Errorf("Search() with stop words only = %v, want no result", results)
+	}
+}
+
+func TestAddReplacesSource(t *testing.T) {
+	index := &Index{}
+	index.Add("oom.md", oomRunbook)
+	index.Add("oom.md", "# OOMKilled Pods\n\nSee the new procedure.")
+	if got := index.Sources()["oom.md"]; got != 1 {
+		t.Errorf("Sources()[oom.md] = %d, want 1 after re-ingesting", got)
+	}
+	if !index.Remove("oom.md") || len(index.Chunks) != 0 {
+		t.Errorf("Remove() left %d chunks", len(index.Chunks))
+	}
+}
+
+func TestSplitParagraphs(t *testing.T) {
+	paragraph := strings.Repeat("word ", 100)
+	text := strings.Join([]string{paragraph, paragraph, paragraph, paragraph}, "\n\n")
+	parts := splitParagraphs(text)
+	if len(parts) != 2 {
+		t.Fatalf("splitParagraphs() returned %d parts, want 2", len(parts))
+	}
+	for _, part := range parts {
+		if len(part) > maxChunkSize {
+			t.Errorf("part of %d bytes exceeds %d", len(part), maxChunkSize)
+		}
+	}
+}
+
+func TestIngestPathAndSave(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "oom.md"), []byte(oomRunbook), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(dnsRunbook), 0o600)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0o700)
+	os.WriteFile(filepath.Join(dir, ".git", "README.md"), []byte(dnsRunbook), 0o600)
+
+	index := &Index{}
+	files, err := index.IngestPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "oom.md" {
+		t.Fatalf("IngestPath() = %v, want only oom.md", files)
+	}
+
+	path := filepath.Join(dir, "index", "runbooks.json")
+	if err := index.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Chunks) != len(index.Chunks) {
+		t.Errorf("Load() returned %d chunks, want %d", len(loaded.Chunks), len(index.Chunks))
+	}
+
+	empty, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || len(empty.Chunks) != 0 {
+		t.Errorf("Load() of a missing file = %v, %v, want an empty index", empty, err)
+	}
+}
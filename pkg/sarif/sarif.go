@@ -0,0 +1,237 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+// Version and Schema of the SARIF logs.
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// informationURI is the home page of the tool in the SARIF logs.
+const informationURI = "https://github.com/feiskyer/kube-copilot"
+
+// Log is a SARIF log.
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is a run of the tool.
+type Run struct {
+	Tool        Tool         `json:"tool"`
+	Invocations []Invocation `json:"invocations,omitempty"`
+	Results     []Result     `json:"results"`
+}
+
+// Tool describes the tool and its rules.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool component producing the results.
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+// Rule describes a kind of finding.
+type Rule struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name,omitempty"`
+	ShortDescription Message                `json:"shortDescription"`
+	Help             *Message               `json:"help,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Result is a finding.
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+// Message is a plain text message, optionally with a markdown variant.
+type Message struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+// Location is the location of a result: the manifest file and/or the Kubernetes object.
+type Location struct {
+	PhysicalLocation *PhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// PhysicalLocation is a location in a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+// ArtifactLocation is the URI of a file.
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// LogicalLocation is a named location, e.g. a Kubernetes object.
+type LogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind,omitempty"`
+}
+
+// Invocation reports whether the tool ran successfully.
+type Invocation struct {
+	ExecutionSuccessful        bool           `json:"executionSuccessful"`
+	ToolExecutionNotifications []Notification `json:"toolExecutionNotifications,omitempty"`
+}
+
+// Notification is a failure reported by the tool.
+type Notification struct {
+	Level   string  `json:"level"`
+	Message Message `json:"message"`
+}
+
+// Target is an audited or analyzed object with its findings.
+type Target struct {
+	// Name identifies the object, e.g. "pod/default/nginx".
+	Name string
+	// File is the manifest file of the object, if any.
+	File     string
+	Findings []workflows.Finding
+	// Error is the failure of the workflow, if any.
+	Error string
+}
+
+// securitySeverities are the CVSS-like scores used by code scanning dashboards
+// (e.g. GitHub) to rank the findings.
+var securitySeverities = map[string]string{
+	"CRITICAL": "9.5",
+	"HIGH":     "8.0",
+	"MEDIUM":   "5.5",
+	"LOW":      "2.0",
+}
+
+// New creates a SARIF log of the findings of the targets.
+func New(toolName, toolVersion string, targets []Target) *Log {
+	run := Run{
+		Tool: Tool{Driver: Driver{Name: toolName, Version: toolVersion, InformationURI: informationURI}},
+		// Code scanning requires a results array, even if empty.
+		Results: []Result{},
+	}
+
+	invocation := Invocation{ExecutionSuccessful: true}
+	ruleIndexes := map[string]int{}
+	for _, target := range targets {
+		if target.Error != "" {
+			invocation.ExecutionSuccessful = false
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, Notification{
+				Level:   "error",
+				Message: Message{Text: target.Name + ": " + target.Error},
+			})
+		}
+
+		for _, f := range target.Findings {
+			id := f.ID
+			if id == "" {
+				id = RuleID(f.Title)
+			}
+			index, ok := ruleIndexes[id]
+			if !ok {
+				index = len(run.Tool.Driver.Rules)
+				ruleIndexes[id] = index
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, newRule(id, f))
+			}
+
+			text := f.Title
+			if f.Description != "" {
+				text += ": " + f.Description
+			}
+			run.Results = append(run.Results, Result{
+				RuleID:              id,
+				RuleIndex:           index,
+				Level:               Level(f.Severity),
+				Message:             Message{Text: text},
+				Locations:           []Location{location(target)},
+				PartialFingerprints: map[string]string{"findingHash/v1": fingerprint(target.Name, id)},
+			})
+		}
+	}
+	run.Invocations = []Invocation{invocation}
+
+	return &Log{Version: Version, Schema: Schema, Runs: []Run{run}}
+}
+
+// RuleID returns a stable rule ID for a finding title: the CVE ID for
+// vulnerabilities, or a slug of the title otherwise.
+func RuleID(title string) string {
+	return workflows.FindingID(title)
+}
+
+// Level returns the SARIF level of a severity.
+func Level(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func newRule(id string, f workflows.Finding) Rule {
+	rule := Rule{ID: id, Name: f.Title, ShortDescription: Message{Text: f.Title}}
+	if f.Remediation != "" {
+		rule.Help = &Message{Text: f.Remediation, Markdown: f.Remediation}
+	}
+	tags := []string{"security", "kubernetes"}
+	if f.Category != "" {
+		tags = append(tags, f.Category)
+	}
+	properties := map[string]interface{}{"tags": tags}
+	if score, ok := securitySeverities[strings.ToUpper(f.Severity)]; ok {
+		properties["security-severity"] = score
+	}
+	rule.Properties = properties
+	return rule
+}
+
+func location(target Target) Location {
+	loc := Location{LogicalLocations: []LogicalLocation{{FullyQualifiedName: target.Name, Kind: "resource"}}}
+	if target.File != "" && target.File != "-" {
+		loc.PhysicalLocation = &PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: target.File}}
+	}
+	return loc
+}
+
+func fingerprint(target, ruleID string) string {
+	sum := sha256.Sum256([]byte(target + "\x00" + ruleID))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sarif
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+)
+
+func TestRuleID(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Missing memory limit", want: "missing-memory-limit"},
+		{title: "HIGH Severity: cve-2024-10963 in libpam", want: "CVE-2024-10963"},
+		{title: "Critical severity: Privileged container (nginx)", want: "privileged-container-nginx"},
+		{title: "!!!", want: "finding"},
+	}
+	for _, tt := range tests {
+		if got := RuleID(tt.title); got != tt.want {
+			t.Errorf("RuleID(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	limit := workflows.Finding{Title: "Missing memory limit", Severity: "MEDIUM", Description: "No memory limit.", Remediation: "Set resources.limits.memory."}
+	targets := []Target{
+		{Name: "deploy.yaml", File: "deploy.yaml", Findings: []workflows.Finding{limit, {ID: "CVE-2024-10963", Title: "HIGH Severity: CVE-2024-10963 in pam", Severity: "HIGH", Category: workflows.CategoryVulnerability}}},
+		{Name: "pod/default/nginx", Findings: []workflows.Finding{limit}},
+		{Name: "pod/default/broken", Error: "provider authentication failed"},
+	}
+	log := New("kube-copilot", "v0.6.4", targets)
+
+	if log.Version != Version || len(log.Runs) != 1 {
+		t.Fatalf("New() = %+v, want a single run of SARIF %s", log, Version)
+	}
+	run := log.Runs[0]
+	if got := len(run.Tool.Driver.Rules); got != 2 {
+		t.Errorf("got %d rules, want 2 (rules are shared by the findings)", got)
+	}
+	if got := len(run.Results); got != 3 {
+		t.Fatalf("got %d results, want 3", got)
+	}
+
+	first, third := run.Results[0], run.Results[2]
+	if first.Level != "warning" || first.RuleIndex != 0 || first.Locations[0].PhysicalLocation == nil || first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "deploy.yaml" {
+		t.Errorf("unexpected first result %+v", first)
+	}
+	if third.Locations[0].PhysicalLocation != nil || third.Locations[0].LogicalLocations[0].FullyQualifiedName != "pod/default/nginx" {
+		t.Errorf("unexpected location of a live object %+v", third.Locations[0])
+	}
+	if first.PartialFingerprints["findingHash/v1"] == third.PartialFingerprints["findingHash/v1"] {
+		t.Errorf("the same rule on different targets should have different fingerprints")
+	}
+	cve := run.Tool.Driver.Rules[1]
+	if run.Results[1].Level != "error" || cve.ID != "CVE-2024-10963" || cve.Properties["security-severity"] != "8.0" || !reflect.DeepEqual(cve.Properties["tags"], []string{"security", "kubernetes", "vulnerability"}) {
+		t.Errorf("unexpected CVE result %+v and rule %+v", run.Results[1], cve)
+	}
+
+	invocation := run.Invocations[0]
+	if invocation.ExecutionSuccessful || len(invocation.ToolExecutionNotifications) != 1 {
+		t.Errorf("unexpected invocation %+v, want the failure of pod/default/broken", invocation)
+	}
+
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded["$schema"] != Schema {
+		t.Errorf("unexpected SARIF document %s", data)
+	}
+}
+
+func TestNewWithoutFindings(t *testing.T) {
+	data, _ := json.Marshal(New("kube-copilot", "v0.6.4", nil))
+	var decoded struct {
+		Runs []struct {
+			Results []interface{} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Runs[0].Results == nil {
+		t.Errorf("results should be an empty array, got %s", data)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// Schemes are the supported secret reference schemes.
+var Schemes = []string{"env-file", "k8s", "vault", "store"}
+
+// Resolve returns the secret value referenced by ref, which has one of the forms:
+//
+//	env-file://<path>#<key>           a KEY=VALUE line of an environment file
+//	k8s://<namespace>/<name>#<key>    a key of a Kubernetes Secret
+//	vault://<path>#<field>            a field of a HashiCorp Vault secret (KV v1 or v2 API path)
+//	store://<path>#<name>             a secret of the encrypted store (see Store)
+func Resolve(ctx context.Context, ref string) (string, error) {
+	scheme, location, key, err := parseRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	switch scheme {
+	case "env-file":
+		value, err = fromEnvFile(location, key)
+	case "k8s":
+		value, err = fromKubernetes(ctx, location, key)
+	case "vault":
+		value, err = fromVault(ctx, os.Getenv("VAULT_ADDR"), location, key)
+	case "store":
+		value, err = fromStore(ctx, location, key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %v", ref, err)
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return value, nil
+}
+
+// ValidateRef returns an error if ref is not a valid secret reference.
+func ValidateRef(ref string) error {
+	_, _, _, err := parseRef(ref)
+	return err
+}
+
+// parseRef splits ref into its scheme, location and key.
+func parseRef(ref string) (scheme, location, key string, err error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || !contains(Schemes, scheme) {
+		return "", "", "", fmt.Errorf("invalid secret reference %q, should start with one of %s://", ref, strings.Join(Schemes, "://, "))
+	}
+	location, key, _ = strings.Cut(rest, "#")
+	if location == "" || key == "" {
+		return "", "", "", fmt.Errorf("invalid secret reference %q, should be %s://<location>#<key>", ref, scheme)
+	}
+	return scheme, location, key, nil
+}
+
+// fromEnvFile reads key from an environment file with KEY=VALUE lines.
+func fromEnvFile(path, key string) (string, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("key %s not found in %s", key, path)
+}
+
+// fromKubernetes reads key from the Secret at "<namespace>/<name>".
+func fromKubernetes(ctx context.Context, location, key string) (string, error) {
+	namespace, name, ok := strings.Cut(location, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", fmt.Errorf("invalid Secret %q, should be <namespace>/<name>", location)
+	}
+
+	config, err := kubernetes.GetKubeConfig()
+	if err != nil {
+		return "", err
+	}
+	client, err := clientset.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in Secret %s", key, location)
+	}
+	return string(value), nil
+}
+
+// fromVault reads field from the Vault secret at path. The token is read
+// from VAULT_TOKEN or ~/.vault-token, and VAULT_NAMESPACE is honored.
+func fromVault(ctx context.Context, addr, path, field string) (string, error) {
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("VAULT_TOKEN is not set")
+	}
+
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+
+	// KV v2 nests the secret under data.data, while KV v1 returns it under data.
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %v", err)
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("field %s not found in vault secret %s", field, path)
+	}
+	return value, nil
+}
+
+// expandHome replaces the leading "~/" of path with the home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref          string
+		wantScheme   string
+		wantLocation string
+		wantKey      string
+		wantErr      bool
+	}{
+		{ref: "env-file:///etc/kube-copilot.env#OPENAI_API_KEY", wantScheme: "env-file", wantLocation: "/etc/kube-copilot.env", wantKey: "OPENAI_API_KEY"},
+		{ref: "k8s://default/openai#apiKey", wantScheme: "k8s", wantLocation: "default/openai", wantKey: "apiKey"},
+		{ref: "vault://secret/data/kube-copilot#apiKey", wantScheme: "vault", wantLocation: "secret/data/kube-copilot", wantKey: "apiKey"},
+		{ref: "store://~/.kube-copilot/secrets.json#openai", wantScheme: "store", wantLocation: "~/.kube-copilot/secrets.json", wantKey: "openai"},
+		{ref: "k8s://default/openai", wantErr: true},
+		{ref: "sk-plaintext", wantErr: true},
+		{ref: "aws://secret#key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			scheme, location, key, err := parseRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if scheme != tt.wantScheme || location != tt.wantLocation || key != tt.wantKey {
+				t.Errorf("parseRef() = %q, %q, %q, want %q, %q, %q", scheme, location, key, tt.wantScheme, tt.wantLocation, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestFromEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kube-copilot.env")
+	content := "# LLM keys\nexport OPENAI_API_KEY=\"sk-openai\"\nAZURE_OPENAI_API_KEY = 'sk-azure'\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "OPENAI_API_KEY", want: "sk-openai"},
+		{key: "AZURE_OPENAI_API_KEY", want: "sk-azure"},
+		{key: "GOOGLE_API_KEY", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := fromEnvFile(path, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fromEnvFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fromEnvFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/kube-copilot":
+			w.Write([]byte(`{"data":{"data":{"apiKey":"sk-v2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/kube-copilot":
+			w.Write([]byte(`{"data":{"apiKey":"sk-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_TOKEN", "token")
+
+	tests := []struct {
+		path    string
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{path: "secret/data/kube-copilot", field: "apiKey", want: "sk-v2"},
+		{path: "kv/kube-copilot", field: "apiKey", want: "sk-v1"},
+		{path: "kv/kube-copilot", field: "missing", wantErr: true},
+		{path: "kv/unknown", field: "apiKey", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"#"+tt.field, func(t *testing.T) {
+			got, err := fromVault(context.Background(), server.URL, tt.path, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fromVault() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fromVault() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
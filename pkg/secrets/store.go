@@ -0,0 +1,359 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Environment variables of the master keys encrypting the secret store.
+const (
+	// EnvMasterKey is the current master key, 32 bytes encoded in base64 or hex.
+	EnvMasterKey = "KUBE_COPILOT_MASTER_KEY"
+	// EnvPreviousMasterKeys are the comma-separated master keys replaced by a
+	// rotation, so that the secrets encrypted with them can still be read.
+	EnvPreviousMasterKeys = "KUBE_COPILOT_PREVIOUS_MASTER_KEYS"
+)
+
+// MasterKeySecret references the master key (see Resolve) when KUBE_COPILOT_MASTER_KEY
+// is not set, e.g. a Vault secret or a Kubernetes Secret encrypted by a KMS provider.
+var MasterKeySecret string
+
+// storeVersion is the version of the store file format.
+const storeVersion = 1
+
+// masterKeySize is the size of the AES-256 master keys.
+const masterKeySize = 32
+
+// secretNamePattern matches the valid names of the stored secrets.
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store is a file of secrets encrypted at rest with AES-256-GCM. Each secret
+// records the ID of the master key encrypting it, so that the secrets remain
+// readable while they are re-encrypted with a new key by Rotate.
+type Store struct {
+	path    string
+	keys    [][]byte
+	secrets map[string]storeEntry
+}
+
+// storeFile is the on-disk format of the Store.
+type storeFile struct {
+	Version int                   `json:"version"`
+	Secrets map[string]storeEntry `json:"secrets"`
+}
+
+// storeEntry is an encrypted secret.
+type storeEntry struct {
+	KeyID      string    `json:"keyID"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// SecretInfo describes a stored secret without its value.
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	KeyID     string    `json:"keyID"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DefaultStorePath returns the path of the user-level secret store.
+func DefaultStorePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "secrets.json"), nil
+}
+
+// GenerateMasterKey returns a new random master key encoded in base64.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseMasterKey decodes a 32-byte master key encoded in base64 or hex.
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == masterKeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(value); err == nil && len(key) == masterKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid master key, should be %d bytes encoded in base64 or hex", masterKeySize)
+}
+
+// KeyID returns the identifier of a master key recorded with the secrets it encrypts.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// MasterKeys returns the current master key, from KUBE_COPILOT_MASTER_KEY or
+// MasterKeySecret, followed by the previous ones from KUBE_COPILOT_PREVIOUS_MASTER_KEYS.
+func MasterKeys(ctx context.Context) ([][]byte, error) {
+	value := os.Getenv(EnvMasterKey)
+	if value == "" && MasterKeySecret != "" {
+		if strings.HasPrefix(MasterKeySecret, "store://") {
+			return nil, fmt.Errorf("the master key cannot be read from the secret store it encrypts")
+		}
+		var err error
+		if value, err = Resolve(ctx, MasterKeySecret); err != nil {
+			return nil, err
+		}
+	}
+	if value == "" {
+		return nil, fmt.Errorf("no master key for the secret store, set %s or masterKeySecret (generate one with \"kube-copilot secrets generate-key\")", EnvMasterKey)
+	}
+
+	current, err := ParseMasterKey(value)
+	if err != nil {
+		return nil, err
+	}
+	keys := [][]byte{current}
+	for _, value := range strings.Split(os.Getenv(EnvPreviousMasterKeys), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		key, err := ParseMasterKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", EnvPreviousMasterKeys, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// OpenStore opens the secret store at path, which is created on the first
+// write. The first key encrypts the new secrets, while all the keys are
+// tried to decrypt the existing ones.
+func OpenStore(path string, keys ...[]byte) (*Store, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no master key for the secret store")
+	}
+	for _, key := range keys {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("invalid master key, should be %d bytes", masterKeySize)
+		}
+	}
+
+	s := &Store{path: path, keys: keys, secrets: map[string]storeEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secret store %s: %v", path, err)
+	}
+	if file.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported secret store version %d in %s", file.Version, path)
+	}
+	if file.Secrets != nil {
+		s.secrets = file.Secrets
+	}
+	return s, nil
+}
+
+// Get returns the decrypted value of the secret name.
+func (s *Store) Get(name string) (string, error) {
+	entry, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found in %s", name, s.path)
+	}
+	value, err := s.decrypt(name, entry)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// Set encrypts value with the current master key and saves it as the secret name.
+func (s *Store) Set(name, value string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q, should only contain letters, digits, '.', '_' and '-'", name)
+	}
+	entry, err := s.encrypt(name, []byte(value))
+	if err != nil {
+		return err
+	}
+	s.secrets[name] = entry
+	return s.save()
+}
+
+// Delete removes the secret name.
+func (s *Store) Delete(name string) error {
+	if _, ok := s.secrets[name]; !ok {
+		return fmt.Errorf("secret %s not found in %s", name, s.path)
+	}
+	delete(s.secrets, name)
+	return s.save()
+}
+
+// List returns the stored secrets sorted by name.
+func (s *Store) List() []SecretInfo {
+	infos := make([]SecretInfo, 0, len(s.secrets))
+	for name, entry := range s.secrets {
+		infos = append(infos, SecretInfo{Name: name, KeyID: entry.KeyID, UpdatedAt: entry.UpdatedAt})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Rotate re-encrypts the secrets encrypted with a previous master key with
+// the current one and returns the number of re-encrypted secrets. Once it
+// succeeds, the previous keys are no longer needed.
+func (s *Store) Rotate() (int, error) {
+	current := KeyID(s.keys[0])
+	rotated := 0
+	for name, entry := range s.secrets {
+		if entry.KeyID == current {
+			continue
+		}
+		value, err := s.decrypt(name, entry)
+		if err != nil {
+			return 0, err
+		}
+		updated, err := s.encrypt(name, value)
+		if err != nil {
+			return 0, err
+		}
+		// Rotation doesn't change the value.
+		updated.UpdatedAt = entry.UpdatedAt
+		s.secrets[name] = updated
+		rotated++
+	}
+	if rotated == 0 {
+		return 0, nil
+	}
+	return rotated, s.save()
+}
+
+// encrypt seals value with the current master key. The secret name is
+// authenticated, so that the ciphertexts cannot be swapped between secrets.
+func (s *Store) encrypt(name string, value []byte) (storeEntry, error) {
+	gcm, err := newGCM(s.keys[0])
+	if err != nil {
+		return storeEntry{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return storeEntry{}, err
+	}
+	return storeEntry{
+		KeyID:      KeyID(s.keys[0]),
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, value, []byte(name)),
+		UpdatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// decrypt opens the secret with the master key it was encrypted with.
+func (s *Store) decrypt(name string, entry storeEntry) ([]byte, error) {
+	for _, key := range s.keys {
+		if KeyID(key) != entry.KeyID {
+			continue
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := gcm.Open(nil, entry.Nonce, entry.Ciphertext, []byte(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %v", name, err)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("secret %s is encrypted with master key %s, which is neither in %s nor in %s", name, entry.KeyID, EnvMasterKey, EnvPreviousMasterKeys)
+}
+
+// save writes the store atomically with owner-only permissions.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(storeFile{Version: storeVersion, Secrets: s.secrets}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fromStore reads the secret name from the encrypted store at path.
+func fromStore(ctx context.Context, path, name string) (string, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return "", err
+	}
+	keys, err := MasterKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	store, err := OpenStore(path, keys...)
+	if err != nil {
+		return "", err
+	}
+	return store.Get(name)
+}
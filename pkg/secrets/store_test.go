@@ -0,0 +1,179 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	key := make([]byte, masterKeySize)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestParseMasterKey(t *testing.T) {
+	key := testKey(7)
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "base64", value: base64.StdEncoding.EncodeToString(key)},
+		{name: "hex", value: hex.EncodeToString(key) + "\n"},
+		{name: "too short", value: base64.StdEncoding.EncodeToString(key[:16]), wantErr: true},
+		{name: "plaintext", value: "my-master-key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMasterKey(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMasterKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != string(key) {
+				t.Errorf("ParseMasterKey() = %x, want %x", got, key)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	store, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("openai", "sk-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("bad#name", "value"); err == nil {
+		t.Error("Set() with an invalid name succeeded")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret") {
+		t.Errorf("secret stored in plaintext: %s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("store permissions = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	reopened, err := OpenStore(path, testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get("openai"); err != nil || got != "sk-secret" {
+		t.Errorf("Get() = %q, %v, want sk-secret", got, err)
+	}
+	if _, err := reopened.Get("missing"); err == nil {
+		t.Error("Get() of a missing secret succeeded")
+	}
+
+	wrongKey, err := OpenStore(path, testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrongKey.Get("openai"); err == nil {
+		t.Error("Get() with a wrong master key succeeded")
+	}
+
+	if err := reopened.Delete("openai"); err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.List(); len(got) != 0 {
+		t.Errorf("List() after Delete() = %v, want none", got)
+	}
+}
+
+func TestStoreRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	oldKey, newKey := testKey(1), testKey(2)
+	store, err := OpenStore(path, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"openai": "sk-secret", "slack": "https://hooks.slack.com/services/x"} {
+		if err := store.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The new key encrypts, while the old one still decrypts until the rotation.
+	store, err = OpenStore(path, newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("feishu", "https://open.feishu.cn/x"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := store.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 2 {
+		t.Errorf("Rotate() = %d, want 2", rotated)
+	}
+	for _, info := range store.List() {
+		if info.KeyID != KeyID(newKey) {
+			t.Errorf("secret %s encrypted with key %s, want %s", info.Name, info.KeyID, KeyID(newKey))
+		}
+	}
+
+	store, err = OpenStore(path, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Get("slack"); err != nil || got != "https://hooks.slack.com/services/x" {
+		t.Errorf("Get() after rotation = %q, %v", got, err)
+	}
+}
+
+func TestResolveStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	current, previous := testKey(3), testKey(4)
+	store, err := OpenStore(path, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("openai", "sk-stored"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvMasterKey, base64.StdEncoding.EncodeToString(current))
+	if _, err := Resolve(context.Background(), "store://"+path+"#openai"); err == nil {
+		t.Error("Resolve() without the previous master key succeeded")
+	}
+
+	t.Setenv(EnvPreviousMasterKeys, hex.EncodeToString(previous))
+	got, err := Resolve(context.Background(), "store://"+path+"#openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "sk-stored" {
+		t.Errorf("Resolve() = %q, want sk-stored", got)
+	}
+}
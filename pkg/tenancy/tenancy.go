@@ -0,0 +1,234 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tenancy
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"gopkg.in/yaml.v2"
+)
+
+// Tenancy maps the users and roles to the clusters and namespaces they may access,
+// so that app teams can use kube-copilot scoped to their own namespaces:
+//
+//	roles:
+//	  payments-dev: [alice, bob]
+//	tenants:
+//	- name: payments
+//	  roles: [payments-dev]
+//	  clusters: [prod-*, staging]
+//	  namespaces: [payments, payments-*]
+//	- name: platform
+//	  users: [carol]
+type Tenancy struct {
+	// Roles maps the role names to their users.
+	Roles map[string][]string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	// Tenants are the scopes granted to the users and roles.
+	Tenants []Tenant `yaml:"tenants" json:"tenants"`
+}
+
+// Tenant grants access to clusters and namespaces to users and roles.
+type Tenant struct {
+	// Name identifies the tenant in the error messages.
+	Name string `yaml:"name" json:"name"`
+	// Users and Roles are the members of the tenant.
+	Users []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Roles []string `yaml:"roles,omitempty" json:"roles,omitempty"`
+	// Clusters are the kubeconfig contexts (glob patterns, e.g. prod-*). All clusters when empty.
+	Clusters []string `yaml:"clusters,omitempty" json:"clusters,omitempty"`
+	// Namespaces are the namespaces (glob patterns). All namespaces and the
+	// cluster-scoped resources when empty.
+	Namespaces []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+	// ClusterScopedRead allows the namespaced tenants to read the cluster-scoped
+	// resources such as Nodes and StorageClasses.
+	ClusterScopedRead bool `yaml:"clusterScopedRead,omitempty" json:"clusterScopedRead,omitempty"`
+}
+
+// Load reads the tenancy mapping from a YAML file.
+func Load(file string) (*Tenancy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Tenancy
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tenancy %s: %v", file, err)
+	}
+	return &t, nil
+}
+
+// Validate returns an error listing the problems of the mapping.
+func (t *Tenancy) Validate() error {
+	var problems []string
+	if len(t.Tenants) == 0 {
+		problems = append(problems, "no tenant defined")
+	}
+	for i, tenant := range t.Tenants {
+		name := tenant.Name
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("tenant #%d has no name", i+1))
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(tenant.Users) == 0 && len(tenant.Roles) == 0 {
+			problems = append(problems, fmt.Sprintf("tenant %s has no users or roles", name))
+		}
+		for _, role := range tenant.Roles {
+			if _, ok := t.Roles[role]; !ok {
+				problems = append(problems, fmt.Sprintf("tenant %s references undefined role %s", name, role))
+			}
+		}
+		for _, pattern := range append(append([]string{}, tenant.Clusters...), tenant.Namespaces...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("tenant %s has invalid pattern %q", name, pattern))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ScopeFor returns the scope of the tenants the user belongs to, directly or
+// through a role. Users without any tenant have an empty scope denying all accesses.
+func (t *Tenancy) ScopeFor(name string) *Scope {
+	scope := &Scope{User: name}
+	for _, tenant := range t.Tenants {
+		if t.isMember(tenant, name) {
+			scope.Tenants = append(scope.Tenants, tenant)
+		}
+	}
+	return scope
+}
+
+func (t *Tenancy) isMember(tenant Tenant, name string) bool {
+	for _, u := range tenant.Users {
+		if u == name {
+			return true
+		}
+	}
+	for _, role := range tenant.Roles {
+		for _, u := range t.Roles[role] {
+			if u == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Scope is the set of clusters and namespaces accessible to a user.
+type Scope struct {
+	User    string   `json:"user"`
+	Tenants []Tenant `json:"tenants"`
+}
+
+// CheckCluster returns an error if no tenant of the user grants the cluster.
+func (s *Scope) CheckCluster(cluster string) error {
+	for _, tenant := range s.Tenants {
+		if matchAny(tenant.Clusters, cluster) {
+			return nil
+		}
+	}
+	return s.deny("cluster %s", displayName(cluster))
+}
+
+// CheckNamespace returns an error if no tenant of the user grants the
+// namespace of the cluster. An empty namespace stands for all the namespaces.
+func (s *Scope) CheckNamespace(cluster, namespace string) error {
+	for _, tenant := range s.Tenants {
+		if !matchAny(tenant.Clusters, cluster) {
+			continue
+		}
+		if len(tenant.Namespaces) == 0 || (namespace != "" && matchAny(tenant.Namespaces, namespace)) {
+			return nil
+		}
+	}
+	if namespace == "" {
+		return s.deny("all namespaces of cluster %s", displayName(cluster))
+	}
+	return s.deny("namespace %s of cluster %s", namespace, displayName(cluster))
+}
+
+// CheckClusterScoped returns an error if no tenant of the user grants the
+// cluster-scoped resources of the cluster. Only the tenants without namespace
+// restrictions may change them.
+func (s *Scope) CheckClusterScoped(cluster string, write bool) error {
+	for _, tenant := range s.Tenants {
+		if !matchAny(tenant.Clusters, cluster) {
+			continue
+		}
+		if len(tenant.Namespaces) == 0 || (tenant.ClusterScopedRead && !write) {
+			return nil
+		}
+	}
+	return s.deny("cluster-scoped resources of cluster %s", displayName(cluster))
+}
+
+// Namespaced returns true if a tenant of the user restricts the namespaces of
+// the cluster, i.e. tools which can't be scoped to namespaces must be denied.
+func (s *Scope) Namespaced(cluster string) bool {
+	return s.CheckNamespace(cluster, "") != nil
+}
+
+func (s *Scope) deny(format string, args ...interface{}) error {
+	var names []string
+	for _, tenant := range s.Tenants {
+		names = append(names, tenant.Name)
+	}
+	tenants := "no tenant"
+	if len(names) > 0 {
+		tenants = "tenants " + strings.Join(names, ", ")
+	}
+	return fmt.Errorf("%w: %s is not granted to user %s (%s)", errdefs.ErrTenantScope, fmt.Sprintf(format, args...), s.User, tenants)
+}
+
+// matchAny returns true if patterns is empty or value matches one of them.
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func displayName(cluster string) string {
+	if cluster == "" {
+		return "(in-cluster)"
+	}
+	return cluster
+}
+
+// CurrentUser returns the name of the user running the process.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
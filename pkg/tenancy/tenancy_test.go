@@ -0,0 +1,126 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tenancy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+)
+
+const testTenancy = `roles:
+  payments-dev: [alice, bob]
+tenants:
+- name: payments
+  roles: [payments-dev]
+  clusters: [prod-*, staging]
+  namespaces: [payments, payments-*]
+  clusterScopedRead: true
+- name: search
+  users: [bob]
+  clusters: [staging]
+  namespaces: [search]
+- name: platform
+  users: [carol]
+`
+
+func loadTestTenancy(t *testing.T) *Tenancy {
+	path := filepath.Join(t.TempDir(), "tenancy.yaml")
+	if err := os.WriteFile(path, []byte(testTenancy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tenancy, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenancy
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenancy Tenancy
+		wantErr bool
+	}{
+		{name: "valid", tenancy: Tenancy{Tenants: []Tenant{{Name: "a", Users: []string{"alice"}, Namespaces: []string{"a-*"}}}}},
+		{name: "no tenant", tenancy: Tenancy{}, wantErr: true},
+		{name: "no member", tenancy: Tenancy{Tenants: []Tenant{{Name: "a"}}}, wantErr: true},
+		{name: "undefined role", tenancy: Tenancy{Tenants: []Tenant{{Name: "a", Roles: []string{"dev"}}}}, wantErr: true},
+		{name: "invalid pattern", tenancy: Tenancy{Tenants: []Tenant{{Name: "a", Users: []string{"alice"}, Namespaces: []string{"[a"}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tenancy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	tenancy := loadTestTenancy(t)
+	tests := []struct {
+		name      string
+		user      string
+		cluster   string
+		namespace string
+		wantErr   bool
+	}{
+		{name: "role namespace", user: "alice", cluster: "prod-eu", namespace: "payments-api"},
+		{name: "other namespace", user: "alice", cluster: "prod-eu", namespace: "kube-system", wantErr: true},
+		{name: "other cluster", user: "alice", cluster: "dev", namespace: "payments", wantErr: true},
+		{name: "all namespaces", user: "alice", cluster: "prod-eu", namespace: "", wantErr: true},
+		{name: "second tenant", user: "bob", cluster: "staging", namespace: "search"},
+		{name: "tenants are not merged", user: "bob", cluster: "prod-eu", namespace: "search", wantErr: true},
+		{name: "unrestricted", user: "carol", cluster: "anything", namespace: ""},
+		{name: "no tenant", user: "mallory", cluster: "prod-eu", namespace: "payments", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tenancy.ScopeFor(tt.user).CheckNamespace(tt.cluster, tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckNamespace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errdefs.ErrTenantScope) {
+				t.Errorf("CheckNamespace() error = %v, want ErrTenantScope", err)
+			}
+		})
+	}
+}
+
+func TestCheckClusterScoped(t *testing.T) {
+	tenancy := loadTestTenancy(t)
+	tests := []struct {
+		user    string
+		cluster string
+		write   bool
+		wantErr bool
+	}{
+		{user: "alice", cluster: "prod-eu"},
+		{user: "alice", cluster: "prod-eu", write: true, wantErr: true},
+		{user: "bob", cluster: "staging"},
+		{user: "carol", cluster: "prod-eu", write: true},
+		{user: "mallory", cluster: "prod-eu", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tenancy.ScopeFor(tt.user).CheckClusterScoped(tt.cluster, tt.write); (err != nil) != tt.wantErr {
+			t.Errorf("CheckClusterScoped(%s, %s, %v) error = %v, wantErr %v", tt.user, tt.cluster, tt.write, err, tt.wantErr)
+		}
+	}
+}
@@ -25,7 +25,11 @@ import (
 )
 
 // GoogleSearch returns the results of a Google search for the given query.
-func GoogleSearch(query string) (string, error) {
+func GoogleSearch(query string) (result string, err error) {
+	defer func() { recordExecution("search", query, err) }()
+	if err = authorize("search", query); err != nil {
+		return "", err
+	}
 	svc, err := customsearch.NewService(context.Background(), option.WithAPIKey(os.Getenv("GOOGLE_API_KEY")))
 	if err != nil {
 		return "", err
@@ -0,0 +1,73 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+)
+
+// readOnlyHelmCommands are the helm commands that never change cluster state.
+var readOnlyHelmCommands = map[string]bool{
+	"list":     true,
+	"ls":       true,
+	"status":   true,
+	"get":      true,
+	"history":  true,
+	"show":     true,
+	"search":   true,
+	"template": true,
+	"version":  true,
+	"env":      true,
+	"lint":     true,
+}
+
+// isReadOnlyHelm returns true if the helm command never changes cluster state.
+func isReadOnlyHelm(command string) bool {
+	args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(command), "helm "))
+	return len(args) > 0 && readOnlyHelmCommands[args[0]]
+}
+
+// Helm runs the given helm command and returns the output.
+func Helm(command string) (result string, err error) {
+	command = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(command), "helm "))
+	defer func() { recordExecution("helm", command, err) }()
+	if err = authorize("helm", command); err != nil {
+		return "", err
+	}
+
+	if ReadOnly && !isReadOnlyHelm(command) {
+		return "", fmt.Errorf("command %q is not allowed in read-only mode", "helm "+command)
+	}
+
+	args := strings.Fields(command)
+
+	if kubernetes.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", kubernetes.Kubeconfig)
+	}
+	if kubernetes.Context != "" {
+		args = append(args, "--kube-context", kubernetes.Context)
+	}
+	output, err := exec.Command("helm", args...).CombinedOutput()
+	if err != nil {
+		return strings.TrimSpace(string(output)), err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
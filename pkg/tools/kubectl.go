@@ -16,22 +16,115 @@ limitations under the License.
 package tools
 
 import (
+	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
 )
 
+// ReadOnly rejects the tool calls that may change the cluster (see IsMutatingTool).
+var ReadOnly bool
+
 // Kubectl runs the given kubectl command and returns the output.
-func Kubectl(command string) (string, error) {
-	if strings.HasPrefix(command, "kubectl") {
-		command = strings.TrimSpace(strings.TrimPrefix(command, "kubectl"))
+func Kubectl(command string) (result string, err error) {
+	// The checks below parse the same arguments as the ones kubectl runs.
+	fields := kubectlFields(command)
+	command = strings.Join(fields, " ")
+	defer func() { recordExecution("kubectl", command, err) }()
+	if err = authorize("kubectl", command); err != nil {
+		return "", err
+	}
+	if ReadOnly && !IsReadOnlyKubectl(command) {
+		return "", fmt.Errorf("command %q is not allowed in read-only mode", "kubectl "+command)
 	}
 
-	cmd := exec.Command("kubectl", strings.Split(command, " ")...)
+	args := append(kubernetes.KubectlArgs(), fields...)
+	cmd := exec.Command("kubectl", args...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if isUnreachableOutput(string(output)) {
+			err = fmt.Errorf("%w: %v", errdefs.ErrClusterUnreachable, err)
+		}
 		return strings.TrimSpace(string(output)), err
 	}
 
 	return strings.TrimSpace(string(output)), nil
 }
+
+// kubectlFields returns the arguments of a kubectl command, split on any
+// whitespace (e.g. tabs or newlines), without the kubectl prefix.
+func kubectlFields(command string) []string {
+	fields := strings.Fields(command)
+	if len(fields) > 0 && fields[0] == "kubectl" {
+		fields = fields[1:]
+	}
+	return fields
+}
+
+// unreachableMessages are the kubectl errors when the API server can't be reached.
+var unreachableMessages = []string{
+	"Unable to connect to the server",
+	"The connection to the server",
+	"connection refused",
+	"no such host",
+	"i/o timeout",
+}
+
+// isUnreachableOutput returns true if the kubectl output reports that the API server can't be reached.
+func isUnreachableOutput(output string) bool {
+	for _, message := range unreachableMessages {
+		if strings.Contains(output, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyKubectlCommands are the kubectl subcommands that never change cluster state.
+var readOnlyKubectlCommands = map[string]bool{
+	"get":           true,
+	"describe":      true,
+	"logs":          true,
+	"top":           true,
+	"explain":       true,
+	"events":        true,
+	"diff":          true,
+	"api-resources": true,
+	"api-versions":  true,
+	"cluster-info":  true,
+	"version":       true,
+}
+
+// readOnlyKubectlSubcommands are the read-only subcommands of kubectl commands
+// that may also change cluster state (e.g. "rollout status" vs "rollout undo").
+var readOnlyKubectlSubcommands = map[string]map[string]bool{
+	"rollout": {"status": true, "history": true},
+	"auth":    {"can-i": true, "whoami": true},
+	"config":  {"view": true, "get-contexts": true, "current-context": true, "get-clusters": true},
+}
+
+// IsReadOnlyKubectl returns true if the kubectl command only reads cluster state.
+// Unknown commands, and commands which can't be parsed unambiguously, are
+// considered to be mutating.
+func IsReadOnlyKubectl(command string) bool {
+	cmd, err := kubernetes.ParseKubectl(command)
+	if err != nil {
+		return false
+	}
+	args, ok := cmd.Command(1)
+	if !ok {
+		return false
+	}
+
+	if readOnlyKubectlCommands[args[0]] {
+		return true
+	}
+	if subcommands, ok := readOnlyKubectlSubcommands[args[0]]; ok {
+		args, ok := cmd.Command(2)
+		return ok && subcommands[args[1]]
+	}
+	return false
+}
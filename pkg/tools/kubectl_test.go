@@ -0,0 +1,98 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsReadOnlyKubectl(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{command: "kubectl get pods -n default", want: true},
+		{command: "get pods", want: true},
+		{command: "kubectl -n kube-system logs coredns --previous", want: true},
+		{command: "kubectl rollout status deployment/nginx", want: true},
+		{command: "kubectl rollout restart deployment/nginx", want: false},
+		{command: "kubectl delete pod nginx", want: false},
+		{command: "kubectl scale deployment nginx --replicas=3", want: false},
+		{command: "kubectl apply -f nginx.yaml", want: false},
+		{command: "kubectl", want: false},
+		{command: "kubectl --cache-dir get delete pod x", want: false},
+		{command: "kubectl --unknown get delete pod x", want: false},
+		{command: "kubectl --context=dev -nkube-system get pods", want: true},
+		{command: "kubectl get pods --show-labels --unknown", want: true},
+		{command: "kubectl rollout --unknown status undo deployment/nginx", want: false},
+		{command: "kubectl logs -f nginx", want: true},
+		{command: "kubectl -n", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := IsReadOnlyKubectl(tt.command); got != tt.want {
+				t.Errorf("IsReadOnlyKubectl(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubectlFields(t *testing.T) {
+	tests := []struct {
+		command      string
+		want         []string
+		wantReadOnly bool
+	}{
+		{command: "kubectl get pods", want: []string{"get", "pods"}, wantReadOnly: true},
+		{command: "get  pods", want: []string{"get", "pods"}, wantReadOnly: true},
+		{command: "--cache-dir=\tget delete pod foo", want: []string{"--cache-dir=", "get", "delete", "pod", "foo"}, wantReadOnly: true},
+		{command: "--cache-dir\tget\ndelete pod foo", want: []string{"--cache-dir", "get", "delete", "pod", "foo"}, wantReadOnly: false},
+		{command: "kubectl\tdelete\npod foo", want: []string{"delete", "pod", "foo"}, wantReadOnly: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := kubectlFields(tt.command)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("kubectlFields(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+			// Kubectl checks the arguments it runs.
+			if readOnly := IsReadOnlyKubectl(strings.Join(got, " ")); readOnly != tt.wantReadOnly {
+				t.Errorf("IsReadOnlyKubectl(%q) = %v, want %v", got, readOnly, tt.wantReadOnly)
+			}
+		})
+	}
+}
+
+func TestIsUnreachableOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{output: "The connection to the server localhost:8080 was refused - did you specify the right host or port?", want: true},
+		{output: "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout", want: true},
+		{output: "E0301 memcache.go:265] couldn't get current server API group list: Get \"https://127.0.0.1:6443/api\": dial tcp 127.0.0.1:6443: connect: connection refused", want: true},
+		{output: "Error from server (NotFound): pods \"nginx\" not found", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := isUnreachableOutput(tt.output); got != tt.want {
+				t.Errorf("isUnreachableOutput(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// describeTimeout is the timeout of the describe request of a plugin.
+	describeTimeout = 5 * time.Second
+	// invokeTimeout is the timeout of the invoke request of a plugin.
+	invokeTimeout = 5 * time.Minute
+)
+
+// Plugin actions sent to the plugin executables.
+const (
+	PluginActionDescribe = "describe"
+	PluginActionInvoke   = "invoke"
+)
+
+// pluginNamePattern matches the valid plugin names.
+var pluginNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// PluginRequest is the JSON request written to the stdin of a plugin executable.
+type PluginRequest struct {
+	Action string `json:"action"`
+	Input  string `json:"input,omitempty"`
+}
+
+// PluginResponse is the JSON response read from the stdout of a plugin executable.
+// Describe responses set Name, Description, Input and ReadOnly; invoke
+// responses set Output or Error.
+type PluginResponse struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Input       string `json:"input,omitempty"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+	Output      string `json:"output,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Plugin is an external executable registered as an agent tool.
+type Plugin struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Input       string `json:"input,omitempty"`
+	ReadOnly    bool   `json:"readOnly"`
+	Path        string `json:"path"`
+}
+
+// Plugins are the plugins registered in CopilotTools.
+var Plugins []Plugin
+
+// DefaultPluginsDir returns the default directory of the plugin executables.
+func DefaultPluginsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube-copilot", "plugins"), nil
+}
+
+// LoadPlugins describes the executables of dir. A missing directory yields
+// no plugins; executables failing to describe themselves are reported in the
+// error and skipped.
+func LoadPlugins(dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var plugins []Plugin
+	var errs []error
+	names := map[string]string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		plugin, err := describePlugin(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := CopilotTools[plugin.Name]; ok && !isPlugin(plugin.Name) {
+			errs = append(errs, fmt.Errorf("plugin %s: name %q conflicts with a built-in tool", path, plugin.Name))
+			continue
+		}
+		if other, ok := names[plugin.Name]; ok {
+			errs = append(errs, fmt.Errorf("plugin %s: name %q is already used by %s", path, plugin.Name, other))
+			continue
+		}
+		names[plugin.Name] = path
+		plugins = append(plugins, plugin)
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, errors.Join(errs...)
+}
+
+// RegisterPlugins registers the plugins as tools, replacing the plugins registered before.
+func RegisterPlugins(plugins []Plugin) {
+	for _, plugin := range Plugins {
+		delete(CopilotTools, plugin.Name)
+	}
+	for _, plugin := range plugins {
+		CopilotTools[plugin.Name] = plugin.Invoke
+	}
+	Plugins = plugins
+}
+
+// Invoke runs the plugin with the input and returns its output.
+func (p Plugin) Invoke(input string) (result string, err error) {
+	defer func() { recordExecution(p.Name, input, err) }()
+	if err = authorize(p.Name, input); err != nil {
+		return "", err
+	}
+	if ReadOnly && !p.ReadOnly {
+		return "", fmt.Errorf("plugin %s is not allowed in read-only mode", p.Name)
+	}
+
+	resp, err := runPlugin(p.Path, PluginRequest{Action: PluginActionInvoke, Input: input}, invokeTimeout)
+	if err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return resp.Output, errors.New(resp.Error)
+	}
+	return resp.Output, nil
+}
+
+// describePlugin sends the describe request to the executable at path.
+func describePlugin(path string) (Plugin, error) {
+	resp, err := runPlugin(path, PluginRequest{Action: PluginActionDescribe}, describeTimeout)
+	if err != nil {
+		return Plugin{}, err
+	}
+	if !pluginNamePattern.MatchString(resp.Name) {
+		return Plugin{}, fmt.Errorf("plugin %s: invalid name %q, should match %s", path, resp.Name, pluginNamePattern)
+	}
+	if strings.TrimSpace(resp.Description) == "" {
+		return Plugin{}, fmt.Errorf("plugin %s: missing description", path)
+	}
+	return Plugin{
+		Name:        resp.Name,
+		Description: strings.TrimSpace(resp.Description),
+		Input:       strings.TrimSpace(resp.Input),
+		ReadOnly:    resp.ReadOnly,
+		Path:        path,
+	}, nil
+}
+
+// runPlugin writes the request to the stdin of the executable and parses the response of its stdout.
+func runPlugin(path string, req PluginRequest, timeout time.Duration) (*PluginResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin %s: %s timed out after %s", path, req.Action, timeout)
+		}
+		return nil, fmt.Errorf("plugin %s: %s failed: %v: %s", path, req.Action, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid %s response: %v", path, req.Action, err)
+	}
+	return &resp, nil
+}
+
+// IsReadOnlyPlugin returns true if name is a registered plugin declared read-only.
+func IsReadOnlyPlugin(name string) bool {
+	plugin, ok := findPlugin(name)
+	return ok && plugin.ReadOnly
+}
+
+// IsMutatingTool returns true if the tool call may change the cluster: a
+// kubectl or helm command that is not read-only, a python script, or a
+// plugin not declared read-only.
+func IsMutatingTool(name, input string) bool {
+	switch name {
+	case "kubectl":
+		return !IsReadOnlyKubectl(input)
+	case "helm":
+		return !isReadOnlyHelm(input)
+	case "python":
+		return true
+	}
+	plugin, ok := findPlugin(name)
+	return ok && !plugin.ReadOnly
+}
+
+// isPlugin returns true if name is a registered plugin.
+func isPlugin(name string) bool {
+	_, ok := findPlugin(name)
+	return ok
+}
+
+func findPlugin(name string) (Plugin, bool) {
+	for _, plugin := range Plugins {
+		if plugin.Name == name {
+			return plugin, true
+		}
+	}
+	return Plugin{}, false
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writePlugin writes an executable shell script to dir.
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts in this test")
+	}
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "oncall", `read request
+case "$request" in
+  *describe*) echo '{"name": "oncall", "description": "Show the on-call engineer of a team.", "input": "a team name", "readOnly": true}' ;;
+  *payments*) echo '{"output": "alice"}' ;;
+  *) echo '{"error": "unknown team"}' ;;
+esac
+`)
+	writePlugin(t, dir, "restart", `echo '{"name": "restart", "description": "Restart a deployment."}'`)
+	writePlugin(t, dir, "broken", `echo 'not json'`)
+	writePlugin(t, dir, "shadow", `echo '{"name": "kubectl", "description": "Shadows kubectl."}'`)
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := LoadPlugins(dir)
+	if err == nil || !strings.Contains(err.Error(), "invalid describe response") || !strings.Contains(err.Error(), "conflicts with a built-in tool") {
+		t.Errorf("LoadPlugins() error = %v, want the broken and shadowing plugins reported", err)
+	}
+	if len(plugins) != 2 || plugins[0].Name != "oncall" || plugins[1].Name != "restart" {
+		t.Fatalf("LoadPlugins() = %+v, want oncall and restart", plugins)
+	}
+
+	RegisterPlugins(plugins)
+	defer RegisterPlugins(nil)
+	oncall, ok := CopilotTools["oncall"]
+	if !ok {
+		t.Fatalf("plugin oncall is not registered")
+	}
+	if got, err := oncall("payments"); err != nil || got != "alice" {
+		t.Errorf("oncall(payments) = %q, %v, want alice", got, err)
+	}
+	if _, err := oncall("search"); err == nil || err.Error() != "unknown team" {
+		t.Errorf("oncall(search) error = %v, want the plugin error", err)
+	}
+
+	if !IsReadOnlyPlugin("oncall") || IsReadOnlyPlugin("restart") {
+		t.Errorf("IsReadOnlyPlugin() does not follow the readOnly of the plugins")
+	}
+	if IsMutatingTool("oncall", "payments") || !IsMutatingTool("restart", "web") {
+		t.Errorf("IsMutatingTool() does not follow the readOnly of the plugins")
+	}
+
+	ReadOnly = true
+	defer func() { ReadOnly = false }()
+	if _, err := CopilotTools["restart"]("web"); err == nil {
+		t.Errorf("restart should be rejected in read-only mode")
+	}
+
+	RegisterPlugins(nil)
+	if _, ok := CopilotTools["oncall"]; ok {
+		t.Errorf("RegisterPlugins(nil) did not unregister oncall")
+	}
+	if _, ok := CopilotTools["kubectl"]; !ok {
+		t.Errorf("RegisterPlugins(nil) unregistered a built-in tool")
+	}
+}
+
+func TestLoadPluginsMissingDir(t *testing.T) {
+	plugins, err := LoadPlugins(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(plugins) != 0 {
+		t.Errorf("LoadPlugins() = %v, %v, want no plugins", plugins, err)
+	}
+}
+
+func TestIsMutatingTool(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "kubectl", input: "get pods", want: false},
+		{name: "kubectl", input: "delete pod nginx", want: true},
+		{name: "helm", input: "list -A", want: false},
+		{name: "helm", input: "helm status nginx", want: false},
+		{name: "helm", input: "uninstall nginx", want: true},
+		{name: "helm", input: "", want: true},
+		{name: "python", input: "print(1)", want: true},
+		{name: "trivy", input: "nginx", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsMutatingTool(tt.name, tt.input); got != tt.want {
+			t.Errorf("IsMutatingTool(%q, %q) = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
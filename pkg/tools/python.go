@@ -16,13 +16,35 @@ limitations under the License.
 package tools
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
 )
 
 // PythonREPL runs the given Python script and returns the output.
-func PythonREPL(script string) (string, error) {
+func PythonREPL(script string) (result string, err error) {
+	defer func() { recordExecution("python", script, err) }()
+	if err = authorize("python", script); err != nil {
+		return "", err
+	}
+	if ReadOnly {
+		// The scripts may change the cluster, e.g. with the Kubernetes client.
+		return "", fmt.Errorf("python is not allowed in read-only mode")
+	}
 	cmd := exec.Command("python3", "-c", script)
+	// The scripts follow KUBECONFIG, which must select the --context the
+	// checks above were made for.
+	kubeconfig, remove, err := kubernetes.WriteContextKubeconfig()
+	if err != nil {
+		return "", err
+	}
+	defer remove()
+	if kubeconfig != "" {
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfig)
+	}
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -56,3 +56,11 @@ func TestPythonREPL(t *testing.T) {
 		})
 	}
 }
+
+func TestPythonREPLReadOnly(t *testing.T) {
+	ReadOnly = true
+	defer func() { ReadOnly = false }()
+	if _, err := PythonREPL("print('hello world')"); err == nil {
+		t.Errorf("PythonREPL() should be rejected in read-only mode")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/runbooks"
+)
+
+// maxRunbookResults is the number of runbook excerpts returned by a search.
+const maxRunbookResults = 3
+
+// RunbooksPath is the path of the runbook index. Defaults to runbooks.DefaultPath().
+var RunbooksPath string
+
+// RunbooksAvailable returns true if runbooks have been ingested.
+func RunbooksAvailable() bool {
+	path, err := runbooksPath()
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() > 0
+}
+
+// Runbooks searches the ingested runbooks and returns the most relevant excerpts.
+func Runbooks(query string) (result string, err error) {
+	query = strings.TrimSpace(query)
+	defer func() { recordExecution("runbooks", query, err) }()
+	if err = authorize("runbooks", query); err != nil {
+		return "", err
+	}
+
+	path, err := runbooksPath()
+	if err != nil {
+		return "", err
+	}
+	index, err := runbooks.Load(path)
+	if err != nil {
+		return "", err
+	}
+
+	results := index.Search(query, maxRunbookResults)
+	if len(results) == 0 {
+		return "No relevant runbook found.", nil
+	}
+	var sb strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&sb, "## %d. %s", i+1, r.Source)
+		if r.Heading != "" {
+			fmt.Fprintf(&sb, " (%s)", r.Heading)
+		}
+		fmt.Fprintf(&sb, "\n\n%s\n\n", r.Text)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func runbooksPath() (string, error) {
+	if RunbooksPath != "" {
+		return RunbooksPath, nil
+	}
+	return runbooks.DefaultPath()
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tenancy"
+)
+
+// Tenancy, if set, restricts the clusters and namespaces accessible to the tools.
+var Tenancy *tenancy.Scope
+
+// tenancyDeniedFlags are the kubectl flags which would escape the tenant scope
+// by switching the credentials or the API server.
+var tenancyDeniedFlags = map[string]bool{
+	"--kubeconfig": true,
+	"--cluster":    true,
+	"--user":       true,
+	"--server":     true,
+	"--token":      true,
+	"--as":         true,
+	"--as-group":   true,
+	"--as-uid":     true,
+}
+
+// clusterScopedResources are the built-in cluster-scoped resources, by their
+// plural, singular and short names.
+var clusterScopedResources = map[string]bool{
+	"nodes": true, "node": true, "no": true,
+	"namespaces": true, "namespace": true, "ns": true,
+	"persistentvolumes": true, "persistentvolume": true, "pv": true,
+	"storageclasses": true, "storageclass": true, "sc": true,
+	"clusterroles": true, "clusterrole": true,
+	"clusterrolebindings": true, "clusterrolebinding": true,
+	"customresourcedefinitions": true, "customresourcedefinition": true, "crd": true, "crds": true,
+	"priorityclasses": true, "priorityclass": true, "pc": true,
+	"ingressclasses": true, "ingressclass": true,
+	"runtimeclasses": true, "runtimeclass": true,
+	"validatingwebhookconfigurations": true, "validatingwebhookconfiguration": true,
+	"mutatingwebhookconfigurations": true, "mutatingwebhookconfiguration": true,
+	"apiservices": true, "apiservice": true,
+	"certificatesigningrequests": true, "certificatesigningrequest": true, "csr": true,
+	"csidrivers": true, "csidriver": true, "csinodes": true, "csinode": true,
+	"volumeattachments": true, "volumeattachment": true,
+}
+
+// clusterInfoKubectlCommands are the kubectl commands reading no resource.
+var clusterInfoKubectlCommands = map[string]bool{
+	"version":       true,
+	"api-resources": true,
+	"api-versions":  true,
+	"explain":       true,
+}
+
+// clusterInfoRawPaths are the API paths reading no resource.
+var clusterInfoRawPaths = map[string]bool{
+	"/version": true,
+	"/api":     true,
+	"/apis":    true,
+	"/healthz": true,
+	"/livez":   true,
+	"/readyz":  true,
+}
+
+// checkTenancy checks that the tool execution stays in the clusters and namespaces of Tenancy.
+func checkTenancy(tool, input string) error {
+	if Tenancy == nil || offlineTools[tool] {
+		// The offline tools never access the cluster.
+		return nil
+	}
+	if tool == "kubectl" {
+		return checkKubectlTenancy(input)
+	}
+
+	cluster := kubernetes.CurrentContext()
+	if err := Tenancy.CheckCluster(cluster); err != nil {
+		return err
+	}
+	if Tenancy.Namespaced(cluster) {
+		return fmt.Errorf("%w: the %s tool can't be restricted to the namespaces of user %s", errdefs.ErrTenantScope, tool, Tenancy.User)
+	}
+	return nil
+}
+
+// checkKubectlTenancy checks the cluster, namespace and resources of a kubectl command.
+func checkKubectlTenancy(command string) error {
+	cmd, err := kubernetes.ParseKubectl(command)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errdefs.ErrTenantScope, err)
+	}
+	if _, ok := cmd.Command(min(len(cmd.Args), 2)); !ok {
+		// A flag of unknown arity may hide the command or its resources.
+		return fmt.Errorf("%w: ambiguous kubectl command %q is not allowed for user %s", errdefs.ErrTenantScope, command, Tenancy.User)
+	}
+	for name := range cmd.Flags {
+		if tenancyDeniedFlags[name] {
+			return fmt.Errorf("%w: kubectl %s is not allowed for user %s", errdefs.ErrTenantScope, name, Tenancy.User)
+		}
+	}
+	cluster := cmd.Flags["--context"]
+	if cluster == "" {
+		cluster = kubernetes.CurrentContext()
+	}
+	namespace := cmd.Namespace()
+	allNamespaces := cmd.AllNamespaces()
+	_, fromFiles := cmd.Flags["--filename"]
+	if _, ok := cmd.Flags["--kustomize"]; ok {
+		fromFiles = true
+	}
+	args := cmd.Args
+
+	if err := Tenancy.CheckCluster(cluster); err != nil {
+		return err
+	}
+	if len(args) == 0 || clusterInfoKubectlCommands[args[0]] {
+		return nil
+	}
+	if args[0] == "config" || args[0] == "cluster-info" {
+		// The kubeconfig and the cluster services are not scoped to namespaces.
+		return Tenancy.CheckClusterScoped(cluster, args[0] == "config")
+	}
+	if args[0] == "proxy" {
+		// The proxy gives access to the whole API server.
+		return Tenancy.CheckClusterScoped(cluster, true)
+	}
+
+	write := !IsReadOnlyKubectl(command)
+	if path, ok := cmd.Flags["--raw"]; ok {
+		if namespace, ok := rawPathNamespace(path); ok {
+			return Tenancy.CheckNamespace(cluster, namespace)
+		}
+		if !write && clusterInfoRawPaths[path] {
+			return nil
+		}
+		// Other paths may list the resources of all the namespaces.
+		if err := Tenancy.CheckClusterScoped(cluster, write); err != nil {
+			return err
+		}
+		return Tenancy.CheckNamespace(cluster, "")
+	}
+	if write && fromFiles && Tenancy.Namespaced(cluster) {
+		// The manifests may set any namespace or cluster-scoped resource.
+		return fmt.Errorf("%w: kubectl %s with manifest files is not allowed for user %s", errdefs.ErrTenantScope, args[0], Tenancy.User)
+	}
+	if len(args) > 1 {
+		resources, names := splitKubectlResources(args[1:])
+		namespaced := false
+		for _, resource := range resources {
+			if !clusterScopedResources[resource] {
+				namespaced = true
+				continue
+			}
+			if resource == "namespaces" || resource == "namespace" || resource == "ns" {
+				// Reading the user's own namespaces is allowed.
+				if !write && len(names) > 0 && checkNamespaces(cluster, names) == nil {
+					continue
+				}
+			}
+			if err := Tenancy.CheckClusterScoped(cluster, write); err != nil {
+				return err
+			}
+		}
+		if !namespaced {
+			// kubectl ignores the namespace of the cluster-scoped resources.
+			return nil
+		}
+	}
+
+	if allNamespaces {
+		return Tenancy.CheckNamespace(cluster, "")
+	}
+	if namespace == "" {
+		namespace = kubernetes.CurrentNamespace()
+	}
+	return Tenancy.CheckNamespace(cluster, namespace)
+}
+
+// rawPathPattern matches the API paths of the namespaced resources, e.g.
+// /api/v1/namespaces/default/pods or /apis/apps/v1/namespaces/default/deployments.
+var rawPathPattern = regexp.MustCompile(`^/(?:api/v1|apis/[^/]+/[^/]+)/namespaces/([^/]+)(?:/|$)`)
+
+// rawPathNamespace returns the namespace of a kubectl --raw path, or false if
+// the path is not within a single namespace.
+func rawPathNamespace(rawPath string) (string, bool) {
+	rawPath, _, _ = strings.Cut(rawPath, "?")
+	if path.Clean(rawPath) != rawPath || strings.Contains(rawPath, "%") {
+		// Escaped or relative segments may leave the namespace.
+		return "", false
+	}
+	match := rawPathPattern.FindStringSubmatch(rawPath)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// splitKubectlResources returns the resource types (without API group) and
+// the names of the kubectl arguments after the verb, e.g. "pods,nodes" or
+// "node/node-1" or "storageclasses.storage.k8s.io standard".
+func splitKubectlResources(args []string) (resources, names []string) {
+	for _, typ := range strings.Split(args[0], ",") {
+		typ, name, hasName := strings.Cut(typ, "/")
+		typ, _, _ = strings.Cut(strings.ToLower(typ), ".")
+		resources = append(resources, typ)
+		if hasName {
+			names = append(names, name)
+		}
+	}
+	if !strings.Contains(args[0], "/") {
+		names = append(names, args[1:]...)
+	}
+	return resources, names
+}
+
+// checkNamespaces checks the access to each of the namespaces.
+func checkNamespaces(cluster string, namespaces []string) error {
+	for _, namespace := range namespaces {
+		if err := Tenancy.CheckNamespace(cluster, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
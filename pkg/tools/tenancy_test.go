@@ -0,0 +1,83 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tenancy"
+)
+
+func TestCheckTenancy(t *testing.T) {
+	defer func(context string) { kubernetes.Context = context }(kubernetes.Context)
+	defer func() { Tenancy = nil }()
+	kubernetes.Context = "prod"
+	Tenancy = &tenancy.Scope{User: "alice", Tenants: []tenancy.Tenant{
+		{Name: "payments", Clusters: []string{"prod"}, Namespaces: []string{"payments"}, ClusterScopedRead: true},
+	}}
+
+	tests := []struct {
+		tool    string
+		input   string
+		wantErr bool
+	}{
+		{tool: "kubectl", input: "get pods -n payments"},
+		{tool: "kubectl", input: "get -o wide pods --namespace=payments"},
+		{tool: "kubectl", input: "get pods -n kube-system", wantErr: true},
+		{tool: "kubectl", input: "get pods -A", wantErr: true},
+		{tool: "kubectl", input: "get pods -n payments --context staging", wantErr: true},
+		{tool: "kubectl", input: "get pods -n payments --as admin", wantErr: true},
+		{tool: "kubectl", input: "get -o wide nodes"},
+		{tool: "kubectl", input: "get nodes,pods -n kube-system", wantErr: true},
+		{tool: "kubectl", input: "cordon node/node-1 -n payments", wantErr: true},
+		{tool: "kubectl", input: "get namespace payments"},
+		{tool: "kubectl", input: "delete namespace payments", wantErr: true},
+		{tool: "kubectl", input: "apply -f deploy.yaml -n payments", wantErr: true},
+		{tool: "kubectl", input: "config view --raw", wantErr: true},
+		{tool: "kubectl", input: "api-resources"},
+		{tool: "kubectl", input: "get pods -nkube-system", wantErr: true},
+		{tool: "kubectl", input: "get pods -npayments"},
+		{tool: "kubectl", input: "get pods --namespace=kube-system", wantErr: true},
+		{tool: "kubectl", input: "get pods -n", wantErr: true},
+		{tool: "kubectl", input: "--unknown kube-system get pods -n payments", wantErr: true},
+		{tool: "kubectl", input: "-skube.example.com get pods -n payments", wantErr: true},
+		{tool: "kubectl", input: "get --raw /api/v1/namespaces/payments/pods"},
+		{tool: "kubectl", input: "get --raw=/apis/apps/v1/namespaces/payments/deployments?limit=1"},
+		{tool: "kubectl", input: "get --raw /api/v1/namespaces/kube-system/secrets", wantErr: true},
+		{tool: "kubectl", input: "get --raw /api/v1/namespaces/payments/../kube-system/secrets", wantErr: true},
+		{tool: "kubectl", input: "get --raw /api/v1/namespaces/payments%2F..%2Fkube-system/secrets", wantErr: true},
+		{tool: "kubectl", input: "get --raw /api/v1/nodes", wantErr: true},
+		{tool: "kubectl", input: "get --raw /version"},
+		{tool: "kubectl", input: "get --raw /api/v1/secrets", wantErr: true},
+		{tool: "kubectl", input: "proxy --port 8001", wantErr: true},
+		{tool: "python", input: "print(1)", wantErr: true},
+		{tool: "trivy", input: "nginx:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool+" "+tt.input, func(t *testing.T) {
+			err := checkTenancy(tt.tool, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkTenancy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errdefs.ErrTenantScope) {
+				t.Errorf("checkTenancy() error = %v, want ErrTenantScope", err)
+			}
+		})
+	}
+}
@@ -15,13 +15,80 @@ limitations under the License.
 */
 package tools
 
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/auditlog"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+)
+
 // Tool is a function that takes an input and returns an output.
 type Tool func(input string) (string, error)
 
 // CopilotTools is a map of tool names to tools.
 var CopilotTools = map[string]Tool{
-	"search":  GoogleSearch,
-	"python":  PythonREPL,
-	"trivy":   Trivy,
-	"kubectl": Kubectl,
+	"search":   GoogleSearch,
+	"python":   PythonREPL,
+	"trivy":    Trivy,
+	"kubectl":  Kubectl,
+	"runbooks": Runbooks,
+}
+
+// Authorize, if set, is called before every tool execution with the tool
+// name and input; the execution is rejected when it returns an error.
+var Authorize func(tool, input string) error
+
+// Offline disables the tools that may access the cluster (kubectl, helm,
+// python and the plugins), so that the agent only answers from its inputs.
+var Offline bool
+
+// offlineTools are the tools available in offline mode.
+var offlineTools = map[string]bool{
+	"search":   true,
+	"trivy":    true,
+	"runbooks": true,
+}
+
+// AirGapped disables the tools that need the internet (search), for the
+// environments without egress.
+var AirGapped bool
+
+// internetTools are the tools disabled in air-gapped mode.
+var internetTools = map[string]bool{
+	"search": true,
+}
+
+// Available returns false if the tool is disabled by the offline or air-gapped mode.
+func Available(tool string) bool {
+	if AirGapped && internetTools[tool] {
+		return false
+	}
+	return !Offline || offlineTools[tool]
+}
+
+// authorize checks the tool execution with the offline and air-gapped modes, Tenancy and Authorize.
+func authorize(tool, input string) error {
+	if AirGapped && internetTools[tool] {
+		return fmt.Errorf("%w: the %s tool is disabled", errdefs.ErrAirGapped, tool)
+	}
+	if !Available(tool) {
+		return fmt.Errorf("%w: the %s tool is disabled", errdefs.ErrOffline, tool)
+	}
+	if err := checkTenancy(tool, input); err != nil {
+		return err
+	}
+	if Authorize == nil {
+		return nil
+	}
+	return Authorize(tool, input)
+}
+
+// recordExecution writes the tool execution to the audit log.
+func recordExecution(tool, input string, err error) {
+	event := auditlog.Event{Type: auditlog.EventToolExecution, Tool: tool, Input: input, Status: auditlog.StatusSucceeded}
+	if err != nil {
+		event.Status = auditlog.StatusFailed
+		event.Error = err.Error()
+	}
+	auditlog.Record(event)
 }
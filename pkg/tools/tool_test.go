@@ -0,0 +1,56 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+)
+
+func TestOffline(t *testing.T) {
+	Offline = true
+	defer func() { Offline = false }()
+
+	for name, tool := range map[string]Tool{"kubectl": Kubectl, "helm": Helm, "python": PythonREPL} {
+		if _, err := tool("get pods"); !errors.Is(err, errdefs.ErrOffline) {
+			t.Errorf("%s error = %v, want ErrOffline", name, err)
+		}
+	}
+	for _, name := range []string{"search", "trivy", "runbooks"} {
+		if !Available(name) {
+			t.Errorf("Available(%s) = false, want true in offline mode", name)
+		}
+	}
+	if Available("oncall") {
+		t.Errorf("Available(oncall) = true, want plugins disabled in offline mode")
+	}
+}
+
+func TestAirGapped(t *testing.T) {
+	AirGapped = true
+	defer func() { AirGapped = false }()
+
+	if _, err := GoogleSearch("kubernetes"); !errors.Is(err, errdefs.ErrAirGapped) {
+		t.Errorf("GoogleSearch() error = %v, want ErrAirGapped", err)
+	}
+	for _, name := range []string{"kubectl", "trivy", "python"} {
+		if !Available(name) {
+			t.Errorf("Available(%s) = false, want true in air-gapped mode", name)
+		}
+	}
+}
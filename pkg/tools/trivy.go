@@ -16,22 +16,263 @@ limitations under the License.
 package tools
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
-// Trivy runs trivy against the image and returns the output
-func Trivy(image string) (string, error) {
+var (
+	// TrivyCacheDir is the cache directory of trivy holding the vulnerability
+	// database, e.g. a copy bundled for air-gapped environments.
+	TrivyCacheDir string
+	// TrivySkipUpdate scans with the existing vulnerability database without
+	// downloading its updates. It is implied by AirGapped.
+	TrivySkipUpdate bool
+	// TrivySeverity is the minimum severity of the vulnerabilities handed to
+	// the model; the less severe ones are only counted.
+	TrivySeverity = "HIGH"
+	// TrivyCacheTTL is how long the scan results of an image digest are reused
+	// instead of scanning the image again. Zero disables the scan cache.
+	TrivyCacheTTL = 6 * time.Hour
+)
+
+// TrivySeverities are the severities of trivy, from the least to the most severe.
+var TrivySeverities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// Limits keeping the vulnerability summary within the token budget.
+const (
+	maxTrivyVulnerabilities = 50
+	maxTrivyTitle           = 120
+)
+
+// Vulnerability is a vulnerability found by trivy in a package of the image.
+type Vulnerability struct {
+	ID               string `json:"id"`
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installedVersion"`
+	FixedVersion     string `json:"fixedVersion,omitempty"`
+	Severity         string `json:"severity"`
+	Title            string `json:"title,omitempty"`
+}
+
+// trivyReport is the part of the JSON report of trivy listing the vulnerabilities.
+type trivyReport struct {
+	Metadata struct {
+		RepoDigests []string `json:"RepoDigests"`
+	} `json:"Metadata"`
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+			Title            string `json:"Title"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// Trivy scans the image with trivy and returns the summary of its
+// vulnerabilities: the counts by severity and the details of the ones at or
+// above TrivySeverity, instead of the full report. The results are cached
+// by image digest for TrivyCacheTTL.
+func Trivy(image string) (result string, err error) {
 	image = strings.TrimSpace(image)
 	if strings.HasPrefix(image, "image ") {
 		image = strings.TrimPrefix(image, "image ")
 	}
-	cmd := exec.Command("trivy", "image", image, "--scanners", "vuln")
+	defer func() { recordExecution("trivy", image, err) }()
+	if err = authorize("trivy", image); err != nil {
+		return "", err
+	}
+	if vulnerabilities, ok := trivyCache.lookup(image, TrivyCacheTTL, time.Now()); ok {
+		return FormatVulnerabilities(image, vulnerabilities, TrivySeverity), nil
+	}
+	cmd := exec.Command("trivy", trivyArgs(image)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return strings.TrimSpace(stderr.String() + string(output)), err
+	}
 
-	output, err := cmd.CombinedOutput()
+	vulnerabilities, repoDigests, err := parseTrivyReport(output)
 	if err != nil {
-		return strings.TrimSpace(string(output)), err
+		// Unknown report format: hand it over as is.
+		return strings.TrimSpace(string(output)), nil
+	}
+	if TrivyCacheTTL > 0 {
+		trivyCache.store(image, repoDigests, vulnerabilities, time.Now())
+	}
+	return FormatVulnerabilities(image, vulnerabilities, TrivySeverity), nil
+}
+
+// trivyArgs returns the arguments of trivy to scan the image.
+func trivyArgs(image string) []string {
+	args := []string{"image", image, "--scanners", "vuln", "--format", "json", "--quiet"}
+	if TrivyCacheDir != "" {
+		args = append(args, "--cache-dir", TrivyCacheDir)
+	}
+	if TrivySkipUpdate || AirGapped {
+		args = append(args, "--skip-db-update", "--skip-java-db-update", "--offline-scan")
+	}
+	return args
+}
+
+// ParseTrivyReport parses the JSON report of trivy into the vulnerabilities
+// of the image, most severe first. A vulnerability of a package found in
+// several targets (e.g. layers) is only listed once.
+func ParseTrivyReport(data []byte) ([]Vulnerability, error) {
+	vulnerabilities, _, err := parseTrivyReport(data)
+	return vulnerabilities, err
+}
+
+// parseTrivyReport parses the JSON report of trivy into the vulnerabilities
+// and the repository digests of the image.
+func parseTrivyReport(data []byte) ([]Vulnerability, []string, error) {
+	var report trivyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, nil, fmt.Errorf("invalid trivy report: %v", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	seen := map[string]bool{}
+	var vulnerabilities []Vulnerability
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			key := v.VulnerabilityID + "/" + v.PkgName + "/" + v.InstalledVersion
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			severity := strings.ToUpper(v.Severity)
+			if severityRank(severity) < 0 {
+				severity = "UNKNOWN"
+			}
+			vulnerabilities = append(vulnerabilities, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         severity,
+				Title:            v.Title,
+			})
+		}
+	}
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		ri, rj := severityRank(vulnerabilities[i].Severity), severityRank(vulnerabilities[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		return vulnerabilities[i].ID < vulnerabilities[j].ID
+	})
+	return vulnerabilities, report.Metadata.RepoDigests, nil
+}
+
+// FormatVulnerabilities summarizes the vulnerabilities of the image for the
+// model: the counts by severity, followed by one line per vulnerability at or
+// above minSeverity.
+func FormatVulnerabilities(image string, vulnerabilities []Vulnerability, minSeverity string) string {
+	if len(vulnerabilities) == 0 {
+		return fmt.Sprintf("Image %s: no vulnerabilities found.", image)
+	}
+
+	counts := map[string]int{}
+	var selected []Vulnerability
+	minRank := severityRank(minSeverity)
+	for _, v := range vulnerabilities {
+		counts[v.Severity]++
+		if severityRank(v.Severity) >= minRank {
+			selected = append(selected, v)
+		}
+	}
+	var parts []string
+	for i := len(TrivySeverities) - 1; i >= 0; i-- {
+		if n := counts[TrivySeverities[i]]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", TrivySeverities[i], n))
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Image %s: %d vulnerabilities (%s).\n", image, len(vulnerabilities), strings.Join(parts, ", "))
+	if len(selected) == 0 {
+		fmt.Fprintf(&sb, "None of them has a severity of %s or above.\n", strings.ToUpper(minSeverity))
+		return strings.TrimSpace(sb.String())
+	}
+	fmt.Fprintf(&sb, "Vulnerabilities with a severity of %s or above:\n", strings.ToUpper(minSeverity))
+	for i, v := range selected {
+		if i == maxTrivyVulnerabilities {
+			fmt.Fprintf(&sb, "(%d more omitted)\n", len(selected)-maxTrivyVulnerabilities)
+			break
+		}
+		fix := "no fix available"
+		if v.FixedVersion != "" {
+			fix = "fixed in " + v.FixedVersion
+		}
+		fmt.Fprintf(&sb, "- %s %s: %s %s (%s)", v.Severity, v.ID, v.Package, v.InstalledVersion, fix)
+		if title := strings.Join(strings.Fields(v.Title), " "); title != "" {
+			if len(title) > maxTrivyTitle {
+				title = title[:maxTrivyTitle-3] + "..."
+			}
+			sb.WriteString(": " + title)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ValidTrivySeverity returns true if s is one of the severities of trivy (case-insensitive).
+func ValidTrivySeverity(s string) bool {
+	return severityRank(s) >= 0
+}
+
+// severityRank returns the index of the severity in TrivySeverities, or -1 if unknown.
+func severityRank(severity string) int {
+	for i, s := range TrivySeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// TrivyDBInfo is the metadata of the vulnerability database of trivy.
+type TrivyDBInfo struct {
+	Path       string    `json:"-"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+	NextUpdate time.Time `json:"NextUpdate"`
+}
+
+// TrivyDB returns the metadata of the vulnerability database in TrivyCacheDir,
+// or in the default cache directory of trivy.
+func TrivyDB() (*TrivyDBInfo, error) {
+	cacheDir := TrivyCacheDir
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = filepath.Join(userCacheDir, "trivy")
+	}
+
+	path := filepath.Join(cacheDir, "db", "metadata.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no vulnerability database in %s", filepath.Dir(path))
+		}
+		return nil, err
+	}
+	info := &TrivyDBInfo{Path: filepath.Dir(path)}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability database metadata %s: %v", path, err)
+	}
+	return info, nil
 }
@@ -0,0 +1,161 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrivyArgs(t *testing.T) {
+	defer func() { TrivyCacheDir, TrivySkipUpdate, AirGapped = "", false, false }()
+
+	if got, want := trivyArgs("nginx"), []string{"image", "nginx", "--scanners", "vuln", "--format", "json", "--quiet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trivyArgs() = %v, want %v", got, want)
+	}
+
+	TrivyCacheDir, AirGapped = "/opt/trivy", true
+	want := []string{"image", "nginx", "--scanners", "vuln", "--format", "json", "--quiet", "--cache-dir", "/opt/trivy", "--skip-db-update", "--skip-java-db-update", "--offline-scan"}
+	if got := trivyArgs("nginx"); !reflect.DeepEqual(got, want) {
+		t.Errorf("trivyArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestTrivyDB(t *testing.T) {
+	defer func() { TrivyCacheDir = "" }()
+	TrivyCacheDir = t.TempDir()
+
+	if _, err := TrivyDB(); err == nil {
+		t.Error("TrivyDB() = nil error, want a missing database error")
+	}
+
+	os.MkdirAll(filepath.Join(TrivyCacheDir, "db"), 0o755)
+	os.WriteFile(filepath.Join(TrivyCacheDir, "db", "metadata.json"), []byte(`{"Version":2,"NextUpdate":"2025-03-02T00:00:00Z","UpdatedAt":"2025-03-01T00:00:00Z"}`), 0o644)
+	info, err := TrivyDB()
+	if err != nil {
+		t.Fatalf("TrivyDB() error = %v", err)
+	}
+	if !info.UpdatedAt.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) || info.Path != filepath.Join(TrivyCacheDir, "db") {
+		t.Errorf("TrivyDB() = %+v", info)
+	}
+}
+
+const trivyReportJSON = `{
+  "SchemaVersion": 2,
+  "ArtifactName": "nginx:1.19",
+  "Results": [
+    {
+      "Target": "nginx:1.19 (debian 10.10)",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2022-0001", "PkgName": "libc6", "InstalledVersion": "2.28-10", "Severity": "MEDIUM", "Title": "glibc: buffer overflow"},
+        {"VulnerabilityID": "CVE-2022-1292", "PkgName": "openssl", "InstalledVersion": "1.1.1d-0", "FixedVersion": "1.1.1n-0", "Severity": "CRITICAL", "Title": "openssl: c_rehash script allows command injection"},
+        {"VulnerabilityID": "CVE-2021-3999", "PkgName": "libc6", "InstalledVersion": "2.28-10", "Severity": "HIGH", "Title": "glibc: off-by-one in getcwd"},
+        {"VulnerabilityID": "CVE-2019-0002", "PkgName": "bash", "InstalledVersion": "5.0-4", "Severity": "LOW"},
+        {"VulnerabilityID": "TEMP-0841856", "PkgName": "bash", "InstalledVersion": "5.0-4", "Severity": ""}
+      ]
+    },
+    {
+      "Target": "usr/lib/libssl.so",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2022-1292", "PkgName": "openssl", "InstalledVersion": "1.1.1d-0", "FixedVersion": "1.1.1n-0", "Severity": "CRITICAL"}
+      ]
+    },
+    {"Target": "app/go.sum"}
+  ]
+}`
+
+func TestParseTrivyReport(t *testing.T) {
+	got, err := ParseTrivyReport([]byte(trivyReportJSON))
+	if err != nil {
+		t.Fatalf("ParseTrivyReport() error = %v", err)
+	}
+	var ids []string
+	for _, v := range got {
+		ids = append(ids, v.Severity+" "+v.ID)
+	}
+	want := []string{"CRITICAL CVE-2022-1292", "HIGH CVE-2021-3999", "MEDIUM CVE-2022-0001", "LOW CVE-2019-0002", "UNKNOWN TEMP-0841856"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ParseTrivyReport() = %v, want %v (deduplicated, most severe first)", ids, want)
+	}
+	if got[0].Package != "openssl" || got[0].FixedVersion != "1.1.1n-0" {
+		t.Errorf("ParseTrivyReport()[0] = %+v", got[0])
+	}
+
+	if _, err := ParseTrivyReport([]byte("nginx:1.19 (debian 10.10)\nTotal: 5")); err == nil {
+		t.Errorf("ParseTrivyReport() of a table should fail")
+	}
+}
+
+func TestFormatVulnerabilities(t *testing.T) {
+	vulnerabilities, err := ParseTrivyReport([]byte(trivyReportJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		severity    string
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name:     "high",
+			severity: "HIGH",
+			want: []string{
+				"Image nginx:1.19: 5 vulnerabilities (CRITICAL 1, HIGH 1, MEDIUM 1, LOW 1, UNKNOWN 1).",
+				"- CRITICAL CVE-2022-1292: openssl 1.1.1d-0 (fixed in 1.1.1n-0): openssl: c_rehash script allows command injection",
+				"- HIGH CVE-2021-3999: libc6 2.28-10 (no fix available): glibc: off-by-one in getcwd",
+			},
+			wantMissing: []string{"CVE-2022-0001", "CVE-2019-0002"},
+		},
+		{
+			name:     "low",
+			severity: "low",
+			want:     []string{"severity of LOW or above", "- LOW CVE-2019-0002: bash 5.0-4 (no fix available)"},
+		},
+		{
+			name:     "critical",
+			severity: "CRITICAL",
+			want:     []string{"CVE-2022-1292"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatVulnerabilities("nginx:1.19", vulnerabilities, tt.severity)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("FormatVulnerabilities() = %s\nwant it to contain %q", got, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got, missing) {
+					t.Errorf("FormatVulnerabilities() = %s\nwant it without %q", got, missing)
+				}
+			}
+		})
+	}
+
+	if got := FormatVulnerabilities("nginx:1.27", nil, "HIGH"); got != "Image nginx:1.27: no vulnerabilities found." {
+		t.Errorf("FormatVulnerabilities() = %q for a clean image", got)
+	}
+	if got := FormatVulnerabilities("nginx:1.19", vulnerabilities[2:], "HIGH"); !strings.Contains(got, "None of them has a severity of HIGH or above.") {
+		t.Errorf("FormatVulnerabilities() = %q, want no details below the severity", got)
+	}
+}
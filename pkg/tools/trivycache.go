@@ -0,0 +1,126 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// trivyScan is a cached scan result of an image digest.
+type trivyScan struct {
+	vulnerabilities []Vulnerability
+	scannedAt       time.Time
+}
+
+// trivyDigest is the digest an image reference was resolved to by a scan.
+type trivyDigest struct {
+	digest     string
+	resolvedAt time.Time
+}
+
+// trivyScanCache caches the scan results by image digest, so that the images
+// shared by many pods (e.g. the same base image in a namespace) are scanned
+// once. The image references without a digest are mapped to the digest
+// reported by their last scan, which expires with the result.
+type trivyScanCache struct {
+	lock    sync.Mutex
+	scans   map[string]trivyScan
+	digests map[string]trivyDigest
+	hits    int
+	misses  int
+}
+
+// trivyCache is the scan cache shared by all the trivy executions of the process.
+var trivyCache = newTrivyScanCache()
+
+func newTrivyScanCache() *trivyScanCache {
+	return &trivyScanCache{
+		scans:   map[string]trivyScan{},
+		digests: map[string]trivyDigest{},
+	}
+}
+
+// lookup returns the cached vulnerabilities of the image if it was scanned within ttl.
+func (c *trivyScanCache) lookup(image string, ttl time.Duration, now time.Time) ([]Vulnerability, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	digest := imageDigest(image)
+	if digest == "" {
+		if d, ok := c.digests[image]; ok && now.Sub(d.resolvedAt) < ttl {
+			digest = d.digest
+		}
+	}
+	if scan, ok := c.scans[digest]; ok && digest != "" && now.Sub(scan.scannedAt) < ttl {
+		c.hits++
+		return scan.vulnerabilities, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// store caches the vulnerabilities of the image under its digests: the one of
+// the reference and the repository digests reported by trivy.
+func (c *trivyScanCache) store(image string, repoDigests []string, vulnerabilities []Vulnerability, now time.Time) {
+	var digests []string
+	if digest := imageDigest(image); digest != "" {
+		digests = append(digests, digest)
+	}
+	for _, ref := range repoDigests {
+		if digest := imageDigest(ref); digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+	if len(digests) == 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, digest := range digests {
+		c.scans[digest] = trivyScan{vulnerabilities: vulnerabilities, scannedAt: now}
+	}
+	if imageDigest(image) == "" {
+		c.digests[image] = trivyDigest{digest: digests[0], resolvedAt: now}
+	}
+}
+
+// stats returns the number of cache hits and misses.
+func (c *trivyScanCache) stats() (hits, misses int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.hits, c.misses
+}
+
+// TrivyCacheStats returns the number of image scans served from the scan
+// cache and the number of scans run by trivy while the cache was enabled.
+func TrivyCacheStats() (hits, misses int) {
+	return trivyCache.stats()
+}
+
+// imageDigest returns the digest of an image reference pinned by digest
+// (e.g. "sha256:..." of "nginx@sha256:..."), or an empty string.
+func imageDigest(image string) string {
+	if _, digest, ok := strings.Cut(image, "@"); ok && strings.Contains(digest, ":") {
+		return digest
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrivyScanCache(t *testing.T) {
+	now := time.Date(2024, 11, 1, 10, 0, 0, 0, time.UTC)
+	vulnerabilities := []Vulnerability{{ID: "CVE-2022-1292", Package: "openssl", Severity: "CRITICAL"}}
+	_, repoDigests, err := parseTrivyReport([]byte(`{"Metadata": {"RepoDigests": ["nginx@sha256:abc"]}, "Results": []}`))
+	if err != nil {
+		t.Fatalf("parseTrivyReport() error = %v", err)
+	}
+
+	cache := newTrivyScanCache()
+	cache.store("nginx:1.25", repoDigests, vulnerabilities, now)
+	cache.store("redis:7", nil, vulnerabilities, now)
+
+	tests := []struct {
+		name  string
+		image string
+		ttl   time.Duration
+		age   time.Duration
+		want  bool
+	}{
+		{name: "same tag", image: "nginx:1.25", ttl: time.Hour, want: true},
+		{name: "same digest", image: "docker.io/library/nginx@sha256:abc", ttl: time.Hour, want: true},
+		{name: "other digest", image: "nginx@sha256:def", ttl: time.Hour},
+		{name: "expired", image: "nginx:1.25", ttl: time.Hour, age: 2 * time.Hour},
+		{name: "disabled", image: "nginx:1.25"},
+		{name: "unknown digest", image: "redis:7", ttl: time.Hour},
+		{name: "not scanned", image: "busybox", ttl: time.Hour},
+	}
+	hits := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cache.lookup(tt.image, tt.ttl, now.Add(tt.age))
+			if ok != tt.want {
+				t.Fatalf("lookup(%q) = %v, want %v", tt.image, ok, tt.want)
+			}
+			if ok {
+				hits++
+				if len(got) != 1 || got[0].ID != "CVE-2022-1292" {
+					t.Errorf("lookup(%q) = %+v", tt.image, got)
+				}
+			}
+		})
+	}
+
+	// The lookups with the cache disabled are not counted.
+	if gotHits, gotMisses := cache.stats(); gotHits != hits || gotMisses != len(tests)-1-hits {
+		t.Errorf("stats() = %d, %d, want %d, %d", gotHits, gotMisses, hits, len(tests)-1-hits)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ReflowTables converts the markdown tables wider than width into lists, so
+// that they stay readable in narrow terminals. Each row becomes an item titled
+// with its first cell in bold, with one "<header>: <cell>" sub-item per other cell.
+func ReflowTables(md string, width int) string {
+	lines := strings.Split(md, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if !isTableRow(lines[i]) || i+1 >= len(lines) || !isTableSeparator(lines[i+1]) {
+			out = append(out, lines[i])
+			continue
+		}
+
+		end := i + 2
+		for end < len(lines) && isTableRow(lines[end]) {
+			end++
+		}
+		table := lines[i:end]
+		if tableWidth(table) <= width {
+			out = append(out, table...)
+		} else {
+			out = append(out, tableToList(table)...)
+		}
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// tableToList converts the table lines (header, separator and rows) to a list.
+func tableToList(table []string) []string {
+	headers := tableCells(table[0])
+	var out []string
+	for _, row := range table[2:] {
+		cells := tableCells(row)
+		if len(cells) == 0 {
+			continue
+		}
+		out = append(out, "- **"+cells[0]+"**")
+		for j, cell := range cells[1:] {
+			if cell == "" {
+				continue
+			}
+			header := ""
+			if j+1 < len(headers) {
+				header = headers[j+1] + ": "
+			}
+			out = append(out, "  - "+header+cell)
+		}
+	}
+	return out
+}
+
+// tableWidth returns the width of the table once its columns are aligned.
+func tableWidth(table []string) int {
+	var widths []int
+	for _, line := range table {
+		if isTableSeparator(line) {
+			continue
+		}
+		for j, cell := range tableCells(line) {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	// Each column is surrounded by "| " and " ".
+	total := 1
+	for _, w := range widths {
+		total += w + 3
+	}
+	return total
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+func isTableSeparator(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "|") && strings.Trim(line, "|-: ") == "" && strings.Contains(line, "-")
+}
+
+// tableCells returns the trimmed cells of a table row.
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import "testing"
+
+func TestReflowTables(t *testing.T) {
+	table := `## Findings
+
+| Pod | Severity | Issue |
+|-----|:--------:|-------|
+| nginx | HIGH | Running as root |
+| redis | LOW | |
+
+Done.`
+	tests := []struct {
+		name  string
+		width int
+		want  string
+	}{
+		{
+			name:  "fits in the terminal",
+			width: 80,
+			want:  table,
+		},
+		{
+			name:  "narrow terminal",
+			width: 30,
+			want: `## Findings
+
+- **nginx**
+  - Severity: HIGH
+  - Issue: Running as root
+- **redis**
+  - Severity: LOW
+
+Done.`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReflowTables(table, tt.width); got != tt.want {
+				t.Errorf("ReflowTables() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReflowTablesIgnoresPipes(t *testing.T) {
+	md := "Run `kubectl get pods | grep nginx` to check it."
+	if got := ReflowTables(md, 10); got != md {
+		t.Errorf("ReflowTables() = %q, want %q", got, md)
+	}
+}
@@ -16,17 +16,50 @@ limitations under the License.
 package utils
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/glamour"
+	"github.com/fatih/color"
 	"golang.org/x/term"
 )
 
-// RenderMarkdown renders markdown to the terminal.
+// noColor disables colors in the rendered markdown.
+var noColor bool
+
+// Themes are the supported markdown themes. "auto" picks dark or light from
+// the terminal background, and "plain" renders without colors.
+var Themes = []string{"auto", "dark", "light", "plain"}
+
+// Theme is the markdown theme used by RenderMarkdown (defaults to auto).
+var Theme string
+
+// DisableColor disables colored output, including the rendered markdown.
+func DisableColor() {
+	noColor = true
+	color.NoColor = true
+}
+
+// RenderMarkdown renders markdown to the terminal, wrapped to its width.
+// Tables wider than the terminal are rendered as lists.
 func RenderMarkdown(md string) error {
-	width, _, _ := term.GetSize(0)
+	width := TerminalWidth()
+	md = ReflowTables(md, width)
+
+	var style glamour.TermRendererOption
+	switch {
+	case noColor || color.NoColor || Theme == "plain":
+		style = glamour.WithStandardStyle("notty")
+	case Theme == "dark" || Theme == "light":
+		style = glamour.WithStandardStyle(Theme)
+	default:
+		style = glamour.WithAutoStyle()
+	}
 	styler, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
+		style,
 		glamour.WithWordWrap(width),
 	)
 	if err != nil {
@@ -43,3 +76,46 @@ func RenderMarkdown(md string) error {
 	fmt.Println(out)
 	return nil
 }
+
+// TerminalWidth returns the width of the terminal attached to stdout. The
+// COLUMNS environment variable is used when stdout is not a terminal, and 80 by default.
+func TerminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+// stdin is shared by the prompts so that buffered input is not lost between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+// Ask prints the prompt to stderr (so that stdout stays parseable) and
+// returns the trimmed line typed by the user.
+func Ask(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// Confirm asks the user a yes/no question on the terminal and returns true
+// only if the answer is "y" or "yes".
+func Confirm(prompt string) bool {
+	answer := strings.ToLower(Ask(prompt + " (y/n) "))
+	return answer == "y" || answer == "yes"
+}
+
+// AskSecret is like Ask but does not echo the input when stdin is a terminal.
+func AskSecret(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return Ask(prompt)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	secret, _ := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(secret))
+}
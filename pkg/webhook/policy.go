@@ -0,0 +1,114 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podSpecPaths are the paths of the Pod spec in the supported kinds.
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// CheckManifest runs the fast built-in checks on the Pod spec of a workload
+// and returns the findings. Kinds without a Pod spec have no findings.
+func CheckManifest(obj *unstructured.Unstructured) []workflows.Finding {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	spec, ok, _ := unstructured.NestedMap(obj.Object, path...)
+	if !ok {
+		return nil
+	}
+
+	var findings []workflows.Finding
+	for _, field := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if enabled, _, _ := unstructured.NestedBool(spec, field); enabled {
+			findings = append(findings, workflows.Finding{
+				Title:       fmt.Sprintf("%s is enabled", field),
+				Severity:    "HIGH",
+				Description: fmt.Sprintf("The Pod shares the %s namespace of the node.", strings.TrimPrefix(field, "host")),
+				Remediation: fmt.Sprintf("Remove %s unless the workload really needs it.", field),
+			})
+		}
+	}
+
+	for _, c := range podContainers(spec) {
+		name, _, _ := unstructured.NestedString(c, "name")
+		if privileged, _, _ := unstructured.NestedBool(c, "securityContext", "privileged"); privileged {
+			findings = append(findings, workflows.Finding{
+				Title:       fmt.Sprintf("Container %s is privileged", name),
+				Severity:    "CRITICAL",
+				Description: "A privileged container has full access to the node.",
+				Remediation: "Remove securityContext.privileged and grant only the capabilities required.",
+			})
+		}
+		if image, _, _ := unstructured.NestedString(c, "image"); !pinnedImage(image) {
+			findings = append(findings, workflows.Finding{
+				Title:       fmt.Sprintf("Container %s uses a mutable image tag", name),
+				Severity:    "MEDIUM",
+				Description: fmt.Sprintf("The image %q has no tag or uses the latest tag.", image),
+				Remediation: "Pin the image to a version tag or a digest.",
+			})
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(c, "resources", "limits", "memory"); !ok {
+			findings = append(findings, workflows.Finding{
+				Title:       fmt.Sprintf("Container %s has no memory limit", name),
+				Severity:    "MEDIUM",
+				Description: "The container may consume all the memory of the node.",
+				Remediation: "Set resources.limits.memory.",
+			})
+		}
+	}
+	return findings
+}
+
+// podContainers returns the init and regular containers of a Pod spec.
+func podContainers(spec map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		items, _, _ := unstructured.NestedSlice(spec, field)
+		for _, item := range items {
+			if c, ok := item.(map[string]interface{}); ok {
+				containers = append(containers, c)
+			}
+		}
+	}
+	return containers
+}
+
+// pinnedImage returns true if the image has a digest or a tag other than latest.
+func pinnedImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	// The tag follows the last colon after the last slash (the registry may have a port).
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i >= 0 && name[i+1:] != "latest"
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AnnotationFindings is the audit annotation holding the findings of a request.
+const AnnotationFindings = "kube-copilot.io/findings"
+
+// maxRequestSize bounds the size of the AdmissionReview bodies.
+const maxRequestSize = 3 << 20
+
+// Analyzer reviews a manifest (in YAML or JSON) with the LLM.
+type Analyzer func(ctx context.Context, manifest string) ([]workflows.Finding, error)
+
+// Handler is a validating admission webhook reviewing the manifests on CREATE
+// and UPDATE. The fast built-in checks run on every request, followed by the
+// LLM analysis when an Analyzer is set. Requests with findings at or above
+// DenySeverity are denied; findings at or above WarnSeverity are returned as
+// warnings and audit annotations.
+type Handler struct {
+	// Analyzer runs the LLM analysis (disabled if nil).
+	Analyzer Analyzer
+	// AnalysisTimeout bounds the LLM analysis. The request is reviewed with
+	// the built-in checks only when the analysis fails or times out.
+	AnalysisTimeout time.Duration
+	// DenySeverity is the severity at or above which requests are denied (never if empty).
+	DenySeverity string
+	// WarnSeverity is the severity at or above which findings are reported.
+	WarnSeverity string
+}
+
+// ServeHTTP implements http.Handler for admission.k8s.io/v1 AdmissionReviews.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = h.Review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// Review reviews an admission request.
+func (h *Handler) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return resp
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		// Let the API server report malformed objects.
+		return resp
+	}
+
+	findings := CheckManifest(obj)
+	if h.Analyzer != nil {
+		findings = append(findings, h.analyze(ctx, req.Object.Raw)...)
+	}
+
+	reported := findings
+	if h.WarnSeverity != "" {
+		reported = workflows.FindingsAtOrAbove(findings, h.WarnSeverity)
+	}
+	for _, f := range reported {
+		resp.Warnings = append(resp.Warnings, formatFinding(f))
+	}
+	if len(reported) > 0 {
+		data, _ := json.Marshal(reported)
+		resp.AuditAnnotations = map[string]string{AnnotationFindings: string(data)}
+	}
+
+	if h.DenySeverity != "" {
+		if denied := workflows.FindingsAtOrAbove(findings, h.DenySeverity); len(denied) > 0 {
+			messages := make([]string, 0, len(denied))
+			for _, f := range denied {
+				messages = append(messages, formatFinding(f))
+			}
+			resp.Allowed = false
+			resp.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Reason:  metav1.StatusReasonForbidden,
+				Code:    http.StatusForbidden,
+				Message: fmt.Sprintf("denied by kube-copilot: %s", strings.Join(messages, "; ")),
+			}
+		}
+	}
+	return resp
+}
+
+// analyze runs the LLM analysis, bounded by AnalysisTimeout.
+func (h *Handler) analyze(ctx context.Context, manifest []byte) []workflows.Finding {
+	if h.AnalysisTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.AnalysisTimeout)
+		defer cancel()
+	}
+
+	type result struct {
+		findings []workflows.Finding
+		err      error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		findings, err := h.Analyzer(ctx, string(manifest))
+		ch <- result{findings, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil
+		}
+		return r.findings
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// formatFinding formats a finding as a single-line message.
+func formatFinding(f workflows.Finding) string {
+	severity := f.Severity
+	if severity == "" {
+		severity = "LOW"
+	}
+	if f.Remediation == "" {
+		return fmt.Sprintf("[%s] %s", severity, f.Title)
+	}
+	return fmt.Sprintf("[%s] %s: %s", severity, f.Title, f.Remediation)
+}
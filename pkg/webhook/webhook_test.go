@@ -0,0 +1,218 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/workflows"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const privilegedDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  template:
+    spec:
+      hostNetwork: true
+      containers:
+        - name: nginx
+          image: nginx
+          securityContext:
+            privileged: true
+`
+
+const safePod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+spec:
+  containers:
+    - name: nginx
+      image: registry.local:5000/nginx:1.27
+      resources:
+        limits:
+          memory: 128Mi
+`
+
+func rawObject(t *testing.T, manifest string) runtime.RawExtension {
+	data, err := yaml.YAMLToJSON([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+func TestCheckManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "privileged deployment",
+			manifest: privilegedDeployment,
+			want:     []string{"hostNetwork is enabled", "Container nginx is privileged", "Container nginx uses a mutable image tag", "Container nginx has no memory limit"},
+		},
+		{name: "safe pod", manifest: safePod},
+		{name: "configmap", manifest: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(rawObject(t, tt.manifest).Raw); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range CheckManifest(obj) {
+				got = append(got, f.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("CheckManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinnedImage(t *testing.T) {
+	tests := map[string]bool{
+		"nginx":                       false,
+		"nginx:latest":                false,
+		"nginx:1.27":                  true,
+		"registry.local:5000/nginx":   false,
+		"registry.local:5000/nginx:1": true,
+		"nginx@sha256:abc":            true,
+	}
+	for image, want := range tests {
+		if got := pinnedImage(image); got != want {
+			t.Errorf("pinnedImage(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestReview(t *testing.T) {
+	slowAnalyzer := func(ctx context.Context, manifest string) ([]workflows.Finding, error) {
+		time.Sleep(time.Second)
+		return []workflows.Finding{{Title: "late", Severity: "CRITICAL"}}, nil
+	}
+	llmAnalyzer := func(ctx context.Context, manifest string) ([]workflows.Finding, error) {
+		return []workflows.Finding{{Title: "Exposed secret", Severity: "HIGH", Remediation: "Use a Secret."}}, nil
+	}
+	tests := []struct {
+		name         string
+		handler      *Handler
+		operation    admissionv1.Operation
+		manifest     string
+		wantAllowed  bool
+		wantWarnings int
+	}{
+		{
+			name:         "warn only",
+			handler:      &Handler{WarnSeverity: "HIGH"},
+			operation:    admissionv1.Create,
+			manifest:     privilegedDeployment,
+			wantAllowed:  true,
+			wantWarnings: 2,
+		},
+		{
+			name:         "deny critical",
+			handler:      &Handler{DenySeverity: "CRITICAL", WarnSeverity: "CRITICAL"},
+			operation:    admissionv1.Update,
+			manifest:     privilegedDeployment,
+			wantAllowed:  false,
+			wantWarnings: 1,
+		},
+		{
+			name:         "deny by LLM finding",
+			handler:      &Handler{Analyzer: llmAnalyzer, DenySeverity: "HIGH"},
+			operation:    admissionv1.Create,
+			manifest:     safePod,
+			wantAllowed:  false,
+			wantWarnings: 1,
+		},
+		{
+			name:        "LLM timeout falls back to the built-in checks",
+			handler:     &Handler{Analyzer: slowAnalyzer, AnalysisTimeout: 10 * time.Millisecond, DenySeverity: "CRITICAL"},
+			operation:   admissionv1.Create,
+			manifest:    safePod,
+			wantAllowed: true,
+		},
+		{
+			name:        "delete is ignored",
+			handler:     &Handler{DenySeverity: "LOW"},
+			operation:   admissionv1.Delete,
+			manifest:    privilegedDeployment,
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := tt.handler.Review(context.Background(), &admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Object:    rawObject(t, tt.manifest),
+			})
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v (result: %v)", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+			if len(resp.Warnings) != tt.wantWarnings {
+				t.Errorf("Warnings = %v, want %d warnings", resp.Warnings, tt.wantWarnings)
+			}
+			if tt.wantWarnings > 0 && resp.AuditAnnotations[AnnotationFindings] == "" {
+				t.Errorf("AuditAnnotations = %v, want the findings", resp.AuditAnnotations)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	review := admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{UID: "uid", Operation: admissionv1.Create, Object: rawObject(t, privilegedDeployment)},
+	}
+	review.APIVersion = "admission.k8s.io/v1"
+	review.Kind = "AdmissionReview"
+	body, _ := json.Marshal(review)
+
+	rec := httptest.NewRecorder()
+	(&Handler{DenySeverity: "CRITICAL"}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var got admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != "AdmissionReview" || got.Request != nil || got.Response == nil {
+		t.Fatalf("unexpected AdmissionReview %+v", got)
+	}
+	if got.Response.UID != "uid" || got.Response.Allowed {
+		t.Errorf("Response = %+v, want a denial for uid", got.Response)
+	}
+}
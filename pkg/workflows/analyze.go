@@ -17,10 +17,14 @@ package workflows
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"strings"
+	"sync"
 
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
 	"github.com/feiskyer/swarm-go"
+	"sigs.k8s.io/yaml"
 )
 
 const analysisPrompt = `As an expert on Kubernetes, your task is analyzing the given Kubernetes manifests, figure out the issues and provide solutions in a human-readable format.
@@ -42,17 +46,24 @@ Provide the output in structured markdown, using clear and concise language.
 
 ## 1. <title of the issue or potential problem>
 
+- **Severity**: MEDIUM
+- **Category**: misconfiguration
 - **Findings**: The YAML configuration doesn't specify the memory limit for the pod.
+- **Evidence**: spec.containers[0].resources has no limits.
 - **How to resolve**: Set memory limit in Pod spec.
 
 ## 2. HIGH Severity: CVE-2024-10963
 
+- **Severity**: HIGH
+- **Category**: vulnerability
 - **Findings**: The Pod is running with CVE pam: Improper Hostname Interpretation in pam_access Leads to Access Control Bypass.
 - **How to resolve**: Update package libpam-modules to fixed version (>=1.5.3) in the image. (leave the version number to empty if you don't know it)
 
 # Notes
 
+- The recent events of the resource ("events") and the last log lines of its failing containers ("logs") may be given with the manifest; use them as clues before running kubectl.
 - Keep your language concise and simple.
+- Rate the severity of each issue as CRITICAL, HIGH, MEDIUM or LOW.
 - Ensure key points are included, e.g. CVE number, error code, versions.
 - Relatable analogies should help in visualizing the problem and solution.
 - Ensure explanations are self-contained, enough for newcomers without previous technical exposure to understand.
@@ -60,6 +71,86 @@ Provide the output in structured markdown, using clear and concise language.
 
 // AnalysisFlow runs a workflow to analyze Kubernetes issues and provide solutions in a human-readable format.
 func AnalysisFlow(model string, manifest string, verbose bool) (string, error) {
+	return analysisFlow(context.Background(), model, manifest, nil, []swarm.AgentFunction{kubectlFunc}, verbose)
+}
+
+// ManifestAnalysisFlow analyzes a manifest without any cluster tool until
+// ctx is done. The manifests submitted by others (e.g. to the admission
+// webhook) can't get the model to read the cluster and disclose it.
+func ManifestAnalysisFlow(ctx context.Context, model string, manifest string, verbose bool) (string, error) {
+	return analysisFlow(ctx, model, manifest, nil, nil, verbose)
+}
+
+// AnalyzeResourceFlow runs the analysis workflow for a resource of the
+// cluster. Its recent events, and the logs of the failing containers of a
+// Pod, are fetched concurrently with its manifest and handed to the model
+// with it, saving the tool iterations that would fetch them.
+func AnalyzeResourceFlow(model string, resource string, name string, namespace string, verbose bool) (string, error) {
+	prefetched := make(chan map[string]interface{}, 1)
+	go func() { prefetched <- prefetchAnalysis(resource, name, namespace, verbose) }()
+
+	manifest, err := kubernetes.GetYaml(resource, name, namespace)
+	if err != nil {
+		return "", err
+	}
+	return analysisFlow(context.Background(), model, manifest, <-prefetched, []swarm.AgentFunction{kubectlFunc}, verbose)
+}
+
+// prefetchAnalysis returns the recent events of the resource and, for a Pod,
+// the logs of its failing containers, as YAML inputs of the analysis.
+func prefetchAnalysis(resource, name, namespace string, verbose bool) map[string]interface{} {
+	inputs := map[string]interface{}{}
+	// Recorded runs are replayed without cluster access.
+	if Cassette != nil || tools.Offline {
+		return inputs
+	}
+
+	var wg sync.WaitGroup
+	var events []kubernetes.EventSummary
+	var logs []kubernetes.ContainerLogs
+	var eventsErr, logsErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		events, eventsErr = kubernetes.ListEvents(namespace, name)
+	}()
+	switch strings.ToLower(resource) {
+	case "po", "pod", "pods":
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logs, logsErr = kubernetes.GetPodLogs(namespace, name)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range []error{eventsErr, logsErr} {
+		if err != nil && verbose {
+			color.Yellow("Unable to prefetch the context of %s %s/%s: %v\n", resource, namespace, name, err)
+		}
+	}
+	if len(events) > 0 {
+		if data, err := yaml.Marshal(events); err == nil {
+			inputs["events"] = string(data)
+		}
+	}
+	if len(logs) > 0 {
+		if data, err := yaml.Marshal(logs); err == nil {
+			inputs["logs"] = string(data)
+		}
+	}
+	return inputs
+}
+
+// analysisFlow analyzes the manifest with the prefetched inputs and the tools in functions.
+func analysisFlow(ctx context.Context, model string, manifest string, prefetched map[string]interface{}, functions []swarm.AgentFunction, verbose bool) (string, error) {
+	inputs := map[string]interface{}{
+		"k8s_manifest": manifest,
+	}
+	for key, value := range prefetched {
+		inputs[key] = value
+	}
+
 	analysisWorkflow := &swarm.SimpleFlow{
 		Name:     "analysis-workflow",
 		Model:    model,
@@ -69,11 +160,9 @@ func AnalysisFlow(model string, manifest string, verbose bool) (string, error) {
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "analyze",
-				Instructions: analysisPrompt,
-				Inputs: map[string]interface{}{
-					"k8s_manifest": manifest,
-				},
-				Functions: []swarm.AgentFunction{kubectlFunc},
+				Instructions: prompt("analyze"),
+				Inputs:       inputs,
+				Functions:    functions,
 			},
 		},
 	}
@@ -81,13 +170,12 @@ func AnalysisFlow(model string, manifest string, verbose bool) (string, error) {
 	// Create OpenAI client
 	client, err := NewSwarm()
 	if err != nil {
-		fmt.Printf("Failed to create client: %v\n", err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Initialize and run workflow
 	analysisWorkflow.Initialize()
-	result, _, err := analysisWorkflow.Run(context.Background(), client)
+	result, err := runAnswerFlow(ctx, analysisWorkflow, client)
 	if err != nil {
 		return "", err
 	}
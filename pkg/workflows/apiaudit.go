@@ -0,0 +1,176 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/apiaudit"
+	"github.com/feiskyer/swarm-go"
+)
+
+// maxAuditEvents is the maximum number of audit events returned by a query.
+const maxAuditEvents = 50
+
+const apiAuditPrompt = `As an expert on Kubernetes security and operations, answer the user's question about the changes and accesses in the cluster from its API server audit logs.
+
+# Steps
+
+1. **Understand the Question**: Identify the resource, namespace, object name, user and time range the question is about (e.g. "in the last hour" is relative to the current time).
+2. **Query the Audit Events**: Call the audit_events function with the matching filters. Only mutating requests are returned by default; set verbs to "*" or to specific verbs (e.g. get, list, watch) for read accesses. Broaden the filters (e.g. drop the name or extend the time range) when nothing matches.
+3. **Correlate**: Follow related events (e.g. a Deployment scaled down before its Pods were deleted, or a ServiceAccount used by a controller) to explain what happened.
+
+# Output Format
+
+Start with a direct answer (who did what, and when), followed by the supporting events as a markdown table (time, user, verb, resource, namespace/name, response code) and any notable observations (e.g. failed requests, impersonation, unusual source IPs or user agents).
+
+If no event matches, say so and mention the time range covered by the audit log.
+`
+
+// APIAuditFlow answers a question about the cluster changes and accesses from the API server audit events.
+func APIAuditFlow(model string, question string, events []apiaudit.Event, verbose bool) (string, error) {
+	now := time.Now()
+	flow := &swarm.SimpleFlow{
+		Name:     "apiaudit-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to investigate the API server audit logs.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "apiaudit",
+				Instructions: prompt("apiaudit"),
+				Inputs: map[string]interface{}{
+					"question":     question,
+					"current_time": now.Format(time.RFC3339),
+					"audit_log":    describeAuditEvents(events),
+				},
+				Functions: []swarm.AgentFunction{auditEventsFunc(events, now)},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// auditEventsFunc is a Swarm function querying the audit events.
+func auditEventsFunc(events []apiaudit.Event, now time.Time) swarm.AgentFunction {
+	return swarm.NewAgentFunction(
+		"audit_events",
+		"Query the Kubernetes API server audit events, the most recent first",
+		func(args map[string]interface{}) (interface{}, error) {
+			recordUsage(func(u *Usage) { u.ToolCalls++ })
+			filter, err := parseAuditFilter(args, now)
+			if err != nil {
+				return nil, err
+			}
+
+			matches := apiaudit.Query(events, filter)
+			result := apiaudit.Format(matches)
+			if len(matches) == maxAuditEvents {
+				result += fmt.Sprintf("\n\nOnly the %d most recent events are shown; narrow the filters for older events.", maxAuditEvents)
+			}
+			return result, nil
+		},
+		[]swarm.Parameter{
+			{Name: "verbs", Description: "Comma-separated request verbs (e.g. delete,patch); defaults to the mutating verbs, * for all", Type: reflect.TypeOf("")},
+			{Name: "resource", Description: "The resource, e.g. deployments or secrets", Type: reflect.TypeOf("")},
+			{Name: "namespace", Description: "The namespace of the objects", Type: reflect.TypeOf("")},
+			{Name: "name", Description: "A substring of the object names", Type: reflect.TypeOf("")},
+			{Name: "user", Description: "A substring of the usernames", Type: reflect.TypeOf("")},
+			{Name: "since", Description: "Only events after this time: a duration before now (e.g. 1h) or an RFC 3339 time", Type: reflect.TypeOf("")},
+			{Name: "until", Description: "Only events before this time: a duration before now (e.g. 30m) or an RFC 3339 time", Type: reflect.TypeOf("")},
+		},
+	)
+}
+
+// parseAuditFilter converts the arguments of the audit_events function into a filter.
+func parseAuditFilter(args map[string]interface{}, now time.Time) (apiaudit.Filter, error) {
+	arg := func(name string) string {
+		value, _ := args[name].(string)
+		return strings.TrimSpace(value)
+	}
+
+	filter := apiaudit.Filter{
+		Resource:  arg("resource"),
+		Namespace: arg("namespace"),
+		Name:      arg("name"),
+		User:      arg("user"),
+		Limit:     maxAuditEvents,
+	}
+	for _, verb := range strings.Split(arg("verbs"), ",") {
+		if verb = strings.TrimSpace(verb); verb != "" {
+			filter.Verbs = append(filter.Verbs, verb)
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(arg("since"), now); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseAuditTime(arg("until"), now); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses a duration before now or an RFC 3339 time. Empty values yield the zero time.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, should be a duration (e.g. 1h) or an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// describeAuditEvents summarizes the number and time range of the audit events.
+func describeAuditEvents(events []apiaudit.Event) string {
+	if len(events) == 0 {
+		return "The audit log is empty."
+	}
+
+	var first, last time.Time
+	for _, event := range events {
+		t := event.RequestReceivedTimestamp
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	return fmt.Sprintf("%d events from %s to %s", len(events), first.UTC().Format(time.RFC3339), last.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/apiaudit"
+)
+
+func TestParseAuditFilter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    apiaudit.Filter
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			args: map[string]interface{}{},
+			want: apiaudit.Filter{Limit: maxAuditEvents},
+		},
+		{
+			name: "all filters",
+			args: map[string]interface{}{
+				"verbs":     "delete, patch",
+				"resource":  "deployments",
+				"namespace": "web",
+				"name":      "nginx",
+				"user":      "alice",
+				"since":     "2h",
+				"until":     "2025-03-01T11:00:00Z",
+			},
+			want: apiaudit.Filter{
+				Verbs:     []string{"delete", "patch"},
+				Resource:  "deployments",
+				Namespace: "web",
+				Name:      "nginx",
+				User:      "alice",
+				Since:     now.Add(-2 * time.Hour),
+				Until:     time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC),
+				Limit:     maxAuditEvents,
+			},
+		},
+		{
+			name:    "invalid time",
+			args:    map[string]interface{}{"since": "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuditFilter(tt.args, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuditFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAuditFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,9 +17,10 @@ package workflows
 
 import (
 	"context"
-	"fmt"
-	"os"
 
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
 	"github.com/feiskyer/swarm-go"
 )
 
@@ -40,7 +41,7 @@ const auditPrompt = `Conduct a structured security audit of a Kubernetes environ
 **2. Vulnerability Scanning:**
    - **Extract and Scan Image:**
       - Extract the container image from the YAML configuration obtained during last step.
-      - Perform a scan using "trivy image <image>".
+      - Perform a scan using "trivy image <image>", unless the image is already summarized in the image_scans input.
       - Summerize Vulnerability Scans results with CVE numbers, severity, and descriptions.
 
 **3. Issue Identification and Solution Formulation:**
@@ -53,17 +54,24 @@ Example output:
 
 	## 1. <title of the issue or potential problem>
 
+	- **Severity**: MEDIUM
+	- **Category**: misconfiguration
 	- **Findings**: The YAML configuration doesn't specify the memory limit for the pod.
+	- **Evidence**: spec.containers[0].resources has no limits.
 	- **How to resolve**: Set memory limit in Pod spec.
 
 	## 2. HIGH Severity: CVE-2024-10963
 
+	- **Severity**: HIGH
+	- **Category**: vulnerability
 	- **Findings**: The Pod is running with CVE pam: Improper Hostname Interpretation in pam_access Leads to Access Control Bypass.
 	- **How to resolve**: Update package libpam-modules to fixed version (>=1.5.3) in the image. (leave the version number to empty if you don't know it)
 
 # Notes
 
 - Keep your language concise and simple.
+- The image_scans input, if provided, holds the trivy scans of the Pod images: use them instead of scanning the images again.
+- Rate the severity of each issue as CRITICAL, HIGH, MEDIUM or LOW.
 - Ensure key points are included, e.g. CVE number, error code, versions.
 - Relatable analogies should help in visualizing the problem and solution.
 - Ensure explanations are self-contained, enough for newcomers without previous technical exposure to understand.
@@ -71,6 +79,24 @@ Example output:
 
 // AuditFlow conducts a structured security audit of a Kubernetes Pod.
 func AuditFlow(model string, namespace string, name string, verbose bool) (string, error) {
+	return auditFlow(context.Background(), model, namespace, name, "", verbose)
+}
+
+// AuditFlowContext conducts a structured security audit of a Kubernetes Pod until ctx is done.
+func AuditFlowContext(ctx context.Context, model string, namespace string, name string, verbose bool) (string, error) {
+	return auditFlow(ctx, model, namespace, name, "", verbose)
+}
+
+// auditFlow audits the Pod, of which the images already scanned are
+// summarized in imageScans.
+func auditFlow(ctx context.Context, model string, namespace string, name string, imageScans string, verbose bool) (string, error) {
+	inputs := map[string]interface{}{
+		"pod_namespace": namespace,
+		"pod_name":      name,
+	}
+	if imageScans != "" {
+		inputs["image_scans"] = imageScans
+	}
 	auditWorkflow := &swarm.SimpleFlow{
 		Name:     "audit-workflow",
 		Model:    model,
@@ -80,12 +106,9 @@ func AuditFlow(model string, namespace string, name string, verbose bool) (strin
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "audit",
-				Instructions: auditPrompt,
-				Inputs: map[string]interface{}{
-					"pod_namespace": namespace,
-					"pod_name":      name,
-				},
-				Functions: []swarm.AgentFunction{trivyFunc, kubectlFunc},
+				Instructions: prompt("audit"),
+				Inputs:       inputs,
+				Functions:    []swarm.AgentFunction{trivyFunc, kubectlFunc},
 			},
 		},
 	}
@@ -93,16 +116,69 @@ func AuditFlow(model string, namespace string, name string, verbose bool) (strin
 	// Create OpenAI client
 	client, err := NewSwarm()
 	if err != nil {
-		fmt.Printf("Failed to create client: %v\n", err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Initialize and run workflow
 	auditWorkflow.Initialize()
-	result, _, err := auditWorkflow.Run(context.Background(), client)
+	result, err := runAnswerFlow(ctx, auditWorkflow, client)
 	if err != nil {
 		return "", err
 	}
 
 	return result, nil
 }
+
+// AuditResult is the audit report for a single Pod.
+type AuditResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Report    string `json:"report,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// ErrorCode is the stable code of the error kind (see errdefs.Code).
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// BatchAuditFlow audits all the Pods in the given namespace one by one.
+// The distinct images of the Pods are scanned upfront by ScanWorkers
+// concurrent workers, and each audit is given the scans of its Pod images.
+// Failures of individual Pods are recorded in their results instead of aborting the batch.
+func BatchAuditFlow(model string, namespace string, verbose bool) ([]AuditResult, error) {
+	pods, err := kubernetes.ListPodImages(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, pod := range pods {
+		images = append(images, pod.Images...)
+	}
+	if verbose && len(pods) > 0 {
+		color.Blue("Scanning the images of %d Pods with %d workers\n", len(pods), ScanWorkers)
+	}
+	scans := map[string]ImageScan{}
+	for _, scan := range ScanImages(images, ScanWorkers) {
+		scans[scan.Image] = scan
+		if verbose && scan.Error != nil {
+			color.Yellow("Failed to scan image %s: %v\n", scan.Image, scan.Error)
+		}
+	}
+
+	results := make([]AuditResult, 0, len(pods))
+	for i, pod := range pods {
+		if verbose {
+			color.Blue("[%d/%d] Auditing Pod %s/%s\n", i+1, len(pods), namespace, pod.Name)
+		}
+
+		result := AuditResult{Namespace: namespace, Name: pod.Name}
+		report, err := auditFlow(context.Background(), model, namespace, pod.Name, formatImageScans(pod.Images, scans), verbose)
+		if err != nil {
+			result.Error, result.ErrorCode = err.Error(), errdefs.Code(err)
+		} else {
+			result.Report = report
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/feiskyer/kube-copilot/pkg/llms"
+	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+var (
+	chatClientsLock sync.Mutex
+	// chatClients are the LLM clients created so far, by provider configuration.
+	chatClients = map[string]swarm.OpenAIClient{}
+)
+
+// chatClient implements swarm.OpenAIClient with an openai-go client sending
+// its requests through the shared llms.HTTPClient.
+type chatClient struct {
+	client *openai.Client
+}
+
+// newChatClient returns the LLM client of the provider configuration, created
+// on the first call only so that all the workflows of the process reuse its
+// connections.
+func newChatClient(config []string, opts ...option.RequestOption) swarm.OpenAIClient {
+	key := strings.Join(config, "\x00")
+	chatClientsLock.Lock()
+	defer chatClientsLock.Unlock()
+	if client, ok := chatClients[key]; ok {
+		return client
+	}
+
+	client := &chatClient{client: openai.NewClient(append(opts, option.WithHTTPClient(llms.HTTPClient()))...)}
+	chatClients[key] = client
+	return client
+}
+
+// CreateChatCompletion implements swarm.OpenAIClient.
+func (c *chatClient) CreateChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	completion, err := c.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	return completion, nil
+}
+
+// CreateChatCompletionStream implements swarm.OpenAIClient.
+func (c *chatClient) CreateChatCompletionStream(ctx context.Context, params openai.ChatCompletionNewParams) (*ssestream.Stream[openai.ChatCompletionChunk], error) {
+	return c.client.Chat.Completions.NewStreaming(ctx, params), nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+
+	"github.com/feiskyer/kube-copilot/pkg/datasafety"
+	"github.com/feiskyer/swarm-go"
+)
+
+const dataSafetyPrompt = `As an expert on Kubernetes storage, review the data-loss risks of the operation "{operation}" on {target} in namespace {namespace} before the user performs it.
+
+The automated checks of the StatefulSet update strategy, PersistentVolumeClaim retention policy, PersistentVolume reclaim policy and backup coverage (Velero Schedules and VolumeSnapshots) found the risks given in the input, with a verdict of SAFE, CAUTION or UNSAFE.
+
+# Steps
+
+1. **Verify**: Use kubectl to confirm the risks when needed (e.g. "kubectl get pvc -n <namespace>", "kubectl get pv <pv> -o jsonpath='{.spec.persistentVolumeReclaimPolicy}'", "kubectl get schedules.velero.io -A").
+2. **Assess the Impact**: For each risk, explain which data is lost or becomes unavailable if the operation is performed now.
+3. **Recommend**: Give the safe procedure, in order: the backups to take, the policies to change, and the commands to perform the operation.
+
+# Output Format
+
+Start with the verdict and a one-paragraph summary of the data-loss risk, followed by one section per risk (group the risks of the same StatefulSet):
+
+## 1. <title of the risk>
+
+- **Severity**: <HIGH, MEDIUM or LOW, as given by the checks>
+- **Findings**: <the StatefulSet, the risk and the data at stake>
+- **How to resolve**: <step-by-step fix>
+
+If there is no risk, say that the operation is safe for the data and summarize the checks performed.
+`
+
+// DataSafetyFlow writes the data-loss risk report of an operation on StatefulSets from the risks found by the data-safety checks.
+func DataSafetyFlow(model string, namespace string, target string, operation datasafety.Operation, risks []datasafety.Risk, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "datasafety-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes storage helping the user to protect the data of their StatefulSets.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "datasafety",
+				Instructions: prompt("datasafety"),
+				Inputs: map[string]interface{}{
+					"namespace": namespace,
+					"target":    target,
+					"operation": operation.String(),
+					"verdict":   datasafety.Verdict(risks),
+					"risks":     datasafety.Format(risks),
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/kubernetes"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+const diagnosePrompt = `Diagnose the issues for Pod %s in namespace %s.
+
+# Steps
+
+1. **Pod Status**: Check the Pod phase, conditions, container statuses (restart count, last termination reason, exit code) and recent events.
+2. **Container Logs**: Fetch logs for failing containers, including the previous instance ("--previous") for crashing ones.
+3. **Related Resources**: Inspect the owner workload, Services, ConfigMaps, Secrets references, PVCs and the Node when the Pod status points at them.
+4. **Root Cause**: Correlate the evidence and identify the root cause of each issue.
+
+# Output Format
+
+Provide the final answer in structured markdown, one section per issue, using clear and concise language:
+
+## 1. <title of the issue>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <what is wrong>
+- **Evidence**: <what shows it (e.g. event message, exit code, log line)>
+- **How to resolve**: <step-by-step fix>
+
+If no issue is found, say that the Pod is healthy and summarize the checks performed.
+`
+
+const podContextPrompt = `
+# Context
+
+The state of the Pod and of its related resources (owners, Services, Ingresses, referenced ConfigMaps and Secrets, recent events, Node and the last log lines of the failing containers) was collected up front. Start from it and only run kubectl for the evidence it does not cover (e.g. older log lines or the parts listed in "skipped"):
+
+` + "```yaml\n%s```\n"
+
+// NewDiagnoseFlow creates a ReAct workflow to diagnose problems for a Kubernetes Pod.
+// The context of the Pod, including the logs of its failing containers, is
+// collected concurrently with the planning call to save tool iterations.
+func NewDiagnoseFlow(model string, namespace string, name string, verbose bool, maxIterations int) (*ReActFlow, error) {
+	flow, err := NewReActFlow(model, fmt.Sprintf(prompt("diagnose"), name, namespace), verbose, maxIterations)
+	if err != nil {
+		return nil, err
+	}
+	flow.Prefetch = func(ctx context.Context) string {
+		if podContext := collectPodContext(namespace, name, verbose); podContext != "" {
+			return fmt.Sprintf(podContextPrompt, podContext)
+		}
+		return ""
+	}
+	return flow, nil
+}
+
+// collectPodContext returns the context of the Pod as YAML, or "" if it cannot
+// be collected, in which case the model gathers the evidence with the tools.
+func collectPodContext(namespace, name string, verbose bool) string {
+	// Recorded runs are replayed, and evaluations mocked, without cluster access.
+	if Cassette != nil || tools.Offline || kubernetes.Offline {
+		return ""
+	}
+	podContext, err := kubernetes.GetPodContext(namespace, name)
+	if err != nil {
+		if verbose {
+			color.Yellow("Unable to collect the context of Pod %s/%s: %v\n", namespace, name, err)
+		}
+		return ""
+	}
+	return podContext.Format()
+}
+
+// DiagnoseFlow runs a ReAct workflow to diagnose problems for a Kubernetes Pod.
+func DiagnoseFlow(model string, namespace string, name string, verbose bool, maxIterations int) (string, error) {
+	flow, err := NewDiagnoseFlow(model, namespace, name, verbose, maxIterations)
+	if err != nil {
+		return "", err
+	}
+
+	return flow.Run()
+}
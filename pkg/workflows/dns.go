@@ -0,0 +1,70 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"fmt"
+)
+
+const dnsPrompt = `Troubleshoot the DNS resolution %s.
+
+# Steps
+
+1. **CoreDNS Health**: Check the DNS Pods in kube-system (e.g. "kubectl get pods -n kube-system -l k8s-app=kube-dns -o wide"), their restarts, readiness and recent logs (look for "SERVFAIL", "i/o timeout", "plugin/loop" or "no such host").
+2. **CoreDNS Configuration**: Inspect the coredns ConfigMap (Corefile): the forward upstreams, stub domains, rewrite and cache plugins, and loop detection.
+3. **DNS Service**: Check that the kube-dns Service has a ClusterIP matching the nameserver of the Pods and ready endpoints ("kubectl get endpointslices -n kube-system -l kubernetes.io/service-name=kube-dns").
+4. **Client Configuration**: For the Pod, check its dnsPolicy, dnsConfig, hostNetwork and /etc/resolv.conf (nameserver, search domains, ndots).
+5. **Target Records**: When the name is a Service, check that the Service exists in the expected namespace, its type, selector and ready endpoints (headless Services only resolve to ready Pods).
+6. **Test Lookups**: Run lookups from an ephemeral debug Pod, e.g. "kubectl run dns-test-<random suffix> -n <namespace> --rm -i --restart=Never --image=registry.k8s.io/e2e-test-images/jessie-dnsutils:1.3 -- nslookup <name>", or "dig +search <name>" to show the status codes. Compare the short name, the fully qualified name (<service>.<namespace>.svc.cluster.local), an external name, and a direct query to a CoreDNS Pod IP.
+7. **Network Path**: When lookups time out, check the NetworkPolicies blocking egress to kube-system on UDP/TCP port 53, and kube-proxy or the CNI on the Node.
+
+# Explaining the Results
+
+- NXDOMAIN means the name does not exist: a typo, the wrong namespace, a missing Service, or search domains and ndots expanding the name unexpectedly.
+- SERVFAIL means CoreDNS failed to answer: an unreachable or failing upstream, a forward loop, or a misconfigured stub domain.
+- Timeouts mean the queries don't reach CoreDNS or get no answer: NetworkPolicies, no ready CoreDNS endpoints, kube-proxy or conntrack issues.
+
+# Output Format
+
+Explain the root cause step by step, following the path of the query (Pod resolv.conf, kube-dns Service, CoreDNS, upstream), then provide one section per issue:
+
+## 1. <title of the issue>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <what is wrong, with the evidence (e.g. lookup result, CoreDNS log line, Corefile snippet)>
+- **How to resolve**: <step-by-step fix>
+
+If DNS works as expected, say so and summarize the checks performed.
+`
+
+// dnsTarget describes what to troubleshoot: the resolution of a name and/or from a Pod.
+func dnsTarget(namespace string, name string, pod string) string {
+	switch {
+	case name != "" && pod != "":
+		return fmt.Sprintf("of %q from Pod %s in namespace %s", name, pod, namespace)
+	case name != "":
+		return fmt.Sprintf("of %q from Pods in namespace %s", name, namespace)
+	case pod != "":
+		return fmt.Sprintf("for Pod %s in namespace %s", pod, namespace)
+	default:
+		return fmt.Sprintf("in the cluster for Pods in namespace %s", namespace)
+	}
+}
+
+// NewDNSFlow creates a ReAct workflow to troubleshoot the DNS resolution of a name and/or from a Pod.
+func NewDNSFlow(model string, namespace string, name string, pod string, verbose bool, maxIterations int) (*ReActFlow, error) {
+	return NewReActFlow(model, fmt.Sprintf(prompt("dns"), dnsTarget(namespace, name, pod)), verbose, maxIterations)
+}
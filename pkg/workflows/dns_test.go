@@ -0,0 +1,36 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import "testing"
+
+func TestDNSTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  string
+		want string
+	}{
+		{name: "api.prod", pod: "web-0", want: `of "api.prod" from Pod web-0 in namespace default`},
+		{name: "example.com", want: `of "example.com" from Pods in namespace default`},
+		{pod: "web-0", want: "for Pod web-0 in namespace default"},
+		{want: "in the cluster for Pods in namespace default"},
+	}
+	for _, tt := range tests {
+		if got := dnsTarget("default", tt.name, tt.pod); got != tt.want {
+			t.Errorf("dnsTarget(%q, %q) = %q, want %q", tt.name, tt.pod, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+// EventType is the type of a ReActFlow progress event.
+type EventType string
+
+const (
+	// EventPlanning is emitted when the planning phase starts.
+	EventPlanning EventType = "planning"
+	// EventStepStarted is emitted at the start of each iteration.
+	EventStepStarted EventType = "step_started"
+	// EventThinking is emitted before asking the LLM how to execute a step.
+	EventThinking EventType = "thinking"
+	// EventToolStarted is emitted before running a tool.
+	EventToolStarted EventType = "tool_started"
+	// EventToolFinished is emitted after a tool returns.
+	EventToolFinished EventType = "tool_finished"
+	// EventFinished is emitted when the flow completes.
+	EventFinished EventType = "finished"
+)
+
+// Event is a progress event emitted by ReActFlow.
+type Event struct {
+	Type          EventType
+	Iteration     int
+	MaxIterations int
+	Step          string
+	Tool          string
+	ToolInput     string
+}
+
+// emit sends the event to the OnEvent callback, if any.
+func (r *ReActFlow) emit(event Event) {
+	if r.OnEvent == nil {
+		return
+	}
+	event.MaxIterations = r.MaxIterations
+	r.OnEvent(event)
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Finding is a single issue reported by a workflow. The findings of all the
+// workflows share this model, so that they are exported (e.g. SARIF), stored
+// and filtered the same way.
+type Finding struct {
+	// ID identifies the kind of issue across runs: the CVE ID for
+	// vulnerabilities, or a slug of the title otherwise (see FindingID).
+	ID       string `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Severity string `json:"severity,omitempty"`
+	// Category is the kind of issue, e.g. vulnerability or misconfiguration.
+	Category string `json:"category,omitempty"`
+	// Resource is the Kubernetes object affected by the issue.
+	Resource *ResourceRef `json:"resource,omitempty"`
+	// Evidence is what shows the issue, e.g. a YAML field, an event or a log line.
+	Evidence    string `json:"evidence,omitempty"`
+	Description string `json:"description,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Categories of the findings.
+const (
+	CategoryVulnerability    = "vulnerability"
+	CategoryMisconfiguration = "misconfiguration"
+	CategoryReliability      = "reliability"
+	CategoryAvailability     = "availability"
+)
+
+// commandCategories are the default categories of the findings of the commands.
+var commandCategories = map[string]string{
+	"analyze":    CategoryMisconfiguration,
+	"apiaudit":   CategoryMisconfiguration,
+	"audit":      CategoryMisconfiguration,
+	"datasafety": CategoryAvailability,
+	"diagnose":   CategoryReliability,
+	"dns":        CategoryReliability,
+	"jobs":       CategoryReliability,
+	"oom":        CategoryReliability,
+	"readiness":  CategoryAvailability,
+	"rebalance":  CategoryAvailability,
+}
+
+// ResourceRef references a Kubernetes object, or another target of the
+// workflows such as a Helm release.
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// String returns the reference as "<kind>/<namespace>/<name>", or "<kind>/<name>" for cluster-scoped objects.
+func (r ResourceRef) String() string {
+	if r.Namespace == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// ParseResourceRef parses a target in the "<kind>/<namespace>/<name>" or
+// "<kind>/<name>" format, returning nil for the other targets.
+func ParseResourceRef(target string) *ResourceRef {
+	parts := strings.SplitN(target, "/", 3)
+	if !resourceKindPattern.MatchString(parts[0]) {
+		return nil
+	}
+	switch {
+	case len(parts) == 2 && parts[1] != "":
+		return &ResourceRef{Kind: parts[0], Name: parts[1]}
+	case len(parts) == 3 && parts[1] != "" && parts[2] != "":
+		return &ResourceRef{Kind: parts[0], Namespace: parts[1], Name: parts[2]}
+	}
+	return nil
+}
+
+var (
+	findingTitlePattern    = regexp.MustCompile(`^#{2,3}\s+(?:\d+\.\s*)?(.+)$`)
+	findingFieldPattern    = regexp.MustCompile(`^[-*]\s+\*\*([^*]+)\*\*:?\s*(.*)$`)
+	findingSeverityPattern = regexp.MustCompile(`(?i)\b(critical|high|medium|low)\b`)
+	cvePattern             = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d+\b`)
+	nonSlugPattern         = regexp.MustCompile(`[^a-z0-9]+`)
+	severityPrefixPattern  = regexp.MustCompile(`(?i)\b(critical|high|medium|low)\s+severity:?\s*`)
+	resourceKindPattern    = regexp.MustCompile(`^[a-z]+$`)
+)
+
+// maxFindingIDLength bounds the length of the finding IDs.
+const maxFindingIDLength = 64
+
+// FindingID returns a stable ID for a finding title: the CVE ID for
+// vulnerabilities, or a slug of the title otherwise.
+func FindingID(title string) string {
+	if cve := cvePattern.FindString(title); cve != "" {
+		return strings.ToUpper(cve)
+	}
+	slug := nonSlugPattern.ReplaceAllString(strings.ToLower(severityPrefixPattern.ReplaceAllString(title, "")), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > maxFindingIDLength {
+		slug = strings.TrimRight(slug[:maxFindingIDLength], "-")
+	}
+	if slug == "" {
+		return "finding"
+	}
+	return slug
+}
+
+// ExtractFindings parses the findings of the report of a command about the
+// target, and completes them with their ID, their category (the category of
+// the command unless the report sets it, vulnerability for the CVEs) and the
+// resource of the target.
+func ExtractFindings(command, target, report string) []Finding {
+	findings := ParseFindings(report)
+	resource := ParseResourceRef(target)
+	for i := range findings {
+		f := &findings[i]
+		f.ID = FindingID(f.Title)
+		if f.Category == "" {
+			if cvePattern.MatchString(f.Title) {
+				f.Category = CategoryVulnerability
+			} else {
+				f.Category = commandCategories[command]
+			}
+		}
+		if f.Resource == nil && resource != nil {
+			ref := *resource
+			f.Resource = &ref
+		}
+	}
+	return findings
+}
+
+// ParseFindings extracts the findings from a markdown report in the format
+// requested by the workflow prompts:
+//
+//	## 1. <title of the issue>
+//
+//	- **Severity**: <severity>
+//	- **Category**: <category>
+//	- **Resource**: <kind>/<namespace>/<name>
+//	- **Findings**: <description>
+//	- **Evidence**: <evidence>
+//	- **How to resolve**: <remediation>
+//
+// The sections without findings, evidence or remediation are skipped, and
+// the other fields are optional.
+func ParseFindings(report string) []Finding {
+	var findings []Finding
+	var current *Finding
+	var field *string
+
+	for _, line := range strings.Split(report, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if matches := findingTitlePattern.FindStringSubmatch(trimmed); matches != nil {
+			findings = append(findings, Finding{Title: strings.TrimSpace(matches[1])})
+			current = &findings[len(findings)-1]
+			current.Severity = parseSeverity(current.Title)
+			field = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if trimmed == "---" {
+			// A thematic break ends the field, e.g. before the footer of the answer.
+			field = nil
+			continue
+		}
+
+		if matches := findingFieldPattern.FindStringSubmatch(trimmed); matches != nil {
+			name := strings.ToLower(strings.TrimSpace(matches[1]))
+			value := strings.TrimSpace(matches[2])
+			switch {
+			case strings.HasPrefix(name, "finding"):
+				field = &current.Description
+			case strings.HasPrefix(name, "how to resolve"), strings.HasPrefix(name, "solution"), strings.HasPrefix(name, "remediation"):
+				field = &current.Remediation
+			case strings.HasPrefix(name, "evidence"):
+				field = &current.Evidence
+			case strings.HasPrefix(name, "severity"):
+				current.Severity = parseSeverity(value)
+				field = nil
+				continue
+			case strings.HasPrefix(name, "category"):
+				current.Category = strings.ToLower(strings.Trim(value, "` "))
+				field = nil
+				continue
+			case strings.HasPrefix(name, "resource"):
+				current.Resource = ParseResourceRef(strings.Trim(value, "` "))
+				field = nil
+				continue
+			default:
+				field = nil
+				continue
+			}
+			*field = value
+			continue
+		}
+
+		// Continuation lines of a multi-line field.
+		if field != nil && trimmed != "" {
+			*field = strings.TrimSpace(*field + "\n" + trimmed)
+		}
+	}
+
+	// Sections without any finding details are headings rather than issues.
+	result := findings[:0]
+	for _, f := range findings {
+		if f.Description != "" || f.Evidence != "" || f.Remediation != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// severityRanks orders the severities from the least to the most severe.
+var severityRanks = map[string]int{
+	"LOW":      1,
+	"MEDIUM":   2,
+	"HIGH":     3,
+	"CRITICAL": 4,
+}
+
+// ValidSeverity returns true if s is one of CRITICAL, HIGH, MEDIUM or LOW (case-insensitive).
+func ValidSeverity(s string) bool {
+	_, ok := severityRanks[strings.ToUpper(s)]
+	return ok
+}
+
+// FindingsAtOrAbove returns the findings with a severity at or above the
+// threshold. Findings without a severity are considered LOW.
+func FindingsAtOrAbove(findings []Finding, threshold string) []Finding {
+	minRank := severityRanks[strings.ToUpper(threshold)]
+	var result []Finding
+	for _, f := range findings {
+		rank, ok := severityRanks[f.Severity]
+		if !ok {
+			rank = severityRanks["LOW"]
+		}
+		if rank >= minRank {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// parseSeverity returns the upper-cased severity mentioned in s, or "" if none.
+func parseSeverity(s string) string {
+	if matches := findingSeverityPattern.FindStringSubmatch(s); matches != nil {
+		return strings.ToUpper(matches[1])
+	}
+	return ""
+}
+
+// Summarize returns a one-line summary of a report of at most maxLength
+// bytes: the titles of its findings, or its first line if it has none.
+func Summarize(report string, maxLength int) string {
+	var titles []string
+	for _, f := range ParseFindings(report) {
+		titles = append(titles, f.Title)
+	}
+
+	summary := strings.Join(titles, "; ")
+	if summary == "" {
+		summary = strings.TrimSpace(strings.SplitN(strings.TrimSpace(report), "\n", 2)[0])
+		summary = strings.TrimLeft(summary, "# ")
+	}
+	if maxLength > 3 && len(summary) > maxLength {
+		summary = summary[:maxLength-3] + "..."
+	}
+	return summary
+}
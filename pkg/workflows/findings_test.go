@@ -0,0 +1,228 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []Finding
+	}{
+		{
+			name:   "no findings",
+			report: "The Pod is healthy.",
+			want:   []Finding{},
+		},
+		{
+			name: "audit report",
+			report: `# Audit report
+
+## 1. Missing memory limit
+
+- **Findings**: The YAML configuration doesn't specify the memory limit for the pod.
+- **How to resolve**: Set memory limit in Pod spec.
+
+## 2. HIGH Severity: CVE-2024-10963
+
+- **Findings**: The Pod is running with CVE pam.
+  It leads to access control bypass.
+- **How to resolve**: Update package libpam-modules.
+`,
+			want: []Finding{
+				{
+					Title:       "Missing memory limit",
+					Description: "The YAML configuration doesn't specify the memory limit for the pod.",
+					Remediation: "Set memory limit in Pod spec.",
+				},
+				{
+					Title:       "HIGH Severity: CVE-2024-10963",
+					Severity:    "HIGH",
+					Description: "The Pod is running with CVE pam.\nIt leads to access control bypass.",
+					Remediation: "Update package libpam-modules.",
+				},
+			},
+		},
+		{
+			name: "explicit severity field",
+			report: `### Privileged container
+- **Severity**: Critical
+- **Findings**: privileged is set to true.
+- **Solution**: Drop privileged.`,
+			want: []Finding{
+				{
+					Title:       "Privileged container",
+					Severity:    "CRITICAL",
+					Description: "privileged is set to true.",
+					Remediation: "Drop privileged.",
+				},
+			},
+		},
+		{
+			name: "report with a footer",
+			report: `## 1. Missing memory limit
+
+- **Findings**: The Pod has no memory limit.
+- **How to resolve**: Set memory limit in Pod spec.
+
+---
+
+**Commands executed:**
+
+- ` + "`kubectl get pod nginx -o yaml`",
+			want: []Finding{
+				{
+					Title:       "Missing memory limit",
+					Description: "The Pod has no memory limit.",
+					Remediation: "Set memory limit in Pod spec.",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseFindings(tt.report); !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+				t.Errorf("ParseFindings() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractFindings(t *testing.T) {
+	report := `## 1. Missing memory limit
+
+- **Severity**: MEDIUM
+- **Findings**: The memory limit is not set.
+- **Evidence**: spec.containers[0].resources has no limits.
+- **How to resolve**: Set the memory limit.
+
+## 2. HIGH Severity: CVE-2024-10963
+
+- **Findings**: The image has a vulnerable pam.
+
+## 3. Exposed dashboard
+
+- **Category**: Exposure
+- **Resource**: service/monitoring/grafana
+- **Findings**: The Service is of type LoadBalancer.
+`
+	pod := &ResourceRef{Kind: "pod", Namespace: "web", Name: "web-1"}
+	want := []Finding{
+		{
+			ID:          "missing-memory-limit",
+			Title:       "Missing memory limit",
+			Severity:    "MEDIUM",
+			Category:    CategoryMisconfiguration,
+			Resource:    pod,
+			Evidence:    "spec.containers[0].resources has no limits.",
+			Description: "The memory limit is not set.",
+			Remediation: "Set the memory limit.",
+		},
+		{
+			ID:          "CVE-2024-10963",
+			Title:       "HIGH Severity: CVE-2024-10963",
+			Severity:    "HIGH",
+			Category:    CategoryVulnerability,
+			Resource:    pod,
+			Description: "The image has a vulnerable pam.",
+		},
+		{
+			ID:          "exposed-dashboard",
+			Title:       "Exposed dashboard",
+			Category:    "exposure",
+			Resource:    &ResourceRef{Kind: "service", Namespace: "monitoring", Name: "grafana"},
+			Description: "The Service is of type LoadBalancer.",
+		},
+	}
+	if got := ExtractFindings("audit", "pod/web/web-1", report); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFindings() = %#v, want %#v", got, want)
+	}
+
+	got := ExtractFindings("execute", "manifests.yaml", report)
+	if got[0].Category != "" || got[0].Resource != nil {
+		t.Errorf("ExtractFindings() of an unknown command and target = %#v, want no category nor resource", got[0])
+	}
+}
+
+func TestParseResourceRef(t *testing.T) {
+	tests := []struct {
+		target string
+		want   *ResourceRef
+	}{
+		{target: "pod/default/nginx", want: &ResourceRef{Kind: "pod", Namespace: "default", Name: "nginx"}},
+		{target: "namespace/payments", want: &ResourceRef{Kind: "namespace", Name: "payments"}},
+		{target: "release/web/frontend", want: &ResourceRef{Kind: "release", Namespace: "web", Name: "frontend"}},
+		{target: "cluster"},
+		{target: "./manifests/app.yaml"},
+		{target: "pod//nginx"},
+		{target: ""},
+	}
+	for _, tt := range tests {
+		got := ParseResourceRef(tt.target)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResourceRef(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+		if got != nil && got.String() != tt.target {
+			t.Errorf("ParseResourceRef(%q).String() = %q", tt.target, got.String())
+		}
+	}
+}
+
+func TestFindingsAtOrAbove(t *testing.T) {
+	findings := []Finding{
+		{Title: "a", Severity: "CRITICAL"},
+		{Title: "b", Severity: "HIGH"},
+		{Title: "c", Severity: "MEDIUM"},
+		{Title: "d"},
+	}
+	tests := []struct {
+		threshold string
+		want      int
+	}{
+		{threshold: "critical", want: 1},
+		{threshold: "HIGH", want: 2},
+		{threshold: "MEDIUM", want: 3},
+		{threshold: "LOW", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.threshold, func(t *testing.T) {
+			if got := FindingsAtOrAbove(findings, tt.threshold); len(got) != tt.want {
+				t.Errorf("FindingsAtOrAbove(%s) = %v, want %d findings", tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		report string
+		want   string
+	}{
+		{report: "# The Pod is healthy\n\nAll checks passed.", want: "The Pod is healthy"},
+		{report: "## 1. A\n- **Findings**: a\n## 2. B\n- **Findings**: b", want: "A; B"},
+		{report: strings.Repeat("x", 100), want: strings.Repeat("x", 77) + "..."},
+	}
+	for _, tt := range tests {
+		if got := Summarize(tt.report, 80); got != tt.want {
+			t.Errorf("Summarize(%q) = %q, want %q", tt.report, got, tt.want)
+		}
+	}
+}
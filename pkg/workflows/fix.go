@@ -0,0 +1,81 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/swarm-go"
+)
+
+const fixPrompt = `Turn the fix proposed by the diagnosis of {target} into Kubernetes manifests that can be applied to the cluster.
+
+# Steps
+
+1. **Read the Diagnosis**: Identify the changes of the "How to resolve" sections that can be applied as Kubernetes objects (e.g. a fixed image tag, resource requests and limits, probes, environment variables or a missing ConfigMap key). Skip the steps that need a human (e.g. rebuilding an image or changing the cloud infrastructure).
+2. **Fetch the Live Objects**: Use kubectl (read-only commands only, never change the cluster) to get the current manifest of each object to change (e.g. "kubectl get deployment <name> -n <namespace> -o yaml"). Change the owner workload rather than its Pods or ReplicaSets.
+3. **Write the Manifests**: Write the complete manifest of each changed or new object with the fix applied, keeping all its other fields unchanged. Remove the status and the server-set metadata (uid, resourceVersion, generation, creationTimestamp and managedFields).
+
+# Output Format
+
+Only the manifests in a single yaml code block, separated by "---". If no change can be applied automatically, reply with an empty yaml code block.
+
+# Diagnosis
+
+{diagnosis}
+`
+
+// FixFlow runs a workflow turning the fix proposed by a diagnosis of target
+// into the manifests to apply. It returns "" when the fix can't be applied
+// automatically. The drafting only reads the cluster: the manifests are
+// applied by the caller, after the snapshot of the objects and the approval.
+func FixFlow(model string, target string, diagnosis string, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "fix-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to apply the fixes of their diagnoses safely.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "fix",
+				Instructions: prompt("fix"),
+				Inputs: map[string]interface{}{
+					"target":    target,
+					"diagnosis": diagnosis,
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, _, err := runTracedFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(result, "```") {
+		result = utils.ExtractYaml(result)
+	}
+	return strings.TrimSpace(result), nil
+}
@@ -17,8 +17,6 @@ package workflows
 
 import (
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/feiskyer/swarm-go"
 )
@@ -63,7 +61,7 @@ func GeneratorFlow(model string, instructions string, verbose bool) (string, err
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "generator",
-				Instructions: generatePrompt,
+				Instructions: prompt("generate"),
 				Inputs: map[string]interface{}{
 					"instructions": instructions,
 				},
@@ -74,13 +72,12 @@ func GeneratorFlow(model string, instructions string, verbose bool) (string, err
 	// Create OpenAI client
 	client, err := NewSwarm()
 	if err != nil {
-		fmt.Printf("Failed to create client: %v\n", err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Initialize and run workflow
 	generatorWorkflow.Initialize()
-	result, _, err := generatorWorkflow.Run(context.Background(), client)
+	result, _, err := runTracedFlow(context.Background(), generatorWorkflow, client)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/feiskyer/swarm-go"
+)
+
+var (
+	// imagePattern matches the image references with a tag or a digest, e.g. nginx:1.25.3.
+	imagePattern = regexp.MustCompile(`\b[a-z0-9][a-z0-9._/-]*:[a-zA-Z0-9_][a-zA-Z0-9_.-]*`)
+	// podNamePattern matches the generated Pod names, whose random suffix has no vowels, e.g. nginx-7c5ddbdf54-x8z2q.
+	podNamePattern = regexp.MustCompile(`\b[a-z0-9](?:[a-z0-9-]*[a-z0-9])?-[bcdfghjklmnpqrstvwxz2456789]{5}\b`)
+	countPattern   = regexp.MustCompile(`(?i)\b(\d+)\s+(?:restarts?|pods?|containers?|replicas?|nodes?|times|vulnerabilit(?:y|ies)|CVEs)\b`)
+	digitPattern   = regexp.MustCompile(`\d`)
+	numberPattern  = regexp.MustCompile(`^\d+$`)
+)
+
+// ungroundedClaims returns the concrete values of the answer (CVE IDs, image
+// tags, Pod names and counts) that appear in none of the observations.
+// The code blocks are skipped since they hold the suggested commands and
+// manifests rather than the state of the cluster.
+func ungroundedClaims(answer string, observations []string) []string {
+	data := strings.ToLower(strings.Join(observations, "\n"))
+	text := stripCodeBlocks(answer)
+
+	var claims []string
+	seen := map[string]bool{}
+	check := func(claim string, grounded bool) {
+		if !grounded && !seen[claim] {
+			seen[claim] = true
+			claims = append(claims, claim)
+		}
+	}
+
+	for _, cve := range cvePattern.FindAllString(text, -1) {
+		check(strings.ToUpper(cve), strings.Contains(data, strings.ToLower(cve)))
+	}
+	for _, image := range imagePattern.FindAllString(text, -1) {
+		image = strings.TrimRight(image, ".-_")
+		repo, tag, _ := strings.Cut(image, ":")
+		// Ports, times and tags without a version (e.g. latest) aren't checked.
+		if numberPattern.MatchString(tag) || !digitPattern.MatchString(tag) || numberPattern.MatchString(strings.ReplaceAll(repo, ".", "")) {
+			continue
+		}
+		check(image, strings.Contains(data, strings.ToLower(image)))
+	}
+	for _, pod := range podNamePattern.FindAllString(text, -1) {
+		if numberPattern.MatchString(pod[len(pod)-5:]) {
+			// e.g. the year and the number of a CVE ID.
+			continue
+		}
+		check(pod, strings.Contains(data, pod))
+	}
+	for _, m := range countPattern.FindAllStringSubmatch(text, -1) {
+		number := regexp.MustCompile(`\b` + m[1] + `\b`)
+		check(m[0], number.MatchString(data))
+	}
+	return claims
+}
+
+// stripCodeBlocks removes the fenced code blocks of a markdown text.
+func stripCodeBlocks(text string) string {
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flagClaims appends a note listing the ungrounded claims to the answer.
+func flagClaims(answer string, claims []string) string {
+	quoted := make([]string, len(claims))
+	for i, claim := range claims {
+		quoted[i] = "`" + strings.ReplaceAll(claim, "`", "'") + "`"
+	}
+	return fmt.Sprintf("%s\n\n---\n\n> **Unverified**: %s not found in the data collected during this run. Check them before relying on them.",
+		strings.TrimSpace(answer), strings.Join(quoted, ", "))
+}
+
+// verifyAnswer flags the claims of the answer which are not grounded in the
+// observations of the run. With regenerate, the model is first asked to
+// rewrite the answer without them.
+func verifyAnswer(answer string, ac answerContext, regenerate bool) (string, error) {
+	if len(ac.observations) == 0 {
+		return answer, nil
+	}
+
+	claims := ungroundedClaims(answer, ac.observations)
+	if len(claims) > 0 && regenerate && ac.client != nil {
+		if regenerated, err := regenerateAnswer(answer, claims, ac); err == nil {
+			answer = regenerated
+			claims = ungroundedClaims(answer, ac.observations)
+		}
+	}
+	if len(claims) == 0 {
+		return answer, nil
+	}
+
+	recordUsage(func(u *Usage) { u.UngroundedClaims += int64(len(claims)) })
+	return flagClaims(answer, claims), nil
+}
+
+// maxGroundingData is the size of the observations handed to the model when regenerating an answer.
+const maxGroundingData = 32 * 1024
+
+const regeneratePrompt = `The answer below states values which do not appear in the data collected from the cluster: {claims}.
+
+Rewrite the answer so that every pod name, image, CVE ID and count it states comes from the data. Correct the unsupported values from the data, or remove the statements that the data doesn't support. Never invent example values. Keep the structure and the format of the answer, and reply with the rewritten answer only.
+
+# Data
+
+{data}
+
+# Answer
+
+{answer}
+`
+
+// regenerateAnswer asks the model to rewrite the answer without the ungrounded claims.
+func regenerateAnswer(answer string, claims []string, ac answerContext) (string, error) {
+	data := strings.Join(ac.observations, "\n\n")
+	if len(data) > maxGroundingData {
+		data = data[:maxGroundingData]
+	}
+
+	flow := &swarm.SimpleFlow{
+		Name:     "grounding-workflow",
+		Model:    ac.model,
+		MaxTurns: 1,
+		System:   "You are an expert on Kubernetes reviewing the troubleshooting reports for facts not supported by the data.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "regenerate",
+				Instructions: regeneratePrompt,
+				Inputs: map[string]interface{}{
+					"claims": strings.Join(claims, ", "),
+					"data":   data,
+					"answer": answer,
+				},
+			},
+		},
+	}
+	flow.Initialize()
+	result, _, err := runTracedFlow(ac.ctx, flow, ac.client)
+	if err != nil {
+		return answer, err
+	}
+	if result = strings.TrimSpace(result); result == "" {
+		return answer, fmt.Errorf("empty answer")
+	}
+	return result, nil
+}
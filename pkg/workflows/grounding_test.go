@@ -0,0 +1,81 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUngroundedClaims(t *testing.T) {
+	observations := []string{
+		"NAME                     READY   STATUS             RESTARTS   AGE\nnginx-7c5ddbdf54-x8z2q   0/1     CrashLoopBackOff   12         1h",
+		"Image: docker.io/library/nginx:1.25.3\nCVE-2024-10963 HIGH libpam-modules",
+	}
+
+	tests := []struct {
+		name   string
+		answer string
+		want   []string
+	}{
+		{
+			name:   "grounded",
+			answer: "Pod `nginx-7c5ddbdf54-x8z2q` restarted 12 times with image nginx:1.25.3, affected by cve-2024-10963.",
+		},
+		{
+			name:   "hallucinated values",
+			answer: "Pod nginx-7c5ddbdf54-q7wtd runs redis:7.2.4 affected by CVE-2023-44487 and has 7 restarts.",
+			want:   []string{"CVE-2023-44487", "redis:7.2.4", "nginx-7c5ddbdf54-q7wtd", "7 restarts"},
+		},
+		{
+			name:   "ports, times and untagged images",
+			answer: "The service listens on 10.0.0.1:8080 since 12:30pm and runs nginx:latest. See https://kubernetes.io.",
+		},
+		{
+			name:   "code blocks skipped",
+			answer: "Upgrade the image:\n\n```sh\nkubectl set image deployment/nginx nginx=nginx:1.27.0\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ungroundedClaims(tt.answer, observations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ungroundedClaims() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAnswer(t *testing.T) {
+	ac := answerContext{ctx: context.Background(), observations: []string{"nginx-7c5ddbdf54-x8z2q 0/1 Pending"}}
+
+	got, err := verifyAnswer("The Pod nginx-7c5ddbdf54-x8z2q is pending.", ac, false)
+	if err != nil || got != "The Pod nginx-7c5ddbdf54-x8z2q is pending." {
+		t.Errorf("verifyAnswer() = %q, %v, want the answer unchanged", got, err)
+	}
+
+	got, err = verifyAnswer("The Pod web-5f8b9c7d4-h7kqz is pending.", ac, true)
+	if err != nil || !strings.HasSuffix(got, "---\n\n> **Unverified**: `web-5f8b9c7d4-h7kqz` not found in the data collected during this run. Check them before relying on them.") {
+		t.Errorf("verifyAnswer() = %q, %v, want the claim flagged", got, err)
+	}
+
+	// Nothing is verified without observations.
+	if got, _ := verifyAnswer("The Pod web-5f8b9c7d4-h7kqz is pending.", answerContext{}, false); got != "The Pod web-5f8b9c7d4-h7kqz is pending." {
+		t.Errorf("verifyAnswer() = %q, want the answer unchanged", got)
+	}
+}
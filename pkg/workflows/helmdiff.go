@@ -0,0 +1,95 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/helmdiff"
+	"github.com/feiskyer/swarm-go"
+)
+
+// maxHelmDiffLength is the maximum length of the manifest diff sent to the LLM.
+const maxHelmDiffLength = 60000
+
+const helmDiffPrompt = `As an expert on Kubernetes and Helm, explain the operational impact of the change of Helm release {release} in namespace {namespace} from {from} to {to}, given the diff of the rendered manifests.
+
+# Steps
+
+1. **Understand the Change**: Summarize what changes for each object (lines starting with "-" are removed, "+" added).
+2. **Assess the Impact**: Explain what happens in the cluster when the change is applied, for example:
+   - Pod template changes (image, env, resources, probes, annotations) trigger a rolling restart of the workload.
+   - Changes of immutable fields (e.g. Deployment selectors, StatefulSet volumeClaimTemplates, Job templates, Service clusterIP) make the upgrade fail unless the objects are recreated.
+   - Removed objects are deleted, e.g. PersistentVolumeClaims with their data, or Services and Ingresses serving traffic.
+   - Service type or port changes may allocate a new load balancer IP or break clients.
+   - RBAC, NetworkPolicy, PodDisruptionBudget, replica and resource changes alter the permissions, connectivity, availability and capacity.
+3. **Check the Cluster**: Use kubectl when the impact depends on the live state (e.g. whether a removed PVC is bound, or whether the new resource requests fit the Nodes).
+
+# Output Format
+
+Start with a plain-language summary of the change and whether it is safe to apply, followed by one section per risk:
+
+## 1. <title of the risk>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <the objects and fields changed, and their impact>
+- **How to resolve**: <how to apply the change safely, e.g. recreate the object, scale first, or keep the old value>
+
+If there is no change, say so.
+`
+
+// HelmDiffFlow explains the operational impact of the changes between two versions of a Helm release.
+func HelmDiffFlow(model string, release string, namespace string, from helmdiff.Source, to helmdiff.Source, changes []helmdiff.Change, verbose bool) (string, error) {
+	diff := helmdiff.Format(changes)
+	if len(diff) > maxHelmDiffLength {
+		diff = diff[:maxHelmDiffLength] + fmt.Sprintf("\n\n(the diff is truncated, %d objects changed in total)", len(changes))
+	}
+
+	flow := &swarm.SimpleFlow{
+		Name:     "helmdiff-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to review the changes of Helm releases.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "helmdiff",
+				Instructions: prompt("helmdiff"),
+				Inputs: map[string]interface{}{
+					"release":   release,
+					"namespace": namespace,
+					"from":      from.String(),
+					"to":        to.String(),
+					"diff":      diff,
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// ScanWorkers is the number of images scanned concurrently by the batch audits.
+var ScanWorkers = 4
+
+// ImageScan is the vulnerability scan of a container image.
+type ImageScan struct {
+	Image   string
+	Summary string
+	Error   error
+}
+
+// ScanImages scans the distinct images with a pool of workers and returns
+// their scans in the order of the images. The first image is scanned alone,
+// so that the vulnerability database is downloaded or updated only once.
+func ScanImages(images []string, workers int) []ImageScan {
+	var distinct []string
+	seen := map[string]bool{}
+	for _, image := range images {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			distinct = append(distinct, image)
+		}
+	}
+	if len(distinct) == 0 {
+		return nil
+	}
+
+	scans := make([]ImageScan, len(distinct))
+	scan := func(i int) {
+		recordUsage(func(u *Usage) { u.ToolCalls++ })
+		summary, err := tools.CopilotTools["trivy"](distinct[i])
+		scans[i] = ImageScan{Image: distinct[i], Summary: summary, Error: err}
+	}
+	scan(0)
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(distinct)-1; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scan(i)
+			}
+		}()
+	}
+	for i := 1; i < len(distinct); i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return scans
+}
+
+// formatImageScans formats the successful scans of the given images for the
+// prompt, or returns an empty string if none of them was scanned.
+func formatImageScans(images []string, scans map[string]ImageScan) string {
+	var parts []string
+	for _, image := range images {
+		if scan, ok := scans[image]; ok && scan.Error == nil {
+			parts = append(parts, scan.Summary)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+func TestScanImages(t *testing.T) {
+	original := tools.CopilotTools["trivy"]
+	defer func() { tools.CopilotTools["trivy"] = original }()
+
+	var lock sync.Mutex
+	var scanned []string
+	running, maxRunning := 0, 0
+	tools.CopilotTools["trivy"] = func(image string) (string, error) {
+		lock.Lock()
+		scanned = append(scanned, image)
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if image == "broken" {
+			return "", errors.New("scan failed")
+		}
+		return "Image " + image + ": no vulnerabilities found.", nil
+	}
+
+	images := []string{"nginx:1.25", "redis:7", "nginx:1.25", "broken", "busybox", "", "redis:7", "envoy:1.30"}
+	scans := ScanImages(images, 2)
+
+	var got []string
+	byImage := map[string]ImageScan{}
+	for _, scan := range scans {
+		got = append(got, scan.Image)
+		byImage[scan.Image] = scan
+	}
+	if want := []string{"nginx:1.25", "redis:7", "broken", "busybox", "envoy:1.30"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanImages() images = %v, want %v", got, want)
+	}
+	if len(scanned) != 5 || scanned[0] != "nginx:1.25" {
+		t.Errorf("scanned = %v, want each image once, the first one first", scanned)
+	}
+	if maxRunning != 2 {
+		t.Errorf("max concurrent scans = %d, want 2", maxRunning)
+	}
+	if byImage["broken"].Error == nil {
+		t.Errorf("ScanImages() error of broken = nil")
+	}
+
+	tests := []struct {
+		images []string
+		want   string
+	}{
+		{images: []string{"nginx:1.25", "broken", "redis:7"}, want: "Image nginx:1.25: no vulnerabilities found.\n\nImage redis:7: no vulnerabilities found."},
+		{images: []string{"broken", "unknown"}, want: ""},
+		{images: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := formatImageScans(tt.images, byImage); got != tt.want {
+			t.Errorf("formatImageScans(%v) = %q, want %q", tt.images, got, tt.want)
+		}
+	}
+
+	if scans := ScanImages(nil, 2); scans != nil {
+		t.Errorf("ScanImages(nil) = %v, want nil", scans)
+	}
+}
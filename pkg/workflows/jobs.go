@@ -0,0 +1,83 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"fmt"
+	"strings"
+)
+
+const jobsPrompt = `Explain why the scheduled work of %s isn't running or failing.
+
+# Steps
+
+1. **CronJob Schedule**: For CronJobs, check the schedule and timeZone, suspend, lastScheduleTime and lastSuccessfulTime, and compare them with the current time ("date -u") to find the missed schedules. A startingDeadlineSeconds too short, or more than 100 missed schedules, stops the controller from creating new Jobs.
+2. **Concurrency Policy**: Check the concurrencyPolicy: with Forbid, a Job still running (or stuck) skips the next schedules; with Replace, long-running Jobs are killed by the next schedule.
+3. **Job History**: List the Jobs owned by the CronJob ("kubectl get jobs -n <namespace> -o wide" and their ownerReferences), their conditions (Complete, Failed, Suspended), start and completion times, and successfulJobsHistoryLimit/failedJobsHistoryLimit.
+4. **Backoff and Deadlines**: For the failed Jobs, check the failure reason (BackoffLimitExceeded, DeadlineExceeded, PodFailurePolicy), backoffLimit, activeDeadlineSeconds, ttlSecondsAfterFinished, parallelism, completions and podFailurePolicy.
+5. **Failed Pods**: Find the Pods of the failed Jobs ("kubectl get pods -n <namespace> -l job-name=<job>"), their status, exit codes, termination reasons and events, and fetch their logs (including "--previous" for restarted containers). Check restartPolicy, image pull errors, missing ConfigMaps/Secrets, resource quotas and scheduling failures.
+6. **Controller Events**: Check the events of the CronJob and Jobs (e.g. "FailedCreate", "MissSchedule", "TooManyMissedTimes", "SawCompletedJob").
+
+# Output Format
+
+Explain the timeline of the recent runs (scheduled, started, finished or failed), then provide one section per issue:
+
+## 1. <title of the issue>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <what is wrong, with the evidence (e.g. Job condition, exit code, event message, log line)>
+- **How to resolve**: <step-by-step fix>
+
+If the scheduled work runs as expected, say so and summarize the checks performed.
+`
+
+// ParseJobRef parses a CronJob or Job reference such as "cronjob/backup",
+// "job/backup-28903680" or "backup" (a CronJob). An empty reference means
+// all the CronJobs and Jobs of the namespace.
+func ParseJobRef(ref string) (kind string, name string, err error) {
+	if ref == "" {
+		return "", "", nil
+	}
+
+	kind, name, found := strings.Cut(ref, "/")
+	if !found {
+		return "CronJob", ref, nil
+	}
+	if name == "" {
+		return "", "", fmt.Errorf("invalid reference %q: missing name", ref)
+	}
+	switch strings.ToLower(kind) {
+	case "cronjob", "cronjobs", "cj":
+		return "CronJob", name, nil
+	case "job", "jobs":
+		return "Job", name, nil
+	default:
+		return "", "", fmt.Errorf("invalid reference %q: kind must be cronjob or job", ref)
+	}
+}
+
+// jobsTarget describes the CronJob or Job to troubleshoot.
+func jobsTarget(namespace string, kind string, name string) string {
+	if name == "" {
+		return fmt.Sprintf("the CronJobs and Jobs in namespace %s", namespace)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", kind, name, namespace)
+}
+
+// NewJobsFlow creates a ReAct workflow to diagnose the failures of a CronJob or Job.
+func NewJobsFlow(model string, namespace string, kind string, name string, verbose bool, maxIterations int) (*ReActFlow, error) {
+	return NewReActFlow(model, fmt.Sprintf(prompt("jobs"), jobsTarget(namespace, kind, name)), verbose, maxIterations)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import "testing"
+
+func TestParseJobRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{ref: ""},
+		{ref: "backup", wantKind: "CronJob", wantName: "backup"},
+		{ref: "cj/backup", wantKind: "CronJob", wantName: "backup"},
+		{ref: "CronJob/backup", wantKind: "CronJob", wantName: "backup"},
+		{ref: "job/backup-28903680", wantKind: "Job", wantName: "backup-28903680"},
+		{ref: "job/", wantErr: true},
+		{ref: "deployment/web", wantErr: true},
+	}
+	for _, tt := range tests {
+		kind, name, err := ParseJobRef(tt.ref)
+		if (err != nil) != tt.wantErr || kind != tt.wantKind || name != tt.wantName {
+			t.Errorf("ParseJobRef(%q) = %q, %q, %v, want %q, %q (error: %v)", tt.ref, kind, name, err, tt.wantKind, tt.wantName, tt.wantErr)
+		}
+	}
+}
+
+func TestJobsTarget(t *testing.T) {
+	if got, want := jobsTarget("ops", "CronJob", "backup"), "CronJob backup in namespace ops"; got != want {
+		t.Errorf("jobsTarget() = %q, want %q", got, want)
+	}
+	if got, want := jobsTarget("ops", "", ""), "the CronJobs and Jobs in namespace ops"; got != want {
+		t.Errorf("jobsTarget() = %q, want %q", got, want)
+	}
+}
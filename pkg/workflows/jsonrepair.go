@@ -0,0 +1,215 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+)
+
+var jsonFencePattern = regexp.MustCompile("(?s)```(?:json)?\\s*(.*?)\\s*```")
+
+// unmarshalLLMJSON parses a JSON response of the LLM into v, repairing the
+// common mistakes of LLM outputs if it isn't valid JSON.
+func unmarshalLLMJSON(response string, v interface{}) error {
+	err := json.Unmarshal([]byte(response), v)
+	if err == nil {
+		return nil
+	}
+	if json.Unmarshal([]byte(repairJSON(response)), v) == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", errdefs.ErrJSONParse, err)
+}
+
+// repairJSON turns a malformed JSON value produced by an LLM into valid JSON.
+// It tokenizes the input instead of rewriting it with regular expressions, so
+// that valid strings (including escaped quotes) are never changed. It handles:
+//
+//   - markdown code fences and text around the JSON value
+//   - raw newlines, tabs and control characters inside strings
+//   - unescaped quotes inside strings and invalid escape sequences
+//   - trailing commas, comments and Python literals (True, False, None)
+//   - truncated output (unterminated strings, objects and arrays)
+func repairJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if matches := jsonFencePattern.FindStringSubmatch(s); matches != nil {
+		s = matches[1]
+	}
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return s
+	}
+	s = s[start:]
+
+	var out []byte
+	var stack []byte
+	inString, isKey, expectKey := false, false, false
+
+scan:
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case c == '\\':
+				switch {
+				case i+1 < len(s) && strings.IndexByte(`"\/bfnrtu`, s[i+1]) >= 0:
+					out = append(out, c, s[i+1])
+					i++
+				case i+1 < len(s) && s[i+1] == '\'':
+					out = append(out, '\'')
+					i++
+				default:
+					out = append(out, `\\`...)
+				}
+			case c == '"':
+				if closesString(s[i+1:], isKey) {
+					out = append(out, c)
+					inString = false
+				} else {
+					out = append(out, `\"`...)
+				}
+			case c == '\n':
+				out = append(out, `\n`...)
+			case c == '\r':
+				out = append(out, `\r`...)
+			case c == '\t':
+				out = append(out, `\t`...)
+			case c < 0x20:
+				out = append(out, fmt.Sprintf(`\u%04x`, c)...)
+			default:
+				out = append(out, c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			isKey = expectKey && len(stack) > 0 && stack[len(stack)-1] == '{'
+			out = append(out, c)
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+			expectKey = c == '{'
+			out = append(out, c)
+		case c == '}' || c == ']':
+			out = trimTrailingComma(out)
+			if len(stack) == 0 {
+				break scan
+			}
+			out = append(out, closerOf(stack[len(stack)-1]))
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				// Drop the text after the JSON value.
+				break scan
+			}
+		case c == ',':
+			out = append(out, c)
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+		case c == ':':
+			out = append(out, c)
+			expectKey = false
+		case c == '/' && i+1 < len(s) && s[i+1] == '/':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				break scan
+			}
+			i += end + 3
+		case isLetter(c):
+			j := i
+			for j < len(s) && isLetter(s[j]) {
+				j++
+			}
+			switch word := s[i:j]; word {
+			case "True":
+				out = append(out, "true"...)
+			case "False":
+				out = append(out, "false"...)
+			case "None":
+				out = append(out, "null"...)
+			default:
+				out = append(out, word...)
+			}
+			i = j - 1
+		default:
+			out = append(out, c)
+		}
+	}
+
+	// Complete a truncated value.
+	if inString {
+		out = append(out, '"')
+	}
+	out = trimTrailingComma(out)
+	if trimmed := strings.TrimSpace(string(out)); strings.HasSuffix(trimmed, ":") {
+		out = append([]byte(trimmed), " null"...)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		out = append(out, closerOf(stack[i]))
+	}
+	return string(out)
+}
+
+// closesString returns true if a quote followed by rest terminates the current string.
+func closesString(rest string, isKey bool) bool {
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	if rest == "" {
+		return true
+	}
+	if isKey {
+		return rest[0] == ':'
+	}
+
+	switch rest[0] {
+	case '}', ']':
+		return true
+	case ',':
+		next := strings.TrimLeft(rest[1:], " \t\r\n")
+		if next == "" || strings.IndexByte(`"{}[]-0123456789`, next[0]) >= 0 {
+			return true
+		}
+		return strings.HasPrefix(next, "true") || strings.HasPrefix(next, "false") || strings.HasPrefix(next, "null")
+	}
+	return false
+}
+
+// trimTrailingComma removes a trailing comma (and the whitespace after it).
+func trimTrailingComma(out []byte) []byte {
+	trimmed := strings.TrimRight(string(out), " \t\r\n")
+	if strings.HasSuffix(trimmed, ",") {
+		return []byte(strings.TrimSuffix(trimmed, ","))
+	}
+	return out
+}
+
+func closerOf(opener byte) byte {
+	if opener == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "valid json is unchanged",
+			input: `{"thought": "Run \"kubectl get pods\" first", "steps": [{"name": "a\\b"}]}`,
+			want:  `{"thought": "Run \"kubectl get pods\" first", "steps": [{"name": "a\\b"}]}`,
+		},
+		{
+			name:  "markdown code fence",
+			input: "Here is the plan:\n```json\n{\"question\": \"why\"}\n```\nLet me know.",
+			want:  `{"question": "why"}`,
+		},
+		{
+			name:  "text around the object",
+			input: `Sure! {"final_answer": "done"} Hope this helps.`,
+			want:  `{"final_answer": "done"}`,
+		},
+		{
+			name:  "raw newlines in strings",
+			input: "{\"final_answer\": \"line 1\nline 2\"}",
+			want:  `{"final_answer": "line 1\nline 2"}`,
+		},
+		{
+			name:  "unescaped quotes in strings",
+			input: `{"thought": "The pod is in "CrashLoopBackOff" state", "observation": ""}`,
+			want:  `{"thought": "The pod is in \"CrashLoopBackOff\" state", "observation": ""}`,
+		},
+		{
+			name:  "invalid escapes",
+			input: `{"input": "kubectl get pods -o jsonpath='{.items[*].metadata.name}' | grep \d", "quote": "it\'s"}`,
+			want:  `{"input": "kubectl get pods -o jsonpath='{.items[*].metadata.name}' | grep \\d", "quote": "it's"}`,
+		},
+		{
+			name:  "trailing commas and comments",
+			input: "{\n  // the plan\n  \"steps\": [\"a\", \"b\",],\n  /* done */ \"current_step_index\": 0,\n}",
+			want:  "{\n    \"steps\": [\"a\", \"b\"],\n   \"current_step_index\": 0}",
+		},
+		{
+			name:  "python literals",
+			input: `{"ok": True, "failed": False, "error": None, "thought": "True story"}`,
+			want:  `{"ok": true, "failed": false, "error": null, "thought": "True story"}`,
+		},
+		{
+			name:  "truncated output",
+			input: `{"question": "list pods", "steps": [{"name": "get pods", "description": "Run kubectl`,
+			want:  `{"question": "list pods", "steps": [{"name": "get pods", "description": "Run kubectl"}]}`,
+		},
+		{
+			name:  "truncated after key",
+			input: `{"question": "list pods", "final_answer":`,
+			want:  `{"question": "list pods", "final_answer": null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := repairJSON(tt.input)
+			if got != tt.want {
+				t.Errorf("repairJSON() = %q, want %q", got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("repairJSON() = %q, which is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalLLMJSON(t *testing.T) {
+	var action ReactAction
+	response := "```json\n{\"question\": \"Why is the pod \"nginx\" failing?\", \"final_answer\": \"Image pull error\",}\n```"
+	if err := unmarshalLLMJSON(response, &action); err != nil {
+		t.Fatalf("unmarshalLLMJSON() error = %v", err)
+	}
+	want := ReactAction{Question: `Why is the pod "nginx" failing?`, FinalAnswer: "Image pull error"}
+	if !reflect.DeepEqual(action, want) {
+		t.Errorf("unmarshalLLMJSON() = %+v, want %+v", action, want)
+	}
+
+	if err := unmarshalLLMJSON("I can't help with that.", &action); err == nil {
+		t.Errorf("unmarshalLLMJSON() of a plain text response should fail")
+	}
+}
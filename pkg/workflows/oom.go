@@ -0,0 +1,82 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+
+	"github.com/feiskyer/kube-copilot/pkg/oomkill"
+	"github.com/feiskyer/swarm-go"
+)
+
+const oomPrompt = `As an expert on Kubernetes and application memory management, analyze the OOM kills and memory usage of Pod {pod} in namespace {namespace}.
+
+The memory analysis of its containers is given in the input: the requests, limits, restarts and last termination reasons, the working set over the {window} window when Prometheus is available, the leak indicators and the right-sized request and limit.
+
+# Steps
+
+1. **Correlate the Restarts**: Use kubectl to confirm the OOMKilled terminations ("kubectl get pod -n <namespace> <pod> -o yaml"), the related events, and the logs of the previous instances ("kubectl logs -n <namespace> <pod> -c <container> --previous"). Distinguish container OOM kills (limit reached) from Node memory pressure evictions.
+2. **Find the Owner**: Identify the workload owning the Pod, since the limits must be changed there, and check whether its other replicas are affected.
+3. **Leak or Undersized**: Decide for each container whether the memory is undersized (a stable footprint above the limit) or leaking (steady growth, memory only released by restarts). Consider the runtime (e.g. JVM heap flags, Go GOMEMLIMIT, Node.js --max-old-space-size) when the logs or the image reveal it.
+
+# Output Format
+
+Start with a summary, followed by one section per issue:
+
+## 1. <title of the issue>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <the container, the restarts and the usage evidence, and whether a leak is suspected>
+- **How to resolve**: <the right-sized request and limit as a YAML snippet of the workload, and the next steps to confirm or fix a leak (e.g. heap profiles)>
+
+If the containers are not OOM killed and have enough headroom, say so.
+`
+
+// OOMFlow explains the OOM kills of a Pod and recommends right-sized memory limits from the memory analysis of its containers.
+func OOMFlow(model string, namespace string, pod string, containers []oomkill.Container, window string, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "oom-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to fix out of memory issues.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "oom",
+				Instructions: prompt("oom"),
+				Inputs: map[string]interface{}{
+					"namespace": namespace,
+					"pod":       pod,
+					"window":    window,
+					"analysis":  oomkill.Format(containers),
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/utils"
+	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+)
+
+// The post-processors of the final answers.
+const (
+	// PostProcessStripThoughts removes the reasoning leaked into the answer,
+	// such as <think> blocks and ReAct "Thought:" lines.
+	PostProcessStripThoughts = "strip-thoughts"
+	// PostProcessMarkdown closes the unbalanced code fences, fixes the
+	// headings without a space and collapses the runs of blank lines.
+	PostProcessMarkdown = "markdown"
+	// PostProcessVerify flags the pod names, image tags, CVE IDs and counts
+	// of the answer that are not found in the observations of the run.
+	PostProcessVerify = "verify"
+	// PostProcessVerifyRegenerate asks the model to rewrite the answer
+	// without the ungrounded claims, and flags those that remain.
+	PostProcessVerifyRegenerate = "verify-regenerate"
+	// PostProcessTranslate translates the answer into Language.
+	PostProcessTranslate = "translate"
+	// PostProcessFooter appends the commands executed and the Disclaimer.
+	PostProcessFooter = "footer"
+)
+
+// PostProcessors are the supported post-processors.
+var PostProcessors = []string{PostProcessStripThoughts, PostProcessMarkdown, PostProcessVerify, PostProcessVerifyRegenerate, PostProcessTranslate, PostProcessFooter}
+
+var (
+	// PostProcess are the post-processors applied in order to the final
+	// answers of all workflows.
+	PostProcess = []string{PostProcessStripThoughts, PostProcessMarkdown, PostProcessVerify}
+	// Disclaimer is appended to the final answers by the footer post-processor.
+	Disclaimer string
+)
+
+// ParsePostProcess validates the names of a post-processing chain. The
+// name "none" disables the post-processing.
+func ParsePostProcess(names []string) ([]string, error) {
+	chain := []string{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "" || name == "none":
+			continue
+		case !validPostProcessor(name):
+			return nil, fmt.Errorf("unsupported post-processor %q, should be one of %s or none", name, strings.Join(PostProcessors, ", "))
+		}
+		chain = append(chain, name)
+	}
+	return chain, nil
+}
+
+func validPostProcessor(name string) bool {
+	for _, p := range PostProcessors {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// answerContext is what the post-processors know about the run that produced the answer.
+type answerContext struct {
+	ctx    context.Context
+	client *swarm.Swarm
+	model  string
+	// commands are the tool calls executed by the run.
+	commands []ToolCall
+	// observations are the inputs of the run and the outputs of its tools.
+	observations []string
+}
+
+// postProcessor rewrites a final answer.
+type postProcessor func(answer string, ac answerContext) (string, error)
+
+var postProcessorFuncs = map[string]postProcessor{
+	PostProcessStripThoughts: func(answer string, _ answerContext) (string, error) {
+		return stripThoughts(answer), nil
+	},
+	PostProcessMarkdown: func(answer string, _ answerContext) (string, error) {
+		return normalizeMarkdown(answer), nil
+	},
+	PostProcessVerify: func(answer string, ac answerContext) (string, error) {
+		return verifyAnswer(answer, ac, false)
+	},
+	PostProcessVerifyRegenerate: func(answer string, ac answerContext) (string, error) {
+		return verifyAnswer(answer, ac, true)
+	},
+	PostProcessTranslate: translateAnswer,
+	PostProcessFooter: func(answer string, ac answerContext) (string, error) {
+		return appendFooter(answer, ac.commands, Disclaimer), nil
+	},
+}
+
+// finalizeAnswer applies the PostProcess chain to a final answer. A failing
+// post-processor leaves the answer unchanged, so that e.g. an unavailable
+// translation doesn't discard a diagnosis.
+func finalizeAnswer(answer string, ac answerContext) string {
+	for _, name := range PostProcess {
+		process, ok := postProcessorFuncs[name]
+		if !ok {
+			continue
+		}
+		if result, err := process(answer, ac); err == nil {
+			answer = result
+		}
+	}
+	return answer
+}
+
+// runAnswerFlow runs a SimpleFlow whose result is a final answer and post-processes it.
+func runAnswerFlow(ctx context.Context, flow *swarm.SimpleFlow, client *swarm.Swarm) (string, error) {
+	result, history, err := runTracedFlow(ctx, flow, client)
+	if err != nil {
+		return result, err
+	}
+	return finalizeAnswer(result, answerContext{
+		ctx:          ctx,
+		client:       client,
+		model:        flow.Model,
+		commands:     historyToolCalls(history),
+		observations: flowObservations(flow, history),
+	}), nil
+}
+
+// flowObservations returns the inputs of a SimpleFlow and the outputs of its tool calls.
+func flowObservations(flow *swarm.SimpleFlow, history []map[string]interface{}) []string {
+	var observations []string
+	for _, step := range flow.Steps {
+		for _, input := range step.Inputs {
+			observations = append(observations, fmt.Sprint(input))
+		}
+	}
+	for _, msg := range history {
+		if role, _ := msg["role"].(string); role != "tool" {
+			continue
+		}
+		if content, ok := msg["content"].(string); ok {
+			observations = append(observations, content)
+		}
+	}
+	return observations
+}
+
+var (
+	thoughtBlockPattern = regexp.MustCompile(`(?is)<(think|thinking|reasoning)>.*?</(think|thinking|reasoning)>`)
+	// A closing tag without its opening one ends the reasoning of models
+	// which start their answers in thinking mode.
+	thoughtPrefixPattern = regexp.MustCompile(`(?is)^.*?</(think|thinking|reasoning)>`)
+	thoughtLinePattern   = regexp.MustCompile(`(?i)^\s*(thought|observation|action input)\s*:`)
+	finalAnswerPattern   = regexp.MustCompile(`(?i)^\s*final answer\s*:\s*`)
+)
+
+// stripThoughts removes the chain of thought leaked into an answer.
+func stripThoughts(answer string) string {
+	answer = thoughtBlockPattern.ReplaceAllString(answer, "")
+	answer = thoughtPrefixPattern.ReplaceAllString(answer, "")
+
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(answer, "\n") {
+		if isFence(line) {
+			inFence = !inFence
+		}
+		if !inFence && thoughtLinePattern.MatchString(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	answer = strings.TrimSpace(strings.Join(lines, "\n"))
+	return finalAnswerPattern.ReplaceAllString(answer, "")
+}
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})(\pL)`)
+
+// normalizeMarkdown fixes the common markdown mistakes of the models outside of the code blocks.
+func normalizeMarkdown(answer string) string {
+	var lines []string
+	inFence := false
+	blank := 0
+	for _, line := range strings.Split(strings.TrimSpace(answer), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if isFence(line) {
+			inFence = !inFence
+		}
+		if inFence {
+			lines = append(lines, line)
+			continue
+		}
+
+		if line == "" {
+			if blank++; blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		lines = append(lines, headingPattern.ReplaceAllString(line, "$1 $2"))
+	}
+	if inFence {
+		lines = append(lines, "```")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isFence returns true if line opens or closes a fenced code block.
+func isFence(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
+
+const translatePrompt = `Translate the answer below into {language}.
+
+Keep the markdown structure, the code blocks, the commands, the Kubernetes resource names and the field names (e.g. "Severity" or "How to resolve") unchanged. Reply with the translated answer only.
+
+# Answer
+
+{answer}
+`
+
+// translateAnswer translates the answer into Language with the model of the run.
+func translateAnswer(answer string, ac answerContext) (string, error) {
+	if Language == "" || ac.client == nil {
+		return answer, nil
+	}
+
+	flow := &swarm.SimpleFlow{
+		Name:     "translate-workflow",
+		Model:    ac.model,
+		MaxTurns: 1,
+		System:   "You are a technical translator of Kubernetes troubleshooting reports.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "translate",
+				Instructions: translatePrompt,
+				Inputs: map[string]interface{}{
+					"language": Language,
+					"answer":   answer,
+				},
+			},
+		},
+	}
+	flow.Initialize()
+	result, _, err := runTracedFlow(ac.ctx, flow, ac.client)
+	if err != nil {
+		return answer, err
+	}
+	if result = strings.TrimSpace(result); result == "" {
+		return answer, fmt.Errorf("empty translation")
+	}
+	return result, nil
+}
+
+// maxFooterCommand is the length above which the commands are truncated in the footer.
+const maxFooterCommand = 200
+
+// appendFooter appends the commands executed and the disclaimer to the answer.
+func appendFooter(answer string, commands []ToolCall, disclaimer string) string {
+	var footer []string
+	seen := map[string]bool{}
+	for _, call := range commands {
+		command := formatCommand(call)
+		if command == "" || seen[command] {
+			continue
+		}
+		seen[command] = true
+		footer = append(footer, fmt.Sprintf("- `%s`", command))
+	}
+	if len(footer) > 0 {
+		footer = append([]string{"**Commands executed:**", ""}, footer...)
+	}
+	if disclaimer = strings.TrimSpace(disclaimer); disclaimer != "" {
+		if len(footer) > 0 {
+			footer = append(footer, "")
+		}
+		footer = append(footer, "_"+disclaimer+"_")
+	}
+	if len(footer) == 0 {
+		return answer
+	}
+	return strings.TrimSpace(answer) + "\n\n---\n\n" + strings.Join(footer, "\n")
+}
+
+// formatCommand returns the command line of a tool call, with the secrets masked.
+func formatCommand(call ToolCall) string {
+	command := strings.Join(strings.Fields(utils.Redact(call.Input)), " ")
+	if !strings.HasPrefix(command, call.Name) {
+		command = strings.TrimSpace(call.Name + " " + command)
+	}
+	if len(command) > maxFooterCommand {
+		command = command[:maxFooterCommand] + "..."
+	}
+	return strings.ReplaceAll(command, "`", "'")
+}
+
+// historyToolCalls returns the tool calls of the chat history of a SimpleFlow.
+func historyToolCalls(history []map[string]interface{}) []ToolCall {
+	var calls []ToolCall
+	for _, msg := range history {
+		toolCalls, ok := msg["tool_calls"].([]openai.ChatCompletionMessageToolCall)
+		if !ok {
+			continue
+		}
+		for _, tc := range toolCalls {
+			calls = append(calls, ToolCall{Name: tc.Function.Name, Input: toolCallInput(tc.Function.Arguments)})
+		}
+	}
+	return calls
+}
+
+// toolCallInput returns the input of a tool call from its JSON arguments
+// (e.g. the command of kubectl or the image of trivy).
+func toolCallInput(arguments string) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return arguments
+	}
+	for _, key := range []string{"command", "image", "code"} {
+		if value, ok := args[key].(string); ok {
+			return value
+		}
+	}
+	return arguments
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestParsePostProcess(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{names: []string{"strip-thoughts", " Markdown "}, want: []string{"strip-thoughts", "markdown"}},
+		{names: []string{"none"}, want: []string{}},
+		{names: []string{"footer", ""}, want: []string{"footer"}},
+		{names: []string{"summarize"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePostProcess(tt.names)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParsePostProcess(%v) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePostProcess(%v) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestStripThoughts(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   string
+	}{
+		{
+			name:   "think block",
+			answer: "<think>\nThe Pod is pending, check the events.\n</think>\n\nThe Pod is unschedulable.",
+			want:   "The Pod is unschedulable.",
+		},
+		{
+			name:   "unopened think block",
+			answer: "Let me check the node.</think>The node is full.",
+			want:   "The node is full.",
+		},
+		{
+			name:   "react lines",
+			answer: "Thought: I know the answer.\nFinal Answer: The image tag doesn't exist.",
+			want:   "The image tag doesn't exist.",
+		},
+		{
+			name:   "code block kept",
+			answer: "Run:\n```python\nthought: str = 'x'\n```",
+			want:   "Run:\n```python\nthought: str = 'x'\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripThoughts(tt.answer); got != tt.want {
+				t.Errorf("stripThoughts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   string
+	}{
+		{
+			name:   "headings and blank lines",
+			answer: "##Summary\n\n\n\nThe Pod is healthy.  \n",
+			want:   "## Summary\n\nThe Pod is healthy.",
+		},
+		{
+			name:   "unclosed code block",
+			answer: "Apply:\n```yaml\n#comment\n\n\nkind: Pod",
+			want:   "Apply:\n```yaml\n#comment\n\n\nkind: Pod\n```",
+		},
+		{
+			name:   "shebang",
+			answer: "#!/bin/sh",
+			want:   "#!/bin/sh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeMarkdown(tt.answer); got != tt.want {
+				t.Errorf("normalizeMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendFooter(t *testing.T) {
+	tests := []struct {
+		name       string
+		commands   []ToolCall
+		disclaimer string
+		want       string
+	}{
+		{
+			name: "nothing to append",
+			want: "The Pod is healthy.\n",
+		},
+		{
+			name: "commands and disclaimer",
+			commands: []ToolCall{
+				{Name: "kubectl", Input: "kubectl get pods -n default"},
+				{Name: "kubectl", Input: "kubectl  get pods -n default"},
+				{Name: "trivy", Input: "nginx:1.25"},
+			},
+			disclaimer: "Review the commands before applying them.",
+			want:       "The Pod is healthy.\n\n---\n\n**Commands executed:**\n\n- `kubectl get pods -n default`\n- `trivy nginx:1.25`\n\n_Review the commands before applying them._",
+		},
+		{
+			name:       "disclaimer only",
+			disclaimer: "Generated answer.",
+			want:       "The Pod is healthy.\n\n---\n\n_Generated answer._",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendFooter("The Pod is healthy.\n", tt.commands, tt.disclaimer); got != tt.want {
+				t.Errorf("appendFooter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryToolCalls(t *testing.T) {
+	history := []map[string]interface{}{
+		{"role": "user", "content": "audit the pod"},
+		{
+			"role": "assistant",
+			"tool_calls": []openai.ChatCompletionMessageToolCall{
+				{Function: openai.ChatCompletionMessageToolCallFunction{Name: "kubectl", Arguments: `{"command":"kubectl get pod nginx"}`}},
+				{Function: openai.ChatCompletionMessageToolCallFunction{Name: "trivy", Arguments: `{"image":"nginx"}`}},
+			},
+		},
+		{"role": "tool", "content": "..."},
+	}
+
+	want := []ToolCall{{Name: "kubectl", Input: "kubectl get pod nginx"}, {Name: "trivy", Input: "nginx"}}
+	if got := historyToolCalls(history); !reflect.DeepEqual(got, want) {
+		t.Errorf("historyToolCalls() = %v, want %v", got, want)
+	}
+}
+
+func TestFinalizeAnswer(t *testing.T) {
+	defer func(chain []string, disclaimer string) { PostProcess, Disclaimer = chain, disclaimer }(PostProcess, Disclaimer)
+	PostProcess = []string{PostProcessStripThoughts, PostProcessMarkdown, PostProcessVerify, PostProcessTranslate, PostProcessFooter}
+	Disclaimer = ""
+
+	// The translation is skipped without a Language.
+	got := finalizeAnswer("<think>check app-6d4cf56db6-x2b9z</think>##Cause\nPod app-6d4cf56db6-x2b9z is OOMKilled", answerContext{
+		ctx:          context.Background(),
+		model:        "gpt-4o",
+		commands:     []ToolCall{{Name: "kubectl", Input: "kubectl describe pod app-6d4cf56db6-x2b9z"}},
+		observations: []string{"Name: app-6d4cf56db6-x2b9z\nReason: OOMKilled"},
+	})
+	want := "## Cause\nPod app-6d4cf56db6-x2b9z is OOMKilled\n\n---\n\n**Commands executed:**\n\n- `kubectl describe pod app-6d4cf56db6-x2b9z`"
+	if got != want {
+		t.Errorf("finalizeAnswer() = %q, want %q", got, want)
+	}
+}
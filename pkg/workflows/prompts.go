@@ -0,0 +1,64 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"github.com/feiskyer/kube-copilot/pkg/prompts"
+)
+
+// Prompts selects the versions of the prompts of the workflows. The builtin
+// prompts are used if it is nil.
+var Prompts *prompts.Selector
+
+// PromptTemplates are the builtin prompts of the workflows by name, whose
+// other versions can be stored and selected (see prompts.Selector).
+var PromptTemplates = map[string]string{
+	"analyze":    analysisPrompt,
+	"apiaudit":   apiAuditPrompt,
+	"assistant":  assistantPrompt,
+	"audit":      auditPrompt,
+	"datasafety": dataSafetyPrompt,
+	"diagnose":   diagnosePrompt,
+	"dns":        dnsPrompt,
+	"fix":        fixPrompt,
+	"generate":   generatePrompt,
+	"helmdiff":   helmDiffPrompt,
+	"jobs":       jobsPrompt,
+	"next-step":  nextStepPrompt,
+	"oom":        oomPrompt,
+	"plan":       planPrompt,
+	"react":      reactPrompt,
+	"readiness":  readinessPrompt,
+	"rebalance":  rebalancePrompt,
+	"trace":      tracePrompt,
+}
+
+// prompt returns the selected version of the named prompt.
+func prompt(name string) string {
+	if Prompts == nil {
+		return PromptTemplates[name]
+	}
+	return Prompts.Prompt(name)
+}
+
+// SelectedPrompts returns the versions of the prompts used so far by name,
+// which segment the results and the metrics of the runs.
+func SelectedPrompts() map[string]string {
+	if Prompts == nil {
+		return nil
+	}
+	return Prompts.Selected()
+}
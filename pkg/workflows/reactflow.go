@@ -20,12 +20,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/telemetry"
 	"github.com/feiskyer/kube-copilot/pkg/tools"
+	"github.com/feiskyer/kube-copilot/pkg/utils"
 	"github.com/feiskyer/swarm-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const planPrompt = `
@@ -202,6 +208,65 @@ Your final output must strictly adhere to this JSON structure:
 Follow these instructions strictly to ensure a seamless, automated diagnostic and troubleshooting process.
 `
 
+// trivyToolPrompt is the last built-in tool in the Available Tools of the prompts.
+const trivyToolPrompt = "- trivy: Scan container images for vulnerabilities using the 'trivy image' command. Input: an image name. Output: a report of vulnerabilities."
+
+// clusterToolsPattern matches the descriptions of the tools disabled in offline mode.
+var clusterToolsPattern = regexp.MustCompile(`(?m)^- (kubectl|python): .*\n`)
+
+// offlinePrompt is appended to the prompts in offline mode.
+const offlinePrompt = `
+# Offline Mode
+
+The cluster is not accessible: the kubectl and python tools are disabled. Answer only from the manifests, documents and other context provided by the user, and say which information is missing when they are not enough to answer.
+`
+
+// runbooksToolPrompt describes the runbooks tool, available once runbooks have been ingested.
+const runbooksToolPrompt = "- runbooks: Search the organization's runbooks and postmortems for known issues and procedures. Use it early when diagnosing an issue and follow the relevant runbook. Input: a short description of the symptoms (e.g. error messages, resource kinds). Output: the most relevant runbook excerpts."
+
+// withAvailableTools adds the optional tools that are currently available to a prompt:
+// the runbooks once ingested, and the registered plugins. The disabled
+// built-in tools are removed from it.
+func withAvailableTools(prompt string, disabled []string) string {
+	var names, descriptions []string
+	if tools.RunbooksAvailable() {
+		names = append(names, "runbooks")
+		descriptions = append(descriptions, runbooksToolPrompt)
+	}
+	for _, plugin := range tools.Plugins {
+		if !tools.Available(plugin.Name) || slices.Contains(disabled, plugin.Name) {
+			continue
+		}
+		description := fmt.Sprintf("- %s: %s", plugin.Name, plugin.Description)
+		if plugin.Input != "" {
+			description += " Input: " + plugin.Input
+		}
+		names = append(names, plugin.Name)
+		descriptions = append(descriptions, description)
+	}
+
+	builtins := []string{"kubectl", "python", "trivy"}
+	if tools.Offline {
+		prompt = clusterToolsPattern.ReplaceAllString(prompt, "")
+		prompt += offlinePrompt
+		builtins = []string{"trivy"}
+	} else if len(names) == 0 && len(disabled) == 0 {
+		return prompt
+	}
+	builtins = slices.DeleteFunc(builtins, func(name string) bool {
+		if !slices.Contains(disabled, name) {
+			return false
+		}
+		prompt = regexp.MustCompile(`(?m)^- `+name+`: .*\n`).ReplaceAllString(prompt, "")
+		return true
+	})
+
+	if len(descriptions) > 0 {
+		prompt = strings.Replace(prompt, trivyToolPrompt, trivyToolPrompt+"\n"+strings.Join(descriptions, "\n"), 1)
+	}
+	return strings.Replace(prompt, "one of [kubectl, python, trivy]", "one of ["+strings.Join(append(builtins, names...), ", ")+"]", 1)
+}
+
 // ReactAction is the JSON format for the react action.
 type ReactAction struct {
 	Question         string       `json:"question"`
@@ -458,6 +523,12 @@ func (pt *PlanTracker) SyncStepsWithReactAction(reactAction *ReactAction) {
 	}
 }
 
+// ToolCall is a tool invocation proposed by the agent.
+type ToolCall struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+}
+
 // ReActFlow orchestrates the ReAct (Reason + Act) workflow
 type ReActFlow struct {
 	Model         string
@@ -467,6 +538,32 @@ type ReActFlow struct {
 	PlanTracker   *PlanTracker
 	Client        *swarm.Swarm
 	ChatHistory   interface{}
+
+	// DryRun skips all tool calls except read-only kubectl commands and plugins.
+	DryRun bool
+	// SkippedToolCalls are the tool calls skipped in dry-run mode.
+	SkippedToolCalls []ToolCall
+	// ConfirmToolCall, if set, is called before running a mutating kubectl
+	// command or plugin. It returns the (possibly edited) input and whether to run it.
+	ConfirmToolCall func(call ToolCall) (ToolCall, bool)
+	// OnEvent, if set, receives the progress events of the flow.
+	OnEvent func(event Event)
+	// Prefetch, if set, collects the data the flow likely needs (e.g. the
+	// state of a Pod) concurrently with the planning call. Its result is
+	// appended to the instructions of the following steps.
+	Prefetch func(ctx context.Context) string
+	// DisabledTools are the tools the flow may not run, e.g. python in the
+	// operator, where nobody reviews the scripts.
+	DisabledTools []string
+
+	// toolCache holds the results of the read-only tool calls of the run.
+	toolCache toolCache
+	// executed are the tool calls run by the flow, listed by the footer post-processor.
+	executed []ToolCall
+	// observations are the outputs of the tool calls, against which the final answer is verified.
+	observations []string
+	// artifacts is the scratch directory of the run in ArtifactStore.
+	artifacts *artifacts.Run
 }
 
 // NewReActFlow creates a new ReActFlow instance
@@ -480,6 +577,7 @@ func NewReActFlow(model string, instructions string, verbose bool, maxIterations
 	return &ReActFlow{
 		Model:         model,
 		Instructions:  instructions,
+		Verbose:       verbose,
 		MaxIterations: maxIterations,
 		PlanTracker:   NewPlanTracker(),
 		Client:        client,
@@ -489,25 +587,61 @@ func NewReActFlow(model string, instructions string, verbose bool, maxIterations
 
 // Run executes the complete ReAct workflow
 func (r *ReActFlow) Run() (string, error) {
+	return r.RunContext(context.Background())
+}
+
+// RunContext executes the complete ReAct workflow until ctx is done.
+func (r *ReActFlow) RunContext(ctx context.Context) (string, error) {
 	// Set a reasonable default response in case of early failures
 	defaultResponse := "I was unable to complete the task due to technical issues. Please try again or simplify your request."
 
 	// Set a context with timeout for the entire flow
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Minute)
 	defer cancel()
 
+	ctx, span := telemetry.StartSpan(ctx, "react.run", attribute.String("llm.model", r.Model))
+
+	// Speculatively collect the data needed by the next steps while the plan is created.
+	var prefetched chan string
+	if r.Prefetch != nil {
+		prefetched = make(chan string, 1)
+		go func() { prefetched <- r.Prefetch(ctx) }()
+	}
+
 	// Step 1: Create initial plan
 	if err := r.Plan(ctx); err != nil {
 		r.PlanTracker.LastError = fmt.Sprintf("Planning phase failed: %v", err)
+		r.emit(Event{Type: EventFinished})
+		telemetry.EndSpan(span, err)
 		return defaultResponse, err
 	}
+	if prefetched != nil {
+		select {
+		case data := <-prefetched:
+			r.Instructions += data
+		case <-ctx.Done():
+		}
+	}
 
 	// Step 2: Execute plan steps in a loop
-	return r.ExecutePlan(ctx)
+	result, err := r.ExecutePlan(ctx)
+	if err == nil {
+		result = finalizeAnswer(result, answerContext{
+			ctx:          ctx,
+			client:       r.Client,
+			model:        r.Model,
+			commands:     r.executed,
+			observations: append([]string{r.Instructions}, r.observations...),
+		})
+	}
+	r.emit(Event{Type: EventFinished})
+	telemetry.EndSpan(span, err)
+	return result, err
 }
 
 // Plan creates the initial plan for solving the problem
 func (r *ReActFlow) Plan(ctx context.Context) error {
+	r.emit(Event{Type: EventPlanning})
 	if r.Verbose {
 		color.Blue("Planning phase: creating a detailed plan\n")
 	}
@@ -520,7 +654,7 @@ func (r *ReActFlow) Plan(ctx context.Context) error {
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "plan-step",
-				Instructions: planPrompt,
+				Instructions: withAvailableTools(prompt("plan"), r.DisabledTools),
 				Inputs: map[string]interface{}{
 					"instructions": fmt.Sprintf("First, create a clear and actionable step-by-step plan to solve this problem: %s", r.Instructions),
 				},
@@ -531,7 +665,7 @@ func (r *ReActFlow) Plan(ctx context.Context) error {
 	// Initialize and run workflow
 	reactFlow.Initialize()
 
-	result, chatHistory, err := reactFlow.Run(ctx, r.Client)
+	result, chatHistory, err := runTracedFlow(ctx, reactFlow, r.Client)
 	if err != nil {
 		return err
 	}
@@ -550,7 +684,7 @@ func (r *ReActFlow) Plan(ctx context.Context) error {
 // ParsePlanResult parses the planning phase result
 func (r *ReActFlow) ParsePlanResult(result string) error {
 	var reactAction ReactAction
-	if err := json.Unmarshal([]byte(result), &reactAction); err != nil {
+	if err := unmarshalLLMJSON(result, &reactAction); err != nil {
 		if r.Verbose {
 			color.Red("Unable to parse response as JSON: %v\n", err)
 		}
@@ -567,7 +701,7 @@ func (r *ReActFlow) ParsePlanResult(result string) error {
 
 		// If we still don't have a valid plan, return an error
 		if !r.PlanTracker.HasValidPlan {
-			return fmt.Errorf("couldn't create a proper plan")
+			return fmt.Errorf("%w: couldn't create a proper plan", errdefs.ErrJSONParse)
 		}
 	} else {
 		// Parse plan from the structured ReactAction
@@ -649,6 +783,8 @@ func (r *ReActFlow) ExecutePlan(ctx context.Context) (string, error) {
 
 		// Mark the current step as in progress
 		currentStep.Status = "in_progress"
+		r.emit(Event{Type: EventStepStarted, Iteration: iteration + 1, Step: currentStep.Description})
+		recordUsage(func(u *Usage) { u.Iterations++ })
 		if r.Verbose {
 			color.Blue("[step: %s] %s [%s]\n", currentStep.Name, currentStep.Description, currentStep.Status)
 		}
@@ -681,6 +817,13 @@ func (r *ReActFlow) ExecutePlan(ctx context.Context) (string, error) {
 
 // ExecuteStep executes a single step in the plan
 func (r *ReActFlow) ExecuteStep(ctx context.Context, iteration int, currentStep *StepDetail) error {
+	ctx, span := telemetry.StartSpan(ctx, "react.step",
+		attribute.Int("react.iteration", iteration),
+		attribute.Int("react.step_index", r.PlanTracker.CurrentStep),
+		attribute.String("react.step_name", currentStep.Name),
+	)
+	defer span.End()
+
 	// Update step status to in_progress
 	r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "in_progress", "", "")
 	if r.Verbose {
@@ -689,6 +832,7 @@ func (r *ReActFlow) ExecuteStep(ctx context.Context, iteration int, currentStep
 	}
 
 	// Think about the step
+	r.emit(Event{Type: EventThinking, Iteration: iteration + 1, Step: currentStep.Description})
 	stepResult, err := r.ThinkAboutStep(ctx, currentStep)
 	if err != nil {
 		if r.Verbose {
@@ -706,7 +850,7 @@ func (r *ReActFlow) ExecuteStep(ctx context.Context, iteration int, currentStep
 
 	// Parse the step result
 	var stepAction ReactAction
-	if err = json.Unmarshal([]byte(stepResult), &stepAction); err != nil {
+	if err = unmarshalLLMJSON(stepResult, &stepAction); err != nil {
 		if r.Verbose {
 			color.Red("Unable to parse step response as JSON: %v\n", err)
 		}
@@ -781,7 +925,7 @@ func (r *ReActFlow) ThinkAboutStep(ctx context.Context, currentStep *StepDetail)
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "think-step",
-				Instructions: reactPrompt,
+				Instructions: withAvailableTools(prompt("react"), r.DisabledTools),
 				Inputs: map[string]interface{}{
 					"instructions": fmt.Sprintf("User input: %s\n\nCurrent plan and status:\n%s\n\nExecute the current step (index %d) of the plan.",
 						r.Instructions, string(currentReactActionJSON), r.PlanTracker.CurrentStep),
@@ -800,7 +944,7 @@ func (r *ReActFlow) ThinkAboutStep(ctx context.Context, currentStep *StepDetail)
 		color.Blue("[step: %s] Running the step %s\n", currentStep.Name, currentStep.Description)
 	}
 
-	stepResult, stepChatHistory, err := stepFlow.Run(stepCtx, r.Client)
+	stepResult, stepChatHistory, err := runTracedFlow(stepCtx, stepFlow, r.Client)
 	stepCancel() // Cancel the context regardless of result
 
 	// Update chat history
@@ -827,28 +971,69 @@ func (r *ReActFlow) ExecuteToolIfNeeded(ctx context.Context, stepAction *ReactAc
 
 	// Get current step action
 	currentStep := &stepAction.Steps[currentStepIndex]
-	observation := r.ExecuteTool(currentStep.Action.Name, currentStep.Action.Input)
+	observation := r.ExecuteTool(ctx, currentStep.Action.Name, currentStep.Action.Input)
 
 	// Process the tool observation
 	return r.ProcessToolObservation(ctx, currentStep, observation)
 }
 
 // ExecuteTool executes the specified tool and returns the observation
-func (r *ReActFlow) ExecuteTool(toolName string, toolInput string) string {
+func (r *ReActFlow) ExecuteTool(ctx context.Context, toolName string, toolInput string) string {
+	_, span := telemetry.StartSpan(ctx, "tool.execute", attribute.String("tool.name", toolName))
+	defer span.End()
+
 	if r.Verbose {
 		color.Blue("Executing tool %s\n", toolName)
-		color.Cyan("Invoking %s tool with inputs: \n============\n%s\n============\n\n", toolName, toolInput)
+		color.Cyan("Invoking %s tool with inputs: \n============\n%s\n============\n\n", toolName, utils.Redact(toolInput))
 	}
 
 	// Execute the tool with timeout
 	toolFunc, ok := tools.CopilotTools[toolName]
-	if !ok {
+	if !ok || slices.Contains(r.DisabledTools, toolName) {
 		observation := fmt.Sprintf("Tool %s is not available. Considering switch to other supported tools.", toolName)
 		r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "failed", toolName, observation)
+		span.SetAttributes(attribute.String("tool.status", "unavailable"))
+		return observation
+	}
+
+	call, decision := guardToolCall(ToolCall{Name: toolName, Input: toolInput}, r.DryRun, r.ConfirmToolCall)
+	switch decision {
+	case toolSkipped:
+		r.SkippedToolCalls = append(r.SkippedToolCalls, call)
+		observation := skippedObservation(toolName)
+		r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "in_progress", toolName, "")
+		span.SetAttributes(attribute.String("tool.status", "skipped"))
+		if r.Verbose {
+			color.Yellow("Dry-run: skipped %s tool\n\n", toolName)
+		}
 		return observation
+	case toolDeclined:
+		observation := declinedObservation(toolInput)
+		r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "failed", toolName, observation)
+		span.SetAttributes(attribute.String("tool.status", "declined"))
+		return observation
+	}
+	toolInput = call.Input
+
+	if r.toolCache == nil {
+		r.toolCache = toolCache{}
+	}
+	if result, ok := r.toolCache.lookup(toolName, toolInput); ok {
+		recordUsage(func(u *Usage) { u.CachedToolCalls++ })
+		r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "in_progress", toolName, "")
+		span.SetAttributes(attribute.String("tool.status", "cached"))
+		if r.Verbose {
+			color.Yellow("Reusing the cached result of %s tool\n\n", toolName)
+		}
+		return cachedResultNote + result
 	}
+	r.toolCache.invalidate(toolName, toolInput)
 
 	// Execute tool with timeout
+	r.executed = append(r.executed, ToolCall{Name: toolName, Input: toolInput})
+	r.emit(Event{Type: EventToolStarted, Tool: toolName, ToolInput: toolInput})
+	recordUsage(func(u *Usage) { u.ToolCalls++ })
+	defer r.emit(Event{Type: EventToolFinished, Tool: toolName, ToolInput: toolInput})
 	toolResultCh := make(chan struct {
 		result string
 		err    error
@@ -871,23 +1056,62 @@ func (r *ReActFlow) ExecuteTool(toolName string, toolInput string) string {
 			observation = fmt.Sprintf("Tool %s failed with error: %v. Considering refine the inputs for the tool.",
 				toolName, toolResult.err)
 			r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "failed", toolName, observation)
+			span.RecordError(toolResult.err)
+			span.SetAttributes(attribute.String("tool.status", "failed"))
 		} else {
+			if toolName == "kubectl" {
+				observation = compactObservation(toolInput, observation)
+			}
+			r.observations = append(r.observations, toolResult.result)
+			observation = r.saveArtifact(toolName, strings.TrimSpace(toolResult.result), observation)
+			r.toolCache.store(toolName, toolInput, observation)
 			// Update step with tool call info
 			r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "in_progress", toolName, "")
+			span.SetAttributes(attribute.String("tool.status", "succeeded"))
 		}
 	case <-time.After(r.PlanTracker.ExecutionTimeout):
 		observation = fmt.Sprintf("Tool %s execution timed out after %v seconds. Try with a simpler query or different tool.",
 			toolName, r.PlanTracker.ExecutionTimeout.Seconds())
 		r.PlanTracker.UpdateStepStatus(r.PlanTracker.CurrentStep, "failed", toolName, observation)
+		span.RecordError(fmt.Errorf("%w: %s", errdefs.ErrToolTimeout, toolName))
+		span.SetAttributes(attribute.String("tool.status", "timeout"))
 	}
 
 	if r.Verbose {
-		color.Cyan("Observation: %s\n\n", observation)
+		color.Cyan("Observation: %s\n\n", utils.Redact(observation))
 	}
 
 	return observation
 }
 
+// saveArtifact saves the output of the tool to the scratch directory of the
+// run if its observation is too large for the chat history, and returns the
+// excerpt of the observation referencing the artifact instead.
+func (r *ReActFlow) saveArtifact(toolName, output, observation string) string {
+	if ArtifactStore == nil || len(observation) <= maxObservationSize {
+		return observation
+	}
+	if r.artifacts == nil {
+		r.artifacts = ArtifactStore.NewRun()
+	}
+	artifact, err := r.artifacts.Save(toolName, output)
+	if err != nil {
+		if r.Verbose {
+			color.Yellow("Unable to save the output of %s tool as an artifact: %v\n", toolName, err)
+		}
+		return observation
+	}
+	return artifacts.Excerpt(observation, artifact)
+}
+
+// Artifacts returns the tool outputs saved as artifacts during the run.
+func (r *ReActFlow) Artifacts() []artifacts.Artifact {
+	if r.artifacts == nil {
+		return nil
+	}
+	return r.artifacts.Artifacts()
+}
+
 // ProcessToolObservation processes the observation from a tool execution
 func (r *ReActFlow) ProcessToolObservation(ctx context.Context, currentStep *StepDetail, observation string) error {
 	// Update stepAction with the observation
@@ -902,7 +1126,7 @@ func (r *ReActFlow) ProcessToolObservation(ctx context.Context, currentStep *Ste
 		Steps: []swarm.SimpleFlowStep{
 			{
 				Name:         "tool-call-step",
-				Instructions: nextStepPrompt,
+				Instructions: prompt("next-step"),
 				Inputs: map[string]interface{}{
 					"instructions": fmt.Sprintf("User input: %s\n\nCurrent plan with tool execution result:\n%s\n",
 						r.Instructions, string(observationActionJSON)),
@@ -921,7 +1145,7 @@ func (r *ReActFlow) ProcessToolObservation(ctx context.Context, currentStep *Ste
 		color.Blue("[step: %s] Processing tool observation\n", currentStep.Name)
 	}
 
-	observationResult, observationChatHistory, err := observationFlow.Run(obsCtx, r.Client)
+	observationResult, observationChatHistory, err := runTracedFlow(obsCtx, observationFlow, r.Client)
 	obsCancel() // Cancel the context regardless of result
 
 	if err != nil {
@@ -944,7 +1168,7 @@ func (r *ReActFlow) ProcessToolObservation(ctx context.Context, currentStep *Ste
 
 	// Parse the observation result
 	var observationAction ReactAction
-	if err = json.Unmarshal([]byte(observationResult), &observationAction); err != nil {
+	if err = unmarshalLLMJSON(observationResult, &observationAction); err != nil {
 		if r.Verbose {
 			color.Red("Unable to parse observation response as JSON: %v\n", err)
 		}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/cassette"
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// chatCompletion returns a recorded chat completion answering content.
+func chatCompletion(t *testing.T, content string) cassette.LLMInteraction {
+	t.Helper()
+	response, err := json.Marshal(map[string]interface{}{
+		"id":     "chatcmpl-test",
+		"object": "chat.completion",
+		"model":  "gpt-4o",
+		"choices": []map[string]interface{}{
+			{"index": 0, "finish_reason": "stop", "message": map[string]interface{}{"role": "assistant", "content": content}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cassette.LLMInteraction{Request: json.RawMessage(`{}`), Response: response}
+}
+
+func TestReActFlowReplay(t *testing.T) {
+	steps := func(first, second string) string {
+		return `"steps": [
+			{"name": "Check Pod", "description": "Get the Pod status", "action": {"name": "kubectl", "input": "get pod nginx -n default"}, "status": "` + first + `"},
+			{"name": "Summarize", "description": "Summarize the findings", "status": "` + second + `"}
+		]`
+	}
+	plan := `{"question": "diagnose nginx", "thought": "check the Pod", ` + steps("pending", "pending") + `, "current_step_index": 0}`
+	think := `{"question": "diagnose nginx", "thought": "run kubectl", ` + steps("in_progress", "pending") + `, "current_step_index": 0}`
+	observe := "```json\n" + `{"question": "diagnose nginx", "thought": "the Pod is running", ` + steps("completed", "pending") + `, "current_step_index": 1}` + "\n```"
+	summarize := `{"question": "diagnose nginx", "thought": "done", ` + steps("completed", "completed") + `, "current_step_index": 1, "final_answer": "The Pod nginx is healthy."}`
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	data, err := json.Marshal(&cassette.Cassette{
+		Version: 1,
+		LLM:     []cassette.LLMInteraction{chatCompletion(t, plan), chatCompletion(t, think), chatCompletion(t, observe), chatCompletion(t, summarize)},
+		Tools:   []cassette.ToolInteraction{{Tool: "kubectl", Input: "get pod nginx -n default", Output: "NAME    READY   STATUS    RESTARTS\nnginx   1/1     Running   0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	savedTools := tools.CopilotTools["kubectl"]
+	Cassette = c
+	c.WrapTools(tools.CopilotTools)
+	t.Cleanup(func() {
+		Cassette = nil
+		tools.CopilotTools["kubectl"] = savedTools
+	})
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("AZURE_OPENAI_API_KEY", "")
+
+	flow, err := NewDiagnoseFlow("gpt-4o", "default", "nginx", false, 5)
+	if err != nil {
+		t.Fatalf("NewDiagnoseFlow() error = %v", err)
+	}
+	// The recorded diagnosis has no cluster to prefetch the Pod context from.
+	flow.Prefetch = func(ctx context.Context) string { return "\n# Context\n\nprefetched" }
+	result, err := flow.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(result, "The Pod nginx is healthy.") {
+		t.Errorf("Run() = %q, want the replayed final answer", result)
+	}
+	if got := flow.PlanTracker.Steps[0].Observation; !strings.Contains(got, "Running") {
+		t.Errorf("step observation = %q, want the replayed kubectl output", got)
+	}
+	if !strings.HasSuffix(flow.Instructions, "prefetched") {
+		t.Errorf("instructions = %q, want the prefetched context appended", flow.Instructions)
+	}
+}
+
+func TestWithAvailableToolsOffline(t *testing.T) {
+	tools.Offline = true
+	defer func() { tools.Offline = false }()
+
+	for _, prompt := range []string{planPrompt, reactPrompt} {
+		got := withAvailableTools(prompt, nil)
+		if strings.Contains(got, "- kubectl:") || strings.Contains(got, "- python:") {
+			t.Errorf("withAvailableTools() kept the cluster tools in offline mode")
+		}
+		if !strings.Contains(got, trivyToolPrompt) || !strings.Contains(got, "# Offline Mode") {
+			t.Errorf("withAvailableTools() = %q, want the trivy tool and the offline instructions", got)
+		}
+		if strings.Contains(prompt, "one of [kubectl, python, trivy]") && !strings.Contains(got, "one of [trivy") {
+			t.Errorf("withAvailableTools() did not restrict the tool names in offline mode")
+		}
+	}
+}
+
+func TestWithAvailableToolsDisabled(t *testing.T) {
+	for _, prompt := range []string{planPrompt, reactPrompt} {
+		got := withAvailableTools(prompt, []string{"python"})
+		if strings.Contains(got, "- python:") || !strings.Contains(got, "- kubectl:") {
+			t.Errorf("withAvailableTools() = %q, want the kubectl tool without python", got)
+		}
+		if strings.Contains(prompt, "one of [kubectl, python, trivy]") && !strings.Contains(got, "one of [kubectl, trivy]") {
+			t.Errorf("withAvailableTools() did not remove python from the tool names")
+		}
+	}
+
+	flow := &ReActFlow{PlanTracker: NewPlanTracker(), DisabledTools: []string{"python"}}
+	if got := flow.ExecuteTool(context.Background(), "python", "print(1)"); !strings.Contains(got, "Tool python is not available") {
+		t.Errorf("ExecuteTool() of a disabled tool = %q", got)
+	}
+}
+
+func TestExecuteToolArtifacts(t *testing.T) {
+	logs := strings.Repeat("GET /healthz 200\n", 2000)
+	original := tools.CopilotTools["kubectl"]
+	tools.CopilotTools["kubectl"] = func(command string) (string, error) {
+		if strings.HasPrefix(command, "logs") {
+			return logs, nil
+		}
+		return "NAME    READY\nweb     1/1", nil
+	}
+	defer func() { tools.CopilotTools["kubectl"] = original }()
+	defer func(store *artifacts.Store) { ArtifactStore = store }(ArtifactStore)
+	ArtifactStore = artifacts.NewStore(t.TempDir())
+
+	flow := &ReActFlow{PlanTracker: NewPlanTracker()}
+	if got := flow.ExecuteTool(context.Background(), "kubectl", "get pods"); strings.Contains(got, artifacts.HandlePrefix) || len(flow.Artifacts()) != 0 {
+		t.Errorf("small observation = %q, want it unchanged", got)
+	}
+
+	got := flow.ExecuteTool(context.Background(), "kubectl", "logs web")
+	saved := flow.Artifacts()
+	if len(saved) != 1 || !strings.Contains(got, saved[0].Handle) || len(got) >= maxObservationSize {
+		t.Fatalf("large observation of %d bytes = %q..., artifacts = %+v, want an excerpt referencing the artifact", len(got), got[:100], saved)
+	}
+	f, err := ArtifactStore.Open(saved[0].Run, saved[0].Name)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+	if info, _ := f.Stat(); info.Size() != int64(len(strings.TrimSpace(logs))) {
+		t.Errorf("artifact size = %d, want the full output", info.Size())
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+
+	"github.com/feiskyer/kube-copilot/pkg/availability"
+	"github.com/feiskyer/swarm-go"
+)
+
+const readinessPrompt = `As an expert on Kubernetes reliability, write the availability readiness report of namespace {namespace} before a maintenance window (e.g. node drains, node pool or cluster upgrades).
+
+The automated checks of replica counts, PodDisruptionBudgets, topology spread, anti-affinity and Pod placement found the issues given in the input, with a verdict of READY, AT RISK or NOT READY.
+
+# Steps
+
+1. **Verify**: Use kubectl to confirm the issues when needed (e.g. "kubectl get pdb -n <namespace>" or "kubectl get pods -n <namespace> -o wide") and to check the Nodes the workloads depend on.
+2. **Assess the Impact**: For each issue, explain what happens to the workload during the maintenance (e.g. downtime while the only replica is rescheduled, or a drain blocked by a PodDisruptionBudget).
+3. **Recommend**: Give concrete fixes, with the YAML snippets or kubectl commands to apply them.
+
+# Output Format
+
+Start with the verdict and a one-paragraph summary of the readiness, followed by one section per issue (group the issues of the same workload):
+
+## 1. <title of the issue>
+
+- **Severity**: <HIGH, MEDIUM or LOW, as given by the checks>
+- **Findings**: <the workload, the issue and its impact during the maintenance>
+- **How to resolve**: <step-by-step fix>
+
+If there is no issue, say that the namespace is ready for the maintenance and summarize the checks performed.
+`
+
+// ReadinessFlow writes the availability readiness report of a namespace from the issues found by the availability checks.
+func ReadinessFlow(model string, namespace string, issues []availability.Issue, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "readiness-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to prepare the workloads for a maintenance window.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "readiness",
+				Instructions: prompt("readiness"),
+				Inputs: map[string]interface{}{
+					"namespace": namespace,
+					"verdict":   availability.Verdict(issues),
+					"issues":    availability.Format(issues),
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
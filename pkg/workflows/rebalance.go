@@ -0,0 +1,80 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+
+	"github.com/feiskyer/kube-copilot/pkg/rebalance"
+	"github.com/feiskyer/swarm-go"
+)
+
+const rebalancePrompt = `As an expert on Kubernetes scheduling, recommend how to rebalance the workloads of the cluster from the given Node utilization and Pod placement skew.
+
+# Steps
+
+1. **Understand the Imbalance**: Identify the overutilized and underutilized Nodes and the workloads concentrated on few Nodes. Use kubectl to find out why (e.g. "kubectl describe node <node>" for taints and labels, "kubectl get pods -A -o wide --field-selector spec.nodeName=<node>", or the nodeSelector, affinity and tolerations of the skewed workloads).
+2. **Recommend**: For each issue, recommend the fix that addresses its cause, such as topology spread constraints or pod anti-affinity for skewed workloads, node affinity or taints and tolerations to steer workloads away from hot Nodes, right-sizing the resource requests, or running the descheduler to evict Pods so that the scheduler places them again.
+3. **Mind the Disruption**: Mention the evictions caused by each recommendation and the PodDisruptionBudgets they are subject to.
+
+# Output Format
+
+Start with a summary of the cluster balance, followed by one section per issue:
+
+## 1. <title of the issue>
+
+- **Severity**: <HIGH, MEDIUM or LOW>
+- **Findings**: <the Nodes or workloads affected, with the utilization or placement evidence>
+- **How to resolve**: <step-by-step fix, with the YAML snippets or kubectl commands when generate_manifests is true>
+
+If the cluster is balanced, say so and summarize the utilization.
+`
+
+// RebalanceFlow recommends how to rebalance the cluster from the rebalancing analysis.
+// When generateManifests is true, the recommendations include the manifests to apply.
+func RebalanceFlow(model string, report *rebalance.Report, generateManifests bool, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "rebalance-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes helping the user to rebalance the workloads across the Nodes.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "rebalance",
+				Instructions: prompt("rebalance"),
+				Inputs: map[string]interface{}{
+					"analysis":           report.Format(),
+					"balanced":           report.Balanced(),
+					"generate_manifests": generateManifests,
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -17,8 +17,6 @@ package workflows
 
 import (
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/feiskyer/swarm-go"
 )
@@ -66,13 +64,12 @@ func SimpleFlow(model string, systemPrompt string, instructions string, verbose
 	// Create OpenAI client
 	client, err := NewSwarm()
 	if err != nil {
-		fmt.Printf("Failed to create client: %v\n", err)
-		os.Exit(1)
+		return "", err
 	}
 
 	// Initialize and run workflow
 	simpleFlow.Initialize()
-	result, _, err := simpleFlow.Run(context.Background(), client)
+	result, err := runAnswerFlow(context.Background(), simpleFlow, client)
 	if err != nil {
 		return "", err
 	}
@@ -82,5 +79,5 @@ func SimpleFlow(model string, systemPrompt string, instructions string, verbose
 
 // AssistantFlow runs a simple workflow with kubernetes assistant prompt.
 func AssistantFlow(model string, instructions string, verbose bool) (string, error) {
-	return SimpleFlow(model, assistantPrompt, instructions, verbose)
+	return SimpleFlow(model, prompt("assistant"), instructions, verbose)
 }
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/feiskyer/swarm-go"
+)
+
+const summarizePrompt = `Write a title and a summary of the Kubernetes troubleshooting run below, so that it can be found in a list of previous runs and continued later.
+
+# Steps
+
+1. **Title**: A few words (at most 8) naming the problem and the affected resource, e.g. "nginx Pod CrashLoopBackOff in default".
+2. **Summary**: Two or three sentences with the question, the cause or the result found, and the fix applied or proposed. Keep the resource names, images and error messages of the answer; never add facts that are not in the answer.
+
+# Output Format
+
+JSON only, without code fences: {"title": "<title>", "summary": "<summary>"}
+
+# Question
+
+{question}
+
+# Answer
+
+{answer}
+`
+
+// maxSummarizedAnswer is the size of the answer handed to the model, which
+// keeps the summaries cheap for the long audit reports.
+const maxSummarizedAnswer = 8 * 1024
+
+// maxTitleLength is the length above which the titles are truncated.
+const maxTitleLength = 80
+
+// RunSummary is the title and the summary of a run.
+type RunSummary struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// SummarizeFlow runs a workflow writing a short title and summary of the
+// answer to the question, used to browse and continue the previous runs.
+func SummarizeFlow(model string, question string, answer string) (*RunSummary, error) {
+	if len(answer) > maxSummarizedAnswer {
+		answer = answer[:maxSummarizedAnswer] + "\n..."
+	}
+
+	flow := &swarm.SimpleFlow{
+		Name:     "summarize-workflow",
+		Model:    model,
+		MaxTurns: 1,
+		System:   "You are an expert on Kubernetes summarizing troubleshooting runs for the user.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "summarize",
+				Instructions: summarizePrompt,
+				Inputs: map[string]interface{}{
+					"question": question,
+					"answer":   answer,
+				},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return nil, err
+	}
+
+	flow.Initialize()
+	result, _, err := runTracedFlow(context.Background(), flow, client)
+	if err != nil {
+		return nil, err
+	}
+	return parseRunSummary(result)
+}
+
+// parseRunSummary parses the JSON response of the summarize workflow.
+func parseRunSummary(response string) (*RunSummary, error) {
+	var summary RunSummary
+	if err := unmarshalLLMJSON(stripThoughts(response), &summary); err != nil {
+		return nil, err
+	}
+
+	summary.Title = strings.Join(strings.Fields(strings.Trim(summary.Title, `"# `)), " ")
+	summary.Summary = strings.TrimSpace(summary.Summary)
+	if summary.Title == "" {
+		return nil, fmt.Errorf("no title in the summary %q", response)
+	}
+	if title := []rune(summary.Title); len(title) > maxTitleLength {
+		summary.Title = string(title[:maxTitleLength-3]) + "..."
+	}
+	return &summary, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRunSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     *RunSummary
+		wantErr  bool
+	}{
+		{
+			name:     "json",
+			response: `{"title": "nginx Pod CrashLoopBackOff in default", "summary": "The nginx Pod crashed on a missing ConfigMap key."}`,
+			want:     &RunSummary{Title: "nginx Pod CrashLoopBackOff in default", Summary: "The nginx Pod crashed on a missing ConfigMap key."},
+		},
+		{
+			name:     "fenced with a markdown title",
+			response: "<think>short</think>\n```json\n{\"title\": \"# Node   disk pressure\", \"summary\": \" Node-1 evicts Pods. \"}\n```",
+			want:     &RunSummary{Title: "Node disk pressure", Summary: "Node-1 evicts Pods."},
+		},
+		{
+			name:     "long title",
+			response: `{"title": "` + strings.Repeat("a", 100) + `", "summary": ""}`,
+			want:     &RunSummary{Title: strings.Repeat("a", 77) + "..."},
+		},
+		{
+			name:     "no title",
+			response: `{"summary": "The cluster is healthy."}`,
+			wantErr:  true,
+		},
+		{
+			name:     "not json",
+			response: "The cluster is healthy.",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRunSummary(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRunSummary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRunSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
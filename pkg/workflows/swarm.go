@@ -16,14 +16,52 @@ limitations under the License.
 package workflows
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 
+	"github.com/feiskyer/kube-copilot/pkg/artifacts"
+	"github.com/feiskyer/kube-copilot/pkg/cassette"
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
 	"github.com/feiskyer/kube-copilot/pkg/tools"
 	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
 )
 
+var (
+	// Provider selects the LLM provider (openai or azure). When empty, the
+	// provider is detected from the environment variables.
+	Provider string
+	// Language is the language of the responses. Defaults to the language of the question.
+	Language string
+	// Cassette records the LLM interactions, or replays them instead of
+	// calling the provider when it is loaded for replay.
+	Cassette *cassette.Cassette
+	// AirGapped rejects the LLM providers outside of the local network.
+	AirGapped bool
+	// ArtifactStore, if set, keeps the tool outputs larger than
+	// maxObservationSize, of which only an excerpt enters the chat history.
+	ArtifactStore *artifacts.Store
+)
+
+// maxObservationSize is the size above which a tool output is saved as an artifact.
+const maxObservationSize = 16 * 1024
+
+// defaultOpenAIBaseURL is the endpoint of OpenAI when OPENAI_API_BASE is not set.
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// localDomains are the DNS suffixes of the local network.
+var localDomains = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa", ".svc", ".cluster.local"}
+
+// lookupIP resolves the host names; it is replaced in tests.
+var lookupIP = net.LookupIP
+
 var (
 	// auditFunc is a Swarm function that conducts a structured security audit of a Kubernetes Pod.
 	trivyFunc = swarm.NewAgentFunction(
@@ -35,7 +73,8 @@ var (
 				return nil, fmt.Errorf("image not provided")
 			}
 
-			result, err := tools.Trivy(image)
+			recordUsage(func(u *Usage) { u.ToolCalls++ })
+			result, err := tools.CopilotTools["trivy"](image)
 			if err != nil {
 				return nil, err
 			}
@@ -48,16 +87,22 @@ var (
 	)
 
 	// kubectlFunc is a Swarm function that runs kubectl command.
-	kubectlFunc = swarm.NewAgentFunction(
-		"kubectl",
-		"Run kubectl command",
+	kubectlFunc = newKubectlFunc("Run kubectl command", false)
+
+	// readOnlyKubectlFunc is a Swarm function that runs read-only kubectl
+	// commands, for the workflows reporting on the cluster without changing it.
+	readOnlyKubectlFunc = newKubectlFunc("Run read-only kubectl command (e.g. get, describe, logs or top)", true)
+
+	pythonFunc = swarm.NewAgentFunction(
+		"python",
+		"Run python code",
 		func(args map[string]interface{}) (interface{}, error) {
-			command, ok := args["command"].(string)
+			code, ok := args["code"].(string)
 			if !ok {
-				return nil, fmt.Errorf("command not provided")
+				return nil, fmt.Errorf("code not provided")
 			}
 
-			result, err := tools.Kubectl(command)
+			result, err := runGuardedTool("python", code)
 			if err != nil {
 				return nil, err
 			}
@@ -65,43 +110,155 @@ var (
 			return result, nil
 		},
 		[]swarm.Parameter{
-			{Name: "command", Type: reflect.TypeOf(""), Required: true},
+			{Name: "code", Type: reflect.TypeOf(""), Required: true},
 		},
 	)
+)
 
-	pythonFunc = swarm.NewAgentFunction(
-		"python",
-		"Run python code",
+// newKubectlFunc creates a Swarm function that runs kubectl command, only the
+// read-only ones if readOnly is set.
+func newKubectlFunc(description string, readOnly bool) swarm.AgentFunction {
+	return swarm.NewAgentFunction(
+		"kubectl",
+		description,
 		func(args map[string]interface{}) (interface{}, error) {
-			code, ok := args["code"].(string)
+			command, ok := args["command"].(string)
 			if !ok {
-				return nil, fmt.Errorf("code not provided")
+				return nil, fmt.Errorf("command not provided")
+			}
+			if readOnly && !tools.IsReadOnlyKubectl(command) {
+				return nil, fmt.Errorf("command %q is not allowed: this workflow only runs read-only kubectl commands", command)
 			}
 
-			result, err := tools.PythonREPL(code)
+			result, err := runGuardedTool("kubectl", command)
 			if err != nil {
 				return nil, err
 			}
 
-			return result, nil
+			return compactObservation(command, result), nil
 		},
 		[]swarm.Parameter{
-			{Name: "code", Type: reflect.TypeOf(""), Required: true},
+			{Name: "command", Type: reflect.TypeOf(""), Required: true},
 		},
 	)
-)
+}
 
 // NewSwarm creates a new Swarm client.
 func NewSwarm() (*swarm.Swarm, error) {
+	client, err := newOpenAIClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return swarm.NewSwarm(&tracedClient{OpenAIClient: client}), nil
+}
+
+// CheckLLM sends a minimal chat completion request to verify the credentials
+// of the configured provider and the availability of the model.
+func CheckLLM(ctx context.Context, model string) error {
+	client, err := newOpenAIClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CreateChatCompletion(ctx, openai.ChatCompletionNewParams{
+		Model:    openai.F(model),
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{openai.UserMessage("ping")}),
+	})
+	return err
+}
+
+// newOpenAIClient creates the LLM client from environment variables,
+// wrapped by the Cassette if any.
+func newOpenAIClient() (swarm.OpenAIClient, error) {
+	if Cassette != nil && !Cassette.Recording() {
+		return Cassette.Client(nil), nil
+	}
+
+	client, err := newProviderClient()
+	if err != nil {
+		return nil, err
+	}
+	if Cassette != nil {
+		return Cassette.Client(client), nil
+	}
+	return client, nil
+}
+
+// LLMEndpoint returns the base URL of the configured LLM provider, or "" if none is configured.
+func LLMEndpoint() string {
+	if os.Getenv("OPENAI_API_KEY") != "" && Provider != "azure" {
+		if baseURL := os.Getenv("OPENAI_API_BASE"); baseURL != "" {
+			return baseURL
+		}
+		return defaultOpenAIBaseURL
+	}
+	if os.Getenv("AZURE_OPENAI_API_KEY") != "" && Provider != "openai" {
+		return os.Getenv("AZURE_OPENAI_API_BASE")
+	}
+	return ""
+}
+
+// IsLocalEndpoint returns true if the URL points to the local network: a
+// loopback or private address, a single-label or local domain name (e.g. a
+// Kubernetes Service), or a name resolving only to private addresses.
+func IsLocalEndpoint(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return localIP(ip)
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, domain := range localDomains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+
+	ips, err := lookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !localIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func localIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+}
+
+// checkAirGapped rejects the LLM endpoints outside of the local network in air-gapped mode.
+func checkAirGapped(endpoint string) error {
+	if AirGapped && !IsLocalEndpoint(endpoint) {
+		return fmt.Errorf("%w: the LLM endpoint %s is not on the local network, set OPENAI_API_BASE to a local model server (e.g. Ollama or vLLM)", errdefs.ErrAirGapped, endpoint)
+	}
+	return nil
+}
+
+// newProviderClient creates the client of the configured LLM provider.
+func newProviderClient() (swarm.OpenAIClient, error) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey != "" {
+	if apiKey != "" && Provider != "azure" {
+		if err := checkAirGapped(LLMEndpoint()); err != nil {
+			return nil, err
+		}
 		baseURL := os.Getenv("OPENAI_API_BASE")
 		if baseURL == "" {
-			return swarm.NewSwarm(swarm.NewOpenAIClient(apiKey)), nil
+			return newChatClient([]string{"openai", apiKey}, option.WithAPIKey(apiKey)), nil
 		}
 
 		// OpenAI compatible LLM
-		return swarm.NewSwarm(swarm.NewOpenAIClientWithBaseURL(apiKey, baseURL)), nil
+		return newChatClient([]string{"openai", apiKey, baseURL}, option.WithAPIKey(apiKey), option.WithBaseURL(baseURL)), nil
 	}
 
 	azureAPIKey := os.Getenv("AZURE_OPENAI_API_KEY")
@@ -110,9 +267,13 @@ func NewSwarm() (*swarm.Swarm, error) {
 	if azureAPIVersion == "" {
 		azureAPIVersion = "2025-02-01-preview"
 	}
-	if azureAPIKey != "" && azureAPIBase != "" {
-		return swarm.NewSwarm(swarm.NewAzureOpenAIClient(azureAPIKey, azureAPIBase, azureAPIVersion)), nil
+	if azureAPIKey != "" && azureAPIBase != "" && Provider != "openai" {
+		if err := checkAirGapped(azureAPIBase); err != nil {
+			return nil, err
+		}
+		return newChatClient([]string{"azure", azureAPIKey, azureAPIBase, azureAPIVersion},
+			azure.WithEndpoint(azureAPIBase, azureAPIVersion), azure.WithAPIKey(azureAPIKey)), nil
 	}
 
-	return nil, fmt.Errorf("OPENAI_API_KEY or AZURE_OPENAI_API_KEY is not set")
+	return nil, fmt.Errorf("%w: OPENAI_API_KEY or AZURE_OPENAI_API_KEY is not set", errdefs.ErrProviderAuth)
 }
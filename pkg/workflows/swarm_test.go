@@ -0,0 +1,96 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+)
+
+func TestIsLocalEndpoint(t *testing.T) {
+	defer func(lookup func(string) ([]net.IP, error)) { lookupIP = lookup }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "llm.corp.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "mixed.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3"), net.ParseIP("20.1.2.3")}, nil
+		case "api.openai.com":
+			return []net.IP{net.ParseIP("162.159.140.245")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "http://localhost:11434/v1", want: true},
+		{url: "http://127.0.0.1:8000/v1", want: true},
+		{url: "http://[::1]:8000/v1", want: true},
+		{url: "http://192.168.1.10/v1", want: true},
+		{url: "http://ollama:11434/v1", want: true},
+		{url: "http://vllm.ai.svc.cluster.local/v1", want: true},
+		{url: "https://llm.corp.example.com/v1", want: true},
+		{url: "https://mixed.example.com/v1", want: false},
+		{url: "https://api.openai.com/v1", want: false},
+		{url: "https://8.8.8.8/v1", want: false},
+		{url: "https://unknown.example.com/v1", want: false},
+		{url: "not a url", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsLocalEndpoint(tt.url); got != tt.want {
+			t.Errorf("IsLocalEndpoint(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestAirGappedProvider(t *testing.T) {
+	defer func() { AirGapped = false }()
+	AirGapped = true
+	t.Setenv("OPENAI_API_KEY", "key")
+
+	t.Setenv("OPENAI_API_BASE", "")
+	if _, err := newProviderClient(); !errors.Is(err, errdefs.ErrAirGapped) {
+		t.Errorf("newProviderClient() error = %v, want ErrAirGapped for OpenAI", err)
+	}
+
+	t.Setenv("OPENAI_API_BASE", "http://localhost:11434/v1")
+	if _, err := newProviderClient(); err != nil {
+		t.Errorf("newProviderClient() error = %v, want a local model to be allowed", err)
+	}
+}
+
+func TestNewChatClientReuse(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_API_BASE", "http://127.0.0.1:8000/v1")
+	first, err := newProviderClient()
+	if err != nil {
+		t.Fatalf("newProviderClient() error = %v", err)
+	}
+	second, _ := newProviderClient()
+	if first != second {
+		t.Errorf("newProviderClient() created a new client for the same configuration")
+	}
+
+	t.Setenv("OPENAI_API_BASE", "http://127.0.0.1:9000/v1")
+	if third, _ := newProviderClient(); third == first {
+		t.Errorf("newProviderClient() reused the client of another endpoint")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// cachedResultNote prefixes the observations served from the tool cache so
+// that the model knows the cluster was not queried again.
+const cachedResultNote = "(Cached result of an identical earlier call in this run; the cluster was not queried again.)\n"
+
+// toolCache holds the results of the read-only tool calls of a single run.
+type toolCache map[string]string
+
+// toolCacheKey returns the cache key of a tool call. Whitespace differences
+// in the input do not matter.
+func toolCacheKey(name, input string) string {
+	return name + "\x00" + strings.Join(strings.Fields(input), " ")
+}
+
+// isCacheableTool returns true if the result of the tool call only depends on
+// the cluster state: a read-only kubectl command that does not stream, or a
+// plugin declared read-only.
+func isCacheableTool(name, input string) bool {
+	if name == "kubectl" {
+		return tools.IsReadOnlyKubectl(input) && !isStreamingKubectl(input)
+	}
+	return tools.IsReadOnlyPlugin(name)
+}
+
+// isStreamingKubectl returns true if the kubectl command follows or watches
+// its output, whose result differs on every call.
+func isStreamingKubectl(command string) bool {
+	for _, field := range strings.Fields(command) {
+		flag, _, _ := strings.Cut(field, "=")
+		switch flag {
+		case "-f", "--follow", "-w", "--watch", "--watch-only":
+			if flag == "-f" && !strings.Contains(" "+command+" ", " logs ") {
+				// "-f" is --filename for the other subcommands.
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the cached result of the tool call.
+func (c toolCache) lookup(name, input string) (string, bool) {
+	if !isCacheableTool(name, input) {
+		return "", false
+	}
+	result, ok := c[toolCacheKey(name, input)]
+	return result, ok
+}
+
+// store caches the result of a successful read-only tool call.
+func (c toolCache) store(name, input, result string) {
+	if isCacheableTool(name, input) {
+		c[toolCacheKey(name, input)] = result
+	}
+}
+
+// invalidate drops all the cached results if the tool call may change the
+// cluster.
+func (c toolCache) invalidate(name, input string) {
+	if tools.IsMutatingTool(name, input) {
+		clear(c)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+func TestIsCacheableTool(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		cacheable bool
+	}{
+		{"kubectl", "get pods -n default", true},
+		{"kubectl", "kubectl describe pod nginx", true},
+		{"kubectl", "logs nginx --tail 100", true},
+		{"kubectl", "logs -f nginx", false},
+		{"kubectl", "get pods --watch", false},
+		{"kubectl", "get pods -w", false},
+		{"kubectl", "get -f deploy.yaml", true},
+		{"kubectl", "delete pod nginx", false},
+		{"python", "print(1)", false},
+		{"trivy", "nginx:latest", false},
+	}
+	for _, test := range tests {
+		if got := isCacheableTool(test.name, test.input); got != test.cacheable {
+			t.Errorf("isCacheableTool(%q, %q) = %v, want %v", test.name, test.input, got, test.cacheable)
+		}
+	}
+}
+
+func TestExecuteToolCache(t *testing.T) {
+	calls := map[string]int{}
+	original := tools.CopilotTools["kubectl"]
+	tools.CopilotTools["kubectl"] = func(command string) (string, error) {
+		calls[command]++
+		return "output of " + command, nil
+	}
+	defer func() { tools.CopilotTools["kubectl"] = original }()
+
+	flow := &ReActFlow{PlanTracker: NewPlanTracker()}
+	run := func(input string) string {
+		return flow.ExecuteTool(context.Background(), "kubectl", input)
+	}
+
+	first := run("get pods -n default")
+	second := run("get  pods -n default ")
+	if calls["get pods -n default"] != 1 || calls["get  pods -n default "] != 0 {
+		t.Fatalf("identical read-only calls ran %v, want once", calls)
+	}
+	if second != cachedResultNote+first {
+		t.Errorf("cached observation = %q, want the earlier result %q", second, first)
+	}
+
+	run("logs -f nginx")
+	run("logs -f nginx")
+	if calls["logs -f nginx"] != 2 {
+		t.Errorf("streaming command ran %d times, want 2", calls["logs -f nginx"])
+	}
+
+	run("delete pod nginx")
+	if got := run("get pods -n default"); strings.HasPrefix(got, cachedResultNote) {
+		t.Errorf("mutating call did not invalidate the cache: %q", got)
+	}
+	if calls["get pods -n default"] != 2 {
+		t.Errorf("read-only call ran %d times after invalidation, want 2", calls["get pods -n default"])
+	}
+}
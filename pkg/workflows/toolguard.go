@@ -0,0 +1,80 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"fmt"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+// ConfirmToolCall, if set, is called before the workflows without their own
+// confirmation hook (e.g. the audit or analysis ones) run a tool call that may
+// change the cluster. It returns the (possibly edited) input and whether to run it.
+var ConfirmToolCall func(call ToolCall) (ToolCall, bool)
+
+// toolDecision is the outcome of guardToolCall.
+type toolDecision int
+
+const (
+	// toolAllowed means the tool call can run.
+	toolAllowed toolDecision = iota
+	// toolSkipped means the tool call is skipped in dry-run mode.
+	toolSkipped
+	// toolDeclined means the user declined the tool call.
+	toolDeclined
+)
+
+// guardToolCall checks a tool call before it runs, for all the workflows. In
+// dry-run mode, only the runbooks and the read-only kubectl commands and
+// plugins run. The other calls that may change the cluster are confirmed with
+// confirm, if set, unless the read-only mode rejects them anyway. It returns
+// the call to run, with the input possibly edited by the user.
+func guardToolCall(call ToolCall, dryRun bool, confirm func(ToolCall) (ToolCall, bool)) (ToolCall, toolDecision) {
+	if dryRun && call.Name != "runbooks" && !(call.Name == "kubectl" && tools.IsReadOnlyKubectl(call.Input)) && !tools.IsReadOnlyPlugin(call.Name) {
+		return call, toolSkipped
+	}
+	if confirm != nil && !tools.ReadOnly && tools.IsMutatingTool(call.Name, call.Input) {
+		edited, approved := confirm(call)
+		if !approved {
+			return call, toolDeclined
+		}
+		// The edited call is checked by the tool itself, e.g. in read-only mode.
+		return ToolCall{Name: call.Name, Input: edited.Input}, toolAllowed
+	}
+	return call, toolAllowed
+}
+
+// skippedObservation is the observation of a tool call skipped in dry-run mode.
+func skippedObservation(name string) string {
+	return fmt.Sprintf("Dry-run mode: tool %s was not executed. Assume it succeeded and continue with the remaining steps.", name)
+}
+
+// declinedObservation is the observation of a tool call declined by the user.
+func declinedObservation(input string) string {
+	return fmt.Sprintf("The user declined to run the command %q. Do not retry it; continue with the remaining steps or provide the final answer.", input)
+}
+
+// runGuardedTool runs a tool call of the swarm functions, confirmed with
+// ConfirmToolCall like the tool calls of the ReAct workflows.
+func runGuardedTool(name, input string) (string, error) {
+	call, decision := guardToolCall(ToolCall{Name: name, Input: input}, false, ConfirmToolCall)
+	if decision == toolDeclined {
+		return declinedObservation(call.Input), nil
+	}
+	recordUsage(func(u *Usage) { u.ToolCalls++ })
+	return tools.CopilotTools[name](call.Input)
+}
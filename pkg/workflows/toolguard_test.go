@@ -0,0 +1,101 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/feiskyer/kube-copilot/pkg/tools"
+)
+
+func TestGuardToolCall(t *testing.T) {
+	edit := func(call ToolCall) (ToolCall, bool) {
+		return ToolCall{Name: call.Name, Input: call.Input + " --dry-run=server"}, true
+	}
+	decline := func(call ToolCall) (ToolCall, bool) { return call, false }
+	tests := []struct {
+		name         string
+		call         ToolCall
+		dryRun       bool
+		confirm      func(ToolCall) (ToolCall, bool)
+		want         toolDecision
+		wantInput    string
+		wantReadOnly bool
+	}{
+		{name: "read-only kubectl", call: ToolCall{Name: "kubectl", Input: "get pods"}, dryRun: true, confirm: decline, want: toolAllowed, wantInput: "get pods"},
+		{name: "dry-run", call: ToolCall{Name: "kubectl", Input: "delete pod nginx"}, dryRun: true, confirm: decline, want: toolSkipped, wantInput: "delete pod nginx"},
+		{name: "dry-run python", call: ToolCall{Name: "python", Input: "print(1)"}, dryRun: true, want: toolSkipped, wantInput: "print(1)"},
+		{name: "declined", call: ToolCall{Name: "python", Input: "print(1)"}, confirm: decline, want: toolDeclined, wantInput: "print(1)"},
+		{name: "edited", call: ToolCall{Name: "kubectl", Input: "delete pod nginx"}, confirm: edit, want: toolAllowed, wantInput: "delete pod nginx --dry-run=server"},
+		{name: "no confirmation", call: ToolCall{Name: "kubectl", Input: "delete pod nginx"}, want: toolAllowed, wantInput: "delete pod nginx"},
+		{name: "read-only mode", call: ToolCall{Name: "kubectl", Input: "delete pod nginx"}, confirm: decline, want: toolAllowed, wantInput: "delete pod nginx", wantReadOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools.ReadOnly = tt.wantReadOnly
+			defer func() { tools.ReadOnly = false }()
+			call, got := guardToolCall(tt.call, tt.dryRun, tt.confirm)
+			if got != tt.want || call.Input != tt.wantInput {
+				t.Errorf("guardToolCall() = %q %v, want %q %v", call.Input, got, tt.wantInput, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunGuardedTool(t *testing.T) {
+	original := tools.CopilotTools["kubectl"]
+	defer func() { tools.CopilotTools["kubectl"] = original }()
+	defer func() { ConfirmToolCall = nil }()
+	var executed []string
+	tools.CopilotTools["kubectl"] = func(command string) (string, error) {
+		executed = append(executed, command)
+		return "ok", nil
+	}
+	ConfirmToolCall = func(call ToolCall) (ToolCall, bool) { return call, false }
+
+	if got, err := runGuardedTool("kubectl", "get pods"); err != nil || got != "ok" {
+		t.Errorf("runGuardedTool(get) = %q, %v, want ok", got, err)
+	}
+	if got, err := runGuardedTool("kubectl", "delete pod nginx"); err != nil || !strings.Contains(got, "declined") {
+		t.Errorf("runGuardedTool(delete) = %q, %v, want the declined observation", got, err)
+	}
+	if len(executed) != 1 || executed[0] != "get pods" {
+		t.Errorf("executed = %q, want only the read-only command", executed)
+	}
+}
+
+func TestReadOnlyKubectlFunc(t *testing.T) {
+	original := tools.CopilotTools["kubectl"]
+	defer func() { tools.CopilotTools["kubectl"] = original }()
+	var executed []string
+	tools.CopilotTools["kubectl"] = func(command string) (string, error) {
+		executed = append(executed, command)
+		return "ok", nil
+	}
+
+	if _, err := readOnlyKubectlFunc.Call(map[string]interface{}{"command": "get pods -n default"}); err != nil {
+		t.Errorf("readOnlyKubectlFunc(get) error = %v", err)
+	}
+	for _, command := range []string{"delete pvc data-0", "patch pv data --patch {}", "--unknown get delete pod x"} {
+		if _, err := readOnlyKubectlFunc.Call(map[string]interface{}{"command": command}); err == nil {
+			t.Errorf("readOnlyKubectlFunc(%q) should be rejected", command)
+		}
+	}
+	if len(executed) != 1 {
+		t.Errorf("executed = %q, want only the read-only command", executed)
+	}
+}
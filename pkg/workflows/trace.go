@@ -0,0 +1,81 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+
+	"github.com/feiskyer/kube-copilot/pkg/pathtrace"
+	"github.com/feiskyer/swarm-go"
+)
+
+const tracePrompt = `As an expert on Kubernetes networking, explain why the HTTP requests to {url} in namespace {namespace} fail, or confirm that the path is healthy.
+
+The automated trace followed the requests hop by hop (Ingress or Gateway API route, Gateway, Service, Endpoints, Pod readiness and container port) and produced the table given in the input, with the first broken hop.
+
+# Steps
+
+1. **Verify**: Use kubectl to confirm the first broken hop and the warnings (e.g. "kubectl describe ingress <name> -n <namespace>", "kubectl get endpointslices -n <namespace> -l kubernetes.io/service-name=<service>", "kubectl describe pod <pod> -n <namespace>").
+2. **Root Cause**: Find why the hop is broken (e.g. a typo in the backend Service name or port, a selector not matching the Pod labels, failing readiness probes, a targetPort not matching the container port, an HTTPRoute not accepted by its Gateway). Check the logs of the ingress controller or Gateway when the route itself is broken.
+3. **Recommend**: Give concrete fixes, with the YAML snippets or kubectl commands to apply them.
+
+# Output Format
+
+Start with the hop-by-hop table given in the input, unchanged, then explain the root cause, followed by one section per issue:
+
+## 1. <title of the issue>
+
+- **Severity**: <CRITICAL, HIGH, MEDIUM or LOW>
+- **Findings**: <the hop, what is wrong and the evidence>
+- **How to resolve**: <step-by-step fix>
+
+If no hop is broken, say that the path is healthy and mention the warnings.
+`
+
+// TraceFlow explains the first broken hop of the path of the requests to a URL.
+func TraceFlow(model string, namespace string, trace *pathtrace.Trace, verbose bool) (string, error) {
+	flow := &swarm.SimpleFlow{
+		Name:     "trace-workflow",
+		Model:    model,
+		MaxTurns: 30,
+		Verbose:  verbose,
+		System:   "You are an expert on Kubernetes networking helping the user to troubleshoot the HTTP requests to their applications.",
+		Steps: []swarm.SimpleFlowStep{
+			{
+				Name:         "trace",
+				Instructions: prompt("trace"),
+				Inputs: map[string]interface{}{
+					"url":       trace.URL,
+					"namespace": namespace,
+					"trace":     trace.Format(),
+				},
+				Functions: []swarm.AgentFunction{readOnlyKubectlFunc},
+			},
+		},
+	}
+
+	client, err := NewSwarm()
+	if err != nil {
+		return "", err
+	}
+
+	flow.Initialize()
+	result, err := runAnswerFlow(context.Background(), flow, client)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/feiskyer/kube-copilot/pkg/errdefs"
+	"github.com/feiskyer/kube-copilot/pkg/telemetry"
+	"github.com/feiskyer/swarm-go"
+	"github.com/openai/openai-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedClient wraps a swarm.OpenAIClient and records a span and the token usage for each LLM call.
+type tracedClient struct {
+	swarm.OpenAIClient
+}
+
+// CreateChatCompletion implements swarm.OpenAIClient.
+func (c *tracedClient) CreateChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	ctx, span := telemetry.StartSpan(ctx, "llm.chat_completion",
+		attribute.String("llm.model", params.Model.Value),
+		attribute.Int("llm.messages", len(params.Messages.Value)),
+	)
+
+	resp, err := c.OpenAIClient.CreateChatCompletion(ctx, params)
+	if err == nil && resp != nil {
+		recordUsage(func(u *Usage) {
+			u.Requests++
+			u.PromptTokens += resp.Usage.PromptTokens
+			u.CompletionTokens += resp.Usage.CompletionTokens
+		})
+		span.SetAttributes(
+			attribute.Int64("llm.usage.prompt_tokens", resp.Usage.PromptTokens),
+			attribute.Int64("llm.usage.completion_tokens", resp.Usage.CompletionTokens),
+		)
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+		err = fmt.Errorf("%w: %v", errdefs.ErrProviderAuth, err)
+	}
+	telemetry.EndSpan(span, err)
+	return resp, err
+}
+
+// runTracedFlow runs a SimpleFlow inside a span named after the workflow.
+// The configured response Language is appended to the system prompt.
+func runTracedFlow(ctx context.Context, flow *swarm.SimpleFlow, client *swarm.Swarm) (string, []map[string]interface{}, error) {
+	if Language != "" {
+		flow.System = strings.TrimSpace(fmt.Sprintf("%s\n\nWrite all explanations in %s.", flow.System, Language))
+	}
+
+	ctx, span := telemetry.StartSpan(ctx, "workflow."+flow.Name, attribute.String("llm.model", flow.Model))
+	result, history, err := flow.Run(ctx, client)
+	telemetry.EndSpan(span, err)
+	return result, history, err
+}
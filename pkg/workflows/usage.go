@@ -0,0 +1,72 @@
+/*
+Copyright 2023 - Present, Pengfei Ni
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workflows
+
+import (
+	"sync"
+
+	"github.com/feiskyer/kube-copilot/pkg/llms"
+	"github.com/feiskyer/kube-copilot/pkg/observation"
+)
+
+// Usage is the LLM and tool usage accumulated by all the workflows of the process.
+type Usage struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"promptTokens"`
+	CompletionTokens int64 `json:"completionTokens"`
+	Iterations       int64 `json:"iterations"`
+	ToolCalls        int64 `json:"toolCalls"`
+	// CachedToolCalls are the read-only tool calls answered from the cache
+	// of the run instead of being executed again.
+	CachedToolCalls int64 `json:"cachedToolCalls"`
+	// RawObservationTokens and ObservationTokens are the estimated tokens of
+	// the kubectl outputs before and after their compaction (see observation.Compact).
+	RawObservationTokens int64 `json:"rawObservationTokens"`
+	ObservationTokens    int64 `json:"observationTokens"`
+	// UngroundedClaims are the values of the final answers not found in the
+	// observations of their runs (see ungroundedClaims).
+	UngroundedClaims int64 `json:"ungroundedClaims"`
+}
+
+var (
+	usageLock sync.Mutex
+	usage     Usage
+)
+
+// GetUsage returns the usage accumulated so far.
+func GetUsage() Usage {
+	usageLock.Lock()
+	defer usageLock.Unlock()
+	return usage
+}
+
+// recordUsage updates the accumulated usage.
+func recordUsage(update func(u *Usage)) {
+	usageLock.Lock()
+	defer usageLock.Unlock()
+	update(&usage)
+}
+
+// compactObservation compacts the output of a kubectl command before it
+// enters the prompt and records the tokens saved.
+func compactObservation(command, output string) string {
+	compacted := observation.Compact(command, output)
+	recordUsage(func(u *Usage) {
+		u.RawObservationTokens += int64(llms.EstimateTokens(output))
+		u.ObservationTokens += int64(llms.EstimateTokens(compacted))
+	})
+	return compacted
+}
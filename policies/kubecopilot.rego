@@ -0,0 +1,49 @@
+# Example action policy of kube-copilot:
+#
+#   kube-copilot execute --policy policies/kubecopilot.rego "..."
+#
+# Every tool execution is evaluated against data.kubecopilot.deny and
+# rejected when any message is returned. See the README for the input.
+package kubecopilot
+
+import rego.v1
+
+mutating_verbs := {"apply", "create", "delete", "drain", "edit", "label", "annotate", "patch", "replace", "rollout", "scale", "set", "taint", "cordon", "uncordon"}
+
+protected_namespaces := {"kube-system", "kube-public", "cert-manager"}
+
+mutating if {
+	input.tool == "kubectl"
+	mutating_verbs[input.verb]
+}
+
+deny contains msg if {
+	mutating
+	protected_namespaces[input.namespace]
+	msg := sprintf("kubectl %s is not allowed in the protected namespace %s", [input.verb, input.namespace])
+}
+
+deny contains msg if {
+	mutating
+	input.allNamespaces
+	msg := sprintf("kubectl %s is not allowed across all namespaces", [input.verb])
+}
+
+deny contains msg if {
+	mutating
+	startswith(input.cluster, "prod")
+	not business_hours
+	msg := sprintf("changes to %s are only allowed on weekdays between 09:00 and 17:00", [input.cluster])
+}
+
+deny contains msg if {
+	input.tool == "python"
+	startswith(input.cluster, "prod")
+	msg := "python scripts are not allowed against production clusters"
+}
+
+business_hours if {
+	not input.weekday in {"Saturday", "Sunday"}
+	input.hour >= 9
+	input.hour < 17
+}